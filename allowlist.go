@@ -0,0 +1,162 @@
+package distroseed
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+)
+
+const (
+	allowlistRefreshInterval = 5 * time.Minute
+	allowlistMaxBytes        = 4 << 20 // Largest list accepted from the endpoint
+)
+
+// peerAllowlist restricts peer connections to the listed networks, for private deployments
+// that distribute images within a corporate network. It's the client's IP blocklist, matching
+// every address that isn't allowed, so peers outside it are neither dialed nor accepted. The
+// networks are given up front and can be extended by a list fetched from an endpoint, which is
+// refreshed periodically.
+type peerAllowlist struct {
+	static   []netip.Prefix
+	url      string
+	prefixes atomic.Pointer[[]netip.Prefix] // Static and fetched networks in effect
+}
+
+// parseAllowlist reads networks separated by commas, spaces or lines, as CIDRs or single
+// addresses. Anything after a # on a line is a comment.
+func parseAllowlist(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(strings.NewReader(s))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+			if strings.Contains(field, "/") {
+				p, err := netip.ParsePrefix(field)
+				if err != nil {
+					return nil, fmt.Errorf("❌ Invalid network '%s' in peer allowlist", field)
+				}
+				prefixes = append(prefixes, p.Masked())
+				continue
+			}
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("❌ Invalid address '%s' in peer allowlist", field)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		}
+	}
+	return prefixes, scanner.Err()
+}
+
+func newPeerAllowlist(static []netip.Prefix, url string) *peerAllowlist {
+	a := &peerAllowlist{static: static, url: url}
+	a.prefixes.Store(&static)
+	return a
+}
+
+// Allowed reports whether a peer at the address may connect
+func (a *peerAllowlist) Allowed(addr netip.Addr) bool {
+	if a == nil {
+		return true
+	}
+	addr = addr.Unmap()
+	for _, p := range *a.prefixes.Load() {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup implements iplist.Ranger, finding the addresses that aren't allowed
+func (a *peerAllowlist) Lookup(ip net.IP) (iplist.Range, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if ok && a.Allowed(addr) {
+		return iplist.Range{}, false
+	}
+	return iplist.Range{First: ip, Last: ip, Description: "not in the peer allowlist"}, true
+}
+
+// NumRanges implements iplist.Ranger
+func (a *peerAllowlist) NumRanges() int {
+	return len(*a.prefixes.Load())
+}
+
+// refresh fetches the endpoint's list and puts it in effect along with the static networks,
+// reporting whether the networks changed. On errors the networks in effect are kept.
+func (a *peerAllowlist) refresh(ctx context.Context, httpClient *http.Client) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s returned %s", a.url, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, allowlistMaxBytes))
+	if err != nil {
+		return false, err
+	}
+	fetched, err := parseAllowlist(string(body))
+	if err != nil {
+		return false, err
+	}
+	prefixes := append(slices.Clip(a.static), fetched...)
+	previous := a.prefixes.Swap(&prefixes)
+	return !slices.Equal(*previous, prefixes), nil
+}
+
+// run refreshes the list from the endpoint, closing connections to peers that are no longer
+// allowed when it changes
+func (a *peerAllowlist) run(ctx context.Context, client *torrent.Client) {
+	if a.url == "" {
+		return
+	}
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	ticker := time.NewTicker(allowlistRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := a.refresh(ctx, httpClient)
+			if err != nil {
+				log.Printf("⚠️ Could not refresh the peer allowlist, keeping the last one: %v", err)
+				continue
+			}
+			if changed {
+				log.Printf("🛡️ Peer allowlist changed, now %d networks", a.NumRanges())
+				a.dropDisallowed(client)
+			}
+		}
+	}
+}
+
+// dropDisallowed closes the connections to peers the list no longer allows
+func (a *peerAllowlist) dropDisallowed(client *torrent.Client) {
+	for _, t := range client.Torrents() {
+		for _, pc := range t.PeerConns() {
+			addrPort, err := netip.ParseAddrPort(pc.RemoteAddr.String())
+			if err == nil && !a.Allowed(addrPort.Addr()) {
+				pc.Close()
+			}
+		}
+	}
+}
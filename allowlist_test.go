@@ -0,0 +1,76 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestParseAllowlist(t *testing.T) {
+	prefixes, err := parseAllowlist("10.0.0.0/8, 192.168.1.7\n# Lab\nfd00::/8 172.16.5.9/16 # Build hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "fd00::/8", "172.16.0.0/16"}
+	if fmt.Sprint(prefixes) != fmt.Sprint(want) {
+		t.Errorf("parsed %v, want %v", prefixes, want)
+	}
+	for _, bad := range []string{"10.0.0.0/33", "intranet"} {
+		if _, err := parseAllowlist(bad); err == nil {
+			t.Errorf("%q is valid", bad)
+		}
+	}
+}
+
+func TestPeerAllowlistLookup(t *testing.T) {
+	static, _ := parseAllowlist("10.0.0.0/8")
+	a := newPeerAllowlist(static, "")
+	if _, blocked := a.Lookup(net.ParseIP("10.1.2.3")); blocked {
+		t.Error("address in an allowed network is blocked")
+	}
+	if _, blocked := a.Lookup(net.ParseIP("10.1.2.3").To16()); blocked {
+		t.Error("IPv4-mapped address in an allowed network is blocked")
+	}
+	if _, blocked := a.Lookup(net.ParseIP("8.8.8.8")); !blocked {
+		t.Error("address outside the allowed networks isn't blocked")
+	}
+	var none *peerAllowlist
+	if !none.Allowed(netip.MustParseAddr("8.8.8.8")) {
+		t.Error("peers aren't allowed without an allowlist")
+	}
+}
+
+func TestPeerAllowlistRefresh(t *testing.T) {
+	list := "192.168.0.0/16\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, list)
+	}))
+	defer server.Close()
+
+	static, _ := parseAllowlist("10.0.0.0/8")
+	a := newPeerAllowlist(static, server.URL)
+	if changed, err := a.refresh(context.Background(), server.Client()); err != nil || !changed {
+		t.Fatalf("first refresh: changed %v, %v", changed, err)
+	}
+	for addr, want := range map[string]bool{"10.0.0.1": true, "192.168.3.4": true, "172.16.0.1": false} {
+		if got := a.Allowed(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("%s allowed %v, want %v", addr, got, want)
+		}
+	}
+	if changed, _ := a.refresh(context.Background(), server.Client()); changed {
+		t.Error("refreshing the same list changed it")
+	}
+
+	// A broken list keeps the last one
+	list = "not a network"
+	if _, err := a.refresh(context.Background(), server.Client()); err == nil {
+		t.Error("broken list was accepted")
+	}
+	if !a.Allowed(netip.MustParseAddr("192.168.3.4")) {
+		t.Error("last list wasn't kept")
+	}
+}
@@ -0,0 +1,104 @@
+package announce
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/internal/swarmtest"
+)
+
+func TestAnnounceSchedulerBudgetsPerHost(t *testing.T) {
+	dir := t.TempDir()
+	client := swarmtest.NewClient(t, dir)
+	add := func(name string, trackers ...string) *torrent.Torrent {
+		meta := swarmtest.NewMeta(t, dir, name, 16<<10)
+		meta.AnnounceList = [][]string{trackers}
+		tt, err := client.AddTorrent(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tt
+	}
+	first := add("first.iso", "http://tracker.invalid/announce", "udp://tracker.invalid:6969")
+	second := add("second.iso", "http://Tracker.invalid/announce")
+	elsewhere := add("elsewhere.iso", "http://other.invalid/announce")
+	removed := add("removed.iso", "http://removed.invalid/announce")
+
+	s := NewScheduler(6, nil) // One announce per host every 10 seconds
+	ctx := context.Background()
+	for _, tt := range []*torrent.Torrent{first, second, first, elsewhere, removed} {
+		s.Request(ctx, client, tt)
+	}
+	if s.Queued() != 4 {
+		t.Fatalf("queued %d torrents, want the repeated request left out", s.Queued())
+	}
+	removed.Drop()
+
+	now := time.Now()
+	if due := s.due(now); !slices.Equal(due, []*torrent.Torrent{first, elsewhere}) {
+		t.Fatalf("due first: %v", due)
+	}
+	if due := s.due(now.Add(5 * time.Second)); len(due) != 0 {
+		t.Fatalf("announced %v before the host's budget refilled", due)
+	}
+	if due := s.due(now.Add(10 * time.Second)); !slices.Equal(due, []*torrent.Torrent{second}) {
+		t.Fatalf("due once the budget refilled: %v", due)
+	}
+	if s.Queued() != 0 {
+		t.Fatalf("%d torrents left queued", s.Queued())
+	}
+}
+
+func TestAnnounceHosts(t *testing.T) {
+	dir := t.TempDir()
+	client := swarmtest.NewClient(t, dir)
+	for name, trackers := range map[string][][]string{
+		"a.iso": {{"http://tracker.invalid/announce"}, {"udp://tracker.invalid:6969"}},
+		"b.iso": {{"http://tracker.invalid/announce", "http://other.invalid/announce"}},
+		"c.iso": nil,
+	} {
+		meta := swarmtest.NewMeta(t, dir, name, 16<<10)
+		meta.AnnounceList = trackers
+		if _, err := client.AddTorrent(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hosts := Hosts(client)
+	if len(hosts) != 2 {
+		t.Fatalf("hosts: %+v", hosts)
+	}
+	if h := hosts[0]; h.Host != "tracker.invalid" || h.Torrents != 2 || !slices.Equal(h.URLs, []string{"http://tracker.invalid/announce", "udp://tracker.invalid:6969"}) {
+		t.Errorf("busiest host: %+v", h)
+	}
+	if h := hosts[1]; h.Host != "other.invalid" || h.Torrents != 1 {
+		t.Errorf("other host: %+v", h)
+	}
+}
+
+func TestValidateBudget(t *testing.T) {
+	if err := ValidateBudget(0); err != nil {
+		t.Errorf("no limit: %v", err)
+	}
+	if err := ValidateBudget(-1); err == nil {
+		t.Error("a negative budget was accepted")
+	}
+}
+
+func TestSchedulerWithoutLimit(t *testing.T) {
+	dir := t.TempDir()
+	client := swarmtest.NewClient(t, dir)
+	tt, err := client.AddTorrent(swarmtest.NewMeta(t, dir, "a.iso", 16<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var announced []*torrent.Torrent
+	s := NewScheduler(0, func(_ context.Context, _ *torrent.Client, t *torrent.Torrent) { announced = append(announced, t) })
+	s.Request(context.Background(), client, tt)
+	if !slices.Equal(announced, []*torrent.Torrent{tt}) || s.Queued() != 0 {
+		t.Errorf("announced %v with %d queued, want the torrent announced straight away", announced, s.Queued())
+	}
+}
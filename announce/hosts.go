@@ -0,0 +1,72 @@
+package announce
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+)
+
+// trackerHosts returns the hosts a torrent announces to, each once
+func trackerHosts(t *torrent.Torrent) []string {
+	var hosts []string
+	for _, tracker := range t.Metainfo().AnnounceList.DistinctValues() {
+		if host := trackerHost(tracker); host != "" && !slices.Contains(hosts, host) {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// trackerHost returns the host of an announce URL, empty if it hasn't got one
+func trackerHost(tracker string) string {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// Host is one tracker host, with the announce URLs its torrents use
+type Host struct {
+	Host     string   `json:"host"`
+	URLs     []string `json:"urls"`
+	Torrents int      `json:"torrents"`
+}
+
+// Hosts lists the tracker hosts the torrents announce to, with each announce URL once
+// however many torrents share it, busiest first. Trackers held back while torrents are paused
+// aren't announced to, so aren't listed.
+func Hosts(client *torrent.Client) []Host {
+	byHost := make(map[string]*Host)
+	for _, t := range client.Torrents() {
+		for _, tracker := range t.Metainfo().AnnounceList.DistinctValues() {
+			host := trackerHost(tracker)
+			if host == "" {
+				continue
+			}
+			h, ok := byHost[host]
+			if !ok {
+				h = &Host{Host: host}
+				byHost[host] = h
+			}
+			if !slices.Contains(h.URLs, tracker) {
+				h.URLs = append(h.URLs, tracker)
+			}
+		}
+		for _, host := range trackerHosts(t) {
+			byHost[host].Torrents++
+		}
+	}
+	list := []Host{}
+	for _, h := range byHost {
+		slices.Sort(h.URLs)
+		list = append(list, *h)
+	}
+	slices.SortFunc(list, func(a, b Host) int {
+		return cmp.Or(cmp.Compare(b.Torrents, a.Torrents), strings.Compare(a.Host, b.Host))
+	})
+	return list
+}
@@ -0,0 +1,138 @@
+// Package announce spreads the re-announces of many torrents out over their trackers, and keeps
+// track of how trackers answer announces and scrapes.
+package announce
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	announceTick         = time.Second // How often queued announces are checked against the budgets
+	announceBurstSeconds = 10          // Seconds of a tracker host's budget that can build up while it's idle
+)
+
+// Scheduler spreads the re-announces of many torrents sharing trackers out over time,
+// with a budget of announces per minute for each tracker host. Distro torrents mostly announce
+// to the same few trackers, so 200 of them re-announcing at once would be 200 requests to one host.
+type Scheduler struct {
+	perMinute float64
+	announce  func(context.Context, *torrent.Client, *torrent.Torrent)
+
+	mu      sync.Mutex
+	queue   []*torrent.Torrent // Waiting to be announced, in the order they were asked for
+	budgets map[string]*announceBudget
+}
+
+// announceBudget is a token bucket of announces to one tracker host
+type announceBudget struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewScheduler returns a scheduler that re-announces torrents with announce, at most perMinute
+// times a minute to each tracker host, or as soon as they're requested if perMinute is 0
+func NewScheduler(perMinute int, announce func(context.Context, *torrent.Client, *torrent.Torrent)) *Scheduler {
+	return &Scheduler{perMinute: float64(perMinute), announce: announce, budgets: make(map[string]*announceBudget)}
+}
+
+// ValidateBudget checks the announces per minute to each tracker host, 0 for no limit
+func ValidateBudget(perMinute int) error {
+	if perMinute < 0 {
+		return fmt.Errorf("❌ Announces per tracker %d can't be negative", perMinute)
+	}
+	return nil
+}
+
+// Request re-announces a torrent once its trackers' hosts have budget for it, or straight away
+// without a budget. A torrent already waiting isn't queued twice.
+func (s *Scheduler) Request(ctx context.Context, client *torrent.Client, t *torrent.Torrent) {
+	if s.perMinute == 0 {
+		s.announce(ctx, client, t)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.queue, t) {
+		s.queue = append(s.queue, t)
+	}
+}
+
+// PerMinute returns the announces each tracker host is budgeted per minute, 0 for no limit
+func (s *Scheduler) PerMinute() int {
+	return int(s.perMinute)
+}
+
+// Queued returns how many torrents are waiting to be announced
+func (s *Scheduler) Queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.queue)
+}
+
+// Run announces queued torrents as their budgets allow until the context is cancelled. Without
+// a limit, there's never anything queued.
+func (s *Scheduler) Run(ctx context.Context, client *torrent.Client) {
+	if s.perMinute == 0 {
+		return
+	}
+	ticker := time.NewTicker(announceTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, t := range s.due(now) {
+				s.announce(ctx, client, t)
+			}
+		}
+	}
+}
+
+// due takes the queued torrents every tracker host of which has budget left, spending it. A
+// torrent waiting on a busy host doesn't hold up the ones behind it that announce elsewhere.
+func (s *Scheduler) due(now time.Time) []*torrent.Torrent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []*torrent.Torrent
+	s.queue = slices.DeleteFunc(s.queue, func(t *torrent.Torrent) bool {
+		select {
+		case <-t.Closed():
+			return true
+		default:
+		}
+		hosts := trackerHosts(t)
+		for _, host := range hosts {
+			if s.budget(host, now).tokens < 1 {
+				return false
+			}
+		}
+		for _, host := range hosts {
+			s.budgets[host].tokens--
+		}
+		due = append(due, t)
+		return true
+	})
+	return due
+}
+
+// budget returns a host's budget, topped up for the time since it was last looked at
+func (s *Scheduler) budget(host string, now time.Time) *announceBudget {
+	burst := max(1, s.perMinute*announceBurstSeconds/60)
+	b, ok := s.budgets[host]
+	if !ok {
+		b = &announceBudget{tokens: burst, last: now}
+		s.budgets[host] = b
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = min(burst, b.tokens+elapsed.Minutes()*s.perMinute)
+		b.last = now
+	}
+	return b
+}
@@ -0,0 +1,96 @@
+package announce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/tracker"
+	"github.com/anacrolix/torrent/tracker/udp"
+)
+
+const scrapeTimeout = 30 * time.Second
+
+// ErrNoScrape is returned for trackers whose announce URL has no scrape convention, see BEP 48
+var ErrNoScrape = errors.New("tracker doesn't support scraping")
+
+// Scrape asks a tracker for the totals of the torrents, in their order. HTTP trackers are
+// scraped with httpClient, identifying as userAgent.
+func Scrape(ctx context.Context, httpClient *http.Client, userAgent, trackerURL string, infoHashes []metainfo.Hash) (udp.ScrapeResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, scrapeTimeout)
+	defer cancel()
+	u, err := url.Parse(trackerURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "udp", "udp4", "udp6":
+		c, err := tracker.NewClient(trackerURL, tracker.NewClientOpts{})
+		if err != nil {
+			return nil, err
+		}
+		defer c.Close()
+		return c.Scrape(ctx, infoHashes)
+	case "http", "https":
+		return scrapeHTTP(ctx, httpClient, userAgent, u, infoHashes)
+	}
+	return nil, ErrNoScrape
+}
+
+// scrapeHTTP scrapes an HTTP tracker at the URL its announce URL implies, by replacing
+// "announce" at the start of the last path segment with "scrape"
+func scrapeHTTP(ctx context.Context, httpClient *http.Client, userAgent string, announce *url.URL, infoHashes []metainfo.Hash) (udp.ScrapeResponse, error) {
+	dir, last, _ := cutLast(announce.Path, "/")
+	if !strings.HasPrefix(last, "announce") {
+		return nil, ErrNoScrape
+	}
+	u := *announce
+	u.Path = dir + "/scrape" + strings.TrimPrefix(last, "announce")
+	query := u.Query()
+	for _, ih := range infoHashes {
+		query.Add("info_hash", string(ih[:]))
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	var body struct {
+		Files         map[string]udp.ScrapeInfohashResult `bencode:"files"`
+		FailureReason string                              `bencode:"failure reason"`
+	}
+	if err := bencode.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.FailureReason != "" {
+		return nil, errors.New(body.FailureReason)
+	}
+	results := make(udp.ScrapeResponse, len(infoHashes))
+	for i, ih := range infoHashes {
+		results[i] = body.Files[string(ih[:])]
+	}
+	return results, nil
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return "", s, false
+}
@@ -0,0 +1,93 @@
+package announce
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status records the outcome of the client's announces per tracker URL
+type Status struct {
+	mu       sync.Mutex
+	trackers map[string]*Health
+}
+
+// NewStatus returns a status with no announces recorded yet
+func NewStatus() *Status {
+	return &Status{trackers: make(map[string]*Health)}
+}
+
+// Health is how a tracker answered announces
+type Health struct {
+	Protocol            string    `json:"protocol"`
+	Announces           int       `json:"announces"`
+	Failures            int       `json:"failures"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastSuccess         time.Time `json:"last_success"`
+}
+
+// ProtocolStats totals the announces to the trackers of one protocol
+type ProtocolStats struct {
+	Trackers  int
+	Announces int
+	Failures  int
+}
+
+// Record counts an announce to the tracker, which failed with err unless it's empty
+func (s *Status) Record(url string, err string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.trackers[url]
+	if !ok {
+		h = &Health{Protocol: protocol(url)}
+		s.trackers[url] = h
+	}
+	h.Announces++
+	if err != "" {
+		h.Failures++
+		h.ConsecutiveFailures++
+		h.LastError = err
+	} else {
+		h.ConsecutiveFailures = 0
+		h.LastError = ""
+		h.LastSuccess = time.Now()
+	}
+}
+
+// Snapshot returns the health of each tracker announced to
+func (s *Status) Snapshot() map[string]Health {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]Health, len(s.trackers))
+	for url, h := range s.trackers {
+		snapshot[url] = *h
+	}
+	return snapshot
+}
+
+// Protocols totals the announces made over each protocol
+func (s *Status) Protocols() map[string]ProtocolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals := make(map[string]ProtocolStats)
+	for _, h := range s.trackers {
+		p := totals[h.Protocol]
+		p.Trackers++
+		p.Announces += h.Announces
+		p.Failures += h.Failures
+		totals[h.Protocol] = p
+	}
+	return totals
+}
+
+// protocol names the protocol a tracker is announced to over. The library announces to
+// udp:// trackers over IPv4 and IPv6 separately, as udp4:// and udp6://, which are kept apart.
+func protocol(trackerURL string) string {
+	u, err := url.Parse(trackerURL)
+	if err != nil || u.Scheme == "" {
+		return "unknown"
+	}
+	return strings.ToLower(u.Scheme)
+}
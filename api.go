@@ -0,0 +1,604 @@
+package distroseed
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/announce"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+// apiServer is the HTTP management API
+type apiServer struct {
+	seeder      *Seeder
+	ctx         context.Context
+	client      *torrent.Client
+	downloadDir string
+	baseConfig  runtimeConfig // Settings from flags, which reloads start from
+	configFile  string
+}
+
+func (a *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/status", a.getStatus)
+	mux.HandleFunc("GET /api/config", a.getConfig)
+	mux.HandleFunc("PATCH /api/config", a.patchConfig)
+	mux.HandleFunc("POST /api/reload", a.reload)
+	mux.HandleFunc("GET /api/torrents", a.getTorrents)
+	mux.HandleFunc("POST /api/torrents", a.addTorrent)
+	mux.HandleFunc("POST /api/torrents/{action}", a.batchTorrents)
+	mux.HandleFunc("GET /api/torrents/{infohash}/events", a.getTorrentEvents)
+	mux.HandleFunc("GET /api/torrents/{infohash}/magnet", a.getMagnet)
+	mux.HandleFunc("GET /api/torrents/{infohash}/torrent", a.getTorrentFile)
+	mux.HandleFunc("GET /api/torrents/{infohash}/files/{path...}", a.streamFile)
+	mux.HandleFunc("GET /api/mirror", a.getMirror)
+	mux.HandleFunc("GET /api/trackers", a.getTrackers)
+	mux.HandleFunc("GET /api/announces", a.getAnnounces)
+	mux.HandleFunc("GET /api/downloads", a.getDownloads)
+	mux.HandleFunc("GET /api/swarm", a.getSwarm)
+	mux.HandleFunc("GET /api/rates", a.getRates)
+	mux.HandleFunc("GET /api/sources", a.getSources)
+	mux.HandleFunc("GET /api/verifications", a.getVerifications)
+	mux.HandleFunc("GET /api/limits", a.getLimits)
+	mux.HandleFunc("POST /api/limits", a.addLimit)
+	mux.HandleFunc("DELETE /api/limits/{id}", a.removeLimit)
+	mux.HandleFunc("GET /api/cluster", a.getCluster)
+	mux.HandleFunc("POST /api/cluster/heartbeat", a.clusterHeartbeat)
+	mux.HandleFunc("GET /metrics", a.getMetrics)
+	registerDebugHandlers(mux)
+	return mux
+}
+
+// Serve the API on the listener until ctx is done. With a token set, requests must carry it,
+// and with a TLS config it's served over TLS.
+func (a *apiServer) serve(ctx context.Context, l net.Listener, token string, tlsConfig *tls.Config) {
+	log.Printf("🌐 Management API listening on %s", l.Addr())
+	handler := a.handler()
+	if token != "" {
+		handler = api.RequireToken(handler, token)
+	}
+	if err := api.Serve(ctx, l, handler, tlsConfig); err != nil {
+		log.Printf("⚠️ Management API stopped: %v", err)
+	}
+}
+
+// listenAPISocket listens on a Unix socket at path, which only the owner and group can use
+func (s *Seeder) listenAPISocket(path string) (net.Listener, error) {
+	if !s.inherited.has("api-socket") {
+		// A socket left behind by a process that didn't shut down cleanly
+		if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+			os.Remove(path)
+		}
+	}
+	l, err := s.listenOrInherit("api-socket", "unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Report the running build, how long it has been up and how many torrents are seeding
+func (a *apiServer) getStatus(w http.ResponseWriter, r *http.Request) {
+	torrents := a.client.Torrents()
+	seeding := 0
+	for _, t := range torrents {
+		if t.Info() != nil && a.seeder.downloadComplete(t) {
+			seeding++
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, struct {
+		versionInfo
+		Client        string    `json:"client"`
+		Started       time.Time `json:"started"`
+		UptimeSeconds int64     `json:"uptime_seconds"`
+		Torrents      int       `json:"torrents"`
+		Seeding       int       `json:"seeding"`
+	}{buildInfo, buildInfo.clientName(), processStarted, int64(time.Since(processStarted).Seconds()), len(torrents), seeding})
+}
+
+func (a *apiServer) getConfig(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, a.seeder.liveSettings.Get().redacted())
+}
+
+// Change the settings present in the request body, leaving the rest as they are. Changes last
+// until the next reload. With ?preview=1, only the changes that would be made are returned.
+func (a *apiServer) patchConfig(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	diff, err := a.seeder.updateConfig(a.ctx, a.client, a.downloadDir, isPreview(r), func(current runtimeConfig) (runtimeConfig, error) {
+		next := current
+		// Decoding merges into the maps
+		next.TorrentDirs = maps.Clone(current.TorrentDirs)
+		next.TorrentConns = maps.Clone(current.TorrentConns)
+		next.TorrentOptions = maps.Clone(current.TorrentOptions)
+		// Strictly like the config file, so misspelled settings aren't silently ignored
+		if err := decodeConfigStrict(body, &next); err != nil {
+			return current, err
+		}
+		// Options read from GET /api/config come back masked
+		for url, opts := range next.TorrentOptions {
+			next.TorrentOptions[url] = opts.unredacted(current.TorrentOptions[url])
+		}
+		return next, next.validate()
+	})
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, diff)
+}
+
+// addTorrentRequest is a torrent URL or magnet link to add, with the options for it
+type addTorrentRequest struct {
+	URL string `json:"url"`
+	Dir string `json:"dir,omitempty"` // Directory for its data, chosen by free space if empty
+	torrentOptions
+}
+
+var errAlreadyAdded = errors.New("torrent is already added")
+
+// Add a torrent with the options for it. Like config changes, it lasts until the next reload.
+// With ?preview=1, only the changes that would be made are returned.
+func (a *apiServer) addTorrent(w http.ResponseWriter, r *http.Request) {
+	var req addTorrentRequest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.URL == "" {
+		api.WriteError(w, http.StatusBadRequest, errors.New("a URL or magnet link is needed"))
+		return
+	}
+	diff, err := a.seeder.updateConfig(a.ctx, a.client, a.downloadDir, isPreview(r), func(current runtimeConfig) (runtimeConfig, error) {
+		if slices.Contains(current.TorrentURLs, req.URL) {
+			return current, errAlreadyAdded
+		}
+		next := current.withTorrentSource(req.URL, req.Dir, req.torrentOptions)
+		return next, next.validate()
+	})
+	switch {
+	case errors.Is(err, errAlreadyAdded):
+		api.WriteError(w, http.StatusConflict, err)
+		return
+	case err != nil:
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	case isPreview(r):
+		api.WriteJSON(w, http.StatusOK, diff)
+		return
+	}
+	t, ok := a.seeder.torrentSources.Get(req.URL)
+	if !ok {
+		api.WriteError(w, http.StatusUnprocessableEntity, fmt.Errorf("couldn't add %s, see the seeder's log", req.URL))
+		return
+	}
+	// A URL resolving to a torrent that's already there only adds its trackers and webseeds to it
+	code := http.StatusCreated
+	others := slices.DeleteFunc(a.seeder.torrentSources.URLs(t), func(url string) bool { return url == req.URL })
+	if len(others) > 0 {
+		code = http.StatusOK
+	}
+	api.WriteJSON(w, code, struct {
+		InfoHash     string         `json:"infohash"`
+		Name         string         `json:"name"`
+		Options      torrentOptions `json:"options"`
+		AlreadyAdded bool           `json:"already_added,omitempty"`
+		AddedFrom    []string       `json:"added_from,omitempty"` // The other sources of a torrent that was already added
+	}{t.InfoHash().HexString(), t.Name(), a.seeder.optionsOf(t).redacted(), len(others) > 0, others})
+}
+
+// keepTorrentFile saves a .torrent file uploaded through the Transmission or qBittorrent API in
+// the download directory, returning the path to add it from
+func (a *apiServer) keepTorrentFile(data []byte) (string, error) {
+	mi, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent file: %v", err)
+	}
+	dir := filepath.Join(a.downloadDir, importDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path, err := filepath.Abs(filepath.Join(dir, mi.HashInfoBytes().HexString()+".torrent"))
+	if err != nil {
+		return "", err
+	}
+	return path, atomicfile.Write(path, data, 0644)
+}
+
+// addSource adds a torrent source like POST /api/torrents, for the APIs of other clients that
+// don't treat adding one twice as an error. It reports whether the torrent was already there,
+// from this source or another.
+func (a *apiServer) addSource(url, dir string, opts torrentOptions) (t *torrent.Torrent, duplicate bool, err error) {
+	_, err = a.seeder.updateConfig(a.ctx, a.client, a.downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+		if slices.Contains(current.TorrentURLs, url) {
+			duplicate = true
+			return current, nil
+		}
+		next := current.withTorrentSource(url, dir, opts)
+		return next, next.validate()
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	t, ok := a.seeder.torrentSources.Get(url)
+	if !ok {
+		return nil, false, fmt.Errorf("couldn't add %s, see the seeder's log", url)
+	}
+	return t, duplicate || len(a.seeder.torrentSources.URLs(t)) > 1, nil
+}
+
+// torrentSelection picks the torrents a batch operation acts on: the ones listed, the ones with
+// the label, or the listed ones with the label when both are given
+type torrentSelection struct {
+	InfoHashes []string `json:"infohashes,omitempty"`
+	Label      string   `json:"label,omitempty"`
+}
+
+// selectTorrents finds the torrents sel selects, along with the listed infohashes that aren't in
+// the client
+func (s *Seeder) selectTorrents(client *torrent.Client, sel torrentSelection) (ts []*torrent.Torrent, notFound []string, err error) {
+	if len(sel.InfoHashes) == 0 && sel.Label == "" {
+		return nil, nil, errors.New("infohashes or a label is needed")
+	}
+	candidates := client.Torrents()
+	if len(sel.InfoHashes) > 0 {
+		candidates = nil
+		for _, hex := range sel.InfoHashes {
+			var ih metainfo.Hash
+			if err := ih.FromHexString(hex); err != nil {
+				return nil, nil, fmt.Errorf("invalid infohash '%s'", hex)
+			}
+			if t, ok := client.Torrent(ih); ok {
+				candidates = append(candidates, t)
+			} else {
+				notFound = append(notFound, hex)
+			}
+		}
+	}
+	for _, t := range candidates {
+		if sel.Label == "" || slices.Contains(s.optionsOf(t).Labels, sel.Label) {
+			ts = append(ts, t)
+		}
+	}
+	return ts, notFound, nil
+}
+
+// batchTorrent is what a batch operation did to one torrent
+type batchTorrent struct {
+	InfoHash string `json:"infohash"`
+	Name     string `json:"name"`
+	Changed  bool   `json:"changed"`         // False if it was already in the state asked for
+	Error    string `json:"error,omitempty"` // Why it couldn't be changed
+}
+
+// Pause, resume, remove or re-announce the selected torrents at once. Pauses last until restart,
+// and removals until the next reload, like other config changes.
+func (a *apiServer) batchTorrents(w http.ResponseWriter, r *http.Request) {
+	action := r.PathValue("action")
+	if !slices.Contains([]string{"pause", "resume", "remove", "reannounce"}, action) {
+		api.WriteError(w, http.StatusNotFound, fmt.Errorf("unknown action '%s', use pause, resume, remove or reannounce", action))
+		return
+	}
+	var sel torrentSelection
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&sel); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	ts, notFound, err := a.seeder.selectTorrents(a.client, sel)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	results := make([]batchTorrent, 0, len(ts))
+	for _, t := range ts {
+		results = append(results, batchTorrent{InfoHash: t.InfoHash().HexString(), Name: t.Name()})
+	}
+	switch action {
+	case "pause":
+		for i, t := range ts {
+			results[i].Changed = a.seeder.pauses.Pause(t)
+		}
+	case "resume":
+		for i, t := range ts {
+			results[i].Changed = a.seeder.pauses.Resume(t)
+		}
+	case "reannounce":
+		for i, t := range ts {
+			if a.seeder.uploadOnly.Held(results[i].InfoHash) || a.seeder.pauses.IsPaused(results[i].InfoHash) || a.seeder.schedule.Paused() {
+				results[i].Error = "not announced while it's held back or paused"
+				continue
+			}
+			a.seeder.announces.Request(a.ctx, a.client, t)
+			results[i].Changed = true
+		}
+	case "remove":
+		if err := a.removeSources(ts, results); err != nil {
+			api.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	api.WriteJSON(w, http.StatusOK, struct {
+		Action   string         `json:"action"`
+		Torrents []batchTorrent `json:"torrents"`
+		NotFound []string       `json:"not_found,omitempty"`
+	}{action, results, notFound})
+}
+
+// removeSources removes the sources of the torrents, filling in the results. A torrent added
+// from a directory of torrent files with others that aren't being removed is left alone, since
+// removing the directory would remove them too.
+func (a *apiServer) removeSources(ts []*torrent.Torrent, results []batchTorrent) error {
+	var sources []string
+	for i, t := range ts {
+		urls := a.seeder.torrentSources.URLs(t)
+		if len(urls) == 0 {
+			results[i].Error = "not added from a configured source"
+			continue
+		}
+		shared := slices.ContainsFunc(urls, func(url string) bool {
+			return slices.ContainsFunc(a.seeder.torrentSources.Torrents(url), func(other *torrent.Torrent) bool {
+				return !slices.Contains(ts, other)
+			})
+		})
+		if shared {
+			results[i].Error = "added from a directory along with other torrents, remove its torrent file instead"
+			continue
+		}
+		sources = appendMissing(sources, urls...)
+		results[i].Changed = true
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	_, err := a.seeder.updateConfig(a.ctx, a.client, a.downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+		next := current
+		next.TorrentURLs = slices.DeleteFunc(slices.Clone(current.TorrentURLs), func(url string) bool {
+			return slices.Contains(sources, url)
+		})
+		return next, nil
+	})
+	return err
+}
+
+// Reload the config file like SIGHUP. With ?preview=1, only the changes that would be made are
+// returned.
+func (a *apiServer) reload(w http.ResponseWriter, r *http.Request) {
+	diff, err := a.seeder.reloadConfig(a.ctx, a.client, a.baseConfig, a.configFile, a.downloadDir, isPreview(r))
+	if err != nil {
+		api.WriteError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, diff)
+}
+
+func isPreview(r *http.Request) bool {
+	preview, _ := strconv.ParseBool(r.URL.Query().Get("preview"))
+	return preview
+}
+
+// Report what couldn't be matched against the mirror manifest
+func (a *apiServer) getMirror(w http.ResponseWriter, r *http.Request) {
+	if a.seeder.mirror == nil {
+		api.WriteError(w, http.StatusNotFound, errors.New("no mirror manifest configured"))
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, a.seeder.mirror.Report())
+}
+
+// Report the results of recent announces to each tracker
+func (a *apiServer) getTrackers(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, a.seeder.trackers.Snapshot())
+}
+
+// Report the tracker hosts torrents announce to, and the re-announces waiting on their budgets
+func (a *apiServer) getAnnounces(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, map[string]any{
+		"per_tracker_minute": a.seeder.announces.PerMinute(),
+		"queued":             a.seeder.announces.Queued(),
+		"hosts":              announce.Hosts(a.client),
+	})
+}
+
+// Report the progress of the torrents still downloading
+func (a *apiServer) getDownloads(w http.ResponseWriter, r *http.Request) {
+	type download struct {
+		InfoHash string `json:"infohash"`
+		Name     string `json:"name"`
+		downloadProgress
+	}
+	list := []download{}
+	for _, t := range a.client.Torrents() {
+		if p, ok := a.seeder.downloads.Progress(t); ok {
+			list = append(list, download{InfoHash: t.InfoHash().HexString(), Name: t.Name(), downloadProgress: p})
+		}
+	}
+	slices.SortFunc(list, func(a, b download) int { return strings.Compare(a.Name, b.Name) })
+	api.WriteJSON(w, http.StatusOK, list)
+}
+
+// Report how well each torrent is seeded by the connected peers, rarest first, along with the
+// whole swarm's totals from tracker scrapes
+func (a *apiServer) getSwarm(w http.ResponseWriter, r *http.Request) {
+	type torrentSwarm struct {
+		InfoHash string `json:"infohash"`
+		Name     string `json:"name"`
+		Priority string `json:"priority"`
+		swarmHealth
+		Tracker *swarmTotals `json:"tracker,omitempty"`
+	}
+	list := []torrentSwarm{}
+	for _, t := range a.client.Torrents() {
+		if h, ok := swarmHealthOf(t); ok {
+			ih := t.InfoHash().HexString()
+			ts := torrentSwarm{InfoHash: ih, Name: t.Name(), Priority: a.seeder.priorities.Of(ih).String(), swarmHealth: h}
+			if totals, ok := a.seeder.scrapes.Totals(ih); ok {
+				ts.Tracker = &totals
+			}
+			list = append(list, ts)
+		}
+	}
+	slices.SortFunc(list, func(a, b torrentSwarm) int {
+		return cmp.Or(cmp.Compare(a.Seeds, b.Seeds), cmp.Compare(a.RarestCopies, b.RarestCopies), strings.Compare(a.Name, b.Name))
+	})
+	api.WriteJSON(w, http.StatusOK, list)
+}
+
+// Report the current and average transfer rates, in total and per torrent, fastest uploading first
+func (a *apiServer) getRates(w http.ResponseWriter, r *http.Request) {
+	type torrentRates struct {
+		InfoHash string `json:"infohash"`
+		Name     string `json:"name"`
+		transferRates
+	}
+	list := []torrentRates{}
+	for _, t := range a.client.Torrents() {
+		ih := t.InfoHash().HexString()
+		list = append(list, torrentRates{InfoHash: ih, Name: t.Name(), transferRates: a.seeder.rates.Torrent(ih)})
+	}
+	slices.SortFunc(list, func(a, b torrentRates) int {
+		return cmp.Or(cmp.Compare(b.Upload, a.Upload), strings.Compare(a.Name, b.Name))
+	})
+	api.WriteJSON(w, http.StatusOK, struct {
+		Total    transferRates  `json:"total"`
+		Torrents []torrentRates `json:"torrents"`
+	}{a.seeder.rates.Total(), list})
+}
+
+// Report the traffic with peers by how they were discovered, since start
+func (a *apiServer) getSources(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, a.seeder.peerSources.Totals())
+}
+
+// Report the torrents being verified and the ones waiting their turn
+func (a *apiServer) getVerifications(w http.ResponseWriter, r *http.Request) {
+	api.WriteJSON(w, http.StatusOK, a.seeder.verifications.Status(a.client.Torrents()))
+}
+
+// Report the effective global rate limits, the temporary overrides lowering them, the monthly
+// quota and each torrent's share of the upload limit
+func (a *apiServer) getLimits(w http.ResponseWriter, r *http.Request) {
+	cfg := a.seeder.liveSettings.Get()
+	upload, download := a.seeder.bandwidth.Limits(cfg.UploadLimit, cfg.DownloadLimit)
+	limits := map[string]any{
+		"upload_limit":   upload,
+		"download_limit": download,
+		"overrides":      a.seeder.bandwidth.Active(),
+	}
+	if a.seeder.quota != nil {
+		limits["quota"] = a.seeder.quota.Status()
+	}
+	if a.seeder.shares != nil {
+		limits["shares"] = a.seeder.shares.Claims()
+	}
+	api.WriteJSON(w, http.StatusOK, limits)
+}
+
+// Lower the global or a torrent's rate limits for a duration, or until a given time
+func (a *apiServer) addLimit(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		rateOverride
+		Duration duration `json:"duration"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	o := req.rateOverride
+	if req.Duration != 0 {
+		o.Until = time.Now().Add(time.Duration(req.Duration))
+	}
+	if o.Torrent != "" {
+		var ih metainfo.Hash
+		if err := ih.FromHexString(o.Torrent); err != nil {
+			api.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if _, ok := a.client.Torrent(ih); !ok {
+			api.WriteError(w, http.StatusNotFound, fmt.Errorf("no torrent %s", o.Torrent))
+			return
+		}
+		o.Torrent = ih.HexString()
+	}
+	o, err := a.seeder.bandwidth.Add(o)
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	api.WriteJSON(w, http.StatusCreated, o)
+}
+
+// End an override before it expires
+func (a *apiServer) removeLimit(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !a.seeder.bandwidth.Remove(id) {
+		api.WriteError(w, http.StatusNotFound, fmt.Errorf("no override %d", id))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Report the cluster's nodes, how the catalog is divided among them, and fleet-wide uploads
+func (a *apiServer) getCluster(w http.ResponseWriter, r *http.Request) {
+	if a.seeder.cluster == nil || a.seeder.cluster.coordinator == nil {
+		api.WriteError(w, http.StatusNotFound, errors.New("not a cluster coordinator"))
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, a.seeder.cluster.coordinator.Status(a.seeder.localHeartbeat(a.seeder.cluster.id, a.client), a.seeder.liveSettings.Get().TorrentURLs))
+}
+
+// Record a member's heartbeat and reply with its share of the catalog
+func (a *apiServer) clusterHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if a.seeder.cluster == nil || a.seeder.cluster.coordinator == nil {
+		api.WriteError(w, http.StatusNotFound, errors.New("not a cluster coordinator"))
+		return
+	}
+	var hb clusterHeartbeat
+	if err := json.NewDecoder(r.Body).Decode(&hb); err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	switch hb.Node {
+	case "":
+		api.WriteError(w, http.StatusBadRequest, errors.New("a node ID is needed"))
+		return
+	case a.seeder.cluster.id:
+		api.WriteError(w, http.StatusConflict, fmt.Errorf("node ID %s is the coordinator's", hb.Node))
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, a.seeder.cluster.coordinator.Heartbeat(a.seeder.cluster.id, hb, a.seeder.liveSettings.Get().TorrentURLs))
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Client talks to a running seeder's management API, over TCP or its Unix socket
+type Client struct {
+	Addr   string // Address or URL of the API
+	Socket string // Unix socket, used instead of Addr if set
+	Token  string
+}
+
+// ClientFlags defines the flags for reaching the API, defaulting to the same environment
+// variables the seeder reads
+func ClientFlags(fs *flag.FlagSet) *Client {
+	c := &Client{}
+	fs.StringVar(&c.Addr, "api", "127.0.0.1:8080", "Address or URL of the seeder's management API")
+	fs.StringVar(&c.Socket, "api-socket", "", "Unix socket of the seeder's management API, used instead of -api if set")
+	fs.StringVar(&c.Token, "api-token", "", "Token for the management API, preferably set with DISTRO_SEED_API_TOKEN")
+	return c
+}
+
+// Do sends a request with an optional JSON body and decodes the JSON reply into out, if not nil.
+// Any 2xx status is a success, as adding things replies 201 Created.
+func (c *Client) Do(method, path string, body, out any) error {
+	httpClient := &http.Client{}
+	base := c.Addr
+	if c.Socket != "" {
+		base = "http://distro-seed"
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", c.Socket)
+			},
+		}
+	} else if !strings.Contains(base, "://") {
+		base = "http://" + base
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(base, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("❌ Can't reach the management API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&apiErr)
+		return fmt.Errorf("❌ %s %s: %s %s", method, path, resp.Status, apiErr.Error)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientDoAcceptsAnySuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"infohash":"abc"}`))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"error":"already added"}`))
+		}
+	}))
+	defer server.Close()
+	c := &Client{Addr: server.URL}
+
+	var added struct {
+		InfoHash string `json:"infohash"`
+	}
+	if err := c.Do(http.MethodPost, "/created", nil, &added); err != nil || added.InfoHash != "abc" {
+		t.Errorf("201 reply: %+v, %v", added, err)
+	}
+	if err := c.Do(http.MethodDelete, "/empty", nil, &added); err != nil {
+		t.Errorf("204 reply: %v", err)
+	}
+	if err := c.Do(http.MethodPost, "/conflict", nil, &added); err == nil || !strings.Contains(err.Error(), "already added") {
+		t.Errorf("409 reply: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Package api has the HTTP plumbing the seeder's management APIs share: serving them so that
+// shutting down leaves their sockets open for upgrades, token checks, JSON replies and TLS, and
+// a client for the commands that talk to a running seeder.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ShutdownTimeout is how long requests in progress get to finish when a server is shut down
+const ShutdownTimeout = 10 * time.Second
+
+// Serve serves handler on l until ctx is done, then shuts the server down, giving requests
+// in progress up to ShutdownTimeout to finish. Requests' contexts end with ctx, so streams stop.
+// TLS is layered over the stoppable listener, so shutting down leaves l open for upgrades.
+func Serve(ctx context.Context, l net.Listener, handler http.Handler, tlsConfig *tls.Config) error {
+	server := &http.Server{Handler: handler, BaseContext: func(net.Listener) context.Context { return ctx }}
+	var sl net.Listener = &StoppableListener{Listener: l}
+	if tlsConfig != nil {
+		sl = tls.NewListener(sl, tlsConfig)
+	}
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(sl) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	<-served
+	return err
+}
+
+// StoppableListener stops a server accepting connections without closing the socket, which is
+// handed over to the upgraded binary after the servers are shut down, and closed after that
+type StoppableListener struct {
+	net.Listener
+	stopped atomic.Bool
+}
+
+// Accept returns the next connection, or net.ErrClosed once the listener is stopped
+func (l *StoppableListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil && l.stopped.Load() {
+		return nil, net.ErrClosed
+	}
+	return conn, err
+}
+
+// Close makes Accept return, leaving the socket open
+func (l *StoppableListener) Close() error {
+	l.stopped.Store(true)
+	if dl, ok := l.Listener.(interface{ SetDeadline(time.Time) error }); ok {
+		return dl.SetDeadline(time.Now())
+	}
+	return l.Listener.Close()
+}
+
+// RequireToken only lets through requests with the token, either as a bearer token or as the
+// password for basic auth, which some tools like Prometheus find easier to send
+func RequireToken(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			_, given, ok = r.BasicAuth()
+		}
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="distro-seed"`)
+			WriteError(w, http.StatusUnauthorized, errors.New("missing or wrong API token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteJSON replies with v as JSON
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error: Failed to write API response: %v", err)
+	}
+}
+
+// WriteError replies with err as a JSON error message
+func WriteError(w http.ResponseWriter, status int, err error) {
+	WriteJSON(w, status, map[string]string{"error": err.Error()})
+}
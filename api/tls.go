@@ -0,0 +1,101 @@
+package api
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const acmeCacheDirName = "acme"
+
+// TLSConfig is how the management API's TCP listener is secured, either with certificate
+// files or with certificates from Let's Encrypt
+type TLSConfig struct {
+	CertFile    string
+	KeyFile     string
+	ACMEDomains []string
+	ACMEEmail   string
+}
+
+// Enabled reports whether the API is served over TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || len(c.ACMEDomains) > 0
+}
+
+// Validate checks the certificate settings go together
+func (c TLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("❌ API TLS needs both a certificate and a key file")
+	}
+	if c.CertFile != "" && len(c.ACMEDomains) > 0 {
+		return fmt.Errorf("❌ Use either API certificate files or ACME domains, not both")
+	}
+	return nil
+}
+
+// Config returns the TLS configuration for the API, keeping ACME state in stateDir
+func (c TLSConfig) Config(stateDir string) (*tls.Config, error) {
+	if len(c.ACMEDomains) > 0 {
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(c.ACMEDomains...),
+			Cache:      autocert.DirCache(filepath.Join(stateDir, acmeCacheDirName)),
+			Email:      c.ACMEEmail,
+		}
+		// Certificates are validated with TLS-ALPN, on the API's own listener
+		return m.TLSConfig(), nil
+	}
+
+	r := &certReloader{certFile: c.CertFile, keyFile: c.KeyFile}
+	if _, err := r.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return &tls.Config{GetCertificate: r.GetCertificate, MinVersion: tls.VersionTLS12}, nil
+}
+
+// certReloader serves a certificate from files, reloading them when they change so renewed
+// certificates are picked up without a restart
+type certReloader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// GetCertificate returns the certificate, loading it again if its file has changed
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fi, err := os.Stat(r.certFile)
+	if err != nil {
+		if r.cert != nil {
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("❌ Failed to read API certificate: %w", err)
+	}
+	if r.cert != nil && fi.ModTime().Equal(r.modTime) {
+		return r.cert, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		if r.cert != nil {
+			// Likely caught between the certificate and key being replaced
+			log.Printf("⚠️ Keeping current API certificate: %v", err)
+			return r.cert, nil
+		}
+		return nil, fmt.Errorf("❌ Failed to load API certificate: %w", err)
+	}
+	if r.cert != nil {
+		log.Printf("🔐 Reloaded API certificate from %s", r.certFile)
+	}
+	r.cert, r.modTime = &cert, fi.ModTime()
+	return r.cert, nil
+}
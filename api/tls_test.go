@@ -0,0 +1,102 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for name and its key into dir, with the given
+// modification time
+func writeTestCert(t *testing.T, dir, name string, modTime time.Time) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(certFile, modTime, modTime)
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now().Add(-time.Hour)
+	certFile, keyFile := writeTestCert(t, dir, "old.example.com", start)
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	commonName := func() string {
+		t.Helper()
+		cert, err := r.GetCertificate(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if got := commonName(); got != "old.example.com" {
+		t.Fatalf("serving %s, want old.example.com", got)
+	}
+
+	// A renewed certificate is picked up
+	writeTestCert(t, dir, "new.example.com", start.Add(time.Minute))
+	if got := commonName(); got != "new.example.com" {
+		t.Errorf("serving %s after renewal, want new.example.com", got)
+	}
+
+	// A broken one isn't, the current one is kept
+	os.WriteFile(certFile, []byte("not a certificate"), 0o644)
+	os.Chtimes(certFile, start.Add(2*time.Minute), start.Add(2*time.Minute))
+	if got := commonName(); got != "new.example.com" {
+		t.Errorf("serving %s after a broken renewal, want new.example.com", got)
+	}
+}
+
+func TestTLSConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg   TLSConfig
+		valid bool
+	}{
+		{cfg: TLSConfig{}, valid: true},
+		{cfg: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem"}, valid: true},
+		{cfg: TLSConfig{ACMEDomains: []string{"seed.example.com"}}, valid: true},
+		{cfg: TLSConfig{CertFile: "cert.pem"}},
+		{cfg: TLSConfig{CertFile: "cert.pem", KeyFile: "key.pem", ACMEDomains: []string{"seed.example.com"}}},
+	}
+	for _, tt := range tests {
+		if err := tt.cfg.Validate(); (err == nil) != tt.valid {
+			t.Errorf("%+v.Validate() = %v, want valid %t", tt.cfg, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.UploadLimit = 100
+	s := newTestSeeder(t, cfg)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+
+	faster := cfg
+	faster.StatusInterval = duration(30 * time.Second)
+
+	tests := []struct {
+		body   string
+		status int
+		want   runtimeConfig
+	}{
+		// Only what's sent changes
+		{body: `{"status_interval": "30s"}`, status: http.StatusOK, want: faster},
+		{body: `{"announce_interval": "1s"}`, status: http.StatusBadRequest},
+		{body: `{"upload_limit": -5}`, status: http.StatusBadRequest},
+		{body: `{"upload_limit": "fast"}`, status: http.StatusBadRequest},
+		{body: `{"statusInterval": "5m"}`, status: http.StatusBadRequest}, // Misspelled
+	}
+	for _, tt := range tests {
+		prev := s.liveSettings.Get()
+		w := httptest.NewRecorder()
+		api.patchConfig(w, httptest.NewRequest(http.MethodPatch, "/api/config", strings.NewReader(tt.body)))
+		if w.Code != tt.status {
+			t.Errorf("PATCH %s: status %d, want %d: %s", tt.body, w.Code, tt.status, w.Body)
+			continue
+		}
+		want := tt.want
+		if tt.status != http.StatusOK {
+			want = prev
+		}
+		if got := s.liveSettings.Get(); !reflect.DeepEqual(got, want) {
+			t.Errorf("PATCH %s: config = %+v, want %+v", tt.body, got, want)
+		}
+	}
+}
+
+func TestConfigRedacted(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	const announce = "https://tracker.example.com/0123456789abcdef/announce"
+	cfg.TorrentOptions = map[string]torrentOptions{"private.torrent": {Announce: announce, Username: "mirror", Password: "hunter2", Headers: map[string]string{"X-Api-Key": "key123"}}}
+	s := newTestSeeder(t, cfg)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+
+	w := httptest.NewRecorder()
+	api.getConfig(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	for _, secret := range []string{"0123456789abcdef", "hunter2", "key123"} {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Errorf("GET /api/config shows %s: %s", secret, w.Body)
+		}
+	}
+
+	// Sending back what was read doesn't replace the secrets with the mask
+	body := w.Body.String()
+	w = httptest.NewRecorder()
+	api.patchConfig(w, httptest.NewRequest(http.MethodPatch, "/api/config", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH of the config read: status %d: %s", w.Code, w.Body)
+	}
+	if got := s.liveSettings.Get().TorrentOptions["private.torrent"]; !reflect.DeepEqual(got, cfg.TorrentOptions["private.torrent"]) {
+		t.Errorf("options are %+v after sending the config back", got)
+	}
+}
+
+func TestPatchConfigPreview(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	s := newTestSeeder(t, cfg)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+
+	w := httptest.NewRecorder()
+	api.patchConfig(w, httptest.NewRequest(http.MethodPatch, "/api/config?preview=1", strings.NewReader(`{"status_interval": "30s"}`)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var diff configDiff
+	if err := json.NewDecoder(w.Body).Decode(&diff); err != nil {
+		t.Fatal(err)
+	}
+	want := []settingChange{{Setting: "status_interval", From: "1m0s", To: "30s"}}
+	if !reflect.DeepEqual(diff.Changed, want) {
+		t.Errorf("preview changes = %+v, want %+v", diff.Changed, want)
+	}
+	if got := s.liveSettings.Get(); !reflect.DeepEqual(got, cfg) {
+		t.Errorf("a preview changed the config to %+v", got)
+	}
+}
+
+func TestListenAPISocketReplacesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.sock")
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Left behind without being removed, like after a crash
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := newSeeder(nil).listenAPISocket(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := fi.Mode().Perm(); perm != 0o660 {
+		t.Errorf("socket permissions %o, want 660", perm)
+	}
+}
+
+func TestAddTorrentWithOptions(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	setTestSeederState(s, dir)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		api.addTorrent(w, httptest.NewRequest(http.MethodPost, "/api/torrents", strings.NewReader(body)))
+		return w
+	}
+
+	meta := newTestMeta(t, dir, "image.iso", 32<<10)
+	meta.Announce = "http://tracker.example.com/announce"
+	torrentPath := filepath.Join(dir, "image.iso.torrent")
+	f, err := os.Create(torrentPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.Write(f)
+	f.Close()
+
+	body := `{"url": "` + torrentPath + `", "trackers": ["udp://backup.example.com:6969"], "webseeds": ["https://mirror.example.com/"],
+		"labels": ["ubuntu"], "upload_limit": 512, "ratio_target": 2}`
+	w := post(body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	waitForSeedTorrents(t, s)
+	tt, ok := s.torrentSources.Get(torrentPath)
+	if !ok {
+		t.Fatal("torrent wasn't added")
+	}
+	mi := tt.Metainfo()
+	if trackers := mi.UpvertedAnnounceList().DistinctValues(); !slices.Contains(trackers, "udp://backup.example.com:6969") || !slices.Contains(trackers, meta.Announce) {
+		t.Errorf("trackers = %v, want the torrent's and the added one", trackers)
+	}
+	if !slices.Equal(mi.UrlList, []string{"https://mirror.example.com/"}) {
+		t.Errorf("webseeds = %v", mi.UrlList)
+	}
+	if labels := s.optionsOf(tt).Labels; !slices.Equal(labels, []string{"ubuntu"}) {
+		t.Errorf("labels = %v", labels)
+	}
+	if limit := s.bandwidth.torrentLimiter(tt.InfoHash().HexString()).Limit(); limit != 512*1024 {
+		t.Errorf("upload limit = %v, want 512 KiB/s", limit)
+	}
+
+	if w := post(body); w.Code != http.StatusConflict {
+		t.Errorf("adding it again: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	for _, body := range []string{
+		`{"trackers": ["udp://backup.example.com:6969"]}`,
+		`{"url": "other.torrent", "trackers": ["backup.example.com"]}`,
+		`{"url": "other.torrent", "ratio": 2}`,
+	} {
+		if w := post(body); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %s: status %d, want %d", body, w.Code, http.StatusBadRequest)
+		}
+	}
+	if got := s.liveSettings.Get().TorrentURLs; !slices.Equal(got, []string{torrentPath}) {
+		t.Errorf("torrents = %v, want only %s", got, torrentPath)
+	}
+}
+
+func TestBatchTorrents(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent", "b.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu"}}}
+	s := newTestSeeder(t, cfg)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	b := addSeedingTestTorrent(t, client, dir, "b.iso")
+	s.torrentSources.Add("a.torrent", a)
+	s.torrentSources.Add("b.torrent", b)
+
+	type reply struct {
+		Torrents []batchTorrent `json:"torrents"`
+		NotFound []string       `json:"not_found"`
+	}
+	batch := func(action, body string) (int, reply) {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/api/torrents/"+action, strings.NewReader(body))
+		api.handler().ServeHTTP(w, r)
+		var got reply
+		json.NewDecoder(w.Body).Decode(&got)
+		return w.Code, got
+	}
+
+	code, got := batch("pause", `{"label": "ubuntu"}`)
+	if code != http.StatusOK || len(got.Torrents) != 1 || got.Torrents[0].InfoHash != a.InfoHash().HexString() || !got.Torrents[0].Changed {
+		t.Fatalf("pause by label: %d %+v", code, got)
+	}
+	if !s.pauses.IsPaused(a.InfoHash().HexString()) || s.pauses.IsPaused(b.InfoHash().HexString()) {
+		t.Error("pausing by label didn't pause only the labeled torrent")
+	}
+
+	const unknown = "ffffffffffffffffffffffffffffffffffffffff"
+	code, got = batch("resume", `{"infohashes": ["`+a.InfoHash().HexString()+`", "`+b.InfoHash().HexString()+`", "`+unknown+`"]}`)
+	if code != http.StatusOK || len(got.Torrents) != 2 || !got.Torrents[0].Changed || got.Torrents[1].Changed || !slices.Equal(got.NotFound, []string{unknown}) {
+		t.Errorf("resume: %d %+v", code, got)
+	}
+
+	code, got = batch("remove", `{"infohashes": ["`+b.InfoHash().HexString()+`"]}`)
+	if code != http.StatusOK || len(got.Torrents) != 1 || !got.Torrents[0].Changed {
+		t.Errorf("remove: %d %+v", code, got)
+	}
+	if urls := s.liveSettings.Get().TorrentURLs; !slices.Equal(urls, []string{"a.torrent"}) {
+		t.Errorf("torrents after removing b = %v", urls)
+	}
+	if _, ok := client.Torrent(b.InfoHash()); ok {
+		t.Error("removed torrent is still in the client")
+	}
+
+	for action, body := range map[string]string{"pause": `{}`, "stop": `{"label": "ubuntu"}`, "resume": `{"infohashes": ["nope"]}`} {
+		if code, _ := batch(action, body); code == http.StatusOK {
+			t.Errorf("%s %s succeeded", action, body)
+		}
+	}
+}
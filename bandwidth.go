@@ -0,0 +1,324 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"golang.org/x/time/rate"
+)
+
+// rateOverride lowers rate limits until it expires, so other systems can borrow bandwidth for a
+// while and have it handed back automatically
+type rateOverride struct {
+	ID            int       `json:"id"`
+	Torrent       string    `json:"torrent,omitempty"` // Infohash, or empty for the global limits
+	UploadLimit   int64     `json:"upload_limit"`      // KiB/s, 0 to leave uploads alone
+	DownloadLimit int64     `json:"download_limit"`    // KiB/s, 0 to leave downloads alone
+	Reason        string    `json:"reason,omitempty"`
+	Until         time.Time `json:"until"`
+}
+
+func (o rateOverride) validate(now time.Time) error {
+	if o.UploadLimit < 0 || o.DownloadLimit < 0 {
+		return fmt.Errorf("❌ Rate limits can't be negative")
+	}
+	if o.UploadLimit == 0 && o.DownloadLimit == 0 {
+		return fmt.Errorf("❌ An upload or download limit is needed")
+	}
+	if o.Torrent != "" && o.DownloadLimit != 0 {
+		return fmt.Errorf("❌ Only upload limits can be set per torrent")
+	}
+	if !o.Until.After(now) {
+		return fmt.Errorf("❌ Expiry %s is in the past", o.Until.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// bandwidthSchedule holds the active rate limit overrides. Global overrides lower the client's
+// limiters, per-torrent ones throttle reads of the torrent's data for peers.
+type bandwidthSchedule struct {
+	seeder    *Seeder
+	mu        sync.Mutex
+	nextID    int
+	overrides []rateOverride
+	limiters  map[string]*rate.Limiter // Per torrent, by infohash
+	changed   chan struct{}
+
+	crowded      map[string]bool // Torrents with plenty of other seeds, by infohash
+	crowdedLimit int64           // KiB/s each crowded torrent may upload, 0 for unlimited
+
+	quotaLimit int64 // KiB/s uploads are throttled to by the monthly quota, 0 for unlimited
+
+	configured map[string]int64 // KiB/s torrents may upload by their sources' options, by infohash
+	shares     map[string]int64 // KiB/s of the upload limit each torrent is given, by infohash
+}
+
+// Add starts an override, returning it with its ID
+func (s *bandwidthSchedule) Add(o rateOverride) (rateOverride, error) {
+	if err := o.validate(time.Now()); err != nil {
+		return o, err
+	}
+	s.mu.Lock()
+	s.nextID++
+	o.ID = s.nextID
+	s.overrides = append(s.overrides, o)
+	s.applyTorrentLimits()
+	s.mu.Unlock()
+
+	log.Printf("🚦 Rate limit override %d until %s: %s", o.ID, o.Until.Format(time.DateTime), o.describe())
+	s.wake()
+	return o, nil
+}
+
+// Remove ends an override early, reporting whether it existed
+func (s *bandwidthSchedule) Remove(id int) bool {
+	s.mu.Lock()
+	n := len(s.overrides)
+	s.overrides = slices.DeleteFunc(s.overrides, func(o rateOverride) bool { return o.ID == id })
+	removed := len(s.overrides) < n
+	s.applyTorrentLimits()
+	s.mu.Unlock()
+
+	if removed {
+		log.Printf("🚦 Rate limit override %d removed", id)
+		s.wake()
+	}
+	return removed
+}
+
+// Active returns the overrides in effect, soonest to expire first
+func (s *bandwidthSchedule) Active() []rateOverride {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active := append([]rateOverride{}, s.overrides...)
+	slices.SortFunc(active, func(a, b rateOverride) int { return a.Until.Compare(b.Until) })
+	return active
+}
+
+// Limits returns the configured global limits lowered by any global overrides
+func (s *bandwidthSchedule) Limits(upload, download int64) (int64, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, o := range s.overrides {
+		if o.Torrent == "" {
+			upload = lowerLimit(upload, o.UploadLimit)
+			download = lowerLimit(download, o.DownloadLimit)
+		}
+	}
+	return lowerLimit(upload, s.quotaLimit), download
+}
+
+// torrentLimiter returns the limiter for reads of the torrent's data, unlimited unless overridden
+func (s *bandwidthSchedule) torrentLimiter(infoHash string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.limiters[infoHash]
+	if !ok {
+		l = rate.NewLimiter(rate.Inf, 0)
+		s.limiters[infoHash] = l
+		s.applyTorrentLimits()
+	}
+	return l
+}
+
+// applyTorrentLimits sets each torrent's limiter from its overrides, the caller must hold s.mu
+func (s *bandwidthSchedule) applyTorrentLimits() {
+	for infoHash, l := range s.limiters {
+		var limit int64
+		for _, o := range s.overrides {
+			if o.Torrent == infoHash {
+				limit = lowerLimit(limit, o.UploadLimit)
+			}
+		}
+		if s.crowded[infoHash] {
+			limit = lowerLimit(limit, s.crowdedLimit)
+		}
+		limit = lowerLimit(limit, s.configured[infoHash])
+		limit = lowerLimit(limit, s.shares[infoHash])
+		if limit == 0 {
+			l.SetLimit(rate.Inf)
+			continue
+		}
+		// A second's worth, but at least a block so any read can be let through
+		l.SetBurst(max(int(limit*1024), 16*1024))
+		l.SetLimit(rate.Limit(limit * 1024))
+	}
+}
+
+// setCrowded caps the upload rate of the given torrents, on top of any overrides
+func (s *bandwidthSchedule) setCrowded(infoHashes []string, limit int64) {
+	crowded := make(map[string]bool, len(infoHashes))
+	for _, ih := range infoHashes {
+		crowded[ih] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.crowded, s.crowdedLimit = crowded, limit
+	s.applyTorrentLimits()
+}
+
+// setTorrentLimits caps the upload rate of torrents whose sources limit it, by infohash
+func (s *bandwidthSchedule) setTorrentLimits(limits map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configured = limits
+	s.applyTorrentLimits()
+}
+
+// setShares caps the upload rate of torrents to their share of the upload limit, by infohash
+func (s *bandwidthSchedule) setShares(shares map[string]int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shares = shares
+	s.applyTorrentLimits()
+}
+
+// setQuotaLimit throttles uploads to what's left of the monthly quota
+func (s *bandwidthSchedule) setQuotaLimit(limit int64) {
+	s.mu.Lock()
+	s.quotaLimit = limit
+	s.mu.Unlock()
+	s.wake()
+}
+
+func (s *bandwidthSchedule) wake() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+	// Global limits are shared with reloads
+	s.seeder.configMu.Lock()
+	s.seeder.applyRateLimits(s.seeder.liveSettings.Get())
+	s.seeder.configMu.Unlock()
+}
+
+// expire drops overrides that have run out, returning when the next one will
+func (s *bandwidthSchedule) expire(now time.Time) (expired []rateOverride, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = slices.DeleteFunc(s.overrides, func(o rateOverride) bool {
+		if o.Until.After(now) {
+			if next.IsZero() || o.Until.Before(next) {
+				next = o.Until
+			}
+			return false
+		}
+		expired = append(expired, o)
+		return true
+	})
+	if len(expired) > 0 {
+		s.applyTorrentLimits()
+	}
+	return expired, next
+}
+
+// Hand bandwidth back as overrides expire
+func (s *bandwidthSchedule) run(ctx context.Context) {
+	for {
+		expired, next := s.expire(time.Now())
+		for _, o := range expired {
+			log.Printf("⏱️ Rate limit override %d expired: %s", o.ID, o.describe())
+		}
+		if len(expired) > 0 {
+			s.wake()
+		}
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			timer = time.After(time.Until(next))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.changed:
+		case <-timer:
+		}
+	}
+}
+
+func (o rateOverride) describe() string {
+	scope := "all torrents"
+	if o.Torrent != "" {
+		scope = o.Torrent
+	}
+	desc := fmt.Sprintf("%s upload %s", scope, formatRateLimit(o.UploadLimit))
+	if o.DownloadLimit != 0 {
+		desc += fmt.Sprintf(", download %s", formatRateLimit(o.DownloadLimit))
+	}
+	if o.Reason != "" {
+		desc += fmt.Sprintf(" (%s)", o.Reason)
+	}
+	return desc
+}
+
+// The lower of two KiB/s limits, where 0 is unlimited
+func lowerLimit(a, b int64) int64 {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
+
+// throttledStorage throttles reads of each torrent's data by its limiter from the bandwidth
+// schedule, which limits how fast the torrent is uploaded
+type throttledStorage struct {
+	seeder *Seeder
+	storage.ClientImplCloser
+}
+
+func (s throttledStorage) OpenTorrent(ctx context.Context, info *metainfo.Info, infoHash metainfo.Hash) (storage.TorrentImpl, error) {
+	t, err := s.ClientImplCloser.OpenTorrent(ctx, info, infoHash)
+	if err != nil {
+		return t, err
+	}
+	limiter := s.seeder.bandwidth.torrentLimiter(infoHash.HexString())
+	return storage.TorrentImpl{
+		Piece: func(p metainfo.Piece) storage.PieceImpl {
+			return throttledPiece{seeder: s.seeder, PieceImpl: t.Piece(p), limiter: limiter, infoHash: infoHash.HexString(), length: p.Length()}
+		},
+		Close:    t.Close,
+		Capacity: t.Capacity,
+	}, nil
+}
+
+type throttledPiece struct {
+	seeder *Seeder
+	storage.PieceImpl
+	limiter  *rate.Limiter
+	infoHash string
+	length   int64
+}
+
+func (p throttledPiece) ReadAt(b []byte, off int64) (int, error) {
+	// Waits are capped at the burst, so large reads wait in parts
+	for remaining := len(b); remaining > 0 && p.limiter.Limit() != rate.Inf; {
+		n := min(remaining, p.limiter.Burst())
+		if err := p.limiter.WaitN(context.Background(), n); err != nil {
+			break
+		}
+		remaining -= n
+	}
+	return p.PieceImpl.ReadAt(b, off)
+}
+
+// WriteTo is how the client reads pieces to hash them, which isn't throttled like uploads but
+// waits for a worker from the hash pool
+func (p throttledPiece) WriteTo(w io.Writer) (int64, error) {
+	defer p.seeder.hashing.acquire()()
+	var n int64
+	var err error
+	if wt, ok := p.PieceImpl.(io.WriterTo); ok {
+		n, err = wt.WriteTo(w)
+	} else {
+		n, err = io.Copy(w, io.NewSectionReader(p.PieceImpl, 0, p.length))
+	}
+	p.seeder.hashing.add(p.infoHash, n)
+	return n, err
+}
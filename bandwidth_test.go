@@ -0,0 +1,98 @@
+package distroseed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func newTestBandwidthSchedule(s *Seeder) *bandwidthSchedule {
+	return &bandwidthSchedule{seeder: s, limiters: make(map[string]*rate.Limiter), changed: make(chan struct{}, 1)}
+}
+
+func TestRateOverrideValidate(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		o   rateOverride
+		err string // Part of the error expected, if any
+	}{
+		{o: rateOverride{UploadLimit: 100, Until: now.Add(time.Hour)}},
+		{o: rateOverride{Torrent: "aa", UploadLimit: 100, Until: now.Add(time.Hour)}},
+		{o: rateOverride{UploadLimit: -1, Until: now.Add(time.Hour)}, err: "can't be negative"},
+		{o: rateOverride{Until: now.Add(time.Hour)}, err: "upload or download limit is needed"},
+		{o: rateOverride{Torrent: "aa", DownloadLimit: 100, Until: now.Add(time.Hour)}, err: "Only upload limits"},
+		{o: rateOverride{UploadLimit: 100, Until: now}, err: "in the past"},
+	}
+	for _, tt := range tests {
+		err := tt.o.validate(now)
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%+v.validate() = %v, want %q", tt.o, err, tt.err)
+		}
+	}
+}
+
+func TestBandwidthScheduleLowersLimits(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	sched := newTestBandwidthSchedule(s)
+	until := time.Now().Add(time.Hour)
+	if _, err := sched.Add(rateOverride{UploadLimit: 500, Until: until}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sched.Add(rateOverride{UploadLimit: 800, DownloadLimit: 200, Until: until}); err != nil {
+		t.Fatal(err)
+	}
+	// The lowest limit wins, and unlimited is lowered to any limit
+	tests := []struct{ upload, download, wantUpload, wantDownload int64 }{
+		{0, 0, 500, 200},
+		{100, 1000, 100, 200},
+		{1000, 50, 500, 50},
+	}
+	for _, tt := range tests {
+		if up, down := sched.Limits(tt.upload, tt.download); up != tt.wantUpload || down != tt.wantDownload {
+			t.Errorf("Limits(%d, %d) = %d, %d, want %d, %d", tt.upload, tt.download, up, down, tt.wantUpload, tt.wantDownload)
+		}
+	}
+}
+
+func TestBandwidthScheduleTorrentLimits(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	sched := newTestBandwidthSchedule(s)
+	limiter := sched.torrentLimiter("aa")
+	other := sched.torrentLimiter("bb")
+	o, err := sched.Add(rateOverride{Torrent: "aa", UploadLimit: 64, Until: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limiter.Limit() != rate.Limit(64*1024) || other.Limit() != rate.Inf {
+		t.Errorf("limits = %v and %v, want 64 KiB/s for the overridden torrent only", limiter.Limit(), other.Limit())
+	}
+	if !sched.Remove(o.ID) || sched.Remove(o.ID) {
+		t.Error("the override wasn't removed exactly once")
+	}
+	if limiter.Limit() != rate.Inf {
+		t.Errorf("limit after removing the override = %v, want unlimited", limiter.Limit())
+	}
+}
+
+func TestBandwidthScheduleExpire(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	sched := newTestBandwidthSchedule(s)
+	now := time.Now()
+	sched.overrides = []rateOverride{
+		{ID: 1, UploadLimit: 100, Until: now.Add(-time.Second)},
+		{ID: 2, UploadLimit: 100, Until: now.Add(2 * time.Hour)},
+		{ID: 3, UploadLimit: 100, Until: now.Add(time.Hour)},
+	}
+	expired, next := sched.expire(now)
+	if len(expired) != 1 || expired[0].ID != 1 {
+		t.Errorf("expired %+v, want override 1", expired)
+	}
+	if !next.Equal(now.Add(time.Hour)) {
+		t.Errorf("next expiry %s, want %s", next, now.Add(time.Hour))
+	}
+	if active := sched.Active(); len(active) != 2 || active[0].ID != 3 {
+		t.Errorf("active overrides %+v, want 3 then 2", active)
+	}
+}
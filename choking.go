@@ -0,0 +1,217 @@
+package distroseed
+
+import (
+	"context"
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+const (
+	chokeRoundInterval = 10 * time.Second // How often the peers to upload to are picked, as in the BitTorrent spec
+	chokedUploadRate   = 1024             // Bytes/s still written to choked peers, enough for protocol messages
+	chokedUploadBurst  = 16 * 1024        // A block's worth, so a write in progress isn't held up long
+)
+
+// chokePolicy limits how many peers of each torrent are uploaded to at full speed. The client
+// serves every interested peer, so the others are choked by holding their TCP connections to a
+// trickle. The torrent's upload strategy picks the peers that get slots, and an optimistic unchoke
+// now and then gives the rest a chance to take one.
+type chokePolicy struct {
+	seeder     *Seeder
+	mu         sync.Mutex
+	conns      map[string]*chokedConn      // Open TCP peer connections, by remote address
+	written    map[*torrent.PeerConn]int64 // Bytes written to each peer by the last round
+	read       map[*torrent.PeerConn]int64 // Bytes read from each peer by the last round
+	optimistic map[string]string           // Optimistically unchoked peer address, by infohash
+	lastPick   time.Time                   // When optimistic unchokes were last picked
+	peerLimit  atomic.Int64                // KiB/s each peer may be uploaded to, 0 for unlimited
+	rand       *rand.Rand                  // Shuffles peers and picks optimistic unchokes, seeded on the first round if nil
+	recorder   *traceRecorder              // Records the swarms for replaying with simulate, if set
+}
+
+// chokedConn is a peer connection whose writes are throttled while it's choked, and to the
+// per-peer upload limit while it isn't
+type chokedConn struct {
+	net.Conn
+	policy      *chokePolicy
+	choked      atomic.Bool
+	limiter     *rate.Limiter
+	peerLimiter *rate.Limiter
+}
+
+func (p *chokePolicy) wrap(conn net.Conn) net.Conn {
+	c := &chokedConn{
+		Conn:        conn,
+		policy:      p,
+		limiter:     rate.NewLimiter(chokedUploadRate, chokedUploadBurst),
+		peerLimiter: rate.NewLimiter(rate.Inf, 0),
+	}
+	p.mu.Lock()
+	p.conns[conn.RemoteAddr().String()] = c
+	p.mu.Unlock()
+	return c
+}
+
+func (c *chokedConn) Write(b []byte) (int, error) {
+	// Waits are capped at the burst, so large writes wait in parts, and stop if it's unchoked
+	for remaining := len(b); remaining > 0 && c.choked.Load(); {
+		n := min(remaining, chokedUploadBurst)
+		if err := c.limiter.WaitN(context.Background(), n); err != nil {
+			break
+		}
+		remaining -= n
+	}
+	c.throttle(len(b))
+	return c.Conn.Write(b)
+}
+
+// throttle waits until n bytes may be written under the per-peer upload limit
+func (c *chokedConn) throttle(n int) {
+	limit := c.policy.peerLimit.Load()
+	if limit == 0 {
+		return
+	}
+	if c.peerLimiter.Limit() != rate.Limit(limit*1024) {
+		// A second's worth, but at least a block so any write can be let through
+		c.peerLimiter.SetBurst(max(int(limit*1024), 16*1024))
+		c.peerLimiter.SetLimit(rate.Limit(limit * 1024))
+	}
+	for n > 0 {
+		part := min(n, c.peerLimiter.Burst())
+		if err := c.peerLimiter.WaitN(context.Background(), part); err != nil {
+			return
+		}
+		n -= part
+	}
+}
+
+// setPeerLimit limits how fast each peer is uploaded to, in KiB/s, 0 for unlimited
+func (p *chokePolicy) setPeerLimit(limit int64) {
+	p.peerLimit.Store(limit)
+}
+
+func (c *chokedConn) Close() error {
+	c.policy.mu.Lock()
+	addr := c.RemoteAddr().String()
+	if c.policy.conns[addr] == c {
+		delete(c.policy.conns, addr)
+	}
+	c.policy.mu.Unlock()
+	return c.Conn.Close()
+}
+
+// chokingListener hands accepted peer connections to the choke policy
+type chokingListener struct {
+	seeder *Seeder
+	net.Listener
+}
+
+func (l chokingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return conn, err
+	}
+	return l.seeder.chokes.wrap(conn), nil
+}
+
+// chokingDialer hands dialed peer connections to the choke policy
+type chokingDialer struct {
+	seeder *Seeder
+	net.Dialer
+}
+
+func (d *chokingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.Dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return conn, err
+	}
+	return d.seeder.chokes.wrap(conn), nil
+}
+
+// Pick the peers to upload to every round until ctx is done
+func (p *chokePolicy) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(chokeRoundInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			cfg := p.seeder.liveSettings.Get()
+			p.round(client.Torrents(), cfg.MaxUnchoked, time.Duration(cfg.OptimisticUnchokeInterval), now)
+		}
+	}
+}
+
+func (p *chokePolicy) round(torrents []*torrent.Torrent, maxUnchoked int, optimisticInterval time.Duration, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pick := now.Sub(p.lastPick) >= optimisticInterval
+	if pick {
+		p.lastPick = now
+	}
+
+	if p.rand == nil {
+		p.rand = rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64()))
+	}
+
+	written := make(map[*torrent.PeerConn]int64)
+	read := make(map[*torrent.PeerConn]int64)
+	optimistic := make(map[string]string)
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		var peers []strategyPeer
+		for _, pc := range t.PeerConns() {
+			stats := pc.Stats()
+			written[pc] = stats.BytesWrittenData.Int64()
+			read[pc] = stats.BytesReadData.Int64()
+			peers = append(peers, strategyPeer{
+				addr:         pc.RemoteAddr.String(),
+				uploadRate:   written[pc] - p.written[pc],
+				downloadRate: read[pc] - p.read[pc],
+				uploaded:     written[pc],
+			})
+		}
+		p.recorder.round(ih, peers, now)
+
+		var unchoked map[string]bool
+		unchoked, optimistic[ih] = unchoke(peers, p.seeder.strategyOf(t), maxUnchoked, p.optimistic[ih], pick, p.rand)
+		for _, peer := range peers {
+			if c, ok := p.conns[peer.addr]; ok {
+				c.choked.Store(!unchoked[peer.addr])
+			}
+		}
+	}
+	p.written, p.read, p.optimistic = written, read, optimistic
+}
+
+// unchoke returns the addresses of a torrent's peers to upload to: the ones its strategy ranks
+// first, and its optimistic unchoke. That's picked again from the rest if pick is set, and kept
+// otherwise. With no limit, every peer is unchoked.
+func unchoke(peers []strategyPeer, strategy uploadStrategy, maxUnchoked int, optimistic string, pick bool, rnd *rand.Rand) (map[string]bool, string) {
+	// In random order among equals, so idle peers take turns
+	rnd.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	strategy.rank(peers)
+
+	slots := len(peers)
+	if maxUnchoked > 0 {
+		slots = min(slots, strategy.slots(maxUnchoked))
+	}
+	if pick {
+		optimistic = ""
+		if slots < len(peers) {
+			optimistic = peers[slots+rnd.IntN(len(peers)-slots)].addr
+		}
+	}
+	unchoked := make(map[string]bool, slots+1)
+	for i, peer := range peers {
+		unchoked[peer.addr] = i < slots || peer.addr == optimistic
+	}
+	return unchoked, optimistic
+}
@@ -0,0 +1,176 @@
+package distroseed
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/types"
+	"golang.org/x/time/rate"
+)
+
+func TestChokePolicyRound(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	seeder := newTestClient(t, dir)
+	meta := newTestMeta(t, dir, "a.iso", 64<<10)
+	seeding, err := seeder.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seeding.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leechers that only want the first piece stay connected
+	for range 3 {
+		leecher := newTestClient(t, t.TempDir())
+		leeching, err := leecher.AddTorrent(meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		leeching.Piece(0).SetPriority(types.PiecePriorityNormal)
+		leeching.AddClientPeer(seeder)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(seeding.PeerConns()) < 3; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d leechers connected, want 3", len(seeding.PeerConns()))
+		}
+	}
+
+	// The connections the seeder's listener would have wrapped
+	p := &chokePolicy{seeder: s, conns: make(map[string]*chokedConn), written: make(map[*torrent.PeerConn]int64), optimistic: make(map[string]string)}
+	for _, pc := range seeding.PeerConns() {
+		p.conns[pc.RemoteAddr.String()] = &chokedConn{policy: p}
+	}
+	choked := func() (n int) {
+		for _, c := range p.conns {
+			if c.choked.Load() {
+				n++
+			}
+		}
+		return n
+	}
+
+	now := time.Now()
+	p.round([]*torrent.Torrent{seeding}, 1, time.Minute, now)
+	if got := choked(); got != 1 {
+		t.Errorf("with 1 upload slot and an optimistic unchoke, %d of 3 peers are choked, want 1", got)
+	}
+	optimistic := p.optimistic[seeding.InfoHash().HexString()]
+	if optimistic == "" {
+		t.Fatal("no peer was optimistically unchoked")
+	}
+	p.round([]*torrent.Torrent{seeding}, 1, time.Minute, now.Add(chokeRoundInterval))
+	if got := p.optimistic[seeding.InfoHash().HexString()]; got != optimistic {
+		t.Errorf("the optimistic unchoke moved from %s to %s before its interval", optimistic, got)
+	}
+
+	p.round([]*torrent.Torrent{seeding}, 0, time.Minute, now.Add(2*chokeRoundInterval))
+	if got := choked(); got != 0 {
+		t.Errorf("without a limit, %d peers are choked", got)
+	}
+}
+
+func TestChokedConnWrite(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	p := &chokePolicy{seeder: s, conns: make(map[string]*chokedConn)}
+	local, remote := net.Pipe()
+	t.Cleanup(func() { remote.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	conn := p.wrap(local).(*chokedConn)
+	conn.limiter = rate.NewLimiter(rate.Limit(chokedUploadBurst*4), chokedUploadBurst) // A block every 250ms
+	conn.choked.Store(true)
+
+	// A block fits in the burst, but the next one waits its turn
+	if _, err := conn.Write(make([]byte, chokedUploadBurst)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		conn.Write(make([]byte, chokedUploadBurst))
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("a choked connection wrote past its burst")
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a choked write never went through")
+	}
+
+	// Unchoked, writes aren't held up at all
+	conn.choked.Store(false)
+	start := time.Now()
+	for range 4 {
+		if _, err := conn.Write(make([]byte, chokedUploadBurst)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 200*time.Millisecond {
+		t.Errorf("unchoked writes took %s", elapsed)
+	}
+
+	conn.Close()
+	if len(p.conns) != 0 {
+		t.Errorf("%d connections still tracked after closing", len(p.conns))
+	}
+}
+
+func TestChokedConnPeerLimit(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	p := &chokePolicy{seeder: s, conns: make(map[string]*chokedConn)}
+	local, remote := net.Pipe()
+	t.Cleanup(func() { remote.Close() })
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	conn := p.wrap(local).(*chokedConn)
+	defer conn.Close()
+	p.setPeerLimit(64) // Four blocks a second
+
+	// A second's worth fits in the burst, but the next block waits its turn
+	start := time.Now()
+	for range 4 {
+		if _, err := conn.Write(make([]byte, 16*1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("writes within the burst took %s", elapsed)
+	}
+	if _, err := conn.Write(make([]byte, 16*1024)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("writes past the per-peer limit took only %s", elapsed)
+	}
+
+	// Without a limit, writes aren't held up at all
+	p.setPeerLimit(0)
+	start = time.Now()
+	for range 8 {
+		if _, err := conn.Write(make([]byte, 16*1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("unlimited writes took %s", elapsed)
+	}
+}
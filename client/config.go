@@ -0,0 +1,81 @@
+// Package client builds the configuration of the torrent client a seeder runs: its connection
+// and memory limits, its identity, and the DNS cache trackers and webseeds are reached through.
+package client
+
+import (
+	"context"
+	"net"
+	"net/url"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	DefaultRequestBufferKiB = 1024     // Peer request data buffered per connection
+	MinRequestBuffer        = 16 << 10 // A request chunk, the least a connection can buffer
+	DefaultPieceHashers     = 2        // Pieces hashed at once per torrent
+)
+
+// Tuning holds the client settings fixed once it's created
+type Tuning struct {
+	PeerPort           int
+	ConnsPerTorrent    int
+	HalfOpenPerTorrent int
+	TotalHalfOpen      int
+	RequestBuffer      int // Bytes of peer request data buffered per connection
+	PieceHashers       int
+	DisablePEX         bool
+
+	PeerIDPrefix string // Start of the peer ID, naming the client and its version
+	Name         string // Sent in the extended handshake and as the HTTP user agent
+	PeerID       string // Kept from before an upgrade, random if empty
+}
+
+// RequestBufferFor shrinks the per-connection request buffer so that the buffers of maxConns
+// connections fit in half the memory target
+func RequestBufferFor(buffer int, maxMemory int64, maxConns int) int {
+	if maxMemory <= 0 || maxConns <= 0 {
+		return buffer
+	}
+	return max(min(buffer, int(maxMemory/2/int64(maxConns))), MinRequestBuffer)
+}
+
+// NewConfig returns the configuration of a client seeding from dataDir, reaching trackers and
+// webseeds through resolver. Storage, rate limits, peer discovery and the TCP listener are left
+// for the caller to set up.
+func NewConfig(dataDir string, tuning Tuning, resolver *DNSCache) *torrent.ClientConfig {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = dataDir
+	cfg.Seed = true
+	cfg.NoUpload = false // Allow uploading
+	cfg.ListenPort = tuning.PeerPort
+
+	// **Configurable Connection Limits**
+	cfg.EstablishedConnsPerTorrent = tuning.ConnsPerTorrent // Adjusted per torrent as slots are rebalanced
+	cfg.HalfOpenConnsPerTorrent = tuning.HalfOpenPerTorrent
+	cfg.TotalHalfOpenConns = tuning.TotalHalfOpen
+
+	// **Memory Use**
+	cfg.MaxAllocPeerRequestDataPerConn = tuning.RequestBuffer
+	cfg.PieceHashersPerTorrent = tuning.PieceHashers
+
+	// **Peer Discovery**
+	cfg.NoDHT = true                   // DHT servers are started per configured network instead
+	cfg.DisablePEX = tuning.DisablePEX // Peer Exchange (PEX), also turned off per torrent
+
+	// **Cache DNS for Trackers and Webseeds**
+	cfg.LookupTrackerIp = func(u *url.URL) ([]net.IP, error) {
+		return resolver.LookupIP(context.Background(), u.Hostname())
+	}
+	cfg.TrackerDialContext = resolver.DialContext
+	cfg.HTTPDialContext = resolver.DialContext
+
+	// **Identify Ourselves**
+	cfg.Bep20 = tuning.PeerIDPrefix
+	cfg.ExtendedHandshakeClientVersion = tuning.Name
+	cfg.HTTPUserAgent = tuning.Name
+	if len(tuning.PeerID) == len(torrent.PeerID{}) {
+		cfg.PeerID = tuning.PeerID
+	}
+	return cfg
+}
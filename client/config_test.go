@@ -0,0 +1,35 @@
+package client
+
+import "testing"
+
+func TestRequestBufferFor(t *testing.T) {
+	tests := []struct {
+		buffer    int
+		maxMemory int64
+		maxConns  int
+		want      int
+	}{
+		{buffer: 1 << 20, want: 1 << 20},                                    // No target
+		{buffer: 1 << 20, maxMemory: 1 << 30, want: 1 << 20},                // No connection cap
+		{buffer: 1 << 20, maxMemory: 1 << 30, maxConns: 100, want: 1 << 20}, // Fits
+		{buffer: 1 << 20, maxMemory: 256 << 20, maxConns: 1024, want: 128 << 10},
+		{buffer: 1 << 20, maxMemory: 64 << 20, maxConns: 100000, want: MinRequestBuffer},
+	}
+	for _, tt := range tests {
+		if got := RequestBufferFor(tt.buffer, tt.maxMemory, tt.maxConns); got != tt.want {
+			t.Errorf("RequestBufferFor(%d, %d, %d) = %d, want %d", tt.buffer, tt.maxMemory, tt.maxConns, got, tt.want)
+		}
+	}
+}
+
+func TestNewConfigKeepsPeerID(t *testing.T) {
+	id := "-DS0100-abcdefghijkl"
+	cfg := NewConfig(t.TempDir(), Tuning{PeerIDPrefix: "-DS0100-", PeerID: id}, NewDNSCache(0, 0))
+	if cfg.PeerID != id {
+		t.Errorf("PeerID = %q, want %q", cfg.PeerID, id)
+	}
+	// A malformed saved ID is left for the client to generate
+	if cfg := NewConfig(t.TempDir(), Tuning{PeerID: "short"}, NewDNSCache(0, 0)); cfg.PeerID == "short" {
+		t.Error("used a peer ID of the wrong length")
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDNSCacheTTL    = 5 * time.Minute  // How long resolved addresses are reused
+	DefaultDNSNegativeTTL = 30 * time.Second // How long failed lookups are remembered
+)
+
+// DNSCache resolves tracker and webseed hostnames, reusing results for a TTL. When a lookup
+// fails, the last good addresses are kept in use, so a resolver outage doesn't stop announces.
+type DNSCache struct {
+	ttl         time.Duration // 0 disables caching
+	negativeTTL time.Duration
+	resolver    *net.Resolver
+	dialer      net.Dialer
+
+	mu      sync.Mutex
+	entries map[string]*dnsEntry
+}
+
+type dnsEntry struct {
+	ips     []net.IP
+	err     error // Set for a cached failure
+	expires time.Time
+}
+
+// NewDNSCache returns a cache that reuses lookups for ttl and failed ones for negativeTTL
+func NewDNSCache(ttl, negativeTTL time.Duration) *DNSCache {
+	return &DNSCache{
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		resolver:    net.DefaultResolver,
+		entries:     make(map[string]*dnsEntry),
+	}
+}
+
+// LookupIP returns the addresses of host, from the cache while they're fresh
+func (c *DNSCache) LookupIP(ctx context.Context, host string) ([]net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	if c.ttl <= 0 {
+		return c.resolve(ctx, host)
+	}
+
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.ips, entry.err
+	}
+
+	ips, err := c.resolve(ctx, host)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case err == nil:
+		c.entries[host] = &dnsEntry{ips: ips, expires: now.Add(c.ttl)}
+	case ok && entry.err == nil:
+		// Serve the stale addresses for a while rather than failing
+		log.Printf("⚠️ DNS lookup for %s failed, reusing cached addresses: %v", host, err)
+		entry.expires = now.Add(c.negativeTTL)
+		return entry.ips, nil
+	case c.negativeTTL > 0:
+		c.entries[host] = &dnsEntry{err: err, expires: now.Add(c.negativeTTL)}
+	}
+	return ips, err
+}
+
+func (c *DNSCache) resolve(ctx context.Context, host string) ([]net.IP, error) {
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	ips := make([]net.IP, len(addrs))
+	for i, addr := range addrs {
+		ips[i] = addr.IP
+	}
+	return ips, nil
+}
+
+// DialContext dials addr using cached addresses for its host, trying each in turn
+func (c *DNSCache) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := c.LookupIP(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	var errs []error
+	for _, ip := range ips {
+		conn, err := c.dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
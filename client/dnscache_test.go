@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// newFailingDNSCache returns a cache whose lookups all fail, counting them
+func newFailingDNSCache(lookups *int) *DNSCache {
+	c := NewDNSCache(time.Minute, 10*time.Second)
+	c.resolver = &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		*lookups++
+		return nil, errors.New("resolver unreachable")
+	}}
+	return c
+}
+
+func TestDNSCacheServesFreshEntries(t *testing.T) {
+	var lookups int
+	c := newFailingDNSCache(&lookups)
+	want := []net.IP{net.ParseIP("192.0.2.1")}
+	c.entries["tracker.example.com"] = &dnsEntry{ips: want, expires: time.Now().Add(time.Minute)}
+
+	ips, err := c.LookupIP(context.Background(), "tracker.example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(want[0]) {
+		t.Errorf("LookupIP = %v, %v, want %v", ips, err, want)
+	}
+	if lookups != 0 {
+		t.Errorf("resolved a fresh entry %d times", lookups)
+	}
+}
+
+func TestDNSCacheFallsBackToStaleEntries(t *testing.T) {
+	var lookups int
+	c := newFailingDNSCache(&lookups)
+	want := []net.IP{net.ParseIP("192.0.2.1")}
+	c.entries["tracker.example.com"] = &dnsEntry{ips: want, expires: time.Now().Add(-time.Second)}
+
+	ips, err := c.LookupIP(context.Background(), "tracker.example.com")
+	if err != nil || len(ips) != 1 || !ips[0].Equal(want[0]) {
+		t.Errorf("LookupIP with the resolver down = %v, %v, want the stale %v", ips, err, want)
+	}
+	if lookups == 0 {
+		t.Error("an expired entry wasn't resolved again")
+	}
+	// The stale addresses are used without retrying for the negative TTL
+	lookups = 0
+	c.LookupIP(context.Background(), "tracker.example.com")
+	if lookups != 0 {
+		t.Errorf("retried the lookup %d times within the negative TTL", lookups)
+	}
+}
+
+func TestDNSCacheRemembersFailures(t *testing.T) {
+	var lookups int
+	c := newFailingDNSCache(&lookups)
+	if _, err := c.LookupIP(context.Background(), "tracker.example.com"); err == nil {
+		t.Fatal("LookupIP with the resolver down didn't fail")
+	}
+	lookups = 0
+	if _, err := c.LookupIP(context.Background(), "tracker.example.com"); err == nil {
+		t.Error("a cached failure didn't fail")
+	}
+	if lookups != 0 {
+		t.Errorf("retried a failed lookup %d times within the negative TTL", lookups)
+	}
+
+	// IP addresses need no lookup
+	if ips, err := c.LookupIP(context.Background(), "2001:db8::1"); err != nil || len(ips) != 1 {
+		t.Errorf("LookupIP of an address = %v, %v", ips, err)
+	}
+}
@@ -0,0 +1,259 @@
+package distroseed
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+const importDirName = "imported" // Under the download directory, where imported .torrent files are kept
+
+// importedTorrent is a torrent found in another client's state, with where that client kept its
+// data
+type importedTorrent struct {
+	TorrentFile string
+	SavePath    string // The directory the torrent's name is in
+	Labels      []string
+}
+
+// qbittorrentResume is what's used of a qBittorrent .fastresume file
+type qbittorrentResume struct {
+	SavePath   string   `bencode:"save_path"`
+	QBSavePath string   `bencode:"qBt-savePath"`
+	QBCategory string   `bencode:"qBt-category"`
+	QBTags     []string `bencode:"qBt-tags"`
+}
+
+// transmissionResume is what's used of a Transmission .resume file
+type transmissionResume struct {
+	Destination string   `bencode:"destination"`
+	Labels      []string `bencode:"labels"`
+}
+
+// ImportTorrents moves the torrents of qBittorrent or Transmission over to a seeder, seeding
+// their data where it already is. Their .torrent files are copied into downloadDir and added to
+// configFile, after sample pieces of each are checked, unless incomplete ones are wanted too.
+func ImportTorrents(stateDir, from, downloadDir, configFile string, sample int, incomplete bool) error {
+	if from == "" {
+		from = guessTorrentClient(stateDir)
+	}
+	var found []importedTorrent
+	var err error
+	switch from {
+	case "qbittorrent":
+		found, err = qbittorrentTorrents(stateDir)
+	case "transmission":
+		found, err = transmissionTorrents(stateDir)
+	case "":
+		return fmt.Errorf("❌ Couldn't tell which client '%s' is from, pass -from qbittorrent or -from transmission", stateDir)
+	default:
+		return fmt.Errorf("❌ Unknown client '%s', use qbittorrent or transmission", from)
+	}
+	if err != nil {
+		return err
+	}
+
+	cfg, err := readConfigObject(configFile)
+	if err != nil {
+		return err
+	}
+	// Only the torrent sources are changed, other settings are written back as they were
+	var sources struct {
+		URLs           []string
+		TorrentDirs    map[string]string
+		TorrentOptions map[string]torrentOptions
+	}
+	for key, target := range map[string]any{"urls": &sources.URLs, "torrent_dirs": &sources.TorrentDirs, "torrent_options": &sources.TorrentOptions} {
+		if raw, ok := cfg[key]; ok {
+			if err := json.Unmarshal(raw, target); err != nil {
+				return fmt.Errorf("❌ Failed to parse %s in config file '%s': %w", key, configFile, err)
+			}
+		}
+	}
+	sources.TorrentDirs = cloneOrMake(sources.TorrentDirs)
+	sources.TorrentOptions = cloneOrMake(sources.TorrentOptions)
+
+	importDir := filepath.Join(downloadDir, importDirName)
+	if err := os.MkdirAll(importDir, 0755); err != nil {
+		return err
+	}
+	imported, skipped := 0, 0
+	for _, it := range found {
+		mi, err := metainfo.LoadFromFile(it.TorrentFile)
+		if err != nil {
+			log.Printf("⚠️ %s: could not load torrent file: %v", it.TorrentFile, err)
+			skipped++
+			continue
+		}
+		info, err := mi.UnmarshalInfo()
+		if err != nil {
+			log.Printf("⚠️ %s: invalid metadata: %v", it.TorrentFile, err)
+			skipped++
+			continue
+		}
+		ih := mi.HashInfoBytes().HexString()
+		dest, err := filepath.Abs(filepath.Join(importDir, ih+".torrent"))
+		if err != nil {
+			return err
+		}
+		if slices.Contains(sources.URLs, dest) {
+			log.Printf("✅ %s: already imported", info.BestName())
+			continue
+		}
+		if it.SavePath == "" {
+			log.Printf("⚠️ %s: the client doesn't say where its data is", info.BestName())
+			skipped++
+			continue
+		}
+
+		checked, failed, err := verifyPieceSample(&info, it.SavePath, nil, sample)
+		switch {
+		case err != nil && !incomplete:
+			log.Printf("❌ %s: %v", info.BestName(), err)
+			skipped++
+			continue
+		case failed > 0 && !incomplete:
+			log.Printf("❌ %s: %d of %d sampled pieces don't match in %s", info.BestName(), failed, checked, it.SavePath)
+			skipped++
+			continue
+		case err != nil || failed > 0:
+			log.Printf("⏬ %s: incomplete in %s, the rest will be downloaded", info.BestName(), it.SavePath)
+		default:
+			log.Printf("✅ %s: %d sampled pieces verified in %s", info.BestName(), checked, it.SavePath)
+		}
+
+		data, err := os.ReadFile(it.TorrentFile)
+		if err != nil {
+			return err
+		}
+		if err := atomicfile.Write(dest, data, 0644); err != nil {
+			return err
+		}
+		sources.URLs = append(sources.URLs, dest)
+		sources.TorrentDirs[dest] = it.SavePath
+		if len(it.Labels) > 0 {
+			sources.TorrentOptions[dest] = torrentOptions{Labels: it.Labels}
+		}
+		imported++
+	}
+
+	for key, value := range map[string]any{"urls": sources.URLs, "torrent_dirs": sources.TorrentDirs, "torrent_options": sources.TorrentOptions} {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		cfg[key] = raw
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.Write(configFile, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("❌ Failed to write config file: %w", err)
+	}
+	log.Printf("📥 Imported %d torrents from %s into %s, skipped %d", imported, from, configFile, skipped)
+	if skipped > 0 {
+		return fmt.Errorf("❌ %d torrents couldn't be imported", skipped)
+	}
+	return nil
+}
+
+// guessTorrentClient tells which client a state directory is from by its files: qBittorrent's
+// BT_backup has .fastresume files, and Transmission's config directory has resume and torrents
+// directories
+func guessTorrentClient(dir string) string {
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*.fastresume")); len(matches) > 0 {
+		return "qbittorrent"
+	}
+	if info, err := os.Stat(filepath.Join(dir, "resume")); err == nil && info.IsDir() {
+		return "transmission"
+	}
+	return ""
+}
+
+// qbittorrentTorrents reads qBittorrent's BT_backup directory, where each torrent has a .torrent
+// and a .fastresume file named by its infohash. The category and tags become labels.
+func qbittorrentTorrents(dir string) ([]importedTorrent, error) {
+	resumes, err := filepath.Glob(filepath.Join(dir, "*.fastresume"))
+	if err != nil {
+		return nil, err
+	}
+	var found []importedTorrent
+	for _, path := range resumes {
+		torrentFile := strings.TrimSuffix(path, ".fastresume") + ".torrent"
+		if _, err := os.Stat(torrentFile); err != nil {
+			log.Printf("⚠️ %s: no .torrent file, skipping magnets without metadata", filepath.Base(path))
+			continue
+		}
+		var resume qbittorrentResume
+		if err := readBencodeFile(path, &resume); err != nil {
+			log.Printf("⚠️ %s: %v", filepath.Base(path), err)
+			continue
+		}
+		it := importedTorrent{TorrentFile: torrentFile, SavePath: cmp.Or(resume.QBSavePath, resume.SavePath)}
+		if resume.QBCategory != "" {
+			it.Labels = append(it.Labels, resume.QBCategory)
+		}
+		it.Labels = appendMissing(it.Labels, slices.DeleteFunc(resume.QBTags, func(tag string) bool { return tag == "" })...)
+		found = append(found, it)
+	}
+	return found, nil
+}
+
+// transmissionTorrents reads Transmission's config directory, where each torrent has a .torrent
+// file in torrents and a .resume file with the same name in resume
+func transmissionTorrents(dir string) ([]importedTorrent, error) {
+	torrentFiles, err := filepath.Glob(filepath.Join(dir, "torrents", "*.torrent"))
+	if err != nil {
+		return nil, err
+	}
+	var found []importedTorrent
+	for _, torrentFile := range torrentFiles {
+		name := strings.TrimSuffix(filepath.Base(torrentFile), ".torrent")
+		var resume transmissionResume
+		if err := readBencodeFile(filepath.Join(dir, "resume", name+".resume"), &resume); err != nil {
+			log.Printf("⚠️ %s: %v", name, err)
+			continue
+		}
+		found = append(found, importedTorrent{TorrentFile: torrentFile, SavePath: resume.Destination, Labels: resume.Labels})
+	}
+	return found, nil
+}
+
+func readBencodeFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := bencode.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid resume data: %w", err)
+	}
+	return nil
+}
+
+// readConfigObject reads a JSON config file as its settings, keeping the ones import doesn't
+// change as they were. A missing file has no settings yet.
+func readConfigObject(path string) (map[string]json.RawMessage, error) {
+	cfg := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to read config file: %w", err)
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("❌ Failed to parse config file '%s': %w", path, err)
+	}
+	return cfg, nil
+}
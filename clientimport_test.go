@@ -0,0 +1,108 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// writeClientState writes a torrent's .torrent and bencoded resume data the way another client
+// keeps them
+func writeClientState(t *testing.T, torrentFile, resumeFile string, mi *metainfo.MetaInfo, resume map[string]any) {
+	t.Helper()
+	for _, dir := range []string{filepath.Dir(torrentFile), filepath.Dir(resumeFile)} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.Create(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mi.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	data, err := bencode.Marshal(resume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(resumeFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportQBittorrent(t *testing.T) {
+	dataDir, state, downloadDir := t.TempDir(), t.TempDir(), t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(configFile, []byte(`{"upload_limit": 512, "urls": ["https://example.com/other.torrent"]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mi := newTestMeta(t, dataDir, "image.iso", 64<<10)
+	ih := mi.HashInfoBytes().HexString()
+	writeClientState(t, filepath.Join(state, ih+".torrent"), filepath.Join(state, ih+".fastresume"), mi,
+		map[string]any{"save_path": dataDir, "qBt-category": "ubuntu", "qBt-tags": []string{"lts"}})
+
+	if err := ImportTorrents(state, "", downloadDir, configFile, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := testConfig().withConfigFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := filepath.Join(downloadDir, importDirName, ih+".torrent")
+	if cfg.UploadLimit != 512 || !slices.Equal(cfg.TorrentURLs, []string{"https://example.com/other.torrent", imported}) {
+		t.Fatalf("config after import: upload limit %d, urls %v", cfg.UploadLimit, cfg.TorrentURLs)
+	}
+	if cfg.TorrentDirs[imported] != dataDir || !slices.Equal(cfg.TorrentOptions[imported].Labels, []string{"ubuntu", "lts"}) {
+		t.Errorf("imported torrent: dir %q, options %+v", cfg.TorrentDirs[imported], cfg.TorrentOptions[imported])
+	}
+	if _, err := metainfo.LoadFromFile(imported); err != nil {
+		t.Errorf("imported torrent file: %v", err)
+	}
+
+	// Importing again doesn't add the torrent twice
+	if err := ImportTorrents(state, "qbittorrent", downloadDir, configFile, 8, false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := testConfig().withConfigFile(configFile); len(cfg.TorrentURLs) != 2 {
+		t.Errorf("urls after importing again: %v", cfg.TorrentURLs)
+	}
+}
+
+func TestImportTransmission(t *testing.T) {
+	dataDir, state, downloadDir := t.TempDir(), t.TempDir(), t.TempDir()
+	configFile := filepath.Join(t.TempDir(), "config.json")
+	complete := newTestMeta(t, dataDir, "complete.iso", 64<<10)
+	writeClientState(t, filepath.Join(state, "torrents", "complete.iso.0123456789abcdef.torrent"), filepath.Join(state, "resume", "complete.iso.0123456789abcdef.resume"), complete,
+		map[string]any{"destination": dataDir})
+	missing := newTestMeta(t, t.TempDir(), "missing.iso", 64<<10)
+	writeClientState(t, filepath.Join(state, "torrents", "missing.iso.torrent"), filepath.Join(state, "resume", "missing.iso.resume"), missing,
+		map[string]any{"destination": dataDir, "labels": []string{"debian"}})
+
+	// Torrents without their data are left out unless asked for
+	if err := ImportTorrents(state, "", downloadDir, configFile, 8, false); err == nil {
+		t.Error("importing a torrent without its data succeeded")
+	}
+	cfg, err := testConfig().withConfigFile(configFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completeFile := filepath.Join(downloadDir, importDirName, complete.HashInfoBytes().HexString()+".torrent")
+	if !slices.Equal(cfg.TorrentURLs, []string{completeFile}) || cfg.TorrentDirs[completeFile] != dataDir {
+		t.Fatalf("after importing: urls %v, dirs %v", cfg.TorrentURLs, cfg.TorrentDirs)
+	}
+
+	if err := ImportTorrents(state, "transmission", downloadDir, configFile, 8, true); err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ = testConfig().withConfigFile(configFile)
+	missingFile := filepath.Join(downloadDir, importDirName, missing.HashInfoBytes().HexString()+".torrent")
+	if len(cfg.TorrentURLs) != 2 || !slices.Equal(cfg.TorrentOptions[missingFile].Labels, []string{"debian"}) {
+		t.Errorf("after importing incomplete torrents: urls %v, options %+v", cfg.TorrentURLs, cfg.TorrentOptions)
+	}
+}
@@ -0,0 +1,352 @@
+package distroseed
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	clusterHeartbeatInterval = 30 * time.Second
+	clusterNodeTimeout       = 3 * clusterHeartbeatInterval // Members are dropped after missing this many heartbeats
+)
+
+// clusterNode is this seeder's part in a cluster. The coordinator's configured torrents are the
+// catalog, which is divided among it and the members that send it heartbeats. Members seed
+// their share on top of the torrents they're configured with.
+type clusterNode struct {
+	seeder      *Seeder
+	id          string
+	coordinator *clusterCoordinator // Only on the coordinator
+	joinURL     string              // Only on members
+	token       string
+
+	mu       sync.Mutex
+	assigned []string
+}
+
+// torrentURLs returns the torrents this seeder should have loaded with the configuration
+func (c *clusterNode) torrentURLs(cfg runtimeConfig) []string {
+	if c == nil {
+		return cfg.TorrentURLs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.coordinator != nil {
+		return slices.DeleteFunc(slices.Clone(cfg.TorrentURLs), func(url string) bool {
+			return !slices.Contains(c.assigned, url)
+		})
+	}
+	urls := slices.Clone(cfg.TorrentURLs)
+	for _, url := range c.assigned {
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// reassign replaces this seeder's share of the catalog, adding and removing torrents to match
+func (c *clusterNode) reassign(ctx context.Context, client *torrent.Client, downloadDir string, assigned []string) {
+	c.seeder.configMu.Lock()
+	defer c.seeder.configMu.Unlock()
+
+	cfg := c.seeder.liveSettings.Get()
+	prev := c.torrentURLs(cfg)
+	c.mu.Lock()
+	c.assigned = assigned
+	c.mu.Unlock()
+	next := c.torrentURLs(cfg)
+
+	added, removed := missingURLs(next, prev), missingURLs(prev, next)
+	if len(added) == 0 && len(removed) == 0 {
+		return
+	}
+	log.Printf("🛰️ Cluster share changed: %d torrents added, %d removed", len(added), len(removed))
+	c.seeder.dropTorrents(removed)
+	c.seeder.processTorrents(ctx, client, added, downloadDir)
+}
+
+// clusterHeartbeat is what members report to the coordinator
+type clusterHeartbeat struct {
+	Node     string           `json:"node"`
+	Uploaded int64            `json:"uploaded"` // Bytes since the member started
+	Peers    int              `json:"peers"`
+	Torrents map[string]int64 `json:"torrents"` // Bytes uploaded since start by URL, for the torrents it has loaded
+}
+
+// clusterAssignment is the coordinator's reply, the member's share of the catalog
+type clusterAssignment struct {
+	Torrents []string `json:"torrents"`
+}
+
+// localHeartbeat reports this seeder's own stats
+func (s *Seeder) localHeartbeat(id string, client *torrent.Client) clusterHeartbeat {
+	hb := clusterHeartbeat{Node: id, Torrents: make(map[string]int64)}
+	for _, t := range client.Torrents() {
+		uploaded := s.sessionUploaded(t)
+		hb.Uploaded += uploaded
+		hb.Peers += len(t.PeerConns())
+		for _, url := range s.torrentSources.URLs(t) {
+			hb.Torrents[url] = uploaded
+		}
+	}
+	return hb
+}
+
+// Send heartbeats to the coordinator and seed the share it replies with. While it can't be
+// reached, the current share keeps being seeded.
+func (c *clusterNode) runMember(ctx context.Context, client *torrent.Client, downloadDir string) {
+	log.Printf("🛰️ Joining cluster at %s as %s", c.joinURL, c.id)
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	ticker := time.NewTicker(clusterHeartbeatInterval)
+	defer ticker.Stop()
+
+	delivered := true
+	for {
+		assignment, err := c.sendHeartbeat(ctx, httpClient, c.seeder.localHeartbeat(c.id, client))
+		switch {
+		case err != nil && ctx.Err() != nil:
+			return
+		case err != nil:
+			if delivered {
+				log.Printf("⚠️ Cluster heartbeat failed, keeping current share: %v", err)
+			}
+			delivered = false
+		default:
+			if !delivered {
+				log.Printf("🛰️ Cluster heartbeats are getting through again")
+			}
+			delivered = true
+			c.reassign(ctx, client, downloadDir, assignment.Torrents)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *clusterNode) sendHeartbeat(ctx context.Context, httpClient *http.Client, hb clusterHeartbeat) (clusterAssignment, error) {
+	var assignment clusterAssignment
+	body, err := json.Marshal(hb)
+	if err != nil {
+		return assignment, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.joinURL, "/")+"/api/cluster/heartbeat", bytes.NewReader(body))
+	if err != nil {
+		return assignment, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return assignment, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return assignment, fmt.Errorf("coordinator replied %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&assignment); err != nil {
+		return assignment, fmt.Errorf("❌ Failed to parse cluster assignment: %w", err)
+	}
+	return assignment, nil
+}
+
+// clusterCoordinator keeps track of the members and divides the catalog among them
+type clusterCoordinator struct {
+	replicas int // Seeders each torrent is assigned to
+
+	mu      sync.Mutex
+	members map[string]clusterHeartbeat
+	seen    map[string]time.Time
+	changed chan struct{}
+}
+
+func newClusterCoordinator(replicas int) *clusterCoordinator {
+	return &clusterCoordinator{
+		replicas: replicas,
+		members:  make(map[string]clusterHeartbeat),
+		seen:     make(map[string]time.Time),
+		changed:  make(chan struct{}, 1),
+	}
+}
+
+// Heartbeat records a member's stats, returning its share of the catalog
+func (co *clusterCoordinator) Heartbeat(self string, hb clusterHeartbeat, catalog []string) clusterAssignment {
+	co.mu.Lock()
+	_, known := co.members[hb.Node]
+	co.members[hb.Node] = hb
+	co.seen[hb.Node] = time.Now()
+	nodes := co.nodes(self)
+	co.mu.Unlock()
+
+	if !known {
+		log.Printf("🛰️ Cluster member joined: %s (%d nodes)", hb.Node, len(nodes))
+		select {
+		case co.changed <- struct{}{}:
+		default:
+		}
+	}
+	return clusterAssignment{Torrents: assignTorrents(catalog, nodes, co.replicas)[hb.Node]}
+}
+
+// nodes returns the coordinator and the current members, the caller must hold co.mu
+func (co *clusterCoordinator) nodes(self string) []string {
+	nodes := []string{self}
+	for id := range co.members {
+		if id != self {
+			nodes = append(nodes, id)
+		}
+	}
+	return nodes
+}
+
+// expire drops members that have stopped sending heartbeats
+func (co *clusterCoordinator) expire(now time.Time) []string {
+	co.mu.Lock()
+	defer co.mu.Unlock()
+	var gone []string
+	for id, seen := range co.seen {
+		if now.Sub(seen) > clusterNodeTimeout {
+			gone = append(gone, id)
+			delete(co.members, id)
+			delete(co.seen, id)
+		}
+	}
+	return gone
+}
+
+// Rebalance the catalog as members come and go and the configured torrents change
+func (c *clusterNode) runCoordinator(ctx context.Context, client *torrent.Client, downloadDir string) {
+	co := c.coordinator
+	log.Printf("🛰️ Coordinating cluster as %s, each torrent seeded by %d nodes", c.id, co.replicas)
+	ticker := time.NewTicker(clusterHeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		gone := co.expire(time.Now())
+		co.mu.Lock()
+		nodes := co.nodes(c.id)
+		co.mu.Unlock()
+		for _, id := range gone {
+			log.Printf("🛰️ Cluster member left: %s (%d nodes)", id, len(nodes))
+		}
+		c.reassign(ctx, client, downloadDir, assignTorrents(c.seeder.liveSettings.Get().TorrentURLs, nodes, co.replicas)[c.id])
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-co.changed:
+		case <-c.seeder.liveSettings.Changed():
+		}
+	}
+}
+
+// clusterStatus is the coordinator's view of the cluster, with fleet-wide upload stats
+type clusterStatus struct {
+	Nodes    []clusterNodeStatus `json:"nodes"`
+	Uploaded int64               `json:"uploaded"` // Bytes uploaded by all nodes since they started
+	Peers    int                 `json:"peers"`
+	Torrents []clusterTorrent    `json:"torrents"`
+}
+
+type clusterNodeStatus struct {
+	ID       string    `json:"id"`
+	LastSeen time.Time `json:"last_seen"`
+	Uploaded int64     `json:"uploaded"`
+	Peers    int       `json:"peers"`
+	Assigned int       `json:"assigned"` // Torrents in its share
+}
+
+type clusterTorrent struct {
+	URL      string   `json:"url"`
+	Nodes    []string `json:"nodes"` // Nodes it's assigned to
+	Uploaded int64    `json:"uploaded"`
+}
+
+// Status aggregates the members' latest heartbeats with the coordinator's own stats
+func (co *clusterCoordinator) Status(self clusterHeartbeat, catalog []string) clusterStatus {
+	co.mu.Lock()
+	heartbeats := map[string]clusterHeartbeat{self.Node: self}
+	seen := map[string]time.Time{self.Node: time.Now()}
+	for id, hb := range co.members {
+		heartbeats[id], seen[id] = hb, co.seen[id]
+	}
+	nodes := co.nodes(self.Node)
+	co.mu.Unlock()
+
+	assignment := assignTorrents(catalog, nodes, co.replicas)
+	status := clusterStatus{Nodes: []clusterNodeStatus{}, Torrents: []clusterTorrent{}}
+	for _, id := range nodes {
+		hb := heartbeats[id]
+		status.Nodes = append(status.Nodes, clusterNodeStatus{
+			ID: id, LastSeen: seen[id], Uploaded: hb.Uploaded, Peers: hb.Peers, Assigned: len(assignment[id]),
+		})
+		status.Uploaded += hb.Uploaded
+		status.Peers += hb.Peers
+	}
+	slices.SortFunc(status.Nodes, func(a, b clusterNodeStatus) int { return cmp.Compare(a.ID, b.ID) })
+
+	for _, url := range catalog {
+		t := clusterTorrent{URL: url, Nodes: []string{}}
+		for _, id := range nodes {
+			if slices.Contains(assignment[id], url) {
+				t.Nodes = append(t.Nodes, id)
+			}
+			t.Uploaded += heartbeats[id].Torrents[url]
+		}
+		slices.Sort(t.Nodes)
+		status.Torrents = append(status.Torrents, t)
+	}
+	return status
+}
+
+// assignTorrents divides the catalog among the nodes by rendezvous hashing, so when a node joins
+// or leaves only the torrents it gains or loses move
+func assignTorrents(catalog, nodes []string, replicas int) map[string][]string {
+	assignment := make(map[string][]string, len(nodes))
+	for _, url := range catalog {
+		ranked := slices.Clone(nodes)
+		slices.SortFunc(ranked, func(a, b string) int {
+			return cmp.Or(cmp.Compare(rendezvousScore(b, url), rendezvousScore(a, url)), cmp.Compare(a, b))
+		})
+		for _, id := range ranked[:min(replicas, len(ranked))] {
+			assignment[id] = append(assignment[id], url)
+		}
+	}
+	return assignment
+}
+
+func rendezvousScore(node, url string) uint64 {
+	sum := sha256.Sum256([]byte(node + "\x00" + url))
+	return binary.BigEndian.Uint64(sum[:])
+}
+
+// missingURLs returns the URLs in a that aren't in b
+func missingURLs(a, b []string) []string {
+	var missing []string
+	for _, url := range a {
+		if !slices.Contains(b, url) {
+			missing = append(missing, url)
+		}
+	}
+	return missing
+}
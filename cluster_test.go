@@ -0,0 +1,96 @@
+package distroseed
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAssignTorrents(t *testing.T) {
+	var catalog []string
+	for i := range 100 {
+		catalog = append(catalog, fmt.Sprintf("https://example.com/%d.torrent", i))
+	}
+	nodes := []string{"a", "b", "c"}
+
+	assignment := assignTorrents(catalog, nodes, 2)
+	for _, url := range catalog {
+		var holders int
+		for _, id := range nodes {
+			if slices.Contains(assignment[id], url) {
+				holders++
+			}
+		}
+		if holders != 2 {
+			t.Fatalf("%s is assigned to %d nodes, want 2", url, holders)
+		}
+	}
+
+	// Only the torrents a node held move when it leaves
+	without := assignTorrents(catalog, []string{"a", "b"}, 1)
+	with := assignTorrents(catalog, nodes, 1)
+	for _, id := range []string{"a", "b"} {
+		for _, url := range with[id] {
+			if !slices.Contains(without[id], url) {
+				t.Errorf("%s moved off %s when c left", url, id)
+			}
+		}
+	}
+
+	if got := len(assignTorrents(catalog[:1], nodes, 5)); got != 3 {
+		t.Errorf("with more replicas than nodes, %d nodes got the torrent, want 3", got)
+	}
+}
+
+func TestClusterCoordinatorHeartbeats(t *testing.T) {
+	co := newClusterCoordinator(1)
+	catalog := []string{"https://example.com/a.torrent", "https://example.com/b.torrent", "https://example.com/c.torrent"}
+
+	got := co.Heartbeat("self", clusterHeartbeat{Node: "m", Uploaded: 10, Torrents: map[string]int64{catalog[0]: 10}}, catalog)
+	if want := assignTorrents(catalog, []string{"self", "m"}, 1)["m"]; !reflect.DeepEqual(got.Torrents, want) {
+		t.Errorf("Heartbeat share = %v, want %v", got.Torrents, want)
+	}
+	select {
+	case <-co.changed:
+	default:
+		t.Error("a new member didn't signal a rebalance")
+	}
+	co.Heartbeat("self", clusterHeartbeat{Node: "m"}, catalog)
+	select {
+	case <-co.changed:
+		t.Error("a known member signalled a rebalance")
+	default:
+	}
+
+	status := co.Status(clusterHeartbeat{Node: "self", Uploaded: 5, Torrents: map[string]int64{catalog[0]: 5}}, catalog)
+	if len(status.Nodes) != 2 || status.Uploaded != 5 || status.Torrents[0].Uploaded != 5 {
+		t.Errorf("Status = %+v, want 2 nodes and 5 bytes uploaded", status)
+	}
+
+	if gone := co.expire(time.Now()); len(gone) != 0 {
+		t.Errorf("expire dropped %v straight after a heartbeat", gone)
+	}
+	if gone := co.expire(time.Now().Add(clusterNodeTimeout + time.Second)); !reflect.DeepEqual(gone, []string{"m"}) {
+		t.Errorf("expire after the timeout dropped %v, want [m]", gone)
+	}
+}
+
+func TestClusterTorrentURLs(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	cfg := runtimeConfig{TorrentURLs: []string{"a", "b", "c"}}
+	var none *clusterNode
+	if got := none.torrentURLs(cfg); !reflect.DeepEqual(got, cfg.TorrentURLs) {
+		t.Errorf("without a cluster: %v, want %v", got, cfg.TorrentURLs)
+	}
+
+	coordinator := &clusterNode{seeder: s, coordinator: newClusterCoordinator(1), assigned: []string{"b"}}
+	if got := coordinator.torrentURLs(cfg); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("coordinator: %v, want its share [b]", got)
+	}
+	member := &clusterNode{seeder: s, joinURL: "http://coordinator", assigned: []string{"c", "d"}}
+	if got := member.torrentURLs(cfg); !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("member: %v, want its own torrents and its share", got)
+	}
+}
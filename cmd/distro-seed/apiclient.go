@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pawl/distro-seed/api"
+)
+
+// addCommand adds torrent URLs to a running seeder's live settings. Like other API changes,
+// they last until the next reload unless they're also added to the config file.
+func addCommand(fs *flag.FlagSet) func() error {
+	remote := api.ClientFlags(fs)
+	return func() error {
+		if fs.NArg() == 0 {
+			return errUsage
+		}
+		var cfg struct {
+			TorrentURLs []string `json:"urls"`
+		}
+		if err := remote.Do(http.MethodGet, "/api/config", nil, &cfg); err != nil {
+			return err
+		}
+		urls := cfg.TorrentURLs
+		for _, url := range fs.Args() {
+			if slices.Contains(urls, url) {
+				fmt.Printf("⏭️ %s is already seeded\n", url)
+				continue
+			}
+			urls = append(urls, url)
+		}
+		if len(urls) == len(cfg.TorrentURLs) {
+			return nil
+		}
+		var diff json.RawMessage
+		if err := remote.Do(http.MethodPatch, "/api/config", map[string][]string{"urls": urls}, &diff); err != nil {
+			return err
+		}
+		fmt.Printf("✅ Added %d torrents\n", len(urls)-len(cfg.TorrentURLs))
+		return nil
+	}
+}
+
+// batchCommand returns the setup of a command that applies a batch action to a running seeder's
+// torrents, given by infohash, label or both
+func batchCommand(action, done string) func(fs *flag.FlagSet) func() error {
+	return func(fs *flag.FlagSet) func() error {
+		remote := api.ClientFlags(fs)
+		label := fs.String("label", "", "Act on the torrents with this label, or only the listed ones with it")
+		return func() error {
+			sel := torrentSelection{InfoHashes: fs.Args(), Label: *label}
+			if len(sel.InfoHashes) == 0 && sel.Label == "" {
+				return errUsage
+			}
+			var reply struct {
+				Torrents []batchTorrent `json:"torrents"`
+				NotFound []string       `json:"not_found"`
+			}
+			if err := remote.Do(http.MethodPost, "/api/torrents/"+action, sel, &reply); err != nil {
+				return err
+			}
+			for _, t := range reply.Torrents {
+				switch {
+				case t.Error != "":
+					fmt.Printf("⚠️ %s: %s\n", t.Name, t.Error)
+				case t.Changed:
+					fmt.Printf("✅ %s %s\n", done, t.Name)
+				default:
+					fmt.Printf("⏭️ %s is already %s\n", t.Name, strings.ToLower(done))
+				}
+			}
+			for _, ih := range reply.NotFound {
+				fmt.Printf("❓ No torrent %s\n", ih)
+			}
+			if len(reply.Torrents)+len(reply.NotFound) == 0 {
+				return errors.New("❌ No torrents matched")
+			}
+			return nil
+		}
+	}
+}
+
+// statusCommand prints a running seeder's transfer rates per torrent
+func statusCommand(fs *flag.FlagSet) func() error {
+	remote := api.ClientFlags(fs)
+	return func() error {
+		if fs.NArg() > 0 {
+			return errUsage
+		}
+		var reply struct {
+			Total    transferRates `json:"total"`
+			Torrents []struct {
+				InfoHash string `json:"infohash"`
+				Name     string `json:"name"`
+				transferRates
+			} `json:"torrents"`
+		}
+		if err := remote.Do(http.MethodGet, "/api/rates", nil, &reply); err != nil {
+			return err
+		}
+		if len(reply.Torrents) == 0 {
+			return errors.New("❌ The seeder has no torrents")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tINFOHASH\tUPLOAD\tDOWNLOAD\tUPLOAD 15M\tDOWNLOAD 15M")
+		for _, t := range reply.Torrents {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", t.Name, t.InfoHash, formatRate(t.Upload), formatRate(t.Download),
+				formatRate(t.Upload15m), formatRate(t.Download15m))
+		}
+		fmt.Fprintf(w, "Total\t\t%s\t%s\t%s\t%s\n", formatRate(reply.Total.Upload), formatRate(reply.Total.Download),
+			formatRate(reply.Total.Upload15m), formatRate(reply.Total.Download15m))
+		return w.Flush()
+	}
+}
+
+// eventsCommand prints a torrent's history from a running seeder, oldest first
+func eventsCommand(fs *flag.FlagSet) func() error {
+	remote := api.ClientFlags(fs)
+	kind := fs.String("kind", "", "Only show these kinds of events, separated by commas, like tracker_error,paused")
+	return func() error {
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		path := "/api/torrents/" + fs.Arg(0) + "/events"
+		if *kind != "" {
+			path += "?kind=" + url.QueryEscape(*kind)
+		}
+		var reply struct {
+			Name   string         `json:"name"`
+			Events []torrentEvent `json:"events"`
+		}
+		if err := remote.Do(http.MethodGet, path, nil, &reply); err != nil {
+			return err
+		}
+		if len(reply.Events) == 0 {
+			fmt.Println("No events")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tEVENT\tDETAILS")
+		for _, e := range reply.Events {
+			when := e.Time.Local().Format(time.DateTime)
+			if e.Count > 1 {
+				when += fmt.Sprintf(" (%d times until %s)", e.Count, e.last().Local().Format(time.DateTime))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", when, e.Kind, e.Message)
+		}
+		return w.Flush()
+	}
+}
+
+// torrentSelection picks the torrents a batch action applies to
+type torrentSelection struct {
+	InfoHashes []string `json:"infohashes,omitempty"`
+	Label      string   `json:"label,omitempty"`
+}
+
+// batchTorrent is the outcome of a batch action for one torrent
+type batchTorrent struct {
+	InfoHash string `json:"infohash"`
+	Name     string `json:"name"`
+	Changed  bool   `json:"changed"`
+	Error    string `json:"error,omitempty"`
+}
+
+// transferRates are the rates the seeder reports, in bytes per second
+type transferRates struct {
+	Upload      int64 `json:"upload"`
+	Download    int64 `json:"download"`
+	Upload15m   int64 `json:"upload_15m"`
+	Download15m int64 `json:"download_15m"`
+}
+
+// torrentEvent is an entry of a torrent's history
+type torrentEvent struct {
+	Time    time.Time  `json:"time"`
+	Kind    string     `json:"kind"`
+	Message string     `json:"message,omitempty"`
+	Count   int        `json:"count,omitempty"`
+	Last    *time.Time `json:"last,omitempty"`
+}
+
+// last returns when the event last happened
+func (e torrentEvent) last() time.Time {
+	if e.Last != nil {
+		return *e.Last
+	}
+	return e.Time
+}
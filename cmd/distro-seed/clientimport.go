@@ -0,0 +1,23 @@
+package main
+
+import (
+	"flag"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// importCommand implements the import subcommand, which moves the torrents of qBittorrent or
+// Transmission over to the seeder, seeding their data where it already is
+func importCommand(fs *flag.FlagSet) func() error {
+	from := fs.String("from", "", "Client the state is from: qbittorrent or transmission, guessed from the directory if empty")
+	downloadDir := fs.String("dir", "./downloads", "Download directory of the seeder, where the .torrent files are copied to")
+	configFile := fs.String("config", "", "Config file to add the torrents to, created if it doesn't exist")
+	sample := fs.Int("sample", 8, "Number of pieces to verify per torrent")
+	incomplete := fs.Bool("incomplete", false, "Import torrents whose sampled pieces are missing or don't match too, and download the rest")
+	return func() error {
+		if fs.NArg() != 1 || *configFile == "" {
+			return errUsage
+		}
+		return distroseed.ImportTorrents(fs.Arg(0), *from, *downloadDir, *configFile, *sample, *incomplete)
+	}
+}
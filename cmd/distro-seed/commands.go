@@ -0,0 +1,149 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// command is a subcommand of distro-seed. setup defines its flags on fs and returns the function
+// that runs it once they're parsed, so help and shell completions can list the flags without
+// running anything.
+type command struct {
+	name    string
+	args    string // Positional arguments, for usage
+	summary string
+	setup   func(fs *flag.FlagSet) func() error
+}
+
+// The commands, in the order help lists them. Filled in by init, since help refers back to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"serve", "", "Seed torrents, the default when no command is given", serveCommand},
+		{"add", "url...", "Add torrents to a running seeder through its management API", addCommand},
+		{"status", "", "Show a running seeder's torrents and transfer rates", statusCommand},
+		{"pause", "[infohash...]", "Pause torrents of a running seeder, by infohash or -label", batchCommand("pause", "Paused")},
+		{"resume", "[infohash...]", "Resume paused torrents of a running seeder", batchCommand("resume", "Resumed")},
+		{"remove", "[infohash...]", "Remove torrents from a running seeder until its next reload", batchCommand("remove", "Removed")},
+		{"reannounce", "[infohash...]", "Re-announce torrents of a running seeder to trackers and the DHT", batchCommand("reannounce", "Re-announced")},
+		{"events", "infohash", "Show what happened to a torrent of a running seeder, like errors and pauses", eventsCommand},
+		{"create", "path", "Create a torrent file for a file or directory", createCommand},
+		{"simulate", "trace", "Replay a recorded swarm trace against the upload strategies, without networking", simulateCommand},
+		{"config", "check [file]", "Check a config file without starting the seeder", configCommand},
+		{"relocate-datadir", "", "Update the registry after the data directory moved", relocateDataDirCommand},
+		{"import", "dir", "Import the torrents of qBittorrent or Transmission, seeding their data where it is", importCommand},
+		{"service", "install [serve flags]|uninstall", "Install the seeder as a Windows service, or uninstall it", serviceCommand},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", completionCommand},
+		{"help", "[command]", "Show help for a command", helpCommand},
+	}
+}
+
+// serveCommand runs the seeder until it's signaled, or stopped by the service manager
+func serveCommand(fs *flag.FlagSet) func() error {
+	var cfg distroseed.Config
+	cfg.RegisterFlags(fs)
+	version := fs.Bool("version", false, "Print the version and exit")
+	return func() error {
+		if *version {
+			fmt.Println(distroseed.Version())
+			return nil
+		}
+		return distroseed.Serve(serviceContext(), cfg)
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs the command named by the first argument. Without one, or when the arguments
+// start with a flag, the seeder is run as it was before there were subcommands.
+func runCommand(args []string) error {
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	c, ok := findCommand(name)
+	if !ok {
+		printUsage(os.Stderr)
+		return fmt.Errorf("❌ Unknown command %q", name)
+	}
+	fs := flag.NewFlagSet(c.name, flag.ExitOnError)
+	run := c.setup(fs)
+	fs.Usage = commandUsage(fs, c)
+	if err := applyFlagEnv(fs); err != nil {
+		return err
+	}
+	fs.Parse(args)
+	return run()
+}
+
+// commandUsage prints the command's usage and flags
+func commandUsage(fs *flag.FlagSet, c command) func() {
+	return func() {
+		w := fs.Output()
+		fmt.Fprintf(w, "%s\n\nUsage: %s\n", c.summary, strings.TrimSpace("distro-seed "+c.name+" [flags] "+c.args))
+		if c.name == "serve" {
+			fmt.Fprintln(w, "\nRun distro-seed help for the other commands.")
+		}
+		var first *flag.Flag
+		fs.VisitAll(func(f *flag.Flag) {
+			if first == nil {
+				first = f
+			}
+		})
+		if first != nil {
+			fmt.Fprintln(w, "\nFlags:")
+			fs.PrintDefaults()
+			fmt.Fprintf(w, "\nFlags can also be set in the environment, like -%s as %s.\n", first.Name, flagEnvName(first.Name))
+		}
+	}
+}
+
+func printUsage(w *os.File) {
+	fmt.Fprintln(w, "Usage: distro-seed [command] [flags]\n\nCommands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-18s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w, "\nRun distro-seed help <command> or distro-seed <command> -h for a command's flags.")
+}
+
+func helpCommand(fs *flag.FlagSet) func() error {
+	return func() error {
+		if fs.NArg() == 0 {
+			printUsage(os.Stdout)
+			return nil
+		}
+		c, ok := findCommand(fs.Arg(0))
+		if !ok {
+			return fmt.Errorf("❌ Unknown command %q", fs.Arg(0))
+		}
+		cfs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(cfs)
+		cfs.SetOutput(os.Stdout)
+		commandUsage(cfs, c)()
+		return nil
+	}
+}
+
+// commandFlags returns the names of a command's flags, for completions
+func commandFlags(c command) []string {
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	c.setup(fs)
+	var names []string
+	fs.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	return names
+}
+
+var errUsage = errors.New("❌ Wrong arguments, see -h")
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/internal/swarmtest"
+)
+
+func TestCreateCommand(t *testing.T) {
+	dir := t.TempDir()
+	swarmtest.NewMeta(t, dir, "a.iso", 100<<10)
+	output := filepath.Join(t.TempDir(), "out.torrent")
+	args := []string{"create", "-o", output, "-tracker", "udp://a.example:6969, http://b.example/announce", "-comment", "test", dir}
+	if err := runCommand(args); err != nil {
+		t.Fatal(err)
+	}
+	mi, err := metainfo.LoadFromFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalLength() != 100<<10 || info.PieceLength != 256<<10 || len(info.Files) != 1 {
+		t.Errorf("created %d bytes in pieces of %d with %d files", info.TotalLength(), info.PieceLength, len(info.Files))
+	}
+	if mi.Announce != "udp://a.example:6969" || len(mi.AnnounceList) != 2 || mi.Comment != "test" || mi.CreatedBy != "distro-seed" {
+		t.Errorf("created %+v", mi)
+	}
+
+	if err := runCommand([]string{"create", filepath.Join(dir, "missing")}); err == nil {
+		t.Error("created a torrent of a missing path")
+	}
+}
+
+func TestAddCommand(t *testing.T) {
+	var patched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			api.WriteJSON(w, http.StatusOK, map[string][]string{"urls": {"https://example.com/a.torrent"}})
+		case http.MethodPatch:
+			var body struct {
+				URLs []string `json:"urls"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			patched = body.URLs
+			api.WriteJSON(w, http.StatusOK, map[string]any{})
+		}
+	}))
+	defer srv.Close()
+
+	err := runCommand([]string{"add", "-api", srv.URL, "-api-token", "s3cret", "https://example.com/a.torrent", "https://example.com/b.torrent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(patched, []string{"https://example.com/a.torrent", "https://example.com/b.torrent"}) {
+		t.Errorf("patched URLs to %v", patched)
+	}
+	if err := runCommand([]string{"add", "-api", srv.URL, "https://example.com/c.torrent"}); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("adding without the token returned %v", err)
+	}
+}
+
+func TestCompletionScripts(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buf bytes.Buffer
+		if err := writeCompletion(&buf, shell); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{"relocate-datadir", "piece-length", "status-format", "api-token"} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("%s completion is missing %s", shell, want)
+			}
+		}
+	}
+	if err := writeCompletion(os.Stdout, "tcsh"); err == nil {
+		t.Error("wrote a completion for an unknown shell")
+	}
+}
+
+func TestBatchCommand(t *testing.T) {
+	var path string
+	var sel torrentSelection
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&sel)
+		api.WriteJSON(w, http.StatusOK, map[string]any{"torrents": []batchTorrent{{InfoHash: "0123", Name: "a.iso", Changed: true}}})
+	}))
+	defer srv.Close()
+
+	if err := runCommand([]string{"pause", "-api", srv.URL, "-label", "ubuntu", "0123"}); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/torrents/pause" || sel.Label != "ubuntu" || !slices.Equal(sel.InfoHashes, []string{"0123"}) {
+		t.Errorf("sent %s %+v", path, sel)
+	}
+	if err := runCommand([]string{"reannounce", "-api", srv.URL}); err != errUsage {
+		t.Errorf("reannounce without torrents returned %v", err)
+	}
+}
+
+func TestEventsCommand(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Path + "?" + r.URL.RawQuery
+		api.WriteJSON(w, http.StatusOK, map[string]any{"events": []torrentEvent{{Time: time.Now(), Kind: "paused"}}})
+	}))
+	defer srv.Close()
+
+	if err := runCommand([]string{"events", "-api", srv.URL, "-kind", "paused,resumed", "0123"}); err != nil {
+		t.Fatal(err)
+	}
+	if query != "/api/torrents/0123/events?kind=paused%2Cresumed" {
+		t.Errorf("requested %s", query)
+	}
+	if err := runCommand([]string{"events", "-api", srv.URL}); err != errUsage {
+		t.Errorf("events without a torrent returned %v", err)
+	}
+}
+
+func TestConfigCommand(t *testing.T) {
+	if err := runCommand([]string{"config", "lint"}); err != errUsage {
+		t.Errorf("config lint returned %v", err)
+	}
+	if err := runCommand([]string{"config", "check"}); err == nil {
+		t.Error("checked without a config file")
+	}
+	if err := runCommand([]string{"config", "check", filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Error("checked a missing config file")
+	}
+}
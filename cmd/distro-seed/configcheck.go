@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"flag"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// configCommand implements the config subcommand. `config check` validates a config file
+// against the defaults, so mistakes are caught before the daemon is started or reloaded.
+func configCommand(fs *flag.FlagSet) func() error {
+	configFile := fs.String("config", "", "JSON config file to check")
+	return func() error {
+		if fs.Arg(0) != "check" {
+			return errUsage
+		}
+		// Flags may also follow the check
+		fs.Parse(fs.Args()[1:])
+		if fs.NArg() > 0 {
+			*configFile = fs.Arg(0)
+		}
+		if *configFile == "" {
+			return errors.New("❌ No config file given, pass it as an argument, with -config or DISTRO_SEED_CONFIG")
+		}
+		return distroseed.CheckConfigFile(*configFile)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// createCommand writes a torrent file for a file or directory, ready to be seeded from where it is
+func createCommand(fs *flag.FlagSet) func() error {
+	output := fs.String("o", "", "Torrent file to write, the path's name with .torrent if empty")
+	trackers := fs.String("tracker", "", "Comma-separated tracker announce URLs")
+	webseeds := fs.String("webseed", "", "Comma-separated web seed URLs")
+	comment := fs.String("comment", "", "Comment to store in the torrent")
+	pieceLength := fs.Int64("piece-length", 0, "Piece length in KiB, picked from the size if 0")
+	return func() error {
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		path := filepath.Clean(fs.Arg(0))
+		mi, err := distroseed.CreateTorrent(path, *pieceLength<<10, splitList(*trackers), splitList(*webseeds), *comment)
+		if err != nil {
+			return err
+		}
+		if *output == "" {
+			*output = filepath.Base(path) + ".torrent"
+		}
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		if err := mi.Write(f); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		info, _ := mi.UnmarshalInfo()
+		ih := mi.HashInfoBytes()
+		fmt.Printf("✅ Wrote %s: %s in %d pieces of %s\n", *output, formatBytes(info.TotalLength()), info.NumPieces(), formatBytes(info.PieceLength))
+		fmt.Printf("🔑 Infohash %s\n", ih.HexString())
+		fmt.Printf("🧲 %s\n", mi.Magnet(&ih, &info).String())
+		return nil
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+)
+
+// envPrefix starts the environment variable every flag can also be set with, named after the
+// flag: -upload-limit is DISTRO_SEED_UPLOAD_LIMIT. The command line takes precedence over it.
+const envPrefix = "DISTRO_SEED_"
+
+// Flags that aren't settings, so a variable that happens to share their name, like an image's
+// version, doesn't change what the command does
+var envIgnoredFlags = []string{"version"}
+
+// Variables without the prefix that the first releases documented, still read for their flags
+// when the prefixed one isn't set. Other unprefixed names, like UMASK or QUIET, are too common
+// in containers' environments to be taken as settings.
+var legacyEnvNames = map[string]string{
+	"dir": "DOWNLOAD_DIR",
+	"url": "TORRENT_URLS",
+}
+
+// flagEnvName returns the environment variable for a flag
+func flagEnvName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyFlagEnv sets the flags of fs that have a DISTRO_SEED_ variable, or a deprecated
+// unprefixed one, in the environment
+func applyFlagEnv(fs *flag.FlagSet) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || slices.Contains(envIgnoredFlags, f.Name) {
+			return
+		}
+		name := flagEnvName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if legacy := legacyEnvNames[f.Name]; !ok && legacy != "" {
+			if value, ok = os.LookupEnv(legacy); ok {
+				name = legacy
+				log.Printf("⚠️ %s is deprecated, set %s instead", legacy, flagEnvName(f.Name))
+			}
+		}
+		if ok {
+			if setErr := fs.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("❌ Invalid value for %s: %w", name, setErr)
+			}
+		}
+	})
+	return err
+}
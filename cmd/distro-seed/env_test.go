@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"slices"
+	"testing"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+func TestApplyFlagEnv(t *testing.T) {
+	t.Setenv("UMASK", "077") // Too common a name to be read without the prefix
+	t.Setenv("TORRENT_URLS", "a.torrent")
+	t.Setenv("DISTRO_SEED_UPLOAD_LIMIT", "300")
+	t.Setenv("DISTRO_SEED_API_TOKEN", "secret")
+	t.Setenv("DISTRO_SEED_VERSION", "1.2.3")
+	t.Setenv("DISTRO_SEED_DIR", "/data")
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	var cfg distroseed.Config
+	cfg.RegisterFlags(fs)
+	version := fs.Bool("version", false, "")
+	if err := applyFlagEnv(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-dir", "/downloads"}); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.UploadLimit != 300 || cfg.APIToken != "secret" || *version || cfg.DownloadDir != "/downloads" {
+		t.Errorf("upload limit %d, token %q, version %v, dir %q", cfg.UploadLimit, cfg.APIToken, *version, cfg.DownloadDir)
+	}
+	if cfg.Umask != "" || !slices.Equal(cfg.TorrentURLs, []string{"a.torrent"}) {
+		t.Errorf("umask %q, urls %q", cfg.Umask, cfg.TorrentURLs)
+	}
+
+	t.Setenv("DISTRO_SEED_STATUS_INTERVAL", "soon")
+	fs = flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	cfg.RegisterFlags(fs)
+	if err := applyFlagEnv(fs); err == nil {
+		t.Error("an invalid duration was accepted")
+	}
+
+	// Deprecated names lose to the prefixed ones
+	t.Setenv("DISTRO_SEED_STATUS_INTERVAL", "1m")
+	t.Setenv("DISTRO_SEED_URL", "b.torrent")
+	fs = flag.NewFlagSet("serve", flag.ContinueOnError)
+	cfg.RegisterFlags(fs)
+	if err := applyFlagEnv(fs); err != nil || !slices.Equal(cfg.TorrentURLs, []string{"b.torrent"}) {
+		t.Errorf("urls %q, %v", cfg.TorrentURLs, err)
+	}
+}
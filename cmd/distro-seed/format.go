@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// splitList splits a comma-separated flag value, nil if it's empty
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	values := strings.Split(value, ",")
+	for i, v := range values {
+		values[i] = strings.TrimSpace(v)
+	}
+	return values
+}
+
+// formatBytes formats a size in the largest binary unit that keeps it at least 1
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for (value >= 1024 || value <= -1024) && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.2f %ciB", value, units[unit])
+}
+
+// formatRate formats a rate in bytes per second like formatBytes
+func formatRate(n int64) string {
+	return formatBytes(n) + "/s"
+}
@@ -0,0 +1,24 @@
+// Command distro-seed seeds torrents of Linux distributions and other large downloads. It's
+// built on the distroseed package, which Go programs can embed instead.
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	// Disable the default timestamp in log package to avoid duplicate dates
+	log.SetFlags(0)
+
+	run := func() error { return runCommand(os.Args[1:]) }
+	if runningAsService() {
+		if err := runService(run); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"flag"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// relocateDataDirCommand implements the relocate-datadir subcommand, which updates the registry
+// after the data directory was moved or remounted at a new path, and spot checks the payloads
+// at the new location.
+func relocateDataDirCommand(fs *flag.FlagSet) func() error {
+	to := fs.String("dir", "./downloads", "Directory the data now lives in")
+	from := fs.String("from", "", "Directory the data used to live in, guessed from the registry if empty")
+	sample := fs.Int("sample", 8, "Number of pieces to verify per torrent")
+	dataDirs := fs.String("data-dirs", "", "Comma-separated extra directories torrents' data is spread over")
+	return func() error {
+		return distroseed.RelocateDataDir(*to, *from, *sample, splitList(*dataDirs))
+	}
+}
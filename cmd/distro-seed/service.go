@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sync"
+)
+
+// Name the seeder is installed under as a Windows service, and logs to the event log as
+const serviceName = "distro-seed"
+
+// serviceStop is closed when the service manager stops the seeder, which then shuts down as it
+// does for SIGTERM
+var (
+	serviceStop     = make(chan struct{})
+	serviceStopOnce sync.Once
+)
+
+func stopService() {
+	serviceStopOnce.Do(func() { close(serviceStop) })
+}
+
+// serviceContext returns a context that's canceled when the service manager stops the seeder
+func serviceContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-serviceStop
+		log.Println("🛑 Stopped by the service manager...")
+		cancel()
+	}()
+	return ctx
+}
+
+// serviceCommand implements the service subcommand, which installs the seeder as a Windows
+// service started with the serve flags that follow, or uninstalls it
+func serviceCommand(fs *flag.FlagSet) func() error {
+	return func() error {
+		switch fs.Arg(0) {
+		case "install":
+			return installService(fs.Args()[1:])
+		case "uninstall":
+			if fs.NArg() != 1 {
+				return errUsage
+			}
+			return uninstallService()
+		}
+		return errUsage
+	}
+}
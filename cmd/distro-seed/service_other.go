@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// Only Windows has a service manager to run under this way, systemd runs the seeder as it is.
+func runningAsService() bool {
+	return false
+}
+
+func runService(run func() error) error {
+	return run()
+}
+
+func installService(args []string) error {
+	return errors.New("❌ Services can only be installed on Windows, see the systemd unit for Linux")
+}
+
+func uninstallService() error {
+	return errors.New("❌ Services can only be uninstalled on Windows")
+}
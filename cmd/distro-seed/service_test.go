@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServiceStopCancelsContext(t *testing.T) {
+	prev := serviceStop
+	serviceStop = make(chan struct{})
+	t.Cleanup(func() { serviceStop = prev })
+
+	ctx := serviceContext()
+	if ctx.Err() != nil {
+		t.Fatal("cancelled before the service manager stopped us")
+	}
+	close(serviceStop)
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the service manager stopping us didn't cancel the context")
+	}
+}
@@ -0,0 +1,146 @@
+//go:build windows
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// How long the service manager is told stopping may take, for stats to be flushed
+const serviceStopHint = 30 * time.Second
+
+func runningAsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+// runService runs the seeder under the service manager, logging to the event log. Relative
+// paths in its flags are from the directory of the executable, rather than System32.
+func runService(run func() error) error {
+	if elog, err := eventlog.Open(serviceName); err == nil {
+		defer elog.Close()
+		log.SetOutput(eventLogWriter{elog})
+	}
+	if executable, err := os.Executable(); err == nil {
+		os.Chdir(filepath.Dir(executable))
+	}
+	var runErr error
+	if err := svc.Run(serviceName, windowsService{run: run, err: &runErr}); err != nil {
+		return fmt.Errorf("❌ Failed to run as a service: %w", err)
+	}
+	return runErr
+}
+
+// windowsService answers the service manager while the seeder runs, turning stop and shutdown
+// requests into the same shutdown as a console's Ctrl+C or close
+type windowsService struct {
+	run func() error
+	err *error
+}
+
+func (s windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	done := make(chan error, 1)
+	go func() { done <- s.run() }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			status <- svc.Status{State: svc.StopPending}
+			if err != nil {
+				*s.err = err
+				return false, 1
+			}
+			return false, 0
+		case r := <-requests:
+			switch r.Cmd {
+			case svc.Interrogate:
+				status <- r.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending, WaitHint: uint32(serviceStopHint / time.Millisecond)}
+				stopService()
+			}
+		}
+	}
+}
+
+// eventLogWriter sends log lines to the event log, as warnings or errors by their prefix
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSpace(string(p))
+	var err error
+	switch {
+	case strings.HasPrefix(msg, "❌"):
+		err = w.elog.Error(1, msg)
+	case strings.HasPrefix(msg, "⚠️"), strings.HasPrefix(msg, "Warning:"):
+		err = w.elog.Warning(1, msg)
+	default:
+		err = w.elog.Info(1, msg)
+	}
+	return len(p), err
+}
+
+// installService registers the seeder as an automatically started service, run with args as
+// its serve flags, and as an event log source
+func installService(args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to locate executable: %w", err)
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to connect to the service manager, run as administrator: %w", err)
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(serviceName); err == nil {
+		s.Close()
+		return fmt.Errorf("❌ The %s service is installed already", serviceName)
+	}
+	s, err := m.CreateService(serviceName, executable, mgr.Config{
+		DisplayName: "Distro Seed",
+		Description: "Seeds Linux distribution images over BitTorrent",
+		StartType:   mgr.StartAutomatic,
+	}, append([]string{"serve"}, args...)...)
+	if err != nil {
+		return fmt.Errorf("❌ Failed to install the service: %w", err)
+	}
+	defer s.Close()
+	// Restart after crashes, like systemd's Restart=on-failure
+	s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 10 * time.Second}}, uint32((24 * time.Hour).Seconds()))
+	if err := eventlog.InstallAsEventCreate(serviceName, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil && !strings.Contains(err.Error(), "exists") {
+		log.Printf("⚠️ Could not register with the event log: %v", err)
+	}
+	log.Printf("✅ Installed the %s service, start it with: sc start %s", serviceName, serviceName)
+	return nil
+}
+
+func uninstallService() error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("❌ Failed to connect to the service manager, run as administrator: %w", err)
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(serviceName)
+	if err != nil {
+		return fmt.Errorf("❌ The %s service isn't installed", serviceName)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("❌ Failed to uninstall the service: %w", err)
+	}
+	eventlog.Remove(serviceName)
+	log.Printf("✅ Uninstalled the %s service", serviceName)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// completionCommand prints a completion script for a shell, generated from the commands and
+// their flags so it can't fall out of date
+func completionCommand(fs *flag.FlagSet) func() error {
+	return func() error {
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		return writeCompletion(os.Stdout, fs.Arg(0))
+	}
+}
+
+func writeCompletion(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w)
+	case "zsh":
+		writeZshCompletion(w)
+	case "fish":
+		writeFishCompletion(w)
+	default:
+		return fmt.Errorf("❌ Unknown shell %q, use bash, zsh or fish", shell)
+	}
+	return nil
+}
+
+func commandNames() []string {
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+	return names
+}
+
+// dashed prefixes each flag name with a dash, the way the flag package reads them
+func dashed(names []string) string {
+	flags := make([]string, len(names))
+	for i, name := range names {
+		flags[i] = "-" + name
+	}
+	return strings.Join(flags, " ")
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for distro-seed
+_distro_seed() {
+    local cur="${COMP_WORDS[COMP_CWORD]}" cmd="" i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        if [[ "${COMP_WORDS[i]}" != -* ]]; then
+            cmd="${COMP_WORDS[i]}"
+            break
+        fi
+    done
+    if [[ -z "$cmd" && "$cur" != -* ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    local flags=""
+    case "${cmd:-serve}" in
+`, strings.Join(commandNames(), " "))
+	for _, c := range commands {
+		fmt.Fprintf(w, "        %s) flags=\"%s\" ;;\n", c.name, dashed(commandFlags(c)))
+	}
+	fmt.Fprint(w, `    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+    else
+        COMPREPLY=($(compgen -f -- "$cur"))
+    fi
+}
+complete -o filenames -F _distro_seed distro-seed
+`)
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprint(w, `#compdef distro-seed
+_distro_seed() {
+    local cmd="" word
+    for word in ${words[2,CURRENT-1]}; do
+        if [[ "$word" != -* ]]; then
+            cmd="$word"
+            break
+        fi
+    done
+    if [[ -z "$cmd" && "$PREFIX" != -* ]]; then
+        local -a cmds
+        cmds=(
+`)
+	for _, c := range commands {
+		fmt.Fprintf(w, "            %s\n", zshQuote(c.name+":"+c.summary))
+	}
+	fmt.Fprint(w, `        )
+        _describe command cmds
+        return
+    fi
+    local -a flags
+    case "${cmd:-serve}" in
+`)
+	for _, c := range commands {
+		fmt.Fprintf(w, "        %s) flags=(%s) ;;\n", c.name, dashed(commandFlags(c)))
+	}
+	fmt.Fprint(w, `    esac
+    if [[ "$PREFIX" == -* ]]; then
+        compadd -a flags
+    else
+        _files
+    fi
+}
+compdef _distro_seed distro-seed
+`)
+}
+
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	names := strings.Join(commandNames(), " ")
+	fmt.Fprintf(w, "# fish completion for distro-seed\ncomplete -c distro-seed -n 'not __fish_seen_subcommand_from %s' -f\n", names)
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c distro-seed -n 'not __fish_seen_subcommand_from %s' -a %s -d %s\n", names, c.name, zshQuote(c.summary))
+	}
+	for _, c := range commands {
+		condition := "__fish_seen_subcommand_from " + c.name
+		if c.name == "serve" {
+			// Flags given without a command are the seeder's
+			condition = "not __fish_seen_subcommand_from " + names
+		}
+		fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+		c.setup(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			fmt.Fprintf(w, "complete -c distro-seed -n '%s' -o %s -d %s\n", condition, f.Name, zshQuote(firstLine(f.Usage)))
+		})
+	}
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	distroseed "github.com/pawl/distro-seed"
+)
+
+// simulateCommand replays a trace recorded with -record-trace against the upload strategies
+func simulateCommand(fs *flag.FlagSet) func() error {
+	strategy := fs.String("strategy", "", "Upload strategy to replay the trace against, all of them if empty")
+	maxUnchoked := fs.Int("max-unchoked", 4, "Peers per torrent to upload to at full speed, 0 for all")
+	uploadRate := fs.Int64("upload-rate", 1024, "Upload rate in KiB/s")
+	optimisticInterval := fs.Duration("optimistic-unchoke-interval", distroseed.DefaultConfig().OptimisticUnchokeInterval, "How often another peer gets a turn")
+	seed := fs.Uint64("seed", 1, "Seed for the random choices, so runs can be repeated")
+	asJSON := fs.Bool("json", false, "Print the results as JSON")
+	return func() error {
+		if fs.NArg() != 1 || *uploadRate <= 0 {
+			return errUsage
+		}
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("❌ Failed to open trace: %w", err)
+		}
+		defer f.Close()
+		results, err := distroseed.SimulateTrace(f, distroseed.SimulationSettings{
+			Strategy:           *strategy,
+			MaxUnchoked:        *maxUnchoked,
+			UploadRate:         *uploadRate * 1024,
+			OptimisticInterval: *optimisticInterval,
+			Seed:               *seed,
+		})
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		}
+		for _, r := range results {
+			fmt.Printf("📊 %s\n", r)
+		}
+		return nil
+	}
+}
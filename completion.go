@@ -0,0 +1,149 @@
+package distroseed
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/tracker"
+)
+
+const completionsFileName = "completions.json"
+
+// completionStore records when each torrent finished downloading, keyed by infohash. It is
+// persisted so the "completed" tracker event is only ever sent once per torrent.
+type completionStore struct {
+	mu    sync.Mutex
+	path  string
+	times map[string]completion
+}
+
+// completion is when a torrent finished downloading, and how long that took from it being added
+type completion struct {
+	At   time.Time `json:"at"`
+	Took duration  `json:"took,omitempty"` // 0 if when it was added isn't known
+}
+
+// UnmarshalJSON also reads the bare times completions were recorded as before they were timed
+func (c *completion) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.At); err == nil {
+		return nil
+	}
+	type plain completion
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+func loadCompletions(downloadDir string) *completionStore {
+	store := &completionStore{
+		path:  filepath.Join(downloadDir, completionsFileName),
+		times: make(map[string]completion),
+	}
+	data, err := os.ReadFile(store.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not read completions file: %v", err)
+		}
+		return store
+	}
+	if err := json.Unmarshal(data, &store.times); err != nil {
+		log.Printf("Warning: Failed to parse completions file: %v", err)
+	}
+	return store
+}
+
+// CompletedAt returns when the torrent finished downloading, if it was downloaded by us.
+func (s *completionStore) CompletedAt(infoHash string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.times[infoHash]
+	return c.At, ok
+}
+
+// TimeToComplete returns how long the torrent took to download from being added, if it was
+// downloaded by us and that's known.
+func (s *completionStore) TimeToComplete(infoHash string) (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.times[infoHash]
+	return time.Duration(c.Took), c.Took > 0
+}
+
+// record stores the completion time and how long it took, 0 if that isn't known, unless one
+// exists, and reports whether it was new.
+func (s *completionStore) record(infoHash string, at time.Time, took time.Duration) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.times[infoHash]; ok {
+		return false
+	}
+	s.times[infoHash] = completion{At: at, Took: duration(took)}
+
+	data, err := json.MarshalIndent(s.times, "", "  ")
+	if err == nil {
+		err = os.WriteFile(s.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error: Failed to write completions file: %v", err)
+	}
+	return true
+}
+
+// Record the torrent's completion and tell its trackers, if it was downloaded in this session.
+// Data that was already on disk only needed verifying, which isn't a completion.
+func (s *Seeder) handleCompletion(client *torrent.Client, t *torrent.Torrent) {
+	stats := t.Stats()
+	if stats.BytesReadUsefulData.Int64() == 0 {
+		return
+	}
+	ih := t.InfoHash().HexString()
+	now := time.Now()
+	var took time.Duration
+	if s.registry != nil {
+		if entry, ok := s.registry.Entry(ih); ok && entry.AddedAt.Before(now) {
+			took = now.Sub(entry.AddedAt).Round(time.Second)
+		}
+	}
+	if !s.completions.record(ih, now, took) {
+		return
+	}
+	if took > 0 {
+		log.Printf("✅ Download complete in %s, now seeding: %s", took, t.Name())
+		s.events.Record(ih, eventCompleted, fmt.Sprintf("%s in %s", formatBytes(t.Length()), took))
+	} else {
+		log.Printf("✅ Download complete, now seeding: %s", t.Name())
+		s.events.Record(ih, eventCompleted, formatBytes(t.Length()))
+	}
+	s.announceCompleted(client, t)
+}
+
+// The library never sends the "completed" event, so send it to every tracker ourselves
+func (s *Seeder) announceCompleted(client *torrent.Client, t *torrent.Torrent) {
+	stats := t.Stats()
+	peerID := client.PeerID()
+	req := tracker.AnnounceRequest{
+		InfoHash:   t.InfoHash(),
+		PeerId:     peerID,
+		Downloaded: stats.BytesReadUsefulData.Int64(),
+		Left:       0,
+		Uploaded:   stats.BytesWrittenData.Int64(),
+		Event:      tracker.Completed,
+		Key:        int32(binary.BigEndian.Uint32(peerID[16:20])), // Same key as the client's own announces
+		NumWant:    -1,
+		Port:       uint16(client.LocalPort()),
+	}
+
+	mi := t.Metainfo()
+	for _, trackerURL := range mi.UpvertedAnnounceList().DistinctValues() {
+		if _, err := (tracker.Announce{TrackerUrl: trackerURL, Request: req, DialContext: s.resolverCache.DialContext}).Do(); err != nil {
+			log.Printf("⚠️ Error announcing completion of %s to %s: %v", t.Name(), trackerURL, err)
+			continue
+		}
+		log.Printf("📣 Announced completion of %s to %s", t.Name(), trackerURL)
+	}
+}
@@ -0,0 +1,62 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompletionRecordedOnce(t *testing.T) {
+	dir := t.TempDir()
+	store := loadCompletions(dir)
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if !store.record("abc", first, 90*time.Minute) {
+		t.Fatal("the first completion wasn't recorded")
+	}
+	if store.record("abc", first.Add(time.Hour), 0) {
+		t.Error("a second completion of the same torrent was recorded")
+	}
+
+	// Across restarts too, so the completed event is never sent again
+	reloaded := loadCompletions(dir)
+	if at, ok := reloaded.CompletedAt("abc"); !ok || !at.Equal(first) {
+		t.Errorf("CompletedAt after reloading = %s, %t, want %s", at, ok, first)
+	}
+	if reloaded.record("abc", first.Add(2*time.Hour), 0) {
+		t.Error("a completion recorded before restarting was recorded again")
+	}
+	if took, ok := reloaded.TimeToComplete("abc"); !ok || took != 90*time.Minute {
+		t.Errorf("TimeToComplete after reloading = %s, %t, want 1h30m", took, ok)
+	}
+	if _, ok := reloaded.CompletedAt("def"); ok {
+		t.Error("a torrent that never completed has a completion time")
+	}
+}
+
+func TestCompletionsFromBeforeTheyWereTimed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, completionsFileName), []byte(`{"abc": "2024-05-01T12:00:00Z"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := loadCompletions(dir)
+	if at, ok := store.CompletedAt("abc"); !ok || !at.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("CompletedAt = %s, %t, want the recorded time", at, ok)
+	}
+	if _, ok := store.TimeToComplete("abc"); ok {
+		t.Error("a completion recorded without how long it took has a time to complete")
+	}
+}
+
+func TestVerifiedDataIsNotACompletion(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	s.completions = loadCompletions(dir)
+	client := newTestClient(t, dir)
+	tt := addSeedingTestTorrent(t, client, dir, "a.iso")
+
+	s.handleCompletion(client, tt)
+	if _, ok := s.completions.CompletedAt(tt.InfoHash().HexString()); ok {
+		t.Error("a torrent whose data was already on disk was recorded as completed")
+	}
+}
@@ -0,0 +1,514 @@
+package distroseed
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runtimeConfig holds the settings that can be changed without restarting, from flags and
+// the optional config file
+type runtimeConfig struct {
+	TorrentURLs      []string          `json:"urls"`
+	UploadLimit      int64             `json:"upload_limit"`   // KiB/s, 0 for unlimited
+	DownloadLimit    int64             `json:"download_limit"` // KiB/s, 0 for unlimited
+	StatusInterval   duration          `json:"status_interval"`
+	AnnounceInterval duration          `json:"announce_interval"`
+	TorrentDirs      map[string]string `json:"torrent_dirs,omitempty"` // URL to the directory its data goes in
+
+	TorrentOptions map[string]torrentOptions `json:"torrent_options,omitempty"` // URL to the options for its torrents
+
+	MaxUnchoked               int      `json:"max_unchoked"` // Peers per torrent uploaded to at full speed, 0 for all
+	OptimisticUnchokeInterval duration `json:"optimistic_unchoke_interval"`
+	PeerUploadLimit           int64    `json:"peer_upload_limit"` // KiB/s each peer may be uploaded to, 0 for unlimited
+	UploadStrategy            string   `json:"upload_strategy"`   // How torrents pick the peers they upload to, unless their options say otherwise
+
+	ConnsPerTorrent    int            `json:"conns_per_torrent"`       // Established connections per torrent before scaling
+	MaxConnsPerTorrent int            `json:"max_conns_per_torrent"`   // Upper bound for scaled and reclaimed slots
+	TorrentConns       map[string]int `json:"torrent_conns,omitempty"` // URL to a fixed connection limit for its torrents
+	MaxConns           int            `json:"max_conns"`               // Peer connections across all torrents, 0 for no limit
+}
+
+// redacted returns the config with the secrets in torrent options masked, for the API
+func (c runtimeConfig) redacted() runtimeConfig {
+	c.TorrentOptions = maps.Clone(c.TorrentOptions)
+	for url, opts := range c.TorrentOptions {
+		c.TorrentOptions[url] = opts.redacted()
+	}
+	return c
+}
+
+// Sane bounds for the intervals, outside which logs flood or trackers treat us as gone
+const (
+	minStatusInterval    = 5 * time.Second
+	maxStatusInterval    = 24 * time.Hour
+	minAnnounceInterval  = 1 * time.Minute
+	maxAnnounceInterval  = 24 * time.Hour
+	minOptimisticUnchoke = chokeRoundInterval
+	maxOptimisticUnchoke = 1 * time.Hour
+)
+
+// More connections per torrent than any swarm needs, and than most file descriptor limits allow
+const maxConnLimit = 5000
+
+func (c runtimeConfig) validate() error {
+	if c.UploadLimit < 0 || c.DownloadLimit < 0 || c.PeerUploadLimit < 0 {
+		return fmt.Errorf("❌ Rate limits can't be negative")
+	}
+	if d := time.Duration(c.StatusInterval); d < minStatusInterval || d > maxStatusInterval {
+		return fmt.Errorf("❌ Status interval %s must be between %s and %s", d, minStatusInterval, maxStatusInterval)
+	}
+	if d := time.Duration(c.AnnounceInterval); d < minAnnounceInterval || d > maxAnnounceInterval {
+		return fmt.Errorf("❌ Announce interval %s must be between %s and %s", d, minAnnounceInterval, maxAnnounceInterval)
+	}
+	if c.MaxUnchoked < 0 {
+		return fmt.Errorf("❌ Max unchoked peers can't be negative")
+	}
+	if err := validateStrategy(c.UploadStrategy); err != nil {
+		return err
+	}
+	if d := time.Duration(c.OptimisticUnchokeInterval); d < minOptimisticUnchoke || d > maxOptimisticUnchoke {
+		return fmt.Errorf("❌ Optimistic unchoke interval %s must be between %s and %s", d, minOptimisticUnchoke, maxOptimisticUnchoke)
+	}
+	if c.ConnsPerTorrent < 1 || c.MaxConnsPerTorrent > maxConnLimit {
+		return fmt.Errorf("❌ Connections per torrent must be between 1 and %d", maxConnLimit)
+	}
+	if c.MaxConnsPerTorrent < c.ConnsPerTorrent {
+		return fmt.Errorf("❌ Max connections per torrent %d is below connections per torrent %d", c.MaxConnsPerTorrent, c.ConnsPerTorrent)
+	}
+	if c.MaxConns < 0 {
+		return fmt.Errorf("❌ Max connections can't be negative")
+	}
+	for url, limit := range c.TorrentConns {
+		if limit < 1 || limit > maxConnLimit {
+			return fmt.Errorf("❌ Connection limit %d for torrent %s must be between 1 and %d", limit, url, maxConnLimit)
+		}
+	}
+	for url, dir := range c.TorrentDirs {
+		if dir == "" {
+			return fmt.Errorf("❌ Empty directory for torrent %s", url)
+		}
+	}
+	for url, opts := range c.TorrentOptions {
+		if err := opts.validate(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// duration is a time.Duration written as a string like "30s" in config files
+type duration time.Duration
+
+func (d duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(parsed)
+	return nil
+}
+
+// withConfigFile returns base overlaid with the settings present in the config file at path.
+// Torrents from the file are added to those given on the command line.
+func (base runtimeConfig) withConfigFile(path string) (runtimeConfig, error) {
+	if path == "" {
+		return base, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return base, fmt.Errorf("❌ Failed to read config file: %w", err)
+	}
+
+	// Start from the base so settings missing from the file keep their values
+	merged := base
+	merged.TorrentURLs = nil
+	merged.TorrentDirs = maps.Clone(base.TorrentDirs)
+	merged.TorrentConns = maps.Clone(base.TorrentConns)
+	merged.TorrentOptions = maps.Clone(base.TorrentOptions)
+	if err := decodeConfigStrict(data, &merged); err != nil {
+		return base, fmt.Errorf("❌ Failed to parse config file '%s': %w", path, err)
+	}
+
+	urls := slices.Clone(base.TorrentURLs)
+	for _, url := range merged.TorrentURLs {
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	merged.TorrentURLs = urls
+	if err := merged.validate(); err != nil {
+		return base, fmt.Errorf("%w in '%s'", err, path)
+	}
+	return merged, nil
+}
+
+// decodeConfigStrict decodes a JSON config object into cfg one setting at a time, so errors can
+// say which setting is wrong and where it is in the file. Unknown settings are errors, since
+// they're most likely typos.
+func decodeConfigStrict(data []byte, cfg *runtimeConfig) error {
+	fields := configFields(cfg)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return positionError(data, dec.InputOffset(), err)
+	} else if tok != json.Delim('{') {
+		return fmt.Errorf("line 1: the config must be a JSON object")
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return positionError(data, dec.InputOffset(), err)
+		}
+		// Object keys are always strings, and errors point at where the quoted key starts
+		key := tok.(string)
+		keyOffset := dec.InputOffset() - int64(len(key)) - 2
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return positionError(data, dec.InputOffset(), err)
+		}
+		field, ok := fields[key]
+		if !ok {
+			err := fmt.Errorf("unknown setting %q", key)
+			if suggestion := closestName(key, slices.Collect(maps.Keys(fields))); suggestion != "" {
+				err = fmt.Errorf("%w, did you mean %q?", err, suggestion)
+			}
+			return positionError(data, keyOffset, err)
+		}
+		if err := json.Unmarshal(raw, field); err != nil {
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &typeErr) {
+				err = fmt.Errorf("expected %s, got %s", typeName(typeErr.Type), typeErr.Value)
+			}
+			return positionError(data, keyOffset, fmt.Errorf("%q: %w", key, err))
+		}
+		if err := checkNestedKeys(data, dec.InputOffset()-int64(len(raw)), raw, reflect.TypeOf(field).Elem(), key); err != nil {
+			return err
+		}
+	}
+	if _, err := dec.Token(); err != nil {
+		return positionError(data, dec.InputOffset(), err)
+	}
+	if dec.More() {
+		return positionError(data, dec.InputOffset(), errors.New("unexpected data after the config object"))
+	}
+	return nil
+}
+
+// checkNestedKeys reports keys of the objects in a setting's value, which starts at offset in
+// data, that don't match anything they're decoded into. json.Unmarshal would silently drop them,
+// so a typo in per-torrent options would leave them unset.
+func checkNestedKeys(data []byte, offset int64, raw json.RawMessage, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(reflect.TypeFor[json.Unmarshaler]()) {
+		return nil // Parses itself
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil // Already decoded without errors
+	}
+	var fields map[string]reflect.Type
+	switch {
+	case tok == json.Delim('{') && t.Kind() == reflect.Struct:
+		fields = jsonFields(t)
+	case tok == json.Delim('{') && t.Kind() == reflect.Map, tok == json.Delim('[') && t.Kind() == reflect.Slice:
+	default:
+		return nil
+	}
+	for i := 0; dec.More(); i++ {
+		name := fmt.Sprintf("%s[%d]", path, i)
+		var elem reflect.Type
+		if fields == nil {
+			elem = t.Elem()
+		}
+		if tok == json.Delim('{') {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil
+			}
+			key := tok.(string)
+			keyOffset := offset + dec.InputOffset() - int64(len(key)) - 2
+			name = path + "[" + key + "]"
+			if fields != nil {
+				name = path + "." + key
+				var ok bool
+				if elem, ok = fieldType(fields, key); !ok {
+					err := fmt.Errorf("unknown setting %q", name)
+					if suggestion := closestName(key, slices.Collect(maps.Keys(fields))); suggestion != "" {
+						err = fmt.Errorf("%w, did you mean %q?", err, suggestion)
+					}
+					return positionError(data, keyOffset, err)
+				}
+			}
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil
+		}
+		if err := checkNestedKeys(data, offset+dec.InputOffset()-int64(len(value)), value, elem, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonFields maps the JSON names of a struct's fields, including those of embedded structs, to
+// their types
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for _, f := range reflect.VisibleFields(t) {
+		tag := f.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" || (f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		fields[cmp.Or(name, f.Name)] = f.Type
+	}
+	return fields
+}
+
+// fieldType looks a key up in fields the way encoding/json does, ignoring case if there's no
+// exact match
+func fieldType(fields map[string]reflect.Type, key string) (reflect.Type, bool) {
+	if t, ok := fields[key]; ok {
+		return t, true
+	}
+	for name, t := range fields {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// configFields maps the JSON names of the config's settings to pointers to them
+func configFields(cfg *runtimeConfig) map[string]any {
+	fields := make(map[string]any)
+	v := reflect.ValueOf(cfg).Elem()
+	for i := range v.NumField() {
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			fields[name] = v.Field(i).Addr().Interface()
+		}
+	}
+	return fields
+}
+
+// positionError adds the line and column of the byte offset in data to err
+func positionError(data []byte, offset int64, err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset - 1 // The offset is just past the offending byte
+	}
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - (bytes.LastIndexByte(before, '\n') + 1) + 1
+	return fmt.Errorf("line %d, column %d: %w", line, column, err)
+}
+
+func typeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int64:
+		return "a whole number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map:
+		return "an object"
+	default:
+		return t.String()
+	}
+}
+
+// closestName returns the name within a few edits of name, for suggesting what a typo meant
+func closestName(name string, names []string) string {
+	slices.Sort(names)
+	best, bestDistance := "", 4
+	for _, n := range names {
+		if d := editDistance(name, n); d < bestDistance {
+			best, bestDistance = n, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// settings holds the current runtimeConfig and notifies goroutines when it changes
+type settings struct {
+	mu      sync.Mutex
+	current runtimeConfig
+	changed chan struct{}
+}
+
+func (s *settings) Get() runtimeConfig {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Changed returns a channel that is closed the next time the settings change
+func (s *settings) Changed() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.changed
+}
+
+func (s *settings) set(cfg runtimeConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current = cfg
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// configDiff is what changes between two configurations
+type configDiff struct {
+	AddedTorrents   []string        `json:"added_torrents"`
+	RemovedTorrents []string        `json:"removed_torrents"`
+	Changed         []settingChange `json:"changed"`
+}
+
+type settingChange struct {
+	Setting string `json:"setting"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+func diffConfig(prev, next runtimeConfig) configDiff {
+	diff := configDiff{AddedTorrents: []string{}, RemovedTorrents: []string{}, Changed: []settingChange{}}
+	for _, url := range next.TorrentURLs {
+		if !slices.Contains(prev.TorrentURLs, url) {
+			diff.AddedTorrents = append(diff.AddedTorrents, url)
+		}
+	}
+	for _, url := range prev.TorrentURLs {
+		if !slices.Contains(next.TorrentURLs, url) {
+			diff.RemovedTorrents = append(diff.RemovedTorrents, url)
+		}
+	}
+
+	changed := func(setting, from, to string) {
+		if from != to {
+			diff.Changed = append(diff.Changed, settingChange{Setting: setting, From: from, To: to})
+		}
+	}
+	changed("upload_limit", formatRateLimit(prev.UploadLimit), formatRateLimit(next.UploadLimit))
+	changed("download_limit", formatRateLimit(prev.DownloadLimit), formatRateLimit(next.DownloadLimit))
+	changed("status_interval", time.Duration(prev.StatusInterval).String(), time.Duration(next.StatusInterval).String())
+	changed("announce_interval", time.Duration(prev.AnnounceInterval).String(), time.Duration(next.AnnounceInterval).String())
+	changed("max_unchoked", formatUnchoked(prev.MaxUnchoked), formatUnchoked(next.MaxUnchoked))
+	changed("optimistic_unchoke_interval", time.Duration(prev.OptimisticUnchokeInterval).String(), time.Duration(next.OptimisticUnchokeInterval).String())
+	changed("peer_upload_limit", formatRateLimit(prev.PeerUploadLimit), formatRateLimit(next.PeerUploadLimit))
+	changed("upload_strategy", cmp.Or(prev.UploadStrategy, strategyDefault), cmp.Or(next.UploadStrategy, strategyDefault))
+	changed("conns_per_torrent", strconv.Itoa(prev.ConnsPerTorrent), strconv.Itoa(next.ConnsPerTorrent))
+	changed("max_conns_per_torrent", strconv.Itoa(prev.MaxConnsPerTorrent), strconv.Itoa(next.MaxConnsPerTorrent))
+	changed("max_conns", formatMaxConns(prev.MaxConns), formatMaxConns(next.MaxConns))
+	for _, url := range unionKeys(prev.TorrentDirs, next.TorrentDirs) {
+		changed("torrent_dirs["+url+"]", cmp.Or(prev.TorrentDirs[url], "auto"), cmp.Or(next.TorrentDirs[url], "auto"))
+	}
+	for _, url := range unionKeys(prev.TorrentConns, next.TorrentConns) {
+		changed("torrent_conns["+url+"]", formatConnLimit(prev.TorrentConns[url]), formatConnLimit(next.TorrentConns[url]))
+	}
+	for _, url := range unionKeys(prev.TorrentOptions, next.TorrentOptions) {
+		changed("torrent_options["+url+"]", prev.TorrentOptions[url].String(), next.TorrentOptions[url].String())
+	}
+	return diff
+}
+
+// unionKeys returns the keys in either map, sorted
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := slices.Collect(maps.Keys(a))
+	for k := range b {
+		if !slices.Contains(keys, k) {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatMaxConns(n int) string {
+	if n == 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(n)
+}
+
+func formatConnLimit(n int) string {
+	if n == 0 {
+		return "auto"
+	}
+	return strconv.Itoa(n)
+}
+
+func formatUnchoked(n int) string {
+	if n == 0 {
+		return "all"
+	}
+	return strconv.Itoa(n)
+}
+
+func (d configDiff) empty() bool {
+	return len(d.AddedTorrents) == 0 && len(d.RemovedTorrents) == 0 && len(d.Changed) == 0
+}
+
+func (d configDiff) log() {
+	if d.empty() {
+		log.Println("🔀 No configuration changes")
+		return
+	}
+	log.Printf("🔀 Configuration changes: %d torrents added, %d removed, %d settings changed",
+		len(d.AddedTorrents), len(d.RemovedTorrents), len(d.Changed))
+	for _, url := range d.AddedTorrents {
+		log.Printf("   + %s", url)
+	}
+	for _, url := range d.RemovedTorrents {
+		log.Printf("   - %s", url)
+	}
+	for _, c := range d.Changed {
+		log.Printf("   ~ %s: %s → %s", c.Setting, c.From, c.To)
+	}
+}
@@ -0,0 +1,115 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"urls": ["b", "c"], "upload_limit": 512, "status_interval": "5m"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	base := testConfig()
+	base.TorrentURLs, base.DownloadLimit = []string{"a", "b"}, 100
+
+	got, err := base.withConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Torrents from the file are added to the flag's, and settings the file lacks are kept
+	want := base
+	want.TorrentURLs, want.UploadLimit, want.StatusInterval = []string{"a", "b", "c"}, 512, duration(5*time.Minute)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("withConfigFile = %+v, want %+v", got, want)
+	}
+	if !reflect.DeepEqual(base.TorrentURLs, []string{"a", "b"}) {
+		t.Errorf("withConfigFile changed the base's torrents to %v", base.TorrentURLs)
+	}
+
+	for _, invalid := range []string{`{"status_interval": "often"}`, `{"status_interval": "1s"}`, `{"upload_limit": -1}`, `{"peer_upload_limit": -1}`, `{"max_unchoked": -1}`, `{"optimistic_unchoke_interval": "1s"}`,
+		`{"conns_per_torrent": 0}`, `{"max_conns_per_torrent": 50}`, `{"torrent_conns": {"a": 0}}`, `{"max_conns": -1}`} {
+		if err := os.WriteFile(path, []byte(invalid), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := base.withConfigFile(path); err == nil || !reflect.DeepEqual(got, base) {
+			t.Errorf("withConfigFile of %s = %+v, %v, want the base and an error", invalid, got, err)
+		}
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+	prev := testConfig()
+	prev.TorrentURLs, prev.UploadLimit = []string{"a", "b"}, 100
+	next := testConfig()
+	next.TorrentURLs, next.AnnounceInterval, next.MaxUnchoked = []string{"b", "c"}, duration(2*time.Hour), 4
+	next.ConnsPerTorrent, next.TorrentConns = 50, map[string]int{"c": 20}
+	want := configDiff{
+		AddedTorrents:   []string{"c"},
+		RemovedTorrents: []string{"a"},
+		Changed: []settingChange{
+			{Setting: "upload_limit", From: "100 KiB/s", To: "unlimited"},
+			{Setting: "announce_interval", From: "1h0m0s", To: "2h0m0s"},
+			{Setting: "max_unchoked", From: "all", To: "4"},
+			{Setting: "conns_per_torrent", From: "100", To: "50"},
+			{Setting: "torrent_conns[c]", From: "auto", To: "20"},
+		},
+	}
+	if got := diffConfig(prev, next); !reflect.DeepEqual(got, want) {
+		t.Errorf("diffConfig = %+v, want %+v", got, want)
+	}
+	if diff := diffConfig(next, next); !diff.empty() {
+		t.Errorf("diffConfig of the same config = %+v, want it empty", diff)
+	}
+}
+
+func TestConfigFileErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	for _, tt := range []struct {
+		config string
+		want   string
+	}{
+		{"{\n  \"urls\": [\"a\"],\n  \"uplod_limit\": 512\n}", `line 3, column 3: unknown setting "uplod_limit", did you mean "upload_limit"?`},
+		{"{\n  \"upload_limit\": \"512\"\n}", `line 2, column 3: "upload_limit": expected a whole number, got string`},
+		{"{\n  \"urls\": [\"a\",]\n}", `line 2, column 16: invalid character ']'`},
+		{`{"status_interval": "often"}`, `line 1, column 2: "status_interval": time: invalid duration "often"`},
+		{`{"frobnicate": true}`, `line 1, column 2: unknown setting "frobnicate"`},
+		{`[]`, `the config must be a JSON object`},
+		{"{\n  \"torrent_options\": {\"http://x/a.torrent\": {\n    \"lables\": [\"a\"]}}\n}", `line 3, column 5: unknown setting "torrent_options[http://x/a.torrent].lables", did you mean "labels"?`},
+		{`{"torrent_options": {"http://x/a.torrent": {"labels": ["a"], "pasword": "x"}}}`, `unknown setting "torrent_options[http://x/a.torrent].pasword", did you mean "password"?`},
+	} {
+		if err := os.WriteFile(path, []byte(tt.config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := testConfig().withConfigFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("withConfigFile of %q = %v, want an error containing %q", tt.config, err, tt.want)
+		}
+		if err != nil && strings.Contains(tt.want, "frobnicate") && strings.Contains(err.Error(), "did you mean") {
+			t.Errorf("suggested a setting for an unrelated name: %v", err)
+		}
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	config := `{"urls": ["` + dir + `", "` + filepath.Join(dir, "missing.torrent") + `", "magnet:?xt=urn:btih:0000000000000000000000000000000000000000"],
+		"torrent_dirs": {"a": "` + filepath.Join(dir, "new") + `", "b": "` + filepath.Join(dir, "no", "such") + `"}}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckConfigFile(path); err == nil || !strings.Contains(err.Error(), "2 paths") {
+		t.Errorf("config check = %v, want 2 missing paths", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"urls": ["`+dir+`"], "upload_limit": 100}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckConfigFile(path); err != nil {
+		t.Errorf("config check of a valid config = %v", err)
+	}
+}
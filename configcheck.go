@@ -0,0 +1,64 @@
+package distroseed
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// CheckConfigFile loads a config file on top of the defaults and checks the paths it names, so
+// mistakes are caught before a seeder is started or reloaded with it
+func CheckConfigFile(configFile string) error {
+	cfg, err := defaultConfig().withConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	problems := checkConfigPaths(cfg)
+	for _, p := range problems {
+		log.Printf("⚠️ %s", p)
+	}
+	log.Printf("✅ %s is valid, with %d torrents", configFile, len(cfg.TorrentURLs))
+	if len(problems) > 0 {
+		return fmt.Errorf("❌ %d paths in %s don't exist", len(problems), configFile)
+	}
+	return nil
+}
+
+// defaultConfig returns the settings the flags default to, without the environment
+func defaultConfig() runtimeConfig {
+	return runtimeConfig{
+		StatusInterval:            duration(defaultStatusInterval),
+		AnnounceInterval:          duration(defaultAnnounceInterval),
+		OptimisticUnchokeInterval: duration(defaultOptimisticUnchoke),
+		ConnsPerTorrent:           defaultConnsPerTorrent,
+		MaxConnsPerTorrent:        defaultMaxConnsPerTorrent,
+	}
+}
+
+// checkConfigPaths lists the local torrent files and directories the config names that don't
+// exist. Data directories for torrents are created when needed, so only their parents count.
+func checkConfigPaths(cfg runtimeConfig) []string {
+	var problems []string
+	for _, url := range cfg.TorrentURLs {
+		if _, ok := magnetURL(url); ok || isMetalinkURL(url) {
+			continue
+		}
+		if path, ok := localTorrentPath(url); ok {
+			if _, err := os.Stat(path); err != nil {
+				problems = append(problems, fmt.Sprintf("Torrent file %s: %v", url, err))
+			}
+		}
+	}
+	for url, dir := range cfg.TorrentDirs {
+		if _, err := os.Stat(dir); err == nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Dir(dir)); err != nil {
+			problems = append(problems, fmt.Sprintf("Directory %s for torrent %s can't be created: %v", dir, url, err))
+		}
+	}
+	slices.Sort(problems)
+	return problems
+}
@@ -0,0 +1,89 @@
+package distroseed
+
+import (
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Upper bounds of the connection lifetime histogram buckets, in seconds
+var connLifetimeBuckets = []float64{10, 60, 300, 900, 3600, 4 * 3600, 24 * 3600}
+
+// connTracker follows peer connections through their lifetime, per torrent, to spot routers and
+// ISPs that silently kill long-lived connections
+type connTracker struct {
+	mu       sync.Mutex
+	open     map[*torrent.PeerConn]openConn
+	torrents map[string]*connStats // Infohash to stats
+}
+
+type openConn struct {
+	infoHash string
+	since    time.Time
+}
+
+type connStats struct {
+	Opened          int64
+	Closed          int64
+	LifetimeSum     float64 // Seconds, over closed connections
+	LifetimeBuckets []int64 // Cumulative counts per connLifetimeBuckets bound
+}
+
+// Register the tracker's callbacks with the client config
+func (c *connTracker) install(cfg *torrent.ClientConfig) {
+	cfg.Callbacks.PeerConnAdded = append(cfg.Callbacks.PeerConnAdded, c.added)
+	cfg.Callbacks.PeerConnClosed = c.closed
+}
+
+// Called with the client lock held, so mustn't call back into the client
+func (c *connTracker) added(pc *torrent.PeerConn) {
+	ih := pc.Torrent().InfoHash().HexString()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.open[pc] = openConn{infoHash: ih, since: time.Now()}
+	c.stats(ih).Opened++
+}
+
+func (c *connTracker) closed(pc *torrent.PeerConn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	conn, ok := c.open[pc]
+	if !ok {
+		return // Closed before it was added to a torrent
+	}
+	delete(c.open, pc)
+
+	stats := c.stats(conn.infoHash)
+	lifetime := time.Since(conn.since).Seconds()
+	stats.Closed++
+	stats.LifetimeSum += lifetime
+	for i, bound := range connLifetimeBuckets {
+		if lifetime <= bound {
+			stats.LifetimeBuckets[i]++
+		}
+	}
+}
+
+// stats returns the torrent's stats, the caller must hold c.mu
+func (c *connTracker) stats(infoHash string) *connStats {
+	stats, ok := c.torrents[infoHash]
+	if !ok {
+		stats = &connStats{LifetimeBuckets: make([]int64, len(connLifetimeBuckets))}
+		c.torrents[infoHash] = stats
+	}
+	return stats
+}
+
+// Snapshot returns a copy of each torrent's stats
+func (c *connTracker) Snapshot() map[string]connStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := make(map[string]connStats, len(c.torrents))
+	for ih, stats := range c.torrents {
+		s := *stats
+		s.LifetimeBuckets = append([]int64(nil), stats.LifetimeBuckets...)
+		snapshot[ih] = s
+	}
+	return snapshot
+}
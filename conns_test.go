@@ -0,0 +1,53 @@
+package distroseed
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+func TestConnTrackerLifetimes(t *testing.T) {
+	c := &connTracker{open: make(map[*torrent.PeerConn]openConn), torrents: make(map[string]*connStats)}
+	short, long := &torrent.PeerConn{}, &torrent.PeerConn{}
+	c.open[short] = openConn{infoHash: "aa", since: time.Now().Add(-30 * time.Second)}
+	c.open[long] = openConn{infoHash: "aa", since: time.Now().Add(-2 * time.Hour)}
+	c.stats("aa").Opened = 2
+
+	c.closed(short)
+	c.closed(long)
+	c.closed(&torrent.PeerConn{}) // Never added
+	stats := c.Snapshot()["aa"]
+	if stats.Opened != 2 || stats.Closed != 2 {
+		t.Errorf("opened %d and closed %d, want 2 and 2", stats.Opened, stats.Closed)
+	}
+	// Buckets are cumulative: 10s, 1m, 5m, 15m, 1h, 4h, 24h
+	want := []int64{0, 1, 1, 1, 1, 2, 2}
+	for i := range want {
+		if stats.LifetimeBuckets[i] != want[i] {
+			t.Fatalf("lifetime buckets = %v, want %v", stats.LifetimeBuckets, want)
+		}
+	}
+	if len(c.open) != 0 {
+		t.Errorf("%d connections still open", len(c.open))
+	}
+}
+
+func TestWriteConnMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	snapshot := map[string]connStats{"aa": {Opened: 3, Closed: 1, LifetimeSum: 42.5, LifetimeBuckets: []int64{0, 1, 1, 1, 1, 1, 1}}}
+	writeConnMetrics(metricsWriter{&buf}, snapshot, map[string]string{"aa": `debian "12".iso`})
+	for _, want := range []string{
+		"# TYPE distro_seed_peer_connections_opened_total counter\n",
+		`distro_seed_peer_connections_opened_total{infohash="aa",name="debian \"12\".iso"} 3` + "\n",
+		`distro_seed_peer_connection_lifetime_seconds_bucket{infohash="aa",name="debian \"12\".iso",le="60"} 1` + "\n",
+		`distro_seed_peer_connection_lifetime_seconds_bucket{infohash="aa",name="debian \"12\".iso",le="+Inf"} 1` + "\n",
+		`distro_seed_peer_connection_lifetime_seconds_sum{infohash="aa",name="debian \"12\".iso"} 42.5` + "\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("metrics don't contain %q:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package distroseed
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// Piece lengths chosen by CreateTorrent aim for at most this many pieces, within the bounds
+const (
+	createTargetPieces   = 2000
+	createMinPieceLength = 256 << 10
+	createMaxPieceLength = 16 << 20
+)
+
+// CreateTorrent hashes the file or directory at path into a torrent, ready to be seeded from
+// where it is. A piece length of 0 is picked from the total size.
+func CreateTorrent(path string, pieceLength int64, trackers, webseeds []string, comment string) (*metainfo.MetaInfo, error) {
+	size, err := pathSize(path)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, errors.New("❌ Nothing to put in the torrent, the path is empty")
+	}
+	if pieceLength == 0 {
+		pieceLength = choosePieceLength(size)
+	}
+	info := metainfo.Info{PieceLength: pieceLength}
+	if err := info.BuildFromFilePath(path); err != nil {
+		return nil, err
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	mi := &metainfo.MetaInfo{
+		InfoBytes:    infoBytes,
+		CreationDate: time.Now().Unix(),
+		CreatedBy:    "distro-seed",
+		Comment:      comment,
+		UrlList:      webseeds,
+	}
+	if len(trackers) > 0 {
+		mi.Announce = trackers[0]
+		for _, tr := range trackers {
+			mi.AnnounceList = append(mi.AnnounceList, []string{tr})
+		}
+	}
+	return mi, nil
+}
+
+// choosePieceLength doubles the piece length from the minimum until the torrent has few enough
+// pieces
+func choosePieceLength(size int64) int64 {
+	length := int64(createMinPieceLength)
+	for length < createMaxPieceLength && size/length > createTargetPieces {
+		length *= 2
+	}
+	return length
+}
+
+// pathSize totals the sizes of the regular files at path
+func pathSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,42 @@
+package distroseed
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCreateTorrent(t *testing.T) {
+	dir := t.TempDir()
+	newTestMeta(t, dir, "a.iso", 100<<10)
+	mi, err := CreateTorrent(dir, 0, []string{"udp://a.example:6969", "http://b.example/announce"}, nil, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TotalLength() != 100<<10 || info.PieceLength != createMinPieceLength || len(info.Files) != 1 {
+		t.Errorf("created %d bytes in pieces of %d with %d files", info.TotalLength(), info.PieceLength, len(info.Files))
+	}
+	if mi.Announce != "udp://a.example:6969" || len(mi.AnnounceList) != 2 || mi.Comment != "test" {
+		t.Errorf("created %+v", mi)
+	}
+
+	if _, err := CreateTorrent(filepath.Join(dir, "missing"), 0, nil, nil, ""); err == nil {
+		t.Error("created a torrent of a missing path")
+	}
+}
+
+func TestChoosePieceLength(t *testing.T) {
+	for size, want := range map[int64]int64{
+		1 << 20:   256 << 10,
+		1 << 30:   1 << 20,
+		4 << 30:   4 << 20,
+		100 << 30: 16 << 20,
+	} {
+		if got := choosePieceLength(size); got != want {
+			t.Errorf("choosePieceLength(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
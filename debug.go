@@ -0,0 +1,102 @@
+package distroseed
+
+import (
+	"cmp"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pawl/distro-seed/api"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	expvar.Publish("uptime_seconds", expvar.Func(func() any { return int64(time.Since(processStarted).Seconds()) }))
+}
+
+// registerDebugHandlers adds live profiling and runtime diagnostics to the management API, behind
+// its token like everything else: the pprof profiles under /debug/pprof/, expvar's runtime
+// variables at /debug/vars and a goroutine dump grouped by stack at /api/debug/goroutines
+func registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("POST /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /api/debug/goroutines", getGoroutines)
+}
+
+// goroutineStack is a stack some goroutines are at, with its frames innermost first
+type goroutineStack struct {
+	Count  int      `json:"count"`
+	Frames []string `json:"frames"`
+}
+
+// Dump the running goroutines grouped by stack, most common first, so a leak shows up as a
+// stack with a count that keeps growing. ?min=N leaves out stacks with fewer goroutines.
+func getGoroutines(w http.ResponseWriter, r *http.Request) {
+	minCount := 1
+	if s := r.URL.Query().Get("min"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			api.WriteError(w, http.StatusBadRequest, fmt.Errorf("min must be a positive number, got '%s'", s))
+			return
+		}
+		minCount = n
+	}
+	total, stacks := goroutineStacks()
+	stacks = slices.DeleteFunc(stacks, func(s goroutineStack) bool { return s.Count < minCount })
+	api.WriteJSON(w, http.StatusOK, struct {
+		Goroutines int              `json:"goroutines"`
+		Stacks     []goroutineStack `json:"stacks"`
+	}{total, stacks})
+}
+
+// goroutineStacks groups the running goroutines by where they are
+func goroutineStacks() (total int, stacks []goroutineStack) {
+	var records []runtime.StackRecord
+	n, _ := runtime.GoroutineProfile(nil)
+	for {
+		// Room for goroutines started in the meantime
+		records = make([]runtime.StackRecord, n+n/10+10)
+		var ok bool
+		if n, ok = runtime.GoroutineProfile(records); ok {
+			records = records[:n]
+			break
+		}
+	}
+
+	byStack := make(map[string]*goroutineStack)
+	for _, record := range records {
+		var frames []string
+		callers := runtime.CallersFrames(record.Stack())
+		for {
+			frame, more := callers.Next()
+			frames = append(frames, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+			if !more {
+				break
+			}
+		}
+		key := strings.Join(frames, "\n")
+		if s, ok := byStack[key]; ok {
+			s.Count++
+		} else {
+			byStack[key] = &goroutineStack{Count: 1, Frames: frames}
+		}
+	}
+	for _, s := range byStack {
+		stacks = append(stacks, *s)
+	}
+	slices.SortFunc(stacks, func(a, b goroutineStack) int {
+		return cmp.Or(b.Count-a.Count, slices.Compare(a.Frames, b.Frames))
+	})
+	return len(records), stacks
+}
@@ -0,0 +1,68 @@
+package distroseed
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pawl/distro-seed/api"
+)
+
+func TestGoroutineDump(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	for range 5 {
+		go func() { <-block }()
+	}
+
+	w := httptest.NewRecorder()
+	getGoroutines(w, httptest.NewRequest(http.MethodGet, "/api/debug/goroutines?min=5", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var dump struct {
+		Goroutines int              `json:"goroutines"`
+		Stacks     []goroutineStack `json:"stacks"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&dump); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, s := range dump.Stacks {
+		if s.Count < 5 {
+			t.Errorf("stack with %d goroutines is under the minimum", s.Count)
+		}
+		found = found || s.Count >= 5 && strings.Contains(strings.Join(s.Frames, "\n"), "TestGoroutineDump")
+	}
+	if !found || dump.Goroutines < 5 {
+		t.Errorf("the blocked goroutines aren't grouped in %d goroutines: %+v", dump.Goroutines, dump.Stacks)
+	}
+
+	w = httptest.NewRecorder()
+	getGoroutines(w, httptest.NewRequest(http.MethodGet, "/api/debug/goroutines?min=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("min=0: status %d", w.Code)
+	}
+}
+
+func TestDebugHandlersNeedToken(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	srv := &apiServer{seeder: s}
+	handler := api.RequireToken(srv.handler(), "secret")
+	for _, path := range []string{"/debug/pprof/", "/debug/vars", "/api/debug/goroutines"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s without the token: status %d", path, w.Code)
+		}
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("%s with the token: status %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package distroseed
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/anacrolix/dht/v2"
+	"github.com/anacrolix/torrent"
+)
+
+// dhtNetwork describes one DHT server to run. The builtin "ipv4" and "ipv6" networks share the
+// client's uTP sockets, other networks get a socket of their own.
+type dhtNetwork struct {
+	Name       string
+	ListenAddr string   // Only used by non-builtin networks
+	Bootstrap  []string // host:port nodes, the global bootstrap nodes if empty
+
+	server *dht.Server
+}
+
+// parseDHTNetworks parses comma-separated specs of the form
+// name[=listenAddr][@bootstrap|bootstrap...], e.g. "ipv4,ipv6,lan=:6882@10.0.0.1:6881".
+func parseDHTNetworks(input string) ([]*dhtNetwork, error) {
+	var networks []*dhtNetwork
+	for _, spec := range strings.Split(input, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+
+		n := &dhtNetwork{}
+		spec, bootstrap, hasBootstrap := strings.Cut(spec, "@")
+		if hasBootstrap {
+			for _, node := range strings.Split(bootstrap, "|") {
+				if _, _, err := net.SplitHostPort(node); err != nil {
+					return nil, fmt.Errorf("❌ Invalid DHT bootstrap node '%s': %w", node, err)
+				}
+				n.Bootstrap = append(n.Bootstrap, node)
+			}
+		}
+		n.Name, n.ListenAddr, _ = strings.Cut(spec, "=")
+
+		switch {
+		case n.Name == "":
+			return nil, fmt.Errorf("❌ DHT network without a name: '%s'", spec)
+		case isBuiltinDHTNetwork(n.Name) && n.ListenAddr != "":
+			return nil, fmt.Errorf("❌ DHT network '%s' shares the peer port and can't set a listen address", n.Name)
+		case !isBuiltinDHTNetwork(n.Name) && n.ListenAddr == "":
+			return nil, fmt.Errorf("❌ DHT network '%s' needs a listen address (%s=host:port)", n.Name, n.Name)
+		}
+		networks = append(networks, n)
+	}
+	return networks, nil
+}
+
+func isBuiltinDHTNetwork(name string) bool {
+	return name == "ipv4" || name == "ipv6"
+}
+
+func (s *Seeder) configureDHTBootstrap(cfg *torrent.ClientConfig) {
+	cfg.ConfigureAnacrolixDhtServer = func(sc *dht.ServerConfig) {
+		if bootstrap := s.nextDHTBootstrap; len(bootstrap) > 0 {
+			sc.StartingNodes = func() ([]dht.Addr, error) { return dht.ResolveHostPorts(bootstrap) }
+		}
+	}
+}
+
+// startDHTNetworks starts a DHT server for every configured network and registers it with the
+// client. The client must have been created with NoDHT set.
+func (s *Seeder) startDHTNetworks(client *torrent.Client, networks []*dhtNetwork) error {
+	for _, n := range networks {
+		var conn net.PacketConn
+		if isBuiltinDHTNetwork(n.Name) {
+			conn = findSharedPacketConn(client, n.Name)
+			if conn == nil {
+				log.Printf("⚠️ No %s socket available for the DHT", n.Name)
+				continue
+			}
+		} else {
+			err := retryDuringUpgrade(func() (err error) {
+				conn, err = net.ListenPacket("udp", n.ListenAddr)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("❌ Failed to listen for DHT network '%s': %w", n.Name, err)
+			}
+		}
+
+		s.nextDHTBootstrap = n.Bootstrap
+		server, err := client.NewAnacrolixDhtServer(conn)
+		s.nextDHTBootstrap = nil
+		if err != nil {
+			return fmt.Errorf("❌ Failed to start DHT network '%s': %w", n.Name, err)
+		}
+		n.server = server
+		client.AddDhtServer(privateDHTServer{DhtServer: torrent.AnacrolixDhtServerWrapper{Server: server}, client: client})
+		s.dhtNetworks = append(s.dhtNetworks, n)
+		log.Printf("🌐 DHT network '%s' on %s", n.Name, server.Addr())
+	}
+	return nil
+}
+
+// Close the DHT servers that own their sockets. Shared sockets are closed by the client.
+func (s *Seeder) closeDHTNetworks() {
+	for _, n := range s.dhtNetworks {
+		if !isBuiltinDHTNetwork(n.Name) {
+			n.server.Close()
+		}
+	}
+}
+
+// The client's uTP socket for the given family, which the DHT can share
+func findSharedPacketConn(client *torrent.Client, family string) net.PacketConn {
+	for _, l := range client.Listeners() {
+		if pc, ok := l.(net.PacketConn); ok && udpFamily(pc.LocalAddr()) == family {
+			return pc
+		}
+	}
+	return nil
+}
+
+func udpFamily(addr net.Addr) string {
+	if udp, ok := addr.(*net.UDPAddr); ok && udp.IP.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// dhtStatuses reports the routing table size and announces of each DHT network
+func (s *Seeder) dhtStatuses() []dhtStatus {
+	var list []dhtStatus
+	for _, n := range s.dhtNetworks {
+		stats := n.server.Stats()
+		list = append(list, dhtStatus{Name: n.Name, Nodes: stats.Nodes, GoodNodes: stats.GoodNodes,
+			Announces: stats.SuccessfulOutboundAnnouncePeerQueries})
+	}
+	return list
+}
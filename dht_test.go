@@ -0,0 +1,55 @@
+package distroseed
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDHTNetworks(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []*dhtNetwork
+		err   string // Part of the error expected, if any
+	}{
+		{input: "", want: nil},
+		{input: "ipv4, ipv6,", want: []*dhtNetwork{{Name: "ipv4"}, {Name: "ipv6"}}},
+		{
+			input: "ipv4@router.example.com:6881|10.0.0.1:6881,lan=:6882@10.0.0.2:6881",
+			want: []*dhtNetwork{
+				{Name: "ipv4", Bootstrap: []string{"router.example.com:6881", "10.0.0.1:6881"}},
+				{Name: "lan", ListenAddr: ":6882", Bootstrap: []string{"10.0.0.2:6881"}},
+			},
+		},
+		{input: "=:6882", err: "without a name"},
+		{input: "ipv4=:6882", err: "can't set a listen address"},
+		{input: "lan", err: "needs a listen address"},
+		{input: "lan=:6882@10.0.0.2", err: "Invalid DHT bootstrap node '10.0.0.2'"},
+	}
+	for _, tt := range tests {
+		got, err := parseDHTNetworks(tt.input)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseDHTNetworks(%q) error = %v, want %q", tt.input, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("parseDHTNetworks(%q) error = %v", tt.input, err)
+		case !reflect.DeepEqual(got, tt.want):
+			t.Errorf("parseDHTNetworks(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestUDPFamily(t *testing.T) {
+	for addr, want := range map[net.Addr]string{
+		&net.UDPAddr{IP: net.ParseIP("192.0.2.1")}:        "ipv4",
+		&net.UDPAddr{IP: net.ParseIP("::ffff:192.0.2.1")}: "ipv4",
+		&net.UDPAddr{IP: net.ParseIP("2001:db8::1")}:      "ipv6",
+	} {
+		if got := udpFamily(addr); got != want {
+			t.Errorf("udpFamily(%s) = %s, want %s", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package distroseed
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const lockFileName = ".lock"
+
+// How long an upgraded binary waits for the process it replaces to let go of the data directory
+const upgradeLockWait = time.Minute
+
+// errLocked is returned by tryLockFile when another process holds the lock
+var errLocked = errors.New("locked by another process")
+
+// dirLock keeps other instances from using the same data directory, whose stats, registry and
+// resume data would be corrupted by two processes writing them at once.
+type dirLock struct {
+	f *os.File
+}
+
+// lockDataDir locks dir for this process, waiting up to wait for another process to release
+// it. The lock file holds the pid of the process holding it, for the error of the next one.
+func lockDataDir(dir string, wait time.Duration) (*dirLock, error) {
+	path := filepath.Join(dir, lockFileName)
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to open lock file: %w", err)
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		err = tryLockFile(f)
+		if !errors.Is(err, errLocked) || !time.Now().Before(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			if pid := lockHolder(path); pid != 0 {
+				return nil, fmt.Errorf("❌ %s is in use by another distro-seed (pid %d)", dir, pid)
+			}
+			return nil, fmt.Errorf("❌ %s is in use by another distro-seed", dir)
+		}
+		return nil, fmt.Errorf("❌ Failed to lock %s: %w", dir, err)
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &dirLock{f: f}, nil
+}
+
+// lockHolder returns the pid written to the lock file, or 0 if there's none
+func lockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// Release lets the next process use the directory. The lock file is left in place, since
+// removing it could race with a process that just opened it.
+func (l *dirLock) Release() {
+	if l == nil {
+		return
+	}
+	unlockFile(l.f)
+	l.f.Close()
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package distroseed
+
+import "os"
+
+// Locking the data directory isn't supported on this platform, so nothing stops a second
+// instance from using it.
+func tryLockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
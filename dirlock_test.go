@@ -0,0 +1,33 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockDataDir(t *testing.T) {
+	dir := t.TempDir()
+	lock, err := lockDataDir(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockDataDir(dir, 0); err == nil || !strings.Contains(err.Error(), fmt.Sprintf("pid %d", os.Getpid())) {
+		t.Fatalf("locking a locked directory: %v", err)
+	}
+
+	// An upgraded binary waits for the old process to let go
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		lock.Release()
+	}()
+	next, err := lockDataDir(dir, 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting for the lock: %v", err)
+	}
+	next.Release()
+}
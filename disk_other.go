@@ -0,0 +1,10 @@
+//go:build !unix
+
+package distroseed
+
+import "errors"
+
+// Free space checks aren't supported on this platform.
+func freeDiskSpace(path string) (uint64, error) {
+	return 0, errors.New("free space checks are not supported on this platform")
+}
@@ -0,0 +1,118 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	diskCheckInterval   = 1 * time.Minute // Frequency of free space checks
+	defaultPauseFreeMB  = 512
+	diskResumeMarginPct = 25 // Extra free space needed to resume, so downloads don't flap
+)
+
+// diskPauser pauses downloads into data directories that are nearly full. Complete torrents
+// aren't affected, so seeding carries on.
+type diskPauser struct {
+	seeder *Seeder
+	mu     sync.Mutex
+	full   map[string]bool // Data directories downloads are paused in
+	paused map[string]bool // Infohashes of the paused torrents
+}
+
+func (d *diskPauser) IsPaused(infoHash string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.paused[infoHash]
+}
+
+// Periodically pause and resume downloads by the free space where they're stored
+func (s *Seeder) watchDiskSpace(ctx context.Context, client *torrent.Client, pauseFreeMB int64) {
+	if pauseFreeMB <= 0 {
+		return
+	}
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		s.diskPauses.check(client, uint64(pauseFreeMB)*1024*1024)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (d *diskPauser) check(client *torrent.Client, minFree uint64) {
+	for _, dir := range d.seeder.placement.Dirs() {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			continue
+		}
+		d.mu.Lock()
+		wasFull := d.full[dir]
+		full := free < minFree || (wasFull && free < minFree*(100+diskResumeMarginPct)/100)
+		d.full[dir] = full
+		d.mu.Unlock()
+
+		switch {
+		case full && !wasFull:
+			log.Printf("💾 Only %d MB free in %s, pausing downloads there", free/1024/1024, dir)
+			if d.seeder.notifications != nil {
+				d.seeder.notifications.Notify("pause:"+dir, "Downloads paused: "+dir,
+					fmt.Sprintf("Only %d MB is free in %s, so downloads there are paused until space is freed. Complete torrents are still seeded.", free/1024/1024, dir))
+			}
+		case !full && wasFull:
+			log.Printf("💾 %d MB free in %s, resuming downloads there", free/1024/1024, dir)
+			if d.seeder.notifications != nil {
+				d.seeder.notifications.Resolved("pause:" + dir)
+			}
+		}
+	}
+
+	present := make(map[string]bool)
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		present[ih] = true
+		if t.Info() == nil {
+			continue
+		}
+		d.mu.Lock()
+		pause := d.full[d.seeder.placement.DataDir(ih)] && !d.seeder.downloadComplete(t)
+		changed := d.paused[ih] != pause
+		if pause {
+			d.paused[ih] = true
+		} else {
+			delete(d.paused, ih)
+		}
+		d.mu.Unlock()
+
+		switch {
+		case !changed:
+		case pause:
+			t.DisallowDataDownload()
+			log.Printf("⏸️ Paused download: %s", t.Name())
+			d.seeder.events.Record(ih, eventDiskPaused, d.seeder.placement.Dir(ih))
+		case d.seeder.queue.kind(ih) != queuedDownload && !d.seeder.pauses.IsPaused(ih):
+			// Downloads held back by the queue or paused stay that way
+			t.AllowDataDownload()
+			log.Printf("▶️ Resumed download: %s", t.Name())
+			d.seeder.events.Record(ih, eventDiskResumed, d.seeder.placement.Dir(ih))
+		}
+	}
+
+	// Forget torrents that are no longer in the client
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for ih := range d.paused {
+		if !present[ih] {
+			delete(d.paused, ih)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package distroseed
+
+import "testing"
+
+func TestDiskPauserPausesDownloadsOnly(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	client := newTestClient(t, dir)
+	seeding := addSeedingTestTorrent(t, client, dir, "seeding.iso")
+	downloading, err := client.AddTorrent(newTestMeta(t, t.TempDir(), "downloading.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	free, err := freeDiskSpace(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &diskPauser{seeder: s, full: make(map[string]bool), paused: make(map[string]bool)}
+
+	d.check(client, free*2)
+	if !d.IsPaused(downloading.InfoHash().HexString()) {
+		t.Error("a download into a full directory wasn't paused")
+	}
+	if d.IsPaused(seeding.InfoHash().HexString()) {
+		t.Error("a complete torrent was paused")
+	}
+
+	// Space just above the threshold isn't enough to resume
+	d.check(client, free*9/10)
+	if !d.IsPaused(downloading.InfoHash().HexString()) {
+		t.Error("the download was resumed within the resume margin")
+	}
+	d.check(client, free/2)
+	if d.IsPaused(downloading.InfoHash().HexString()) {
+		t.Error("the download wasn't resumed with space free")
+	}
+}
@@ -0,0 +1,429 @@
+// Package distroseed is the seeding engine of distro-seed, for Go programs that embed it. The
+// distro-seed command, in cmd/distro-seed, is built on the same API: it defines its serve flags
+// with Config.RegisterFlags and runs the seeder with Serve.
+//
+// Each seeder keeps its own state, so a process can run several side by side. Unlike the
+// command, seeders started with New leave the process's signals alone, and are stopped by
+// canceling the context given to Run. Settings that change the whole process, like -umask and
+// -user, apply to every seeder in it.
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/announce"
+	"github.com/pawl/distro-seed/client"
+	"github.com/pawl/distro-seed/stats"
+	"golang.org/x/time/rate"
+)
+
+// Config holds a seeder's settings, one field per flag of 'distro-seed serve', named in its
+// comment and described in the flag's help. Start from DefaultConfig, as the zero value of some
+// settings turns them off and of others isn't valid.
+type Config struct {
+	DownloadDir               string        // -dir
+	DataDirs                  []string      // -data-dirs
+	Layout                    string        // -layout
+	FileMode                  string        // -file-mode
+	DirMode                   string        // -dir-mode
+	Umask                     string        // -umask
+	User                      string        // -user
+	TorrentURLs               []string      // -url
+	DHT                       []string      // -dht
+	PEX                       bool          // -pex
+	LocalDiscovery            bool          // -lsd
+	MDNS                      bool          // -mdns
+	MDNSName                  string        // -mdns-name
+	OTLPEndpoint              string        // -otlp-endpoint
+	MaxActiveDownloads        int           // -max-active-downloads
+	MaxActiveSeeds            int           // -max-active-seeds
+	QueueOrder                string        // -queue-order
+	ConfigFile                string        // -config
+	PeerPort                  int           // -peer-port
+	UploadLimit               int64         // -upload-limit
+	DownloadLimit             int64         // -download-limit
+	StatusInterval            time.Duration // -status-interval
+	AnnounceInterval          time.Duration // -announce-interval
+	ScrapeInterval            time.Duration // -scrape-interval
+	PreferUDPTrackers         bool          // -prefer-udp-trackers
+	AnnouncesPerTracker       int           // -announces-per-tracker
+	MaxUnchoked               int           // -max-unchoked
+	PeerUploadLimit           int64         // -peer-upload-limit
+	UploadStrategy            string        // -upload-strategy
+	RecordTrace               string        // -record-trace
+	OptimisticUnchokeInterval time.Duration // -optimistic-unchoke-interval
+	ConnsPerTorrent           int           // -conns-per-torrent
+	MaxConnsPerTorrent        int           // -max-conns-per-torrent
+	MaxConns                  int           // -max-conns
+	HalfOpenPerTorrent        int           // -half-open-per-torrent
+	PeerRequestBufferKiB      int           // -peer-request-buffer
+	HashWorkers               int           // -hash-workers
+	VerifyAtOnce              int           // -verify-at-once
+	DeferVerify               bool          // -defer-verify
+	PieceHashers              int           // -piece-hashers
+	MaxMemoryMB               int64         // -max-memory
+	TotalHalfOpen             int           // -total-half-open
+	DNSCacheTTL               time.Duration // -dns-cache-ttl
+	DNSNegativeTTL            time.Duration // -dns-negative-ttl
+	Report                    string        // -report
+	ReportFile                string        // -report-file
+	ReportWebhook             string        // -report-webhook
+	Hook                      string        // -hook
+	HookEvents                []string      // -hook-events
+	Webhook                   string        // -webhook
+	WebhookEvents             []string      // -webhook-events
+	MirrorManifest            string        // -mirror-manifest
+	MirrorRoot                string        // -mirror-root
+	ReportEmail               bool          // -report-email
+	SMTPServer                string        // -smtp-server
+	SMTPUser                  string        // -smtp-user
+	SMTPPassword              string        // -smtp-password
+	SMTPFrom                  string        // -smtp-from
+	NotifyEmail               []string      // -notify-email
+	NotifyMinFreeMB           int64         // -notify-min-free-mb
+	NotifyTrackerFailures     int           // -notify-tracker-failures
+	NotifyIdle                time.Duration // -notify-idle
+	StallAfter                time.Duration // -stall-after
+	Encrypt                   bool          // -encrypt
+	UploadOnly                bool          // -upload-only
+	FairBandwidth             bool          // -fair-bandwidth
+	LeechPriority             bool          // -leech-priority
+	PrioritizeRare            bool          // -prioritize-rare
+	PauseFreeMB               int64         // -pause-free-mb
+	MonthlyQuotaGB            int64         // -monthly-quota-gb
+	QuotaResetDay             int           // -quota-reset-day
+	PauseWindows              []string      // -pause-windows
+	PeerAllowlist             []string      // -peer-allowlist
+	PeerAllowlistURL          string        // -peer-allowlist-url
+	APIAddr                   string        // -api
+	APISocket                 string        // -api-socket
+	APITLSCert                string        // -api-tls-cert
+	APITLSKey                 string        // -api-tls-key
+	APIACMEDomains            []string      // -api-acme-domains
+	APIACMEEmail              string        // -api-acme-email
+	GRPCAddr                  string        // -grpc
+	HTTPMirrorAddr            string        // -http-mirror
+	TransmissionRPCAddr       string        // -transmission-rpc
+	QBittorrentAPIAddr        string        // -qbittorrent-api
+	APIToken                  string        // -api-token
+	ManifestURL               string        // -manifest-url
+	ManifestChannels          []string      // -manifest-channels
+	Presets                   []string      // -preset
+	KeepReleases              int           // -keep-releases
+	ManifestInterval          time.Duration // -manifest-interval
+	ClusterCoordinator        bool          // -cluster-coordinator
+	ClusterJoin               string        // -cluster-join
+	ClusterToken              string        // -cluster-token
+	ClusterNodeID             string        // -cluster-node-id
+	StatusFormat              string        // -status-format
+	Quiet                     bool          // -quiet
+	StatusColor               bool          // -status-color
+	ClusterReplicas           int           // -cluster-replicas
+	DryRun                    bool          // -dry-run
+	Verbose                   []string      // -verbose
+}
+
+// DefaultConfig returns the settings 'distro-seed serve' runs with when no flags are given
+func DefaultConfig() Config {
+	var cfg Config
+	cfg.RegisterFlags(flag.NewFlagSet("serve", flag.ContinueOnError))
+	return cfg
+}
+
+// Seeder is a seeding engine set up by New. Each seeder has its own torrent client and state, so
+// a process can run several, as long as they use different download directories and ports.
+type Seeder struct {
+	config  *Config
+	signals bool // Whether Run handles the process's signals, as the command does
+	// Listeners handed over by the previous process in an upgrade, nil for seeders the command
+	// didn't start
+	inherited *inheritedListeners
+	// The management API's handle on the running torrent client, which the exported methods act
+	// through. Nil until Run has started the client and once it returns.
+	running atomic.Pointer[apiServer]
+
+	// Settings that can be changed while running, and the torrents added for them
+	liveSettings   *settings
+	configMu       sync.Mutex // Serializes configuration changes from reloads and the API
+	torrentSources *sourceRegistry
+	registry       *torrentRegistry
+
+	// tasks are the goroutines that run until the seeder's context is done: the periodic tasks,
+	// and the ones following each torrent from being added to seeding. Shutdown waits for them.
+	tasks      sync.WaitGroup
+	supervisor *taskSupervisor
+
+	// Where torrents' data goes, and how it's stored and checked
+	placement      *dataPlacement
+	payloadModes   *dataModes // Modes of downloaded files, nil to leave them as created
+	encryptionKeys *keyStore  // Set when data is stored encrypted
+	hashing        *hashPool  // Pool for piece hashing, nil until the client is configured
+	verifications  *verifyQueue
+	metalinks      *metalinkChecks
+	mirror         *mirrorManifest // Mirror the torrents are reconciled against, nil if none was given
+	uploadOnly     *uploadOnlyGuard
+
+	// Pausing, queueing and prioritizing torrents
+	pauses        *torrentPauser
+	diskPauses    *diskPauser
+	queue         *torrentQueue
+	schedule      *seedingSchedule // The pause schedule, nil without windows
+	quota         *bandwidthQuota  // The monthly quota, nil without one
+	priorities    *swarmPriorities // Nil if -prioritize-rare is off
+	leechBoosts   *leechBoost      // Nil if -leech-priority is off
+	manifest      *torrentManifest // Nil without -manifest-url or -preset
+	cluster       *clusterNode     // Nil when not in a cluster
+	torrentErrors *torrentErrorTracker
+
+	// Bandwidth and peers
+	uploadLimiter   *rate.Limiter
+	downloadLimiter *rate.Limiter
+	bandwidth       *bandwidthSchedule
+	shares          *uploadShares // Nil if -fair-bandwidth is off
+	chokes          *chokePolicy
+	connections     *connTracker
+	allowlist       *peerAllowlist // Nil when all peers may connect
+	pexPeers        *pexMeter
+	peerSources     *peerSourceMeter
+
+	// Trackers and the DHT
+	announces        *announce.Scheduler // Spreads re-announces out with -announces-per-tracker
+	trackers         *announce.Status
+	preferUDP        *udpPreference   // Nil without -prefer-udp-trackers
+	scrapes          *trackerScrapes  // Nil when scraping is disabled
+	scrapeHTTPClient *http.Client     // Resolves trackers through the DNS cache like announces
+	resolverCache    *client.DNSCache // Used for the client's tracker and HTTP connections
+	dhtNetworks      []*dhtNetwork    // A running DHT server per configured network
+	// Bootstrap nodes for the DHT server currently being started, consulted by the client's
+	// ConfigureAnacrolixDhtServer hook
+	nextDHTBootstrap []string
+
+	// Stats, history and what's reported from them
+	rates            *rateMeter
+	downloads        *downloadMeter
+	ledger           *stats.Ledger    // Lifetime upload per torrent
+	uploads          *stats.History   // Daily upload totals across runs
+	completions      *completionStore // Completion times of torrents downloaded by this seeder
+	events           *eventLog        // Per-torrent history across runs
+	inheritedUploads map[string]int64 // Bytes each torrent uploaded in the processes before this one
+	statusOutput     statusWriter
+	alerts           alertThresholds
+	notifications    *notifier // Nil if email notifications aren't configured
+	hooks            *hookRunner
+	webhooks         *webhookSender
+}
+
+// newSeeder returns a seeder that runs with cfg, with nothing added yet
+func newSeeder(cfg *Config) *Seeder {
+	s := &Seeder{config: cfg}
+	s.liveSettings = &settings{changed: make(chan struct{})}
+	s.torrentSources = &sourceRegistry{torrents: make(map[string][]*torrent.Torrent)}
+	s.supervisor = newTaskSupervisor(s)
+	s.metalinks = &metalinkChecks{seeder: s, files: make(map[string][]metalinkFile)}
+	s.pauses = &torrentPauser{seeder: s, trackers: make(map[string][][]string), reasons: make(map[string][]string)}
+	s.diskPauses = &diskPauser{seeder: s, full: make(map[string]bool), paused: make(map[string]bool)}
+	s.queue = &torrentQueue{queued: make(map[string]string)}
+	s.torrentErrors = &torrentErrorTracker{seeder: s, errors: make(map[string]*torrentError)}
+	s.uploadLimiter = rate.NewLimiter(rate.Inf, 0)
+	s.downloadLimiter = rate.NewLimiter(rate.Inf, 1<<20)
+	s.bandwidth = &bandwidthSchedule{seeder: s, limiters: make(map[string]*rate.Limiter), changed: make(chan struct{}, 1)}
+	s.chokes = &chokePolicy{
+		seeder:     s,
+		conns:      make(map[string]*chokedConn),
+		written:    make(map[*torrent.PeerConn]int64),
+		optimistic: make(map[string]string),
+	}
+	s.connections = &connTracker{
+		open:     make(map[*torrent.PeerConn]openConn),
+		torrents: make(map[string]*connStats),
+	}
+	s.pexPeers = &pexMeter{learned: make(map[string]int64)}
+	s.peerSources = newPeerSourceMeter()
+	s.announces = announce.NewScheduler(0, announceTorrent)
+	s.trackers = announce.NewStatus()
+	s.resolverCache = client.NewDNSCache(client.DefaultDNSCacheTTL, client.DefaultDNSNegativeTTL)
+	s.scrapeHTTPClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return s.resolverCache.DialContext(ctx, network, addr)
+		},
+	}}
+	s.rates = newRateMeter()
+	s.downloads = &downloadMeter{seeder: s, samples: make(map[string]downloadSample)}
+	s.inheritedUploads = make(map[string]int64)
+	s.statusOutput = statusWriter{format: statusFormatTable}
+	s.alerts = alertThresholds{StallAfter: defaultStallAfter, TrackerFailures: defaultTrackerFailures}
+	return s
+}
+
+// New sets up a seeder with cfg, which is checked like the command's flags are. Unlike the
+// command, it doesn't read settings from the environment.
+func New(cfg Config) (*Seeder, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return newSeeder(&cfg), nil
+}
+
+// Run seeds until ctx is done, and returns why the seeder couldn't start if it couldn't, like
+// its download directory being locked by another seeder.
+func (s *Seeder) Run(ctx context.Context) error {
+	if s == nil || s.config == nil {
+		return errors.New("❌ Seeder wasn't set up with New")
+	}
+	return s.run(ctx)
+}
+
+// Serve runs a seeder with cfg as the command does, until ctx is done or the process is told to
+// stop. Unlike Run, it handles the process's signals: SIGHUP reloads the config file, and SIGUSR2
+// upgrades to a new binary that takes over the listeners and torrents. Only one seeder in a
+// process should be served this way.
+func Serve(ctx context.Context, cfg Config) error {
+	s := newSeeder(&cfg)
+	s.signals = true
+	s.inherited = loadInheritedListeners()
+	return s.run(ctx)
+}
+
+// Version describes the build: its version, commit and Go release
+func Version() string {
+	return buildInfo.String()
+}
+
+// ErrNotRunning is returned for a seeder's torrents before Run has started it, or once it stopped
+var ErrNotRunning = errors.New("❌ Seeder isn't running")
+
+// TorrentInfo is a torrent as a seeder lists it
+type TorrentInfo struct {
+	InfoHash    string
+	Name        string
+	State       string // downloading, seeding, paused, queued, missing data, waiting for metadata or error
+	Size        int64  // Bytes, 0 until metadata is known
+	Completed   int64  // Bytes downloaded and verified
+	Uploaded    int64  // Bytes across all runs
+	Peers       int
+	UploadRate  int64     // Bytes per second, averaged over the last minute
+	CompletedAt time.Time // When this seeder finished downloading it, zero if it didn't
+	Labels      []string
+	Sources     []string // What it was added from
+	Dir         string   // Where its data is, once metadata is known
+	Error       string   // Why it's in the error state
+}
+
+// Stats are a seeder's transfer totals and rates
+type Stats struct {
+	Torrents     int
+	Seeding      int
+	Peers        int
+	Uploaded     int64 // Bytes since the seeder started
+	Downloaded   int64
+	UploadRate   int64 // Bytes per second, averaged over the last minute
+	DownloadRate int64
+}
+
+// AddTorrent adds a torrent file, magnet link or infohash to seed, like POST /api/torrents. It
+// lasts until the config file is reloaded, and adding one that's already there isn't an error.
+func (s *Seeder) AddTorrent(url string) (TorrentInfo, error) {
+	a := s.running.Load()
+	if a == nil {
+		return TorrentInfo{}, ErrNotRunning
+	}
+	t, _, err := a.addSource(url, "", torrentOptions{})
+	if err != nil {
+		return TorrentInfo{}, err
+	}
+	return torrentInfo(s.listTorrent(t)), nil
+}
+
+// Torrents lists the seeder's torrents by name
+func (s *Seeder) Torrents() ([]TorrentInfo, error) {
+	a := s.running.Load()
+	if a == nil {
+		return nil, ErrNotRunning
+	}
+	var torrents []TorrentInfo
+	for _, t := range a.client.Torrents() {
+		torrents = append(torrents, torrentInfo(s.listTorrent(t)))
+	}
+	slices.SortFunc(torrents, func(a, b TorrentInfo) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return torrents, nil
+}
+
+// RemoveTorrent removes the sources of the torrent with the hex infohash until the config file
+// is reloaded, like 'distro-seed remove'
+func (s *Seeder) RemoveTorrent(infoHash string) error {
+	a := s.running.Load()
+	if a == nil {
+		return ErrNotRunning
+	}
+	var ih metainfo.Hash
+	if err := ih.FromHexString(infoHash); err != nil {
+		return fmt.Errorf("❌ Invalid infohash '%s'", infoHash)
+	}
+	t, ok := a.client.Torrent(ih)
+	if !ok {
+		return fmt.Errorf("❌ No torrent %s", infoHash)
+	}
+	results := []batchTorrent{{InfoHash: infoHash}}
+	if err := a.removeSources([]*torrent.Torrent{t}, results); err != nil {
+		return err
+	}
+	if results[0].Error != "" {
+		return fmt.Errorf("❌ Can't remove %s: %s", infoHash, results[0].Error)
+	}
+	return nil
+}
+
+// Stats returns what the seeder has transferred since it started, and how fast
+func (s *Seeder) Stats() (Stats, error) {
+	a := s.running.Load()
+	if a == nil {
+		return Stats{}, ErrNotRunning
+	}
+	torrents := a.client.Torrents()
+	totals := a.client.Stats()
+	rates := s.rates.Total()
+	st := Stats{
+		Torrents:     len(torrents),
+		Uploaded:     totals.BytesWrittenData.Int64(),
+		Downloaded:   totals.BytesReadData.Int64(),
+		UploadRate:   rates.Upload1m,
+		DownloadRate: rates.Download1m,
+	}
+	for _, t := range torrents {
+		st.Peers += len(t.PeerConns())
+		if t.Info() != nil && s.downloadComplete(t) {
+			st.Seeding++
+		}
+	}
+	return st, nil
+}
+
+func torrentInfo(l torrentListing) TorrentInfo {
+	info := TorrentInfo{
+		InfoHash: l.InfoHash, Name: l.Name, State: l.State,
+		Size: l.Size, Completed: l.Completed, Uploaded: l.Uploaded,
+		Peers: l.Peers, UploadRate: l.UploadRate,
+		Labels: l.Labels, Sources: l.Sources, Dir: l.Dir,
+	}
+	if l.CompletedAt != nil {
+		info.CompletedAt = *l.CompletedAt
+	}
+	if l.Error != nil {
+		info.Error = l.Error.Message
+	}
+	return info
+}
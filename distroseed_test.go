@@ -0,0 +1,62 @@
+package distroseed
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.PeerPort != defaultPeerPort || !cfg.PEX || !slices.Equal(cfg.DHT, []string{"ipv4", "ipv6"}) || cfg.DownloadDir != "./downloads" {
+		t.Errorf("defaults = peer port %d, pex %v, dht %q, dir %s", cfg.PeerPort, cfg.PEX, cfg.DHT, cfg.DownloadDir)
+	}
+	if _, err := New(cfg); err != nil {
+		t.Errorf("New with the defaults: %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("DISTRO_SEED_PEER_PORT", "7000") // Only the command reads the environment
+	cfg := DefaultConfig()
+	cfg.DownloadDir = "/srv/seeds"
+	cfg.TorrentURLs = []string{"https://example.com/a.torrent", "magnet:?xt=urn:btih:abc"}
+	cfg.MaxUnchoked = 4
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddTorrent("magnet:?xt=urn:btih:def"); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("AddTorrent before Run = %v", err)
+	}
+	cfg.MaxUnchoked = 8
+	if got := s.config; got.DownloadDir != "/srv/seeds" || len(got.TorrentURLs) != 2 || got.MaxUnchoked != 4 || got.PeerPort != defaultPeerPort {
+		t.Errorf("config = dir %s, urls %q, max unchoked %d, peer port %d", got.DownloadDir, got.TorrentURLs, got.MaxUnchoked, got.PeerPort)
+	}
+
+	for _, change := range []func(*Config){
+		func(c *Config) { *c = Config{} },
+		func(c *Config) { c.PeerPort = 70000 },
+		func(c *Config) { c.KeepReleases = 0 },
+		func(c *Config) { c.Verbose = []string{"disk"} },
+	} {
+		cfg := DefaultConfig()
+		change(&cfg)
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) succeeded", cfg)
+		}
+	}
+}
+
+func TestRunReturnsStartupErrors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.DownloadDir = t.TempDir()
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(t.Context()); err == nil || !strings.Contains(err.Error(), "No torrent URLs") {
+		t.Errorf("Run without torrents = %v, want the missing URLs error", err)
+	}
+}
@@ -0,0 +1,195 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+// dryRunTorrent is what would be seeded for a configured URL
+type dryRunTorrent struct {
+	Source   string
+	Name     string
+	InfoHash string
+	Size     int64 // 0 while unknown, for magnet links
+	Files    int
+	OnDisk   int64 // Bytes of the torrent's files already in a data directory
+}
+
+// runDryRun resolves the torrent URLs and validates their metainfo without starting the client
+// or writing anything, logging what would be seeded and the disk space it needs. It reports
+// whether every URL was usable.
+func runDryRun(urls []string, options map[string]torrentOptions, dataDirs []string) bool {
+	log.Printf("🧪 Dry run: checking %d torrent URLs", len(urls))
+	ok := true
+	var torrents []dryRunTorrent
+	for _, url := range urls {
+		found, err := resolveDryRunURL(url, options[url].credentials(), dataDirs)
+		if err != nil {
+			log.Printf("❌ %s: %v", url, err)
+			ok = false
+			continue
+		}
+		torrents = append(torrents, found...)
+	}
+
+	var size, onDisk int64
+	var unknown int
+	for _, t := range torrents {
+		if t.Size == 0 {
+			unknown++
+			log.Printf("🧲 %s (%s) - size unknown until its metadata is fetched from peers", t.Name, t.InfoHash)
+			continue
+		}
+		log.Printf("✅ %s (%s) - %s in %d files, %s on disk", t.Name, t.InfoHash, formatBytes(t.Size), t.Files, formatBytes(t.OnDisk))
+		size += t.Size
+		onDisk += t.OnDisk
+	}
+	log.Printf("📦 Would seed %d torrents totalling %s, with %s to download", len(torrents), formatBytes(size), formatBytes(size-onDisk))
+	if unknown > 0 {
+		log.Printf("⚠️ %d magnet links aren't counted in the total", unknown)
+	}
+
+	var free uint64
+	for _, dir := range dataDirs {
+		n, err := freeDiskSpace(dir)
+		if err != nil {
+			continue // Missing directories are created on a real run
+		}
+		free += n
+	}
+	if free > 0 && uint64(size-onDisk) > free {
+		log.Printf("❌ Only %s free in the data directories", formatBytes(int64(free)))
+		ok = false
+	}
+	return ok
+}
+
+// resolveDryRunURL fetches or reads the torrents a URL stands for, like processTorrents does,
+// without saving anything. Files are fetched with creds.
+func resolveDryRunURL(url string, creds fetch.Credentials, dataDirs []string) ([]dryRunTorrent, error) {
+	if magnet, ok := magnetURL(url); ok {
+		m, err := metainfo.ParseMagnetUri(magnet)
+		if err != nil {
+			return nil, fmt.Errorf("invalid magnet link: %w", err)
+		}
+		name := m.DisplayName
+		if name == "" {
+			name = m.InfoHash.HexString()
+		}
+		return []dryRunTorrent{{Source: url, Name: name, InfoHash: m.InfoHash.HexString()}}, nil
+	}
+	if isMetalinkURL(url) {
+		data, err := downloadMetalink(context.Background(), url, creds)
+		if err != nil {
+			return nil, err
+		}
+		var ml metalink
+		if err := xml.Unmarshal(data, &ml); err != nil {
+			return nil, fmt.Errorf("invalid metalink: %w", err)
+		}
+		torrentURL, err := ml.torrentURL()
+		if err != nil {
+			return nil, err
+		}
+		found, err := resolveDryRunURL(torrentURL, creds.For(url, torrentURL), dataDirs)
+		for i := range found {
+			found[i].Source = url
+		}
+		return found, err
+	}
+	if path, ok := localTorrentPath(url); ok {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return nil, err
+		} else if info.IsDir() {
+			if files, err = filepath.Glob(filepath.Join(path, "*.torrent")); err != nil {
+				return nil, err
+			}
+		}
+		var found []dryRunTorrent
+		for _, file := range files {
+			meta, err := metainfo.LoadFromFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			t, err := checkDryRunMeta(url, meta, dataDirs)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			found = append(found, t)
+		}
+		return found, nil
+	}
+
+	// A copy saved by an earlier run is used as is, like loadTorrentFile does, without copying
+	// an older release's copy into the cache
+	var meta *metainfo.MetaInfo
+	cached := fetch.CachePath(dataDirs[0], url)
+	if _, err := os.Stat(cached); err == nil {
+		if meta, err = metainfo.LoadFromFile(cached); err != nil {
+			return nil, err
+		}
+	} else if data, ok := legacyTorrentFile(loadRegistry(dataDirs[0]), dataDirs[0], url); ok {
+		if meta, err = metainfo.Load(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	} else {
+		data, err := fetch.Get(context.Background(), url, creds, 0)
+		if err != nil {
+			return nil, fmt.Errorf("downloading torrent: %w", err)
+		}
+		if meta, err = metainfo.Load(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+	t, err := checkDryRunMeta(url, meta, dataDirs)
+	if err != nil {
+		return nil, err
+	}
+	return []dryRunTorrent{t}, nil
+}
+
+// checkDryRunMeta validates the metainfo the way adding it to the client would, and totals its
+// files' sizes and how much of them is already on disk
+func checkDryRunMeta(source string, meta *metainfo.MetaInfo, dataDirs []string) (dryRunTorrent, error) {
+	if _, err := torrent.TorrentSpecFromMetaInfoErr(meta); err != nil {
+		return dryRunTorrent{}, err
+	}
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		return dryRunTorrent{}, err
+	}
+	if info.PieceLength <= 0 || int64(info.NumPieces()) != (info.TotalLength()+info.PieceLength-1)/info.PieceLength {
+		return dryRunTorrent{}, fmt.Errorf("%d pieces don't cover %d bytes", info.NumPieces(), info.TotalLength())
+	}
+
+	t := dryRunTorrent{
+		Source:   source,
+		Name:     info.BestName(),
+		InfoHash: meta.HashInfoBytes().HexString(),
+		Size:     info.TotalLength(),
+	}
+	for _, f := range info.UpvertedFiles() {
+		t.Files++
+		for _, dir := range dataDirs {
+			path := filepath.Join(append([]string{dir, info.BestName()}, f.BestPath()...)...)
+			if !info.IsDir() {
+				path = filepath.Join(dir, info.BestName())
+			}
+			if fi, err := os.Stat(path); err == nil {
+				t.OnDisk += min(fi.Size(), f.Length)
+				break
+			}
+		}
+	}
+	return t, nil
+}
@@ -0,0 +1,61 @@
+package distroseed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+func TestDryRunResolvesTorrents(t *testing.T) {
+	dataDir := t.TempDir()
+	torrentDir := t.TempDir()
+	metaA := newTestMeta(t, dataDir, "a.iso", 64<<10)
+	metaB := newTestMeta(t, dataDir, "b.iso", 64<<10)
+	// Only the first half of b.iso is on disk
+	if err := os.Truncate(filepath.Join(dataDir, "b.iso"), 32<<10); err != nil {
+		t.Fatal(err)
+	}
+	for name, meta := range map[string]*metainfo.MetaInfo{"a.torrent": metaA, "b.torrent": metaB} {
+		f, err := os.Create(filepath.Join(torrentDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := meta.Write(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	srv := httptest.NewServer(http.FileServer(http.Dir(torrentDir)))
+	defer srv.Close()
+
+	found, err := resolveDryRunURL(torrentDir, fetch.Credentials{}, []string{dataDir})
+	if err != nil || len(found) != 2 {
+		t.Fatalf("directory resolved to %+v, %v", found, err)
+	}
+	if found[0].Name != "a.iso" || found[0].OnDisk != 64<<10 || found[1].Name != "b.iso" || found[1].OnDisk != 32<<10 {
+		t.Errorf("directory resolved to %+v", found)
+	}
+
+	found, err = resolveDryRunURL(srv.URL+"/b.torrent", fetch.Credentials{}, []string{dataDir})
+	if err != nil || len(found) != 1 || found[0].InfoHash != metaB.HashInfoBytes().HexString() || found[0].Size != 64<<10 {
+		t.Errorf("URL resolved to %+v, %v", found, err)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, fetch.CacheDir)); !os.IsNotExist(err) {
+		t.Error("the torrent file was saved")
+	}
+
+	if _, err := resolveDryRunURL(srv.URL+"/missing.torrent", fetch.Credentials{}, []string{dataDir}); err == nil {
+		t.Error("a missing torrent resolved")
+	}
+	if !runDryRun([]string{torrentDir, "magnet:?xt=urn:btih:" + metaA.HashInfoBytes().HexString()}, nil, []string{dataDir}) {
+		t.Error("dry run failed with valid torrents")
+	}
+	if runDryRun([]string{filepath.Join(torrentDir, "missing.torrent")}, nil, []string{dataDir}) {
+		t.Error("dry run passed with a missing torrent file")
+	}
+}
@@ -0,0 +1,66 @@
+package distroseed
+
+import (
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// existingTorrent returns the torrent already added for the infohash, if there is one, with the
+// trackers and webseeds another source has for it added to it
+func (s *Seeder) existingTorrent(client *torrent.Client, infoHash metainfo.Hash, trackers [][]string, webSeeds []string) (*torrent.Torrent, bool) {
+	t, ok := client.Torrent(infoHash)
+	if !ok {
+		return nil, false
+	}
+	s.addTrackers(t, trackers)
+	if len(webSeeds) > 0 {
+		t.AddWebSeeds(webSeeds)
+	}
+	return t, true
+}
+
+// addedAlready reports whether the torrent added for source was already there for it or other
+// sources. Another source becomes one of its sources, rather than the torrent being seeded twice.
+func (s *Seeder) addedAlready(t *torrent.Torrent, source string) bool {
+	sources := s.torrentSources.URLs(t)
+	if slices.Contains(sources, source) {
+		return true
+	}
+	if len(sources) == 0 {
+		return false
+	}
+	ih := t.InfoHash().HexString()
+	log.Printf("🔁 %s from %s is already added from %s, adding its trackers and webseeds to it", t.Name(), source, strings.Join(sources, ", "))
+	s.torrentSources.Add(source, t)
+	s.registry.Duplicate(ih, source)
+	s.events.Record(ih, eventDuplicate, source)
+	return true
+}
+
+// addTrackers adds tiers of trackers to a torrent, or to the announce list it gets back once it's
+// no longer held back from announcing
+func (s *Seeder) addTrackers(t *torrent.Torrent, tiers [][]string) {
+	ih := t.InfoHash().HexString()
+	tiers = s.preferUDP.filter(ih, tiers)
+	if len(tiers) == 0 || s.uploadOnly.addTrackers(ih, tiers) || s.pauses.addTrackers(ih, tiers) || s.schedule.addTrackers(ih, tiers) {
+		return
+	}
+	t.AddTrackers(tiers)
+}
+
+// appendTrackers adds the tiers to an announce list, leaving out the trackers already in it
+func appendTrackers(announceList [][]string, tiers [][]string) [][]string {
+	for _, tier := range tiers {
+		tier = slices.DeleteFunc(slices.Clone(tier), func(url string) bool {
+			return slices.ContainsFunc(announceList, func(have []string) bool { return slices.Contains(have, url) })
+		})
+		if len(tier) > 0 {
+			announceList = append(announceList, tier)
+		}
+	}
+	return announceList
+}
@@ -0,0 +1,119 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestAddDuplicateTorrent(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	setTestSeederState(s, dir)
+	s.events = loadEventLog(s, dir, time.Now())
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+	post := func(url string) (int, map[string]any) {
+		w := httptest.NewRecorder()
+		api.addTorrent(w, httptest.NewRequest(http.MethodPost, "/api/torrents", strings.NewReader(`{"url": "`+url+`"}`)))
+		var reply map[string]any
+		json.NewDecoder(w.Body).Decode(&reply)
+		return w.Code, reply
+	}
+	writeTorrent := func(name string, meta *metainfo.MetaInfo) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		meta.Write(f)
+		return path
+	}
+
+	meta := newTestMeta(t, dir, "image.iso", 32<<10)
+	meta.Announce = "http://tracker.example.com/announce"
+	first := writeTorrent("image.iso.torrent", meta)
+	meta.Announce = "http://mirror-tracker.example.com/announce"
+	meta.UrlList = []string{"https://mirror.example.com/"}
+	second := writeTorrent("mirror-image.iso.torrent", meta)
+
+	if code, _ := post(first); code != http.StatusCreated {
+		t.Fatalf("adding the torrent: status %d", code)
+	}
+	waitForSeedTorrents(t, s)
+	code, reply := post(second)
+	if code != http.StatusOK || reply["already_added"] != true || !slices.Equal(reply["added_from"].([]any), []any{first}) {
+		t.Fatalf("adding it from another URL: %d %v", code, reply)
+	}
+	magnet := "magnet:?xt=urn:btih:" + meta.HashInfoBytes().HexString() + "&tr=udp%3A%2F%2Fmagnet.example.com%3A6969"
+	if code, _ := post(magnet); code != http.StatusOK {
+		t.Errorf("adding it from a magnet link: status %d", code)
+	}
+
+	if n := len(client.Torrents()); n != 1 {
+		t.Fatalf("%d torrents in the client, want 1", n)
+	}
+	tt := client.Torrents()[0]
+	mi := tt.Metainfo()
+	for _, tracker := range []string{"http://tracker.example.com/announce", "http://mirror-tracker.example.com/announce", "udp://magnet.example.com:6969"} {
+		if !slices.Contains(mi.UpvertedAnnounceList().DistinctValues(), tracker) {
+			t.Errorf("trackers %v are missing %s", mi.UpvertedAnnounceList().DistinctValues(), tracker)
+		}
+	}
+	if !slices.Equal(mi.UrlList, []string{"https://mirror.example.com/"}) {
+		t.Errorf("webseeds = %v", mi.UrlList)
+	}
+	ih := tt.InfoHash().HexString()
+	if entry, _ := s.registry.Entry(ih); entry.Source != first || !slices.Equal(entry.Duplicates, []string{second, magnet}) {
+		t.Errorf("registry entry = %+v, want the other sources as duplicates", entry)
+	}
+	var kinds []string
+	history, _ := s.events.Events(ih)
+	for _, e := range history {
+		kinds = append(kinds, e.Kind)
+	}
+	if !slices.Equal(kinds, []string{eventAdded, eventDuplicate, eventDuplicate}) {
+		t.Errorf("events = %v, want it added once", kinds)
+	}
+
+	// Removing the first source keeps the torrent for the others
+	if code, _ := post(first); code != http.StatusConflict {
+		t.Errorf("adding the first URL again: status %d", code)
+	}
+	s.removeTorrents(s.torrentSources.Remove(first))
+	if _, ok := client.Torrent(tt.InfoHash()); !ok {
+		t.Error("torrent was removed with one of its sources")
+	}
+}
+
+func TestAddTrackersToPausedTorrent(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, dir, "image.iso", 32<<10)
+	meta.Announce = "http://tracker.example.com/announce"
+	tt, err := client.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.pauses.Pause(tt)
+	s.addTrackers(tt, [][]string{{"udp://backup.example.com:6969", meta.Announce}})
+	if mi := tt.Metainfo(); len(mi.UpvertedAnnounceList()) != 0 {
+		t.Errorf("paused torrent announces to %v", mi.UpvertedAnnounceList())
+	}
+	s.pauses.Resume(tt)
+	mi := tt.Metainfo()
+	if got, want := mi.UpvertedAnnounceList(), (metainfo.AnnounceList{{meta.Announce}, {"udp://backup.example.com:6969"}}); !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("trackers after resuming = %v, want %v", got, want)
+	}
+}
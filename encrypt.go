@@ -0,0 +1,156 @@
+package distroseed
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+const encryptionKeysFileName = "encryption_keys.json"
+
+// keyStore holds a random AES-256 key per torrent, by infohash, in the data directory
+type keyStore struct {
+	mu   sync.Mutex
+	path string
+	keys map[string]string // Hex encoded
+}
+
+func loadKeyStore(downloadDir string) (*keyStore, error) {
+	ks := &keyStore{path: filepath.Join(downloadDir, encryptionKeysFileName), keys: make(map[string]string)}
+	data, err := os.ReadFile(ks.path)
+	if os.IsNotExist(err) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to read encryption keys: %w", err)
+	}
+	if err := json.Unmarshal(data, &ks.keys); err != nil {
+		return nil, fmt.Errorf("❌ Failed to parse encryption keys: %w", err)
+	}
+	return ks, nil
+}
+
+// hasKeys reports whether any torrent in the directory was stored encrypted
+func (ks *keyStore) hasKeys() bool {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	return len(ks.keys) > 0
+}
+
+// get returns the torrent's key, if it has one
+func (ks *keyStore) get(infoHash string) ([]byte, bool) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	key, err := hex.DecodeString(ks.keys[infoHash])
+	if err != nil || len(key) == 0 {
+		return nil, false
+	}
+	return key, true
+}
+
+// create generates and saves a key for the torrent
+func (ks *keyStore) create(infoHash string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.keys[infoHash] = hex.EncodeToString(key)
+	data, err := json.MarshalIndent(ks.keys, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(ks.path, data, 0600); err != nil {
+		delete(ks.keys, infoHash)
+		return nil, fmt.Errorf("❌ Failed to save encryption key: %w", err)
+	}
+	return key, nil
+}
+
+// encryptedStorage stores torrent data encrypted with AES-CTR under a per-torrent key, on top of
+// the client's usual file storage. Pieces are decrypted as they're read, for hashing or serving
+// to peers.
+type encryptedStorage struct {
+	seeder *Seeder
+	storage.ClientImplCloser
+	keys *keyStore
+}
+
+func (s *encryptedStorage) OpenTorrent(ctx context.Context, info *metainfo.Info, infoHash metainfo.Hash) (storage.TorrentImpl, error) {
+	key, ok := s.keys.get(infoHash.HexString())
+	if !ok {
+		// Plaintext left on disk would pass size checks and be served garbled
+		path := filepath.Join(s.seeder.placement.torrentDir("", info, infoHash), info.BestName())
+		for _, p := range []string{path, path + ".part"} {
+			if _, err := os.Stat(p); err == nil {
+				return storage.TorrentImpl{}, fmt.Errorf("❌ Unencrypted data for %s already exists, move it away to store it encrypted", info.BestName())
+			}
+		}
+		var err error
+		if key, err = s.keys.create(infoHash.HexString()); err != nil {
+			return storage.TorrentImpl{}, err
+		}
+		log.Printf("🔐 Created encryption key for %s", info.BestName())
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return storage.TorrentImpl{}, err
+	}
+
+	t, err := s.ClientImplCloser.OpenTorrent(ctx, info, infoHash)
+	if err != nil {
+		return t, err
+	}
+	return storage.TorrentImpl{
+		Piece: func(p metainfo.Piece) storage.PieceImpl {
+			return encryptedPiece{PieceImpl: t.Piece(p), block: block, offset: p.Offset()}
+		},
+		Close:    t.Close,
+		Capacity: t.Capacity,
+	}, nil
+}
+
+type encryptedPiece struct {
+	storage.PieceImpl
+	block  cipher.Block
+	offset int64 // Of the piece within the torrent
+}
+
+func (p encryptedPiece) ReadAt(b []byte, off int64) (int, error) {
+	n, err := p.PieceImpl.ReadAt(b, off)
+	xorKeyStream(p.block, b[:n], p.offset+off)
+	return n, err
+}
+
+func (p encryptedPiece) WriteAt(b []byte, off int64) (int, error) {
+	encrypted := make([]byte, len(b))
+	copy(encrypted, b)
+	xorKeyStream(p.block, encrypted, p.offset+off)
+	return p.PieceImpl.WriteAt(encrypted, off)
+}
+
+// xorKeyStream en- or decrypts b in place, where b is at offset within the torrent's data. The
+// CTR counter is the offset's block number, so any range can be processed independently.
+func xorKeyStream(block cipher.Block, b []byte, offset int64) {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], uint64(offset/aes.BlockSize))
+	stream := cipher.NewCTR(block, iv)
+	if skip := offset % aes.BlockSize; skip > 0 {
+		discard := make([]byte, skip)
+		stream.XORKeyStream(discard, discard)
+	}
+	stream.XORKeyStream(b, b)
+}
@@ -0,0 +1,117 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptedStorageRoundTrip(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	src, dir := t.TempDir(), t.TempDir()
+	mi := newTestMeta(t, src, "a.iso", 64<<10)
+	plain, err := os.ReadFile(filepath.Join(src, "a.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestSeederState(s, dir)
+	store := &encryptedStorage{seeder: s, ClientImplCloser: newFileStorage(s.placement), keys: keys}
+	defer store.Close()
+	ts, err := store.OpenTorrent(context.Background(), &info, mi.HashInfoBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	for i := range info.NumPieces() {
+		p := info.Piece(i)
+		if _, err := ts.Piece(p).WriteAt(plain[p.Offset():p.Offset()+p.Length()], 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.Piece(p).MarkComplete(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stored, err := os.ReadFile(filepath.Join(dir, "a.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(stored, plain) || len(stored) != len(plain) {
+		t.Fatal("the data was stored unencrypted")
+	}
+	// Any range reads back decrypted
+	p := info.Piece(1)
+	got := make([]byte, 1000)
+	if _, err := ts.Piece(p).ReadAt(got, 123); err != nil {
+		t.Fatal(err)
+	}
+	if want := plain[p.Offset()+123 : p.Offset()+1123]; !bytes.Equal(got, want) {
+		t.Error("a range read back differs from what was written")
+	}
+
+	// The key is kept across restarts, and verifying the data needs it
+	reloaded, err := loadKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := reloaded.get(mi.HashInfoBytes().HexString())
+	if !ok || !reloaded.hasKeys() {
+		t.Fatal("the torrent's key wasn't saved")
+	}
+	if checked, failed, err := verifyPieceSample(&info, dir, key, 100); err != nil || failed != 0 || checked != info.NumPieces() {
+		t.Errorf("verifyPieceSample with the key = %d, %d, %v, want every piece verified", checked, failed, err)
+	}
+	if _, failed, _ := verifyPieceSample(&info, dir, nil, 100); failed == 0 {
+		t.Error("encrypted data verified without its key")
+	}
+}
+
+func TestEncryptedStorageRefusesPlaintext(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	mi := newTestMeta(t, dir, "a.iso", 32<<10)
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := loadKeyStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestSeederState(s, dir)
+	store := &encryptedStorage{seeder: s, ClientImplCloser: newFileStorage(s.placement), keys: keys}
+	defer store.Close()
+	if _, err := store.OpenTorrent(context.Background(), &info, mi.HashInfoBytes()); err == nil {
+		t.Error("opened a torrent whose unencrypted data is already on disk")
+	}
+	if keys.hasKeys() {
+		t.Error("a key was created for a torrent that can't be stored encrypted")
+	}
+}
+
+func TestXORKeyStreamAtAnyOffset(t *testing.T) {
+	block, err := aes.NewCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole := make([]byte, 100)
+	xorKeyStream(block, whole, 0)
+	for _, off := range []int64{1, 15, 16, 17, 50} {
+		part := make([]byte, 100-off)
+		xorKeyStream(block, part, off)
+		if !bytes.Equal(part, whole[off:]) {
+			t.Errorf("the key stream from offset %d differs from the whole one", off)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package distroseed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+const (
+	eventsFileName   = "torrent_events.json"
+	maxTorrentEvents = 100                 // Per torrent, the oldest are dropped first
+	eventRetention   = 90 * 24 * time.Hour // Torrents with nothing newer are forgotten on start
+)
+
+// Kinds of events in a torrent's history
+const (
+	eventAdded            = "added"
+	eventMetadata         = "metadata"
+	eventVerified         = "verified"
+	eventCompleted        = "completed"
+	eventExported         = "exported"
+	eventTrackerError     = "tracker_error"
+	eventTrackerRecovered = "tracker_recovered"
+	eventPaused           = "paused"
+	eventResumed          = "resumed"
+	eventDiskPaused       = "disk_paused"
+	eventDiskResumed      = "disk_resumed"
+	eventRemoved          = "removed"
+	eventDuplicate        = "duplicate" // Added again from another source
+	eventError            = "error"
+	eventRecovered        = "recovered"
+	eventRestarted        = "restarted" // A task of the torrent crashed and was started again
+)
+
+// eventKinds are all the kinds of events, for checking the ones given in the config
+var eventKinds = []string{
+	eventAdded, eventMetadata, eventVerified, eventCompleted, eventExported, eventTrackerError, eventTrackerRecovered,
+	eventPaused, eventResumed, eventDiskPaused, eventDiskResumed, eventRemoved, eventDuplicate, eventError, eventRecovered,
+	eventRestarted,
+}
+
+// torrentEvent is something significant that happened to a torrent. The same event happening
+// again right after is counted rather than repeated, so a tracker that's down for days doesn't
+// push everything else out of the history.
+type torrentEvent struct {
+	Time    time.Time  `json:"time"`
+	Kind    string     `json:"kind"`
+	Message string     `json:"message,omitempty"`
+	Count   int        `json:"count,omitempty"` // Times in a row, when more than once
+	Last    *time.Time `json:"last,omitempty"`  // When it last happened, when more than once
+}
+
+// eventLog keeps a bounded history of events per torrent, by infohash, so operators can find
+// out why a torrent stopped uploading after the fact. It outlives the torrents it's about, and
+// is saved along with the upload totals on every status tick.
+type eventLog struct {
+	seeder   *Seeder
+	mu       sync.Mutex
+	path     string
+	torrents map[string][]torrentEvent // Oldest first
+	failing  map[string]bool           // Infohash and tracker URL pairs whose last announce failed
+	dirty    bool
+}
+
+func loadEventLog(s *Seeder, downloadDir string, now time.Time) *eventLog {
+	l := &eventLog{
+		seeder:   s,
+		path:     filepath.Join(downloadDir, eventsFileName),
+		torrents: make(map[string][]torrentEvent),
+		failing:  make(map[string]bool),
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Could not read torrent events: %v", err)
+		}
+		return l
+	}
+	if err := json.Unmarshal(data, &l.torrents); err != nil {
+		log.Printf("⚠️ Could not parse torrent events: %v", err)
+	}
+	for ih, history := range l.torrents {
+		if len(history) == 0 || now.Sub(history[len(history)-1].last()) > eventRetention {
+			delete(l.torrents, ih)
+			l.dirty = true
+		}
+	}
+	return l
+}
+
+func (e torrentEvent) last() time.Time {
+	if e.Last != nil {
+		return *e.Last
+	}
+	return e.Time
+}
+
+// Record adds an event to the torrent's history
+func (l *eventLog) Record(infoHash, kind, message string) {
+	if l == nil {
+		return
+	}
+	l.seeder.hooks.fire(infoHash, kind, message)
+	l.seeder.webhooks.fire(infoHash, kind, message)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.record(infoHash, kind, message, time.Now())
+}
+
+// record adds an event at the given time. The caller holds mu.
+func (l *eventLog) record(infoHash, kind, message string, at time.Time) {
+	history := l.torrents[infoHash]
+	l.dirty = true
+	if n := len(history); n > 0 && history[n-1].Kind == kind && history[n-1].Message == message {
+		latest := &history[n-1]
+		latest.Count = max(latest.Count, 1) + 1
+		latest.Last = &at
+		return
+	}
+	history = append(history, torrentEvent{Time: at, Kind: kind, Message: message})
+	if len(history) > maxTorrentEvents {
+		history = slices.Delete(history, 0, len(history)-maxTorrentEvents)
+	}
+	l.torrents[infoHash] = history
+}
+
+// announced records the outcome of an announce of the torrent to a tracker, as an error, or as
+// the tracker recovering when its last announce failed. Successes are too common to keep.
+func (l *eventLog) announced(infoHash, trackerURL, errText string) {
+	if l == nil || infoHash == "" {
+		return
+	}
+	key := infoHash + " " + trackerURL
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	switch {
+	case errText != "":
+		l.failing[key] = true
+		l.record(infoHash, eventTrackerError, trackerURL+": "+errText, time.Now())
+		l.seeder.hooks.fire(infoHash, eventTrackerError, trackerURL+": "+errText)
+		l.seeder.webhooks.fire(infoHash, eventTrackerError, trackerURL+": "+errText)
+	case l.failing[key]:
+		delete(l.failing, key)
+		l.record(infoHash, eventTrackerRecovered, trackerURL, time.Now())
+		l.seeder.hooks.fire(infoHash, eventTrackerRecovered, trackerURL)
+		l.seeder.webhooks.fire(infoHash, eventTrackerRecovered, trackerURL)
+	}
+}
+
+// recordVerified records that the torrent's data was checked, with how much of it is there
+func (s *Seeder) recordVerified(t *torrent.Torrent) {
+	s.events.Record(t.InfoHash().HexString(), eventVerified, fmt.Sprintf("%s of %s complete", formatBytes(t.BytesCompleted()), formatBytes(t.Length())))
+}
+
+// Events returns the torrent's history, oldest first, and whether it has one
+func (l *eventLog) Events(infoHash string) ([]torrentEvent, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	history, ok := l.torrents[infoHash]
+	return slices.Clone(history), ok
+}
+
+// save writes the history if anything happened since it was last written
+func (l *eventLog) save() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	data, err := json.MarshalIndent(l.torrents, "", "  ")
+	l.dirty = false
+	l.mu.Unlock()
+	if err == nil {
+		err = atomicfile.Write(l.path, data, 0644)
+	}
+	if err != nil {
+		// Tried again on the next tick
+		l.mu.Lock()
+		l.dirty = true
+		l.mu.Unlock()
+	}
+	return err
+}
+
+// Show a torrent's history, oldest first, also after it was removed. ?kind=tracker_error,paused
+// picks kinds of events, and ?since=2025-06-01T00:00:00Z leaves out those that ended before then.
+func (a *apiServer) getTorrentEvents(w http.ResponseWriter, r *http.Request) {
+	var ih metainfo.Hash
+	if err := ih.FromHexString(r.PathValue("infohash")); err != nil {
+		api.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid infohash '%s'", r.PathValue("infohash")))
+		return
+	}
+	var since time.Time
+	if s := r.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse(time.RFC3339, s); err != nil {
+			api.WriteError(w, http.StatusBadRequest, fmt.Errorf("since must be an RFC 3339 time, got '%s'", s))
+			return
+		}
+	}
+	var kinds []string
+	if s := r.URL.Query().Get("kind"); s != "" {
+		kinds = strings.Split(s, ",")
+	}
+
+	t, inClient := a.client.Torrent(ih)
+	history, ok := a.seeder.events.Events(ih.HexString())
+	if !ok && !inClient {
+		api.WriteError(w, http.StatusNotFound, fmt.Errorf("no history for torrent %s", ih.HexString()))
+		return
+	}
+	history = slices.DeleteFunc(history, func(e torrentEvent) bool {
+		return (len(kinds) > 0 && !slices.Contains(kinds, e.Kind)) || e.last().Before(since)
+	})
+	reply := struct {
+		InfoHash string         `json:"infohash"`
+		Name     string         `json:"name,omitempty"` // Unless it was removed
+		Events   []torrentEvent `json:"events"`
+	}{InfoHash: ih.HexString(), Events: append([]torrentEvent{}, history...)}
+	if inClient {
+		reply.Name = t.Name()
+	}
+	api.WriteJSON(w, http.StatusOK, reply)
+}
@@ -0,0 +1,98 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEventLog(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	l := loadEventLog(s, dir, time.Now())
+	const ih = "0123456789abcdef0123456789abcdef01234567"
+	l.Record(ih, eventAdded, "a.torrent")
+	l.announced(ih, "udp://tracker", "timeout")
+	l.announced(ih, "udp://tracker", "timeout")
+	l.announced(ih, "udp://tracker", "")
+	l.announced(ih, "udp://tracker", "")
+
+	history, ok := l.Events(ih)
+	if !ok || len(history) != 3 {
+		t.Fatalf("history = %+v, want added, a repeated tracker error and a recovery", history)
+	}
+	if e := history[1]; e.Kind != eventTrackerError || e.Message != "udp://tracker: timeout" || e.Count != 2 || e.Last == nil {
+		t.Errorf("tracker error = %+v, want it counted twice", e)
+	}
+	if e := history[2]; e.Kind != eventTrackerRecovered || e.Count != 0 {
+		t.Errorf("recovery = %+v, want one, not one per success", e)
+	}
+	if _, ok := l.Events("ffffffffffffffffffffffffffffffffffffffff"); ok {
+		t.Error("torrent without events has a history")
+	}
+
+	for i := range maxTorrentEvents {
+		l.Record(ih, eventVerified, fmt.Sprint(i))
+	}
+	history, _ = l.Events(ih)
+	if len(history) != maxTorrentEvents || history[0].Message != "0" {
+		t.Errorf("history has %d events starting with %+v, want the latest %d", len(history), history[0], maxTorrentEvents)
+	}
+
+	if err := l.save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, _ := loadEventLog(s, dir, time.Now()).Events(ih)
+	if len(reloaded) != maxTorrentEvents || reloaded[len(reloaded)-1].Message != fmt.Sprint(maxTorrentEvents-1) {
+		t.Errorf("reloaded %d events, want %d", len(reloaded), maxTorrentEvents)
+	}
+	if _, ok := loadEventLog(s, dir, time.Now().Add(eventRetention+time.Hour)).Events(ih); ok {
+		t.Error("history past the retention was kept")
+	}
+}
+
+func TestGetTorrentEvents(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	s.events = loadEventLog(s, dir, time.Now())
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+
+	const removed = "0123456789abcdef0123456789abcdef01234567"
+	s.events.Record(removed, eventAdded, "a.torrent")
+	s.events.Record(removed, eventPaused, "")
+	s.events.Record(removed, eventRemoved, "")
+
+	get := func(path string) (int, []torrentEvent) {
+		w := httptest.NewRecorder()
+		api.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var reply struct {
+			Events []torrentEvent `json:"events"`
+		}
+		json.NewDecoder(w.Body).Decode(&reply)
+		return w.Code, reply.Events
+	}
+
+	if code, got := get("/api/torrents/" + removed + "/events"); code != http.StatusOK || len(got) != 3 {
+		t.Errorf("history of a removed torrent: %d %+v", code, got)
+	}
+	if code, got := get("/api/torrents/" + removed + "/events?kind=paused,removed"); code != http.StatusOK || len(got) != 2 || got[0].Kind != eventPaused {
+		t.Errorf("paused and removed events: %d %+v", code, got)
+	}
+	if code, got := get("/api/torrents/" + removed + "/events?since=" + time.Now().Add(time.Hour).UTC().Format(time.RFC3339)); code != http.StatusOK || got == nil || len(got) != 0 {
+		t.Errorf("events from the future: %d %+v", code, got)
+	}
+	for path, want := range map[string]int{
+		"/api/torrents/ffffffffffffffffffffffffffffffffffffffff/events": http.StatusNotFound,
+		"/api/torrents/nope/events":                                     http.StatusBadRequest,
+		"/api/torrents/" + removed + "/events?since=yesterday":          http.StatusBadRequest,
+	} {
+		if code, _ := get(path); code != want {
+			t.Errorf("GET %s = %d, want %d", path, code, want)
+		}
+	}
+}
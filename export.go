@@ -0,0 +1,170 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Ways of exporting a torrent's files
+const (
+	exportCopy     = "copy"
+	exportHardlink = "hardlink" // Falls back to copying across filesystems, or when the data is encrypted
+)
+
+// exportPlaceholder matches the {name}s in an export path template
+var exportPlaceholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// exportPlaceholders are what can go in an export path template
+var exportPlaceholders = []string{"name", "infohash", "label", "file", "filename", "date"}
+
+func validateExport(template, mode string) error {
+	if template == "" {
+		if mode != "" {
+			return errors.New("export mode without an export path")
+		}
+		return nil
+	}
+	if mode != "" && mode != exportCopy && mode != exportHardlink {
+		return fmt.Errorf("unknown export mode '%s', use %s or %s", mode, exportCopy, exportHardlink)
+	}
+	if !filepath.IsAbs(template) {
+		return fmt.Errorf("export path '%s' isn't absolute", template)
+	}
+	for _, m := range exportPlaceholder.FindAllStringSubmatch(template, -1) {
+		if !slices.Contains(exportPlaceholders, m[1]) {
+			return fmt.Errorf("unknown placeholder {%s} in export path, use one of {%s}", m[1], strings.Join(exportPlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// exportPath fills in the export path template for one of the torrent's files. Without {file}
+// or {filename} in it, the file keeps its path in the torrent under the exported directory.
+func (s *Seeder) exportPath(template string, t *torrent.Torrent, f *torrent.File, now time.Time) string {
+	label := "unlabeled"
+	if labels := s.optionsOf(t).Labels; len(labels) > 0 {
+		label = labels[0]
+	}
+	var file []string
+	for _, part := range strings.Split(f.Path(), "/") {
+		file = append(file, pathSafe(part))
+	}
+	values := map[string]string{
+		"name":     pathSafe(t.Info().BestName()),
+		"infohash": t.InfoHash().HexString(),
+		"label":    pathSafe(label),
+		"file":     filepath.Join(file...),
+		"filename": pathSafe(path.Base(f.Path())),
+		"date":     now.Format(time.DateOnly),
+	}
+	target := exportPlaceholder.ReplaceAllStringFunc(template, func(m string) string {
+		return values[m[1:len(m)-1]]
+	})
+	if !strings.Contains(template, "{file}") && !strings.Contains(template, "{filename}") {
+		target = filepath.Join(target, values["file"])
+	}
+	return filepath.Clean(target)
+}
+
+// pathSafe makes a value from a torrent usable as one part of a path, so a torrent can't
+// get outside the path it's given
+func pathSafe(s string) string {
+	s = strings.NewReplacer("/", "_", `\`, "_").Replace(s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// exportTorrent copies or hardlinks the downloaded files of a torrent to where its sources
+// export them, like a library other programs serve ISOs from. Files already there with the
+// same size are left alone, so torrents that were exported before aren't copied again on start.
+func (s *Seeder) exportTorrent(ctx context.Context, t *torrent.Torrent) {
+	options := s.optionsOf(t)
+	ih := t.InfoHash().HexString()
+	if options.Export == "" || s.torrentErrors.Failed(ih) {
+		return
+	}
+	files := s.selectedFiles(t)
+	if files == nil {
+		files = t.Files()
+	}
+	now := time.Now()
+	var exported []string
+	for _, f := range files {
+		target := s.exportPath(options.Export, t, f, now)
+		if info, err := os.Stat(target); err == nil && info.Size() == f.Length() {
+			continue
+		}
+		if err := s.exportFile(ctx, t, f, target, options.ExportMode); err != nil {
+			log.Printf("⚠️ Couldn't export %s to %s: %v", f.DisplayPath(), target, err)
+			return
+		}
+		exported = append(exported, target)
+	}
+	switch len(exported) {
+	case 0:
+		return
+	case 1:
+		log.Printf("📤 Exported %s to %s", t.Name(), exported[0])
+		s.events.Record(ih, eventExported, exported[0])
+	default:
+		log.Printf("📤 Exported %d files of %s to %s", len(exported), t.Name(), filepath.Dir(exported[0]))
+		s.events.Record(ih, eventExported, fmt.Sprintf("%d files, like %s", len(exported), exported[0]))
+	}
+}
+
+// exportFile puts the file at target, through a temporary file next to it so nothing watching
+// the export path sees it half written
+func (s *Seeder) exportFile(ctx context.Context, t *torrent.Torrent, f *torrent.File, target, mode string) error {
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if mode == exportHardlink && s.encryptionKeys == nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		err := os.Link(s.placement.payloadPath(t, f), tmp.Name())
+		if err == nil {
+			return os.Rename(tmp.Name(), target)
+		}
+		log.Printf("⚠️ Couldn't hardlink %s, copying it instead: %v", f.DisplayPath(), err)
+		if tmp, err = os.Create(tmp.Name()); err != nil {
+			return err
+		}
+	}
+
+	// Read through the client, which decrypts the data and waits for pieces still being checked
+	r := f.NewReader()
+	defer r.Close()
+	r.SetContext(ctx)
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
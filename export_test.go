@@ -0,0 +1,76 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportTorrent(t *testing.T) {
+	dir := t.TempDir()
+	exportDir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent", "b.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{
+		"a.torrent": {Labels: []string{"ubuntu"}, Export: filepath.Join(exportDir, "{label}")},
+		"b.torrent": {Export: filepath.Join(exportDir, "{name}-{infohash}", "{filename}"), ExportMode: exportHardlink},
+	}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	s.events = loadEventLog(s, dir, time.Now())
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	b := addSeedingTestTorrent(t, client, dir, "b.iso")
+	s.torrentSources.Add("a.torrent", a)
+	s.torrentSources.Add("b.torrent", b)
+
+	s.exportTorrent(t.Context(), a)
+	s.exportTorrent(t.Context(), b)
+	copied := filepath.Join(exportDir, "ubuntu", "a.iso")
+	linked := filepath.Join(exportDir, "b.iso-"+b.InfoHash().HexString(), "b.iso")
+	for path, source := range map[string]string{copied: "a.iso", linked: "b.iso"} {
+		want, _ := os.ReadFile(filepath.Join(dir, source))
+		if got, err := os.ReadFile(path); err != nil || string(got) != string(want) {
+			t.Errorf("%s wasn't exported to %s: %v", source, path, err)
+		}
+	}
+	if info, err := os.Stat(linked); err == nil {
+		if source, _ := os.Stat(filepath.Join(dir, "b.iso")); !os.SameFile(info, source) {
+			t.Error("b.iso was copied rather than hardlinked")
+		}
+	}
+	if history, _ := s.events.Events(a.InfoHash().HexString()); len(history) != 1 || history[0].Kind != eventExported || history[0].Message != copied {
+		t.Errorf("history = %+v, want a.iso exported", history)
+	}
+
+	// Exporting again on start leaves files that are already there alone
+	s.exportTorrent(t.Context(), a)
+	if history, _ := s.events.Events(a.InfoHash().HexString()); len(history) != 1 {
+		t.Errorf("exporting again added %+v", history)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(exportDir, "*", ".*")); len(matches) != 0 {
+		t.Errorf("exporting left %v behind", matches)
+	}
+}
+
+func TestExportPath(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	setTestSeederState(s, dir)
+	tt := addSeedingTestTorrent(t, client, dir, "noble.iso")
+	now := time.Date(2026, 4, 23, 12, 0, 0, 0, time.UTC)
+	f := tt.Files()[0]
+	if got, want := s.exportPath("/srv/{date}/{label}/{filename}", tt, f, now), "/srv/2026-04-23/unlabeled/noble.iso"; got != want {
+		t.Errorf("export path = %s, want %s", got, want)
+	}
+	if got, want := s.exportPath("/srv/isos", tt, f, now), "/srv/isos/noble.iso"; got != want {
+		t.Errorf("export path without a file = %s, want %s", got, want)
+	}
+	for value, want := range map[string]string{"..": "_", "": "_", "a/../b": "a_.._b", "noble.iso": "noble.iso"} {
+		if got := pathSafe(value); got != want {
+			t.Errorf("pathSafe(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package fetch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+// Fetched .torrent files are kept in this directory of the download directory, named by a hash
+// of their URL, since URLs like .../download?id=123 share a base name or don't make one
+const (
+	CacheDir   = "torrent-cache"
+	cacheIndex = "index.json"
+)
+
+// CacheEntry maps a cached file back to where it was fetched from
+type CacheEntry struct {
+	URL       string    `json:"url"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+// Guards the index, which is rewritten whole for each file that's cached
+var cacheMu sync.Mutex
+
+// CachePath returns where the .torrent file fetched from url is cached
+func CachePath(downloadDir, url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(downloadDir, CacheDir, hex.EncodeToString(sum[:])+".torrent")
+}
+
+// LegacyPath is where releases before the cache saved the .torrent file fetched from url
+func LegacyPath(downloadDir, url string) string {
+	return filepath.Join(downloadDir, filepath.Base(url))
+}
+
+// CacheTorrent saves the .torrent file fetched from url and records it in the index
+func CacheTorrent(downloadDir, url string, data []byte) (string, error) {
+	path := CachePath(downloadDir, url)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// Written whole, so a download cut short by shutdown isn't loaded next time
+	if err := atomicfile.Write(path, data, 0o644); err != nil {
+		return "", err
+	}
+
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	index := ReadCacheIndex(downloadDir)
+	index[filepath.Base(path)] = CacheEntry{URL: url, FetchedAt: time.Now()}
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := atomicfile.Write(filepath.Join(downloadDir, CacheDir, cacheIndex), data, 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ReadCacheIndex returns the cache's index by file name, empty if there's none yet
+func ReadCacheIndex(downloadDir string) map[string]CacheEntry {
+	index := make(map[string]CacheEntry)
+	if data, err := os.ReadFile(filepath.Join(downloadDir, CacheDir, cacheIndex)); err == nil {
+		json.Unmarshal(data, &index)
+	}
+	return index
+}
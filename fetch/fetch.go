@@ -0,0 +1,99 @@
+// Package fetch downloads the .torrent and metalink files torrents are added from, with the
+// credentials of the source they're configured for, and caches the .torrent files in the download
+// directory.
+package fetch
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Timeout is the longest a torrent or metalink file may take to download
+const Timeout = time.Minute
+
+// Client downloads torrent and metalink files
+var Client = &http.Client{Timeout: Timeout, CheckRedirect: checkRedirect}
+
+// Credentials are what a source's .torrent or metalink file is fetched with
+type Credentials struct {
+	Username string // HTTP basic auth, none if empty
+	Password string
+	Headers  map[string]string // Like an auth token
+}
+
+// For returns the credentials target is fetched with, for a file a source's file points to. The
+// source's credentials and headers are only sent to the host the source is on.
+func (c Credentials) For(source, target string) Credentials {
+	from, err := url.Parse(source)
+	if err != nil {
+		return Credentials{}
+	}
+	if to, err := url.Parse(target); err != nil || from.Host == "" || to.Host != from.Host {
+		return Credentials{}
+	}
+	return c
+}
+
+// NewRequest returns a request for the file at url, with the credentials and headers
+func (c Credentials) NewRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	names := slices.Collect(maps.Keys(c.Headers))
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+		names = append(names, "Authorization")
+	}
+	return req.WithContext(context.WithValue(ctx, headersKey{}, names)), nil
+}
+
+// headersKey is the context key of the names of the headers NewRequest set
+type headersKey struct{}
+
+// checkRedirect follows up to 10 redirects like http.Client does, but leaves out the source's
+// credentials and headers when a redirect leaves the host they were given for. Go only drops
+// Authorization and cookies, and keeps them for subdomains.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	if req.URL.Host != via[0].URL.Host {
+		names, _ := req.Context().Value(headersKey{}).([]string)
+		for _, name := range names {
+			req.Header.Del(name)
+		}
+	}
+	return nil
+}
+
+// Get downloads the file at url with the credentials, reading at most limit bytes of it, or all
+// of it if limit is 0
+func Get(ctx context.Context, url string, creds Credentials, limit int64) ([]byte, error) {
+	req, err := creds.NewRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New(resp.Status)
+	}
+	var body io.Reader = resp.Body
+	if limit > 0 {
+		body = io.LimitReader(resp.Body, limit)
+	}
+	return io.ReadAll(body)
+}
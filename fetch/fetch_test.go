@@ -0,0 +1,62 @@
+package fetch
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCredentialsFor(t *testing.T) {
+	creds := Credentials{Username: "mirror", Password: "secret", Headers: map[string]string{"X-Api-Key": "abc"}}
+	if got := creds.For("https://mirror.example.com/release.meta4", "https://mirror.example.com/private.torrent"); got.Username != "mirror" {
+		t.Error("credentials weren't kept for the metalink's own host")
+	}
+	if got := creds.For("https://mirror.example.com/release.meta4", "https://elsewhere.example.com/private.torrent"); got.Username != "" || got.Headers != nil {
+		t.Errorf("credentials were sent to another host: %+v", got)
+	}
+}
+
+func TestGetDropsCredentialsOnRedirects(t *testing.T) {
+	creds := Credentials{Username: "mirror", Password: "secret", Headers: map[string]string{"X-Api-Key": "abc"}}
+	sent := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent <- r.Header.Get("X-Api-Key") + r.Header.Get("Authorization")
+		w.Write([]byte("data"))
+	}))
+	t.Cleanup(srv.Close)
+
+	// The same host gets them, as the source's own file may be redirected on it
+	sameHost := httptest.NewServer(http.RedirectHandler(srv.URL+"/private.torrent", http.StatusFound))
+	t.Cleanup(sameHost.Close)
+	if _, err := Get(t.Context(), srv.URL+"/private.torrent", creds, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sent; got == "" {
+		t.Error("the source's host wasn't sent the credentials")
+	}
+
+	// A redirect to another host gets neither the credentials nor the headers
+	if _, err := Get(t.Context(), sameHost.URL+"/private.torrent", creds, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := <-sent; got != "" {
+		t.Errorf("the redirect's host was sent %q", got)
+	}
+}
+
+func TestGetLimit(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("0123456789"))
+	}))
+	t.Cleanup(srv.Close)
+	if data, err := Get(t.Context(), srv.URL+"/file", Credentials{}, 4); err != nil || string(data) != "0123" {
+		t.Errorf("Get with a limit = %q, %v, want the first 4 bytes", data, err)
+	}
+	if _, err := Get(t.Context(), srv.URL+"/missing", Credentials{}, 0); err == nil {
+		t.Error("a 404 succeeded")
+	}
+}
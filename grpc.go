@@ -0,0 +1,237 @@
+package distroseed
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/managementpb"
+)
+
+// grpcServer is the gRPC management API, for fleet tooling. Changes go through the same
+// configuration updates as the HTTP API.
+type grpcServer struct {
+	seeder *Seeder
+	managementpb.UnimplementedManagementServer
+	api *apiServer
+}
+
+// Serve the gRPC API on the listener until ctx is done. With a token set, calls must carry it,
+// and with a TLS config it's served over TLS.
+func (g *grpcServer) serve(ctx context.Context, l net.Listener, token string, tlsConfig *tls.Config) {
+	var opts []grpc.ServerOption
+	if token != "" {
+		opts = append(opts,
+			grpc.UnaryInterceptor(func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				if err := checkGRPCToken(ctx, token); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.StreamInterceptor(func(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := checkGRPCToken(ss.Context(), token); err != nil {
+					return err
+				}
+				return handler(srv, ss)
+			}))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	server := grpc.NewServer(opts...)
+	managementpb.RegisterManagementServer(server, g)
+
+	log.Printf("🌐 gRPC management API listening on %s", l.Addr())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(&api.StoppableListener{Listener: l}) }()
+	select {
+	case err := <-served:
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("⚠️ gRPC management API stopped: %v", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	// Streams like event watches only end when they're cut off
+	force := time.AfterFunc(shutdownTimeout, server.Stop)
+	defer force.Stop()
+	server.GracefulStop()
+	<-served
+}
+
+func checkGRPCToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if given, ok := strings.CutPrefix(v, "Bearer "); ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "missing or wrong API token")
+}
+
+func (g *grpcServer) AddTorrent(ctx context.Context, req *managementpb.AddTorrentRequest) (*managementpb.AddTorrentResponse, error) {
+	if req.Url == "" {
+		return nil, status.Error(codes.InvalidArgument, "a URL or magnet link is needed")
+	}
+	_, err := g.seeder.updateConfig(g.api.ctx, g.api.client, g.api.downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+		if slices.Contains(current.TorrentURLs, req.Url) {
+			return current, nil // Already added
+		}
+		next := current.withTorrentSource(req.Url, req.Dir, torrentOptions{})
+		return next, next.validate()
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	t, ok := g.seeder.torrentSources.Get(req.Url)
+	if !ok {
+		return nil, status.Errorf(codes.FailedPrecondition, "couldn't add %s, see the seeder's log", req.Url)
+	}
+	return &managementpb.AddTorrentResponse{Torrent: g.seeder.torrentSummary(t)}, nil
+}
+
+func (g *grpcServer) RemoveTorrent(ctx context.Context, req *managementpb.RemoveTorrentRequest) (*managementpb.RemoveTorrentResponse, error) {
+	var urls []string
+	switch {
+	case req.Url != "":
+		urls = []string{req.Url}
+	case req.InfoHash != "":
+		var ih metainfo.Hash
+		if err := ih.FromHexString(req.InfoHash); err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		t, ok := g.api.client.Torrent(ih)
+		if !ok {
+			return nil, status.Errorf(codes.NotFound, "no torrent %s", req.InfoHash)
+		}
+		urls = g.seeder.torrentSources.URLs(t)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "an infohash or URL is needed")
+	}
+
+	diff, err := g.seeder.updateConfig(g.api.ctx, g.api.client, g.api.downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+		next := current
+		next.TorrentURLs = slices.DeleteFunc(slices.Clone(current.TorrentURLs), func(url string) bool {
+			return slices.Contains(urls, url)
+		})
+		return next, nil
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if len(diff.RemovedTorrents) == 0 {
+		return nil, status.Error(codes.NotFound, "no matching torrent sources are configured")
+	}
+	return &managementpb.RemoveTorrentResponse{RemovedSources: diff.RemovedTorrents}, nil
+}
+
+func (g *grpcServer) ListTorrents(ctx context.Context, req *managementpb.ListTorrentsRequest) (*managementpb.ListTorrentsResponse, error) {
+	return &managementpb.ListTorrentsResponse{Torrents: g.seeder.torrentSummaries(g.api.client)}, nil
+}
+
+func (g *grpcServer) StreamStats(req *managementpb.StreamStatsRequest, stream grpc.ServerStreamingServer[managementpb.Stats]) error {
+	interval := time.Duration(req.IntervalSeconds) * time.Second
+	if interval == 0 {
+		interval = time.Duration(g.seeder.liveSettings.Get().StatusInterval)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var lastUploaded, lastDownloaded int64
+	last := time.Now()
+	for first := true; ; first = false {
+		now := time.Now()
+		stats := g.api.client.Stats()
+		msg := &managementpb.Stats{
+			UnixTime:   now.Unix(),
+			Uploaded:   stats.BytesWrittenData.Int64(),
+			Downloaded: stats.BytesReadData.Int64(),
+			Torrents:   g.seeder.torrentSummaries(g.api.client),
+		}
+		for _, t := range msg.Torrents {
+			msg.Peers += t.Peers
+		}
+		if elapsed := now.Sub(last).Seconds(); !first && elapsed > 0 {
+			msg.UploadRate = int64(float64(msg.Uploaded-lastUploaded) / elapsed)
+			msg.DownloadRate = int64(float64(msg.Downloaded-lastDownloaded) / elapsed)
+		}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		lastUploaded, lastDownloaded, last = msg.Uploaded, msg.Downloaded, now
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-g.api.ctx.Done():
+			return status.Error(codes.Unavailable, "seeder is shutting down")
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Seeder) torrentSummaries(client *torrent.Client) []*managementpb.Torrent {
+	torrents := client.Torrents()
+	slices.SortFunc(torrents, func(a, b *torrent.Torrent) int { return strings.Compare(a.Name(), b.Name()) })
+	summaries := make([]*managementpb.Torrent, 0, len(torrents))
+	for _, t := range torrents {
+		summaries = append(summaries, s.torrentSummary(t))
+	}
+	return summaries
+}
+
+func (s *Seeder) torrentSummary(t *torrent.Torrent) *managementpb.Torrent {
+	ih := t.InfoHash().HexString()
+	summary := &managementpb.Torrent{
+		InfoHash: ih,
+		Name:     t.Name(),
+		Sources:  s.torrentSources.URLs(t),
+		Uploaded: s.sessionUploaded(t),
+		Peers:    int32(len(t.PeerConns())),
+		State:    managementpb.Torrent_STATE_FETCHING_METADATA,
+	}
+	if t.Info() == nil {
+		return summary
+	}
+	summary.Dir = s.placement.Dir(ih)
+	summary.Size = t.Length()
+	summary.Completed = t.BytesCompleted()
+	if p, ok := s.downloads.Progress(t); ok {
+		summary.DownloadRate = p.Rate
+		summary.EtaSeconds = int64(time.Duration(p.ETA).Seconds())
+	}
+	switch {
+	case s.torrentErrors.Failed(ih):
+		summary.State = managementpb.Torrent_STATE_ERROR
+	case s.uploadOnly.Held(ih):
+		if _, ok := s.uploadOnly.Missing(ih); ok {
+			summary.State = managementpb.Torrent_STATE_MISSING_DATA
+		} else {
+			summary.State = managementpb.Torrent_STATE_CHECKING
+		}
+	case s.queue.IsQueued(ih):
+		summary.State = managementpb.Torrent_STATE_QUEUED
+	case s.diskPauses.IsPaused(ih) || s.pauses.IsPaused(ih):
+		summary.State = managementpb.Torrent_STATE_PAUSED
+	case s.downloadComplete(t):
+		summary.State = managementpb.Torrent_STATE_SEEDING
+	default:
+		summary.State = managementpb.Torrent_STATE_DOWNLOADING
+	}
+	return summary
+}
@@ -0,0 +1,88 @@
+package distroseed
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/pawl/distro-seed/managementpb"
+)
+
+func TestGRPCManagement(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	setTestSeederState(s, dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	g := &grpcServer{seeder: s, api: &apiServer{seeder: s, ctx: ctx, client: client, downloadDir: dir}}
+	go g.serve(t.Context(), l, "s3cret", nil)
+
+	conn, err := grpc.NewClient(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	mgmt := managementpb.NewManagementClient(conn)
+
+	if _, err := mgmt.ListTorrents(ctx, &managementpb.ListTorrentsRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("ListTorrents without a token: %v, want Unauthenticated", err)
+	}
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer s3cret")
+
+	const magnet = "magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"
+	if _, err := mgmt.AddTorrent(authed, &managementpb.AddTorrentRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("AddTorrent without a URL: %v, want InvalidArgument", err)
+	}
+	added, err := mgmt.AddTorrent(authed, &managementpb.AddTorrentRequest{Url: magnet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Torrent.State != managementpb.Torrent_STATE_FETCHING_METADATA || len(added.Torrent.Sources) != 1 || added.Torrent.Sources[0] != magnet {
+		t.Errorf("AddTorrent = %+v, want a torrent fetching metadata from %s", added.Torrent, magnet)
+	}
+
+	list, err := mgmt.ListTorrents(authed, &managementpb.ListTorrentsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Torrents) != 1 || list.Torrents[0].InfoHash != added.Torrent.InfoHash {
+		t.Errorf("ListTorrents = %+v, want only %s", list.Torrents, added.Torrent.InfoHash)
+	}
+
+	if _, err := mgmt.RemoveTorrent(authed, &managementpb.RemoveTorrentRequest{InfoHash: "ffffffffffffffffffffffffffffffffffffffff"}); status.Code(err) != codes.NotFound {
+		t.Errorf("RemoveTorrent of an unknown torrent: %v, want NotFound", err)
+	}
+	removed, err := mgmt.RemoveTorrent(authed, &managementpb.RemoveTorrentRequest{InfoHash: added.Torrent.InfoHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed.RemovedSources) != 1 || removed.RemovedSources[0] != magnet {
+		t.Errorf("RemoveTorrent removed %v, want [%s]", removed.RemovedSources, magnet)
+	}
+}
+
+func TestTorrentSummarySeeding(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, runtimeConfig{})
+	setTestSeederState(s, dir)
+	tor := addSeedingTestTorrent(t, client, dir, "a.iso")
+
+	got := s.torrentSummary(tor)
+	if got.State != managementpb.Torrent_STATE_SEEDING || got.Dir != dir || got.Size != tor.Length() || got.Completed != tor.Length() {
+		t.Errorf("torrentSummary = %+v, want seeding %d bytes from %s", got, tor.Length(), dir)
+	}
+}
@@ -0,0 +1,119 @@
+package distroseed
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const hashProgressInterval = 30 * time.Second // How often verification progress is logged
+
+// hashPool limits how many pieces are hashed at once across all torrents, and logs how each
+// torrent's verification is getting on. The client limits hashing per torrent.
+type hashPool struct {
+	seeder  *Seeder
+	workers chan struct{}
+	mu      sync.Mutex
+	hashed  map[string]int64 // Bytes hashed per torrent, by infohash
+}
+
+func newHashPool(s *Seeder, workers int) *hashPool {
+	return &hashPool{seeder: s, workers: make(chan struct{}, workers), hashed: make(map[string]int64)}
+}
+
+// acquire waits for a free worker, returning the function that frees it again
+func (p *hashPool) acquire() func() {
+	if p == nil {
+		return func() {}
+	}
+	p.workers <- struct{}{}
+	return func() { <-p.workers }
+}
+
+func (p *hashPool) add(infoHash string, n int64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.hashed[infoHash] += n
+	p.mu.Unlock()
+}
+
+// verification is a torrent's verification in progress
+type verification struct {
+	started    time.Time
+	pieces     int   // Pieces waiting to be hashed when it started
+	lastHashed int64 // Bytes hashed at the last log
+	lastLogged time.Time
+}
+
+// Log the progress of torrents whose pieces are being verified until ctx is done
+func (p *hashPool) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(hashProgressInterval)
+	defer ticker.Stop()
+	active := make(map[string]*verification)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			p.logProgress(client.Torrents(), active, now)
+		}
+	}
+}
+
+func (p *hashPool) logProgress(torrents []*torrent.Torrent, active map[string]*verification, now time.Time) {
+	present := make(map[string]bool, len(torrents))
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		present[ih] = true
+		pending := p.seeder.pendingHashes(t)
+		p.mu.Lock()
+		hashed := p.hashed[ih]
+		p.mu.Unlock()
+
+		v, ok := active[ih]
+		switch {
+		case !ok && pending > 0:
+			active[ih] = &verification{started: now, pieces: pending, lastHashed: hashed, lastLogged: now}
+			log.Printf("🔍 Verifying %d pieces of %s", pending, t.Name())
+		case ok && pending == 0:
+			delete(active, ih)
+			log.Printf("🔍 Verified %s in %s", t.Name(), now.Sub(v.started).Round(time.Second))
+		case ok:
+			v.pieces = max(v.pieces, pending)
+			rate := float64(hashed-v.lastHashed) / now.Sub(v.lastLogged).Seconds()
+			log.Printf("🔍 Verifying %s: %.1f%% at %.2f MB/s, %d pieces left",
+				t.Name(), float64(v.pieces-pending)*100/float64(v.pieces), rate/1024/1024, pending)
+			v.lastHashed, v.lastLogged = hashed, now
+		}
+	}
+	for ih := range active {
+		if !present[ih] {
+			delete(active, ih)
+		}
+	}
+	p.mu.Lock()
+	for ih := range p.hashed {
+		if !present[ih] {
+			delete(p.hashed, ih)
+		}
+	}
+	p.mu.Unlock()
+}
+
+// pendingHashes returns how many of the torrent's pieces are being or waiting to be hashed
+func (s *Seeder) pendingHashes(t *torrent.Torrent) (pending int) {
+	if t.Info() == nil {
+		return 0
+	}
+	for _, r := range t.PieceStateRuns() {
+		if r.Hashing || r.QueuedForHash {
+			pending += r.Length
+		}
+	}
+	return pending + s.verifications.Unqueued(t.InfoHash().HexString())
+}
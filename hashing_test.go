@@ -0,0 +1,70 @@
+package distroseed
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThrottledPieceHashesThroughPool(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	meta := newTestMeta(t, dir, "a.iso", 64<<10)
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := throttledStorage{s, newFileStorage(s.placement)}
+	tor, err := store.OpenTorrent(context.Background(), &info, meta.HashInfoBytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	piece := tor.Piece(info.Piece(0)).(io.WriterTo)
+
+	if n, err := piece.WriteTo(io.Discard); err != nil || n != info.PieceLength {
+		t.Fatalf("WriteTo = %d, %v, want a whole piece of %d bytes", n, err, info.PieceLength)
+	}
+	if got := s.hashing.hashed[meta.HashInfoBytes().HexString()]; got != info.PieceLength {
+		t.Errorf("%d bytes counted as hashed, want %d", got, info.PieceLength)
+	}
+
+	// With the pool'store only worker busy, hashing waits
+	release := s.hashing.acquire()
+	done := make(chan struct{})
+	go func() {
+		piece.WriteTo(io.Discard)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("a piece was hashed without a free worker")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("hashing didn't go ahead once a worker was free")
+	}
+}
+
+func TestHashPoolForgetsRemovedTorrents(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	tor := addSeedingTestTorrent(t, client, dir, "a.iso")
+	p := newHashPool(s, 1)
+	p.add(tor.InfoHash().HexString(), 100)
+	p.add("gone", 100)
+	active := map[string]*verification{"gone": {started: time.Now()}}
+
+	p.logProgress(client.Torrents(), active, time.Now())
+	if len(active) != 0 {
+		t.Errorf("verifications still tracked: %v", active)
+	}
+	if _, ok := p.hashed["gone"]; ok || p.hashed[tor.InfoHash().HexString()] != 100 {
+		t.Errorf("hashed = %v, want only the torrent still loaded", p.hashed)
+	}
+}
@@ -0,0 +1,89 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/internal/swarmtest"
+)
+
+// newTestSeeder returns a seeder with no sources and the settings in cfg
+func newTestSeeder(t *testing.T, cfg runtimeConfig) *Seeder {
+	t.Helper()
+	s := newSeeder(nil)
+	s.liveSettings.set(cfg)
+	return s
+}
+
+// setTestSeederState sets up the seeder's state like run does, for a client with its data in dir
+func setTestSeederState(s *Seeder, dir string) {
+	s.hashing = newHashPool(s, 1)
+	s.placement = newDataPlacement(s, []string{dir}, "")
+	s.registry = loadRegistry(dir)
+	s.events = loadEventLog(s, dir, time.Now())
+}
+
+// testConfig returns a valid runtime configuration with the defaults main uses
+func testConfig() runtimeConfig {
+	return runtimeConfig{
+		StatusInterval:            duration(time.Minute),
+		AnnounceInterval:          duration(time.Hour),
+		OptimisticUnchokeInterval: duration(defaultOptimisticUnchoke),
+		ConnsPerTorrent:           defaultConnsPerTorrent,
+		MaxConnsPerTorrent:        defaultMaxConnsPerTorrent,
+	}
+}
+
+// newTestClient starts a client with its data in dir, and no networking beyond loopback, with
+// any changes to its config made by configure
+func newTestClient(t *testing.T, dir string, configure ...func(*torrent.ClientConfig)) *torrent.Client {
+	t.Helper()
+	return swarmtest.NewClient(t, dir, configure...)
+}
+
+// resetTestState leaves the seeder with no sources, and no settings but cfg
+func resetTestState(s *Seeder, cfg runtimeConfig) {
+	s.torrentSources = &sourceRegistry{torrents: make(map[string][]*torrent.Torrent)}
+	s.liveSettings.set(cfg)
+}
+
+// newTestMeta writes a file of random data named name in dir and returns its torrent
+func newTestMeta(t *testing.T, dir, name string, size int64) *metainfo.MetaInfo {
+	t.Helper()
+	return swarmtest.NewMeta(t, dir, name, size)
+}
+
+// addSeedingTestTorrent adds a torrent of random data named name to a client with its data in
+// dir, and verifies it so it's complete
+func addSeedingTestTorrent(t *testing.T, client *torrent.Client, dir, name string) *torrent.Torrent {
+	t.Helper()
+	tt, err := client.AddTorrent(newTestMeta(t, dir, name, 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tt.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+	swarmtest.Until(t, 5*time.Second, name+" to be verified", tt.Complete().Bool)
+	return tt
+}
+
+// waitForSeedTorrents waits for the seedTorrent goroutines of the registry's torrents to record
+// their names, which they do once their metadata is in and they're being seeded
+func waitForSeedTorrents(t *testing.T, s *Seeder) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		named := true
+		for _, e := range s.registry.Entries() {
+			named = named && e.Name != ""
+		}
+		if named {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("torrents weren't named in time")
+		}
+	}
+}
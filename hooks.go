@@ -0,0 +1,152 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const (
+	hookTimeout   = 10 * time.Minute // Hooks still running after this long are killed
+	hookQueueSize = 100              // Events waiting for the hook, later ones are dropped
+	hookOutputMax = 1024             // Bytes of a failed hook's output that are logged
+)
+
+// defaultHookEvents are the events the hook is run for unless others are given
+var defaultHookEvents = []string{eventCompleted, eventError}
+
+// hookInheritedEnv is all of the seeder's environment hooks get, leaving out secrets like the
+// API token and SMTP password that can be set in it
+var hookInheritedEnv = []string{"PATH", "HOME", "LANG", "TZ", "SYSTEMROOT"} // SYSTEMROOT for Windows
+
+// hookEvent is an event a hook is run for, with what was known about the torrent at the time
+type hookEvent struct {
+	kind     string
+	message  string
+	infoHash string
+	name     string
+	path     string
+}
+
+// hookRunner runs an operator's executable for torrent events, one at a time in the order they
+// happened, so downloads can be moved, announced or indexed by whatever automation they have
+type hookRunner struct {
+	seeder *Seeder
+	path   string
+	events []string // Kinds of events to run it for
+	client *torrent.Client
+	queue  chan hookEvent
+}
+
+func newHookRunner(s *Seeder, path string, events []string, client *torrent.Client) *hookRunner {
+	if path == "" {
+		return nil
+	}
+	return &hookRunner{seeder: s, path: path, events: events, client: client, queue: make(chan hookEvent, hookQueueSize)}
+}
+
+// validateHook checks the hook can be run, and that the events it's for are ones torrents have
+func validateHook(path string, events []string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := exec.LookPath(path); err != nil {
+		return fmt.Errorf("❌ Invalid hook: %w", err)
+	}
+	for _, kind := range events {
+		if !slices.Contains(eventKinds, kind) {
+			return fmt.Errorf("❌ Unknown hook event '%s', use some of: %s", kind, strings.Join(eventKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// fire queues the hook for an event of a torrent, if it's one the hook is for
+func (h *hookRunner) fire(infoHash, kind, message string) {
+	if h == nil || !slices.Contains(h.events, kind) {
+		return
+	}
+	e := hookEvent{kind: kind, message: message, infoHash: infoHash}
+	if h.seeder.registry != nil {
+		if entry, ok := h.seeder.registry.Entry(infoHash); ok {
+			e.name, e.path = entry.Name, entry.DataPath
+		}
+	}
+	select {
+	case h.queue <- e:
+	default:
+		log.Printf("⚠️ Too many events waiting for the hook, not running it for %s of %s", kind, infoHash)
+	}
+}
+
+// run runs the hook for queued events until the context is cancelled
+func (h *hookRunner) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-h.queue:
+			h.runHook(ctx, e)
+		}
+	}
+}
+
+func (h *hookRunner) runHook(ctx context.Context, e hookEvent) {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, h.path)
+	cmd.Env = h.environment(e)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(output) > hookOutputMax {
+			output = output[len(output)-hookOutputMax:]
+		}
+		log.Printf("⚠️ Hook for %s of %s failed: %v: %s", e.kind, e.infoHash, err, bytes.TrimSpace(output))
+	}
+}
+
+// environment describes the event and its torrent to the hook, after the few variables it gets
+// from the seeder's environment. What the torrent no longer knows, like the size of one that was
+// removed, is left empty.
+func (h *hookRunner) environment(e hookEvent) []string {
+	var size, labels, sources string
+	var ih metainfo.Hash
+	if ih.FromHexString(e.infoHash) == nil {
+		if t, ok := h.client.Torrent(ih); ok {
+			if t.Info() != nil {
+				size = strconv.FormatInt(t.Length(), 10)
+				if e.name == "" {
+					e.name = t.Info().BestName()
+				}
+			}
+			labels = strings.Join(h.seeder.optionsOf(t).Labels, ",")
+			sources = strings.Join(h.seeder.torrentSources.URLs(t), ",")
+		}
+	}
+	var env []string
+	for _, name := range hookInheritedEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env,
+		"DISTRO_SEED_EVENT="+e.kind,
+		"DISTRO_SEED_MESSAGE="+e.message,
+		"DISTRO_SEED_INFOHASH="+e.infoHash,
+		"DISTRO_SEED_NAME="+e.name,
+		"DISTRO_SEED_PATH="+e.path,
+		"DISTRO_SEED_SIZE="+size,
+		"DISTRO_SEED_LABELS="+labels,
+		"DISTRO_SEED_SOURCES="+sources,
+	)
+}
@@ -0,0 +1,75 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHookRunner(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu", "lts"}}}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	s.torrentSources.Add("a.torrent", a)
+	ih := a.InfoHash().HexString()
+	s.registry.Record(ih, "a.torrent", "")
+	s.registry.SetName(ih, "a.iso", dir)
+
+	out := filepath.Join(dir, "hook.env")
+	script := filepath.Join(dir, "hook.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\nenv | grep ^DISTRO_SEED_ | sort > "+out+"\n"), 0o755)
+	s.hooks = newHookRunner(s, script, defaultHookEvents, client)
+
+	s.events.Record(ih, eventPaused, "")
+	s.events.Record(ih, eventCompleted, "32.0 KiB")
+	if len(s.hooks.queue) != 1 {
+		t.Fatalf("%d events queued, want only the completion", len(s.hooks.queue))
+	}
+	t.Setenv("DISTRO_SEED_API_TOKEN", "s3cret")
+	s.hooks.runHook(t.Context(), <-s.hooks.queue)
+	env, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"DISTRO_SEED_EVENT=completed",
+		"DISTRO_SEED_MESSAGE=32.0 KiB",
+		"DISTRO_SEED_INFOHASH=" + ih,
+		"DISTRO_SEED_NAME=a.iso",
+		"DISTRO_SEED_PATH=" + filepath.Join(dir, "a.iso"),
+		"DISTRO_SEED_SIZE=32768",
+		"DISTRO_SEED_LABELS=ubuntu,lts",
+		"DISTRO_SEED_SOURCES=a.torrent",
+	} {
+		if !strings.Contains(string(env), want+"\n") {
+			t.Errorf("hook environment is missing %s:\n%s", want, env)
+		}
+	}
+	if strings.Contains(string(env), "s3cret") {
+		t.Errorf("the seeder's secrets were passed to the hook:\n%s", env)
+	}
+
+}
+
+func TestValidateHook(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "hook.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\n"), 0o755)
+	if err := validateHook(script, []string{eventCompleted, eventExported}); err != nil {
+		t.Errorf("valid hook: %v", err)
+	}
+	if err := validateHook(script, []string{"finished"}); err == nil {
+		t.Error("hook for an unknown event is valid")
+	}
+	if err := validateHook(filepath.Join(t.TempDir(), "missing.sh"), defaultHookEvents); err == nil {
+		t.Error("missing hook is valid")
+	}
+	if err := validateHook("", nil); err != nil {
+		t.Errorf("no hook: %v", err)
+	}
+}
@@ -0,0 +1,254 @@
+package distroseed
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+const (
+	checksumsFileName = "checksums.json"
+	checksumInterval  = time.Minute // How often complete files are looked for that haven't been checksummed
+)
+
+// httpMirror serves the complete files of the torrents over HTTP like a distro mirror, at their
+// path in the torrent, with an index page listing their sizes, SHA-256 checksums and links to
+// their torrents, and a SHA256SUMS file for the lot
+type httpMirror struct {
+	seeder *Seeder
+	client *torrent.Client
+	path   string
+
+	mu   sync.Mutex
+	sums map[string]map[string]string // SHA-256 by infohash, then path in the torrent
+}
+
+func newHTTPMirror(s *Seeder, client *torrent.Client, downloadDir string) *httpMirror {
+	m := &httpMirror{seeder: s, client: client, path: filepath.Join(downloadDir, checksumsFileName), sums: make(map[string]map[string]string)}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Could not read checksums file: %v", err)
+		}
+		return m
+	}
+	if err := json.Unmarshal(data, &m.sums); err != nil {
+		log.Printf("⚠️ Failed to parse checksums file: %v", err)
+	}
+	return m
+}
+
+// mirrorFile is a complete file on the mirror
+type mirrorFile struct {
+	Path    string // In the torrent, which is where it's served
+	Size    int64
+	SHA256  string // Empty until it's been checksummed
+	Torrent *torrent.Torrent
+	file    *torrent.File
+}
+
+// files lists the complete files of the torrents that aren't stopped by an error, by path
+func (m *httpMirror) files() []mirrorFile {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []mirrorFile
+	for _, t := range m.client.Torrents() {
+		ih := t.InfoHash().HexString()
+		if t.Info() == nil || m.seeder.torrentErrors.Failed(ih) {
+			continue
+		}
+		for _, f := range t.Files() {
+			if f.BytesCompleted() == f.Length() {
+				files = append(files, mirrorFile{Path: f.Path(), Size: f.Length(), SHA256: m.sums[ih][f.Path()], Torrent: t, file: f})
+			}
+		}
+	}
+	slices.SortFunc(files, func(a, b mirrorFile) int { return strings.Compare(a.Path, b.Path) })
+	return files
+}
+
+// run checksums complete files until the context is cancelled, one at a time so serving and
+// seeding them isn't held up
+func (m *httpMirror) run(ctx context.Context) {
+	ticker := time.NewTicker(checksumInterval)
+	defer ticker.Stop()
+	for {
+		m.checksumFiles(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checksumFiles checksums the complete files that haven't been, and forgets the torrents that
+// were removed
+func (m *httpMirror) checksumFiles(ctx context.Context) {
+	changed := false
+	for _, f := range m.files() {
+		if f.SHA256 != "" {
+			continue
+		}
+		sum, err := fileChecksum(ctx, f.file)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("⚠️ Couldn't checksum %s: %v", f.Path, err)
+			}
+			continue
+		}
+		ih := f.Torrent.InfoHash().HexString()
+		m.mu.Lock()
+		if m.sums[ih] == nil {
+			m.sums[ih] = make(map[string]string)
+		}
+		m.sums[ih][f.Path] = sum
+		m.mu.Unlock()
+		changed = true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ih := range m.sums {
+		var hash metainfo.Hash
+		if hash.FromHexString(ih) != nil {
+			delete(m.sums, ih)
+			changed = true
+		} else if _, ok := m.client.Torrent(hash); !ok {
+			delete(m.sums, ih)
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	data, err := json.MarshalIndent(m.sums, "", "  ")
+	if err == nil {
+		err = atomicfile.Write(m.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("⚠️ Failed to write checksums file: %v", err)
+	}
+}
+
+// fileChecksum reads a file through the client, which decrypts it, and returns its SHA-256
+func fileChecksum(ctx context.Context, f *torrent.File) (string, error) {
+	r := f.NewReader()
+	defer r.Close()
+	r.SetContext(ctx)
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (m *httpMirror) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", m.index)
+	mux.HandleFunc("GET /SHA256SUMS", m.checksums)
+	mux.HandleFunc("GET /torrent/{infohash}", m.torrentFile)
+	mux.HandleFunc("GET /{path...}", m.file)
+	return mux
+}
+
+// serve serves the mirror until ctx is done, over TLS with a TLS config
+func (m *httpMirror) serve(ctx context.Context, l net.Listener, tlsConfig *tls.Config) {
+	log.Printf("🪞 HTTP mirror listening on %s", l.Addr())
+	if err := api.Serve(ctx, l, m.handler(), tlsConfig); err != nil {
+		log.Printf("⚠️ HTTP mirror stopped: %v", err)
+	}
+}
+
+var mirrorIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"size": formatBytes,
+	"infohash": func(t *torrent.Torrent) string {
+		return t.InfoHash().HexString()
+	},
+	"magnet": func(t *torrent.Torrent) template.URL {
+		return template.URL(torrentMagnet(t))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>distro-seed mirror</title>
+<style>body{font-family:sans-serif}td,th{padding:2px 12px;text-align:left}td.size{text-align:right}code{font-size:smaller}</style>
+</head>
+<body>
+<h1>distro-seed mirror</h1>
+<table>
+<tr><th>File</th><th>Size</th><th>SHA-256</th><th>Torrent</th></tr>
+{{range .}}<tr><td><a href="/{{.Path}}">{{.Path}}</a></td><td class="size">{{size .Size}}</td><td><code>{{or .SHA256 "not checksummed yet"}}</code></td><td><a href="/torrent/{{infohash .Torrent}}">.torrent</a> <a href="{{magnet .Torrent}}">magnet</a></td></tr>
+{{end}}</table>
+<p><a href="/SHA256SUMS">SHA256SUMS</a></p>
+</body>
+</html>
+`))
+
+// index lists the files on the mirror
+func (m *httpMirror) index(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := mirrorIndexTemplate.Execute(w, m.files()); err != nil {
+		log.Printf("⚠️ Couldn't write the mirror index: %v", err)
+	}
+}
+
+// checksums lists the files that have been checksummed in the format sha256sum -c reads
+func (m *httpMirror) checksums(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, f := range m.files() {
+		if f.SHA256 != "" {
+			fmt.Fprintf(w, "%s  %s\n", f.SHA256, f.Path)
+		}
+	}
+}
+
+// torrentFile serves a torrent's metainfo, with the trackers and webseeds it has now
+func (m *httpMirror) torrentFile(w http.ResponseWriter, r *http.Request) {
+	var ih metainfo.Hash
+	name, _ := strings.CutSuffix(r.PathValue("infohash"), ".torrent")
+	if err := ih.FromHexString(name); err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	t, ok := m.client.Torrent(ih)
+	if !ok || t.Info() == nil {
+		http.NotFound(w, r)
+		return
+	}
+	writeTorrentFile(w, t)
+}
+
+// file serves a complete file by its path in its torrent
+func (m *httpMirror) file(w http.ResponseWriter, r *http.Request) {
+	files := m.files()
+	i := slices.IndexFunc(files, func(f mirrorFile) bool { return f.Path == r.PathValue("path") })
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	f := files[i].file
+	reader := f.NewReader()
+	defer reader.Close()
+	reader.SetContext(r.Context())
+	http.ServeContent(w, r, path.Base(f.Path()), time.Time{}, reader)
+}
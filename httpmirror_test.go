@@ -0,0 +1,78 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestHTTPMirror(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	seeding, err := client.AddTorrent(newTestMeta(t, dir, "image.iso", 64<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seeding.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "image.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	want := hex.EncodeToString(sum[:])
+	ih := seeding.InfoHash().HexString()
+
+	m := newHTTPMirror(s, client, dir)
+	handler := m.handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `href="/image.iso"`) || !strings.Contains(w.Body.String(), "not checksummed yet") || !strings.Contains(w.Body.String(), "magnet:?xt=urn:btih:"+ih) {
+		t.Fatalf("index before checksumming: status %d\n%s", w.Code, w.Body)
+	}
+
+	m.checksumFiles(context.Background())
+	if w := get("/"); !strings.Contains(w.Body.String(), want) {
+		t.Errorf("index doesn't list the checksum %s:\n%s", want, w.Body)
+	}
+	if w := get("/SHA256SUMS"); w.Body.String() != want+"  image.iso\n" {
+		t.Errorf("SHA256SUMS = %q", w.Body)
+	}
+	if w := get("/image.iso"); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+		t.Errorf("file: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+	if w := get("/other.iso"); w.Code != http.StatusNotFound {
+		t.Errorf("file not on the mirror: status %d, want 404", w.Code)
+	}
+	w = get("/torrent/" + ih)
+	mi, err := metainfo.Load(w.Body)
+	if err != nil || mi.HashInfoBytes().HexString() != ih {
+		t.Errorf("torrent file: status %d, %v", w.Code, err)
+	}
+
+	// Checksums are kept across restarts, and forgotten with their torrent
+	if got := newHTTPMirror(s, client, dir).files(); len(got) != 1 || got[0].SHA256 != want {
+		t.Errorf("reloaded files = %+v", got)
+	}
+	seeding.Drop()
+	m.checksumFiles(context.Background())
+	if len(newHTTPMirror(s, client, dir).sums) != 0 {
+		t.Error("checksums of a removed torrent were kept")
+	}
+}
@@ -0,0 +1,226 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	defaultConnsPerTorrent    = 100             // Established connections per torrent before scaling
+	defaultMaxConnsPerTorrent = 300             // Upper bound for torrents receiving reclaimed slots
+	defaultHalfOpenPerTorrent = 50              // Connection attempts in progress per torrent
+	defaultTotalHalfOpen      = 100             // Connection attempts in progress across all torrents
+	idleConnsPerTorrent       = 10              // Connections kept by torrents without leechers
+	idleSwarmWindow           = 1 * time.Hour   // How long a swarm must go without leechers to be idle
+	slotCheckInterval         = 1 * time.Minute // Frequency of idle swarm checks
+)
+
+// Periodically move connection slots from torrents whose swarms have had no leechers for
+// idleSwarmWindow to torrents that have leechers to serve, scaling the slots per torrent with
+// upload throughput
+func (s *Seeder) manageConnectionSlots(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(slotCheckInterval)
+	defer ticker.Stop()
+
+	// Last time each torrent was seen with leechers, or was first seen
+	lastLeechers := make(map[string]time.Time)
+	// Connection limit currently applied to each torrent
+	limits := make(map[string]int)
+	cfg := s.liveSettings.Get()
+	scaler := newSlotScaler(cfg.ConnsPerTorrent)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.liveSettings.Changed():
+			// New limits apply to existing torrents straight away
+			next := s.liveSettings.Get()
+			if next.ConnsPerTorrent != cfg.ConnsPerTorrent {
+				scaler = newSlotScaler(next.ConnsPerTorrent)
+			}
+			cfg = next
+			s.rebalanceConnectionSlots(client, cfg, min(scaler.slots, cfg.MaxConnsPerTorrent), lastLeechers, limits)
+		case <-ticker.C:
+			stats := client.Stats()
+			slots := scaler.update(stats.BytesWrittenData.Int64(), time.Now(), cfg.MaxConnsPerTorrent)
+			s.applySwarmPriorities(client)
+			s.leechBoosts.update(client.Torrents())
+			s.rebalanceConnectionSlots(client, cfg, slots, lastLeechers, limits)
+		}
+	}
+}
+
+func (s *Seeder) rebalanceConnectionSlots(client *torrent.Client, cfg runtimeConfig, slots int, lastLeechers map[string]time.Time, limits map[string]int) {
+	if s.schedule.Paused() {
+		// Connections are closed until the pause ends, when all limits are set again
+		clear(limits)
+		return
+	}
+	now := time.Now()
+	present := make(map[string]bool)
+	fixed := make(map[string]int)
+	queued := 0
+	var torrents []*torrent.Torrent
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		present[ih] = true
+		if s.queue.IsQueued(ih) {
+			// Queued torrents have their slots managed by the queue
+			delete(limits, ih)
+			queued++
+			continue
+		}
+		if s.pauses.IsPaused(ih) {
+			// No connections until it's resumed, when its limit is set again
+			delete(limits, ih)
+			continue
+		}
+		if limit, ok := s.fixedConnLimit(cfg, t); ok {
+			fixed[ih] = limit
+		}
+		torrents = append(torrents, t)
+	}
+
+	idle := make(map[string]bool)
+	scaled := 0
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		if _, ok := fixed[ih]; ok {
+			continue
+		}
+		scaled++
+		if _, seen := lastLeechers[ih]; !seen || countLeechers(t) > 0 {
+			lastLeechers[ih] = now
+		}
+		// Torrents still downloading need their slots to find seeders
+		idle[ih] = t.Info() != nil && s.downloadComplete(t) && now.Sub(lastLeechers[ih]) > idleSwarmWindow
+	}
+
+	// Slots given up by idle torrents are shared among the active ones
+	budget := scaled * slots
+	activeCount := scaled
+	for _, isIdle := range idle {
+		if isIdle {
+			budget -= idleConnsPerTorrent
+			activeCount--
+		}
+	}
+	activeLimit := slots
+	if activeCount > 0 {
+		activeLimit = min(budget/activeCount, cfg.MaxConnsPerTorrent)
+	}
+
+	wanted := make(map[string]int, len(torrents))
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		switch limit, ok := fixed[ih]; {
+		case ok:
+			wanted[ih] = limit
+		case idle[ih]:
+			wanted[ih] = idleConnsPerTorrent
+		case s.leechBoosts.Boosted(ih):
+			wanted[ih] = cfg.MaxConnsPerTorrent
+		default:
+			wanted[ih] = s.priorities.connLimit(ih, activeLimit, cfg.MaxConnsPerTorrent)
+		}
+	}
+	granted := wanted
+	if cfg.MaxConns > 0 {
+		granted = shareConns(wanted, cfg.MaxConns-queued*idleConnsPerTorrent)
+	}
+
+	capped := false
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		limit := granted[ih]
+		previous, known := limits[ih]
+		if known && previous == limit {
+			continue
+		}
+		t.SetMaxEstablishedConns(limit)
+		limits[ih] = limit
+		capped = capped || limit < wanted[ih]
+
+		_, isFixed := fixed[ih]
+		switch {
+		case limit < wanted[ih]:
+			// Logged once for all torrents below
+		case isFixed:
+			log.Printf("🔧 Connection limit for %s set to %d", t.Name(), limit)
+		case idle[ih] && previous != idleConnsPerTorrent:
+			log.Printf("💤 No leechers for %s on %s, reducing to %d connections", idleSwarmWindow, t.Name(), limit)
+		case known && previous == idleConnsPerTorrent:
+			log.Printf("🔥 Leechers returned on %s, raising to %d connections", t.Name(), limit)
+		}
+	}
+	if capped {
+		log.Printf("🧮 Connections capped at %d across all torrents, sharing them out evenly", cfg.MaxConns)
+	}
+
+	// Forget torrents that have been dropped
+	for ih := range lastLeechers {
+		if _, ok := idle[ih]; !ok {
+			delete(lastLeechers, ih)
+		}
+	}
+	for ih := range limits {
+		if !present[ih] {
+			delete(limits, ih)
+		}
+	}
+}
+
+// shareConns lowers the wanted connection limits to fit in total. Each torrent gets an equal
+// share, and what torrents wanting less leave over goes to the others.
+func shareConns(wanted map[string]int, total int) map[string]int {
+	sum := 0
+	for _, n := range wanted {
+		sum += n
+	}
+	if sum <= total {
+		return wanted
+	}
+	infoHashes := slices.SortedFunc(maps.Keys(wanted), func(a, b string) int {
+		return cmp.Or(cmp.Compare(wanted[a], wanted[b]), strings.Compare(a, b))
+	})
+	granted := make(map[string]int, len(wanted))
+	left := total
+	for i, ih := range infoHashes {
+		// Every torrent keeps a connection, even if that goes over
+		granted[ih] = max(min(wanted[ih], left/(len(infoHashes)-i)), 1)
+		left -= granted[ih]
+	}
+	return granted
+}
+
+// fixedConnLimit returns the connection limit configured for one of the torrent's sources, which
+// takes it out of slot scaling
+func (s *Seeder) fixedConnLimit(cfg runtimeConfig, t *torrent.Torrent) (int, bool) {
+	for _, url := range s.torrentSources.URLs(t) {
+		if limit, ok := cfg.TorrentConns[url]; ok {
+			return limit, true
+		}
+	}
+	return 0, false
+}
+
+// Number of connected peers that don't have the whole torrent yet
+func countLeechers(t *torrent.Torrent) (leechers int) {
+	if t.Info() == nil {
+		return 0
+	}
+	for _, pc := range t.PeerConns() {
+		if pc.Stats().RemotePieceCount < t.NumPieces() {
+			leechers++
+		}
+	}
+	return leechers
+}
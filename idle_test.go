@@ -0,0 +1,112 @@
+package distroseed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+func TestRebalanceConnectionSlots(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	s := newTestSeeder(t, cfg)
+	idle := addSeedingTestTorrent(t, client, dir, "idle.iso")
+	active := addSeedingTestTorrent(t, client, dir, "active.iso")
+	lastLeechers := map[string]time.Time{
+		idle.InfoHash().HexString():                time.Now().Add(-2 * idleSwarmWindow),
+		active.InfoHash().HexString():              time.Now().Add(-idleSwarmWindow / 2),
+		"0123456789abcdef0123456789abcdef01234567": time.Now(),
+	}
+	limits := make(map[string]int)
+
+	s.rebalanceConnectionSlots(client, cfg, cfg.ConnsPerTorrent, lastLeechers, limits)
+	if got := limits[idle.InfoHash().HexString()]; got != idleConnsPerTorrent {
+		t.Errorf("idle torrent limited to %d connections, want %d", got, idleConnsPerTorrent)
+	}
+	// The slots the idle torrent gave up go to the active one
+	if got, want := limits[active.InfoHash().HexString()], 2*cfg.ConnsPerTorrent-idleConnsPerTorrent; got != want {
+		t.Errorf("active torrent limited to %d connections, want %d", got, want)
+	}
+	if _, ok := lastLeechers["0123456789abcdef0123456789abcdef01234567"]; ok {
+		t.Error("a dropped torrent is still tracked")
+	}
+
+	// Leechers returning to the idle torrent's swarm give it its slots back
+	lastLeechers[idle.InfoHash().HexString()] = time.Now()
+	s.rebalanceConnectionSlots(client, cfg, cfg.ConnsPerTorrent, lastLeechers, limits)
+	if got := limits[idle.InfoHash().HexString()]; got != cfg.ConnsPerTorrent {
+		t.Errorf("torrent with leechers again limited to %d connections, want %d", got, cfg.ConnsPerTorrent)
+	}
+}
+
+func TestRebalanceConnectionSlotsFixedLimits(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.MaxConnsPerTorrent = 150
+	cfg.TorrentConns = map[string]int{"https://example.com/fixed.torrent": 25}
+	s := newTestSeeder(t, cfg)
+	fixed := addSeedingTestTorrent(t, client, dir, "fixed.iso")
+	s.torrentSources.Add("https://example.com/fixed.torrent", fixed)
+	idle := addSeedingTestTorrent(t, client, dir, "idle.iso")
+	active := addSeedingTestTorrent(t, client, dir, "active.iso")
+	lastLeechers := map[string]time.Time{
+		fixed.InfoHash().HexString():  time.Now().Add(-2 * idleSwarmWindow),
+		idle.InfoHash().HexString():   time.Now().Add(-2 * idleSwarmWindow),
+		active.InfoHash().HexString(): time.Now(),
+	}
+	limits := make(map[string]int)
+
+	s.rebalanceConnectionSlots(client, cfg, cfg.ConnsPerTorrent, lastLeechers, limits)
+	// Fixed limits hold even for idle swarms, and reclaimed slots stop at the max
+	if got := limits[fixed.InfoHash().HexString()]; got != 25 {
+		t.Errorf("torrent with a fixed limit limited to %d connections, want 25", got)
+	}
+	if got := limits[active.InfoHash().HexString()]; got != cfg.MaxConnsPerTorrent {
+		t.Errorf("active torrent limited to %d connections, want the max of %d", got, cfg.MaxConnsPerTorrent)
+	}
+}
+
+func TestShareConns(t *testing.T) {
+	tests := []struct {
+		wanted map[string]int
+		total  int
+		want   map[string]int
+	}{
+		// Under the total, everyone gets what they want
+		{wanted: map[string]int{"a": 100, "b": 50}, total: 200, want: map[string]int{"a": 100, "b": 50}},
+		// Equal shares
+		{wanted: map[string]int{"a": 100, "b": 100}, total: 100, want: map[string]int{"a": 50, "b": 50}},
+		// What a small torrent leaves goes to the others
+		{wanted: map[string]int{"a": 10, "b": 100, "c": 100}, total: 110, want: map[string]int{"a": 10, "b": 50, "c": 50}},
+		// Every torrent keeps a connection
+		{wanted: map[string]int{"a": 100, "b": 100, "c": 100}, total: 2, want: map[string]int{"a": 1, "b": 1, "c": 1}},
+	}
+	for _, tt := range tests {
+		if got := shareConns(tt.wanted, tt.total); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shareConns(%v, %d) = %v, want %v", tt.wanted, tt.total, got, tt.want)
+		}
+	}
+}
+
+func TestRebalanceConnectionSlotsMaxConns(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.MaxConns = 120
+	s := newTestSeeder(t, cfg)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	b := addSeedingTestTorrent(t, client, dir, "b.iso")
+	lastLeechers := map[string]time.Time{a.InfoHash().HexString(): time.Now(), b.InfoHash().HexString(): time.Now()}
+	limits := make(map[string]int)
+
+	s.rebalanceConnectionSlots(client, cfg, cfg.ConnsPerTorrent, lastLeechers, limits)
+	for _, tor := range []*torrent.Torrent{a, b} {
+		if got := limits[tor.InfoHash().HexString()]; got != 60 {
+			t.Errorf("%s limited to %d connections, want an even share of 60", tor.Name(), got)
+		}
+	}
+}
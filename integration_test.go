@@ -0,0 +1,197 @@
+package distroseed_test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	distroseed "github.com/pawl/distro-seed"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/internal/swarmtest"
+	"github.com/pawl/distro-seed/stats"
+)
+
+// swarmSeeder is a seeder under test, started through the public API on loopback ports
+type swarmSeeder struct {
+	dir      string
+	peerAddr string
+	seeder   *distroseed.Seeder
+	api      *api.Client
+	stop     func()
+}
+
+// startSwarmSeeder starts a seeder with its data in dir that seeds the swarm's torrent, added
+// from a torrent file like one of its URLs. Peers only reach it directly, as it has no DHT, peer
+// exchange or trackers to find them through.
+func startSwarmSeeder(t *testing.T, swarm *swarmtest.Swarm, dir string) *swarmSeeder {
+	t.Helper()
+	peerPort, apiPort := swarmtest.FreePort(t), swarmtest.FreePort(t)
+	cfg := distroseed.DefaultConfig()
+	cfg.DownloadDir = dir
+	cfg.TorrentURLs = []string{swarmtest.WriteTorrent(t, swarm.Meta, t.TempDir(), "image.torrent")}
+	cfg.PeerPort = peerPort
+	cfg.APIAddr = swarmtest.PeerAddr(apiPort)
+	cfg.DHT, cfg.PEX, cfg.Quiet, cfg.PauseFreeMB = nil, false, true, 0
+	s, err := distroseed.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &swarmSeeder{
+		dir:      dir,
+		peerAddr: swarmtest.PeerAddr(peerPort),
+		seeder:   s,
+		api:      &api.Client{Addr: swarmtest.PeerAddr(apiPort)},
+		stop:     swarmtest.Start(t, s),
+	}
+}
+
+// swarmTorrent is the part of a torrent's listing in the management API the tests check
+type swarmTorrent struct {
+	InfoHash    string     `json:"infohash"`
+	State       string     `json:"state"`
+	Size        int64      `json:"size"`
+	Completed   int64      `json:"completed"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// torrent returns the seeder's listing of the swarm's torrent, once it has been added
+func (s *swarmSeeder) torrent(t *testing.T, swarm *swarmtest.Swarm) swarmTorrent {
+	t.Helper()
+	ih := swarm.Meta.HashInfoBytes().HexString()
+	var listing swarmTorrent
+	swarmtest.Until(t, 10*time.Second, "the seeder to add the torrent", func() bool {
+		var reply struct {
+			Torrents []swarmTorrent `json:"torrents"`
+		}
+		if err := s.api.Do(http.MethodGet, "/api/torrents", nil, &reply); err != nil {
+			return false
+		}
+		i := slices.IndexFunc(reply.Torrents, func(l swarmTorrent) bool { return l.InfoHash == ih })
+		if i < 0 {
+			return false
+		}
+		listing = reply.Torrents[i]
+		return true
+	})
+	return listing
+}
+
+// waitSeeding waits for the seeder to have all of the swarm's torrent and be seeding it
+func (s *swarmSeeder) waitSeeding(t *testing.T, swarm *swarmtest.Swarm) swarmTorrent {
+	t.Helper()
+	var listing swarmTorrent
+	swarmtest.Until(t, 10*time.Second, "the seeder to seed the torrent", func() bool {
+		listing = s.torrent(t, swarm)
+		return listing.Size > 0 && listing.Completed == listing.Size && listing.State == "seeding"
+	})
+	return listing
+}
+
+// lifetimeUpload stops the seeder, which saves what it uploaded since its last status, and
+// returns the upload of the swarm's torrent it saved
+func (s *swarmSeeder) lifetimeUpload(t *testing.T, swarm *swarmtest.Swarm) int64 {
+	t.Helper()
+	s.stop()
+	return stats.LoadLedger(s.dir, stats.NewSessionID()).Lifetime(swarm.Meta.HashInfoBytes().HexString())
+}
+
+func TestSwarmSeedsAndCountsUploads(t *testing.T) {
+	const size = 256 << 10
+	swarm := swarmtest.New(t, "image.iso", size)
+	seeder := startSwarmSeeder(t, swarm, swarm.Dir)
+	seeder.waitSeeding(t, swarm)
+
+	swarmtest.WaitComplete(t, 10*time.Second, swarm.LeechFrom(2, seeder.peerAddr)...)
+
+	lifetime := seeder.lifetimeUpload(t, swarm)
+	if lifetime < 2*size {
+		t.Errorf("uploaded %d bytes to 2 leechers of %d", lifetime, size)
+	}
+	if got := stats.ReadTotalUploaded(filepath.Join(seeder.dir, "seed_stats.txt")); got != lifetime {
+		t.Errorf("stats file has %d bytes uploaded, want %d", got, lifetime)
+	}
+}
+
+func TestSwarmDownloadsThenSeeds(t *testing.T) {
+	swarm := swarmtest.New(t, "image.iso", 256<<10)
+	seeder := startSwarmSeeder(t, swarm, t.TempDir())
+	if listing := seeder.torrent(t, swarm); listing.Completed != 0 {
+		t.Fatalf("seeder started with %d bytes of a torrent it has no data for", listing.Completed)
+	}
+
+	// The source connects to the seeder, which downloads the torrent over that connection
+	source := swarm.Seed()
+	seeding, _ := source.Torrent(swarm.Meta.HashInfoBytes())
+	swarmtest.Connect(seeding, seeder.peerAddr)
+	if listing := seeder.waitSeeding(t, swarm); listing.CompletedAt == nil {
+		t.Error("the completion wasn't recorded")
+	}
+	if _, err := os.Stat(filepath.Join(seeder.dir, "image.iso")); err != nil {
+		t.Errorf("downloaded data isn't in the download directory: %v", err)
+	}
+
+	// What it downloaded, it passes on once the source is gone
+	source.Close()
+	swarmtest.WaitComplete(t, 10*time.Second, swarm.LeechFrom(1, seeder.peerAddr)...)
+}
+
+func TestSwarmRunsSeedersSideBySide(t *testing.T) {
+	const size = 128 << 10
+	swarm := swarmtest.New(t, "image.iso", size)
+	first := startSwarmSeeder(t, swarm, swarm.Dir)
+	second := startSwarmSeeder(t, swarm, t.TempDir())
+	// Peers given to a leecher are dialed once, so both have to be listening first
+	first.waitSeeding(t, swarm)
+	second.torrent(t, swarm)
+
+	// A leecher of the first seeder passes what it gets on to the second, which then serves a
+	// leecher of its own
+	swarmtest.Connect(swarm.LeechFrom(1, first.peerAddr)[0], second.peerAddr)
+	second.waitSeeding(t, swarm)
+	swarmtest.WaitComplete(t, 10*time.Second, swarm.LeechFrom(1, second.peerAddr)...)
+	for _, s := range []*swarmSeeder{first, second} {
+		if got := s.lifetimeUpload(t, swarm); got == 0 {
+			t.Errorf("seeder in %s uploaded nothing", s.dir)
+		}
+	}
+}
+
+func TestSwarmSeederMethods(t *testing.T) {
+	swarm := swarmtest.New(t, "image.iso", 128<<10)
+	seeder := startSwarmSeeder(t, swarm, swarm.Dir)
+	seeder.waitSeeding(t, swarm)
+	ih := swarm.Meta.HashInfoBytes().HexString()
+
+	torrents, err := seeder.seeder.Torrents()
+	if err != nil || len(torrents) != 1 || torrents[0].InfoHash != ih || torrents[0].State != "seeding" {
+		t.Fatalf("Torrents() = %+v, %v", torrents, err)
+	}
+	if st, err := seeder.seeder.Stats(); err != nil || st.Torrents != 1 || st.Seeding != 1 {
+		t.Errorf("Stats() = %+v, %v", st, err)
+	}
+
+	if err := seeder.seeder.RemoveTorrent(ih); err != nil {
+		t.Fatal(err)
+	}
+	if torrents, err := seeder.seeder.Torrents(); err != nil || len(torrents) != 0 {
+		t.Errorf("Torrents() after removing = %+v, %v", torrents, err)
+	}
+	if err := seeder.seeder.RemoveTorrent(ih); err == nil {
+		t.Error("removed a torrent that's gone")
+	}
+
+	added, err := seeder.seeder.AddTorrent(torrents[0].Sources[0])
+	if err != nil || added.InfoHash != ih {
+		t.Fatalf("AddTorrent = %+v, %v", added, err)
+	}
+	seeder.waitSeeding(t, swarm)
+
+	seeder.stop()
+	if _, err := seeder.seeder.Torrents(); !errors.Is(err, distroseed.ErrNotRunning) {
+		t.Errorf("Torrents() once stopped = %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+// Package swarmtest runs swarms over loopback, of in-process torrent clients and the seeders
+// under test, for tests of how torrents are added, seeded, downloaded and counted from end to end.
+package swarmtest
+
+import (
+	"context"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	alog "github.com/anacrolix/log"
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// PieceLength is the piece length of the torrents made by NewMeta, small so tests have several
+const PieceLength = 16 << 10
+
+// NewClient starts a client with its data in dir, and no networking beyond loopback, with any
+// changes to its config made by configure. It's closed when the test ends.
+func NewClient(t testing.TB, dir string, configure ...func(*torrent.ClientConfig)) *torrent.Client {
+	t.Helper()
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = dir
+	cfg.Seed = true
+	cfg.ListenHost = func(string) string { return "127.0.0.1" }
+	cfg.ListenPort = 0
+	cfg.NoDHT = true
+	cfg.DisableIPv6 = true
+	cfg.DisableUTP = true
+	cfg.NoDefaultPortForwarding = true
+	cfg.Logger = alog.Logger{}
+	for _, c := range configure {
+		c(cfg)
+	}
+	client, err := torrent.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// NewMeta writes a file of random data named name in dir and returns its torrent
+func NewMeta(t testing.TB, dir, name string, size int64) *metainfo.MetaInfo {
+	t.Helper()
+	data := make([]byte, size)
+	rand.Read(data)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: PieceLength}
+	if err := info.BuildFromFilePath(path); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &metainfo.MetaInfo{InfoBytes: infoBytes}
+}
+
+// WriteTorrent writes a torrent file named name in dir and returns its path
+func WriteTorrent(t testing.TB, meta *metainfo.MetaInfo, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := meta.Write(f); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Swarm is a torrent shared by in-process clients, each with its own data directory
+type Swarm struct {
+	t    testing.TB
+	Meta *metainfo.MetaInfo
+	Dir  string // Where the torrent's data was written
+}
+
+// New makes a torrent of random data named name for a swarm to share
+func New(t testing.TB, name string, size int64) *Swarm {
+	t.Helper()
+	dir := t.TempDir()
+	return &Swarm{t: t, Meta: NewMeta(t, dir, name, size), Dir: dir}
+}
+
+// Seed starts a client that seeds the torrent from the swarm's data
+func (s *Swarm) Seed(configure ...func(*torrent.ClientConfig)) *torrent.Client {
+	s.t.Helper()
+	client := NewClient(s.t, s.Dir, configure...)
+	tt, err := client.AddTorrent(s.Meta)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	if err := tt.VerifyData(); err != nil {
+		s.t.Fatal(err)
+	}
+	// Pieces are marked complete as their hashes are checked, which can lag VerifyData returning
+	Until(s.t, 5*time.Second, tt.Name()+" to be verified", tt.Complete().Bool)
+	return client
+}
+
+// Leech starts n clients with no data that download the whole torrent from the peer clients
+func (s *Swarm) Leech(n int, peers ...*torrent.Client) []*torrent.Torrent {
+	s.t.Helper()
+	var leeching []*torrent.Torrent
+	for range n {
+		tt := s.leecher()
+		for _, peer := range peers {
+			tt.AddClientPeer(peer)
+		}
+		leeching = append(leeching, tt)
+	}
+	return leeching
+}
+
+// LeechFrom starts n clients with no data that download the whole torrent from the peers at
+// addrs, like seeders listening on PeerAddr
+func (s *Swarm) LeechFrom(n int, addrs ...string) []*torrent.Torrent {
+	s.t.Helper()
+	var leeching []*torrent.Torrent
+	for range n {
+		tt := s.leecher()
+		Connect(tt, addrs...)
+		leeching = append(leeching, tt)
+	}
+	return leeching
+}
+
+func (s *Swarm) leecher() *torrent.Torrent {
+	s.t.Helper()
+	tt, err := NewClient(s.t, s.t.TempDir()).AddTorrent(s.Meta)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	tt.DownloadAll()
+	return tt
+}
+
+// Connect has t connect to the peers at addrs, which can then download from it as well as
+// upload to it
+func Connect(t *torrent.Torrent, addrs ...string) {
+	peers := make([]torrent.PeerInfo, len(addrs))
+	for i, addr := range addrs {
+		peers[i] = torrent.PeerInfo{Addr: torrent.StringAddr(addr), Trusted: true}
+	}
+	t.AddPeers(peers)
+}
+
+// FreePort returns a TCP port nothing is listening on, for a seeder under test to listen on
+func FreePort(t testing.TB) int {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+// PeerAddr returns the loopback address of a seeder listening for peers on port
+func PeerAddr(port int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
+
+// Runner is a seeder under test, which runs until ctx is done
+type Runner interface {
+	Run(ctx context.Context) error
+}
+
+// Start runs r until the returned stop is called or the test ends, and fails the test if r
+// stops with an error. Stop waits for r to return, so what it saves on the way out can be checked.
+func Start(t testing.TB, r Runner) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Run(ctx) }()
+	stop = sync.OnceFunc(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("seeder stopped with %v", err)
+		}
+	})
+	t.Cleanup(stop)
+	return stop
+}
+
+// WaitComplete waits for the torrents to have all their data
+func WaitComplete(t testing.TB, timeout time.Duration, ts ...*torrent.Torrent) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for _, tt := range ts {
+		for !tt.Complete().Bool() {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s downloaded %d of %d bytes in %s", tt.Name(), tt.BytesCompleted(), tt.Length(), timeout)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// Until waits for cond to hold, and fails the test if it doesn't in time, saying what it waited for
+func Until(t testing.TB, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(timeout); !cond(); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out after %s waiting for %s", timeout, what)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package distroseed
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// layoutPlaceholders are what can go in a layout template
+var layoutPlaceholders = []string{"distro", "version", "arch", "variant", "label", "name", "infohash"}
+
+// validateLayout checks a layout template is a relative path ending in the torrent's {name}
+func validateLayout(layout string) error {
+	if layout == "" {
+		return nil
+	}
+	if filepath.IsAbs(layout) {
+		return fmt.Errorf("❌ Layout '%s' must be relative to the data directories", layout)
+	}
+	parts := strings.Split(filepath.ToSlash(layout), "/")
+	if parts[len(parts)-1] != "{name}" {
+		return errors.New("❌ Layout must end with /{name}, where the torrent's file or directory goes")
+	}
+	if slices.Contains(parts, "..") {
+		return fmt.Errorf("❌ Layout '%s' can't go outside the data directories", layout)
+	}
+	for _, m := range exportPlaceholder.FindAllStringSubmatch(layout, -1) {
+		if !slices.Contains(layoutPlaceholders, m[1]) {
+			return fmt.Errorf("❌ Unknown placeholder {%s} in layout, use one of {%s}", m[1], strings.Join(layoutPlaceholders, "}, {"))
+		}
+	}
+	return nil
+}
+
+// layoutDir fills in the directories of the layout template for a torrent, leaving out the
+// {name} at the end, which the file storage adds. The release's fields come from releaseOf, and
+// are "unknown" when it can't tell them.
+func layoutDir(layout, name, infoHash string, labels []string) string {
+	if layout == "" {
+		return ""
+	}
+	release := releaseOf(name, labels)
+	values := map[string]string{
+		"distro":   cmp.Or(release.Distro, "unknown"),
+		"version":  cmp.Or(release.Version, "unknown"),
+		"arch":     cmp.Or(release.Arch, "unknown"),
+		"variant":  cmp.Or(release.Variant, "unknown"),
+		"label":    "unlabeled",
+		"name":     name,
+		"infohash": infoHash,
+	}
+	if i := slices.IndexFunc(labels, func(label string) bool { return !isReleaseLabel(label) }); i >= 0 {
+		values["label"] = labels[i]
+	}
+	dir, _ := strings.CutSuffix(filepath.ToSlash(layout), "{name}")
+	dir = exportPlaceholder.ReplaceAllStringFunc(dir, func(m string) string {
+		return pathSafe(values[m[1:len(m)-1]])
+	})
+	return filepath.Clean(filepath.FromSlash(dir))
+}
@@ -0,0 +1,45 @@
+package distroseed
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestLayoutDir(t *testing.T) {
+	tests := []struct {
+		layout, name string
+		labels       []string
+		want         string
+	}{
+		{"", "ubuntu-24.04.1-desktop-amd64.iso", nil, ""},
+		{"{distro}/{version}/{name}", "ubuntu-24.04.1-desktop-amd64.iso", nil, "ubuntu/24.04.1"},
+		{"{distro}/{version}/{name}", "Rocky-9.4-x86_64-dvd.iso", nil, "rocky/9.4"},
+		{"{distro}/{version}/{name}", "archlinux-x86_64.iso", []string{"distro:arch", "version:2024.10.01"}, "arch/2024.10.01"},
+		{"{distro}/{version}/{name}", "netboot.tar.gz", nil, "netboot/unknown"},
+		{"{arch}/{variant}/{name}", "debian-12.7.0-amd64-netinst.iso", nil, "amd64/netinst"},
+		{"{label}/{name}", "debian-12.7.0-amd64-netinst.iso", []string{"distro:debian", "mirrors", "stable"}, "mirrors"},
+		{"{label}/{name}", "debian-12.7.0-amd64-netinst.iso", []string{"../.."}, ".._.."},
+		{"{name}", "debian-12.7.0-amd64-netinst.iso", nil, "."},
+	}
+	for _, tt := range tests {
+		if got := layoutDir(tt.layout, tt.name, "0123", tt.labels); got != filepath.FromSlash(tt.want) {
+			t.Errorf("layoutDir(%s, %s, %v) = %s, want %s", tt.layout, tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestValidateLayout(t *testing.T) {
+	for layout, ok := range map[string]bool{
+		"":                          true,
+		"{distro}/{version}/{name}": true,
+		"{label}/{infohash}/{name}": true,
+		"{distro}/{version}":        false,
+		"/srv/{name}":               false,
+		"../{name}":                 false,
+		"{codename}/{name}":         false,
+	} {
+		if err := validateLayout(layout); (err == nil) != ok {
+			t.Errorf("validateLayout(%q) = %v, want ok %v", layout, err, ok)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package distroseed
+
+import (
+	"log"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+const leechShareWeight = 4 // Times the usual share of the upload limit a downloading torrent gets, so peers reciprocate
+
+// leechBoost favors torrents until they're downloaded: they get the most connections a torrent
+// may have and a bigger share of the upload limit, which peers pay back with data. Once complete,
+// they're seeded like the others.
+type leechBoost struct {
+	seeder  *Seeder
+	mu      sync.Mutex
+	boosted map[string]bool // By infohash
+}
+
+func newLeechBoost(s *Seeder) *leechBoost {
+	return &leechBoost{seeder: s, boosted: make(map[string]bool)}
+}
+
+// update favors the torrents still downloading, including magnets still fetching metadata,
+// logging the ones that start and stop being favored. Torrents held back from downloading,
+// paused, out of disk space or queued aren't favored until they're let through.
+func (b *leechBoost) update(torrents []*torrent.Torrent) {
+	if b == nil {
+		return
+	}
+	boosted := make(map[string]bool)
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		if b.seeder.uploadOnly.Held(ih) || b.seeder.pauses.IsPaused(ih) || b.seeder.diskPauses.IsPaused(ih) || b.seeder.queue.IsQueued(ih) {
+			continue
+		}
+		if t.Info() == nil || !b.seeder.downloadComplete(t) {
+			boosted[ih] = true
+		}
+	}
+
+	b.mu.Lock()
+	previous := b.boosted
+	b.boosted = boosted
+	b.mu.Unlock()
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		switch {
+		case boosted[ih] && !previous[ih]:
+			log.Printf("⚡ Prioritizing %s until it's downloaded", t.Name())
+		case previous[ih] && !boosted[ih] && t.Info() != nil && b.seeder.downloadComplete(t):
+			log.Printf("🌱 %s is downloaded, seeding it like the others", t.Name())
+		}
+	}
+}
+
+// Boosted reports whether a torrent is favored while it downloads
+func (b *leechBoost) Boosted(infoHash string) bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.boosted[infoHash]
+}
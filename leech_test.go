@@ -0,0 +1,43 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeechBoost(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	s := newTestSeeder(t, cfg)
+	seeding := addSeedingTestTorrent(t, client, dir, "seeding.iso")
+	// The data is written somewhere the client doesn't look, so it has to download it
+	downloading, err := client.AddTorrent(newTestMeta(t, t.TempDir(), "downloading.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.leechBoosts = newLeechBoost(s)
+
+	s.leechBoosts.update(client.Torrents())
+	if !s.leechBoosts.Boosted(downloading.InfoHash().HexString()) || s.leechBoosts.Boosted(seeding.InfoHash().HexString()) {
+		t.Fatal("want only the downloading torrent favored")
+	}
+
+	// It gets the most connections a torrent may have, while the seed keeps its usual share
+	now := time.Now()
+	lastLeechers := map[string]time.Time{seeding.InfoHash().HexString(): now, downloading.InfoHash().HexString(): now}
+	limits := make(map[string]int)
+	s.rebalanceConnectionSlots(client, cfg, cfg.ConnsPerTorrent, lastLeechers, limits)
+	if got := limits[downloading.InfoHash().HexString()]; got != cfg.MaxConnsPerTorrent {
+		t.Errorf("downloading torrent limited to %d connections, want the max of %d", got, cfg.MaxConnsPerTorrent)
+	}
+	if got := limits[seeding.InfoHash().HexString()]; got != cfg.ConnsPerTorrent {
+		t.Errorf("seeding torrent limited to %d connections, want %d", got, cfg.ConnsPerTorrent)
+	}
+
+	var nilBoost *leechBoost
+	nilBoost.update(client.Torrents())
+	if nilBoost.Boosted(downloading.InfoHash().HexString()) {
+		t.Error("a torrent was favored with -leech-priority off")
+	}
+}
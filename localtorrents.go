@@ -0,0 +1,88 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// localTorrentPath returns the path of a torrent file or directory of them, given as a plain
+// path or a file:// URL
+func localTorrentPath(url string) (string, bool) {
+	if path, ok := strings.CutPrefix(url, "file://"); ok {
+		return path, true
+	}
+	return url, !strings.Contains(url, "://")
+}
+
+// localTorrentDirs returns the URLs that are directories of torrent files
+func localTorrentDirs(urls []string) []string {
+	var dirs []string
+	for _, url := range urls {
+		if path, ok := localTorrentPath(url); ok {
+			if info, err := os.Stat(path); err == nil && info.IsDir() {
+				dirs = append(dirs, url)
+			}
+		}
+	}
+	return dirs
+}
+
+// addLocalTorrents adds a torrent file, or the torrent files in a directory. For a directory
+// that's been added before, torrents whose files have gone are removed.
+func (s *Seeder) addLocalTorrents(ctx context.Context, client *torrent.Client, url, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("❌ Failed to open torrent file: %w", err)
+	}
+	if !info.IsDir() {
+		t, err := s.addLocalTorrentFile(ctx, client, url, path)
+		if err != nil {
+			return err
+		}
+		s.torrentSources.Add(url, t)
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, "*.torrent"))
+	if err != nil {
+		return err
+	}
+	var ts []*torrent.Torrent
+	for _, file := range files {
+		t, err := s.addLocalTorrentFile(ctx, client, url, file)
+		if err != nil {
+			log.Printf("⚠️ Error adding torrent file '%s': %v", file, err)
+			continue
+		}
+		ts = append(ts, t)
+	}
+	s.removeTorrents(s.torrentSources.Replace(url, ts))
+	return nil
+}
+
+// addLocalTorrentFile adds the torrent file at path for the source url, unless it's loaded already
+func (s *Seeder) addLocalTorrentFile(ctx context.Context, client *torrent.Client, url, path string) (*torrent.Torrent, error) {
+	meta, err := metainfo.LoadFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to load torrent metadata: %w", err)
+	}
+	t, err := s.addTorrentMeta(client, meta, url)
+	if err != nil {
+		return nil, err
+	}
+	if s.addedAlready(t, url) {
+		return t, nil
+	}
+	log.Printf("📂 Added torrent file: %s", path)
+	s.registry.Record(t.InfoHash().HexString(), url, "")
+	s.events.Record(t.InfoHash().HexString(), eventAdded, url)
+	s.goTorrentTask(ctx, t, "seeding", func(ctx context.Context) { s.seedTorrent(ctx, client, t) })
+	return t, nil
+}
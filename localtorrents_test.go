@@ -0,0 +1,76 @@
+package distroseed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalTorrentPath(t *testing.T) {
+	tests := []struct {
+		url   string
+		path  string
+		local bool
+	}{
+		{url: "/srv/torrents", path: "/srv/torrents", local: true},
+		{url: "file:///srv/torrents/a.torrent", path: "/srv/torrents/a.torrent", local: true},
+		{url: "torrents/a.torrent", path: "torrents/a.torrent", local: true},
+		{url: "https://example.com/a.torrent", path: "https://example.com/a.torrent"},
+	}
+	for _, tt := range tests {
+		if path, local := localTorrentPath(tt.url); path != tt.path || local != tt.local {
+			t.Errorf("localTorrentPath(%q) = %q, %v, want %q, %v", tt.url, path, local, tt.path, tt.local)
+		}
+	}
+}
+
+func TestAddLocalTorrentsRescansDirectories(t *testing.T) {
+	dataDir, torrentDir := t.TempDir(), t.TempDir()
+	s := newTestSeeder(t, runtimeConfig{})
+	setTestSeederState(s, dataDir)
+	client := newTestClient(t, dataDir)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	for _, name := range []string{"a.iso", "b.iso"} {
+		f, err := os.Create(filepath.Join(torrentDir, name+".torrent"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := newTestMeta(t, dataDir, name, 32<<10).Write(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	if got := localTorrentDirs([]string{torrentDir, filepath.Join(torrentDir, "a.iso.torrent"), "https://example.com/a.torrent"}); len(got) != 1 || got[0] != torrentDir {
+		t.Errorf("localTorrentDirs = %v, want [%s]", got, torrentDir)
+	}
+
+	if err := s.addLocalTorrents(ctx, client, torrentDir, torrentDir); err != nil {
+		t.Fatal(err)
+	}
+	waitForSeedTorrents(t, s)
+	if got := len(client.Torrents()); got != 2 {
+		t.Fatalf("%d torrents added from the directory, want 2", got)
+	}
+	for _, tor := range client.Torrents() {
+		if urls := s.torrentSources.URLs(tor); len(urls) != 1 || urls[0] != torrentDir {
+			t.Errorf("%s has sources %v, want [%s]", tor.Name(), urls, torrentDir)
+		}
+	}
+
+	if err := os.Remove(filepath.Join(torrentDir, "a.iso.torrent")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.addLocalTorrents(ctx, client, torrentDir, torrentDir); err != nil {
+		t.Fatal(err)
+	}
+	if ts := client.Torrents(); len(ts) != 1 || ts[0].Name() != "b.iso" {
+		t.Errorf("after a rescan, %d torrents are loaded, want only b.iso", len(ts))
+	}
+
+	if err := s.addLocalTorrents(ctx, client, "missing", filepath.Join(torrentDir, "missing.torrent")); err == nil {
+		t.Error("addLocalTorrents succeeded for a missing file")
+	}
+}
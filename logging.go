@@ -0,0 +1,162 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Subsystems of the torrent client that -verbose can turn on debug logging for
+var logSubsystems = []string{"tracker", "dht", "peer", "client"}
+
+// verboseFlag holds the subsystems to log debug messages from. It's given as -verbose for all of
+// them, or as -verbose=dht,tracker for some.
+type verboseFlag map[string]bool
+
+func (v verboseFlag) String() string {
+	var names []string
+	for _, s := range logSubsystems {
+		if v[s] {
+			names = append(names, s)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+func (v verboseFlag) Set(value string) error {
+	clear(v)
+	switch value {
+	case "", "false":
+		return nil
+	case "true", "all":
+		for _, s := range logSubsystems {
+			v[s] = true
+		}
+		return nil
+	}
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		if !slices.Contains(logSubsystems, s) {
+			return fmt.Errorf("unknown subsystem %q, use %s or all", s, strings.Join(logSubsystems, ", "))
+		}
+		v[s] = true
+	}
+	return nil
+}
+
+// IsBoolFlag lets -verbose be given without a value
+func (v verboseFlag) IsBoolFlag() bool {
+	return true
+}
+
+// verboseSubsystems returns the subsystems named in a config as a verboseFlag, ignoring unknown
+// ones, which the config's validation reports
+func verboseSubsystems(names []string) verboseFlag {
+	v := verboseFlag{}
+	v.Set(strings.Join(names, ","))
+	return v
+}
+
+// verboseList is the -verbose flag, setting the subsystems listed in a config
+type verboseList struct{ subsystems *[]string }
+
+func (l verboseList) String() string {
+	if l.subsystems == nil {
+		return ""
+	}
+	return strings.Join(*l.subsystems, ",")
+}
+
+func (l verboseList) Set(value string) error {
+	v := verboseFlag{}
+	if err := v.Set(value); err != nil {
+		return err
+	}
+	*l.subsystems = nil
+	for _, s := range logSubsystems {
+		if v[s] {
+			*l.subsystems = append(*l.subsystems, s)
+		}
+	}
+	return nil
+}
+
+func (l verboseList) IsBoolFlag() bool {
+	return true
+}
+
+// subsystemLogHandler passes on the client's records at warning level and above, and debug and
+// info records only from the verbose subsystems
+type subsystemLogHandler struct {
+	next      slog.Handler
+	verbose   verboseFlag
+	subsystem string // Known from the logger's attributes, otherwise worked out per record
+}
+
+func (h subsystemLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return (level >= slog.LevelWarn || len(h.verbose) > 0) && h.next.Enabled(ctx, level)
+}
+
+func (h subsystemLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelWarn && !h.verbose[h.subsystemOf(r)] {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h subsystemLogHandler) subsystemOf(r slog.Record) string {
+	if h.subsystem != "" {
+		return h.subsystem
+	}
+	var names []string
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "names" {
+			names, _ = a.Value.Any().([]string)
+		}
+		return true
+	})
+	var function string
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		function = frame.Function
+	}
+	return logSubsystem(function, names)
+}
+
+// logSubsystem sorts a record into a subsystem by the function that logged it and the names of
+// the logger it was logged with
+func logSubsystem(function string, names []string) string {
+	switch {
+	case slices.Contains(names, "dht") || strings.Contains(function, "anacrolix/dht"):
+		return "dht"
+	case strings.Contains(function, "/tracker") || strings.Contains(function, "webtorrent") ||
+		strings.Contains(strings.ToLower(function), "announce"):
+		return "tracker"
+	case strings.Contains(function, "peer_protocol") || strings.Contains(function, "Peer") ||
+		strings.Contains(function, "pex"):
+		return "peer"
+	default:
+		return "client"
+	}
+}
+
+func (h subsystemLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for _, a := range attrs {
+		switch {
+		case a.Key == "urlKey": // Tracker announcers
+			h.subsystem = "tracker"
+		case strings.Contains(a.Key, "PeerConn") || a.Key == "webseed":
+			h.subsystem = "peer"
+		}
+	}
+	h.next = h.next.WithAttrs(attrs)
+	return h
+}
+
+func (h subsystemLogHandler) WithGroup(name string) slog.Handler {
+	h.next = h.next.WithGroup(name)
+	return h
+}
@@ -0,0 +1,63 @@
+package distroseed
+
+import (
+	"bytes"
+	"flag"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestVerboseFlag(t *testing.T) {
+	v := verboseFlag{}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Var(v, "verbose", "")
+	if err := fs.Parse([]string{"-verbose"}); err != nil || v.String() != "tracker,dht,peer,client" {
+		t.Errorf("-verbose = %q, %v, want every subsystem", v, err)
+	}
+	if err := fs.Parse([]string{"-verbose=dht, tracker"}); err != nil || v.String() != "tracker,dht" {
+		t.Errorf("-verbose=dht,tracker = %q, %v", v, err)
+	}
+	if err := v.Set("disk"); err == nil {
+		t.Error("unknown subsystem accepted")
+	}
+}
+
+func TestSubsystemLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	out := slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+	logger := slog.New(subsystemLogHandler{next: out, verbose: verboseFlag{"dht": true}})
+
+	logger.Debug("dht debug", "names", []string{"dht", "0.0.0.0:6881"})
+	logger.With("name", "tracker", "urlKey", "http://tracker.example.com/announce").Debug("tracker debug")
+	logger.With("*torrent.PeerConn", "0xc000123").Info("peer info")
+	logger.With("urlKey", "http://tracker.example.com/announce").Warn("tracker warning")
+
+	got := buf.String()
+	for _, want := range []string{"dht debug", "tracker warning"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q wasn't logged:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"tracker debug", "peer info"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("%q was logged without its subsystem being verbose:\n%s", unwanted, got)
+		}
+	}
+}
+
+func TestLogSubsystem(t *testing.T) {
+	for _, tt := range []struct {
+		function string
+		want     string
+	}{
+		{"github.com/anacrolix/dht/v2.(*Server).announce", "dht"},
+		{"github.com/anacrolix/torrent/tracker/http.announce", "tracker"},
+		{"github.com/anacrolix/torrent.(*PeerConn).mainReadLoop", "peer"},
+		{"github.com/anacrolix/torrent.(*Torrent).pieceHashed", "client"},
+	} {
+		if got := logSubsystem(tt.function, nil); got != tt.want {
+			t.Errorf("logSubsystem(%q) = %q, want %q", tt.function, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package distroseed
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const (
+	lsdPort             = 6771
+	lsdAnnounceInterval = 5 * time.Minute // How often each torrent is announced, as BEP 14 suggests
+	lsdCheckInterval    = time.Minute     // How soon newly added torrents are announced
+	lsdMaxMessage       = 1400            // Announces are split to fit in a packet
+)
+
+// Peers found through Local Service Discovery
+const peerSourceLSD torrent.PeerSource = "Lsd"
+
+// The multicast groups of BEP 14, by network
+var lsdGroups = map[string]*net.UDPAddr{
+	"udp4": {IP: net.IPv4(239, 192, 152, 143), Port: lsdPort},
+	"udp6": {IP: net.ParseIP("ff15::efc0:988f"), Port: lsdPort},
+}
+
+// localDiscovery announces torrents to the LAN with BEP 14 Local Service Discovery and adds the
+// peers that announce the same torrents, so machines on one network find each other without
+// trackers or the DHT. Private torrents aren't announced or joined, as their swarms are limited
+// to what their trackers hand out.
+type localDiscovery struct {
+	seeder *Seeder
+	port   int    // Peer port announced
+	cookie string // Identifies our own announces, which are looped back
+}
+
+func newLocalDiscovery(s *Seeder, port int) *localDiscovery {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &localDiscovery{seeder: s, port: port, cookie: hex.EncodeToString(b)}
+}
+
+// run joins the multicast groups of the networks available, announcing and listening on each
+func (d *localDiscovery) run(ctx context.Context, client *torrent.Client) {
+	for _, network := range []string{"udp4", "udp6"} {
+		group := lsdGroups[network]
+		conn, err := net.ListenMulticastUDP(network, nil, group)
+		if err != nil {
+			log.Printf("⚠️ Local peer discovery unavailable over %s: %v", network, err)
+			continue
+		}
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+		go d.listen(conn, client)
+		go d.announceLoop(ctx, conn, group, client)
+	}
+}
+
+func (d *localDiscovery) announceLoop(ctx context.Context, conn *net.UDPConn, group *net.UDPAddr, client *torrent.Client) {
+	ticker := time.NewTicker(lsdCheckInterval)
+	defer ticker.Stop()
+	announced := make(map[string]time.Time) // When each torrent was last announced, by infohash
+	for {
+		for _, msg := range d.announcements(group, d.seeder.lsdDue(client.Torrents(), announced, time.Now())) {
+			if _, err := conn.WriteToUDP(msg, group); err != nil {
+				log.Printf("⚠️ Could not announce to the local network: %v", err)
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// lsdDue returns the infohashes of the torrents to announce now, marking them announced and
+// forgetting the torrents that were dropped
+func (s *Seeder) lsdDue(torrents []*torrent.Torrent, announced map[string]time.Time, now time.Time) []string {
+	if s.schedule.Paused() {
+		return nil
+	}
+	var due []string
+	current := make(map[string]bool, len(torrents))
+	for _, t := range torrents {
+		ih := t.InfoHash().HexString()
+		current[ih] = true
+		if !lsdAllowed(t) || s.uploadOnly.Held(ih) || s.pauses.IsPaused(ih) || now.Sub(announced[ih]) < lsdAnnounceInterval {
+			continue
+		}
+		announced[ih] = now
+		due = append(due, ih)
+	}
+	for ih := range announced {
+		if !current[ih] {
+			delete(announced, ih)
+		}
+	}
+	return due
+}
+
+// lsdAllowed reports whether a torrent may be announced and joined on the LAN, which private
+// torrents can't once their info is known
+func lsdAllowed(t *torrent.Torrent) bool {
+	return !isPrivate(t)
+}
+
+// announcements builds the BT-SEARCH messages announcing the infohashes to a group, as many to a
+// message as fit
+func (d *localDiscovery) announcements(group *net.UDPAddr, infohashes []string) [][]byte {
+	header := fmt.Sprintf("BT-SEARCH * HTTP/1.1\r\nHost: %s\r\nPort: %d\r\ncookie: %s\r\n", group, d.port, d.cookie)
+	var msgs [][]byte
+	var b bytes.Buffer
+	for _, ih := range infohashes {
+		line := fmt.Sprintf("Infohash: %s\r\n", ih)
+		if b.Len() > 0 && b.Len()+len(line)+4 > lsdMaxMessage {
+			b.WriteString("\r\n\r\n")
+			msgs = append(msgs, bytes.Clone(b.Bytes()))
+			b.Reset()
+		}
+		if b.Len() == 0 {
+			b.WriteString(header)
+		}
+		b.WriteString(line)
+	}
+	if b.Len() > 0 {
+		b.WriteString("\r\n\r\n")
+		msgs = append(msgs, b.Bytes())
+	}
+	return msgs
+}
+
+func (d *localDiscovery) listen(conn *net.UDPConn, client *torrent.Client) {
+	buf := make([]byte, 64<<10)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("⚠️ Stopped listening for local peers: %v", err)
+			}
+			return
+		}
+		port, infohashes, ok := d.parseAnnouncement(buf[:n])
+		if !ok || d.seeder.schedule.Paused() {
+			continue
+		}
+		peer := torrent.PeerInfo{Addr: &net.TCPAddr{IP: from.IP, Port: port}, Source: peerSourceLSD}
+		for _, ih := range infohashes {
+			t, ok := client.Torrent(ih)
+			if ok && lsdAllowed(t) && !d.seeder.uploadOnly.Held(ih.HexString()) && !d.seeder.pauses.IsPaused(ih.HexString()) {
+				t.AddPeers([]torrent.PeerInfo{peer})
+			}
+		}
+	}
+}
+
+// parseAnnouncement reads a BT-SEARCH message, returning the announcing peer's port and
+// infohashes. Our own announces and malformed ones aren't ok.
+func (d *localDiscovery) parseAnnouncement(msg []byte) (port int, infohashes []metainfo.Hash, ok bool) {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(msg)))
+	if err != nil || req.Method != "BT-SEARCH" {
+		return 0, nil, false
+	}
+	if req.Header.Get("Cookie") == d.cookie {
+		return 0, nil, false
+	}
+	port, err = strconv.Atoi(req.Header.Get("Port"))
+	if err != nil || port <= 0 || port > 65535 {
+		return 0, nil, false
+	}
+	for _, value := range req.Header.Values("Infohash") {
+		var ih metainfo.Hash
+		if err := ih.FromHexString(strings.TrimSpace(value)); err == nil {
+			infohashes = append(infohashes, ih)
+		}
+	}
+	return port, infohashes, len(infohashes) > 0
+}
@@ -0,0 +1,77 @@
+package distroseed
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLocalDiscoveryAnnouncements(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	ours := newLocalDiscovery(s, 42000)
+	var infohashes []string
+	for i := range 30 {
+		infohashes = append(infohashes, strings.Repeat(string("0123456789abcdef"[i%16]), 40))
+	}
+	msgs := ours.announcements(lsdGroups["udp4"], infohashes)
+	if len(msgs) < 2 {
+		t.Fatalf("30 infohashes fit in %d message", len(msgs))
+	}
+
+	theirs := newLocalDiscovery(s, 42001)
+	var parsed int
+	for _, msg := range msgs {
+		if len(msg) > lsdMaxMessage {
+			t.Errorf("message of %d bytes", len(msg))
+		}
+		if !strings.HasPrefix(string(msg), "BT-SEARCH * HTTP/1.1\r\nHost: 239.192.152.143:6771\r\n") {
+			t.Errorf("message starts %q", msg[:40])
+		}
+		port, ihs, ok := theirs.parseAnnouncement(msg)
+		if !ok || port != 42000 {
+			t.Fatalf("announcement parsed as port %d, ok %v", port, ok)
+		}
+		parsed += len(ihs)
+		if _, _, ok := ours.parseAnnouncement(msg); ok {
+			t.Error("our own announcement was accepted")
+		}
+	}
+	if parsed != len(infohashes) {
+		t.Errorf("%d infohashes parsed, want %d", parsed, len(infohashes))
+	}
+
+	for _, bad := range []string{
+		"BT-SEARCH * HTTP/1.1\r\nHost: 239.192.152.143:6771\r\nInfohash: " + infohashes[0] + "\r\n\r\n\r\n",
+		"BT-SEARCH * HTTP/1.1\r\nPort: 6881\r\nInfohash: nothex\r\n\r\n\r\n",
+		"M-SEARCH * HTTP/1.1\r\nPort: 6881\r\nInfohash: " + infohashes[0] + "\r\n\r\n\r\n",
+	} {
+		if _, _, ok := theirs.parseAnnouncement([]byte(bad)); ok {
+			t.Errorf("%q was accepted", bad)
+		}
+	}
+}
+
+func TestLocalDiscoveryDue(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	resetTestState(s, testConfig())
+	client := newTestClient(t, dir)
+	tt := addSeedingTestTorrent(t, client, dir, "a.iso")
+	ih := tt.InfoHash().HexString()
+
+	announced := map[string]time.Time{"dropped": {}}
+	now := time.Now()
+	if due := s.lsdDue(client.Torrents(), announced, now); len(due) != 1 || due[0] != ih {
+		t.Errorf("due %v, want %s", due, ih)
+	}
+	if _, ok := announced["dropped"]; ok {
+		t.Error("dropped torrent wasn't forgotten")
+	}
+	if due := s.lsdDue(client.Torrents(), announced, now.Add(time.Minute)); len(due) != 0 {
+		t.Errorf("announced again a minute later: %v", due)
+	}
+	if due := s.lsdDue(client.Torrents(), announced, now.Add(lsdAnnounceInterval)); len(due) != 1 {
+		t.Errorf("not announced again after %s", lsdAnnounceInterval)
+	}
+}
@@ -0,0 +1,86 @@
+package distroseed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/api"
+)
+
+// exportMetainfo rebuilds a torrent's metainfo as it's seeded now, with the trackers and webseeds
+// it has picked up since it was added, so other seeders can join the same swarms
+func exportMetainfo(t *torrent.Torrent) metainfo.MetaInfo {
+	mi := t.Metainfo()
+	mi.CreatedBy = "distro-seed"
+	mi.Comment = ""
+	if len(mi.AnnounceList) > 0 && len(mi.AnnounceList[0]) > 0 {
+		// For clients that don't read the announce list
+		mi.Announce = mi.AnnounceList[0][0]
+	}
+	slices.Sort(mi.UrlList)
+	return mi
+}
+
+// torrentMagnet is the magnet link of a torrent, with its trackers and webseeds. Torrents still
+// fetching their metadata have no name in it.
+func torrentMagnet(t *torrent.Torrent) string {
+	ih := t.InfoHash()
+	mi := exportMetainfo(t)
+	return mi.Magnet(&ih, t.Info()).String()
+}
+
+// apiTorrent finds the torrent a request's path names, writing the error if there isn't one
+func (a *apiServer) apiTorrent(w http.ResponseWriter, r *http.Request) (*torrent.Torrent, bool) {
+	var ih metainfo.Hash
+	if err := ih.FromHexString(r.PathValue("infohash")); err != nil {
+		api.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid infohash '%s'", r.PathValue("infohash")))
+		return nil, false
+	}
+	t, ok := a.client.Torrent(ih)
+	if !ok {
+		api.WriteError(w, http.StatusNotFound, fmt.Errorf("no torrent %s", ih.HexString()))
+		return nil, false
+	}
+	return t, true
+}
+
+// getMagnet returns a torrent's magnet link
+func (a *apiServer) getMagnet(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.apiTorrent(w, r)
+	if !ok {
+		return
+	}
+	api.WriteJSON(w, http.StatusOK, struct {
+		InfoHash string `json:"infohash"`
+		Name     string `json:"name"`
+		Magnet   string `json:"magnet"`
+	}{t.InfoHash().HexString(), t.Name(), torrentMagnet(t)})
+}
+
+// getTorrentFile serves a torrent's .torrent file, once its metadata is known
+func (a *apiServer) getTorrentFile(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.apiTorrent(w, r)
+	if !ok {
+		return
+	}
+	if t.Info() == nil {
+		api.WriteError(w, http.StatusConflict, errors.New("the torrent's metadata isn't known yet"))
+		return
+	}
+	writeTorrentFile(w, t)
+}
+
+// writeTorrentFile sends a torrent's metainfo as a .torrent download
+func writeTorrentFile(w http.ResponseWriter, t *torrent.Torrent) {
+	w.Header().Set("Content-Type", "application/x-bittorrent")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", pathSafe(t.Info().BestName())+".torrent"))
+	mi := exportMetainfo(t)
+	if err := mi.Write(w); err != nil {
+		log.Printf("⚠️ Couldn't write the torrent of %s: %v", t.Name(), err)
+	}
+}
@@ -0,0 +1,69 @@
+package distroseed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestExportMagnetAndTorrentFile(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, dir, "image.iso", 64<<10)
+	meta.AnnounceList = [][]string{{"http://tracker.invalid/announce"}}
+	seeding, err := client.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Picked up after the torrent was added, like from a source's webseeds
+	seeding.AddWebSeeds([]string{"https://mirror.invalid/"})
+	fetching, err := client.AddMagnet("magnet:?xt=urn:btih:" + strings.Repeat("ab", 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client}
+	handler := api.handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	ih := seeding.InfoHash().HexString()
+	w := get("/api/torrents/" + ih + "/magnet")
+	body := w.Body.String()
+	for _, want := range []string{"xt=urn:btih:" + ih, "dn=image.iso", "tr=http%3A%2F%2Ftracker.invalid%2Fannounce", "ws=https%3A%2F%2Fmirror.invalid%2F"} {
+		if w.Code != http.StatusOK || !strings.Contains(body, want) {
+			t.Errorf("magnet: status %d, missing %s in %s", w.Code, want, body)
+		}
+	}
+
+	w = get("/api/torrents/" + ih + "/torrent")
+	mi, err := metainfo.Load(w.Body)
+	if err != nil {
+		t.Fatalf("torrent file: status %d, %v", w.Code, err)
+	}
+	if mi.HashInfoBytes().HexString() != ih || mi.Announce != "http://tracker.invalid/announce" || len(mi.UrlList) != 1 || mi.UrlList[0] != "https://mirror.invalid/" {
+		t.Errorf("torrent file: infohash %s, announce %q, webseeds %v", mi.HashInfoBytes().HexString(), mi.Announce, mi.UrlList)
+	}
+
+	// Magnets still fetching metadata have a magnet link but no .torrent yet
+	if w := get("/api/torrents/" + fetching.InfoHash().HexString() + "/magnet"); w.Code != http.StatusOK {
+		t.Errorf("magnet without metadata: status %d", w.Code)
+	}
+	if w := get("/api/torrents/" + fetching.InfoHash().HexString() + "/torrent"); w.Code != http.StatusConflict {
+		t.Errorf("torrent file without metadata: status %d, want 409", w.Code)
+	}
+	if w := get("/api/torrents/" + strings.Repeat("cd", 20) + "/torrent"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown torrent: status %d, want 404", w.Code)
+	}
+	if w := get("/api/torrents/nope/magnet"); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid infohash: status %d, want 400", w.Code)
+	}
+}
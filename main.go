@@ -1,71 +1,758 @@
-package main
+package distroseed
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"maps"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	alog "github.com/anacrolix/log"
 	"github.com/anacrolix/torrent"
 	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+	"github.com/pawl/distro-seed/announce"
+	"github.com/pawl/distro-seed/api"
+	"github.com/pawl/distro-seed/client"
+	"github.com/pawl/distro-seed/fetch"
+	"github.com/pawl/distro-seed/stats"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
-	statusInterval   = 30 * time.Second // Frequency of status logging
-	announceInterval = 10 * time.Minute // Re-announce to trackers/DHT
+	defaultStatusInterval    = 30 * time.Second // Frequency of status logging
+	defaultAnnounceInterval  = 15 * time.Minute // Re-announce to trackers/DHT
+	defaultOptimisticUnchoke = 30 * time.Second // Give a choked peer a turn
+	defaultPeerPort          = 42069            // The torrent library's default
+	shutdownTimeout          = 10 * time.Second // Longest shutdown waits for background tasks to stop
 )
 
-func main() {
-	// Disable the default timestamp in log package to avoid duplicate dates
-	log.SetFlags(0)
+// goTask runs f in a goroutine that shutdown waits for
+func (s *Seeder) goTask(f func()) {
+	s.tasks.Add(1)
+	go func() {
+		defer s.tasks.Done()
+		f()
+	}()
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+// waitWithTimeout waits for the goroutines of wg to stop, for at most timeout, and reports
+// whether they did
+func waitWithTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// RegisterFlags defines the flags of 'distro-seed serve' on fs, setting cfg's fields to their
+// defaults and then to the flags as they're parsed
+func (cfg *Config) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&cfg.DownloadDir, "dir", "./downloads", "Directory to store downloaded files")
+	fs.Var(listFlag{&cfg.DataDirs}, "data-dirs", "Comma-separated extra directories to spread downloads over by free space")
+	fs.StringVar(&cfg.Layout, "layout", "", "Template of the directories torrents are downloaded into, e.g. {distro}/{version}/{name}, flat if empty")
+	fs.StringVar(&cfg.FileMode, "file-mode", "", "Octal mode for downloaded files, e.g. 0664, 0644 if empty")
+	fs.StringVar(&cfg.DirMode, "dir-mode", "", "Octal mode for the directories downloaded files are in, e.g. 2775, 0755 if empty")
+	fs.StringVar(&cfg.Umask, "umask", "", "Octal umask for every file and directory created, e.g. 027, inherited if empty")
+	fs.StringVar(&cfg.User, "user", "", "User, or user:group, to switch to once listening when started as root")
+	fs.Var(listFlag{&cfg.TorrentURLs}, "url", "Comma-separated list of torrent URLs or magnet links")
+	cfg.DHT = []string{"ipv4", "ipv6"}
+	fs.Var(listFlag{&cfg.DHT}, "dht", "Comma-separated DHT networks: ipv4, ipv6, or name=listenAddr, each optionally followed by @bootstrap|bootstrap")
+	fs.BoolVar(&cfg.PEX, "pex", true, "Exchange peers with connected peers (BEP 11), except for private torrents and ones with the pex option off")
+	fs.BoolVar(&cfg.LocalDiscovery, "lsd", false, "Find peers on the local network with Local Service Discovery (BEP 14)")
+	fs.BoolVar(&cfg.MDNS, "mdns", false, "Advertise the host name and management API on the local network over mDNS")
+	fs.StringVar(&cfg.MDNSName, "mdns-name", "distro-seed", "Name to advertise over mDNS, reachable as <name>.local")
+	fs.StringVar(&cfg.OTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP collector to export traces of torrents being added, verified and downloaded to, e.g. http://localhost:4318")
+	fs.IntVar(&cfg.MaxActiveDownloads, "max-active-downloads", 0, "Maximum torrents downloading at once, 0 for unlimited")
+	fs.IntVar(&cfg.MaxActiveSeeds, "max-active-seeds", 0, "Maximum torrents seeding at once, 0 for unlimited")
+	fs.StringVar(&cfg.QueueOrder, "queue-order", queueOrderAge, "Order queued seeds are rotated in: age or demand")
+	fs.StringVar(&cfg.ConfigFile, "config", "", "JSON config file with settings that are reloaded on SIGHUP")
+	fs.IntVar(&cfg.PeerPort, "peer-port", defaultPeerPort, "TCP and UDP port for peer connections, uTP and the DHT")
+	fs.Int64Var(&cfg.UploadLimit, "upload-limit", 0, "Upload rate limit in KiB/s, 0 for unlimited")
+	fs.Int64Var(&cfg.DownloadLimit, "download-limit", 0, "Download rate limit in KiB/s, 0 for unlimited")
+	fs.DurationVar(&cfg.StatusInterval, "status-interval", defaultStatusInterval, "How often to log status and save upload stats")
+	fs.DurationVar(&cfg.AnnounceInterval, "announce-interval", defaultAnnounceInterval, "How often to re-announce to trackers and DHT")
+	fs.DurationVar(&cfg.ScrapeInterval, "scrape-interval", defaultScrapeInterval, "How often to scrape trackers for the seeders and leechers of whole swarms, 0 to disable")
+	fs.BoolVar(&cfg.PreferUDPTrackers, "prefer-udp-trackers", false, "Leave out the HTTP trackers of torrents that list a UDP tracker on the same host, unless the UDP tracker is failing")
+	fs.IntVar(&cfg.AnnouncesPerTracker, "announces-per-tracker", 0, "Re-announces per minute to each tracker host, spread out so torrents sharing a tracker don't all announce at once, 0 for no limit")
+	fs.IntVar(&cfg.MaxUnchoked, "max-unchoked", 0, "Peers per torrent to upload to at full speed, 0 for all")
+	fs.Int64Var(&cfg.PeerUploadLimit, "peer-upload-limit", 0, "Upload rate limit per peer in KiB/s, so no one leecher takes the whole uplink, 0 for unlimited")
+	fs.StringVar(&cfg.UploadStrategy, "upload-strategy", strategyDefault, "How torrents pick the peers they upload to with -max-unchoked: default, fastest-first or widest-distribution")
+	fs.StringVar(&cfg.RecordTrace, "record-trace", "", "File to record the peers of every swarm to, for replaying against the upload strategies with 'distro-seed simulate'")
+	fs.DurationVar(&cfg.OptimisticUnchokeInterval, "optimistic-unchoke-interval", defaultOptimisticUnchoke, "How often to give another peer an upload slot with -max-unchoked")
+	fs.IntVar(&cfg.ConnsPerTorrent, "conns-per-torrent", defaultConnsPerTorrent, "Established peer connections per torrent, before scaling with upload throughput")
+	fs.IntVar(&cfg.MaxConnsPerTorrent, "max-conns-per-torrent", defaultMaxConnsPerTorrent, "Most peer connections a torrent can be scaled up to")
+	fs.IntVar(&cfg.MaxConns, "max-conns", 0, "Peer connections across all torrents, shared out evenly, 0 for no limit")
+	fs.IntVar(&cfg.HalfOpenPerTorrent, "half-open-per-torrent", defaultHalfOpenPerTorrent, "Peer connection attempts in progress per torrent")
+	fs.IntVar(&cfg.PeerRequestBufferKiB, "peer-request-buffer", client.DefaultRequestBufferKiB, "KiB of requested piece data to buffer per peer connection")
+	fs.IntVar(&cfg.HashWorkers, "hash-workers", runtime.GOMAXPROCS(0), "Pieces to hash at once across all torrents, at most the number of CPUs")
+	fs.IntVar(&cfg.VerifyAtOnce, "verify-at-once", 0, "Torrents to verify at once when they're added, 0 for no limit")
+	fs.BoolVar(&cfg.DeferVerify, "defer-verify", false, "Put off verifying queued and crowded torrents until nothing else is being verified")
+	fs.IntVar(&cfg.PieceHashers, "piece-hashers", client.DefaultPieceHashers, "Pieces to hash at once per torrent")
+	fs.Int64Var(&cfg.MaxMemoryMB, "max-memory", 0, "Memory target in MB, that buffers and garbage collection adapt to, 0 for none")
+	fs.IntVar(&cfg.TotalHalfOpen, "total-half-open", defaultTotalHalfOpen, "Peer connection attempts in progress across all torrents")
+	fs.DurationVar(&cfg.DNSCacheTTL, "dns-cache-ttl", client.DefaultDNSCacheTTL, "How long to cache tracker and webseed DNS lookups, 0 to disable")
+	fs.DurationVar(&cfg.DNSNegativeTTL, "dns-negative-ttl", client.DefaultDNSNegativeTTL, "How long to cache failed DNS lookups")
+	fs.StringVar(&cfg.Report, "report", "", "Generate upload reports: daily or weekly, disabled if empty")
+	fs.StringVar(&cfg.ReportFile, "report-file", "", "File to append upload reports to")
+	fs.StringVar(&cfg.ReportWebhook, "report-webhook", "", "URL to POST upload reports to as JSON")
+	fs.StringVar(&cfg.Hook, "hook", "", "Executable to run on torrent events, with the torrent described in DISTRO_SEED_* environment variables")
+	cfg.HookEvents = slices.Clone(defaultHookEvents)
+	fs.Var(listFlag{&cfg.HookEvents}, "hook-events", "Comma-separated events to run the hook for, from: "+strings.Join(eventKinds, ", "))
+	fs.StringVar(&cfg.Webhook, "webhook", "", "URL to POST torrent events to as JSON, like downloads completing")
+	cfg.WebhookEvents = slices.Clone(defaultWebhookEvents)
+	fs.Var(listFlag{&cfg.WebhookEvents}, "webhook-events", "Comma-separated events to send to the webhook, from: "+strings.Join(eventKinds, ", "))
+	fs.StringVar(&cfg.MirrorManifest, "mirror-manifest", "", "sha256sum manifest of a local mirror to seed matching files from")
+	fs.StringVar(&cfg.MirrorRoot, "mirror-root", "", "Directory the mirror manifest's paths are relative to, defaults to the manifest's directory")
+	fs.BoolVar(&cfg.ReportEmail, "report-email", false, "Email upload reports to the notification recipients")
+	fs.StringVar(&cfg.SMTPServer, "smtp-server", "", "SMTP server for email notifications, as host:port")
+	fs.StringVar(&cfg.SMTPUser, "smtp-user", "", "SMTP username, if the server needs authentication")
+	fs.StringVar(&cfg.SMTPPassword, "smtp-password", "", "SMTP password, preferably set with DISTRO_SEED_SMTP_PASSWORD")
+	fs.StringVar(&cfg.SMTPFrom, "smtp-from", "", "Sender address for email notifications")
+	fs.Var(listFlag{&cfg.NotifyEmail}, "notify-email", "Comma-separated addresses to email about problems, disabled if empty")
+	fs.Int64Var(&cfg.NotifyMinFreeMB, "notify-min-free-mb", defaultMinFreeMB, "Notify when free space in a data directory drops below this many MB")
+	fs.IntVar(&cfg.NotifyTrackerFailures, "notify-tracker-failures", defaultTrackerFailures, "Notify after this many consecutive failed announces to a tracker")
+	fs.DurationVar(&cfg.NotifyIdle, "notify-idle", defaultIdleWindow, "Notify when nothing has been uploaded for this long")
+	fs.DurationVar(&cfg.StallAfter, "stall-after", defaultStallAfter, "Report a torrent as stalled in metrics after this long without uploading while leechers are connected")
+	fs.BoolVar(&cfg.Encrypt, "encrypt", false, "Store torrent data encrypted with per-torrent keys kept in the download directory")
+	fs.BoolVar(&cfg.UploadOnly, "upload-only", false, "Only seed torrents already complete on disk, never download or announce incomplete ones")
+	fs.BoolVar(&cfg.FairBandwidth, "fair-bandwidth", true, "Share the upload limit between torrents by weight and demand, rather than letting whichever peers ask first take it")
+	fs.BoolVar(&cfg.LeechPriority, "leech-priority", false, "Give torrents still downloading the most connections and a bigger share of the upload limit until they're complete")
+	fs.BoolVar(&cfg.PrioritizeRare, "prioritize-rare", true, "Favor torrents with few other seeds over ones with plenty in announces, connections and upload bandwidth")
+	fs.Int64Var(&cfg.PauseFreeMB, "pause-free-mb", defaultPauseFreeMB, "Pause downloads to a data directory when its free space drops below this many MB, 0 to disable")
+	fs.Int64Var(&cfg.MonthlyQuotaGB, "monthly-quota-gb", 0, "GB that may be uploaded per month, throttling uploads as it runs out and pausing them once it's used up, 0 to disable")
+	fs.IntVar(&cfg.QuotaResetDay, "quota-reset-day", 1, "Day of the month, 1 to 28, the monthly quota resets on")
+	fs.Var(listFlag{&cfg.PauseWindows}, "pause-windows", "Comma-separated times to pause seeding entirely, as [days] HH:MM-HH:MM, e.g. \"mon-fri 09:00-10:30, 22:00-06:00\"")
+	fs.Var(listFlag{&cfg.PeerAllowlist}, "peer-allowlist", "Comma-separated networks (CIDRs or addresses) peers may connect from, allowing all if empty")
+	fs.StringVar(&cfg.PeerAllowlistURL, "peer-allowlist-url", "", "URL of a list of networks peers may connect from, one per line, refreshed every 5 minutes")
+	fs.StringVar(&cfg.APIAddr, "api", "", "Address for the HTTP management API, e.g. 127.0.0.1:8080, disabled if empty")
+	fs.StringVar(&cfg.APISocket, "api-socket", "", "Unix socket path for the HTTP management API, disabled if empty")
+	fs.StringVar(&cfg.APITLSCert, "api-tls-cert", "", "Certificate file to serve the management API over TLS with")
+	fs.StringVar(&cfg.APITLSKey, "api-tls-key", "", "Key file for -api-tls-cert")
+	fs.Var(listFlag{&cfg.APIACMEDomains}, "api-acme-domains", "Comma-separated domains to get Let's Encrypt certificates for the management API")
+	fs.StringVar(&cfg.APIACMEEmail, "api-acme-email", "", "Contact address for Let's Encrypt, optional")
+	fs.StringVar(&cfg.GRPCAddr, "grpc", "", "Address for the gRPC management API, e.g. 127.0.0.1:8081, disabled if empty")
+	fs.StringVar(&cfg.HTTPMirrorAddr, "http-mirror", "", "Address to serve complete files on as a browsable mirror with checksums, e.g. :8082, disabled if empty")
+	fs.StringVar(&cfg.TransmissionRPCAddr, "transmission-rpc", "", "Address for a Transmission-compatible RPC API at /transmission/rpc, e.g. 127.0.0.1:9091, disabled if empty")
+	fs.StringVar(&cfg.QBittorrentAPIAddr, "qbittorrent-api", "", "Address for a qBittorrent-compatible Web API at /api/v2, e.g. 127.0.0.1:8090, disabled if empty")
+	fs.StringVar(&cfg.APIToken, "api-token", "", "Token required by the management API over TCP, preferably set with DISTRO_SEED_API_TOKEN")
+	fs.StringVar(&cfg.ManifestURL, "manifest-url", "", "URL of a JSON or YAML manifest of torrents to seed, kept in sync")
+	fs.Var(listFlag{&cfg.ManifestChannels}, "manifest-channels", "Comma-separated manifest channels to seed, all if empty")
+	fs.Var(listFlag{&cfg.Presets}, "preset", "Comma-separated distro presets as distro[:channel|version][@keep], from: "+strings.Join(presetNames(), ", "))
+	fs.IntVar(&cfg.KeepReleases, "keep-releases", 1, "Number of newest releases to seed per preset or manifest channel")
+	fs.DurationVar(&cfg.ManifestInterval, "manifest-interval", defaultManifestInterval, "How often to check the manifest and presets for new releases")
+	fs.BoolVar(&cfg.ClusterCoordinator, "cluster-coordinator", false, "Divide the configured torrents among seeders that join this one, needs -api")
+	fs.StringVar(&cfg.ClusterJoin, "cluster-join", "", "Management API URL of a cluster coordinator to seed a share of its torrents for")
+	fs.StringVar(&cfg.ClusterToken, "cluster-token", "", "API token of the cluster coordinator, preferably set with DISTRO_SEED_CLUSTER_TOKEN")
+	fs.StringVar(&cfg.ClusterNodeID, "cluster-node-id", "", "Name of this seeder in the cluster, defaults to the hostname")
+	fs.StringVar(&cfg.StatusFormat, "status-format", statusFormatTable, "How to log the periodic status: table, json (one object per line) or plain")
+	fs.BoolVar(&cfg.Quiet, "quiet", false, "Don't log the periodic status, leaving warnings, errors and events")
+	fs.BoolVar(&cfg.StatusColor, "status-color", false, "Color the status table")
+	fs.IntVar(&cfg.ClusterReplicas, "cluster-replicas", 1, "Number of seeders in the cluster each torrent is assigned to")
+	fs.BoolVar(&cfg.DryRun, "dry-run", false, "Check the config and torrents, report what would be seeded and the disk space needed, and exit")
+	fs.Var(verboseList{&cfg.Verbose}, "verbose", "Log the torrent client's debug messages, from all subsystems or as -verbose=tracker,dht,peer,client")
+}
+
+// validate checks the settings that don't depend on anything but each other, so they're
+// reported before the seeder starts
+func (cfg *Config) validate() error {
+	if cfg.HalfOpenPerTorrent < 1 || cfg.TotalHalfOpen < 1 {
+		return errors.New("❌ Half-open connection limits must be at least 1")
+	}
+	if cfg.PeerRequestBufferKiB*1024 < client.MinRequestBuffer || cfg.PieceHashers < 1 || cfg.MaxMemoryMB < 0 {
+		return fmt.Errorf("❌ Peer request buffer must be at least %d KiB, piece hashers at least 1, and max memory not negative", client.MinRequestBuffer/1024)
+	}
+	if !validStatusFormat(cfg.StatusFormat) {
+		return fmt.Errorf("❌ Unknown status format %q, use table, json or plain", cfg.StatusFormat)
+	}
+	if cfg.HashWorkers < 1 {
+		return errors.New("❌ Hash workers must be at least 1")
+	}
+	if cfg.VerifyAtOnce < 0 {
+		return errors.New("❌ Torrents to verify at once can't be negative")
+	}
+	switch {
+	case cfg.ClusterCoordinator && cfg.ClusterJoin != "":
+		return errors.New("❌ A seeder can either coordinate a cluster or join one, not both")
+	case cfg.ClusterCoordinator && cfg.APIAddr == "":
+		return errors.New("❌ Cluster members send heartbeats to the management API, set -api on the coordinator")
+	case cfg.ClusterReplicas < 1:
+		return errors.New("❌ Each torrent needs to be assigned to at least 1 cluster node")
+	}
+	if cfg.KeepReleases < 1 {
+		return errors.New("❌ At least 1 release needs to be kept")
+	}
+	if cfg.StallAfter <= 0 {
+		return errors.New("❌ -stall-after must be positive")
+	}
+	if err := validateLayout(cfg.Layout); err != nil {
+		return err
+	}
+	if cfg.Umask != "" {
+		if mask, err := parseMode(cfg.Umask); err != nil || mask&^0777 != 0 {
+			return fmt.Errorf("❌ Invalid umask %q", cfg.Umask)
+		}
+	}
+	if err := (verboseFlag{}).Set(strings.Join(cfg.Verbose, ",")); err != nil {
+		return fmt.Errorf("❌ Invalid -verbose: %w", err)
+	}
+	if cfg.PeerPort < 0 || cfg.PeerPort > 65535 {
+		return fmt.Errorf("❌ Invalid peer port %d", cfg.PeerPort)
+	}
+	return nil
+}
 
-	setupSignalHandling(cancel)
+// run runs the seeder until it's stopped by a signal or ctx is done
+func (s *Seeder) run(ctx context.Context) error {
+	cfg := s.config
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	log.Printf("🚀 Starting %s", buildInfo)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 
-	downloadDir := flag.String("dir", getEnv("DOWNLOAD_DIR", "./downloads"), "Directory to store downloaded files")
-	torrentURLs := flag.String("url", getEnv("TORRENT_URLS", ""), "Comma-separated list of torrent URLs or magnet links")
-	flag.Parse()
+	// Only the command handles signals, as a program embedding seeders has its own uses for them
+	upgradeRequested := new(atomic.Bool)
+	var reloads <-chan os.Signal
+	if s.signals {
+		upgradeRequested = setupSignalHandling(cancel)
+		reloads = notifyReload()
+	}
 
 	// Set the path for seedStatsFile dynamically based on downloadDir
-	seedStatsFile := filepath.Join(*downloadDir, "seed_stats.txt")
+	seedStatsFile := filepath.Join(cfg.DownloadDir, "seed_stats.txt")
+
+	// Settings that can be reloaded start from the flags, overlaid by the config file
+	baseConfig := runtimeConfig{
+		UploadLimit:      cfg.UploadLimit,
+		DownloadLimit:    cfg.DownloadLimit,
+		StatusInterval:   duration(cfg.StatusInterval),
+		AnnounceInterval: duration(cfg.AnnounceInterval),
+
+		MaxUnchoked:               cfg.MaxUnchoked,
+		OptimisticUnchokeInterval: duration(cfg.OptimisticUnchokeInterval),
+		PeerUploadLimit:           cfg.PeerUploadLimit,
+		UploadStrategy:            cfg.UploadStrategy,
+
+		ConnsPerTorrent:    cfg.ConnsPerTorrent,
+		MaxConnsPerTorrent: cfg.MaxConnsPerTorrent,
+		MaxConns:           cfg.MaxConns,
+	}
+	if len(cfg.TorrentURLs) > 0 {
+		baseConfig.TorrentURLs = slices.Clone(cfg.TorrentURLs)
+	}
+	if err := baseConfig.validate(); err != nil {
+		return err
+	}
+	tuneConnections(&baseConfig)
+	s.statusOutput = statusWriter{format: cfg.StatusFormat, color: cfg.StatusColor, quiet: cfg.Quiet}
+	s.hashing = newHashPool(s, cfg.HashWorkers)
+	if cfg.VerifyAtOnce > 0 || cfg.DeferVerify {
+		s.verifications = newVerifyQueue(s, cfg.VerifyAtOnce, cfg.DeferVerify)
+	}
+	runtimeCfg, err := baseConfig.withConfigFile(cfg.ConfigFile)
+	if err != nil {
+		return err
+	}
+
+	if cfg.ClusterCoordinator || cfg.ClusterJoin != "" {
+		s.cluster = &clusterNode{seeder: s, id: cfg.ClusterNodeID, joinURL: cfg.ClusterJoin, token: cfg.ClusterToken}
+		if s.cluster.id == "" {
+			if s.cluster.id, err = os.Hostname(); err != nil {
+				return fmt.Errorf("❌ Failed to get the hostname for the cluster node ID, set -cluster-node-id: %w", err)
+			}
+		}
+		if cfg.ClusterCoordinator {
+			s.cluster.coordinator = newClusterCoordinator(cfg.ClusterReplicas)
+		}
+	}
 
-	if *torrentURLs == "" {
-		log.Fatal("❌ No torrent URLs or magnet links provided. Set -url flag or TORRENT_URLS environment variable.")
+	var manifestSources []manifestSource
+	if len(cfg.Presets) > 0 {
+		for _, name := range cfg.Presets {
+			src, err := presetSource(name, cfg.KeepReleases)
+			if err != nil {
+				return err
+			}
+			manifestSources = append(manifestSources, src)
+		}
+	}
+	if cfg.ManifestURL != "" {
+		var channels []string
+		if len(cfg.ManifestChannels) > 0 {
+			channels = cfg.ManifestChannels
+		}
+		manifestSources = append(manifestSources, manifestURLSource(cfg.ManifestURL, channels, cfg.KeepReleases))
+	}
+	if len(manifestSources) > 0 {
+		s.manifest = newTorrentManifest(s, manifestSources, cfg.ManifestInterval)
+		sdExtendStartup("Fetching the manifest")
+		if _, err := s.manifest.fetch(ctx); err != nil {
+			log.Printf("⚠️ Retrying at the next manifest check: %v", err)
+		}
+		runtimeCfg = s.manifest.withURLs(runtimeCfg)
 	}
 
-	torrentList := parseTorrentURLs(*torrentURLs)
-	ensureDirectoryExists(*downloadDir)
+	// Cluster members can get all their torrents from the coordinator, and the manifest may
+	// list some later
+	if len(runtimeCfg.TorrentURLs) == 0 && cfg.ClusterJoin == "" && s.manifest == nil {
+		return errors.New("❌ No torrent URLs or magnet links provided. Set -url flag, DISTRO_SEED_URL environment variable, urls in the config file, -preset, or -manifest-url.")
+	}
 
-	client := configureTorrentClient(*downloadDir)
+	dhtConfig, err := parseDHTNetworks(strings.Join(cfg.DHT, ","))
+	if err != nil {
+		return err
+	}
+	queueCfg := queueConfig{MaxActiveDownloads: cfg.MaxActiveDownloads, MaxActiveSeeds: cfg.MaxActiveSeeds, Order: cfg.QueueOrder}
+	if err := queueCfg.validate(); err != nil {
+		return err
+	}
+	s.queue.cfg = queueCfg
+	notifyCfg := notifyConfig{
+		SMTPServer:      cfg.SMTPServer,
+		Username:        cfg.SMTPUser,
+		Password:        cfg.SMTPPassword,
+		From:            cfg.SMTPFrom,
+		MinFreeMB:       cfg.NotifyMinFreeMB,
+		TrackerFailures: cfg.NotifyTrackerFailures,
+		IdleWindow:      cfg.NotifyIdle,
+		To:              cfg.NotifyEmail,
+	}
+	if err := notifyCfg.validate(); err != nil {
+		return err
+	}
+	s.notifications = newNotifier(notifyCfg)
+	if err := validateHook(cfg.Hook, cfg.HookEvents); err != nil {
+		return err
+	}
+	if err := validateWebhook(cfg.Webhook, cfg.WebhookEvents); err != nil {
+		return err
+	}
+	if err := announce.ValidateBudget(cfg.AnnouncesPerTracker); err != nil {
+		return err
+	}
+	s.announces = announce.NewScheduler(cfg.AnnouncesPerTracker, announceTorrent)
+	s.alerts = alertThresholds{StallAfter: cfg.StallAfter, TrackerFailures: notifyCfg.TrackerFailures}
+	apiTLS := api.TLSConfig{CertFile: cfg.APITLSCert, KeyFile: cfg.APITLSKey, ACMEEmail: cfg.APIACMEEmail}
+	if len(cfg.APIACMEDomains) > 0 {
+		apiTLS.ACMEDomains = cfg.APIACMEDomains
+	}
+	if err := apiTLS.Validate(); err != nil {
+		return err
+	}
+	quotaCfg := quotaConfig{MonthlyBytes: cfg.MonthlyQuotaGB << 30, ResetDay: cfg.QuotaResetDay}
+	if err := quotaCfg.validate(); err != nil {
+		return err
+	}
+	if quotaCfg.MonthlyBytes > 0 {
+		s.quota = newBandwidthQuota(s, quotaCfg)
+	}
+	pauseWindows, err := parsePauseWindows(strings.Join(cfg.PauseWindows, ","))
+	if err != nil {
+		return err
+	}
+	if len(pauseWindows) > 0 {
+		s.schedule = newSeedingSchedule(s, pauseWindows)
+	}
+	allowedPeers, err := parseAllowlist(strings.Join(cfg.PeerAllowlist, ","))
+	if err != nil {
+		return err
+	}
+	if len(allowedPeers) > 0 || cfg.PeerAllowlistURL != "" {
+		s.allowlist = newPeerAllowlist(allowedPeers, cfg.PeerAllowlistURL)
+		if cfg.PeerAllowlistURL != "" {
+			// Fetched before the client starts, so no peers outside it are ever connected to
+			if _, err := s.allowlist.refresh(ctx, &http.Client{Timeout: 30 * time.Second}); err != nil {
+				log.Printf("⚠️ Could not fetch the peer allowlist, only allowing the -peer-allowlist networks until it can be: %v", err)
+			}
+		}
+		log.Printf("🛡️ Only connecting to peers in %d allowed networks", s.allowlist.NumRanges())
+	}
+	reportCfg := reportConfig{Period: cfg.Report, File: cfg.ReportFile, Webhook: cfg.ReportWebhook, Email: cfg.ReportEmail}
+	if err := reportCfg.validate(s.notifications); err != nil {
+		return err
+	}
+	if s.payloadModes, err = parseModes(cfg.FileMode, cfg.DirMode); err != nil {
+		return err
+	}
+	if cfg.Umask != "" {
+		mask, _ := parseMode(cfg.Umask) // Checked by validate
+		if err := setUmask(int(mask)); err != nil {
+			return err
+		}
+	}
+	placementDirs := append([]string{cfg.DownloadDir}, cfg.DataDirs...)
+	if cfg.DryRun {
+		if !runDryRun(runtimeCfg.TorrentURLs, runtimeCfg.TorrentOptions, placementDirs) {
+			return errors.New("❌ Dry run found problems")
+		}
+		return nil
+	}
+	for _, dir := range placementDirs {
+		if err := ensureDirectoryExists(dir); err != nil {
+			return err
+		}
+	}
+	lockWait := time.Duration(0)
+	if isUpgradeChild() {
+		lockWait = upgradeLockWait
+	}
+	dirLock, err := lockDataDir(cfg.DownloadDir, lockWait)
+	if err != nil {
+		return err
+	}
+	defer dirLock.Release()
+	s.placement = newDataPlacement(s, placementDirs, cfg.Layout)
+	if cfg.MirrorManifest != "" {
+		if s.mirror, err = loadMirrorManifest(s, cfg.MirrorManifest, cfg.MirrorRoot); err != nil {
+			return err
+		}
+	}
+	keys, err := loadKeyStore(cfg.DownloadDir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case cfg.Encrypt && s.mirror != nil:
+		return errors.New("❌ Mirror files can't be seeded from encrypted storage, use either -encrypt or -mirror-manifest")
+	case cfg.Encrypt:
+		s.encryptionKeys = keys
+	case keys.hasKeys():
+		return fmt.Errorf("❌ %s has encrypted torrents, run with -encrypt or DISTRO_SEED_ENCRYPT=true", cfg.DownloadDir)
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		shutdownTracing, err := setupTracing(cfg.OTLPEndpoint)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdownTracing(ctx); err != nil {
+				log.Printf("⚠️ Could not export the last traces: %v", err)
+			}
+		}()
+		log.Printf("🔭 Exporting traces to %s", cfg.OTLPEndpoint)
+	}
+	if cfg.UploadOnly {
+		s.uploadOnly = newUploadOnlyGuard(s)
+	}
+	if cfg.FairBandwidth {
+		s.shares = newUploadShares(s)
+	}
+	if cfg.LeechPriority {
+		s.leechBoosts = newLeechBoost(s)
+	}
+	if cfg.PrioritizeRare {
+		s.priorities = newSwarmPriorities(s)
+	}
+
+	handover := loadHandoverState(cfg.DownloadDir)
+	if handover != nil {
+		for _, ht := range handover.Torrents {
+			s.inheritedUploads[ht.InfoHash] = ht.Uploaded
+		}
+	}
+	s.resolverCache = client.NewDNSCache(cfg.DNSCacheTTL, cfg.DNSNegativeTTL)
+
+	tuning := client.Tuning{
+		PeerPort:           cfg.PeerPort,
+		ConnsPerTorrent:    runtimeCfg.ConnsPerTorrent,
+		HalfOpenPerTorrent: cfg.HalfOpenPerTorrent,
+		TotalHalfOpen:      cfg.TotalHalfOpen,
+		RequestBuffer:      client.RequestBufferFor(cfg.PeerRequestBufferKiB*1024, cfg.MaxMemoryMB<<20, runtimeCfg.MaxConns),
+		PieceHashers:       cfg.PieceHashers,
+		DisablePEX:         !cfg.PEX,
+		PeerIDPrefix:       buildInfo.peerIDPrefix(),
+		Name:               buildInfo.clientName(),
+	}
+	if handover != nil {
+		tuning.PeerID = string(handover.PeerID) // Keep our identity across upgrades
+	}
+	if tuning.RequestBuffer < cfg.PeerRequestBufferKiB*1024 {
+		log.Printf("🧰 Buffering %d KiB of request data per connection to stay within %d MB", tuning.RequestBuffer/1024, cfg.MaxMemoryMB)
+	}
+	client, peerListener, dataStorage, err := s.configureTorrentClient(cfg.DownloadDir, dhtConfig, tuning, verboseSubsystems(cfg.Verbose))
+	if err != nil {
+		return err
+	}
+	defer dataStorage.Close()
 	defer client.Close()
+	defer s.closeDHTNetworks()
+
+	// The tasks are done with the client before it's closed, and the servers are stopped before
+	// their listeners are closed, also when starting fails part way
+	listeners := map[string]net.Listener{"peer": peerListener}
+	var flushers sync.WaitGroup // Tasks that save state when stopping
+	stopTasks := sync.OnceFunc(func() {
+		cancel()
+		// Stats are flushed before anything is handed over or closed
+		if !waitWithTimeout(&flushers, shutdownTimeout) {
+			log.Printf("⚠️ Stats weren't saved within %s, shutting down anyway", shutdownTimeout)
+		}
+		if !waitWithTimeout(&s.tasks, shutdownTimeout) {
+			log.Printf("⚠️ Background tasks didn't stop within %s, shutting down anyway", shutdownTimeout)
+		}
+	})
+	defer func() {
+		stopTasks()
+		for _, l := range listeners {
+			l.Close()
+		}
+	}()
+	s.hooks = newHookRunner(s, cfg.Hook, cfg.HookEvents, client)
+	s.webhooks = newWebhookSender(s, cfg.Webhook, cfg.WebhookEvents, client)
 
 	// Initialize the grand total uploaded amount from the stats file
-	totalUploaded := readTotalUploaded(seedStatsFile)
+	totalUploaded := stats.ReadTotalUploaded(seedStatsFile)
+	session := stats.NewSessionID()
+	if handover != nil && handover.Session != "" {
+		session = handover.Session
+	}
+	s.ledger = stats.LoadLedger(cfg.DownloadDir, session)
+	if handover != nil && handover.Session == "" {
+		s.ledger.Inherit(s.inheritedUploads)
+	}
+	s.completions = loadCompletions(cfg.DownloadDir)
+	s.uploads = stats.LoadHistory(cfg.DownloadDir)
+	s.events = loadEventLog(s, cfg.DownloadDir, time.Now())
+	s.registry = loadRegistry(cfg.DownloadDir, append(placementDirs[1:], slices.Collect(maps.Values(runtimeCfg.TorrentDirs))...)...)
+	if stale := s.registry.StalePaths(); len(stale) > 0 {
+		log.Printf("⚠️ %d torrents are recorded outside %s, run 'distro-seed relocate-datadir -dir %s' if the directory was moved", len(stale), cfg.DownloadDir, cfg.DownloadDir)
+	}
+
+	// Applying the config to an empty one adds all torrents and sets the rate limits
+	startupConfig := runtimeCfg
+	runtimeCfg.TorrentURLs = nil
+	s.liveSettings.set(runtimeCfg)
 
 	// Periodic tasks
-	go logPeriodicTorrentStatus(ctx, client, seedStatsFile, &totalUploaded)
-	go periodicAnnounce(ctx, client)
+	flushers.Add(2)
+	go func() {
+		defer flushers.Done()
+		s.logPeriodicTorrentStatus(ctx, client, seedStatsFile, &totalUploaded)
+	}()
+	s.goTask(func() { s.periodicAnnounce(ctx, client) })
+	s.goTask(func() { s.announces.Run(ctx, client) })
+	s.goTask(func() { s.manageConnectionSlots(ctx, client) })
+	if cfg.RecordTrace != "" {
+		traceFile, err := os.Create(cfg.RecordTrace)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to create swarm trace: %w", err)
+		}
+		defer traceFile.Close()
+		s.chokes.recorder = newTraceRecorder(traceFile, time.Now())
+	}
+	s.goTask(func() { s.chokes.run(ctx, client) })
+	s.goTask(func() { s.manageQueue(ctx, client, queueCfg) })
+	go func() {
+		defer flushers.Done()
+		s.generateReports(ctx, client, reportCfg, cfg.DownloadDir)
+	}()
+	s.goTask(func() { s.watchHealth(ctx, client, s.notifications) })
+	if s.uploadOnly == nil { // Nothing is downloaded otherwise
+		s.goTask(func() { s.watchDiskSpace(ctx, client, cfg.PauseFreeMB) })
+	}
+	s.goTask(func() { s.bandwidth.run(ctx) })
+	if s.shares != nil {
+		s.goTask(func() { s.shares.run(ctx, client) })
+	}
+	s.goTask(func() { s.downloads.run(ctx, client) })
+	s.goTask(func() { s.rates.run(ctx, client) })
+	s.goTask(func() { s.peerSources.run(ctx, client) })
+	if s.quota != nil {
+		s.goTask(func() { s.quota.run(ctx, client) })
+	}
+	if s.schedule != nil {
+		s.goTask(func() { s.schedule.run(ctx, client) })
+	}
+	if s.allowlist != nil {
+		s.goTask(func() { s.allowlist.run(ctx, client) })
+	}
+	if cfg.LocalDiscovery {
+		s.goTask(func() { newLocalDiscovery(s, client.LocalPort()).run(ctx, client) })
+	}
+	s.goTask(func() { s.hashing.run(ctx, client) })
+	s.goTask(func() { s.enforceRatioTargets(ctx, client, cfg.DownloadDir) })
+	if cfg.PreferUDPTrackers {
+		s.preferUDP = newUDPPreference(s)
+		s.goTask(func() { s.preferUDP.run(ctx, client) })
+	}
+	if cfg.ScrapeInterval > 0 {
+		s.scrapes = newTrackerScrapes(s, cfg.ScrapeInterval)
+		s.goTask(func() { s.scrapes.run(ctx, client) })
+	}
+	s.goTask(func() { s.recoverTorrents(ctx, client) })
+	s.goTask(func() { s.supervisor.run(ctx) })
+	s.goTask(func() { s.watchMissingFiles(ctx, client) })
+	if s.hooks != nil {
+		s.goTask(func() { s.hooks.run(ctx) })
+	}
+	if s.webhooks != nil {
+		s.goTask(func() { s.webhooks.run(ctx) })
+	}
+	if cfg.MaxMemoryMB > 0 {
+		s.goTask(func() { watchMemory(ctx, cfg.MaxMemoryMB<<20) })
+	}
 
-	processTorrents(ctx, client, torrentList, *downloadDir)
+	s.applyRuntimeConfig(ctx, client, runtimeCfg, startupConfig, cfg.DownloadDir)
+	if s.manifest != nil {
+		s.goTask(func() { s.manifest.run(ctx, client, cfg.DownloadDir, baseConfig, cfg.ConfigFile) })
+	}
+	switch {
+	case s.cluster == nil:
+	case s.cluster.coordinator != nil:
+		s.goTask(func() { s.cluster.runCoordinator(ctx, client, cfg.DownloadDir) })
+	default:
+		s.goTask(func() { s.cluster.runMember(ctx, client, cfg.DownloadDir) })
+	}
+	if handover != nil {
+		s.restoreHandoverTorrents(ctx, client, handover, queueCfg.enabled())
+	}
 
-	<-ctx.Done()
-	log.Println("🛑 Shutting down torrent client...")
-}
+	management := &apiServer{seeder: s, ctx: ctx, client: client, downloadDir: cfg.DownloadDir, baseConfig: baseConfig, configFile: cfg.ConfigFile}
+	s.running.Store(management)
+	defer s.running.Store(nil)
+	var apiTLSConfig *tls.Config
+	if apiTLS.Enabled() {
+		if apiTLSConfig, err = apiTLS.Config(cfg.DownloadDir); err != nil {
+			return err
+		}
+	}
+	if cfg.APIAddr != "" {
+		apiListener, err := s.listenOrInherit("api", "tcp", cfg.APIAddr)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the management API: %w", err)
+		}
+		if cfg.APIToken == "" {
+			log.Printf("⚠️ The management API on %s doesn't require a token, anyone who can connect can control the seeder", cfg.APIAddr)
+		}
+		listeners["api"] = apiListener
+		s.goTask(func() { management.serve(ctx, apiListener, cfg.APIToken, apiTLSConfig) })
+	}
+	if cfg.APISocket != "" {
+		// Access is controlled by the socket's permissions
+		socketListener, err := s.listenAPISocket(cfg.APISocket)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the management API: %w", err)
+		}
+		listeners["api-socket"] = socketListener
+		s.goTask(func() { management.serve(ctx, socketListener, "", nil) })
+	}
+	if cfg.GRPCAddr != "" {
+		grpcListener, err := s.listenOrInherit("grpc", "tcp", cfg.GRPCAddr)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the gRPC management API: %w", err)
+		}
+		if cfg.APIToken == "" {
+			log.Printf("⚠️ The gRPC management API on %s doesn't require a token, anyone who can connect can control the seeder", cfg.GRPCAddr)
+		}
+		listeners["grpc"] = grpcListener
+		s.goTask(func() { (&grpcServer{seeder: s, api: management}).serve(ctx, grpcListener, cfg.APIToken, apiTLSConfig) })
+	}
+	if cfg.TransmissionRPCAddr != "" {
+		rpcListener, err := s.listenOrInherit("transmission-rpc", "tcp", cfg.TransmissionRPCAddr)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the Transmission RPC: %w", err)
+		}
+		if cfg.APIToken == "" {
+			log.Printf("⚠️ The Transmission RPC on %s doesn't require a token, anyone who can connect can control the seeder", cfg.TransmissionRPCAddr)
+		}
+		listeners["transmission-rpc"] = rpcListener
+		rpc := newTransmissionRPC(s, management)
+		s.goTask(func() { rpc.serve(ctx, rpcListener, cfg.APIToken, apiTLSConfig) })
+	}
+	if cfg.QBittorrentAPIAddr != "" {
+		qbtListener, err := s.listenOrInherit("qbittorrent-api", "tcp", cfg.QBittorrentAPIAddr)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the qBittorrent Web API: %w", err)
+		}
+		if cfg.APIToken == "" {
+			log.Printf("⚠️ The qBittorrent Web API on %s doesn't require a token, anyone who can connect can control the seeder", cfg.QBittorrentAPIAddr)
+		}
+		listeners["qbittorrent-api"] = qbtListener
+		qbt := newQBittorrentAPI(s, management, cfg.APIToken)
+		s.goTask(func() { qbt.serve(ctx, qbtListener, apiTLSConfig) })
+	}
+	if cfg.HTTPMirrorAddr != "" {
+		mirrorListener, err := s.listenOrInherit("http-mirror", "tcp", cfg.HTTPMirrorAddr)
+		if err != nil {
+			return fmt.Errorf("❌ Failed to listen for the HTTP mirror: %w", err)
+		}
+		listeners["http-mirror"] = mirrorListener
+		mirror := newHTTPMirror(s, client, cfg.DownloadDir)
+		s.goTask(func() { mirror.run(ctx) })
+		s.goTask(func() { mirror.serve(ctx, mirrorListener, apiTLSConfig) })
+	}
+
+	if cfg.MDNS {
+		var apiPort int
+		if l, ok := listeners["api"]; ok {
+			apiPort = l.Addr().(*net.TCPAddr).Port
+			if l.Addr().(*net.TCPAddr).IP.IsLoopback() {
+				log.Printf("⚠️ The management API only listens on %s, so others on the network can't reach what mDNS advertises", cfg.APIAddr)
+			}
+		}
+		advertiser, err := newMDNSAdvertiser(s, cfg.MDNSName, apiPort, apiTLSConfig != nil)
+		if err != nil {
+			return err
+		}
+		advertiser.run(ctx)
+	}
+
+	if cfg.User != "" {
+		if err := dropPrivileges(cfg.User, placementDirs); err != nil {
+			return err
+		}
+		log.Printf("👤 Running as %s", cfg.User)
+	}
+
+	// Torrents are loaded, tell systemd we're up
+	sdNotify("READY=1")
+	s.goTask(func() { runWatchdog(ctx, client) })
 
-func getEnv(key, fallback string) string {
-	if value, exists := os.LookupEnv(key); exists {
-		return value
+	for running := true; running; {
+		select {
+		case <-ctx.Done():
+			running = false
+		case <-reloads:
+			s.reloadConfig(ctx, client, baseConfig, cfg.ConfigFile, cfg.DownloadDir, false)
+		}
+	}
+	stopTasks()
+
+	if upgradeRequested.Load() {
+		s.upgrade(client, cfg.DownloadDir, listeners)
+	} else {
+		sdNotify("STOPPING=1")
 	}
-	return fallback
+	log.Println("🛑 Shutting down torrent client...")
+	return nil
 }
 
 func parseTorrentURLs(input string) []string {
@@ -76,82 +763,234 @@ func parseTorrentURLs(input string) []string {
 	return urls
 }
 
-func ensureDirectoryExists(path string) {
+// listFlag is a flag of comma-separated values, like -url
+type listFlag struct{ values *[]string }
+
+func (l listFlag) String() string {
+	if l.values == nil {
+		return ""
+	}
+	return strings.Join(*l.values, ",")
+}
+
+func (l listFlag) Set(value string) error {
+	*l.values = nil
+	if value != "" {
+		*l.values = parseTorrentURLs(value)
+	}
+	return nil
+}
+
+func ensureDirectoryExists(path string) error {
 	if err := os.MkdirAll(path, 0755); err != nil {
-		log.Fatalf("❌ Failed to create directory '%s': %v", path, err)
+		return fmt.Errorf("❌ Failed to create directory '%s': %w", path, err)
 	}
+	return nil
 }
 
-func configureTorrentClient(downloadDir string) *torrent.Client {
-	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = downloadDir
-	cfg.Seed = true
-	cfg.NoUpload = false // Allow uploading
+func (s *Seeder) configureTorrentClient(downloadDir string, dhtConfig []*dhtNetwork, tuning client.Tuning, verbose verboseFlag) (*torrent.Client, net.Listener, storage.ClientImplCloser, error) {
+	cfg := client.NewConfig(downloadDir, tuning, s.resolverCache)
+
+	// **Storage With Per-Torrent Upload Limits**
+	// Files are memory-mapped by the client's file storage, unless TORRENT_STORAGE_DEFAULT_FILE_IO
+	// says otherwise. Blocks go out as framed peer messages, so they can't be sent with sendfile.
+	dataStorage := newFileStorage(s.placement)
+	if s.encryptionKeys != nil {
+		dataStorage = &encryptedStorage{seeder: s, ClientImplCloser: dataStorage, keys: s.encryptionKeys}
+	}
+	cfg.DefaultStorage = throttledStorage{s, dataStorage}
 
-	// **Increase Connection Limits**
-	cfg.EstablishedConnsPerTorrent = 100 // Allow more concurrent connections
-	cfg.HalfOpenConnsPerTorrent = 50     // Allow more incoming connections
+	// **Adjustable Rate Limits**
+	cfg.UploadRateLimiter = s.uploadLimiter
+	cfg.DownloadRateLimiter = s.downloadLimiter
 
 	// **Enable Peer Discovery**
-	cfg.NoDHT = false      // Enable DHT for decentralized peer discovery
-	cfg.DisablePEX = false // Enable Peer Exchange (PEX)
+	s.installPEX(cfg)
+	s.configureDHTBootstrap(cfg)
+
+	// **Restrict Peers in Private Deployments**
+	if s.allowlist != nil {
+		cfg.IPBlocklist = s.allowlist
+	}
+
+	// **Track Peer Connection Churn**
+	s.connections.install(cfg)
+
+	// **Track Announce Results for Notifications**
+	cfg.Slogger = slog.New(trackerLogHandler{seeder: s, next: alog.Default.Slogger().Handler()})
+	if len(verbose) > 0 {
+		// Debug records are let through by subsystem, rather than by the GO_LOG rules
+		out := alog.Default.WithFilterLevel(alog.Debug).Slogger().Handler()
+		cfg.Slogger = slog.New(trackerLogHandler{seeder: s, next: subsystemLogHandler{next: out, verbose: verbose}})
+		cfg.Logger = alog.NewLogger().WithFilterLevel(alog.Debug)
+		cfg.Logger.SetHandlers(alog.SlogHandlerAsHandler{SlogHandler: cfg.Slogger.Handler()})
+	}
 
-	client, err := torrent.NewClient(cfg)
+	// The TCP peer listener is owned by us rather than the client, so it can be handed over to
+	// an upgraded binary without refusing connections
+	peerListener, err := s.listenOrInherit("peer", "tcp", fmt.Sprintf(":%d", cfg.ListenPort))
 	if err != nil {
-		log.Fatalf("❌ Failed to create torrent client: %v", err)
+		dataStorage.Close()
+		return nil, nil, nil, fmt.Errorf("❌ Failed to listen for peers: %w", err)
+	}
+	cfg.DisableTCP = true
+
+	var client *torrent.Client
+	err = retryDuringUpgrade(func() (err error) {
+		client, err = torrent.NewClient(cfg)
+		return err
+	})
+	if err != nil {
+		peerListener.Close()
+		dataStorage.Close()
+		return nil, nil, nil, fmt.Errorf("❌ Failed to create torrent client: %w", err)
+	}
+	client.AddListener(chokingListener{s, peerListener})
+	client.AddDialer(torrent.NetworkDialer{Network: "tcp", Dialer: &chokingDialer{seeder: s}})
+
+	// **Enable DHT for Decentralized Peer Discovery**
+	if err := s.startDHTNetworks(client, dhtConfig); err != nil {
+		s.closeDHTNetworks()
+		client.Close()
+		peerListener.Close()
+		dataStorage.Close()
+		return nil, nil, nil, err
 	}
-	return client
+	return client, peerListener, dataStorage, nil
 }
 
-func processTorrents(ctx context.Context, client *torrent.Client, urls []string, downloadDir string) {
+func (s *Seeder) processTorrents(ctx context.Context, client *torrent.Client, urls []string, downloadDir string) {
 	for _, url := range urls {
-		if strings.HasPrefix(url, "magnet:?") {
-			// Handle magnet URLs
-			log.Printf("📥 Adding magnet URL: %s", url)
-			t, err := client.AddMagnet(url)
-			if err != nil {
-				log.Printf("⚠️ Error adding magnet URL '%s': %v", url, err)
-				continue
-			}
-			go waitForMagnetMetadata(ctx, t)
-		} else {
-			// Handle regular torrent file URLs
-			if t, err := addTorrent(client, url, downloadDir); err != nil {
-				log.Printf("⚠️ Error adding torrent from URL '%s': %v", url, err)
-			} else {
-				go seedTorrent(ctx, t)
-			}
+		sdExtendStartup("Loading " + url)
+		// A malformed torrent is skipped rather than taking the seeder down
+		if err := catchPanic(func() { s.processTorrent(ctx, client, url, downloadDir) }); err != nil {
+			log.Printf("❌ Adding '%s' %v, skipping it", url, err)
+		}
+	}
+}
+
+// processTorrent adds the torrents of a URL and starts seeding them
+func (s *Seeder) processTorrent(ctx context.Context, client *torrent.Client, url, downloadDir string) {
+	if magnet, ok := magnetURL(url); ok {
+		// Handle magnet URLs and bare infohashes
+		log.Printf("📥 Adding magnet URL: %s", url)
+		addCtx, span := traceAdd(ctx, url)
+		t, err := s.addMagnet(client, magnet)
+		endAdd(span, t, err)
+		if err != nil {
+			log.Printf("⚠️ Error adding magnet URL '%s': %v", url, err)
+			return
+		}
+		if s.addedAlready(t, url) {
+			s.applyTorrentOptions(t, s.liveSettings.Get().TorrentOptions[url])
+			return
+		}
+		s.torrentSources.Add(url, t)
+		if dir, ok := s.liveSettings.Get().TorrentDirs[url]; ok {
+			s.placement.Assign(t.InfoHash().HexString(), dir)
+		}
+		s.placement.Label(t.InfoHash().HexString(), s.liveSettings.Get().TorrentOptions[url].Labels)
+		s.applyTorrentOptions(t, s.liveSettings.Get().TorrentOptions[url])
+		s.registry.Record(t.InfoHash().HexString(), url, "")
+		s.events.Record(t.InfoHash().HexString(), eventAdded, url)
+		s.goTorrentTask(addCtx, t, "waiting for metadata", func(ctx context.Context) { s.waitForMagnetMetadata(ctx, client, t) })
+	} else if isMetalinkURL(url) {
+		// Handle Metalink files pointing to a torrent
+		addCtx, span := traceAdd(ctx, url)
+		t, err := s.addMetalink(addCtx, client, url, downloadDir)
+		endAdd(span, t, err)
+		if err != nil {
+			log.Printf("⚠️ Error adding metalink '%s': %v", url, err)
+		} else if !s.addedAlready(t, url) {
+			s.torrentSources.Add(url, t)
+			s.registry.Record(t.InfoHash().HexString(), url, "")
+			s.events.Record(t.InfoHash().HexString(), eventAdded, url)
+			s.goTorrentTask(addCtx, t, "seeding", func(ctx context.Context) { s.seedTorrent(ctx, client, t) })
+		}
+	} else if path, ok := localTorrentPath(url); ok {
+		// Handle torrent files and directories of them on disk
+		if err := s.addLocalTorrents(ctx, client, url, path); err != nil {
+			log.Printf("⚠️ Error adding torrents from '%s': %v", url, err)
+		}
+	} else {
+		// Handle regular torrent file URLs
+		addCtx, span := traceAdd(ctx, url)
+		t, err := s.addTorrent(addCtx, client, url, downloadDir)
+		endAdd(span, t, err)
+		if err != nil {
+			log.Printf("⚠️ Error adding torrent from URL '%s': %v", url, err)
+		} else if !s.addedAlready(t, url) {
+			s.torrentSources.Add(url, t)
+			s.registry.Record(t.InfoHash().HexString(), url, fetch.CachePath(downloadDir, url))
+			s.events.Record(t.InfoHash().HexString(), eventAdded, url)
+			s.goTorrentTask(addCtx, t, "seeding", func(ctx context.Context) { s.seedTorrent(ctx, client, t) })
 		}
 	}
 }
 
-func waitForMagnetMetadata(ctx context.Context, t *torrent.Torrent) {
+// Bare infohashes, in hex or base32, have their metadata fetched from peers found through the DHT
+var infoHashPattern = regexp.MustCompile(`^([0-9a-fA-F]{40}|[A-Za-z2-7]{32})$`)
+
+// magnetURL returns the magnet link for a magnet URL or bare infohash
+func magnetURL(url string) (string, bool) {
+	if infoHashPattern.MatchString(url) {
+		return "magnet:?xt=urn:btih:" + url, true
+	}
+	return url, strings.HasPrefix(url, "magnet:?")
+}
+
+// addMagnet adds the torrent of a magnet link, or merges the link's trackers and webseeds into
+// the torrent already added for its infohash
+func (s *Seeder) addMagnet(client *torrent.Client, magnet string) (*torrent.Torrent, error) {
+	spec, err := torrent.TorrentSpecFromMagnetUri(magnet)
+	if err != nil {
+		return nil, err
+	}
+	if t, ok := s.existingTorrent(client, spec.InfoHash, spec.Trackers, spec.Webseeds); ok {
+		return t, nil
+	}
+	spec.Trackers = s.preferUDP.filter(spec.InfoHash.HexString(), spec.Trackers)
+	t, _, err := client.AddTorrentSpec(spec)
+	return t, err
+}
+
+func (s *Seeder) waitForMagnetMetadata(ctx context.Context, client *torrent.Client, t *torrent.Torrent) {
 	log.Printf("⏳ Waiting for metadata: %s", t.InfoHash().HexString())
-	<-t.GotInfo() // Wait for metadata
+	_, span := tracer.Start(ctx, "torrent.metadata", torrentAttributes(t))
+	select {
+	case <-t.GotInfo(): // Wait for metadata
+	case <-t.Closed():
+		endSpan(span, errors.New("torrent dropped"))
+		return
+	case <-ctx.Done():
+		endSpan(span, ctx.Err())
+		return
+	}
+	span.End()
 	log.Printf("✅ Metadata retrieved: %s", t.Name())
-	go seedTorrent(ctx, t)
+	s.events.Record(t.InfoHash().HexString(), eventMetadata, t.Name())
+	s.seedTorrent(ctx, client, t)
 }
 
-func addTorrent(client *torrent.Client, url, downloadDir string) (*torrent.Torrent, error) {
-	// Handle regular torrent file URLs
-	torrentPath := filepath.Join(downloadDir, filepath.Base(url))
+func (s *Seeder) addTorrent(ctx context.Context, client *torrent.Client, url, downloadDir string) (*torrent.Torrent, error) {
+	meta, err := s.loadTorrentFile(ctx, url, downloadDir, s.liveSettings.Get().TorrentOptions[url].credentials())
+	if err != nil {
+		return nil, err
+	}
+	return s.addTorrentMeta(client, meta, url)
+}
 
-	// Download torrent file if it doesn't exist
-	if _, err := os.Stat(torrentPath); os.IsNotExist(err) {
+// Download a torrent file into downloadDir, with creds, unless it's there already, and load it
+func (s *Seeder) loadTorrentFile(ctx context.Context, url, downloadDir string, creds fetch.Credentials) (*metainfo.MetaInfo, error) {
+	// Download torrent file if it isn't cached
+	torrentPath, ok := s.cachedTorrentPath(downloadDir, url)
+	if !ok {
 		log.Printf("📥 Downloading torrent file: %s", url)
-		resp, err := http.Get(url)
+		data, err := fetch.Get(ctx, url, creds, 0)
 		if err != nil {
 			return nil, fmt.Errorf("❌ Failed to download torrent: %w", err)
 		}
-		defer resp.Body.Close()
-
-		out, err := os.Create(torrentPath)
-		if err != nil {
-			return nil, fmt.Errorf("❌ Failed to create torrent file: %w", err)
-		}
-		defer out.Close()
-
-		if _, err = out.ReadFrom(resp.Body); err != nil {
+		if torrentPath, err = fetch.CacheTorrent(downloadDir, url, data); err != nil {
 			return nil, fmt.Errorf("❌ Failed to save torrent file: %w", err)
 		}
 		log.Printf("✅ Torrent file saved: %s", torrentPath)
@@ -161,142 +1000,303 @@ func addTorrent(client *torrent.Client, url, downloadDir string) (*torrent.Torre
 	if err != nil {
 		return nil, fmt.Errorf("❌ Failed to load torrent metadata: %w", err)
 	}
+	return meta, nil
+}
 
-	t, err := client.AddTorrent(meta)
+// Add a torrent, with its data going where it's configured to for the source it was added from
+func (s *Seeder) addTorrentMeta(client *torrent.Client, meta *metainfo.MetaInfo, source string) (*torrent.Torrent, error) {
+	// Storage is opened when the torrent is added, so its directory is needed first
+	if dir, ok := s.liveSettings.Get().TorrentDirs[source]; ok {
+		s.placement.Assign(meta.HashInfoBytes().HexString(), dir)
+	}
+	s.placement.Label(meta.HashInfoBytes().HexString(), s.liveSettings.Get().TorrentOptions[source].Labels)
+	s.liveSettings.Get().TorrentOptions[source].applyToMeta(meta)
+	s.preferUDP.filterMeta(meta)
+	if t, ok := s.existingTorrent(client, meta.HashInfoBytes(), meta.UpvertedAnnounceList(), meta.UrlList); ok {
+		return t, nil
+	}
+
+	if s.uploadOnly != nil {
+		return s.uploadOnly.add(client, meta)
+	}
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(meta)
 	if err != nil {
 		return nil, fmt.Errorf("❌ Failed to add torrent: %w", err)
 	}
+	// Queued verifications are started by seedTorrent when it's the torrent's turn
+	spec.DisableInitialPieceCheck = s.verifications != nil
+	t, isNew, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to add torrent: %w", err)
+	}
+	if isNew {
+		s.verifications.hold(t.InfoHash().HexString())
+	}
 
 	return t, nil
 }
 
-func seedTorrent(ctx context.Context, t *torrent.Torrent) {
-	<-t.GotInfo()   // Wait for metadata before proceeding
-	t.DownloadAll() // Ensure we have the entire file before seeding
-	log.Printf("🌱 Seeding: %s (Size: %d MB)", t.Name(), t.Length()/1024/1024)
-
-	// Keep running until termination signal
-	<-ctx.Done()
-}
-
-func readTotalUploaded(seedStatsFile string) int64 {
-	file, err := os.Open(seedStatsFile)
-	if err != nil {
-		log.Printf("Warning: Could not open seed stats file for reading: %v", err)
-		return 0
+func (s *Seeder) seedTorrent(ctx context.Context, client *torrent.Client, t *torrent.Torrent) {
+	select { // Wait for metadata before proceeding
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	case <-ctx.Done():
+		return
+	}
+	startCtx, span := tracer.Start(ctx, "torrent.start", torrentAttributes(t))
+	s.registry.SetName(t.InfoHash().HexString(), t.Info().BestName(), s.placement.Dir(t.InfoHash().HexString()))
+	s.watchWriteErrors(t)
+	if s.mirror != nil {
+		s.mirror.reconcile(startCtx, t)
+	}
+	if !s.verifications.verify(startCtx, t) {
+		span.End()
+		return
+	}
+	if s.uploadOnly != nil && !s.uploadOnly.check(startCtx, t) {
+		span.End()
+		return
 	}
-	defer file.Close()
+	s.admitQueued(client, t)
+	s.downloadWanted(t) // Ensure we have the entire file, or the ones selected, before seeding
+	span.End()
+	log.Printf("🌱 Seeding: %s (Size: %s)", t.Name(), formatBytes(t.Length()))
 
-	var totalUploaded int64
-	_, err = fmt.Fscanf(file, "%d", &totalUploaded)
-	if err != nil {
-		log.Printf("Warning: Failed to read total uploaded from file: %v", err)
-		return 0
+	// Downloading can take hours, so it's traced on its own, linked to how the torrent started
+	var download trace.Span
+	if !s.downloadComplete(t) {
+		_, download = tracer.Start(ctx, "torrent.download", torrentAttributes(t), trace.WithLinks(trace.LinkFromContext(startCtx)))
+	}
+	select {
+	case <-s.downloaded(ctx, t):
+		if download != nil {
+			download.End()
+		}
+		s.handleCompletion(client, t)
+		s.payloadModes.apply(t, s.placement)
+		s.metalinks.verifyDownload(t)
+		s.exportTorrent(ctx, t)
+	case <-t.Closed():
+		if download != nil {
+			endSpan(download, errors.New("torrent dropped"))
+		}
+		return
+	case <-ctx.Done():
+		if download != nil {
+			endSpan(download, ctx.Err())
+		}
+		return
 	}
 
-	return totalUploaded
+	// Keep running until termination signal or removal
+	select {
+	case <-t.Closed():
+	case <-ctx.Done():
+	}
 }
 
-func logPeriodicTorrentStatus(ctx context.Context, client *torrent.Client, seedStatsFile string, totalUploaded *int64) {
-	ticker := time.NewTicker(statusInterval)
+func (s *Seeder) logPeriodicTorrentStatus(ctx context.Context, client *torrent.Client, seedStatsFile string, totalUploaded *int64) {
+	interval := s.liveSettings.Get().StatusInterval
+	ticker := time.NewTicker(time.Duration(interval))
 	defer ticker.Stop()
 
-	// Track the previously recorded total uploaded for each torrent
-	previousUploads := make(map[string]int64)
-
 	for {
 		select {
+		case <-s.liveSettings.Changed():
+			if next := s.liveSettings.Get().StatusInterval; next != interval {
+				interval = next
+				ticker.Reset(time.Duration(interval))
+			}
 		case <-ctx.Done():
+			// Flush the upload accrued since the last tick
+			s.logCurrentTorrentStatus(client, seedStatsFile, totalUploaded)
 			return
 		case <-ticker.C:
-			logCurrentTorrentStatus(client, seedStatsFile, totalUploaded, previousUploads)
+			s.logCurrentTorrentStatus(client, seedStatsFile, totalUploaded)
 		}
 	}
 }
 
-func logCurrentTorrentStatus(client *torrent.Client, seedStatsFile string, totalUploaded *int64, previousUploads map[string]int64) {
+func (s *Seeder) logCurrentTorrentStatus(client *torrent.Client, seedStatsFile string, totalUploaded *int64) {
 	var sessionUpload int64
+	var peers int
+	status := seederStatus{Time: time.Now()}
 
 	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
 		stats := t.Stats()
 		uploaded := stats.ConnStats.BytesWrittenData.Int64()
 
-		// Get the previously recorded upload for this torrent
-		prevUploaded := previousUploads[t.InfoHash().HexString()]
-
-		// Calculate the total uploaded for this session
-		increment := uploaded - prevUploaded
-
-		// Update the map with the latest upload value for this torrent
-		previousUploads[t.InfoHash().HexString()] = uploaded
-
-		// Add the increment to the session's total upload
-		sessionUpload += increment
+		// Add what the torrent uploaded since the last tick to the session's total upload
+		sessionUpload += s.ledger.Record(ih, s.inheritedUploads[ih], uploaded)
 
-		// Log per-torrent stats (total uploaded since program started)
-		log.Printf("➡️ %s - %d peers - Total Uploaded: %.2f MB",
-			t.Name(), len(t.PeerConns()), float64(uploaded)/1024/1024)
+		// Per-torrent stats (total uploaded since program started)
+		ts := torrentStatus{
+			InfoHash: ih,
+			Name:     t.Name(),
+			State:    s.torrentState(t),
+			Peers:    len(t.PeerConns()),
+			Uploaded: s.inheritedUploads[ih] + uploaded,
+			Lifetime: s.ledger.Lifetime(ih),
+			Rates:    s.rates.Torrent(ih),
+			Labels:   s.optionsOf(t).Labels,
+		}
+		if at, ok := s.completions.CompletedAt(ih); ok {
+			ts.CompletedAt = &at
+		}
+		if took, ok := s.completions.TimeToComplete(ih); ok {
+			ts.TimeToComplete = (*duration)(&took)
+		}
+		if te, ok := s.torrentErrors.Error(ih); ok {
+			ts.Error = &te
+		}
+		if p, ok := s.downloads.Progress(t); ok && s.uploadOnly == nil {
+			ts.Download = &p
+		}
+		ts.Missing, _ = s.uploadOnly.Missing(ih)
+		if h, ok := swarmHealthOf(t); ok && h.OnlySeed && ts.Peers > 0 {
+			ts.OnlySeed = true
+		}
+		if totals, ok := s.scrapes.Totals(ih); ok {
+			ts.Swarm = &totals
+		}
+		ts.PEXPeers = s.pexPeers.Learned(ih)
+		status.Torrents = append(status.Torrents, ts)
+		peers += ts.Peers
 	}
 
 	// Update the grand total uploaded with the session's upload
 	*totalUploaded += sessionUpload
+	s.uploads.Add(sessionUpload, time.Now())
 
-	log.Printf("📊 Total uploaded: %.2f MB (all runs)", float64(*totalUploaded)/1024/1024)
+	status.TotalUploaded = *totalUploaded
+	status.Rates = s.rates.Total()
+	status.Sources = s.peerSources.Totals()
+	status.DHT = s.dhtStatuses()
+	status.OpenFiles, status.OpenFileLimit = resourceUsage()
+	s.statusOutput.write(status)
+	sdNotifyStatus(len(client.Torrents()), peers, *totalUploaded)
 
 	// Write the updated total uploaded to the stats file
-	file, err := os.Create(seedStatsFile)
-	if err != nil {
-		log.Printf("Error: Could not open seed stats file for writing: %v", err)
-		return
+	if err := stats.WriteSeedStats(seedStatsFile, *totalUploaded, status.Time); err != nil {
+		log.Printf("⚠️ Failed to write the seed stats file: %v", err)
 	}
-	defer file.Close()
-
-	_, err = fmt.Fprintf(file, "%d", *totalUploaded)
-	if err != nil {
-		log.Printf("Error: Failed to write total uploaded to file: %v", err)
+	if err := s.ledger.Save(); err != nil {
+		log.Printf("⚠️ Failed to write per-torrent upload totals: %v", err)
+	}
+	if err := s.events.save(); err != nil {
+		log.Printf("⚠️ Failed to write torrent events: %v", err)
 	}
 }
 
 // Periodically re-announce to DHT and trackers
-func periodicAnnounce(ctx context.Context, client *torrent.Client) {
-	ticker := time.NewTicker(15 * time.Minute) // Announce every 15 minutes
+func (s *Seeder) periodicAnnounce(ctx context.Context, client *torrent.Client) {
+	interval := s.liveSettings.Get().AnnounceInterval
+	ticker := time.NewTicker(time.Duration(interval))
 	defer ticker.Stop()
 
 	for {
 		select {
+		case <-s.liveSettings.Changed():
+			if next := s.liveSettings.Get().AnnounceInterval; next != interval {
+				interval = next
+				ticker.Reset(time.Duration(interval))
+			}
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
+			if s.schedule.Paused() {
+				continue // Nothing is announced during a scheduled pause
+			}
 			log.Println("🔄 Re-announcing torrents to trackers and DHT...")
 
 			for _, t := range client.Torrents() {
-				if t.Stats().TotalPeers < 10 { // Only re-announce if we have few peers
-					log.Printf("🔄 Re-announcing: %s", t.Name())
-
-					// Re-announce to all trackers
-					for _, tracker := range t.Metainfo().AnnounceList {
-						t.ModifyTrackers([][]string{tracker})
-					}
-
-					// Re-announce to DHT
-					var infoHash [20]byte
-					copy(infoHash[:], t.InfoHash().Bytes())
-					for _, dhtServer := range client.DhtServers() {
-						dhtServer.Announce(infoHash, client.LocalPort(), true)
+				if s.uploadOnly.Held(t.InfoHash().HexString()) || s.pauses.IsPaused(t.InfoHash().HexString()) {
+					continue // Not announced until its data is found complete, or it's resumed
+				}
+				switch s.priorities.Of(t.InfoHash().HexString()) {
+				case priorityCrowded:
+					continue // Plenty of other seeds for peers to find
+				case priorityRare:
+					// Announced every time, so leechers find one of its few seeds
+				default:
+					if t.Stats().TotalPeers >= 10 { // Only re-announce if we have few peers
+						continue
 					}
 				}
+				s.announces.Request(ctx, client, t)
 			}
 		}
 	}
 }
 
-// Handle SIGINT and SIGTERM for graceful shutdown
-func setupSignalHandling(cancelFunc context.CancelFunc) {
+// Re-announce a torrent to its trackers and the DHT
+func announceTorrent(ctx context.Context, client *torrent.Client, t *torrent.Torrent) {
+	log.Printf("🔄 Re-announcing: %s", t.Name())
+	_, span := tracer.Start(ctx, "torrent.announce", torrentAttributes(t))
+
+	// Re-announce to all trackers
+	for _, tracker := range t.Metainfo().AnnounceList {
+		t.ModifyTrackers([][]string{tracker})
+	}
+
+	// Re-announce to DHT
+	var infoHash [20]byte
+	copy(infoHash[:], t.InfoHash().Bytes())
+	var announceErr error
+	for _, dhtServer := range client.DhtServers() {
+		if _, err := dhtServer.Announce(infoHash, client.LocalPort(), true); err != nil {
+			announceErr = err
+		}
+	}
+	endSpan(span, announceErr)
+}
+
+// Handle SIGINT and SIGTERM for graceful shutdown, and SIGUSR2 for an in-place binary upgrade.
+// On Windows, closing the console, logging off and shutting down arrive as SIGTERM.
+func setupSignalHandling(cancelFunc context.CancelFunc) *atomic.Bool {
+	var upgradeRequested atomic.Bool
 	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(signals, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, upgradeSignals...)...)
 	go func() {
-		<-signals
-		log.Println("🛑 Received shutdown signal...")
+		sig := <-signals
+		if slices.Contains(upgradeSignals, sig) {
+			log.Println("♻️ Received upgrade signal...")
+			upgradeRequested.Store(true)
+		} else {
+			log.Println("🛑 Received shutdown signal...")
+		}
 		cancelFunc()
 	}()
+	return &upgradeRequested
+}
+
+// Deliver SIGHUP, which asks for the config file to be reloaded
+func notifyReload() <-chan os.Signal {
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	return reloads
+}
+
+// Hand our listeners and torrents over to a freshly exec'd binary
+func (s *Seeder) upgrade(client *torrent.Client, downloadDir string, listeners map[string]net.Listener) {
+	if err := s.saveHandoverState(client, downloadDir); err != nil {
+		log.Printf("⚠️ Error saving handover state: %v", err)
+	}
+	pid, err := startUpgradedProcess(listeners)
+	if err != nil {
+		log.Printf("⚠️ Upgrade failed, shutting down instead: %v", err)
+		sdNotify("STOPPING=1")
+		return
+	}
+	// Under systemd, the new process becomes the service's main process
+	sdNotify(fmt.Sprintf("MAINPID=%d", pid))
+	for _, l := range listeners {
+		// The new process is listening on the socket file now
+		if ul, ok := l.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	log.Println("♻️ Upgraded binary started, handing over...")
 }
@@ -0,0 +1,102 @@
+package distroseed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+func TestMagnetURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		magnet string
+		ok     bool
+	}{
+		{url: "0123456789abcdef0123456789ABCDEF01234567", magnet: "magnet:?xt=urn:btih:0123456789abcdef0123456789ABCDEF01234567", ok: true},
+		{url: "AEBAGBAFAYDQQCIKBMGA2DQPCAIREEYU", magnet: "magnet:?xt=urn:btih:AEBAGBAFAYDQQCIKBMGA2DQPCAIREEYU", ok: true},
+		{url: "magnet:?xt=urn:btih:abc", magnet: "magnet:?xt=urn:btih:abc", ok: true},
+		{url: "0123456789abcdef", magnet: "0123456789abcdef"},
+		{url: "https://example.com/0123456789abcdef0123456789abcdef01234567", magnet: "https://example.com/0123456789abcdef0123456789abcdef01234567"},
+	}
+	for _, tt := range tests {
+		if magnet, ok := magnetURL(tt.url); magnet != tt.magnet || ok != tt.ok {
+			t.Errorf("magnetURL(%q) = %q, %v, want %q, %v", tt.url, magnet, ok, tt.magnet, tt.ok)
+		}
+	}
+}
+
+func TestWaitWithTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-stop
+	}()
+	if waitWithTimeout(&wg, 10*time.Millisecond) {
+		t.Error("waited for a goroutine that's still running")
+	}
+	close(stop)
+	if !waitWithTimeout(&wg, 5*time.Second) {
+		t.Error("a stopped goroutine wasn't waited for")
+	}
+}
+
+func TestTorrentTasksStopOnCancel(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	client := newTestClient(t, t.TempDir())
+	magnet, _ := client.AddTorrentInfoHash(metainfo.NewHashFromHex("0123456789abcdef0123456789abcdef01234567"))
+	for name, task := range map[string]func(context.Context){
+		"waitForMagnetMetadata": func(ctx context.Context) { s.waitForMagnetMetadata(ctx, client, magnet) },
+		"seedTorrent":           func(ctx context.Context) { s.seedTorrent(ctx, client, magnet) },
+	} {
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			task(ctx)
+			close(done)
+		}()
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s kept waiting for metadata after its context was canceled", name)
+		}
+	}
+}
+
+func TestLoadTorrentFileCancel(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.torrent" {
+			http.NotFound(w, r)
+			return
+		}
+		<-r.Context().Done() // Never answers
+	}))
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := s.loadTorrentFile(ctx, srv.URL+"/slow.torrent", dir, fetch.Credentials{}); err == nil {
+		t.Error("a download that was canceled succeeded")
+	}
+	if took := time.Since(start); took > 5*time.Second {
+		t.Errorf("canceled download returned after %s", took)
+	}
+	if _, err := s.loadTorrentFile(context.Background(), srv.URL+"/missing.torrent", dir, fetch.Credentials{}); err == nil {
+		t.Error("a missing torrent file was loaded")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("failed downloads left %v behind", entries)
+	}
+}
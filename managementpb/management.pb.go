@@ -0,0 +1,759 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.31.1
+// source: management.proto
+
+// Management API for controlling a distro-seed instance, for fleet tooling that manages many
+// seeders at once. It mirrors what the HTTP API and config file can do.
+
+package managementpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Torrent_State int32
+
+const (
+	Torrent_STATE_UNSPECIFIED       Torrent_State = 0
+	Torrent_STATE_FETCHING_METADATA Torrent_State = 1
+	Torrent_STATE_DOWNLOADING       Torrent_State = 2
+	Torrent_STATE_SEEDING           Torrent_State = 3
+	Torrent_STATE_QUEUED            Torrent_State = 4
+	Torrent_STATE_PAUSED            Torrent_State = 5 // Not enough disk space, or paused through the API
+	Torrent_STATE_CHECKING          Torrent_State = 6 // Data on disk being checked in upload-only mode
+	Torrent_STATE_MISSING_DATA      Torrent_State = 7 // Incomplete on disk in upload-only mode, so not seeded
+	Torrent_STATE_ERROR             Torrent_State = 8 // Stopped by an error, like missing files, see the HTTP API for details
+)
+
+// Enum value maps for Torrent_State.
+var (
+	Torrent_State_name = map[int32]string{
+		0: "STATE_UNSPECIFIED",
+		1: "STATE_FETCHING_METADATA",
+		2: "STATE_DOWNLOADING",
+		3: "STATE_SEEDING",
+		4: "STATE_QUEUED",
+		5: "STATE_PAUSED",
+		6: "STATE_CHECKING",
+		7: "STATE_MISSING_DATA",
+		8: "STATE_ERROR",
+	}
+	Torrent_State_value = map[string]int32{
+		"STATE_UNSPECIFIED":       0,
+		"STATE_FETCHING_METADATA": 1,
+		"STATE_DOWNLOADING":       2,
+		"STATE_SEEDING":           3,
+		"STATE_QUEUED":            4,
+		"STATE_PAUSED":            5,
+		"STATE_CHECKING":          6,
+		"STATE_MISSING_DATA":      7,
+		"STATE_ERROR":             8,
+	}
+)
+
+func (x Torrent_State) Enum() *Torrent_State {
+	p := new(Torrent_State)
+	*p = x
+	return p
+}
+
+func (x Torrent_State) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Torrent_State) Descriptor() protoreflect.EnumDescriptor {
+	return file_management_proto_enumTypes[0].Descriptor()
+}
+
+func (Torrent_State) Type() protoreflect.EnumType {
+	return &file_management_proto_enumTypes[0]
+}
+
+func (x Torrent_State) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Torrent_State.Descriptor instead.
+func (Torrent_State) EnumDescriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{0, 0}
+}
+
+type Torrent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	InfoHash      string                 `protobuf:"bytes,1,opt,name=info_hash,json=infoHash,proto3" json:"info_hash,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Sources       []string               `protobuf:"bytes,3,rep,name=sources,proto3" json:"sources,omitempty"`      // URLs and magnet links it was added from
+	Dir           string                 `protobuf:"bytes,4,opt,name=dir,proto3" json:"dir,omitempty"`              // Directory its data is in
+	Size          int64                  `protobuf:"varint,5,opt,name=size,proto3" json:"size,omitempty"`           // Bytes, 0 until metadata is known
+	Completed     int64                  `protobuf:"varint,6,opt,name=completed,proto3" json:"completed,omitempty"` // Bytes downloaded and verified
+	Uploaded      int64                  `protobuf:"varint,7,opt,name=uploaded,proto3" json:"uploaded,omitempty"`   // Bytes uploaded since the seeder started
+	Peers         int32                  `protobuf:"varint,8,opt,name=peers,proto3" json:"peers,omitempty"`
+	State         Torrent_State          `protobuf:"varint,9,opt,name=state,proto3,enum=distroseed.management.v1.Torrent_State" json:"state,omitempty"`
+	DownloadRate  int64                  `protobuf:"varint,10,opt,name=download_rate,json=downloadRate,proto3" json:"download_rate,omitempty"` // Bytes per second while downloading
+	EtaSeconds    int64                  `protobuf:"varint,11,opt,name=eta_seconds,json=etaSeconds,proto3" json:"eta_seconds,omitempty"`       // Estimated time left downloading, 0 if unknown
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Torrent) Reset() {
+	*x = Torrent{}
+	mi := &file_management_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Torrent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Torrent) ProtoMessage() {}
+
+func (x *Torrent) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Torrent.ProtoReflect.Descriptor instead.
+func (*Torrent) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Torrent) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *Torrent) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Torrent) GetSources() []string {
+	if x != nil {
+		return x.Sources
+	}
+	return nil
+}
+
+func (x *Torrent) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+func (x *Torrent) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Torrent) GetCompleted() int64 {
+	if x != nil {
+		return x.Completed
+	}
+	return 0
+}
+
+func (x *Torrent) GetUploaded() int64 {
+	if x != nil {
+		return x.Uploaded
+	}
+	return 0
+}
+
+func (x *Torrent) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Torrent) GetState() Torrent_State {
+	if x != nil {
+		return x.State
+	}
+	return Torrent_STATE_UNSPECIFIED
+}
+
+func (x *Torrent) GetDownloadRate() int64 {
+	if x != nil {
+		return x.DownloadRate
+	}
+	return 0
+}
+
+func (x *Torrent) GetEtaSeconds() int64 {
+	if x != nil {
+		return x.EtaSeconds
+	}
+	return 0
+}
+
+type AddTorrentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	Dir           string                 `protobuf:"bytes,2,opt,name=dir,proto3" json:"dir,omitempty"` // Optional directory for its data, chosen by free space if empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddTorrentRequest) Reset() {
+	*x = AddTorrentRequest{}
+	mi := &file_management_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddTorrentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddTorrentRequest) ProtoMessage() {}
+
+func (x *AddTorrentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddTorrentRequest.ProtoReflect.Descriptor instead.
+func (*AddTorrentRequest) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AddTorrentRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *AddTorrentRequest) GetDir() string {
+	if x != nil {
+		return x.Dir
+	}
+	return ""
+}
+
+type AddTorrentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Torrent       *Torrent               `protobuf:"bytes,1,opt,name=torrent,proto3" json:"torrent,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddTorrentResponse) Reset() {
+	*x = AddTorrentResponse{}
+	mi := &file_management_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddTorrentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddTorrentResponse) ProtoMessage() {}
+
+func (x *AddTorrentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddTorrentResponse.ProtoReflect.Descriptor instead.
+func (*AddTorrentResponse) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *AddTorrentResponse) GetTorrent() *Torrent {
+	if x != nil {
+		return x.Torrent
+	}
+	return nil
+}
+
+type RemoveTorrentRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// One of these is needed
+	InfoHash      string `protobuf:"bytes,1,opt,name=info_hash,json=infoHash,proto3" json:"info_hash,omitempty"`
+	Url           string `protobuf:"bytes,2,opt,name=url,proto3" json:"url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveTorrentRequest) Reset() {
+	*x = RemoveTorrentRequest{}
+	mi := &file_management_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveTorrentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTorrentRequest) ProtoMessage() {}
+
+func (x *RemoveTorrentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTorrentRequest.ProtoReflect.Descriptor instead.
+func (*RemoveTorrentRequest) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *RemoveTorrentRequest) GetInfoHash() string {
+	if x != nil {
+		return x.InfoHash
+	}
+	return ""
+}
+
+func (x *RemoveTorrentRequest) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+type RemoveTorrentResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	RemovedSources []string               `protobuf:"bytes,1,rep,name=removed_sources,json=removedSources,proto3" json:"removed_sources,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *RemoveTorrentResponse) Reset() {
+	*x = RemoveTorrentResponse{}
+	mi := &file_management_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveTorrentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveTorrentResponse) ProtoMessage() {}
+
+func (x *RemoveTorrentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveTorrentResponse.ProtoReflect.Descriptor instead.
+func (*RemoveTorrentResponse) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RemoveTorrentResponse) GetRemovedSources() []string {
+	if x != nil {
+		return x.RemovedSources
+	}
+	return nil
+}
+
+type ListTorrentsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTorrentsRequest) Reset() {
+	*x = ListTorrentsRequest{}
+	mi := &file_management_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTorrentsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTorrentsRequest) ProtoMessage() {}
+
+func (x *ListTorrentsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTorrentsRequest.ProtoReflect.Descriptor instead.
+func (*ListTorrentsRequest) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{5}
+}
+
+type ListTorrentsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Torrents      []*Torrent             `protobuf:"bytes,1,rep,name=torrents,proto3" json:"torrents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListTorrentsResponse) Reset() {
+	*x = ListTorrentsResponse{}
+	mi := &file_management_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListTorrentsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListTorrentsResponse) ProtoMessage() {}
+
+func (x *ListTorrentsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListTorrentsResponse.ProtoReflect.Descriptor instead.
+func (*ListTorrentsResponse) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListTorrentsResponse) GetTorrents() []*Torrent {
+	if x != nil {
+		return x.Torrents
+	}
+	return nil
+}
+
+type StreamStatsRequest struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	IntervalSeconds uint32                 `protobuf:"varint,1,opt,name=interval_seconds,json=intervalSeconds,proto3" json:"interval_seconds,omitempty"` // Defaults to the status interval
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *StreamStatsRequest) Reset() {
+	*x = StreamStatsRequest{}
+	mi := &file_management_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStatsRequest) ProtoMessage() {}
+
+func (x *StreamStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStatsRequest.ProtoReflect.Descriptor instead.
+func (*StreamStatsRequest) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *StreamStatsRequest) GetIntervalSeconds() uint32 {
+	if x != nil {
+		return x.IntervalSeconds
+	}
+	return 0
+}
+
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UnixTime      int64                  `protobuf:"varint,1,opt,name=unix_time,json=unixTime,proto3" json:"unix_time,omitempty"`
+	Uploaded      int64                  `protobuf:"varint,2,opt,name=uploaded,proto3" json:"uploaded,omitempty"`                             // Bytes since the seeder started
+	Downloaded    int64                  `protobuf:"varint,3,opt,name=downloaded,proto3" json:"downloaded,omitempty"`                         // Bytes since the seeder started
+	UploadRate    int64                  `protobuf:"varint,4,opt,name=upload_rate,json=uploadRate,proto3" json:"upload_rate,omitempty"`       // Bytes per second since the last update
+	DownloadRate  int64                  `protobuf:"varint,5,opt,name=download_rate,json=downloadRate,proto3" json:"download_rate,omitempty"` // Bytes per second since the last update
+	Peers         int32                  `protobuf:"varint,6,opt,name=peers,proto3" json:"peers,omitempty"`
+	Torrents      []*Torrent             `protobuf:"bytes,7,rep,name=torrents,proto3" json:"torrents,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_management_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_management_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_management_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *Stats) GetUnixTime() int64 {
+	if x != nil {
+		return x.UnixTime
+	}
+	return 0
+}
+
+func (x *Stats) GetUploaded() int64 {
+	if x != nil {
+		return x.Uploaded
+	}
+	return 0
+}
+
+func (x *Stats) GetDownloaded() int64 {
+	if x != nil {
+		return x.Downloaded
+	}
+	return 0
+}
+
+func (x *Stats) GetUploadRate() int64 {
+	if x != nil {
+		return x.UploadRate
+	}
+	return 0
+}
+
+func (x *Stats) GetDownloadRate() int64 {
+	if x != nil {
+		return x.DownloadRate
+	}
+	return 0
+}
+
+func (x *Stats) GetPeers() int32 {
+	if x != nil {
+		return x.Peers
+	}
+	return 0
+}
+
+func (x *Stats) GetTorrents() []*Torrent {
+	if x != nil {
+		return x.Torrents
+	}
+	return nil
+}
+
+var File_management_proto protoreflect.FileDescriptor
+
+const file_management_proto_rawDesc = "" +
+	"\n" +
+	"\x10management.proto\x12\x18distroseed.management.v1\"\x98\x04\n" +
+	"\aTorrent\x12\x1b\n" +
+	"\tinfo_hash\x18\x01 \x01(\tR\binfoHash\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\asources\x18\x03 \x03(\tR\asources\x12\x10\n" +
+	"\x03dir\x18\x04 \x01(\tR\x03dir\x12\x12\n" +
+	"\x04size\x18\x05 \x01(\x03R\x04size\x12\x1c\n" +
+	"\tcompleted\x18\x06 \x01(\x03R\tcompleted\x12\x1a\n" +
+	"\buploaded\x18\a \x01(\x03R\buploaded\x12\x14\n" +
+	"\x05peers\x18\b \x01(\x05R\x05peers\x12=\n" +
+	"\x05state\x18\t \x01(\x0e2'.distroseed.management.v1.Torrent.StateR\x05state\x12#\n" +
+	"\rdownload_rate\x18\n" +
+	" \x01(\x03R\fdownloadRate\x12\x1f\n" +
+	"\veta_seconds\x18\v \x01(\x03R\n" +
+	"etaSeconds\"\xc6\x01\n" +
+	"\x05State\x12\x15\n" +
+	"\x11STATE_UNSPECIFIED\x10\x00\x12\x1b\n" +
+	"\x17STATE_FETCHING_METADATA\x10\x01\x12\x15\n" +
+	"\x11STATE_DOWNLOADING\x10\x02\x12\x11\n" +
+	"\rSTATE_SEEDING\x10\x03\x12\x10\n" +
+	"\fSTATE_QUEUED\x10\x04\x12\x10\n" +
+	"\fSTATE_PAUSED\x10\x05\x12\x12\n" +
+	"\x0eSTATE_CHECKING\x10\x06\x12\x16\n" +
+	"\x12STATE_MISSING_DATA\x10\a\x12\x0f\n" +
+	"\vSTATE_ERROR\x10\b\"7\n" +
+	"\x11AddTorrentRequest\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x10\n" +
+	"\x03dir\x18\x02 \x01(\tR\x03dir\"Q\n" +
+	"\x12AddTorrentResponse\x12;\n" +
+	"\atorrent\x18\x01 \x01(\v2!.distroseed.management.v1.TorrentR\atorrent\"E\n" +
+	"\x14RemoveTorrentRequest\x12\x1b\n" +
+	"\tinfo_hash\x18\x01 \x01(\tR\binfoHash\x12\x10\n" +
+	"\x03url\x18\x02 \x01(\tR\x03url\"@\n" +
+	"\x15RemoveTorrentResponse\x12'\n" +
+	"\x0fremoved_sources\x18\x01 \x03(\tR\x0eremovedSources\"\x15\n" +
+	"\x13ListTorrentsRequest\"U\n" +
+	"\x14ListTorrentsResponse\x12=\n" +
+	"\btorrents\x18\x01 \x03(\v2!.distroseed.management.v1.TorrentR\btorrents\"?\n" +
+	"\x12StreamStatsRequest\x12)\n" +
+	"\x10interval_seconds\x18\x01 \x01(\rR\x0fintervalSeconds\"\xfb\x01\n" +
+	"\x05Stats\x12\x1b\n" +
+	"\tunix_time\x18\x01 \x01(\x03R\bunixTime\x12\x1a\n" +
+	"\buploaded\x18\x02 \x01(\x03R\buploaded\x12\x1e\n" +
+	"\n" +
+	"downloaded\x18\x03 \x01(\x03R\n" +
+	"downloaded\x12\x1f\n" +
+	"\vupload_rate\x18\x04 \x01(\x03R\n" +
+	"uploadRate\x12#\n" +
+	"\rdownload_rate\x18\x05 \x01(\x03R\fdownloadRate\x12\x14\n" +
+	"\x05peers\x18\x06 \x01(\x05R\x05peers\x12=\n" +
+	"\btorrents\x18\a \x03(\v2!.distroseed.management.v1.TorrentR\btorrents2\xb6\x03\n" +
+	"\n" +
+	"Management\x12g\n" +
+	"\n" +
+	"AddTorrent\x12+.distroseed.management.v1.AddTorrentRequest\x1a,.distroseed.management.v1.AddTorrentResponse\x12p\n" +
+	"\rRemoveTorrent\x12..distroseed.management.v1.RemoveTorrentRequest\x1a/.distroseed.management.v1.RemoveTorrentResponse\x12m\n" +
+	"\fListTorrents\x12-.distroseed.management.v1.ListTorrentsRequest\x1a..distroseed.management.v1.ListTorrentsResponse\x12^\n" +
+	"\vStreamStats\x12,.distroseed.management.v1.StreamStatsRequest\x1a\x1f.distroseed.management.v1.Stats0\x01B*Z(github.com/pawl/distro-seed/managementpbb\x06proto3"
+
+var (
+	file_management_proto_rawDescOnce sync.Once
+	file_management_proto_rawDescData []byte
+)
+
+func file_management_proto_rawDescGZIP() []byte {
+	file_management_proto_rawDescOnce.Do(func() {
+		file_management_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_management_proto_rawDesc), len(file_management_proto_rawDesc)))
+	})
+	return file_management_proto_rawDescData
+}
+
+var file_management_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_management_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_management_proto_goTypes = []any{
+	(Torrent_State)(0),            // 0: distroseed.management.v1.Torrent.State
+	(*Torrent)(nil),               // 1: distroseed.management.v1.Torrent
+	(*AddTorrentRequest)(nil),     // 2: distroseed.management.v1.AddTorrentRequest
+	(*AddTorrentResponse)(nil),    // 3: distroseed.management.v1.AddTorrentResponse
+	(*RemoveTorrentRequest)(nil),  // 4: distroseed.management.v1.RemoveTorrentRequest
+	(*RemoveTorrentResponse)(nil), // 5: distroseed.management.v1.RemoveTorrentResponse
+	(*ListTorrentsRequest)(nil),   // 6: distroseed.management.v1.ListTorrentsRequest
+	(*ListTorrentsResponse)(nil),  // 7: distroseed.management.v1.ListTorrentsResponse
+	(*StreamStatsRequest)(nil),    // 8: distroseed.management.v1.StreamStatsRequest
+	(*Stats)(nil),                 // 9: distroseed.management.v1.Stats
+}
+var file_management_proto_depIdxs = []int32{
+	0, // 0: distroseed.management.v1.Torrent.state:type_name -> distroseed.management.v1.Torrent.State
+	1, // 1: distroseed.management.v1.AddTorrentResponse.torrent:type_name -> distroseed.management.v1.Torrent
+	1, // 2: distroseed.management.v1.ListTorrentsResponse.torrents:type_name -> distroseed.management.v1.Torrent
+	1, // 3: distroseed.management.v1.Stats.torrents:type_name -> distroseed.management.v1.Torrent
+	2, // 4: distroseed.management.v1.Management.AddTorrent:input_type -> distroseed.management.v1.AddTorrentRequest
+	4, // 5: distroseed.management.v1.Management.RemoveTorrent:input_type -> distroseed.management.v1.RemoveTorrentRequest
+	6, // 6: distroseed.management.v1.Management.ListTorrents:input_type -> distroseed.management.v1.ListTorrentsRequest
+	8, // 7: distroseed.management.v1.Management.StreamStats:input_type -> distroseed.management.v1.StreamStatsRequest
+	3, // 8: distroseed.management.v1.Management.AddTorrent:output_type -> distroseed.management.v1.AddTorrentResponse
+	5, // 9: distroseed.management.v1.Management.RemoveTorrent:output_type -> distroseed.management.v1.RemoveTorrentResponse
+	7, // 10: distroseed.management.v1.Management.ListTorrents:output_type -> distroseed.management.v1.ListTorrentsResponse
+	9, // 11: distroseed.management.v1.Management.StreamStats:output_type -> distroseed.management.v1.Stats
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_management_proto_init() }
+func file_management_proto_init() {
+	if File_management_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_management_proto_rawDesc), len(file_management_proto_rawDesc)),
+			NumEnums:      1,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_management_proto_goTypes,
+		DependencyIndexes: file_management_proto_depIdxs,
+		EnumInfos:         file_management_proto_enumTypes,
+		MessageInfos:      file_management_proto_msgTypes,
+	}.Build()
+	File_management_proto = out.File
+	file_management_proto_goTypes = nil
+	file_management_proto_depIdxs = nil
+}
@@ -0,0 +1,236 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultManifestInterval = 1 * time.Hour
+	maxManifestSize         = 10 << 20
+	manifestFetchTimeout    = 1 * time.Minute // Longest a manifest or release listing may take to download
+)
+
+// manifestClient fetches manifests and the pages presets find releases on, giving up on servers
+// that accept the connection and never answer, so they can't hold up startup or refreshes
+var manifestClient = &http.Client{Timeout: manifestFetchTimeout}
+
+// torrentManifestFile is a list of torrents published by a distro or mirror organisation, in JSON or
+// YAML:
+//
+//	torrents:
+//	  - https://releases.example.org/example.iso.torrent
+//	  - url: magnet:?xt=urn:btih:...
+//	    name: Example 24.04
+//	    channel: lts
+//	    version: 24.04
+type torrentManifestFile struct {
+	Torrents []torrentManifestEntry `yaml:"torrents"`
+}
+
+type torrentManifestEntry struct {
+	URL     string `yaml:"url"`
+	Name    string `yaml:"name"` // Only informational
+	Channel string `yaml:"channel"`
+	Version string `yaml:"version"` // Only the newest versions in each channel are seeded
+}
+
+// Entries can be plain URLs too
+func (e *torrentManifestEntry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.URL)
+	}
+	type plain torrentManifestEntry
+	return node.Decode((*plain)(e))
+}
+
+// torrentManifest keeps the configured torrents in line with remote manifests and presets.
+// Torrents they list are added to the configured ones, and removed again once they're delisted.
+type torrentManifest struct {
+	seeder   *Seeder
+	sources  []manifestSource
+	interval time.Duration
+
+	mu   sync.Mutex
+	urls map[string][]string // By source, from the last fetch of it that succeeded
+}
+
+// manifestSource is something that lists torrents to seed, like a manifest URL or a preset
+type manifestSource struct {
+	name    string
+	resolve func(context.Context) ([]string, error)
+}
+
+func newTorrentManifest(s *Seeder, sources []manifestSource, interval time.Duration) *torrentManifest {
+	return &torrentManifest{seeder: s, sources: sources, interval: interval, urls: make(map[string][]string)}
+}
+
+// manifestURLSource lists the torrents in the manifest at url, leaving out entries in other
+// channels than those given and all but the newest keep versions in each channel
+func manifestURLSource(url string, channels []string, keep int) manifestSource {
+	return manifestSource{name: url, resolve: func(ctx context.Context) ([]string, error) {
+		entries, err := fetchManifest(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		return selectReleases(entries, channels, keep), nil
+	}}
+}
+
+// withURLs returns cfg with the listed torrents added
+func (m *torrentManifest) withURLs(cfg runtimeConfig) runtimeConfig {
+	if m == nil {
+		return cfg
+	}
+	cfg.TorrentURLs = appendMissing(slices.Clone(cfg.TorrentURLs), m.URLs()...)
+	return cfg
+}
+
+// URLs returns the torrents listed by all sources
+func (m *torrentManifest) URLs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var urls []string
+	for _, src := range m.sources {
+		urls = appendMissing(urls, m.urls[src.name]...)
+	}
+	return urls
+}
+
+// fetch resolves every source, returning the torrents listed before. Sources that fail keep
+// their torrents from the last time they were resolved.
+func (m *torrentManifest) fetch(ctx context.Context) (prev []string, err error) {
+	prev = m.URLs()
+	var errs []error
+	for _, src := range m.sources {
+		urls, err := src.resolve(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		m.mu.Lock()
+		changed := !slices.Equal(m.urls[src.name], urls)
+		m.urls[src.name] = urls
+		m.mu.Unlock()
+		if changed {
+			log.Printf("📜 %s lists %d torrents", src.name, len(urls))
+		}
+	}
+	return prev, errors.Join(errs...)
+}
+
+// fetchManifest downloads and parses the manifest at url
+func fetchManifest(ctx context.Context, url string) ([]torrentManifestEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := manifestClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to download manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("❌ Failed to download manifest: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to download manifest: %w", err)
+	}
+
+	// JSON is valid YAML, so both are parsed the same way
+	var file torrentManifestFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("❌ Failed to parse manifest '%s': %w", url, err)
+	}
+	for i, e := range file.Torrents {
+		if e.URL == "" {
+			return nil, fmt.Errorf("❌ Entry %d of manifest '%s' has no URL", i+1, url)
+		}
+	}
+	return file.Torrents, nil
+}
+
+// selectReleases returns the URLs of the entries in the channels, or in any if none are given,
+// keeping the newest versions in each channel. Entries without a channel or version are always
+// kept.
+func selectReleases(entries []torrentManifestEntry, channels []string, keep int) []string {
+	versions := make(map[string][]string) // By channel, newest first
+	for _, e := range entries {
+		if e.Version != "" && !slices.Contains(versions[e.Channel], e.Version) {
+			versions[e.Channel] = append(versions[e.Channel], e.Version)
+		}
+	}
+	for _, vs := range versions {
+		slices.SortFunc(vs, func(a, b string) int { return compareVersions(b, a) })
+	}
+
+	var urls []string
+	for _, e := range entries {
+		if e.Channel != "" && len(channels) > 0 && !slices.Contains(channels, e.Channel) {
+			continue
+		}
+		if e.Version != "" && slices.Index(versions[e.Channel], e.Version) >= keep {
+			continue
+		}
+		urls = appendMissing(urls, e.URL)
+	}
+	return urls
+}
+
+// Periodically resolve the sources, adding the torrents they list and removing those they
+// delist. Torrents that are also given by flags or the config file are kept.
+func (m *torrentManifest) run(ctx context.Context, client *torrent.Client, downloadDir string, base runtimeConfig, configFile string) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		prev, err := m.fetch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Keeping torrents from the last manifest: %v", err)
+		}
+		current := m.URLs()
+		configured, err := base.withConfigFile(configFile)
+		if err != nil {
+			configured = base
+		}
+		converge := func(cfg runtimeConfig) (runtimeConfig, error) {
+			next := m.withURLs(cfg)
+			next.TorrentURLs = slices.DeleteFunc(next.TorrentURLs, func(url string) bool {
+				return slices.Contains(prev, url) && !slices.Contains(current, url) && !slices.Contains(configured.TorrentURLs, url)
+			})
+			return next, nil
+		}
+		// Unchanged manifests aren't worth a log line
+		if diff, _ := m.seeder.updateConfig(ctx, client, downloadDir, true, converge); diff.empty() {
+			continue
+		}
+		m.seeder.updateConfig(ctx, client, downloadDir, false, converge)
+	}
+}
+
+// appendMissing appends the URLs that aren't in urls already
+func appendMissing(urls []string, more ...string) []string {
+	for _, url := range more {
+		if !slices.Contains(urls, url) {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
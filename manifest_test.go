@@ -0,0 +1,140 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFetchManifest(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+		err  string // Part of the error expected, if any
+	}{
+		{
+			body: "torrents:\n  - https://example.com/a.torrent\n  - url: magnet:?xt=urn:btih:abc\n    name: B\n  - https://example.com/a.torrent\n",
+			want: []string{"https://example.com/a.torrent", "magnet:?xt=urn:btih:abc", "https://example.com/a.torrent"},
+		},
+		{
+			body: `{"torrents": ["https://example.com/a.torrent", {"url": "https://example.com/b.torrent"}]}`,
+			want: []string{"https://example.com/a.torrent", "https://example.com/b.torrent"},
+		},
+		{body: "torrents:\n  - name: No URL\n", err: "has no URL"},
+		{body: "torrents: [", err: "Failed to parse manifest"},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(tt.body))
+		}))
+		entries, err := fetchManifest(context.Background(), server.URL)
+		server.Close()
+		var got []string
+		for _, e := range entries {
+			got = append(got, e.URL)
+		}
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("fetchManifest(%q) error = %v, want %q", tt.body, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("fetchManifest(%q): %v", tt.body, err)
+		} else if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("fetchManifest(%q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestFetchManifestGivesUpOnStalledServers(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+	prev := manifestClient
+	manifestClient = &http.Client{Timeout: 100 * time.Millisecond}
+	defer func() { manifestClient = prev }()
+
+	if _, err := fetchManifest(context.Background(), server.URL); err == nil {
+		t.Error("fetching from a server that never answers didn't fail")
+	}
+}
+
+func TestTorrentManifestKeepsSourcesThatFail(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	listed := map[string][]string{
+		"a": {"https://example.com/a.torrent", "https://example.com/shared.torrent"},
+		"b": {"https://example.com/shared.torrent", "https://example.com/b.torrent"},
+	}
+	var failing string
+	source := func(name string) manifestSource {
+		return manifestSource{name: name, resolve: func(context.Context) ([]string, error) {
+			if name == failing {
+				return nil, errors.New("unreachable")
+			}
+			return listed[name], nil
+		}}
+	}
+	m := newTorrentManifest(s, []manifestSource{source("a"), source("b")}, defaultManifestInterval)
+	if _, err := m.fetch(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"https://example.com/a.torrent", "https://example.com/shared.torrent", "https://example.com/b.torrent"}
+	if got := m.URLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs = %v, want %v", got, want)
+	}
+
+	failing = "a"
+	listed["a"], listed["b"] = nil, []string{"https://example.com/c.torrent"}
+	prev, err := m.fetch(context.Background())
+	if err == nil {
+		t.Error("fetch succeeded with a source failing")
+	}
+	if !reflect.DeepEqual(prev, want) {
+		t.Errorf("fetch returned prev = %v, want %v", prev, want)
+	}
+	want = []string{"https://example.com/a.torrent", "https://example.com/shared.torrent", "https://example.com/c.torrent"}
+	if got := m.URLs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("after a source failed, URLs = %v, want %v", got, want)
+	}
+
+	cfg := m.withURLs(runtimeConfig{TorrentURLs: []string{"https://example.com/c.torrent", "https://example.com/d.torrent"}})
+	want = []string{"https://example.com/c.torrent", "https://example.com/d.torrent", "https://example.com/a.torrent", "https://example.com/shared.torrent"}
+	if !reflect.DeepEqual(cfg.TorrentURLs, want) {
+		t.Errorf("withURLs = %v, want %v", cfg.TorrentURLs, want)
+	}
+}
+
+func TestSelectReleases(t *testing.T) {
+	entries := []torrentManifestEntry{
+		{URL: "lts-22.04", Channel: "lts", Version: "22.04"},
+		{URL: "lts-24.04", Channel: "lts", Version: "24.04"},
+		{URL: "lts-20.04", Channel: "lts", Version: "20.04"},
+		{URL: "stable-24.10", Channel: "stable", Version: "24.10"},
+		{URL: "stable-25.04", Channel: "stable", Version: "25.04"},
+		{URL: "checksums"},
+	}
+	tests := []struct {
+		channels []string
+		keep     int
+		want     []string
+	}{
+		{keep: 1, want: []string{"lts-24.04", "stable-25.04", "checksums"}},
+		{keep: 2, want: []string{"lts-22.04", "lts-24.04", "stable-24.10", "stable-25.04", "checksums"}},
+		{channels: []string{"lts"}, keep: 1, want: []string{"lts-24.04", "checksums"}},
+		{channels: []string{"testing"}, keep: 5, want: []string{"checksums"}},
+	}
+	for _, tt := range tests {
+		if got := selectReleases(entries, tt.channels, tt.keep); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("selectReleases(%v, %d) = %v, want %v", tt.channels, tt.keep, got, tt.want)
+		}
+	}
+}
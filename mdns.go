@@ -0,0 +1,286 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	mdnsPort       = 5353
+	mdnsHostTTL    = 120  // Seconds, for records about the host, as RFC 6762 recommends
+	mdnsServiceTTL = 4500 // Seconds, for the other records
+	mdnsCacheFlush = 1 << 15
+	mdnsUnicast    = 1 << 15 // The QU bit of a question's class, asking for a unicast reply
+	mdnsServices   = "_services._dns-sd._udp.local."
+)
+
+// The mDNS multicast groups, by network
+var mdnsGroups = map[string]*net.UDPAddr{
+	"udp4": {IP: net.IPv4(224, 0, 0, 251), Port: mdnsPort},
+	"udp6": {IP: net.ParseIP("ff02::fb"), Port: mdnsPort},
+}
+
+// mdnsAdvertiser answers mDNS queries for the seeder's hostname, and advertises the management
+// API as a DNS-SD service, so lab users can reach the image server at name.local and browse for
+// it without knowing its IP. The name isn't probed for conflicts, so it's up to the operator to
+// pick one that's unique on the network.
+type mdnsAdvertiser struct {
+	seeder   *Seeder
+	host     dnsmessage.Name // e.g. distro-seed.local.
+	service  dnsmessage.Name // _http._tcp.local. or _https._tcp.local., empty without an API
+	instance dnsmessage.Name // The API's service instance, e.g. distro-seed._http._tcp.local.
+	port     uint16
+	addrs    func() []netip.Addr // Addresses the host name resolves to
+}
+
+func newMDNSAdvertiser(s *Seeder, name string, apiPort int, apiTLS bool) (*mdnsAdvertiser, error) {
+	if !validHostLabel(name) {
+		return nil, fmt.Errorf("❌ Invalid mDNS name '%s', use letters, digits and hyphens", name)
+	}
+	m := &mdnsAdvertiser{seeder: s, host: dnsmessage.MustNewName(name + ".local."), addrs: localAddrs}
+	if apiPort > 0 {
+		service := "_http._tcp.local."
+		if apiTLS {
+			service = "_https._tcp.local."
+		}
+		m.service = dnsmessage.MustNewName(service)
+		m.instance = dnsmessage.MustNewName(name + "." + service)
+		m.port = uint16(apiPort)
+	}
+	return m, nil
+}
+
+func validHostLabel(name string) bool {
+	if name == "" || len(name) > 63 || strings.HasPrefix(name, "-") || strings.HasSuffix(name, "-") {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// localAddrs returns the addresses of the interfaces mDNS is answered on, leaving out loopback
+// and IPv6 link-local addresses, which are no use without their zone
+func localAddrs() []netip.Addr {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var addrs []netip.Addr
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Flags&net.FlagMulticast == 0 {
+			continue
+		}
+		ifaceAddrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range ifaceAddrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			addr, ok := netip.AddrFromSlice(ipNet.IP)
+			if ok && !addr.Unmap().IsLinkLocalUnicast() {
+				addrs = append(addrs, addr.Unmap())
+			}
+		}
+	}
+	return addrs
+}
+
+// run answers queries and announces the records on each network available, saying goodbye when
+// the context ends so caches drop them. It runs as the seeder's tasks, so shutdown waits for the
+// goodbye to be sent.
+func (m *mdnsAdvertiser) run(ctx context.Context) {
+	for _, network := range []string{"udp4", "udp6"} {
+		group := mdnsGroups[network]
+		conn, err := net.ListenMulticastUDP(network, nil, group)
+		if err != nil {
+			log.Printf("⚠️ mDNS unavailable over %s: %v", network, err)
+			continue
+		}
+		m.seeder.goTask(func() { m.listen(conn) })
+		m.seeder.goTask(func() {
+			// Announced twice a second apart, as RFC 6762 asks
+			m.send(conn, group, m.announcement(mdnsHostTTL, mdnsServiceTTL))
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+				m.send(conn, group, m.announcement(mdnsHostTTL, mdnsServiceTTL))
+				<-ctx.Done()
+			}
+			m.send(conn, group, m.announcement(0, 0))
+			conn.Close()
+		})
+	}
+	log.Printf("📣 Advertising %s over mDNS", strings.TrimSuffix(m.host.String(), "."))
+}
+
+func (m *mdnsAdvertiser) send(conn *net.UDPConn, to *net.UDPAddr, msg []byte) {
+	if msg == nil {
+		return
+	}
+	if _, err := conn.WriteToUDP(msg, to); err != nil && !errors.Is(err, net.ErrClosed) {
+		log.Printf("⚠️ Could not send mDNS response: %v", err)
+	}
+}
+
+func (m *mdnsAdvertiser) listen(conn *net.UDPConn) {
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Printf("⚠️ Stopped answering mDNS queries: %v", err)
+			}
+			return
+		}
+		reply, unicast := m.answer(buf[:n], from.Port != mdnsPort)
+		if unicast {
+			m.send(conn, from, reply)
+		} else {
+			m.send(conn, mdnsGroups[networkOf(from.IP)], reply)
+		}
+	}
+}
+
+func networkOf(ip net.IP) string {
+	if ip.To4() != nil {
+		return "udp4"
+	}
+	return "udp6"
+}
+
+// answer builds the reply to a query, nil when it asks about nothing we advertise, and whether
+// it's sent back to the querier rather than to the group. Queries from ports other than 5353
+// come from simple resolvers like dig, which expect a conventional DNS reply.
+func (m *mdnsAdvertiser) answer(query []byte, legacy bool) (reply []byte, unicast bool) {
+	var q dnsmessage.Message
+	if err := q.Unpack(query); err != nil || q.Response {
+		return nil, false
+	}
+	var answers, additionals []dnsmessage.Resource
+	unicast = legacy
+	for _, question := range q.Questions {
+		if question.Class&mdnsUnicast != 0 {
+			unicast = true
+		}
+		a, extra := m.records(question.Name, question.Type)
+		answers = append(answers, a...)
+		additionals = append(additionals, extra...)
+	}
+	if len(answers) == 0 {
+		return nil, false
+	}
+	resp := dnsmessage.Message{
+		Header:      dnsmessage.Header{Response: true, Authoritative: true},
+		Answers:     answers,
+		Additionals: additionals,
+	}
+	if legacy {
+		resp.ID = q.ID
+		resp.Questions = q.Questions
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil, false
+	}
+	return packed, unicast
+}
+
+// records returns the records answering a question and the ones that help resolve them
+func (m *mdnsAdvertiser) records(name dnsmessage.Name, typ dnsmessage.Type) (answers, additionals []dnsmessage.Resource) {
+	is := func(n dnsmessage.Name) bool { return n.Length != 0 && strings.EqualFold(name.String(), n.String()) }
+	wants := func(t dnsmessage.Type) bool { return typ == t || typ == dnsmessage.TypeALL }
+	switch {
+	case is(m.host):
+		for _, r := range m.hostRecords(mdnsHostTTL) {
+			if wants(r.Header.Type) {
+				answers = append(answers, r)
+			}
+		}
+	case m.service.Length != 0 && is(dnsmessage.MustNewName(mdnsServices)) && wants(dnsmessage.TypePTR):
+		answers = append(answers, m.serviceRecords(mdnsServiceTTL)[0])
+	case is(m.service) && wants(dnsmessage.TypePTR):
+		records := m.serviceRecords(mdnsServiceTTL)
+		answers = append(answers, records[1])
+		additionals = append(append(additionals, records[2:]...), m.hostRecords(mdnsHostTTL)...)
+	case is(m.instance):
+		for _, r := range m.serviceRecords(mdnsServiceTTL)[2:] {
+			if wants(r.Header.Type) {
+				answers = append(answers, r)
+			}
+		}
+		additionals = m.hostRecords(mdnsHostTTL)
+	}
+	return answers, additionals
+}
+
+// hostRecords are the host name's addresses
+func (m *mdnsAdvertiser) hostRecords(ttl uint32) []dnsmessage.Resource {
+	var records []dnsmessage.Resource
+	for _, addr := range m.addrs() {
+		h := dnsmessage.ResourceHeader{Name: m.host, Class: dnsmessage.ClassINET | mdnsCacheFlush, TTL: ttl}
+		if addr.Is4() {
+			h.Type = dnsmessage.TypeA
+			records = append(records, dnsmessage.Resource{Header: h, Body: &dnsmessage.AResource{A: addr.As4()}})
+		} else {
+			h.Type = dnsmessage.TypeAAAA
+			records = append(records, dnsmessage.Resource{Header: h, Body: &dnsmessage.AAAAResource{AAAA: addr.As16()}})
+		}
+	}
+	return records
+}
+
+// serviceRecords are the DNS-SD records of the API: the service type's PTR under
+// _services._dns-sd._udp, the instance's PTR under the type, and its SRV and TXT
+func (m *mdnsAdvertiser) serviceRecords(ttl uint32) []dnsmessage.Resource {
+	if m.service.Length == 0 {
+		return nil
+	}
+	shared := dnsmessage.ClassINET
+	unique := dnsmessage.ClassINET | mdnsCacheFlush
+	return []dnsmessage.Resource{
+		{
+			Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(mdnsServices), Type: dnsmessage.TypePTR, Class: shared, TTL: ttl},
+			Body:   &dnsmessage.PTRResource{PTR: m.service},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: m.service, Type: dnsmessage.TypePTR, Class: shared, TTL: ttl},
+			Body:   &dnsmessage.PTRResource{PTR: m.instance},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: m.instance, Type: dnsmessage.TypeSRV, Class: unique, TTL: ttl},
+			Body:   &dnsmessage.SRVResource{Target: m.host, Port: m.port},
+		},
+		{
+			Header: dnsmessage.ResourceHeader{Name: m.instance, Type: dnsmessage.TypeTXT, Class: unique, TTL: ttl},
+			Body:   &dnsmessage.TXTResource{TXT: []string{"path=/api/status", "version=" + buildInfo.Version}},
+		},
+	}
+}
+
+// announcement is an unsolicited response with all the records, with zero TTLs saying goodbye
+func (m *mdnsAdvertiser) announcement(hostTTL, serviceTTL uint32) []byte {
+	msg := dnsmessage.Message{
+		Header:  dnsmessage.Header{Response: true, Authoritative: true},
+		Answers: append(m.hostRecords(hostTTL), m.serviceRecords(serviceTTL)...),
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
@@ -0,0 +1,99 @@
+package distroseed
+
+import (
+	"net/netip"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func testMDNSAdvertiser(t *testing.T) *mdnsAdvertiser {
+	t.Helper()
+	m, err := newMDNSAdvertiser(nil, "distro-seed", 8080, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.addrs = func() []netip.Addr {
+		return []netip.Addr{netip.MustParseAddr("192.168.1.20"), netip.MustParseAddr("fd00::20")}
+	}
+	return m
+}
+
+func mdnsQuery(t *testing.T, id uint16, name string, typ dnsmessage.Type, class dnsmessage.Class) []byte {
+	t.Helper()
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(name), Type: typ, Class: class}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return packed
+}
+
+func unpackMDNSReply(t *testing.T, reply []byte) dnsmessage.Message {
+	t.Helper()
+	var msg dnsmessage.Message
+	if err := msg.Unpack(reply); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestMDNSAnswersHostName(t *testing.T) {
+	m := testMDNSAdvertiser(t)
+	reply, unicast := m.answer(mdnsQuery(t, 0, "Distro-Seed.local.", dnsmessage.TypeA, dnsmessage.ClassINET), false)
+	if reply == nil || unicast {
+		t.Fatalf("reply %v, unicast %v", reply, unicast)
+	}
+	msg := unpackMDNSReply(t, reply)
+	if len(msg.Answers) != 1 {
+		t.Fatalf("%d answers to an A query, want 1", len(msg.Answers))
+	}
+	if a := msg.Answers[0].Body.(*dnsmessage.AResource).A; netip.AddrFrom4(a) != netip.MustParseAddr("192.168.1.20") {
+		t.Errorf("answered %v", a)
+	}
+
+	// Asking for a unicast reply
+	if _, unicast := m.answer(mdnsQuery(t, 0, "distro-seed.local.", dnsmessage.TypeAAAA, dnsmessage.ClassINET|mdnsUnicast), false); !unicast {
+		t.Error("QU question answered over multicast")
+	}
+	// From a plain resolver, which needs its ID and question back
+	msg = unpackMDNSReply(t, mustReply(m.answer(mdnsQuery(t, 1234, "distro-seed.local.", dnsmessage.TypeA, dnsmessage.ClassINET), true)))
+	if msg.ID != 1234 || len(msg.Questions) != 1 {
+		t.Errorf("legacy reply has ID %d and %d questions", msg.ID, len(msg.Questions))
+	}
+	if reply, _ := m.answer(mdnsQuery(t, 0, "other.local.", dnsmessage.TypeA, dnsmessage.ClassINET), false); reply != nil {
+		t.Error("answered for another host")
+	}
+}
+
+func mustReply(reply []byte, _ bool) []byte {
+	return reply
+}
+
+func TestMDNSAdvertisesAPIService(t *testing.T) {
+	m := testMDNSAdvertiser(t)
+	msg := unpackMDNSReply(t, mustReply(m.answer(mdnsQuery(t, 0, "_http._tcp.local.", dnsmessage.TypePTR, dnsmessage.ClassINET), false)))
+	if len(msg.Answers) != 1 || msg.Answers[0].Body.(*dnsmessage.PTRResource).PTR.String() != "distro-seed._http._tcp.local." {
+		t.Fatalf("browsing answered %v", msg.Answers)
+	}
+	var port uint16
+	for _, r := range msg.Additionals {
+		if srv, ok := r.Body.(*dnsmessage.SRVResource); ok {
+			port = srv.Port
+		}
+	}
+	if port != 8080 {
+		t.Errorf("SRV port %d, want 8080", port)
+	}
+
+	withoutAPI, _ := newMDNSAdvertiser(nil, "distro-seed", 0, false)
+	if reply, _ := withoutAPI.answer(mdnsQuery(t, 0, mdnsServices, dnsmessage.TypePTR, dnsmessage.ClassINET), false); reply != nil {
+		t.Error("service advertised without an API")
+	}
+	if _, err := newMDNSAdvertiser(nil, "distro seed", 0, false); err == nil {
+		t.Error("name with a space is valid")
+	}
+}
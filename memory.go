@@ -0,0 +1,51 @@
+package distroseed
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	memoryCheckInterval = 30 * time.Second // How often memory use is checked against -max-memory
+	memoryWarnInterval  = 1 * time.Hour    // How often going over -max-memory is logged
+)
+
+// processMemory returns the memory the Go runtime holds from the OS
+func processMemory() uint64 {
+	samples := []metrics.Sample{
+		{Name: "/memory/classes/total:bytes"},
+		{Name: "/memory/classes/heap/released:bytes"},
+	}
+	metrics.Read(samples)
+	return samples[0].Value.Uint64() - samples[1].Value.Uint64()
+}
+
+// watchMemory keeps memory use near the target by having the garbage collector work harder as
+// it's approached, and handing freed memory back to the OS when it's exceeded
+func watchMemory(ctx context.Context, maxMemory int64) {
+	debug.SetMemoryLimit(maxMemory)
+	ticker := time.NewTicker(memoryCheckInterval)
+	defer ticker.Stop()
+
+	var lastWarned time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			used := processMemory()
+			if used <= uint64(maxMemory) {
+				continue
+			}
+			debug.FreeOSMemory()
+			if now.Sub(lastWarned) >= memoryWarnInterval {
+				lastWarned = now
+				log.Printf("⚠️ Using %.0f MB of memory, over the %.0f MB target. Lower -max-conns or -peer-request-buffer to use less.",
+					float64(used)/1024/1024, float64(maxMemory)/1024/1024)
+			}
+		}
+	}
+}
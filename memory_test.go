@@ -0,0 +1,9 @@
+package distroseed
+
+import "testing"
+
+func TestProcessMemory(t *testing.T) {
+	if got := processMemory(); got == 0 || got > 1<<40 {
+		t.Errorf("processMemory = %d", got)
+	}
+}
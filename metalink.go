@@ -0,0 +1,263 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+const maxMetalinkSize = 10 << 20
+
+// metalink is a Metalink 4 document (RFC 5854), describing files with their hashes, mirrors, and
+// torrents
+type metalink struct {
+	Files []metalinkFile `xml:"file"`
+}
+
+type metalinkFile struct {
+	Name     string            `xml:"name,attr"`
+	Size     int64             `xml:"size"`
+	Hashes   []metalinkHash    `xml:"hash"`
+	URLs     []metalinkMirror  `xml:"url"`
+	MetaURLs []metalinkMetaURL `xml:"metaurl"`
+}
+
+type metalinkHash struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type metalinkMirror struct {
+	Priority int    `xml:"priority,attr"`
+	URL      string `xml:",chardata"`
+}
+
+type metalinkMetaURL struct {
+	MediaType string `xml:"mediatype,attr"`
+	Priority  int    `xml:"priority,attr"`
+	URL       string `xml:",chardata"`
+}
+
+// Hash types checked, strongest first
+var metalinkHashTypes = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{"sha-512", sha512.New},
+	{"sha-384", sha512.New384},
+	{"sha-256", sha256.New},
+	{"sha-1", sha1.New},
+}
+
+func isMetalinkURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && strings.HasSuffix(u.Path, ".meta4")
+}
+
+// metalinkChecks holds the hashes metalinks give for torrents' files, by infohash, so downloads
+// can be checked against them
+type metalinkChecks struct {
+	seeder *Seeder
+	mu     sync.Mutex
+	files  map[string][]metalinkFile
+}
+
+// addMetalink adds the torrent a metalink points to, with its mirrors as webseeds. The metalink
+// is fetched each time for an up to date mirror list, falling back to the last copy saved.
+func (s *Seeder) addMetalink(ctx context.Context, client *torrent.Client, metalinkURL, downloadDir string) (*torrent.Torrent, error) {
+	ml, err := s.loadMetalink(ctx, metalinkURL, downloadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	torrentURL, err := ml.torrentURL()
+	if err != nil {
+		return nil, err
+	}
+	files := slices.Clone(ml.Files)
+
+	var t *torrent.Torrent
+	if strings.HasPrefix(torrentURL, "magnet:?") {
+		spec, err := torrent.TorrentSpecFromMagnetUri(torrentURL)
+		if err != nil {
+			return nil, fmt.Errorf("❌ Invalid magnet link in metalink: %w", err)
+		}
+		if dir, ok := s.liveSettings.Get().TorrentDirs[metalinkURL]; ok {
+			s.placement.Assign(spec.InfoHash.HexString(), dir)
+		}
+		if existing, ok := s.existingTorrent(client, spec.InfoHash, spec.Trackers, spec.Webseeds); ok {
+			t = existing
+		} else if t, _, err = client.AddTorrentSpec(spec); err != nil {
+			return nil, fmt.Errorf("❌ Failed to add torrent: %w", err)
+		}
+		s.applyTorrentOptions(t, s.liveSettings.Get().TorrentOptions[metalinkURL])
+	} else {
+		creds := s.liveSettings.Get().TorrentOptions[metalinkURL].credentials()
+		meta, err := s.loadTorrentFile(ctx, torrentURL, downloadDir, creds.For(metalinkURL, torrentURL))
+		if err != nil {
+			return nil, err
+		}
+		if t, err = s.addTorrentMeta(client, meta, metalinkURL); err != nil {
+			return nil, err
+		}
+	}
+
+	// Mirrors serve the file itself, which as a webseed only works for single file torrents
+	if len(ml.Files) == 1 {
+		mirrors := slices.Clone(ml.Files[0].URLs)
+		slices.SortStableFunc(mirrors, func(a, b metalinkMirror) int {
+			return cmp.Compare(metalinkPriority(a.Priority), metalinkPriority(b.Priority))
+		})
+		var webseeds []string
+		for _, m := range mirrors {
+			webseeds = append(webseeds, strings.TrimSpace(m.URL))
+		}
+		t.AddWebSeeds(webseeds)
+		log.Printf("🪞 Added %d webseeds from metalink %s", len(webseeds), metalinkURL)
+	}
+
+	s.metalinks.mu.Lock()
+	s.metalinks.files[t.InfoHash().HexString()] = files
+	s.metalinks.mu.Unlock()
+	return t, nil
+}
+
+// torrentURL returns the URL of the torrent with the highest priority, where lower numbers come
+// first and 0 is unset
+func (ml *metalink) torrentURL() (string, error) {
+	var best *metalinkMetaURL
+	for i := range ml.Files {
+		f := &ml.Files[i]
+		for j := range f.MetaURLs {
+			m := &f.MetaURLs[j]
+			if m.MediaType == "torrent" && (best == nil || metalinkPriority(m.Priority) < metalinkPriority(best.Priority)) {
+				best = m
+			}
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("❌ Metalink has no torrent")
+	}
+	return strings.TrimSpace(best.URL), nil
+}
+
+// Priorities run from 1, highest, to 999999, and unset ones come last
+func metalinkPriority(p int) int {
+	if p <= 0 {
+		return 1000000
+	}
+	return p
+}
+
+// loadMetalink downloads and parses a metalink, saving it in downloadDir, or reads the saved copy
+// if it can't be downloaded
+func (s *Seeder) loadMetalink(ctx context.Context, metalinkURL, downloadDir string) (*metalink, error) {
+	savedPath := filepath.Join(downloadDir, path.Base(metalinkURL))
+	data, err := downloadMetalink(ctx, metalinkURL, s.liveSettings.Get().TorrentOptions[metalinkURL].credentials())
+	if err != nil {
+		saved, readErr := os.ReadFile(savedPath)
+		if readErr != nil {
+			return nil, err
+		}
+		log.Printf("%v, using saved copy", err)
+		data = saved
+	} else if err := os.WriteFile(savedPath, data, 0644); err != nil {
+		log.Printf("Error: Failed to save metalink: %v", err)
+	}
+
+	var ml metalink
+	if err := xml.Unmarshal(data, &ml); err != nil {
+		return nil, fmt.Errorf("❌ Failed to parse metalink '%s': %w", metalinkURL, err)
+	}
+	return &ml, nil
+}
+
+func downloadMetalink(ctx context.Context, metalinkURL string, creds fetch.Credentials) ([]byte, error) {
+	log.Printf("📥 Downloading metalink: %s", metalinkURL)
+	data, err := fetch.Get(ctx, metalinkURL, creds, maxMetalinkSize)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to download metalink: %w", err)
+	}
+	return data, nil
+}
+
+// verifyDownload checks a torrent downloaded in this session against its metalink's hashes in
+// the background, and stops uploading it if they don't match. Data that was already on disk was
+// checked when it was downloaded.
+func (c *metalinkChecks) verifyDownload(t *torrent.Torrent) {
+	c.mu.Lock()
+	files, ok := c.files[t.InfoHash().HexString()]
+	c.mu.Unlock()
+	stats := t.Stats()
+	if !ok || stats.BytesReadUsefulData.Int64() == 0 {
+		return
+	}
+	go func() {
+		for _, mf := range files {
+			if err := checkMetalinkFile(t, mf); err != nil {
+				log.Printf("❌ %s doesn't match its metalink, no longer uploading it: %v", t.Name(), err)
+				c.seeder.torrentErrors.Fail(t, errorVerification, fmt.Errorf("doesn't match its metalink: %w", err), nil)
+				if c.seeder.notifications != nil {
+					c.seeder.notifications.Notify("metalink:"+t.InfoHash().HexString(), "Metalink hash mismatch: "+t.Name(),
+						fmt.Sprintf("%s was downloaded but doesn't match the hashes in its metalink, so it isn't being uploaded: %v", t.Name(), err))
+				}
+				return
+			}
+		}
+		log.Printf("✅ Verified %s against its metalink", t.Name())
+	}()
+}
+
+// checkMetalinkFile hashes the torrent's copy of a file with the strongest hash the metalink
+// gives for it. Files the torrent doesn't have, or without hashes, are skipped.
+func checkMetalinkFile(t *torrent.Torrent, mf metalinkFile) error {
+	var file *torrent.File
+	for _, f := range t.Files() {
+		if f.DisplayPath() == mf.Name || f.Path() == mf.Name {
+			file = f
+		}
+	}
+	if file == nil {
+		return nil
+	}
+	if mf.Size != 0 && file.Length() != mf.Size {
+		return fmt.Errorf("%s is %d bytes, not %d", mf.Name, file.Length(), mf.Size)
+	}
+
+	for _, ht := range metalinkHashTypes {
+		i := slices.IndexFunc(mf.Hashes, func(h metalinkHash) bool { return strings.EqualFold(h.Type, ht.name) })
+		if i < 0 {
+			continue
+		}
+		// Read through the client, so the payload is decrypted if it's stored encrypted
+		r := file.NewReader()
+		defer r.Close()
+		h := ht.new()
+		if _, err := io.Copy(h, r); err != nil {
+			return fmt.Errorf("failed to read %s: %w", mf.Name, err)
+		}
+		if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, strings.TrimSpace(mf.Hashes[i].Value)) {
+			return fmt.Errorf("%s of %s is %s, not %s", ht.name, mf.Name, sum, strings.TrimSpace(mf.Hashes[i].Value))
+		}
+		return nil
+	}
+	return nil
+}
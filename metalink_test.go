@@ -0,0 +1,109 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddMetalink(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, runtimeConfig{})
+	setTestSeederState(s, dir)
+
+	var torrentFile bytes.Buffer
+	if err := newTestMeta(t, dir, "a.iso", 32<<10).Write(&torrentFile); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a.iso.meta4":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="a.iso">
+    <size>%d</size>
+    <hash type="sha-256">%s</hash>
+    <url priority="2">https://mirror2.example.com/a.iso</url>
+    <url priority="1">https://mirror1.example.com/a.iso</url>
+    <metaurl mediatype="torrent" priority="2">magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567</metaurl>
+    <metaurl mediatype="torrent" priority="1">%s/a.iso.torrent</metaurl>
+  </file>
+</metalink>`, len(data), hex.EncodeToString(sum[:]), server.URL)
+		case "/a.iso.torrent":
+			w.Write(torrentFile.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	metalinkURL := server.URL + "/a.iso.meta4"
+
+	tor, err := s.addMetalink(context.Background(), client, metalinkURL, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tor.Info() == nil || tor.Name() != "a.iso" {
+		t.Fatalf("addMetalink added %q, want the torrent file with the highest priority", tor.Name())
+	}
+	if err := tor.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+
+	s.metalinks.mu.Lock()
+	files := s.metalinks.files[tor.InfoHash().HexString()]
+	s.metalinks.mu.Unlock()
+	if len(files) != 1 {
+		t.Fatalf("metalink checks for %d files, want 1", len(files))
+	}
+	if err := checkMetalinkFile(tor, files[0]); err != nil {
+		t.Errorf("checkMetalinkFile: %v", err)
+	}
+	wrong := files[0]
+	wrong.Hashes = []metalinkHash{{Type: "sha-256", Value: strings.Repeat("0", 64)}}
+	if err := checkMetalinkFile(tor, wrong); err == nil {
+		t.Error("checkMetalinkFile passed with the wrong hash")
+	}
+
+	// The saved copy is used once the metalink can't be downloaded
+	tor.Drop()
+	server.Close()
+	if tor, err = s.addMetalink(context.Background(), client, metalinkURL, dir); err != nil {
+		t.Fatalf("addMetalink from the saved copy: %v", err)
+	}
+	if tor.Name() != "a.iso" {
+		t.Errorf("addMetalink from the saved copy added %q", tor.Name())
+	}
+}
+
+func TestIsMetalinkURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/a.iso.meta4", true},
+		{"https://example.com/a.iso.meta4?mirror=eu", true},
+		{"https://example.com/a.iso.torrent", false},
+		{"magnet:?xt=urn:btih:abc&dn=a.meta4", false},
+	}
+	for _, tt := range tests {
+		if got := isMetalinkURL(tt.url); got != tt.want {
+			t.Errorf("isMetalinkURL(%q) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
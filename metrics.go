@@ -0,0 +1,267 @@
+package distroseed
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const defaultStallAfter = 6 * time.Hour
+
+// alertThresholds decide when the gauges derived for simple alerting rules report a problem
+type alertThresholds struct {
+	StallAfter      time.Duration // Time without uploading while leechers are connected before a torrent is stalled
+	TrackerFailures int           // Consecutive failed announces before a tracker is failing
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m metricsWriter) family(name, kind, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes one value, with labels given as name/value pairs
+func (m metricsWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=%s", labels[i], strconv.Quote(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, "%s %s\n", b.String(), strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+// Serve the client's metrics for scraping by Prometheus
+func (a *apiServer) getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	a.seeder.writeMetrics(metricsWriter{w}, a.client)
+}
+
+func (s *Seeder) writeMetrics(m metricsWriter, client *torrent.Client) {
+	torrents := client.Torrents()
+	names := make(map[string]string, len(torrents))
+	for _, t := range torrents {
+		names[t.InfoHash().HexString()] = t.Name()
+	}
+
+	m.family("distro_seed_torrent_uploaded_bytes_total", "counter", "Bytes uploaded per torrent since start, across upgrades.")
+	for _, t := range torrents {
+		m.sample("distro_seed_torrent_uploaded_bytes_total", float64(s.sessionUploaded(t)),
+			"infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_lifetime_uploaded_bytes_total", "counter", "Bytes uploaded per torrent across all runs.")
+	for _, t := range torrents {
+		m.sample("distro_seed_torrent_lifetime_uploaded_bytes_total", float64(s.ledger.Lifetime(t.InfoHash().HexString())),
+			"infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_private", "gauge", "1 if the torrent is private, for keeping its uploads apart from public torrents'.")
+	for _, t := range torrents {
+		m.sample("distro_seed_torrent_private", boolGauge(isPrivate(t)), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_pex_peers_total", "counter", "Peers a torrent learned of through peer exchange since start.")
+	for _, t := range torrents {
+		m.sample("distro_seed_torrent_pex_peers_total", float64(s.pexPeers.Learned(t.InfoHash().HexString())), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_peers", "gauge", "Connected peers per torrent.")
+	for _, t := range torrents {
+		m.sample("distro_seed_torrent_peers", float64(len(t.PeerConns())),
+			"infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+
+	progress := make(map[*torrent.Torrent]downloadProgress)
+	for _, t := range torrents {
+		if p, ok := s.downloads.Progress(t); ok {
+			progress[t] = p
+		}
+	}
+	m.family("distro_seed_torrent_remaining_bytes", "gauge", "Bytes left to download per incomplete torrent.")
+	for t, p := range progress {
+		m.sample("distro_seed_torrent_remaining_bytes", float64(p.Remaining), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_download_rate_bytes", "gauge", "Smoothed download rate in bytes per second per incomplete torrent.")
+	for t, p := range progress {
+		m.sample("distro_seed_torrent_download_rate_bytes", float64(p.Rate), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_download_eta_seconds", "gauge", "Estimated seconds until an incomplete torrent is downloaded, 0 if unknown.")
+	for t, p := range progress {
+		m.sample("distro_seed_torrent_download_eta_seconds", time.Duration(p.ETA).Seconds(), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+
+	health := make(map[*torrent.Torrent]swarmHealth)
+	for _, t := range torrents {
+		if h, ok := swarmHealthOf(t); ok {
+			health[t] = h
+		}
+	}
+	m.family("distro_seed_torrent_swarm_seeds", "gauge", "Connected peers with the whole torrent.")
+	for t, h := range health {
+		m.sample("distro_seed_torrent_swarm_seeds", float64(h.Seeds), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_swarm_leechers", "gauge", "Connected peers missing part of the torrent.")
+	for t, h := range health {
+		m.sample("distro_seed_torrent_swarm_leechers", float64(h.Leechers), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	totals := make(map[*torrent.Torrent]swarmTotals)
+	for _, t := range torrents {
+		if st, ok := s.scrapes.Totals(t.InfoHash().HexString()); ok {
+			totals[t] = st
+		}
+	}
+	m.family("distro_seed_torrent_tracker_seeders", "gauge", "Seeders in the whole swarm, from the last tracker scrape.")
+	for t, st := range totals {
+		m.sample("distro_seed_torrent_tracker_seeders", float64(st.Seeders), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_tracker_leechers", "gauge", "Leechers in the whole swarm, from the last tracker scrape.")
+	for t, st := range totals {
+		m.sample("distro_seed_torrent_tracker_leechers", float64(st.Leechers), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_tracker_completed", "gauge", "Completed downloads the tracker has seen, from the last tracker scrape.")
+	for t, st := range totals {
+		m.sample("distro_seed_torrent_tracker_completed", float64(st.Completed), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_only_seed", "gauge", "1 if we have the whole torrent and no connected peer does.")
+	for t, h := range health {
+		m.sample("distro_seed_torrent_only_seed", boolGauge(h.OnlySeed), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	now := time.Now()
+	m.family("distro_seed_torrent_stalled", "gauge", "1 if leechers are connected but nothing was uploaded for the stall threshold.")
+	for t, h := range health {
+		m.sample("distro_seed_torrent_stalled", boolGauge(s.torrentStalled(t, h, now)), "infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_torrent_error", "gauge", "1 if the torrent is stopped by an error, labeled with its kind.")
+	for _, t := range torrents {
+		if te, ok := s.torrentErrors.Error(t.InfoHash().HexString()); ok {
+			m.sample("distro_seed_torrent_error", 1, "infohash", t.InfoHash().HexString(), "name", t.Name(), "kind", te.Kind)
+		}
+	}
+	m.family("distro_seed_torrent_piece_availability", "gauge", "Pieces per torrent by how many connected peers have them.")
+	for t, h := range health {
+		for _, b := range availabilityBuckets {
+			m.sample("distro_seed_torrent_piece_availability", float64(h.Availability[b.label]),
+				"infohash", t.InfoHash().HexString(), "name", t.Name(), "copies", b.label)
+		}
+	}
+
+	m.family("distro_seed_torrent_transfer_rate_bytes", "gauge", "Upload and download rate in bytes per second per torrent, currently and averaged over 1m and 15m.")
+	for _, t := range torrents {
+		writeRateSamples(m, "distro_seed_torrent_transfer_rate_bytes", s.rates.Torrent(t.InfoHash().HexString()),
+			"infohash", t.InfoHash().HexString(), "name", t.Name())
+	}
+	m.family("distro_seed_transfer_rate_bytes", "gauge", "Upload and download rate in bytes per second across all torrents, currently and averaged over 1m and 15m.")
+	writeRateSamples(m, "distro_seed_transfer_rate_bytes", s.rates.Total())
+
+	trackerHealth := s.trackers.Snapshot()
+	m.family("distro_seed_tracker_consecutive_failures", "gauge", "Announces to a tracker that failed in a row.")
+	for url, h := range trackerHealth {
+		m.sample("distro_seed_tracker_consecutive_failures", float64(h.ConsecutiveFailures), "tracker", url)
+	}
+	m.family("distro_seed_tracker_failing", "gauge", "1 if enough announces to a tracker failed in a row to consider it down.")
+	for url, h := range trackerHealth {
+		m.sample("distro_seed_tracker_failing", boolGauge(h.ConsecutiveFailures >= s.alerts.TrackerFailures), "tracker", url)
+	}
+
+	m.family("distro_seed_tracker_announces_total", "counter", "Announces to trackers since start, by protocol and whether they succeeded.")
+	protocols := s.trackers.Protocols()
+	for _, protocol := range slices.Sorted(maps.Keys(protocols)) {
+		p := protocols[protocol]
+		m.sample("distro_seed_tracker_announces_total", float64(p.Announces-p.Failures), "protocol", protocol, "result", "success")
+		m.sample("distro_seed_tracker_announces_total", float64(p.Failures), "protocol", protocol, "result", "failure")
+	}
+
+	sources := s.peerSources.Totals()
+	m.family("distro_seed_peer_source_transferred_bytes_total", "counter", "Bytes transferred with peers since start, by how the peers were discovered.")
+	for _, source := range peerSourceNames {
+		if t, ok := sources[source]; ok {
+			m.sample("distro_seed_peer_source_transferred_bytes_total", float64(t.Uploaded), "source", source, "direction", "upload")
+			m.sample("distro_seed_peer_source_transferred_bytes_total", float64(t.Downloaded), "source", source, "direction", "download")
+		}
+	}
+	m.family("distro_seed_peer_source_connections_total", "counter", "Peer connections made since start, by how the peers were discovered.")
+	for _, source := range peerSourceNames {
+		if t, ok := sources[source]; ok {
+			m.sample("distro_seed_peer_source_connections_total", float64(t.Peers), "source", source)
+		}
+	}
+
+	if s.quota != nil {
+		q := s.quota.Status()
+		m.family("distro_seed_quota_bytes", "gauge", "Bytes that may be uploaded in the monthly quota period.")
+		m.sample("distro_seed_quota_bytes", float64(q.Quota))
+		m.family("distro_seed_quota_used_bytes", "gauge", "Bytes uploaded so far in the monthly quota period.")
+		m.sample("distro_seed_quota_used_bytes", float64(q.Used))
+	}
+
+	writeConnMetrics(m, s.connections.Snapshot(), names)
+}
+
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// torrentStalled reports whether a torrent has leechers connected but hasn't uploaded for the
+// stall threshold, leaving out torrents held back from uploading on purpose
+func (s *Seeder) torrentStalled(t *torrent.Torrent, h swarmHealth, now time.Time) bool {
+	ih := t.InfoHash().HexString()
+	last := s.rates.LastUpload(ih)
+	if h.Leechers == 0 || last.IsZero() || now.Sub(last) < s.alerts.StallAfter {
+		return false
+	}
+	held := s.queue.kind(ih) == queuedSeed || s.uploadOnly.Held(ih) || s.schedule.Paused() || s.pauses.IsPaused(ih) || (s.quota != nil && s.quota.Status().Paused)
+	return !held
+}
+
+func writeRateSamples(m metricsWriter, name string, r transferRates, labels ...string) {
+	for _, s := range []struct {
+		direction, window string
+		value             int64
+	}{
+		{"upload", "current", r.Upload},
+		{"upload", "1m", r.Upload1m},
+		{"upload", "15m", r.Upload15m},
+		{"download", "current", r.Download},
+		{"download", "1m", r.Download1m},
+		{"download", "15m", r.Download15m},
+	} {
+		m.sample(name, float64(s.value), append(labels[:len(labels):len(labels)], "direction", s.direction, "window", s.window)...)
+	}
+}
+
+func writeConnMetrics(m metricsWriter, snapshot map[string]connStats, names map[string]string) {
+	m.family("distro_seed_peer_connections_opened_total", "counter", "Peer connections established per torrent.")
+	for ih, stats := range snapshot {
+		m.sample("distro_seed_peer_connections_opened_total", float64(stats.Opened), "infohash", ih, "name", names[ih])
+	}
+	m.family("distro_seed_peer_connections_closed_total", "counter", "Peer connections closed per torrent.")
+	for ih, stats := range snapshot {
+		m.sample("distro_seed_peer_connections_closed_total", float64(stats.Closed), "infohash", ih, "name", names[ih])
+	}
+	m.family("distro_seed_peer_connection_lifetime_seconds", "histogram", "Lifetime of closed peer connections per torrent.")
+	for ih, stats := range snapshot {
+		for i, bound := range connLifetimeBuckets {
+			m.sample("distro_seed_peer_connection_lifetime_seconds_bucket", float64(stats.LifetimeBuckets[i]),
+				"infohash", ih, "name", names[ih], "le", strconv.FormatFloat(bound, 'g', -1, 64))
+		}
+		m.sample("distro_seed_peer_connection_lifetime_seconds_bucket", float64(stats.Closed), "infohash", ih, "name", names[ih], "le", "+Inf")
+		m.sample("distro_seed_peer_connection_lifetime_seconds_sum", stats.LifetimeSum, "infohash", ih, "name", names[ih])
+		m.sample("distro_seed_peer_connection_lifetime_seconds_count", float64(stats.Closed), "infohash", ih, "name", names[ih])
+	}
+}
@@ -0,0 +1,64 @@
+package distroseed
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pawl/distro-seed/announce"
+)
+
+func TestTorrentStalled(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	resetTestState(s, testConfig())
+	client := newTestClient(t, dir)
+	tt := addSeedingTestTorrent(t, client, dir, "a.iso")
+	ih := tt.InfoHash().HexString()
+	s.rates = newRateMeter()
+
+	start := time.Now()
+	s.rates.sample(map[string]transferCounters{ih: {Uploaded: 100}}, transferCounters{}, start)
+	s.rates.sample(map[string]transferCounters{ih: {Uploaded: 200}}, transferCounters{}, start.Add(time.Hour))
+	s.rates.sample(map[string]transferCounters{ih: {Uploaded: 200}}, transferCounters{}, start.Add(2*time.Hour))
+	if last := s.rates.LastUpload(ih); !last.Equal(start.Add(time.Hour)) {
+		t.Fatalf("last upload at %s, want an hour in", last)
+	}
+
+	leeching := swarmHealth{Leechers: 2}
+	later := start.Add(time.Hour + s.alerts.StallAfter)
+	if s.torrentStalled(tt, swarmHealth{}, later) {
+		t.Error("stalled without leechers")
+	}
+	if s.torrentStalled(tt, leeching, later.Add(-time.Minute)) {
+		t.Error("stalled before the threshold")
+	}
+	if !s.torrentStalled(tt, leeching, later) {
+		t.Error("not stalled with leechers and no uploads past the threshold")
+	}
+}
+
+func TestTrackerFailingMetric(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.trackers = announce.NewStatus()
+	for range s.alerts.TrackerFailures {
+		s.trackers.Record("http://down.example.com/announce", "connection refused")
+	}
+	s.trackers.Record("http://up.example.com/announce", "")
+
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	resetTestState(s, testConfig())
+	var b strings.Builder
+	s.writeMetrics(metricsWriter{&b}, newTestClient(t, dir))
+	for _, want := range []string{
+		`distro_seed_tracker_failing{tracker="http://down.example.com/announce"} 1`,
+		`distro_seed_tracker_failing{tracker="http://up.example.com/announce"} 0`,
+		`distro_seed_tracker_consecutive_failures{tracker="http://down.example.com/announce"} 3`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("metrics are missing %s", want)
+		}
+	}
+}
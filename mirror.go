@@ -0,0 +1,211 @@
+package distroseed
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+// mirrorManifest lists the files of an rsync-based mirror with their SHA256 sums, in the format
+// written by sha256sum. Torrent files found in the mirror are linked into the download directory
+// so they are seeded without being downloaded.
+type mirrorManifest struct {
+	seeder  *Seeder
+	root    string // Directory manifest paths are relative to
+	entries []manifestEntry
+	byName  map[string][]int // File name to entry indexes
+
+	mu                sync.Mutex
+	matched           map[int]string    // Entry index to the infohash it was matched to
+	unmatchedTorrents map[string]string // Infohash to name of torrents with files not in the mirror
+}
+
+type manifestEntry struct {
+	Path   string
+	SHA256 string
+}
+
+func loadMirrorManifest(s *Seeder, manifestPath, root string) (*mirrorManifest, error) {
+	file, err := os.Open(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to open mirror manifest: %w", err)
+	}
+	defer file.Close()
+
+	if root == "" {
+		root = filepath.Dir(manifestPath)
+	}
+	m := &mirrorManifest{
+		seeder:            s,
+		root:              root,
+		byName:            make(map[string][]int),
+		matched:           make(map[int]string),
+		unmatchedTorrents: make(map[string]string),
+	}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sum, p, ok := strings.Cut(text, " ")
+		if !ok || len(sum) != sha256.Size*2 {
+			return nil, fmt.Errorf("❌ Invalid mirror manifest line %d: %q", line, text)
+		}
+		// sha256sum marks binary mode with a leading '*'
+		p = strings.TrimPrefix(strings.TrimLeft(p, " "), "*")
+		m.byName[path.Base(p)] = append(m.byName[path.Base(p)], len(m.entries))
+		m.entries = append(m.entries, manifestEntry{Path: p, SHA256: strings.ToLower(sum)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("❌ Failed to read mirror manifest: %w", err)
+	}
+	log.Printf("🪞 Loaded mirror manifest with %d files from %s", len(m.entries), manifestPath)
+	return m, nil
+}
+
+// reconcile links the torrent's missing files in from the mirror, matched by name and size and
+// checked against the manifest's sum, then has the client verify them so they can be seeded
+func (m *mirrorManifest) reconcile(ctx context.Context, t *torrent.Torrent) {
+	var linked, missing int
+	for _, f := range t.Files() {
+		if f.BytesCompleted() == f.Length() {
+			// Already have it, just account for the mirror's copy
+			if index, ok := m.findFile(path.Base(f.Path()), f.Length(), false); ok {
+				m.mu.Lock()
+				m.matched[index] = t.InfoHash().HexString()
+				m.mu.Unlock()
+			}
+			continue
+		}
+		dest := filepath.Join(m.seeder.placement.Dir(t.InfoHash().HexString()), filepath.FromSlash(f.Path()))
+		if _, err := os.Stat(dest); err == nil {
+			continue // Partially downloaded already
+		}
+
+		index, ok := m.findFile(path.Base(f.Path()), f.Length(), true)
+		if !ok {
+			missing++
+			continue
+		}
+		if err := linkMirrorFile(filepath.Join(m.root, m.entries[index].Path), dest); err != nil {
+			log.Printf("⚠️ Error linking %s from mirror: %v", f.Path(), err)
+			missing++
+			continue
+		}
+		m.mu.Lock()
+		m.matched[index] = t.InfoHash().HexString()
+		m.mu.Unlock()
+		linked++
+	}
+
+	m.mu.Lock()
+	if missing > 0 {
+		m.unmatchedTorrents[t.InfoHash().HexString()] = t.Name()
+	} else {
+		delete(m.unmatchedTorrents, t.InfoHash().HexString())
+	}
+	m.mu.Unlock()
+
+	if missing > 0 {
+		log.Printf("🪞 %d files of %s aren't in the mirror and will be downloaded", missing, t.Name())
+	}
+	if linked > 0 {
+		log.Printf("🪞 Verifying %d files of %s from the mirror...", linked, t.Name())
+		if err := t.VerifyDataContext(ctx); err != nil {
+			log.Printf("⚠️ Error verifying %s: %v", t.Name(), err)
+			return
+		}
+		log.Printf("🪞 %s is available locally from the mirror", t.Name())
+		m.seeder.recordVerified(t)
+	}
+}
+
+// Find a manifest entry with the given name whose file has the expected size, and optionally sum
+func (m *mirrorManifest) findFile(name string, size int64, checkSum bool) (int, bool) {
+	for _, index := range m.byName[name] {
+		entry := m.entries[index]
+		src := filepath.Join(m.root, entry.Path)
+		info, err := os.Stat(src)
+		if err != nil || info.Size() != size {
+			continue
+		}
+		if !checkSum {
+			return index, true
+		}
+		sum, err := sha256File(src)
+		if err != nil {
+			log.Printf("⚠️ Error hashing mirror file %s: %v", src, err)
+			continue
+		}
+		if sum != entry.SHA256 {
+			log.Printf("⚠️ Mirror file %s doesn't match its manifest sum", src)
+			continue
+		}
+		return index, true
+	}
+	return 0, false
+}
+
+// Hard link the mirror file into place, or symlink it if it's on another filesystem
+func linkMirrorFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	if err := os.Link(src, dest); err == nil {
+		return nil
+	}
+	abs, err := filepath.Abs(src)
+	if err != nil {
+		return err
+	}
+	return os.Symlink(abs, dest)
+}
+
+func sha256File(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// mirrorReport lists what couldn't be matched between the mirror and the torrents
+type mirrorReport struct {
+	MatchedFiles      int      `json:"matched_files"`
+	UnmatchedTorrents []string `json:"unmatched_torrents"` // Torrents with files missing from the mirror
+	UnmatchedFiles    []string `json:"unmatched_files"`    // Mirror files not in any torrent
+}
+
+func (m *mirrorManifest) Report() mirrorReport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := mirrorReport{MatchedFiles: len(m.matched), UnmatchedTorrents: []string{}, UnmatchedFiles: []string{}}
+	for _, name := range m.unmatchedTorrents {
+		r.UnmatchedTorrents = append(r.UnmatchedTorrents, name)
+	}
+	for i, entry := range m.entries {
+		if _, ok := m.matched[i]; !ok {
+			r.UnmatchedFiles = append(r.UnmatchedFiles, entry.Path)
+		}
+	}
+	slices.Sort(r.UnmatchedTorrents)
+	return r
+}
@@ -0,0 +1,85 @@
+package distroseed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMirrorReconcileLinksFiles(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	mirrorDir, downloadDir := t.TempDir(), t.TempDir()
+	mi := newTestMeta(t, mirrorDir, "a.iso", 64<<10)
+	sum, err := sha256File(filepath.Join(mirrorDir, "a.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := filepath.Join(mirrorDir, "SHA256SUMS")
+	content := "# Mirror files\n" + sum + " *a.iso\n" + strings.Repeat("0", 64) + "  b.iso\n"
+	if err := os.WriteFile(manifest, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadMirrorManifest(s, manifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setTestSeederState(s, downloadDir)
+	client := newTestClient(t, downloadDir)
+	tt, err := client.AddTorrent(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.reconcile(context.Background(), tt)
+	if !tt.Complete().Bool() {
+		t.Error("the torrent isn't complete from the mirror's copy")
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "a.iso")); err != nil {
+		t.Errorf("the mirror's copy wasn't linked: %v", err)
+	}
+	want := mirrorReport{MatchedFiles: 1, UnmatchedTorrents: []string{}, UnmatchedFiles: []string{"b.iso"}}
+	if got := m.Report(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Report = %+v, want %+v", got, want)
+	}
+}
+
+func TestMirrorReconcileSkipsMismatchedSums(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	mirrorDir, downloadDir := t.TempDir(), t.TempDir()
+	mi := newTestMeta(t, mirrorDir, "a.iso", 64<<10)
+	manifest := filepath.Join(mirrorDir, "SHA256SUMS")
+	if err := os.WriteFile(manifest, []byte(strings.Repeat("0", 64)+"  a.iso\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m, err := loadMirrorManifest(s, manifest, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setTestSeederState(s, downloadDir)
+	client := newTestClient(t, downloadDir)
+	tt, err := client.AddTorrent(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.reconcile(context.Background(), tt)
+	if _, err := os.Stat(filepath.Join(downloadDir, "a.iso")); !os.IsNotExist(err) {
+		t.Errorf("a file that doesn't match its sum was linked: %v", err)
+	}
+	if got := m.Report().UnmatchedTorrents; !reflect.DeepEqual(got, []string{"a.iso"}) {
+		t.Errorf("unmatched torrents = %v, want [a.iso]", got)
+	}
+}
+
+func TestLoadMirrorManifestRejectsInvalidLines(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	manifest := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := os.WriteFile(manifest, []byte("\nabc123  a.iso\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadMirrorManifest(s, manifest, ""); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("loadMirrorManifest error = %v, want one for line 2", err)
+	}
+}
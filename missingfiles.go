@@ -0,0 +1,149 @@
+package distroseed
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	missingFilesCheckInterval = 5 * time.Minute
+	readFailureCheckInterval  = 10 * time.Second // How often the client's failed reads are looked at
+)
+
+// payloadFile is a file of a torrent's data the client has at least some of
+type payloadFile struct {
+	path     string
+	length   int64
+	complete bool // Whether all of it was downloaded, so it should be at least length long
+}
+
+// payloadFiles returns the files the client has data of for the torrent, where the file storage
+// lays them out under the torrent's directory
+func (s *Seeder) payloadFiles(t *torrent.Torrent) []payloadFile {
+	if t.Info() == nil {
+		return nil
+	}
+	var files []payloadFile
+	for _, f := range t.Files() {
+		completed := f.BytesCompleted()
+		if completed == 0 {
+			continue
+		}
+		files = append(files, payloadFile{
+			path:     s.placement.payloadPath(t, f),
+			length:   f.Length(),
+			complete: completed == f.Length(),
+		})
+	}
+	return files
+}
+
+// payloadPath is where the file storage keeps one of the torrent's files
+func (p *dataPlacement) payloadPath(t *torrent.Torrent, f *torrent.File) string {
+	fi := f.FileInfo()
+	return filepath.Join(append([]string{p.Dir(t.InfoHash().HexString()), t.Info().BestName()}, fi.BestPath()...)...)
+}
+
+// missingFiles returns why the files are missing, for those that are gone or cut short
+func missingFiles(files []payloadFile) []error {
+	var missing []error
+	for _, f := range files {
+		info, err := os.Stat(f.path)
+		switch {
+		case err != nil:
+			missing = append(missing, err)
+		case f.complete && info.Size() < f.length:
+			missing = append(missing, fmt.Errorf("%s is %d bytes, not %d", f.path, info.Size(), f.length))
+		}
+	}
+	return missing
+}
+
+// missingFilesError describes the missing files of a torrent, or the directory they were in
+// being gone, like after a disk was unmounted
+func (s *Seeder) missingFilesError(t *torrent.Torrent, missing []error) error {
+	dir := s.placement.Dir(t.InfoHash().HexString())
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("its directory is gone: %w", err)
+	}
+	if len(missing) == 1 {
+		return missing[0]
+	}
+	return fmt.Errorf("%d files are gone or cut short, like %w", len(missing), missing[0])
+}
+
+// checkMissingFiles stops the torrents whose data was deleted or cut short since it was
+// downloaded or verified, which would otherwise fail peers' requests one by one. They're
+// retried until the files are back, and then verified again.
+func (s *Seeder) checkMissingFiles(client *torrent.Client) {
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		if t.Info() == nil || s.torrentErrors.Failed(ih) || s.uploadOnly.Held(ih) {
+			continue
+		}
+		files := s.payloadFiles(t)
+		missing := missingFiles(files)
+		if len(missing) == 0 {
+			continue
+		}
+		retry := func() error {
+			if missing := missingFiles(files); len(missing) > 0 {
+				return s.missingFilesError(t, missing)
+			}
+			// Pieces that failed to be read were marked incomplete, so the data is checked again
+			go func() {
+				if err := t.VerifyData(); err == nil {
+					s.recordVerified(t)
+				}
+			}()
+			return nil
+		}
+		if s.torrentErrors.Fail(t, errorMissingFiles, s.missingFilesError(t, missing), retry) {
+			if te, ok := s.torrentErrors.Error(ih); ok {
+				s.notifications.notifyTorrentError(t, te)
+			}
+		}
+	}
+}
+
+// readFailures returns how many times the client couldn't read data peers asked for, which
+// is usually because it's gone from disk
+func readFailures() int64 {
+	if m, ok := expvar.Get("torrent").(*expvar.Map); ok {
+		if n, ok := m.Get("peer request data read failures").(*expvar.Int); ok {
+			return n.Value()
+		}
+	}
+	return 0
+}
+
+// watchMissingFiles checks for missing files every few minutes, and straight away when the
+// client fails to read data for peers, until the context is cancelled
+func (s *Seeder) watchMissingFiles(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(missingFilesCheckInterval)
+	defer ticker.Stop()
+	reads := time.NewTicker(readFailureCheckInterval)
+	defer reads.Stop()
+	failures := readFailures()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.checkMissingFiles(client)
+		case <-reads.C:
+			if n := readFailures(); n > failures {
+				log.Printf("🔍 Reading data for peers failed %d times, checking for missing files", n-failures)
+				failures = n
+				s.checkMissingFiles(client)
+			}
+		}
+	}
+}
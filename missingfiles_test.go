@@ -0,0 +1,71 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	setTestSeederState(s, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	addSeedingTestTorrent(t, client, dir, "b.iso")
+	ih := a.InfoHash().HexString()
+	path := filepath.Join(dir, "a.iso")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.checkMissingFiles(client)
+	if s.torrentErrors.Failed(ih) {
+		t.Fatal("torrent with all its files failed")
+	}
+
+	os.Remove(path)
+	s.checkMissingFiles(client)
+	te, ok := s.torrentErrors.Error(ih)
+	if !ok || te.Kind != errorMissingFiles || !strings.Contains(te.Message, "a.iso") || te.NextRetry == nil {
+		t.Fatalf("error after deleting a.iso = %+v", te)
+	}
+	if state := s.torrentState(a); state != "error" {
+		t.Errorf("state = %s, want error", state)
+	}
+	for _, other := range client.Torrents() {
+		if other != a && s.torrentErrors.Failed(other.InfoHash().HexString()) {
+			t.Error("torrent with all its files failed along with the other")
+		}
+	}
+
+	// Cut short isn't back yet
+	os.WriteFile(path, data[:len(data)/2], 0o644)
+	s.torrentErrors.retry(a, time.Now())
+	if te, _ := s.torrentErrors.Error(ih); te.Attempts != 1 || !strings.Contains(te.Message, "bytes") {
+		t.Errorf("error with a.iso cut short = %+v", te)
+	}
+
+	os.WriteFile(path, data, 0o644)
+	s.torrentErrors.retry(a, time.Now())
+	if s.torrentErrors.Failed(ih) || s.pauses.IsPaused(ih) {
+		t.Error("torrent is still stopped after its file came back")
+	}
+}
+
+func TestMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "partial"), make([]byte, 10), 0o644)
+	os.WriteFile(filepath.Join(dir, "short"), make([]byte, 10), 0o644)
+	missing := missingFiles([]payloadFile{
+		{path: filepath.Join(dir, "partial"), length: 100},
+		{path: filepath.Join(dir, "short"), length: 100, complete: true},
+		{path: filepath.Join(dir, "gone"), length: 100},
+	})
+	if len(missing) != 2 || !strings.Contains(missing[0].Error(), "short is 10 bytes, not 100") || !os.IsNotExist(missing[1]) {
+		t.Errorf("missing = %v, want the complete file that's cut short and the one that's gone", missing)
+	}
+}
@@ -0,0 +1,201 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	healthCheckInterval    = 5 * time.Minute // Frequency of checks for notifiable problems
+	notifyRepeatInterval   = 6 * time.Hour   // Minimum time between notifications of the same problem
+	defaultMinFreeMB       = 1024
+	defaultTrackerFailures = 3
+	defaultIdleWindow      = 24 * time.Hour
+)
+
+type notifyConfig struct {
+	SMTPServer string // host:port
+	Username   string
+	Password   string
+	From       string
+	To         []string // No notifications are sent if empty
+
+	MinFreeMB       int64         // Free space in the download directory below which to warn
+	TrackerFailures int           // Consecutive failed announces to a tracker before warning
+	IdleWindow      time.Duration // Time without any upload before warning
+}
+
+func (c notifyConfig) validate() error {
+	if len(c.To) == 0 {
+		return nil
+	}
+	if c.SMTPServer == "" || c.From == "" {
+		return fmt.Errorf("❌ Email notifications need an SMTP server and a from address")
+	}
+	if _, _, err := net.SplitHostPort(c.SMTPServer); err != nil {
+		return fmt.Errorf("❌ Invalid SMTP server '%s', expected host:port: %w", c.SMTPServer, err)
+	}
+	if c.MinFreeMB < 0 || c.TrackerFailures < 1 || c.IdleWindow <= 0 {
+		return fmt.Errorf("❌ Notification thresholds must be positive")
+	}
+	return nil
+}
+
+// notifier emails operators about problems, without repeating one more often than
+// notifyRepeatInterval
+type notifier struct {
+	cfg notifyConfig
+
+	mu       sync.Mutex
+	lastSent map[string]time.Time // Problem key to when it was last sent
+}
+
+func newNotifier(cfg notifyConfig) *notifier {
+	if len(cfg.To) == 0 {
+		return nil
+	}
+	return &notifier{cfg: cfg, lastSent: make(map[string]time.Time)}
+}
+
+// Notify sends a notification about the problem identified by key, unless it was sent recently
+func (n *notifier) Notify(key, subject, body string) {
+	n.mu.Lock()
+	if last, ok := n.lastSent[key]; ok && time.Since(last) < notifyRepeatInterval {
+		n.mu.Unlock()
+		return
+	}
+	n.lastSent[key] = time.Now()
+	n.mu.Unlock()
+
+	if err := n.send(subject, body); err != nil {
+		log.Printf("⚠️ Error sending notification '%s': %v", subject, err)
+		return
+	}
+	log.Printf("📧 Sent notification: %s", subject)
+}
+
+// Resolved forgets the problem, so it's notified straight away if it happens again
+func (n *notifier) Resolved(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.lastSent, key)
+}
+
+func (n *notifier) send(subject, body string) error {
+	host, _, _ := net.SplitHostPort(n.cfg.SMTPServer)
+	var auth smtp.Auth
+	if n.cfg.Username != "" {
+		auth = smtp.PlainAuth("", n.cfg.Username, n.cfg.Password, host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: [distro-seed] %s\r\nDate: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		n.cfg.From, strings.Join(n.cfg.To, ", "), subject, time.Now().Format(time.RFC1123Z), body)
+	return smtp.SendMail(n.cfg.SMTPServer, auth, n.cfg.From, n.cfg.To, []byte(msg))
+}
+
+// notifyTorrentError sends a notification about a torrent stopped by an error
+func (n *notifier) notifyTorrentError(t *torrent.Torrent, te torrentError) {
+	if n == nil {
+		return
+	}
+	retry := "It won't be retried automatically."
+	if te.NextRetry != nil {
+		retry = fmt.Sprintf("It's been retried %d times, next at %s.", te.Attempts, te.NextRetry.Format(time.DateTime))
+	}
+	n.Notify("error:"+t.InfoHash().HexString(), "Torrent stopped: "+t.Name(),
+		fmt.Sprintf("%s stopped on a %s error at %s, and isn't being seeded: %s\n\n%s", t.Name(), te.Kind, te.Since.Format(time.DateTime), te.Message, retry))
+}
+
+// Periodically check for problems worth notifying about until the context is cancelled
+func (s *Seeder) watchHealth(ctx context.Context, client *torrent.Client, n *notifier) {
+	if n == nil {
+		return
+	}
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	h := &healthState{seeder: s, badPieces: make(map[string]int64), lastUploadAt: time.Now()}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			h.check(client, n)
+		}
+	}
+}
+
+// healthState is what the checks remember between runs
+type healthState struct {
+	seeder       *Seeder
+	badPieces    map[string]int64 // Infohash to pieces that failed verification
+	lastUploaded int64
+	lastUploadAt time.Time
+}
+
+func (h *healthState) check(client *torrent.Client, n *notifier) {
+	// **Disk Space**
+	for _, dir := range h.seeder.placement.Dirs() {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			continue
+		}
+		if int64(free/1024/1024) < n.cfg.MinFreeMB {
+			n.Notify("disk:"+dir, "Disk almost full",
+				fmt.Sprintf("Only %d MB is free in %s, downloads will start failing.", free/1024/1024, dir))
+		} else {
+			n.Resolved("disk:" + dir)
+		}
+	}
+
+	// **Data Verification**
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		stats := t.Stats()
+		bad := stats.PiecesDirtiedBad.Int64()
+		if bad > h.badPieces[ih] {
+			n.Notify("verify:"+ih, "Data verification failed for "+t.Name(),
+				fmt.Sprintf("%d pieces of %s failed hash verification. The data on disk or from peers may be corrupt.", bad, t.Name()))
+		}
+		h.badPieces[ih] = bad
+	}
+
+	// **Torrent Errors**
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		if te, ok := h.seeder.torrentErrors.Error(ih); ok {
+			n.notifyTorrentError(t, te)
+		} else {
+			n.Resolved("error:" + ih)
+		}
+	}
+
+	// **Trackers**
+	for url, health := range h.seeder.trackers.Snapshot() {
+		if health.ConsecutiveFailures >= n.cfg.TrackerFailures {
+			n.Notify("tracker:"+url, "Tracker failing: "+url,
+				fmt.Sprintf("The last %d announces to %s failed: %s", health.ConsecutiveFailures, url, health.LastError))
+		} else if health.ConsecutiveFailures == 0 {
+			n.Resolved("tracker:" + url)
+		}
+	}
+
+	// **Upload Activity**
+	stats := client.Stats()
+	uploaded := stats.BytesWrittenData.Int64()
+	if uploaded > h.lastUploaded {
+		h.lastUploaded = uploaded
+		h.lastUploadAt = time.Now()
+		n.Resolved("idle")
+	} else if idle := time.Since(h.lastUploadAt); idle >= n.cfg.IdleWindow {
+		n.Notify("idle", "Nothing uploaded",
+			fmt.Sprintf("Nothing has been uploaded for %s.", idle.Round(time.Minute)))
+	}
+}
@@ -0,0 +1,113 @@
+package distroseed
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// smtpSink is an SMTP server that accepts every message, keeping their subjects
+type smtpSink struct {
+	net.Listener
+	mu       sync.Mutex
+	subjects []string
+}
+
+func newSMTPSink(t *testing.T) *smtpSink {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpSink{Listener: l}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpSink) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+	reply("220 sink")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+		case "DATA":
+			reply("354 go ahead")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				if subject, ok := strings.CutPrefix(line, "Subject: "); ok {
+					s.mu.Lock()
+					s.subjects = append(s.subjects, strings.TrimSpace(subject))
+					s.mu.Unlock()
+				}
+			}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+func (s *smtpSink) Subjects() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.subjects...)
+}
+
+func TestNotifierDoesNotRepeat(t *testing.T) {
+	sink := newSMTPSink(t)
+	n := newNotifier(notifyConfig{SMTPServer: sink.Addr().String(), From: "seed@example.com", To: []string{"ops@example.com"}})
+	n.Notify("disk", "Disk almost full", "Only 10 MB is free")
+	n.Notify("disk", "Disk almost full", "Only 5 MB is free")
+	n.Notify("idle", "Nothing uploaded", "Nothing has been uploaded for 24h")
+	// Once resolved, it's sent again straight away
+	n.Resolved("disk")
+	n.Notify("disk", "Disk almost full", "Only 1 MB is free")
+
+	want := []string{"[distro-seed] Disk almost full", "[distro-seed] Nothing uploaded", "[distro-seed] Disk almost full"}
+	if got := sink.Subjects(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestNotifyConfigValidate(t *testing.T) {
+	valid := notifyConfig{SMTPServer: "mail.example.com:587", From: "seed@example.com", To: []string{"ops@example.com"}, MinFreeMB: defaultMinFreeMB, TrackerFailures: defaultTrackerFailures, IdleWindow: defaultIdleWindow}
+	if err := valid.validate(); err != nil {
+		t.Errorf("validate = %v", err)
+	}
+	if newNotifier(notifyConfig{}) != nil {
+		t.Error("a notifier was made without recipients")
+	}
+	for _, change := range []func(*notifyConfig){
+		func(c *notifyConfig) { c.SMTPServer = "" },
+		func(c *notifyConfig) { c.SMTPServer = "mail.example.com" },
+		func(c *notifyConfig) { c.TrackerFailures = 0 },
+		func(c *notifyConfig) { c.IdleWindow = 0 },
+	} {
+		c := valid
+		change(&c)
+		if err := c.validate(); err == nil {
+			t.Errorf("%+v is valid", c)
+		}
+	}
+}
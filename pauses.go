@@ -0,0 +1,170 @@
+package distroseed
+
+import (
+	"log"
+	"slices"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+// torrentPauser holds the torrents paused through the API or CLI, or stopped by an error, which
+// stop announcing, transferring and connecting until they're resumed and the error is recovered
+// from. Pauses last until the seeder restarts.
+type torrentPauser struct {
+	seeder   *Seeder
+	mu       sync.Mutex
+	trackers map[string][][]string // Announce lists of paused torrents, by infohash
+	reasons  map[string][]string   // Why each paused torrent is paused
+}
+
+// Why a torrent is paused
+const (
+	pauseRequested = "requested" // Through the API or CLI
+	pauseError     = "error"     // Until the error is recovered from
+)
+
+// Pause pauses a torrent, reporting whether it wasn't paused already
+func (p *torrentPauser) Pause(t *torrent.Torrent) bool {
+	if !p.pause(t, pauseRequested) {
+		return false
+	}
+	log.Printf("⏸️ Paused: %s", t.Name())
+	p.seeder.events.Record(t.InfoHash().HexString(), eventPaused, "")
+	return true
+}
+
+// Resume undoes Pause, and reports whether the torrent was paused. A torrent stopped by an error
+// stays stopped until the error is recovered from.
+func (p *torrentPauser) Resume(t *torrent.Torrent) bool {
+	removed, released := p.resume(t, pauseRequested)
+	switch {
+	case released:
+		log.Printf("▶️ Resumed: %s", t.Name())
+	case removed:
+		log.Printf("▶️ Resumed: %s, which stays stopped until its error is recovered from", t.Name())
+	}
+	if removed {
+		p.seeder.events.Record(t.InfoHash().HexString(), eventResumed, "")
+	}
+	return removed
+}
+
+// pause stops a torrent for a reason, reporting whether it wasn't paused for that reason already
+func (p *torrentPauser) pause(t *torrent.Torrent, reason string) bool {
+	ih := t.InfoHash().HexString()
+	p.mu.Lock()
+	reasons := p.reasons[ih]
+	if slices.Contains(reasons, reason) {
+		p.mu.Unlock()
+		return false
+	}
+	p.reasons[ih] = append(reasons, reason)
+	if len(reasons) == 0 {
+		mi := t.Metainfo()
+		p.trackers[ih] = mi.UpvertedAnnounceList()
+	}
+	p.mu.Unlock()
+	if len(reasons) == 0 {
+		t.ModifyTrackers(nil)
+		t.DisallowDataUpload()
+		t.DisallowDataDownload()
+		t.SetMaxEstablishedConns(0)
+	}
+	return true
+}
+
+// resume drops a reason the torrent is paused for, and lets it go once there are none left,
+// leaving what other parts of the seeder hold back held back. It reports whether the torrent was
+// paused for the reason, and whether it was let go. Connection limits are handed back to the
+// slot manager.
+func (p *torrentPauser) resume(t *torrent.Torrent, reason string) (removed, released bool) {
+	ih := t.InfoHash().HexString()
+	p.mu.Lock()
+	reasons := p.reasons[ih]
+	if !slices.Contains(reasons, reason) {
+		p.mu.Unlock()
+		return false, false
+	}
+	reasons = slices.DeleteFunc(slices.Clone(reasons), func(r string) bool { return r == reason })
+	if len(reasons) > 0 {
+		p.reasons[ih] = reasons
+		p.mu.Unlock()
+		return true, false
+	}
+	trackers := p.trackers[ih]
+	delete(p.reasons, ih)
+	delete(p.trackers, ih)
+	p.mu.Unlock()
+	if len(trackers) > 0 && !p.seeder.uploadOnly.Held(ih) && !p.seeder.schedule.hold(ih, trackers) {
+		t.ModifyTrackers(trackers)
+	}
+	switch p.seeder.queue.kind(ih) {
+	case queuedSeed:
+		t.AllowDataDownload()
+		t.SetMaxEstablishedConns(idleConnsPerTorrent)
+	case queuedDownload:
+		t.AllowDataUpload()
+		t.SetMaxEstablishedConns(p.seeder.liveSettings.Get().ConnsPerTorrent)
+	default:
+		t.AllowDataUpload()
+		if !p.seeder.diskPauses.IsPaused(ih) {
+			t.AllowDataDownload()
+		}
+		t.SetMaxEstablishedConns(p.seeder.liveSettings.Get().ConnsPerTorrent)
+	}
+	p.seeder.uploadOnly.restrict(t)
+	p.seeder.quota.restrict(t)
+	p.seeder.schedule.restrict(t)
+	return true, true
+}
+
+// IsPaused reports whether the torrent was paused through the API or CLI, or stopped by an error
+func (p *torrentPauser) IsPaused(infoHash string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.reasons[infoHash]
+	return ok
+}
+
+// hold takes over the announce list of a torrent another pause is done with, if the torrent is
+// paused, so it's restored when it's resumed. It reports whether it did.
+func (p *torrentPauser) hold(infoHash string, trackers [][]string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.reasons[infoHash]; !ok {
+		return false
+	}
+	p.trackers[infoHash] = trackers
+	return true
+}
+
+// addTrackers adds trackers to the announce list a paused torrent gets back when it's resumed,
+// reporting whether it's paused
+func (p *torrentPauser) addTrackers(infoHash string, tiers [][]string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.reasons[infoHash]; !ok {
+		return false
+	}
+	p.trackers[infoHash] = appendTrackers(p.trackers[infoHash], tiers)
+	return true
+}
+
+// forget drops the pause of a torrent that was removed, so it isn't paused if it's added again
+func (p *torrentPauser) forget(infoHash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.trackers, infoHash)
+	delete(p.reasons, infoHash)
+}
+
+// restrict keeps a paused torrent from transferring data or connecting, after they were allowed
+// for other reasons
+func (p *torrentPauser) restrict(t *torrent.Torrent) {
+	if p.IsPaused(t.InfoHash().HexString()) {
+		t.DisallowDataUpload()
+		t.DisallowDataDownload()
+		t.SetMaxEstablishedConns(0)
+	}
+}
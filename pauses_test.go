@@ -0,0 +1,48 @@
+package distroseed
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPauseResume(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, dir, "image.iso", 32<<10)
+	meta.Announce = "http://127.0.0.1:1/announce"
+	tt, err := client.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ih := tt.InfoHash().HexString()
+
+	if !s.pauses.Pause(tt) || !s.pauses.IsPaused(ih) {
+		t.Fatal("not paused")
+	}
+	if s.pauses.Pause(tt) {
+		t.Error("pausing twice reported a change")
+	}
+	mi := tt.Metainfo()
+	if trackers := mi.UpvertedAnnounceList().DistinctValues(); len(trackers) != 0 {
+		t.Errorf("paused torrent still announces to %v", trackers)
+	}
+
+	// Trackers handed over by another pause ending are restored on resume
+	if !s.pauses.hold(ih, [][]string{{meta.Announce}, {"udp://127.0.0.1:2"}}) {
+		t.Error("hold of a paused torrent failed")
+	}
+	if !s.pauses.Resume(tt) || s.pauses.IsPaused(ih) {
+		t.Fatal("not resumed")
+	}
+	if s.pauses.Resume(tt) {
+		t.Error("resuming twice reported a change")
+	}
+	mi = tt.Metainfo()
+	if trackers := mi.UpvertedAnnounceList().DistinctValues(); !slices.Equal(trackers, []string{meta.Announce, "udp://127.0.0.1:2"}) {
+		t.Errorf("trackers after resuming = %v", trackers)
+	}
+	if s.pauses.hold(ih, nil) {
+		t.Error("hold of a torrent that isn't paused succeeded")
+	}
+}
@@ -0,0 +1,125 @@
+package distroseed
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// How peers were found, in the order they're reported
+var peerSourceNames = []string{"tracker", "dht", "pex", "lsd", "incoming", "holepunch", "other"}
+
+// peerSourceName groups the client's discovery sources into the ones traffic is reported by
+func peerSourceName(source torrent.PeerSource) string {
+	switch source {
+	case torrent.PeerSourceTracker:
+		return "tracker"
+	case torrent.PeerSourceDhtGetPeers, torrent.PeerSourceDhtAnnouncePeer:
+		return "dht"
+	case torrent.PeerSourcePex:
+		return "pex"
+	case peerSourceLSD:
+		return "lsd"
+	case torrent.PeerSourceIncoming:
+		return "incoming"
+	case torrent.PeerSourceUtHolepunch:
+		return "holepunch"
+	default:
+		return "other"
+	}
+}
+
+// sourceTraffic is what was transferred with the peers found through one source since start
+type sourceTraffic struct {
+	Peers      int64 `json:"peers"` // Connections made
+	Uploaded   int64 `json:"uploaded"`
+	Downloaded int64 `json:"downloaded"`
+}
+
+// peerSourceReading is a connection's counters as of a sample
+type peerSourceReading struct {
+	source string
+	transferCounters
+}
+
+// peerSourceMeter attributes traffic to the source each connected peer was discovered through,
+// so operators can see whether trackers or the DHT bring the peers that are uploaded to. The
+// client's per-connection counters can't be read once it closes a connection, so they're sampled
+// and the last few seconds of a closing connection are missed.
+type peerSourceMeter struct {
+	mu     sync.Mutex
+	conns  map[*torrent.PeerConn]transferCounters // Counters as of the last sample
+	totals map[string]*sourceTraffic
+}
+
+func newPeerSourceMeter() *peerSourceMeter {
+	return &peerSourceMeter{
+		conns:  make(map[*torrent.PeerConn]transferCounters),
+		totals: make(map[string]*sourceTraffic),
+	}
+}
+
+func (m *peerSourceMeter) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(rateSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			readings := make(map[*torrent.PeerConn]peerSourceReading)
+			for _, t := range client.Torrents() {
+				for _, pc := range t.PeerConns() {
+					stats := pc.Stats()
+					readings[pc] = peerSourceReading{
+						source: peerSourceName(pc.Discovery),
+						transferCounters: transferCounters{
+							Uploaded:   stats.BytesWrittenData.Int64(),
+							Downloaded: stats.BytesReadData.Int64(),
+						},
+					}
+				}
+			}
+			m.sample(readings)
+		}
+	}
+}
+
+// sample adds what each open connection transferred since the last sample to its source, and
+// forgets the connections that were closed
+func (m *peerSourceMeter) sample(readings map[*torrent.PeerConn]peerSourceReading) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for pc, r := range readings {
+		total, ok := m.totals[r.source]
+		if !ok {
+			total = &sourceTraffic{}
+			m.totals[r.source] = total
+		}
+		last, seen := m.conns[pc]
+		if !seen {
+			total.Peers++
+		}
+		total.Uploaded += r.Uploaded - last.Uploaded
+		total.Downloaded += r.Downloaded - last.Downloaded
+		m.conns[pc] = r.transferCounters
+	}
+	for pc := range m.conns {
+		if _, ok := readings[pc]; !ok {
+			delete(m.conns, pc)
+		}
+	}
+}
+
+// Totals returns the traffic per source, leaving out sources no peers came from
+func (m *peerSourceMeter) Totals() map[string]sourceTraffic {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	totals := make(map[string]sourceTraffic, len(m.totals))
+	for source, t := range m.totals {
+		totals[source] = *t
+	}
+	return totals
+}
@@ -0,0 +1,46 @@
+package distroseed
+
+import (
+	"testing"
+
+	"github.com/anacrolix/torrent"
+)
+
+func TestPeerSourceMeter(t *testing.T) {
+	m := newPeerSourceMeter()
+	fromTracker, fromDHT, incoming := &torrent.PeerConn{}, &torrent.PeerConn{}, &torrent.PeerConn{}
+	reading := func(source torrent.PeerSource, uploaded int64) peerSourceReading {
+		return peerSourceReading{source: peerSourceName(source), transferCounters: transferCounters{Uploaded: uploaded}}
+	}
+
+	m.sample(map[*torrent.PeerConn]peerSourceReading{
+		fromTracker: reading(torrent.PeerSourceTracker, 100),
+		fromDHT:     reading(torrent.PeerSourceDhtGetPeers, 50),
+	})
+	// The tracker peer disconnected, and one connected to us
+	m.sample(map[*torrent.PeerConn]peerSourceReading{
+		fromDHT:  reading(torrent.PeerSourceDhtGetPeers, 80),
+		incoming: reading(torrent.PeerSourceIncoming, 10),
+	})
+	m.sample(map[*torrent.PeerConn]peerSourceReading{
+		incoming: reading(torrent.PeerSourceIncoming, 30),
+	})
+
+	want := map[string]sourceTraffic{
+		"tracker":  {Peers: 1, Uploaded: 100},
+		"dht":      {Peers: 1, Uploaded: 80},
+		"incoming": {Peers: 1, Uploaded: 30},
+	}
+	got := m.Totals()
+	if len(got) != len(want) {
+		t.Errorf("got sources %v, want %v", got, want)
+	}
+	for source, w := range want {
+		if got[source] != w {
+			t.Errorf("%s: got %+v, want %+v", source, got[source], w)
+		}
+	}
+	if len(m.conns) != 1 {
+		t.Errorf("%d connections remembered, want 1", len(m.conns))
+	}
+}
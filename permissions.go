@@ -0,0 +1,77 @@
+package distroseed
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/anacrolix/torrent"
+)
+
+// dataModes are the permissions given to payload files and the directories they're in, instead
+// of the 0644 and 0755 the file storage creates them with. A zero mode leaves them as created.
+type dataModes struct {
+	file, dir os.FileMode
+}
+
+// parseModes parses octal file and directory modes, returning nil when neither is set
+func parseModes(file, dir string) (*dataModes, error) {
+	var m dataModes
+	var err error
+	if m.file, err = parseMode(file); err != nil {
+		return nil, fmt.Errorf("❌ Invalid file mode %q: %w", file, err)
+	}
+	if m.dir, err = parseMode(dir); err != nil {
+		return nil, fmt.Errorf("❌ Invalid directory mode %q: %w", dir, err)
+	}
+	if m.file == 0 && m.dir == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// parseMode parses an octal mode like 0664 or 2775, which may set the setgid and sticky bits
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || n > 07777 {
+		return 0, fmt.Errorf("not an octal mode")
+	}
+	mode := os.FileMode(n & 0777)
+	if n&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if n&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// apply sets the modes of the torrent's files, and of the directories between them and the
+// data directory they're in, where placement put them
+func (m *dataModes) apply(t *torrent.Torrent, placement *dataPlacement) {
+	if m == nil || t.Info() == nil {
+		return
+	}
+	ih := t.InfoHash().HexString()
+	dataDir := placement.DataDir(ih)
+	dirs := make(map[string]bool)
+	for _, f := range t.Files() {
+		path := placement.payloadPath(t, f)
+		if m.file != 0 {
+			if err := os.Chmod(path, m.file); err != nil && !os.IsNotExist(err) {
+				log.Printf("⚠️ Could not set the mode of %s: %v", path, err)
+			}
+		}
+		for dir := filepath.Dir(path); m.dir != 0 && dir != dataDir && isWithinDir(dir, dataDir) && !dirs[dir]; dir = filepath.Dir(dir) {
+			dirs[dir] = true
+			if err := os.Chmod(dir, m.dir); err != nil && !os.IsNotExist(err) {
+				log.Printf("⚠️ Could not set the mode of %s: %v", dir, err)
+			}
+		}
+	}
+}
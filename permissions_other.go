@@ -0,0 +1,14 @@
+//go:build !unix
+
+package distroseed
+
+import "errors"
+
+// Umasks and switching users aren't supported on this platform.
+func setUmask(mask int) error {
+	return errors.New("❌ Setting a umask is not supported on this platform")
+}
+
+func dropPrivileges(spec string, dataDirs []string) error {
+	return errors.New("❌ Switching users is not supported on this platform")
+}
@@ -0,0 +1,76 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestParseModes(t *testing.T) {
+	for _, tt := range []struct {
+		file, dir string
+		want      *dataModes
+		ok        bool
+	}{
+		{"", "", nil, true},
+		{"0664", "", &dataModes{file: 0664}, true},
+		{"640", "2775", &dataModes{file: 0640, dir: 0775 | os.ModeSetgid}, true},
+		{"0999", "", nil, false},
+		{"", "17777", nil, false},
+	} {
+		got, err := parseModes(tt.file, tt.dir)
+		if (err == nil) != tt.ok || (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("parseModes(%q, %q) = %v, %v, want %v", tt.file, tt.dir, got, err, tt.want)
+		}
+	}
+}
+
+func TestApplyDataModes(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	setTestSeederState(s, dir)
+	release := filepath.Join(dir, "release")
+	for _, path := range []string{filepath.Join(release, "sub", "a.iso"), filepath.Join(release, "b.iso")} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := metainfo.Info{PieceLength: 16 << 10}
+	if err := info.BuildFromFilePath(release); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt, err := client.AddTorrent(&metainfo.MetaInfo{InfoBytes: infoBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	(&dataModes{file: 0640, dir: 0750}).apply(tt, s.placement)
+	for path, want := range map[string]os.FileMode{
+		filepath.Join(release, "sub", "a.iso"): 0640,
+		filepath.Join(release, "b.iso"):        0640,
+		filepath.Join(release, "sub"):          0750 | os.ModeDir,
+		release:                                0750 | os.ModeDir,
+	} {
+		if fi, err := os.Stat(path); err != nil {
+			t.Error(err)
+		} else if fi.Mode() != want {
+			t.Errorf("mode of %s = %v, want %v", path, fi.Mode(), want)
+		}
+	}
+	if fi, _ := os.Stat(dir); fi.Mode().Perm() == 0750 {
+		t.Error("the data directory's mode was changed")
+	}
+}
@@ -0,0 +1,83 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// setUmask sets the mask for the modes of files and directories this process creates
+func setUmask(mask int) error {
+	unix.Umask(mask)
+	return nil
+}
+
+// lookupUser resolves "user" or "user:group", by name or id, to ids. The group defaults to the
+// user's primary group.
+func lookupUser(spec string) (uid, gid int, err error) {
+	name, group, _ := strings.Cut(spec, ":")
+	u, err := user.Lookup(name)
+	if err != nil {
+		if u, err = user.LookupId(name); err != nil {
+			return 0, 0, fmt.Errorf("❌ Unknown user %q", name)
+		}
+	}
+	uid, _ = strconv.Atoi(u.Uid)
+	gid, _ = strconv.Atoi(u.Gid)
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			if g, err = user.LookupGroupId(group); err != nil {
+				return 0, 0, fmt.Errorf("❌ Unknown group %q", group)
+			}
+		}
+		gid, _ = strconv.Atoi(g.Gid)
+	}
+	return uid, gid, nil
+}
+
+// dropPrivileges switches the process to the user once it has bound its listeners, which may be
+// on privileged ports. The data directories are given to the user first, since whatever was
+// created in them so far is owned by root. Nothing is done if we're running as the user already,
+// as an upgraded binary is.
+func dropPrivileges(spec string, dataDirs []string) error {
+	uid, gid, err := lookupUser(spec)
+	if err != nil {
+		return err
+	}
+	if os.Getuid() == uid && os.Getgid() == gid {
+		return nil
+	}
+	if os.Getuid() != 0 {
+		return fmt.Errorf("❌ Switching to user %q needs distro-seed to be started as root", spec)
+	}
+	for _, dir := range dataDirs {
+		err := filepath.WalkDir(dir, func(path string, _ fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			return os.Lchown(path, uid, gid)
+		})
+		if err != nil {
+			return fmt.Errorf("❌ Failed to give %s to user %q: %w", dir, spec, err)
+		}
+	}
+	if err := unix.Setgroups([]int{gid}); err != nil {
+		return fmt.Errorf("❌ Failed to set groups: %w", err)
+	}
+	if err := unix.Setgid(gid); err != nil {
+		return fmt.Errorf("❌ Failed to switch to group %d: %w", gid, err)
+	}
+	if err := unix.Setuid(uid); err != nil {
+		return fmt.Errorf("❌ Failed to switch to user %d: %w", uid, err)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package distroseed
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// pexMeter counts the peers each torrent learned of through peer exchange, whether or not they
+// were connected to
+type pexMeter struct {
+	mu      sync.Mutex
+	learned map[string]int64
+}
+
+func (m *pexMeter) add(infoHash string, peers int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.learned[infoHash] += int64(peers)
+}
+
+// Learned returns the peers a torrent learned of through peer exchange since start
+func (m *pexMeter) Learned(infoHash string) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.learned[infoHash]
+}
+
+// pexAllowed reports whether a torrent's peers may be gossiped about. Private torrents and ones
+// whose sources turn it off are kept out of peer exchange.
+func (s *Seeder) pexAllowed(t *torrent.Torrent) bool {
+	if isPrivate(t) {
+		return false
+	}
+	pex := s.optionsOf(t).PEX
+	return pex == nil || *pex
+}
+
+// installPEX keeps peer exchange off for the torrents it isn't allowed for, and counts the peers
+// learned through it. PEX is offered to and taken from every peer otherwise, since the client
+// only turns it off altogether.
+func (s *Seeder) installPEX(cfg *torrent.ClientConfig) {
+	cfg.Callbacks.PeerConnAdded = append(cfg.Callbacks.PeerConnAdded, func(pc *torrent.PeerConn) {
+		if s.pexAllowed(pc.Torrent()) {
+			return
+		}
+		// The map is shared by all connections, so this one gets its own without ut_pex
+		protocols := *pc.LocalLtepProtocolMap
+		if i := slices.Index(protocols.Index, pp.ExtensionNamePex); i >= 0 {
+			protocols.Index = slices.Delete(slices.Clone(protocols.Index), i, i+1)
+			if i < protocols.NumBuiltin {
+				protocols.NumBuiltin--
+			}
+			pc.LocalLtepProtocolMap = &protocols
+		}
+	})
+	// Peers offering PEX aren't sent any
+	cfg.Callbacks.ReadExtendedHandshake = func(pc *torrent.PeerConn, msg *pp.ExtendedHandshakeMessage) {
+		if !s.pexAllowed(pc.Torrent()) {
+			delete(msg.M, pp.ExtensionNamePex)
+		}
+	}
+	cfg.Callbacks.PeerConnReadExtensionMessage = append(cfg.Callbacks.PeerConnReadExtensionMessage, func(e torrent.PeerConnReadExtensionMessageEvent) {
+		if e.ExtensionNumber == pp.HandshakeExtendedID {
+			return
+		}
+		if name, _, err := e.PeerConn.LocalLtepProtocolMap.LookupId(e.ExtensionNumber); err != nil || name != pp.ExtensionNamePex {
+			return
+		}
+		msg, err := pp.LoadPexMsg(e.Payload)
+		if err != nil {
+			return
+		}
+		s.pexPeers.add(e.PeerConn.Torrent().InfoHash().HexString(), len(msg.Added)+len(msg.Added6))
+	})
+}
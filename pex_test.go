@@ -0,0 +1,75 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// offersPEX connects a leecher to a seeder with installPEX, and reports whether the seeder
+// offered peer exchange for the torrent
+func offersPEX(t *testing.T, seeder *torrent.Client, seeding *torrent.Torrent, meta *metainfo.MetaInfo) bool {
+	t.Helper()
+	handshakes := make(chan pp.ExtendedHandshakeMessage, 1)
+	leecher := newTestClient(t, t.TempDir(), func(cfg *torrent.ClientConfig) {
+		cfg.Callbacks.ReadExtendedHandshake = func(pc *torrent.PeerConn, msg *pp.ExtendedHandshakeMessage) {
+			select {
+			case handshakes <- *msg:
+			default:
+			}
+		}
+	})
+	leeching, err := leecher.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leeching.DownloadAll()
+	leeching.AddClientPeer(seeder)
+	select {
+	case msg := <-handshakes:
+		_, ok := msg.M[pp.ExtensionNamePex]
+		return ok
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%s: no extended handshake from the seeder", seeding.Name())
+		return false
+	}
+}
+
+func TestPEXPerTorrent(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig()
+	public, private := newTestMeta(t, dir, "public.iso", 32<<10), newPrivateTestMeta(t, dir, "private.iso")
+	off := newTestMeta(t, dir, "off.iso", 32<<10)
+	pexOff := false
+	cfg.TorrentOptions = map[string]torrentOptions{"off.torrent": {PEX: &pexOff}}
+	s := newTestSeeder(t, cfg)
+	seeder := newTestClient(t, dir, s.installPEX)
+
+	for _, tt := range []struct {
+		meta   *metainfo.MetaInfo
+		source string
+		pex    bool
+	}{
+		{public, "public.torrent", true},
+		{private, "private.torrent", false},
+		{off, "off.torrent", false},
+	} {
+		seeding, err := seeder.AddTorrent(tt.meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := seeding.VerifyData(); err != nil {
+			t.Fatal(err)
+		}
+		s.torrentSources.Add(tt.source, seeding)
+		if got := s.pexAllowed(seeding); got != tt.pex {
+			t.Errorf("%s: PEX allowed %v, want %v", seeding.Name(), got, tt.pex)
+		}
+		if got := offersPEX(t, seeder, seeding, tt.meta); got != tt.pex {
+			t.Errorf("%s: PEX offered %v, want %v", seeding.Name(), got, tt.pex)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package distroseed
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// dataPlacement decides which directory each torrent's data goes in. Torrents go where they're
+// configured to, or where their data already is, or else in the data directory with the most
+// free space, laid out there by the layout template.
+type dataPlacement struct {
+	seeder *Seeder
+	dirs   []string // The download directory first
+	layout string   // Template of the directories under a data directory, flat if empty
+
+	mu       sync.Mutex
+	assigned map[string]string   // Infohash to configured directory
+	labels   map[string][]string // Infohash to the labels the layout can use
+	placed   map[string]string   // Infohash to the directory its storage was opened in
+}
+
+func newDataPlacement(s *Seeder, dirs []string, layout string) *dataPlacement {
+	return &dataPlacement{seeder: s, dirs: dirs, layout: layout, assigned: make(map[string]string), labels: make(map[string][]string), placed: make(map[string]string)}
+}
+
+// Dirs returns the directories torrents are spread over, and any they're configured to be in
+func (p *dataPlacement) Dirs() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	dirs := slices.Clone(p.dirs)
+	for _, dir := range p.assigned {
+		if !slices.Contains(dirs, dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// Assign configures the directory for a torrent whose storage hasn't been opened yet
+func (p *dataPlacement) Assign(infoHash, dir string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.assigned[infoHash] = dir
+}
+
+// Label gives the labels of a torrent whose storage hasn't been opened yet to the layout
+func (p *dataPlacement) Label(infoHash string, labels []string) {
+	if p.layout == "" || len(labels) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.labels[infoHash] = labels
+}
+
+// Dir returns the directory the torrent's data is in
+func (p *dataPlacement) Dir(infoHash string) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if dir, ok := p.placed[infoHash]; ok {
+		return dir
+	}
+	return p.dirs[0]
+}
+
+// DataDir returns the data directory the torrent's data is somewhere in, or its configured
+// directory
+func (p *dataPlacement) DataDir(infoHash string) string {
+	dir := p.Dir(infoHash)
+	for _, dataDir := range p.Dirs() {
+		if isWithinDir(dir, dataDir) {
+			return dataDir
+		}
+	}
+	return dir
+}
+
+// torrentDir chooses the directory for a torrent's data, sticking to the choice afterwards. It
+// is used as the file storage's storage.TorrentDirFilePathMaker.
+func (p *dataPlacement) torrentDir(_ string, info *metainfo.Info, infoHash metainfo.Hash) string {
+	ih := infoHash.HexString()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if dir, ok := p.placed[ih]; ok {
+		return dir
+	}
+
+	layoutDir := layoutDir(p.layout, info.BestName(), ih, p.labels[ih])
+	dir, ok := p.assigned[ih]
+	if !ok {
+		dir = p.existingDir(ih, info.BestName(), layoutDir)
+	}
+	if dir == "" {
+		dir = filepath.Join(p.roomiestDir(info.TotalLength()), layoutDir)
+		if len(p.dirs) > 1 || p.layout != "" {
+			log.Printf("📦 Placing %s in %s", info.BestName(), dir)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("⚠️ Failed to create data directory '%s': %v", dir, err)
+	}
+	p.placed[ih] = dir
+	return dir
+}
+
+// existingDir finds where a torrent's data was put before, from the registry or by looking for
+// it where the layout puts it or flat in a data directory, the caller must hold p.mu
+func (p *dataPlacement) existingDir(infoHash, name, layoutDir string) string {
+	for _, e := range p.seeder.registry.Entries() {
+		if e.InfoHash == infoHash && e.DataPath != "" {
+			if _, err := os.Stat(e.DataPath); err == nil {
+				return filepath.Dir(e.DataPath)
+			}
+		}
+	}
+	for _, dir := range p.dirs {
+		for _, dir := range []string{filepath.Join(dir, layoutDir), dir} {
+			for _, path := range []string{filepath.Join(dir, name), filepath.Join(dir, name+".part")} {
+				if _, err := os.Stat(path); err == nil {
+					return dir
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// roomiestDir returns the directory with the most free space, the caller must hold p.mu
+func (p *dataPlacement) roomiestDir(size int64) string {
+	best, bestFree := p.dirs[0], uint64(0)
+	for _, dir := range p.dirs {
+		free, err := freeDiskSpace(dir)
+		if err != nil {
+			continue
+		}
+		if free > bestFree {
+			best, bestFree = dir, free
+		}
+	}
+	if len(p.dirs) > 1 && bestFree < uint64(size) {
+		log.Printf("⚠️ No data directory has %d MB free, using %s", size/1024/1024, best)
+	}
+	return best
+}
+
+// newFileStorage returns the client's file storage, laid out by placement
+func newFileStorage(p *dataPlacement) storage.ClientImplCloser {
+	completion, err := storage.NewDefaultPieceCompletionForDir(p.dirs[0])
+	if err != nil {
+		log.Printf("Warning: Falling back to in-memory piece completion: %v", err)
+		completion = storage.NewMapPieceCompletion()
+	}
+	return storage.NewFileOpts(storage.NewFileClientOpts{
+		ClientBaseDir:   p.dirs[0],
+		TorrentDirMaker: p.torrentDir,
+		PieceCompletion: completion,
+	})
+}
@@ -0,0 +1,81 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataPlacementTorrentDir(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	first, second, configured := t.TempDir(), t.TempDir(), filepath.Join(t.TempDir(), "isos")
+	setTestSeederState(s, first)
+	p := newDataPlacement(s, []string{first, second}, "")
+
+	// Data already in one of the directories stays there
+	existing := newTestMeta(t, second, "existing.iso", 32<<10)
+	info, err := existing.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.torrentDir("", &info, existing.HashInfoBytes()); got != second {
+		t.Errorf("torrent with data in %s placed in %s", second, got)
+	}
+
+	// Torrents configured to a directory go there, even if it doesn't exist yet
+	assigned := newTestMeta(t, t.TempDir(), "assigned.iso", 32<<10)
+	info, err = assigned.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Assign(assigned.HashInfoBytes().HexString(), configured)
+	if got := p.torrentDir("", &info, assigned.HashInfoBytes()); got != configured {
+		t.Errorf("torrent configured to %s placed in %s", configured, got)
+	}
+	if _, err := os.Stat(configured); err != nil {
+		t.Errorf("the configured directory wasn't created: %v", err)
+	}
+	// The choice sticks
+	p.Assign(assigned.HashInfoBytes().HexString(), second)
+	if got := p.torrentDir("", &info, assigned.HashInfoBytes()); got != configured {
+		t.Errorf("torrent already placed in %s moved to %s", configured, got)
+	}
+	if got := p.Dir(assigned.HashInfoBytes().HexString()); got != configured {
+		t.Errorf("Dir = %s, want %s", got, configured)
+	}
+	if got := p.Dir("unknown"); got != first {
+		t.Errorf("Dir of an unplaced torrent = %s, want the download directory %s", got, first)
+	}
+}
+
+func TestDataPlacementLayout(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	p := newDataPlacement(s, []string{dir}, "{distro}/{version}/{name}")
+
+	meta := newTestMeta(t, t.TempDir(), "debian-12.7.0-amd64-netinst.iso", 32<<10)
+	info, err := meta.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ih := meta.HashInfoBytes().HexString()
+	p.Label(ih, []string{"version:12"})
+	want := filepath.Join(dir, "debian", "12")
+	if got := p.torrentDir("", &info, meta.HashInfoBytes()); got != want {
+		t.Errorf("torrent placed in %s, want %s", got, want)
+	}
+	if got := p.DataDir(ih); got != dir {
+		t.Errorf("DataDir = %s, want %s", got, dir)
+	}
+
+	// Data downloaded flat before the layout was set stays where it is
+	flat := newTestMeta(t, dir, "ubuntu-24.04.1-desktop-amd64.iso", 32<<10)
+	info, err = flat.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.torrentDir("", &info, flat.HashInfoBytes()); got != dir {
+		t.Errorf("torrent with data in %s placed in %s", dir, got)
+	}
+}
@@ -0,0 +1,328 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// distroPreset knows where a distro publishes its torrents, and finds the releases in each of
+// its channels
+type distroPreset struct {
+	defaultChannel string
+	channels       map[string]func(context.Context) ([]distroRelease, error) // Newest release first
+	pinned         func(ctx context.Context, version string) ([]string, error)
+}
+
+// distroRelease is a release whose torrents are only looked up if it's seeded
+type distroRelease struct {
+	version  string
+	torrents func(context.Context) ([]string, error)
+}
+
+var distroPresets = map[string]distroPreset{
+	"ubuntu": {
+		defaultChannel: "lts",
+		channels: map[string]func(context.Context) ([]distroRelease, error){
+			"lts":    ubuntuReleases(true),
+			"stable": ubuntuReleases(false),
+		},
+		pinned: ubuntuPinned,
+	},
+	"debian": {
+		defaultChannel: "stable",
+		channels: map[string]func(context.Context) ([]distroRelease, error){
+			"stable":  debianStableReleases,
+			"testing": debianTestingReleases,
+		},
+		pinned: debianPinned,
+	},
+	"archlinux": {
+		defaultChannel: "stable",
+		channels: map[string]func(context.Context) ([]distroRelease, error){
+			"stable": archReleases,
+		},
+		pinned: archPinned,
+	},
+}
+
+// Preset names from before channels were added
+var presetAliases = map[string]string{
+	"ubuntu-lts":    "ubuntu:lts",
+	"debian-stable": "debian:stable",
+}
+
+const (
+	ubuntuReleasesURL    = "https://releases.ubuntu.com/"
+	ubuntuOldReleasesURL = "https://old-releases.ubuntu.com/releases/"
+	debianCurrentURL     = "https://cdimage.debian.org/debian-cd/"
+	debianArchiveURL     = "https://cdimage.debian.org/cdimage/archive/"
+	debianCDImageURL     = "https://cdimage.debian.org/cdimage/"
+	archArchiveURL       = "https://archive.archlinux.org/iso/"
+)
+
+var (
+	ubuntuReleaseDir   = regexp.MustCompile(`^(\d\d)\.(04|10)/$`)
+	ubuntuTorrent      = regexp.MustCompile(`^ubuntu-[\d.]+-(desktop|live-server)-amd64\.iso\.torrent$`)
+	debianReleaseDir   = regexp.MustCompile(`^\d+\.\d+\.\d+/$`)
+	debianInstallerDir = regexp.MustCompile(`^[a-z]+_di_(alpha|beta|rc)\d+/$`)
+	debianNetinst      = regexp.MustCompile(`^debian-(\d[\d.]*|[a-z]+-DI-[a-z]+\d+)-amd64-netinst\.iso\.torrent$`) // Not debian-edu and the like
+	debianDVD          = regexp.MustCompile(`^debian-\d[\d.]*-amd64-DVD-1\.iso\.torrent$`)
+	archReleaseDir     = regexp.MustCompile(`^\d{4}\.\d{2}\.\d{2}/$`)
+	archTorrent        = regexp.MustCompile(`^archlinux-\d{4}\.\d{2}\.\d{2}-x86_64\.iso\.torrent$`)
+	pinnedVersion      = regexp.MustCompile(`^\d[\w.~-]*$`)
+	directoryLinkRef   = regexp.MustCompile(`href="([^"?#]+)"`)
+)
+
+// presetNames lists the distros and their channels, for help text
+func presetNames() []string {
+	var names []string
+	for _, name := range slices.Sorted(maps.Keys(distroPresets)) {
+		names = append(names, name+":"+strings.Join(slices.Sorted(maps.Keys(distroPresets[name].channels)), "|"))
+	}
+	return names
+}
+
+// presetSource returns the manifest source for a preset given as distro[:channel][@keep], where
+// the channel can instead be a version to pin, and keep is how many of the channel's newest
+// releases to seed
+func presetSource(spec string, defaultKeep int) (manifestSource, error) {
+	spec, keepSpec, hasKeep := strings.Cut(spec, "@")
+	spec = cmp.Or(presetAliases[spec], spec)
+	name, channel, _ := strings.Cut(spec, ":")
+	d, ok := distroPresets[name]
+	if !ok {
+		return manifestSource{}, fmt.Errorf("❌ Unknown preset '%s', use one of: %s", name, strings.Join(presetNames(), ", "))
+	}
+	channel = cmp.Or(channel, d.defaultChannel)
+	keep := defaultKeep
+	if hasKeep {
+		var err error
+		if keep, err = strconv.Atoi(keepSpec); err != nil || keep < 1 {
+			return manifestSource{}, fmt.Errorf("❌ Invalid number of releases to keep in preset '%s'", spec+"@"+keepSpec)
+		}
+	}
+	label := fmt.Sprintf("preset %s:%s", name, channel)
+
+	releases, ok := d.channels[channel]
+	switch {
+	case ok:
+		return manifestSource{name: fmt.Sprintf("%s@%d", label, keep), resolve: func(ctx context.Context) ([]string, error) {
+			return newestReleaseTorrents(ctx, releases, keep)
+		}}, nil
+	case pinnedVersion.MatchString(channel):
+		if hasKeep {
+			return manifestSource{}, fmt.Errorf("❌ Preset '%s' is pinned to one release, so there are no others to keep", spec)
+		}
+		return manifestSource{name: label, resolve: func(ctx context.Context) ([]string, error) {
+			return d.pinned(ctx, channel)
+		}}, nil
+	default:
+		return manifestSource{}, fmt.Errorf("❌ Unknown channel '%s' for %s, use a version or one of: %s",
+			channel, name, strings.Join(slices.Sorted(maps.Keys(d.channels)), ", "))
+	}
+}
+
+// The torrents of a channel's newest releases
+func newestReleaseTorrents(ctx context.Context, channel func(context.Context) ([]distroRelease, error), keep int) ([]string, error) {
+	releases, err := channel(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var urls []string
+	for _, r := range releases[:min(keep, len(releases))] {
+		torrents, err := r.torrents(ctx)
+		if err != nil {
+			return nil, err
+		}
+		urls = appendMissing(urls, torrents...)
+	}
+	return urls, nil
+}
+
+// Ubuntu's releases, or only the LTS ones, which are the April releases of even years. Older
+// point releases move to old-releases, so each release is its latest point release.
+func ubuntuReleases(ltsOnly bool) func(context.Context) ([]distroRelease, error) {
+	return func(ctx context.Context) ([]distroRelease, error) {
+		dirs, err := directoryLinks(ctx, ubuntuReleasesURL, ubuntuReleaseDir)
+		if err != nil {
+			return nil, err
+		}
+		var releases []distroRelease
+		for _, dir := range dirs {
+			m := ubuntuReleaseDir.FindStringSubmatch(strings.TrimPrefix(dir, ubuntuReleasesURL))
+			year, _ := strconv.Atoi(m[1])
+			if ltsOnly && (m[2] != "04" || year%2 != 0) {
+				continue
+			}
+			releases = append(releases, distroRelease{version: m[1] + "." + m[2], torrents: func(ctx context.Context) ([]string, error) {
+				return directoryLinks(ctx, dir, ubuntuTorrent)
+			}})
+		}
+		return newestFirst(releases, ubuntuReleasesURL)
+	}
+}
+
+// A release series like 24.04 while it's current, or a point release like 22.04.3
+func ubuntuPinned(ctx context.Context, version string) ([]string, error) {
+	urls, err := directoryLinks(ctx, ubuntuReleasesURL+version+"/", ubuntuTorrent)
+	if err != nil {
+		return directoryLinks(ctx, ubuntuOldReleasesURL+version+"/", ubuntuTorrent)
+	}
+	return urls, nil
+}
+
+// The current point release and those before it, from the archive
+func debianStableReleases(ctx context.Context) ([]distroRelease, error) {
+	var releases []distroRelease
+	for _, root := range []string{debianCurrentURL, debianArchiveURL} {
+		dirs, err := directoryLinks(ctx, root, debianReleaseDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, dir := range dirs {
+			releases = append(releases, debianRelease(strings.Trim(strings.TrimPrefix(dir, root), "/"), dir+"amd64/", true))
+		}
+	}
+	return newestFirst(releases, debianArchiveURL)
+}
+
+// Alphas, betas and release candidates of the next release's installer
+func debianTestingReleases(ctx context.Context) ([]distroRelease, error) {
+	dirs, err := directoryLinks(ctx, debianCDImageURL, debianInstallerDir)
+	if err != nil {
+		return nil, err
+	}
+	var releases []distroRelease
+	for _, dir := range dirs {
+		releases = append(releases, debianRelease(strings.Trim(strings.TrimPrefix(dir, debianCDImageURL), "/"), dir+"amd64/", false))
+	}
+	return newestFirst(releases, debianCDImageURL)
+}
+
+// debianRelease has the netinst image, and the first DVD for full releases
+func debianRelease(version, amd64Dir string, dvd bool) distroRelease {
+	return distroRelease{version: version, torrents: func(ctx context.Context) ([]string, error) {
+		urls, err := directoryLinks(ctx, amd64Dir+"bt-cd/", debianNetinst)
+		if err != nil || !dvd {
+			return urls, err
+		}
+		dvds, err := directoryLinks(ctx, amd64Dir+"bt-dvd/", debianDVD)
+		return append(urls, dvds...), err
+	}}
+}
+
+func debianPinned(ctx context.Context, version string) ([]string, error) {
+	urls, err := debianRelease(version, debianCurrentURL+version+"/amd64/", true).torrents(ctx)
+	if err != nil {
+		return debianRelease(version, debianArchiveURL+version+"/amd64/", true).torrents(ctx)
+	}
+	return urls, nil
+}
+
+// The monthly ISOs, from the archive which keeps them all
+func archReleases(ctx context.Context) ([]distroRelease, error) {
+	dirs, err := directoryLinks(ctx, archArchiveURL, archReleaseDir)
+	if err != nil {
+		return nil, err
+	}
+	var releases []distroRelease
+	for _, dir := range dirs {
+		releases = append(releases, distroRelease{version: strings.Trim(strings.TrimPrefix(dir, archArchiveURL), "/"), torrents: func(ctx context.Context) ([]string, error) {
+			return directoryLinks(ctx, dir, archTorrent)
+		}})
+	}
+	return newestFirst(releases, archArchiveURL)
+}
+
+func archPinned(ctx context.Context, version string) ([]string, error) {
+	return directoryLinks(ctx, archArchiveURL+version+"/", archTorrent)
+}
+
+// newestFirst sorts releases by version, failing if there are none
+func newestFirst(releases []distroRelease, source string) ([]distroRelease, error) {
+	if len(releases) == 0 {
+		return nil, fmt.Errorf("❌ No releases found at %s", source)
+	}
+	slices.SortStableFunc(releases, func(a, b distroRelease) int { return compareVersions(b.version, a.version) })
+	return releases, nil
+}
+
+// compareVersions orders versions like 24.04.2 and trixie_di_rc2, comparing runs of digits by
+// their value and anything else alphabetically
+func compareVersions(a, b string) int {
+	for a != "" && b != "" {
+		var ra, rb string
+		ra, a = versionRun(a)
+		rb, b = versionRun(b)
+		na, errA := strconv.Atoi(ra)
+		nb, errB := strconv.Atoi(rb)
+		var c int
+		if errA == nil && errB == nil {
+			c = cmp.Compare(na, nb)
+		} else {
+			c = strings.Compare(ra, rb)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+// versionRun splits off the leading run of digits or of other characters
+func versionRun(s string) (run, rest string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsDigit(r) != digit })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
+
+// directoryLinks returns the absolute URLs of the links on a directory listing page whose
+// targets match pattern, failing if there are none
+func directoryLinks(ctx context.Context, pageURL string, pattern *regexp.Regexp) ([]string, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := manifestClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to list %s: %w", pageURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("❌ Failed to list %s: %s", pageURL, resp.Status)
+	}
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to list %s: %w", pageURL, err)
+	}
+
+	var links []string
+	for _, m := range directoryLinkRef.FindAllSubmatch(page, -1) {
+		ref, err := url.Parse(string(m[1]))
+		if err != nil || !pattern.MatchString(ref.Path) {
+			continue
+		}
+		links = appendMissing(links, base.ResolveReference(ref).String())
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("❌ No matching links found at %s", pageURL)
+	}
+	return links, nil
+}
@@ -0,0 +1,121 @@
+package distroseed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDirectoryLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/24.04/":
+			w.Write([]byte(`<a href="?C=N;O=D">Name</a>
+<a href="ubuntu-24.04.1-desktop-amd64.iso.torrent">desktop</a>
+<a href="ubuntu-24.04.1-desktop-amd64.iso">iso</a>
+<a href="ubuntu-24.04.1-live-server-amd64.iso.torrent">server</a>
+<a href="ubuntu-24.04.1-desktop-amd64.iso.torrent">again</a>`))
+		case "/empty/":
+			w.Write([]byte(`<a href="SHA256SUMS">sums</a>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	got, err := directoryLinks(context.Background(), server.URL+"/24.04/", ubuntuTorrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		server.URL + "/24.04/ubuntu-24.04.1-desktop-amd64.iso.torrent",
+		server.URL + "/24.04/ubuntu-24.04.1-live-server-amd64.iso.torrent",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("directoryLinks = %v, want %v", got, want)
+	}
+
+	for _, page := range []string{"/empty/", "/missing/"} {
+		if _, err := directoryLinks(context.Background(), server.URL+page, ubuntuTorrent); err == nil {
+			t.Errorf("directoryLinks(%s) found links", page)
+		}
+	}
+}
+
+func TestPresetSource(t *testing.T) {
+	tests := []struct {
+		spec string
+		name string
+		err  string // Part of the error expected, if any
+	}{
+		{spec: "ubuntu", name: "preset ubuntu:lts@2"},
+		{spec: "ubuntu:stable@3", name: "preset ubuntu:stable@3"},
+		{spec: "ubuntu-lts", name: "preset ubuntu:lts@2"},
+		{spec: "debian:12.5", name: "preset debian:12.5"},
+		{spec: "archlinux@1", name: "preset archlinux:stable@1"},
+		{spec: "gentoo", err: "Unknown preset"},
+		{spec: "ubuntu:daily", err: "Unknown channel"},
+		{spec: "ubuntu@0", err: "Invalid number of releases"},
+		{spec: "debian:12.5@2", err: "pinned to one release"},
+	}
+	for _, tt := range tests {
+		src, err := presetSource(tt.spec, 2)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("presetSource(%q) error = %v, want %q", tt.spec, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || src.name != tt.name {
+			t.Errorf("presetSource(%q) = %q, %v, want %q", tt.spec, src.name, err, tt.name)
+		}
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"24.04", "22.10", 1},
+		{"12.10.0", "12.9.0", 1},
+		{"2024.06.01", "2024.06.01", 0},
+		{"12.5", "12.5.1", -1},
+		{"trixie_di_alpha1", "trixie_di_rc1", -1},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNewestReleaseTorrents(t *testing.T) {
+	var looked []string
+	release := func(version string) distroRelease {
+		return distroRelease{version: version, torrents: func(context.Context) ([]string, error) {
+			looked = append(looked, version)
+			return []string{version + ".torrent"}, nil
+		}}
+	}
+	channel := func(context.Context) ([]distroRelease, error) {
+		return newestFirst([]distroRelease{release("22.04"), release("24.04"), release("20.04")}, "test")
+	}
+
+	got, err := newestReleaseTorrents(context.Background(), channel, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"24.04.torrent", "22.04.torrent"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("newestReleaseTorrents = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(looked, []string{"24.04", "22.04"}) {
+		t.Errorf("looked up torrents of %v, want only the releases kept", looked)
+	}
+	if _, err := newestFirst(nil, "test"); err == nil {
+		t.Error("newestFirst succeeded without releases")
+	}
+}
@@ -0,0 +1,40 @@
+package distroseed
+
+import (
+	"github.com/anacrolix/dht/v2"
+	"github.com/anacrolix/torrent"
+)
+
+// isPrivate reports whether a torrent is flagged private (BEP 27), so its peers may only come
+// from its trackers. Magnets aren't known to be private until their info arrives.
+func isPrivate(t *torrent.Torrent) bool {
+	info := t.Info()
+	return info != nil && info.Private != nil && *info.Private
+}
+
+// privateDHTServer is a DHT server that private torrents aren't announced to or looked up on.
+// The client announces every torrent to the DHT on its own, with no way to leave some out.
+type privateDHTServer struct {
+	torrent.DhtServer
+	client *torrent.Client
+}
+
+// Announce announces and looks up the infohash, unless it's a private torrent's, for which no
+// peers are found
+func (s privateDHTServer) Announce(hash [20]byte, port int, impliedPort bool) (torrent.DhtAnnounce, error) {
+	if t, ok := s.client.Torrent(hash); ok && isPrivate(t) {
+		return skippedAnnounce{}, nil
+	}
+	return s.DhtServer.Announce(hash, port, impliedPort)
+}
+
+// skippedAnnounce is a DHT announce that finished without finding peers
+type skippedAnnounce struct{}
+
+func (skippedAnnounce) Close() {}
+
+func (skippedAnnounce) Peers() <-chan dht.PeersValues {
+	peers := make(chan dht.PeersValues)
+	close(peers)
+	return peers
+}
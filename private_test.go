@@ -0,0 +1,83 @@
+package distroseed
+
+import (
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// fakeDHTServer records the infohashes announced to it
+type fakeDHTServer struct {
+	torrent.DhtServer
+	announced [][20]byte
+}
+
+func (s *fakeDHTServer) Announce(hash [20]byte, port int, impliedPort bool) (torrent.DhtAnnounce, error) {
+	s.announced = append(s.announced, hash)
+	return skippedAnnounce{}, nil
+}
+
+// newPrivateTestMeta returns the torrent of a file of random data, flagged private
+func newPrivateTestMeta(t *testing.T, dir, name string) *metainfo.MetaInfo {
+	t.Helper()
+	mi := newTestMeta(t, dir, name, 32<<10)
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := true
+	info.Private = &private
+	if mi.InfoBytes, err = bencode.Marshal(info); err != nil {
+		t.Fatal(err)
+	}
+	return mi
+}
+
+func TestPrivateTorrentsSkipDHT(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	public, err := client.AddTorrent(newTestMeta(t, dir, "public.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := client.AddTorrent(newPrivateTestMeta(t, dir, "private.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isPrivate(public) || !isPrivate(private) {
+		t.Fatalf("private: public %v, private %v", isPrivate(public), isPrivate(private))
+	}
+
+	fake := &fakeDHTServer{}
+	server := privateDHTServer{DhtServer: fake, client: client}
+	for _, tt := range []*torrent.Torrent{public, private} {
+		ann, err := server.Announce(tt.InfoHash(), 6881, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, open := <-ann.Peers(); open {
+			t.Errorf("announce of %s found peers", tt.Name())
+		}
+	}
+	if len(fake.announced) != 1 || fake.announced[0] != public.InfoHash() {
+		t.Errorf("announced %x, want only the public torrent", fake.announced)
+	}
+}
+
+func TestAnnounceOptionReplacesTrackers(t *testing.T) {
+	const passkey = "https://tracker.example.org/0123456789abcdef/announce"
+	meta := &metainfo.MetaInfo{AnnounceList: metainfo.AnnounceList{{"http://tracker.example.com/announce"}}}
+	opts := torrentOptions{Announce: passkey, Trackers: []string{"udp://backup.example.com:6969"}}
+	opts.applyToMeta(meta)
+	if got := meta.UpvertedAnnounceList(); len(got) != 2 || got[0][0] != passkey || got[1][0] != "udp://backup.example.com:6969" {
+		t.Errorf("announce list = %v", got)
+	}
+	if s := opts.String(); s != `{"announce":"https://tracker.example.org/***","trackers":["udp://backup.example.com:6969"]}` {
+		t.Errorf("options logged as %s", s)
+	}
+	if err := (torrentOptions{Announce: "tracker.example.org/announce"}).validate("x.torrent"); err == nil {
+		t.Error("an announce URL without a scheme was accepted")
+	}
+}
@@ -0,0 +1,113 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	progressSampleInterval = 5 * time.Second
+	progressSmoothing      = 0.3 // Weight of the latest sample in the download rate
+)
+
+// downloadProgress is how far along an incomplete torrent is
+type downloadProgress struct {
+	Size      int64    `json:"size"`
+	Completed int64    `json:"completed"` // Bytes downloaded and verified
+	Remaining int64    `json:"remaining"`
+	Percent   float64  `json:"percent"`
+	Rate      int64    `json:"rate"` // Bytes per second, smoothed over the last half minute or so
+	ETA       duration `json:"eta"`  // 0 while there's no rate to go by
+}
+
+func (p downloadProgress) String() string {
+	s := fmt.Sprintf("Downloading %.1f%% at %s, %s left", p.Percent, formatRate(p.Rate), formatBytes(p.Remaining))
+	if p.ETA > 0 {
+		s += ", ETA " + time.Duration(p.ETA).String()
+	}
+	return s
+}
+
+// downloadMeter keeps a smoothed download rate per torrent from periodic samples
+type downloadMeter struct {
+	seeder  *Seeder
+	mu      sync.Mutex
+	samples map[string]downloadSample
+}
+
+type downloadSample struct {
+	completed int64
+	at        time.Time
+	rate      float64
+}
+
+// Sample the torrents' completed bytes until ctx is done
+func (m *downloadMeter) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(progressSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.sample(client.Torrents(), time.Now())
+		}
+	}
+}
+
+func (m *downloadMeter) sample(torrents []*torrent.Torrent, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	present := make(map[string]bool, len(torrents))
+	for _, t := range torrents {
+		if t.Info() == nil {
+			continue
+		}
+		ih := t.InfoHash().HexString()
+		present[ih] = true
+		completed := t.BytesCompleted()
+		prev, ok := m.samples[ih]
+		next := downloadSample{completed: completed, at: now}
+		if ok {
+			rate := float64(completed-prev.completed) / now.Sub(prev.at).Seconds()
+			rate = max(rate, 0)
+			if prev.rate > 0 {
+				rate = progressSmoothing*rate + (1-progressSmoothing)*prev.rate
+			}
+			next.rate = rate
+		}
+		m.samples[ih] = next
+	}
+	for ih := range m.samples {
+		if !present[ih] {
+			delete(m.samples, ih)
+		}
+	}
+}
+
+// Progress returns how far along the torrent's download is, or false once it's complete or
+// before its metadata is known
+func (m *downloadMeter) Progress(t *torrent.Torrent) (downloadProgress, bool) {
+	if t.Info() == nil || m.seeder.downloadComplete(t) {
+		return downloadProgress{}, false
+	}
+	var p downloadProgress
+	p.Size, p.Completed = m.seeder.wantedBytes(t)
+	p.Remaining = p.Size - p.Completed
+	if p.Size > 0 {
+		p.Percent = float64(p.Completed) * 100 / float64(p.Size)
+	}
+	m.mu.Lock()
+	rate := m.samples[t.InfoHash().HexString()].rate
+	m.mu.Unlock()
+	p.Rate = int64(rate)
+	if rate >= 1 {
+		p.ETA = duration(time.Duration(math.Min(float64(p.Remaining)/rate, math.MaxInt64/float64(time.Second))) * time.Second)
+	}
+	return p, true
+}
@@ -0,0 +1,58 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadMeterProgress(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	meta := newTestMeta(t, dir, "a.iso", 64<<10)
+
+	// Only the first half of the data is there
+	path := filepath.Join(dir, "a.iso")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, append(data[:32<<10:32<<10], make([]byte, 32<<10)...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tor, err := client.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tor.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+
+	m := &downloadMeter{seeder: s, samples: make(map[string]downloadSample)}
+	if p, ok := m.Progress(tor); !ok || p.Completed != 32<<10 || p.Rate != 0 || p.ETA != 0 {
+		t.Errorf("before any samples, Progress = %+v, %v", p, ok)
+	}
+
+	// It arrived over 4 seconds since the torrent was last sampled with nothing
+	start := time.Now()
+	m.samples[tor.InfoHash().HexString()] = downloadSample{at: start}
+	m.sample(client.Torrents(), start.Add(4*time.Second))
+	want := downloadProgress{Size: 64 << 10, Completed: 32 << 10, Remaining: 32 << 10, Percent: 50, Rate: 8 << 10, ETA: duration(4 * time.Second)}
+	if p, ok := m.Progress(tor); !ok || p != want {
+		t.Errorf("Progress = %+v, want %+v", p, want)
+	}
+
+	// The rate eases off while nothing arrives
+	m.sample(client.Torrents(), start.Add(8*time.Second))
+	if p, _ := m.Progress(tor); p.Rate != 5734 {
+		t.Errorf("after a stalled sample, rate = %d, want 5734", p.Rate)
+	}
+
+	tor.Drop()
+	m.sample(client.Torrents(), start.Add(12*time.Second))
+	if len(m.samples) != 0 {
+		t.Errorf("%d samples kept for removed torrents", len(m.samples))
+	}
+}
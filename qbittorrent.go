@@ -0,0 +1,433 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/api"
+)
+
+const (
+	qbittorrentVersion    = "v4.6.0" // The qBittorrent release whose Web API this matches
+	qbittorrentAPIVersion = "2.9.3"
+	qbittorrentCookie     = "SID"
+	qbittorrentMaxUpload  = 32 << 20 // Bytes of .torrent files accepted in one add request
+)
+
+// qbittorrentAPI serves the commonly used part of qBittorrent's Web API, so mobile apps and
+// dashboards made for qBittorrent can list, add, pause, resume and delete torrents. Changes go
+// through the same configuration updates as the HTTP API, and last until the next reload.
+type qbittorrentAPI struct {
+	seeder *Seeder
+	api    *apiServer
+	token  string // The password to log in with, anything goes if empty
+
+	mu       sync.Mutex
+	sessions map[string]bool // Logged in session cookies
+}
+
+func newQBittorrentAPI(s *Seeder, management *apiServer, token string) *qbittorrentAPI {
+	return &qbittorrentAPI{seeder: s, api: management, token: token, sessions: make(map[string]bool)}
+}
+
+func (q *qbittorrentAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v2/auth/login", q.login)
+	mux.HandleFunc("POST /api/v2/auth/logout", q.logout)
+	mux.Handle("GET /api/v2/app/version", q.authed(q.version))
+	mux.Handle("GET /api/v2/app/webapiVersion", q.authed(q.webAPIVersion))
+	mux.Handle("GET /api/v2/torrents/info", q.authed(q.info))
+	mux.Handle("POST /api/v2/torrents/add", q.authed(q.add))
+	mux.Handle("POST /api/v2/torrents/delete", q.authed(q.delete))
+	for _, action := range []string{"pause", "stop", "resume", "start", "reannounce"} {
+		mux.Handle("POST /api/v2/torrents/"+action, q.authed(q.batch(action)))
+	}
+	return mux
+}
+
+// serve serves the API until ctx is done, over TLS with a TLS config
+func (q *qbittorrentAPI) serve(ctx context.Context, l net.Listener, tlsConfig *tls.Config) {
+	log.Printf("🌐 qBittorrent Web API listening on %s", l.Addr())
+	if err := api.Serve(ctx, l, q.handler(), tlsConfig); err != nil {
+		log.Printf("⚠️ qBittorrent Web API stopped: %v", err)
+	}
+}
+
+// login starts a session when the password is the API token. Like qBittorrent, a wrong one still
+// gets a 200, saying Fails.
+func (q *qbittorrentAPI) login(w http.ResponseWriter, r *http.Request) {
+	if q.token != "" && subtle.ConstantTimeCompare([]byte(r.FormValue("password")), []byte(q.token)) != 1 {
+		io.WriteString(w, "Fails.")
+		return
+	}
+	id := make([]byte, 16)
+	rand.Read(id)
+	sid := hex.EncodeToString(id)
+	q.mu.Lock()
+	q.sessions[sid] = true
+	q.mu.Unlock()
+	http.SetCookie(w, &http.Cookie{Name: qbittorrentCookie, Value: sid, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+	io.WriteString(w, "Ok.")
+}
+
+func (q *qbittorrentAPI) logout(w http.ResponseWriter, r *http.Request) {
+	if c, err := r.Cookie(qbittorrentCookie); err == nil {
+		q.mu.Lock()
+		delete(q.sessions, c.Value)
+		q.mu.Unlock()
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// authed lets requests through with a session cookie from logging in, or without one if no token
+// is set
+func (q *qbittorrentAPI) authed(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if q.token != "" {
+			c, err := r.Cookie(qbittorrentCookie)
+			q.mu.Lock()
+			ok := err == nil && q.sessions[c.Value]
+			q.mu.Unlock()
+			if !ok {
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	})
+}
+
+func (q *qbittorrentAPI) version(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, qbittorrentVersion)
+}
+
+func (q *qbittorrentAPI) webAPIVersion(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, qbittorrentAPIVersion)
+}
+
+// qbittorrentTorrent is a torrent as torrents/info describes it. The first label is its
+// category and the others its tags.
+type qbittorrentTorrent struct {
+	Hash          string  `json:"hash"`
+	Name          string  `json:"name"`
+	State         string  `json:"state"`
+	Size          int64   `json:"size"`
+	TotalSize     int64   `json:"total_size"`
+	Progress      float64 `json:"progress"`
+	Completed     int64   `json:"completed"`
+	AmountLeft    int64   `json:"amount_left"`
+	DLSpeed       int64   `json:"dlspeed"`
+	UpSpeed       int64   `json:"upspeed"`
+	Downloaded    int64   `json:"downloaded"`
+	Uploaded      int64   `json:"uploaded"`
+	Ratio         float64 `json:"ratio"`
+	ETA           int64   `json:"eta"` // Seconds, 8640000 when unknown like qBittorrent
+	NumLeechs     int     `json:"num_leechs"`
+	NumSeeds      int     `json:"num_seeds"`
+	NumComplete   int     `json:"num_complete"`   // Seeders in the whole swarm, from tracker scrapes
+	NumIncomplete int     `json:"num_incomplete"` // Leechers in the whole swarm
+	Category      string  `json:"category"`
+	Tags          string  `json:"tags"`
+	SavePath      string  `json:"save_path"`
+	ContentPath   string  `json:"content_path"`
+	AddedOn       int64   `json:"added_on"`
+	CompletionOn  int64   `json:"completion_on"`
+	MagnetURI     string  `json:"magnet_uri"`
+	labels        []string
+}
+
+const qbittorrentUnknownETA = 8640000
+
+// qbittorrentState is a torrent's state as qBittorrent names them
+func (s *Seeder) qbittorrentState(t *torrent.Torrent) string {
+	ih := t.InfoHash().HexString()
+	complete := t.Info() != nil && s.downloadComplete(t)
+	suffix := "DL"
+	if complete {
+		suffix = "UP"
+	}
+	switch s.torrentState(t) {
+	case "error":
+		return "error"
+	case "missing data":
+		return "missingFiles"
+	case "paused":
+		return "paused" + suffix
+	case "queued":
+		return "queued" + suffix
+	case "waiting for metadata":
+		return "metaDL"
+	case "downloading":
+		if s.rates.Torrent(ih).Download > 0 {
+			return "downloading"
+		}
+		return "stalledDL"
+	}
+	if s.uploadOnly.Held(ih) {
+		return "checkingUP"
+	}
+	if s.rates.Torrent(ih).Upload > 0 {
+		return "uploading"
+	}
+	return "stalledUP"
+}
+
+func (s *Seeder) describeQBittorrent(t *torrent.Torrent) qbittorrentTorrent {
+	l := s.listTorrent(t)
+	r := s.rates.Torrent(l.InfoHash)
+	stats := t.Stats()
+	d := qbittorrentTorrent{
+		Hash:       l.InfoHash,
+		Name:       l.Name,
+		State:      s.qbittorrentState(t),
+		Size:       l.Size,
+		TotalSize:  l.Size,
+		Completed:  l.Completed,
+		AmountLeft: l.Size - l.Completed,
+		DLSpeed:    r.Download,
+		UpSpeed:    r.Upload,
+		Downloaded: stats.BytesReadData.Int64(),
+		Uploaded:   l.Uploaded,
+		Ratio:      l.Ratio,
+		ETA:        qbittorrentUnknownETA,
+		SavePath:   l.Dir,
+		MagnetURI:  torrentMagnet(t),
+		labels:     l.Labels,
+	}
+	if l.Size > 0 {
+		d.Progress = float64(l.Completed) / float64(l.Size)
+		d.ContentPath = filepath.Join(l.Dir, t.Info().BestName())
+	}
+	if h, ok := swarmHealthOf(t); ok {
+		d.NumSeeds, d.NumLeechs = h.Seeds, h.Leechers
+	}
+	if totals, ok := s.scrapes.Totals(l.InfoHash); ok {
+		d.NumComplete, d.NumIncomplete = totals.Seeders, totals.Leechers
+	}
+	if len(l.Labels) > 0 {
+		d.Category, d.Tags = l.Labels[0], strings.Join(l.Labels[1:], ", ")
+	}
+	if p, ok := s.downloads.Progress(t); ok && p.ETA > 0 {
+		d.ETA = int64(time.Duration(p.ETA).Seconds())
+	}
+	if s.registry != nil {
+		if e, ok := s.registry.Entry(l.InfoHash); ok {
+			d.AddedOn = e.AddedAt.Unix()
+		}
+	}
+	if l.CompletedAt != nil {
+		d.CompletionOn = l.CompletedAt.Unix()
+	}
+	return d
+}
+
+// qbittorrentFilters are torrents/info's ?filter= values
+var qbittorrentFilters = map[string]func(state string) bool{
+	"downloading": func(s string) bool { return strings.HasSuffix(s, "DL") || s == "downloading" },
+	"seeding":     func(s string) bool { return strings.HasSuffix(s, "UP") || s == "uploading" },
+	"completed":   func(s string) bool { return strings.HasSuffix(s, "UP") || s == "uploading" },
+	"paused":      func(s string) bool { return strings.HasPrefix(s, "paused") },
+	"stopped":     func(s string) bool { return strings.HasPrefix(s, "paused") },
+	"active":      func(s string) bool { return s == "downloading" || s == "uploading" },
+	"inactive":    func(s string) bool { return s != "downloading" && s != "uploading" },
+	"stalled":     func(s string) bool { return strings.HasPrefix(s, "stalled") },
+	"errored":     func(s string) bool { return s == "error" || s == "missingFiles" },
+}
+
+// info lists torrents, filtered by ?filter=, ?category=, ?tag= and ?hashes=a|b, sorted by ?sort=
+// with any field and ?reverse=true, and paged by ?offset= and ?limit=
+func (q *qbittorrentAPI) info(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var hashes []string
+	if h := query.Get("hashes"); h != "" && h != "all" {
+		hashes = strings.Split(strings.ToLower(h), "|")
+	}
+	filter := qbittorrentFilters[query.Get("filter")]
+	var list []qbittorrentTorrent
+	for _, t := range q.api.client.Torrents() {
+		d := q.seeder.describeQBittorrent(t)
+		switch {
+		case hashes != nil && !slices.Contains(hashes, d.Hash),
+			filter != nil && !filter(d.State),
+			query.Has("category") && query.Get("category") != d.Category,
+			query.Has("tag") && !slices.Contains(d.labels, query.Get("tag")):
+			continue
+		}
+		list = append(list, d)
+	}
+
+	sortBy := cmp.Or(query.Get("sort"), "name")
+	slices.SortStableFunc(list, func(a, b qbittorrentTorrent) int {
+		return cmp.Or(compareQBittorrentField(a, b, sortBy), strings.Compare(a.Hash, b.Hash))
+	})
+	if query.Get("reverse") == "true" {
+		slices.Reverse(list)
+	}
+	if offset, err := strconv.Atoi(query.Get("offset")); err == nil && offset > 0 {
+		list = list[min(offset, len(list)):]
+	}
+	if limit, err := strconv.Atoi(query.Get("limit")); err == nil && limit > 0 {
+		list = list[:min(limit, len(list))]
+	}
+	if list == nil {
+		list = []qbittorrentTorrent{}
+	}
+	api.WriteJSON(w, http.StatusOK, list)
+}
+
+// compareQBittorrentField compares torrents by one of the fields torrents/info can sort by
+func compareQBittorrentField(a, b qbittorrentTorrent, field string) int {
+	switch field {
+	case "size", "total_size":
+		return cmp.Compare(a.Size, b.Size)
+	case "progress":
+		return cmp.Compare(a.Progress, b.Progress)
+	case "dlspeed":
+		return cmp.Compare(a.DLSpeed, b.DLSpeed)
+	case "upspeed":
+		return cmp.Compare(a.UpSpeed, b.UpSpeed)
+	case "uploaded":
+		return cmp.Compare(a.Uploaded, b.Uploaded)
+	case "ratio":
+		return cmp.Compare(a.Ratio, b.Ratio)
+	case "added_on":
+		return cmp.Compare(a.AddedOn, b.AddedOn)
+	case "completion_on":
+		return cmp.Compare(a.CompletionOn, b.CompletionOn)
+	case "state":
+		return strings.Compare(a.State, b.State)
+	case "category":
+		return strings.Compare(a.Category, b.Category)
+	}
+	return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+}
+
+// add adds the torrents in the urls field, one per line, and the uploaded .torrent files, all with
+// the category and tags as labels and savepath as their data directory. Like qBittorrent it
+// answers Fails. if none could be added.
+func (q *qbittorrentAPI) add(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(qbittorrentMaxUpload); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var labels []string
+	if category := r.FormValue("category"); category != "" {
+		labels = append(labels, category)
+	}
+	for _, tag := range strings.Split(r.FormValue("tags"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			labels = appendMissing(labels, tag)
+		}
+	}
+	paused := r.FormValue("paused") == "true" || r.FormValue("stopped") == "true"
+
+	var urls []string
+	for _, url := range strings.Split(r.FormValue("urls"), "\n") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	if r.MultipartForm != nil {
+		for _, fh := range r.MultipartForm.File["torrents"] {
+			f, err := fh.Open()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			data, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			path, err := q.api.keepTorrentFile(data)
+			if err != nil {
+				log.Printf("⚠️ Couldn't add %s through the qBittorrent API: %v", fh.Filename, err)
+				continue
+			}
+			urls = append(urls, path)
+		}
+	}
+
+	added := 0
+	for _, url := range urls {
+		t, _, err := q.api.addSource(url, r.FormValue("savepath"), torrentOptions{Labels: labels})
+		if err != nil {
+			log.Printf("⚠️ Couldn't add %s through the qBittorrent API: %v", url, err)
+			continue
+		}
+		if paused {
+			q.seeder.pauses.Pause(t)
+		}
+		added++
+	}
+	if added == 0 {
+		io.WriteString(w, "Fails.")
+		return
+	}
+	io.WriteString(w, "Ok.")
+}
+
+// selected returns the torrents in the hashes field, separated by |, or all of them for "all"
+func (q *qbittorrentAPI) selected(r *http.Request) []*torrent.Torrent {
+	hashes := strings.Split(strings.ToLower(r.FormValue("hashes")), "|")
+	return slices.DeleteFunc(q.api.client.Torrents(), func(t *torrent.Torrent) bool {
+		return !slices.Contains(hashes, "all") && !slices.Contains(hashes, t.InfoHash().HexString())
+	})
+}
+
+// delete removes the torrents' sources. Deleting their files isn't supported.
+func (q *qbittorrentAPI) delete(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("deleteFiles") == "true" {
+		http.Error(w, "Deleting files isn't supported", http.StatusBadRequest)
+		return
+	}
+	ts := q.selected(r)
+	results := make([]batchTorrent, len(ts))
+	if err := q.api.removeSources(ts, results); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for i, result := range results {
+		if result.Error != "" {
+			log.Printf("⚠️ Couldn't delete %s through the qBittorrent API: %s", ts[i].Name(), result.Error)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// batch pauses, resumes or reannounces the selected torrents. qBittorrent 5 calls pausing and
+// resuming stop and start.
+func (q *qbittorrentAPI) batch(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, t := range q.selected(r) {
+			switch ih := t.InfoHash().HexString(); action {
+			case "pause", "stop":
+				q.seeder.pauses.Pause(t)
+			case "resume", "start":
+				q.seeder.pauses.Resume(t)
+			case "reannounce":
+				if !q.seeder.uploadOnly.Held(ih) && !q.seeder.pauses.IsPaused(ih) && !q.seeder.schedule.Paused() {
+					q.seeder.announces.Request(q.api.ctx, q.api.client, t)
+				}
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
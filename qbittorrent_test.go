@@ -0,0 +1,115 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestQBittorrentAPI(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu", "lts"}}}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	s.torrentSources.Add("a.torrent", a)
+	handler := newQBittorrentAPI(s, &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}, "secret").handler()
+
+	var cookie *http.Cookie
+	do := func(r *http.Request) *httptest.ResponseRecorder {
+		if cookie != nil {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	form := func(path string, values url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return do(r)
+	}
+	info := func(query string) []qbittorrentTorrent {
+		t.Helper()
+		w := do(httptest.NewRequest(http.MethodGet, "/api/v2/torrents/info"+query, nil))
+		var list []qbittorrentTorrent
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("torrents/info%s: status %d, %v", query, w.Code, err)
+		}
+		return list
+	}
+
+	if w := do(httptest.NewRequest(http.MethodGet, "/api/v2/torrents/info", nil)); w.Code != http.StatusForbidden {
+		t.Errorf("without logging in: status %d, want 403", w.Code)
+	}
+	if w := form("/api/v2/auth/login", url.Values{"username": {"admin"}, "password": {"wrong"}}); w.Body.String() != "Fails." {
+		t.Errorf("login with the wrong password: %q", w.Body)
+	}
+	w := form("/api/v2/auth/login", url.Values{"username": {"admin"}, "password": {"secret"}})
+	if w.Body.String() != "Ok." || len(w.Result().Cookies()) != 1 {
+		t.Fatalf("login: %q, cookies %v", w.Body, w.Result().Cookies())
+	}
+	cookie = w.Result().Cookies()[0]
+
+	list := info("")
+	if len(list) != 1 {
+		t.Fatalf("torrents/info listed %d torrents", len(list))
+	}
+	if got := list[0]; got.Hash != a.InfoHash().HexString() || got.Name != "a.iso" || got.Progress != 1 || got.State != "stalledUP" || got.Category != "ubuntu" || got.Tags != "lts" {
+		t.Errorf("torrents/info = %+v", got)
+	}
+	if len(info("?filter=downloading")) != 0 || len(info("?filter=seeding&category=ubuntu&tag=lts")) != 1 || len(info("?category=debian")) != 0 {
+		t.Error("torrents/info filters don't match the seeding torrent")
+	}
+
+	form("/api/v2/torrents/pause", url.Values{"hashes": {a.InfoHash().HexString()}})
+	if list := info("?filter=paused"); len(list) != 1 || list[0].State != "pausedUP" {
+		t.Errorf("after pausing: %+v", list)
+	}
+	form("/api/v2/torrents/resume", url.Values{"hashes": {"all"}})
+	if s.pauses.IsPaused(a.InfoHash().HexString()) {
+		t.Error("resuming all didn't resume the torrent")
+	}
+
+	// Adding an uploaded .torrent file with a category and tags
+	meta := newTestMeta(t, dir, "b.iso", 32<<10)
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("torrents", "b.iso.torrent")
+	meta.Write(fw)
+	mw.WriteField("category", "debian")
+	mw.WriteField("tags", "stable, netinst")
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/api/v2/torrents/add", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if w := do(r); w.Body.String() != "Ok." {
+		t.Fatalf("torrents/add: status %d, %q", w.Code, w.Body)
+	}
+	waitForSeedTorrents(t, s)
+	b := filepath.Join(dir, importDirName, meta.HashInfoBytes().HexString()+".torrent")
+	if labels := s.liveSettings.Get().TorrentOptions[b].Labels; !slices.Equal(labels, []string{"debian", "stable", "netinst"}) {
+		t.Errorf("labels of the added torrent = %v", labels)
+	}
+	if w := form("/api/v2/torrents/add", url.Values{"urls": {"nope.torrent"}}); w.Body.String() != "Fails." {
+		t.Errorf("adding a missing torrent file: %q", w.Body)
+	}
+
+	if w := form("/api/v2/torrents/delete", url.Values{"hashes": {a.InfoHash().HexString()}, "deleteFiles": {"true"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("deleting files: status %d, want 400", w.Code)
+	}
+	form("/api/v2/torrents/delete", url.Values{"hashes": {a.InfoHash().HexString()}, "deleteFiles": {"false"}})
+	if urls := s.liveSettings.Get().TorrentURLs; slices.Contains(urls, "a.torrent") || !slices.Contains(urls, b) {
+		t.Errorf("torrents after deleting a = %v", urls)
+	}
+}
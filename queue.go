@@ -0,0 +1,267 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const queueCheckInterval = 1 * time.Minute // Frequency of queue rotation
+
+// Orders in which queued torrents are rotated in
+const (
+	queueOrderAge    = "age"    // Oldest torrents first
+	queueOrderDemand = "demand" // Torrents with the most leechers first
+)
+
+type queueConfig struct {
+	MaxActiveDownloads int // 0 for unlimited
+	MaxActiveSeeds     int // 0 for unlimited
+	Order              string
+}
+
+func (c queueConfig) enabled() bool {
+	return c.MaxActiveDownloads > 0 || c.MaxActiveSeeds > 0
+}
+
+func (c queueConfig) validate() error {
+	if c.MaxActiveDownloads < 0 || c.MaxActiveSeeds < 0 {
+		return fmt.Errorf("❌ Active torrent limits can't be negative")
+	}
+	if c.Order != queueOrderAge && c.Order != queueOrderDemand {
+		return fmt.Errorf("❌ Unknown queue order '%s', expected '%s' or '%s'", c.Order, queueOrderAge, queueOrderDemand)
+	}
+	return nil
+}
+
+// What a queued torrent is waiting to do
+const (
+	queuedDownload = "download"
+	queuedSeed     = "seed"
+)
+
+// torrentQueue tracks which torrents are currently held back by the active limits
+type torrentQueue struct {
+	cfg queueConfig // The limits, set before any torrent is added
+
+	admitting sync.Mutex // Serializes admitQueued, so torrents added together can't all take the last slot
+	mu        sync.Mutex
+	queued    map[string]string // Infohash to queuedDownload or queuedSeed
+	admitted  map[string]bool   // Torrents that have been checked and count against the limits
+}
+
+func (q *torrentQueue) IsQueued(infoHash string) bool {
+	return q.kind(infoHash) != ""
+}
+
+// kind returns what the torrent is queued for, "" if it's active
+func (q *torrentQueue) kind(infoHash string) string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.queued[infoHash]
+}
+
+// Forget torrents that are no longer in the client
+func (q *torrentQueue) prune(present map[string]bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for ih := range q.queued {
+		if !present[ih] {
+			delete(q.queued, ih)
+		}
+	}
+	for ih := range q.admitted {
+		if !present[ih] {
+			delete(q.admitted, ih)
+		}
+	}
+}
+
+// admit records that the torrent counts against the limits, and reports whether it didn't yet
+func (q *torrentQueue) admit(infoHash string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.admitted[infoHash] {
+		return false
+	}
+	if q.admitted == nil {
+		q.admitted = make(map[string]bool)
+	}
+	q.admitted[infoHash] = true
+	return true
+}
+
+func (q *torrentQueue) isAdmitted(infoHash string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.admitted[infoHash]
+}
+
+// set records what the torrent is queued for, "" if it's active, and reports a change.
+func (q *torrentQueue) set(infoHash string, kind string) (changed bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.queued[infoHash] == kind {
+		return false
+	}
+	if kind != "" {
+		q.queued[infoHash] = kind
+	} else {
+		delete(q.queued, infoHash)
+	}
+	return true
+}
+
+// Periodically let the highest ranked torrents download or seed, and queue the rest
+func (s *Seeder) manageQueue(ctx context.Context, client *torrent.Client, cfg queueConfig) {
+	if !cfg.enabled() {
+		return
+	}
+
+	ticker := time.NewTicker(queueCheckInterval)
+	defer ticker.Stop()
+
+	// When each torrent was first seen, for age ordering
+	added := make(map[string]time.Time)
+
+	// Rather than leaving everything active until the first tick
+	s.rotateQueue(client, cfg, added)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.rotateQueue(client, cfg, added)
+		}
+	}
+}
+
+// admitQueued queues a torrent that has just been added and checked if the active limit for what
+// it's doing is already reached, rather than leaving it active until the next rotation. Torrents
+// only count against the limits once they've been checked too, as until then it isn't known
+// whether they're downloading or seeding.
+func (s *Seeder) admitQueued(client *torrent.Client, t *torrent.Torrent) {
+	cfg := s.queue.cfg
+	ih := t.InfoHash().HexString()
+	if !cfg.enabled() {
+		return
+	}
+	s.queue.admitting.Lock()
+	defer s.queue.admitting.Unlock()
+	if !s.queue.admit(ih) || s.queue.IsQueued(ih) {
+		return
+	}
+
+	var downloads, seeds int
+	for _, other := range client.Torrents() {
+		oh := other.InfoHash().HexString()
+		if other == t || !s.queue.isAdmitted(oh) || s.queue.IsQueued(oh) {
+			continue
+		}
+		if s.downloadComplete(other) {
+			seeds++
+		} else {
+			downloads++
+		}
+	}
+	switch complete := s.downloadComplete(t); {
+	case complete && cfg.MaxActiveSeeds > 0 && seeds >= cfg.MaxActiveSeeds:
+		s.applyQueueState(t, queuedSeed)
+	case !complete && cfg.MaxActiveDownloads > 0 && downloads >= cfg.MaxActiveDownloads:
+		s.applyQueueState(t, queuedDownload)
+	}
+}
+
+func (s *Seeder) rotateQueue(client *torrent.Client, cfg queueConfig, added map[string]time.Time) {
+	now := time.Now()
+	present := make(map[string]bool)
+	var downloads, seeds []*torrent.Torrent
+	for _, t := range client.Torrents() {
+		ih := t.InfoHash().HexString()
+		present[ih] = true
+		if _, ok := added[ih]; !ok {
+			added[ih] = now
+		}
+		switch {
+		case t.Info() == nil:
+			// Fetching metadata is cheap, so magnets are never queued
+		case s.downloadComplete(t):
+			seeds = append(seeds, t)
+		default:
+			downloads = append(downloads, t)
+		}
+	}
+
+	s.queue.prune(present)
+	for ih := range added {
+		if !present[ih] {
+			delete(added, ih)
+		}
+	}
+
+	// Downloads are always first come, first served
+	byAge := func(a, b *torrent.Torrent) int {
+		return added[a.InfoHash().HexString()].Compare(added[b.InfoHash().HexString()])
+	}
+	slices.SortStableFunc(downloads, byAge)
+	if cfg.Order == queueOrderDemand {
+		leechers := make(map[*torrent.Torrent]int)
+		for _, t := range seeds {
+			leechers[t] = countLeechers(t)
+		}
+		slices.SortStableFunc(seeds, func(a, b *torrent.Torrent) int {
+			return cmp.Or(cmp.Compare(leechers[b], leechers[a]), byAge(a, b))
+		})
+	} else {
+		slices.SortStableFunc(seeds, byAge)
+	}
+
+	for i, t := range downloads {
+		var kind string
+		if cfg.MaxActiveDownloads > 0 && i >= cfg.MaxActiveDownloads {
+			kind = queuedDownload
+		}
+		s.applyQueueState(t, kind)
+	}
+	for i, t := range seeds {
+		var kind string
+		if cfg.MaxActiveSeeds > 0 && i >= cfg.MaxActiveSeeds {
+			kind = queuedSeed
+		}
+		s.applyQueueState(t, kind)
+	}
+}
+
+func (s *Seeder) applyQueueState(t *torrent.Torrent, kind string) {
+	if !s.queue.set(t.InfoHash().HexString(), kind) {
+		return
+	}
+
+	t.AllowDataDownload()
+	t.AllowDataUpload()
+	s.uploadOnly.restrict(t)
+	s.quota.restrict(t)
+	switch kind {
+	case queuedDownload:
+		t.DisallowDataDownload()
+		log.Printf("⏸️ Queued download: %s", t.Name())
+	case queuedSeed:
+		t.DisallowDataUpload()
+		// A few connections are kept so demand can still be observed
+		t.SetMaxEstablishedConns(idleConnsPerTorrent)
+		log.Printf("⏸️ Queued seed: %s", t.Name())
+	default:
+		if s.diskPauses.IsPaused(t.InfoHash().HexString()) {
+			t.DisallowDataDownload() // Until there's space for it
+		}
+		log.Printf("▶️ Started queued torrent: %s", t.Name())
+	}
+	s.schedule.restrict(t)
+	s.pauses.restrict(t)
+}
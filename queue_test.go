@@ -0,0 +1,94 @@
+package distroseed
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueueConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg queueConfig
+		err string // Part of the error expected, if any
+	}{
+		{cfg: queueConfig{Order: queueOrderAge}},
+		{cfg: queueConfig{MaxActiveDownloads: 2, MaxActiveSeeds: 5, Order: queueOrderDemand}},
+		{cfg: queueConfig{MaxActiveSeeds: -1, Order: queueOrderAge}, err: "can't be negative"},
+		{cfg: queueConfig{Order: "size"}, err: "Unknown queue order 'size'"},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.validate()
+		if tt.err == "" && err != nil || tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%+v.validate() = %v, want %q", tt.cfg, err, tt.err)
+		}
+	}
+}
+
+func TestRotateQueueByAge(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.queue = &torrentQueue{queued: make(map[string]string)}
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	newest := addSeedingTestTorrent(t, client, dir, "c.iso")
+	oldest := addSeedingTestTorrent(t, client, dir, "a.iso")
+	middle := addSeedingTestTorrent(t, client, dir, "b.iso")
+	now := time.Now()
+	added := map[string]time.Time{
+		oldest.InfoHash().HexString(): now.Add(-3 * time.Hour),
+		middle.InfoHash().HexString(): now.Add(-2 * time.Hour),
+		newest.InfoHash().HexString(): now.Add(-time.Hour),
+	}
+
+	s.rotateQueue(client, queueConfig{MaxActiveSeeds: 2, Order: queueOrderAge}, added)
+	if s.queue.IsQueued(oldest.InfoHash().HexString()) || s.queue.IsQueued(middle.InfoHash().HexString()) {
+		t.Error("one of the two oldest seeds was queued")
+	}
+	if !s.queue.IsQueued(newest.InfoHash().HexString()) {
+		t.Error("the newest seed wasn't queued beyond the limit")
+	}
+
+	// Dropping a torrent lets a queued one in
+	oldest.Drop()
+	s.rotateQueue(client, queueConfig{MaxActiveSeeds: 2, Order: queueOrderAge}, added)
+	if s.queue.IsQueued(newest.InfoHash().HexString()) {
+		t.Error("the newest seed is still queued with a slot free")
+	}
+	if _, ok := added[oldest.InfoHash().HexString()]; ok {
+		t.Error("the dropped torrent is still tracked")
+	}
+}
+
+func TestManageQueueRotatesRightAway(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	b := addSeedingTestTorrent(t, client, dir, "b.iso")
+
+	// Stopped before the first tick
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.manageQueue(ctx, client, queueConfig{MaxActiveSeeds: 1, Order: queueOrderAge})
+	if s.queue.IsQueued(a.InfoHash().HexString()) == s.queue.IsQueued(b.InfoHash().HexString()) {
+		t.Error("the limit wasn't applied until the first tick")
+	}
+}
+
+func TestAdmitQueued(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.queue.cfg = queueConfig{MaxActiveSeeds: 1, Order: queueOrderAge}
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	first := addSeedingTestTorrent(t, client, dir, "a.iso")
+	second := addSeedingTestTorrent(t, client, dir, "b.iso")
+
+	s.admitQueued(client, first)
+	if s.queue.IsQueued(first.InfoHash().HexString()) {
+		t.Error("the first seed was queued with a slot free")
+	}
+	s.admitQueued(client, second)
+	if s.queue.kind(second.InfoHash().HexString()) != queuedSeed {
+		t.Error("a seed added with the limit reached wasn't queued")
+	}
+}
@@ -0,0 +1,161 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	quotaThrottleFrom  = 0.8 // Share of the quota after which uploads are spread over the rest of the period
+	quotaMinLimit      = 1   // KiB/s, the lowest uploads are throttled to before pausing
+	quotaCheckInterval = time.Minute
+)
+
+// quotaConfig is a monthly upload allowance, for hosts on metered connections
+type quotaConfig struct {
+	MonthlyBytes int64 // 0 for no quota
+	ResetDay     int   // Day of the month the accounting period starts on
+}
+
+func (c quotaConfig) validate() error {
+	if c.MonthlyBytes < 0 {
+		return fmt.Errorf("❌ Monthly quota can't be negative")
+	}
+	if c.ResetDay < 1 || c.ResetDay > 28 {
+		return fmt.Errorf("❌ Quota reset day must be between 1 and 28, got %d", c.ResetDay)
+	}
+	return nil
+}
+
+// period returns the start and end of the accounting period now falls in, at local midnight
+func (c quotaConfig) period(now time.Time) (start, end time.Time) {
+	start = time.Date(now.Year(), now.Month(), c.ResetDay, 0, 0, 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, -1, 0)
+	}
+	return start, start.AddDate(0, 1, 0)
+}
+
+// limit returns the upload limit in KiB/s for having used the given bytes by now, 0 while
+// there's plenty left, and whether the quota is used up. Past quotaThrottleFrom, what's left is
+// spread evenly over the rest of the period, so the limit drops as the quota runs out.
+func (c quotaConfig) limit(used int64, now time.Time) (kibPerSecond int64, exhausted bool) {
+	if used >= c.MonthlyBytes {
+		return 0, true
+	}
+	if float64(used) < float64(c.MonthlyBytes)*quotaThrottleFrom {
+		return 0, false
+	}
+	_, end := c.period(now)
+	seconds := max(end.Sub(now).Seconds(), 1)
+	return max(int64(float64(c.MonthlyBytes-used)/seconds/1024), quotaMinLimit), false
+}
+
+// quotaStatus is the quota's state as of the last check
+type quotaStatus struct {
+	Quota       int64     `json:"quota"`
+	Used        int64     `json:"used"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	UploadLimit int64     `json:"upload_limit"` // KiB/s, 0 when not throttled
+	Paused      bool      `json:"paused"`
+}
+
+// bandwidthQuota throttles and then pauses uploads as the monthly quota is used up, counting
+// what the upload history recorded since the period started
+type bandwidthQuota struct {
+	seeder *Seeder
+	cfg    quotaConfig
+	mu     sync.Mutex
+	status quotaStatus
+}
+
+func newBandwidthQuota(s *Seeder, cfg quotaConfig) *bandwidthQuota {
+	return &bandwidthQuota{seeder: s, cfg: cfg, status: quotaStatus{Quota: cfg.MonthlyBytes}}
+}
+
+func (q *bandwidthQuota) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+	for {
+		q.check(client, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *bandwidthQuota) check(client *torrent.Client, now time.Time) {
+	start, end := q.cfg.period(now)
+	var used int64
+	for _, d := range q.seeder.uploads.Between(start, now.AddDate(0, 0, 1)) {
+		used += d.Uploaded
+	}
+	limit, exhausted := q.cfg.limit(used, now)
+
+	q.mu.Lock()
+	previous := q.status
+	q.status = quotaStatus{Quota: q.cfg.MonthlyBytes, Used: used, PeriodStart: start, PeriodEnd: end, UploadLimit: limit, Paused: exhausted}
+	q.mu.Unlock()
+
+	switch {
+	case exhausted && !previous.Paused:
+		log.Printf("🛑 Used %s of the %s monthly quota, pausing uploads until %s", formatBytes(used), formatBytes(q.cfg.MonthlyBytes), end.Format(time.DateOnly))
+		if q.seeder.notifications != nil {
+			q.seeder.notifications.Notify("quota", "Monthly quota used up",
+				fmt.Sprintf("%s of the %s monthly quota has been uploaded, so seeding is paused until the period resets on %s.", formatBytes(used), formatBytes(q.cfg.MonthlyBytes), end.Format(time.DateOnly)))
+		}
+	case !exhausted && previous.Paused:
+		log.Printf("▶️ New quota period started, resuming uploads")
+		if q.seeder.notifications != nil {
+			q.seeder.notifications.Resolved("quota")
+		}
+		for _, t := range client.Torrents() {
+			if q.seeder.queue.kind(t.InfoHash().HexString()) != queuedSeed {
+				t.AllowDataUpload()
+			}
+			q.seeder.uploadOnly.restrict(t)
+			q.seeder.schedule.restrict(t)
+			q.seeder.pauses.restrict(t)
+		}
+	case limit > 0 && previous.UploadLimit == 0:
+		log.Printf("🚦 Used %s of the %s monthly quota, limiting uploads to %s to last until %s", formatBytes(used), formatBytes(q.cfg.MonthlyBytes), formatRateLimit(limit), end.Format(time.DateOnly))
+	}
+	if exhausted {
+		// Also catches torrents added since
+		for _, t := range client.Torrents() {
+			t.DisallowDataUpload()
+		}
+	}
+	if limit != previous.UploadLimit {
+		q.seeder.bandwidth.setQuotaLimit(limit)
+	}
+}
+
+// restrict keeps a torrent from uploading while the quota is used up, after its uploads were
+// allowed for other reasons
+func (q *bandwidthQuota) restrict(t *torrent.Torrent) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	paused := q.status.Paused
+	q.mu.Unlock()
+	if paused {
+		t.DisallowDataUpload()
+	}
+}
+
+// Status returns the quota's state as of the last check
+func (q *bandwidthQuota) Status() quotaStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.status
+}
@@ -0,0 +1,81 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pawl/distro-seed/stats"
+)
+
+func TestQuotaPeriod(t *testing.T) {
+	cfg := quotaConfig{MonthlyBytes: 1 << 40, ResetDay: 15}
+	for now, want := range map[time.Time]time.Time{
+		time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local): time.Date(2025, 3, 15, 0, 0, 0, 0, time.Local),
+		time.Date(2025, 3, 15, 0, 0, 0, 0, time.Local):  time.Date(2025, 3, 15, 0, 0, 0, 0, time.Local),
+		time.Date(2025, 1, 3, 12, 0, 0, 0, time.Local):  time.Date(2024, 12, 15, 0, 0, 0, 0, time.Local),
+	} {
+		start, end := cfg.period(now)
+		if !start.Equal(want) || !end.Equal(want.AddDate(0, 1, 0)) {
+			t.Errorf("period of %s = %s to %s, want from %s", now, start, end, want)
+		}
+	}
+}
+
+func TestQuotaLimit(t *testing.T) {
+	cfg := quotaConfig{MonthlyBytes: 1000 << 30, ResetDay: 1}
+	// 10 days left
+	now := time.Date(2025, 4, 21, 0, 0, 0, 0, time.Local)
+	if limit, exhausted := cfg.limit(500<<30, now); limit != 0 || exhausted {
+		t.Errorf("half used: limit %d, exhausted %v", limit, exhausted)
+	}
+	limit, exhausted := cfg.limit(900<<30, now)
+	if want := int64(100<<30) / (10 * 24 * 3600) / 1024; limit != want || exhausted {
+		t.Errorf("90%% used: limit %d, exhausted %v, want %d", limit, exhausted, want)
+	}
+	if lower, _ := cfg.limit(990<<30, now); lower >= limit || lower < quotaMinLimit {
+		t.Errorf("99%% used: limit %d, want below %d", lower, limit)
+	}
+	if _, exhausted := cfg.limit(1000<<30, now); !exhausted {
+		t.Error("quota not exhausted when used up")
+	}
+	if err := (quotaConfig{ResetDay: 31}).validate(); err == nil {
+		t.Error("reset day 31 is valid")
+	}
+}
+
+func TestQuotaPausesUploads(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	resetTestState(s, testConfig())
+	client := newTestClient(t, dir)
+	addSeedingTestTorrent(t, client, dir, "a.iso")
+	s.uploads = stats.LoadHistory(dir)
+
+	q := newBandwidthQuota(s, quotaConfig{MonthlyBytes: 1000, ResetDay: 1})
+	now := time.Now()
+	s.uploads.Add(900, now)
+	q.check(client, now)
+	if st := q.Status(); st.Paused || st.UploadLimit != quotaMinLimit || st.Used != 900 {
+		t.Errorf("at 90%%: %+v", st)
+	}
+	if upload, _ := s.bandwidth.Limits(0, 0); upload != quotaMinLimit {
+		t.Errorf("upload limit %d at 90%%, want %d", upload, quotaMinLimit)
+	}
+
+	s.uploads.Add(100, now)
+	q.check(client, now)
+	if st := q.Status(); !st.Paused {
+		t.Errorf("used up: %+v", st)
+	}
+	if upload, _ := s.bandwidth.Limits(0, 0); upload != 0 {
+		t.Errorf("upload limit %d while paused, want none on top of the pause", upload)
+	}
+
+	// The next period starts with nothing used
+	_, end := q.cfg.period(now)
+	q.check(client, end)
+	if st := q.Status(); st.Paused || st.Used != 0 {
+		t.Errorf("next period: %+v", st)
+	}
+}
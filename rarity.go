@@ -0,0 +1,138 @@
+package distroseed
+
+import (
+	"log"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	rareSeedCount      = 2  // Complete torrents with at most this many other connected seeds are favored
+	crowdedSeedCount   = 50 // Complete torrents with at least this many connected seeds give way
+	crowdedUploadShare = 4  // Crowded torrents may use at most 1/crowdedUploadShare of the upload limit
+)
+
+// swarmPriority is how much a seeded torrent needs us, going by the other seeds in its swarm
+type swarmPriority int
+
+const (
+	priorityNormal swarmPriority = iota
+	priorityRare
+	priorityCrowded
+)
+
+func (p swarmPriority) String() string {
+	switch p {
+	case priorityRare:
+		return "rare"
+	case priorityCrowded:
+		return "crowded"
+	default:
+		return "normal"
+	}
+}
+
+// swarmPriorities classifies complete torrents by swarm health, so announces, connection slots
+// and upload bandwidth go to torrents where we're one of few seeds rather than one of hundreds
+type swarmPriorities struct {
+	seeder *Seeder
+	mu     sync.Mutex
+	levels map[string]swarmPriority
+}
+
+func newSwarmPriorities(s *Seeder) *swarmPriorities {
+	return &swarmPriorities{seeder: s, levels: make(map[string]swarmPriority)}
+}
+
+// update classifies the torrents from their connected peers, logging the ones that change
+func (p *swarmPriorities) update(torrents []*torrent.Torrent) {
+	if p == nil {
+		return
+	}
+	levels := make(map[string]swarmPriority, len(torrents))
+	for _, t := range torrents {
+		h, ok := swarmHealthOf(t)
+		if !ok || !p.seeder.downloadComplete(t) {
+			continue // Only seeds are prioritized, downloads need all the help they can get
+		}
+		ih := t.InfoHash().HexString()
+		switch {
+		case h.Seeds <= rareSeedCount:
+			levels[ih] = priorityRare
+		case h.Seeds >= crowdedSeedCount:
+			levels[ih] = priorityCrowded
+		default:
+			continue
+		}
+
+		p.mu.Lock()
+		previous := p.levels[ih]
+		p.mu.Unlock()
+		if levels[ih] == previous {
+			continue
+		}
+		switch levels[ih] {
+		case priorityRare:
+			log.Printf("💎 %d other seeds connected for %s, prioritizing it", h.Seeds, t.Name())
+		case priorityCrowded:
+			log.Printf("👥 %d other seeds connected for %s, favoring rarer torrents", h.Seeds, t.Name())
+		}
+	}
+
+	p.mu.Lock()
+	p.levels = levels
+	p.mu.Unlock()
+}
+
+// Of returns the torrent's priority, normal if it's not known
+func (p *swarmPriorities) Of(infoHash string) swarmPriority {
+	if p == nil {
+		return priorityNormal
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.levels[infoHash]
+}
+
+// Crowded returns the infohashes of the torrents with plenty of other seeds
+func (p *swarmPriorities) Crowded() []string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var crowded []string
+	for ih, level := range p.levels {
+		if level == priorityCrowded {
+			crowded = append(crowded, ih)
+		}
+	}
+	return crowded
+}
+
+// connLimit shifts a torrent's connection limit toward rare torrents and away from crowded ones
+func (p *swarmPriorities) connLimit(infoHash string, limit, maxLimit int) int {
+	switch p.Of(infoHash) {
+	case priorityRare:
+		return max(limit, min(limit*2, maxLimit))
+	case priorityCrowded:
+		return min(limit, max(limit/2, minConnsPerTorrent))
+	}
+	return limit
+}
+
+// applySwarmPriorities reclassifies the client's torrents and caps the upload rate of the
+// crowded ones to a share of the global limit, leaving the rest for rarer torrents
+func (s *Seeder) applySwarmPriorities(client *torrent.Client) {
+	if s.priorities == nil {
+		return
+	}
+	s.priorities.update(client.Torrents())
+	upload, _ := s.bandwidth.Limits(s.liveSettings.Get().UploadLimit, 0)
+	var share int64
+	if upload > 0 {
+		share = max(upload/crowdedUploadShare, 1)
+	}
+	s.bandwidth.setCrowded(s.priorities.Crowded(), share)
+}
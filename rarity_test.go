@@ -0,0 +1,74 @@
+package distroseed
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestSwarmPrioritiesUpdate(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	seeding := addSeedingTestTorrent(t, client, dir, "seeding.iso")
+	downloading, err := client.AddTorrent(newTestMeta(t, t.TempDir(), "downloading.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := newSwarmPriorities(s)
+	p.levels["gone"] = priorityCrowded
+	p.update(client.Torrents())
+	if got := p.Of(seeding.InfoHash().HexString()); got != priorityRare {
+		t.Errorf("a seed with no other seeds is %s, want rare", got)
+	}
+	if got := p.Of(downloading.InfoHash().HexString()); got != priorityNormal {
+		t.Errorf("a download is %s, want normal", got)
+	}
+	if crowded := p.Crowded(); len(crowded) != 0 {
+		t.Errorf("torrents that are gone are still crowded: %v", crowded)
+	}
+
+	var none *swarmPriorities
+	none.update(client.Torrents())
+	if got := none.Of(seeding.InfoHash().HexString()); got != priorityNormal {
+		t.Errorf("without -prioritize-rare, a seed is %s", got)
+	}
+}
+
+func TestSwarmPrioritiesConnLimit(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	p := &swarmPriorities{seeder: s, levels: map[string]swarmPriority{"rare": priorityRare, "crowded": priorityCrowded}}
+	tests := []struct {
+		ih    string
+		limit int
+		want  int
+	}{
+		{"normal", 100, 100},
+		{"rare", 100, 200},
+		{"rare", 200, defaultMaxConnsPerTorrent},
+		{"rare", 2 * defaultMaxConnsPerTorrent, 2 * defaultMaxConnsPerTorrent},
+		{"crowded", 100, 50},
+		{"crowded", minConnsPerTorrent + 1, minConnsPerTorrent},
+		{"crowded", 10, 10},
+	}
+	for _, tt := range tests {
+		if got := p.connLimit(tt.ih, tt.limit, defaultMaxConnsPerTorrent); got != tt.want {
+			t.Errorf("connLimit(%s, %d) = %d, want %d", tt.ih, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestBandwidthScheduleCrowded(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	sched := newTestBandwidthSchedule(s)
+	crowded, rare := sched.torrentLimiter("aa"), sched.torrentLimiter("bb")
+	sched.setCrowded([]string{"aa"}, 250)
+	if crowded.Limit() != rate.Limit(250*1024) || rare.Limit() != rate.Inf {
+		t.Errorf("limits = %v and %v, want 250 KiB/s for the crowded torrent only", crowded.Limit(), rare.Limit())
+	}
+	sched.setCrowded(nil, 250)
+	if crowded.Limit() != rate.Inf {
+		t.Errorf("limit once it's no longer crowded = %v, want unlimited", crowded.Limit())
+	}
+}
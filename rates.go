@@ -0,0 +1,161 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	rateSampleInterval = 5 * time.Second
+	rateCurrentWindow  = 10 * time.Second // What counts as the current rate
+	rateHistory        = 15 * time.Minute // The longest average kept
+)
+
+// transferRates are upload and download speeds in bytes per second, currently and averaged over
+// the last 1 and 15 minutes, or as long as there have been samples if that's shorter
+type transferRates struct {
+	Upload      int64 `json:"upload"`
+	Download    int64 `json:"download"`
+	Upload1m    int64 `json:"upload_1m"`
+	Download1m  int64 `json:"download_1m"`
+	Upload15m   int64 `json:"upload_15m"`
+	Download15m int64 `json:"download_15m"`
+}
+
+func (r transferRates) String() string {
+	return fmt.Sprintf("↑ %s ↓ %s", formatRate(r.Upload), formatRate(r.Download))
+}
+
+// transferCounters are the bytes of piece data sent and received so far
+type transferCounters struct {
+	Uploaded   int64
+	Downloaded int64
+}
+
+type rateSample struct {
+	at time.Time
+	transferCounters
+}
+
+// rateMeter keeps recent samples of the transfer counters per torrent and for the whole client,
+// to work out rates over rolling windows
+type rateMeter struct {
+	mu         sync.Mutex
+	torrents   map[string][]rateSample // By infohash, oldest first
+	total      []rateSample            // The client's counters, which don't drop when a torrent is removed
+	lastUpload map[string]time.Time    // When each torrent last uploaded, or was first sampled
+}
+
+func newRateMeter() *rateMeter {
+	return &rateMeter{torrents: make(map[string][]rateSample), lastUpload: make(map[string]time.Time)}
+}
+
+// Sample the client's transfer counters until ctx is done
+func (m *rateMeter) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(rateSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			counters := make(map[string]transferCounters)
+			for _, t := range client.Torrents() {
+				stats := t.Stats()
+				counters[t.InfoHash().HexString()] = transferCounters{
+					Uploaded:   stats.BytesWrittenData.Int64(),
+					Downloaded: stats.BytesReadData.Int64(),
+				}
+			}
+			stats := client.Stats()
+			total := transferCounters{Uploaded: stats.BytesWrittenData.Int64(), Downloaded: stats.BytesReadData.Int64()}
+			m.sample(counters, total, time.Now())
+		}
+	}
+}
+
+func (m *rateMeter) sample(counters map[string]transferCounters, total transferCounters, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for ih, c := range counters {
+		samples := m.torrents[ih]
+		if len(samples) == 0 || c.Uploaded > samples[len(samples)-1].Uploaded {
+			m.lastUpload[ih] = now
+		}
+		m.torrents[ih] = appendRateSample(samples, rateSample{at: now, transferCounters: c})
+	}
+	for ih := range m.torrents {
+		if _, ok := counters[ih]; !ok {
+			delete(m.torrents, ih)
+			delete(m.lastUpload, ih)
+		}
+	}
+	m.total = appendRateSample(m.total, rateSample{at: now, transferCounters: total})
+}
+
+// appendRateSample adds the latest sample, dropping those no longer needed for the longest average
+func appendRateSample(samples []rateSample, s rateSample) []rateSample {
+	samples = append(samples, s)
+	// Keep one sample from at or before the start of the window to measure from
+	drop := 0
+	for drop+1 < len(samples) && !samples[drop+1].at.After(s.at.Add(-rateHistory)) {
+		drop++
+	}
+	return append(samples[:0], samples[drop:]...)
+}
+
+// Torrent returns the torrent's rates, zero until it's been sampled twice
+func (m *rateMeter) Torrent(infoHash string) transferRates {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ratesOf(m.torrents[infoHash])
+}
+
+// LastUpload returns when the torrent last uploaded anything, or when it was first sampled if it
+// hasn't since, zero until it's been sampled
+func (m *rateMeter) LastUpload(infoHash string) time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastUpload[infoHash]
+}
+
+// Total returns the rates across all torrents, including ones since removed
+func (m *rateMeter) Total() transferRates {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return ratesOf(m.total)
+}
+
+func ratesOf(samples []rateSample) transferRates {
+	var r transferRates
+	r.Upload, r.Download = rateOver(samples, rateCurrentWindow)
+	r.Upload1m, r.Download1m = rateOver(samples, time.Minute)
+	r.Upload15m, r.Download15m = rateOver(samples, rateHistory)
+	return r
+}
+
+// rateOver works out the upload and download rates from the oldest sample within the window up
+// to the latest
+func rateOver(samples []rateSample, window time.Duration) (upload, download int64) {
+	if len(samples) < 2 {
+		return 0, 0
+	}
+	latest := samples[len(samples)-1]
+	from := len(samples) - 2
+	for from > 0 && !samples[from-1].at.Before(latest.at.Add(-window)) {
+		from--
+	}
+	base := samples[from]
+	seconds := latest.at.Sub(base.at).Seconds()
+	if seconds <= 0 {
+		return 0, 0
+	}
+	// Counters start over if a torrent is removed and added back
+	upload = int64(float64(max(latest.Uploaded-base.Uploaded, 0)) / seconds)
+	download = int64(float64(max(latest.Downloaded-base.Downloaded, 0)) / seconds)
+	return upload, download
+}
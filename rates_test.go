@@ -0,0 +1,42 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateMeter(t *testing.T) {
+	m := newRateMeter()
+	start := time.Now()
+	if r := m.Total(); r != (transferRates{}) {
+		t.Errorf("before any samples, Total = %#v", r)
+	}
+
+	// Uploading 1 KiB/s for 15 minutes, then 10 KiB/s for the last minute
+	var uploaded int64
+	for i := 0; i <= 192; i++ {
+		if i > 180 {
+			uploaded += 10 << 10 * int64(rateSampleInterval/time.Second)
+		} else if i > 0 {
+			uploaded += 1 << 10 * int64(rateSampleInterval/time.Second)
+		}
+		c := transferCounters{Uploaded: uploaded, Downloaded: int64(i) * 512 * int64(rateSampleInterval/time.Second)}
+		m.sample(map[string]transferCounters{"a": c}, c, start.Add(time.Duration(i)*rateSampleInterval))
+	}
+	want := transferRates{Upload: 10 << 10, Download: 512, Upload1m: 10 << 10, Download1m: 512, Upload15m: 1638, Download15m: 512}
+	if r := m.Torrent("a"); r != want {
+		t.Errorf("Torrent = %#v, want %#v", r, want)
+	}
+	if n := len(m.total); n != 181 {
+		t.Errorf("%d samples kept, want 181 covering 15 minutes", n)
+	}
+
+	// Removed torrents are forgotten, but still count towards the total
+	m.sample(nil, transferCounters{Uploaded: uploaded + 100<<10, Downloaded: 192 * 512 * 5}, start.Add(193*rateSampleInterval))
+	if _, ok := m.torrents["a"]; ok {
+		t.Error("samples kept for a removed torrent")
+	}
+	if r := m.Total(); r.Upload != 15<<10 {
+		t.Errorf("total upload = %d, want %d", r.Upload, 15<<10)
+	}
+}
@@ -0,0 +1,196 @@
+package distroseed
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const registryFileName = "registry.json"
+
+// registryEntry is what's remembered about a torrent across restarts
+type registryEntry struct {
+	InfoHash    string    `json:"info_hash"`
+	Source      string    `json:"source"`
+	Name        string    `json:"name,omitempty"`
+	Duplicates  []string  `json:"duplicates,omitempty"`   // Other sources it was added from
+	TorrentFile string    `json:"torrent_file,omitempty"` // Cached .torrent file, if fetched from a URL
+	DataPath    string    `json:"data_path,omitempty"`    // Payload file or directory
+	AddedAt     time.Time `json:"added_at"`
+}
+
+// torrentRegistry persists an entry per torrent, keyed by infohash, in the data directory
+type torrentRegistry struct {
+	mu       sync.Mutex
+	path     string
+	dataDirs []string // Where torrents' data can be, the data directory first
+	entries  map[string]*registryEntry
+}
+
+// loadRegistry loads the registry from dataDir, where otherDirs are further places torrents'
+// data can be kept
+func loadRegistry(dataDir string, otherDirs ...string) *torrentRegistry {
+	r := &torrentRegistry{
+		path:    filepath.Join(dataDir, registryFileName),
+		entries: make(map[string]*registryEntry),
+	}
+	for _, dir := range append([]string{dataDir}, otherDirs...) {
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		r.dataDirs = append(r.dataDirs, dir)
+	}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not read registry: %v", err)
+		}
+		return r
+	}
+	var entries []*registryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		log.Printf("Warning: Failed to parse registry: %v", err)
+		return r
+	}
+	for _, e := range entries {
+		r.entries[e.InfoHash] = e
+	}
+	return r
+}
+
+// Record notes that a torrent was added from source, keeping what's known about it already
+func (r *torrentRegistry) Record(infoHash, source, torrentFile string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[infoHash]
+	if !ok {
+		e = &registryEntry{InfoHash: infoHash, AddedAt: time.Now()}
+		r.entries[infoHash] = e
+	}
+	e.Source = source
+	e.Duplicates = slices.DeleteFunc(e.Duplicates, func(s string) bool { return s == source })
+	if torrentFile != "" {
+		if abs, err := filepath.Abs(torrentFile); err == nil {
+			torrentFile = abs
+		}
+		e.TorrentFile = torrentFile
+	}
+	r.save()
+}
+
+// Duplicate notes that the torrent was added from another source as well
+func (r *torrentRegistry) Duplicate(infoHash, source string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[infoHash]
+	if !ok || e.Source == source || slices.Contains(e.Duplicates, source) {
+		return
+	}
+	e.Duplicates = append(e.Duplicates, source)
+	r.save()
+}
+
+// SetName records the torrent's name and the directory its payload lives in, once metadata is known
+func (r *torrentRegistry) SetName(infoHash, name, dir string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.entries[infoHash]
+	if !ok {
+		return
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	e.Name = name
+	e.DataPath = filepath.Join(dir, name)
+	r.save()
+}
+
+// Entry returns the torrent's entry, if it has one
+func (r *torrentRegistry) Entry(infoHash string) (registryEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.entries[infoHash]; ok {
+		return *e, true
+	}
+	return registryEntry{}, false
+}
+
+func (r *torrentRegistry) Entries() []registryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := make([]registryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, *e)
+	}
+	return entries
+}
+
+// StalePaths returns the entries whose paths aren't inside the current data directories, which
+// happens when a directory was moved or remounted elsewhere
+func (r *torrentRegistry) StalePaths() (stale []registryEntry) {
+	for _, e := range r.Entries() {
+		for _, p := range []string{e.TorrentFile, e.DataPath} {
+			if p != "" && !slices.ContainsFunc(r.dataDirs, func(dir string) bool { return isWithinDir(p, dir) }) {
+				stale = append(stale, e)
+				break
+			}
+		}
+	}
+	return stale
+}
+
+// Relocate rewrites stored paths under oldDir to be under newDir, returning the entries changed
+func (r *torrentRegistry) Relocate(oldDir, newDir string) (changed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.entries {
+		moved := false
+		for _, p := range []*string{&e.TorrentFile, &e.DataPath} {
+			if *p == "" || !isWithinDir(*p, oldDir) {
+				continue
+			}
+			rel, err := filepath.Rel(oldDir, *p)
+			if err != nil {
+				continue
+			}
+			*p = filepath.Join(newDir, rel)
+			moved = true
+		}
+		if moved {
+			changed++
+		}
+	}
+	r.save()
+	return changed
+}
+
+// save writes the registry, the caller must hold r.mu
+func (r *torrentRegistry) save() {
+	entries := make([]*registryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e)
+	}
+	// Stable order keeps the file diffable
+	slices.SortFunc(entries, func(a, b *registryEntry) int {
+		return cmp.Or(a.AddedAt.Compare(b.AddedAt), strings.Compare(a.InfoHash, b.InfoHash))
+	})
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err == nil {
+		err = os.WriteFile(r.path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error: Failed to write registry: %v", err)
+	}
+}
+
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
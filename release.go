@@ -0,0 +1,125 @@
+package distroseed
+
+import (
+	"regexp"
+	"strings"
+)
+
+// releaseInfo is what a release's name says about it, like the distro and version of
+// ubuntu-24.04.1-desktop-amd64.iso
+type releaseInfo struct {
+	Distro  string `json:"distro,omitempty"`
+	Version string `json:"version,omitempty"`
+	Arch    string `json:"arch,omitempty"`    // Spelled the Debian way, so x86_64 is amd64
+	Variant string `json:"variant,omitempty"` // Like desktop, netinst or workstation-live
+}
+
+// releaseExtension matches the file extensions release names end in
+var releaseExtension = regexp.MustCompile(`(?i)(\.(iso|img|raw|qcow2|vhdx?|tar|zip|torrent|gz|xz|bz2|zst))+$`)
+
+// releaseX8664 matches the spellings of x86_64 that would otherwise be split in two
+var releaseX8664 = regexp.MustCompile(`(?i)x86[-_]64`)
+
+// releaseVersion matches a version token, like 24.04.1, 2024.10.01 or v6.1
+var releaseVersion = regexp.MustCompile(`^v?\d+(\.\d+)*[a-z]?$`)
+
+// releaseArches are the spellings of architectures in release names, and how they're reported
+var releaseArches = map[string]string{
+	"amd64": "amd64", "x86_64": "amd64", "x64": "amd64", "64bit": "amd64",
+	"i386": "i386", "i686": "i386", "x86": "i386", "32bit": "i386",
+	"arm64": "arm64", "aarch64": "arm64",
+	"armhf": "armhf", "armv7hl": "armhf", "armv7": "armhf",
+	"ppc64el": "ppc64el", "ppc64le": "ppc64el",
+	"s390x": "s390x", "riscv64": "riscv64",
+}
+
+// releaseDistros are the names distros go by in release names that aren't their usual one
+var releaseDistros = map[string]string{"archlinux": "arch", "linuxmint": "mint"}
+
+// parseReleaseName reads the distro, version, architecture and variant from the name of a
+// release's torrent or file. The distro comes first, the first number after it is the version,
+// and the words left over besides "linux" and build numbers make up the variant. Whatever
+// can't be told is left empty.
+func parseReleaseName(name string) releaseInfo {
+	name = releaseExtension.ReplaceAllString(name, "")
+	name = releaseX8664.ReplaceAllString(name, "x86_64")
+	var tokens []string
+	for _, token := range strings.FieldsFunc(strings.ToLower(name), func(r rune) bool { return r == '-' || r == ' ' || r == '+' }) {
+		if _, ok := releaseArches[token]; ok {
+			tokens = append(tokens, token)
+			continue
+		}
+		tokens = append(tokens, strings.Split(token, "_")...)
+	}
+
+	var r releaseInfo
+	var variant []string
+	for i, token := range tokens {
+		switch arch, isArch := releaseArches[token]; {
+		case token == "":
+		case isArch:
+			if r.Arch == "" {
+				r.Arch = arch
+			}
+		case releaseVersion.MatchString(token):
+			if r.Version == "" && r.Distro != "" {
+				r.Version = strings.TrimPrefix(token, "v")
+			}
+		case i == 0:
+			r.Distro = token
+			if distro, ok := releaseDistros[token]; ok {
+				r.Distro = distro
+			}
+		case token == "linux":
+		default:
+			variant = append(variant, token)
+		}
+	}
+	r.Variant = strings.Join(variant, "-")
+	return r
+}
+
+// releaseOf parses the torrent's name, with labels like distro:debian, version:12, arch:arm64
+// and variant:netinst taking precedence
+func releaseOf(name string, labels []string) releaseInfo {
+	r := parseReleaseName(name)
+	for _, label := range labels {
+		key, value, ok := strings.Cut(label, ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "distro":
+			r.Distro = value
+		case "version":
+			r.Version = value
+		case "arch":
+			r.Arch = value
+		case "variant":
+			r.Variant = value
+		}
+	}
+	return r
+}
+
+// distro returns the release's distro, empty for torrents it doesn't know
+func (r *releaseInfo) distro() string {
+	if r == nil {
+		return ""
+	}
+	return r.Distro
+}
+
+// version returns the release's version, empty for torrents it doesn't know
+func (r *releaseInfo) version() string {
+	if r == nil {
+		return ""
+	}
+	return r.Version
+}
+
+// isReleaseLabel reports whether a label sets one of the release's fields
+func isReleaseLabel(label string) bool {
+	key, _, ok := strings.Cut(label, ":")
+	return ok && (key == "distro" || key == "version" || key == "arch" || key == "variant")
+}
@@ -0,0 +1,30 @@
+package distroseed
+
+import "testing"
+
+func TestParseReleaseName(t *testing.T) {
+	for name, want := range map[string]releaseInfo{
+		"ubuntu-24.04.1-desktop-amd64.iso":          {Distro: "ubuntu", Version: "24.04.1", Arch: "amd64", Variant: "desktop"},
+		"ubuntu-24.04.1-live-server-arm64.iso":      {Distro: "ubuntu", Version: "24.04.1", Arch: "arm64", Variant: "live-server"},
+		"debian-12.7.0-amd64-netinst.iso":           {Distro: "debian", Version: "12.7.0", Arch: "amd64", Variant: "netinst"},
+		"debian-live-12.7.0-amd64-kde.iso.torrent":  {Distro: "debian", Version: "12.7.0", Arch: "amd64", Variant: "live-kde"},
+		"Fedora-Workstation-Live-x86_64-41-1.4.iso": {Distro: "fedora", Version: "41", Arch: "amd64", Variant: "workstation-live"},
+		"archlinux-2024.10.01-x86_64.iso":           {Distro: "arch", Version: "2024.10.01", Arch: "amd64"},
+		"linuxmint-22-cinnamon-64bit.iso":           {Distro: "mint", Version: "22", Arch: "amd64", Variant: "cinnamon"},
+		"kali-linux-2024.3-installer-amd64.iso":     {Distro: "kali", Version: "2024.3", Arch: "amd64", Variant: "installer"},
+		"Rocky-9.4-x86-64-dvd.iso":                  {Distro: "rocky", Version: "9.4", Arch: "amd64", Variant: "dvd"},
+		"openSUSE-Leap-15.6-DVD-x86_64-Media.iso":   {Distro: "opensuse", Version: "15.6", Arch: "amd64", Variant: "leap-dvd-media"},
+		"24.04": {},
+	} {
+		if got := parseReleaseName(name); got != want {
+			t.Errorf("parseReleaseName(%s) = %+v, want %+v", name, got, want)
+		}
+	}
+}
+
+func TestReleaseOf(t *testing.T) {
+	got := releaseOf("debian-12.7.0-amd64-netinst.iso", []string{"mirrors", "version:12", "variant:"})
+	if want := (releaseInfo{Distro: "debian", Version: "12", Arch: "amd64"}); got != want {
+		t.Errorf("release = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+// Re-read the config file and apply what changed, without touching existing peer connections.
+// With preview set, the changes are only computed.
+func (s *Seeder) reloadConfig(ctx context.Context, client *torrent.Client, base runtimeConfig, configFile, downloadDir string, preview bool) (configDiff, error) {
+	if !preview {
+		log.Println("🔁 Reloading configuration...")
+	}
+	diff, err := s.updateConfig(ctx, client, downloadDir, preview, func(runtimeConfig) (runtimeConfig, error) {
+		cfg, err := base.withConfigFile(configFile)
+		return s.manifest.withURLs(cfg), err
+	})
+	if err != nil && !preview {
+		log.Printf("⚠️ Keeping current configuration: %v", err)
+	}
+	return diff, err
+}
+
+// Replace the live configuration with the one change derives from it, returning what differs.
+// With preview set, nothing is applied.
+func (s *Seeder) updateConfig(ctx context.Context, client *torrent.Client, downloadDir string, preview bool, change func(runtimeConfig) (runtimeConfig, error)) (configDiff, error) {
+	s.configMu.Lock()
+	defer s.configMu.Unlock()
+
+	prev := s.liveSettings.Get()
+	next, err := change(prev)
+	if err != nil {
+		return configDiff{}, err
+	}
+	diff := diffConfig(prev, next)
+	if preview {
+		return diff, nil
+	}
+	diff.log()
+	s.applyRuntimeConfig(ctx, client, prev, next, downloadDir)
+	return diff, nil
+}
+
+func (s *Seeder) applyRuntimeConfig(ctx context.Context, client *torrent.Client, prev, next runtimeConfig, downloadDir string) {
+	s.applyRateLimits(next)
+
+	// In a cluster, only part of the configured torrents may be loaded
+	prevURLs, nextURLs := s.cluster.torrentURLs(prev), s.cluster.torrentURLs(next)
+	s.dropTorrents(missingURLs(prevURLs, nextURLs))
+
+	s.liveSettings.set(next)
+	// Directories of torrent files that stay configured are rescanned for changes
+	added := missingURLs(nextURLs, prevURLs)
+	s.processTorrents(ctx, client, append(added, localTorrentDirs(missingURLs(nextURLs, added))...), downloadDir)
+	s.applyTorrentUploadLimits(client)
+}
+
+// Remove the torrents added from the URLs, unless they're still added from another
+func (s *Seeder) dropTorrents(urls []string) {
+	for _, url := range urls {
+		s.removeTorrents(s.torrentSources.Remove(url))
+	}
+}
+
+func (s *Seeder) removeTorrents(ts []*torrent.Torrent) {
+	for _, t := range ts {
+		log.Printf("🗑️ Removing torrent: %s", t.Name())
+		s.pauses.forget(t.InfoHash().HexString())
+		s.torrentErrors.forget(t.InfoHash().HexString())
+		s.supervisor.forget(t.InfoHash().HexString())
+		s.events.Record(t.InfoHash().HexString(), eventRemoved, "")
+		t.Drop()
+	}
+}
+
+// Set the client's limiters from the configuration, lowered by any temporary overrides
+func (s *Seeder) applyRateLimits(cfg runtimeConfig) {
+	upload, download := s.bandwidth.Limits(cfg.UploadLimit, cfg.DownloadLimit)
+	applyRateLimit(s.uploadLimiter, upload)
+	applyRateLimit(s.downloadLimiter, download)
+	s.chokes.setPeerLimit(cfg.PeerUploadLimit)
+}
+
+// Set a limiter to the given KiB/s, 0 meaning unlimited
+func applyRateLimit(limiter *rate.Limiter, kibPerSecond int64) {
+	if kibPerSecond <= 0 {
+		limiter.SetLimit(rate.Inf)
+		return
+	}
+	limiter.SetLimit(rate.Limit(kibPerSecond * 1024))
+}
+
+func formatRateLimit(kibPerSecond int64) string {
+	if kibPerSecond <= 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d KiB/s", kibPerSecond)
+}
@@ -0,0 +1,35 @@
+package distroseed
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestApplyRuntimeConfigRemovesSources(t *testing.T) {
+	s := newTestSeeder(t, runtimeConfig{})
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	shared := addSeedingTestTorrent(t, client, dir, "shared.iso")
+	only := addSeedingTestTorrent(t, client, dir, "only.iso")
+	s.torrentSources.Add("a", shared)
+	s.torrentSources.Add("b", shared)
+	s.torrentSources.Add("c", only)
+	prev := runtimeConfig{TorrentURLs: []string{"a", "b", "c"}}
+
+	s.applyRuntimeConfig(context.Background(), client, prev, runtimeConfig{TorrentURLs: []string{"b"}, UploadLimit: 64}, dir)
+	// A torrent is only dropped once no source refers to it
+	if _, ok := client.Torrent(shared.InfoHash()); !ok {
+		t.Error("a torrent still configured from another URL was dropped")
+	}
+	if _, ok := client.Torrent(only.InfoHash()); ok {
+		t.Error("a torrent whose only source was removed is still seeded")
+	}
+	if got := s.uploadLimiter.Limit(); got != rate.Limit(64*1024) {
+		t.Errorf("upload limit = %v, want 64 KiB/s", got)
+	}
+	if got := s.liveSettings.Get().TorrentURLs; len(got) != 1 || got[0] != "b" {
+		t.Errorf("live torrents = %v, want [b]", got)
+	}
+}
@@ -0,0 +1,99 @@
+package distroseed
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// RelocateDataDir updates the registry after the data directory was moved or remounted at to,
+// from where it was before, guessed from the registry if from is empty. It spot checks sample
+// pieces of each torrent at the new location.
+func RelocateDataDir(to, from string, sample int, dataDirs []string) error {
+	newDir, err := filepath.Abs(to)
+	if err != nil {
+		return err
+	}
+	// The running seeder would write its own registry over ours
+	lock, err := lockDataDir(newDir, 0)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	reg := loadRegistry(newDir, dataDirs...)
+	keys, err := loadKeyStore(newDir)
+	if err != nil {
+		return err
+	}
+
+	oldDir := from
+	if oldDir == "" {
+		oldDir = guessPreviousDataDir(reg)
+		if oldDir != "" {
+			log.Printf("🔎 Previous data directory: %s", oldDir)
+		}
+	}
+	if oldDir != "" {
+		if oldDir, err = filepath.Abs(oldDir); err != nil {
+			return err
+		}
+		changed := reg.Relocate(oldDir, newDir)
+		log.Printf("📦 Updated %d registry entries from %s to %s", changed, oldDir, newDir)
+	} else {
+		log.Printf("✅ Registry paths are already inside %s", newDir)
+	}
+
+	failures := 0
+	for _, e := range reg.Entries() {
+		if e.TorrentFile == "" || e.DataPath == "" {
+			continue
+		}
+		mi, err := metainfo.LoadFromFile(e.TorrentFile)
+		if err != nil {
+			log.Printf("⚠️ %s: could not load torrent file: %v", e.Name, err)
+			failures++
+			continue
+		}
+		info, err := mi.UnmarshalInfo()
+		if err != nil {
+			log.Printf("⚠️ %s: invalid metadata: %v", e.Name, err)
+			failures++
+			continue
+		}
+		key, _ := keys.get(e.InfoHash)
+		checked, failed, err := verifyPieceSample(&info, filepath.Dir(e.DataPath), key, sample)
+		switch {
+		case err != nil:
+			log.Printf("⚠️ %s: %v", e.Name, err)
+			failures++
+		case failed > 0:
+			log.Printf("❌ %s: %d of %d sampled pieces don't match", e.Name, failed, checked)
+			failures++
+		default:
+			log.Printf("✅ %s: %d sampled pieces verified", e.Name, checked)
+		}
+	}
+	if failures > 0 {
+		return fmt.Errorf("❌ %d torrents failed verification at the new location", failures)
+	}
+	return nil
+}
+
+// The directory most stale payload paths point into
+func guessPreviousDataDir(reg *torrentRegistry) string {
+	counts := make(map[string]int)
+	best := ""
+	for _, e := range reg.StalePaths() {
+		if e.DataPath == "" {
+			continue
+		}
+		dir := filepath.Dir(e.DataPath)
+		counts[dir]++
+		if counts[dir] > counts[best] {
+			best = dir
+		}
+	}
+	return best
+}
@@ -0,0 +1,73 @@
+package distroseed
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelocateRegistry(t *testing.T) {
+	oldDir, newDir := t.TempDir(), t.TempDir()
+	reg := loadRegistry(oldDir)
+	reg.Record("aa", "https://example.com/a.torrent", filepath.Join(oldDir, "a.torrent"))
+	reg.SetName("aa", "a.iso", oldDir)
+	reg.Record("bb", "magnet:?xt=urn:btih:bb", "")
+	reg.SetName("bb", "b.iso", oldDir)
+
+	// The directory is moved, and the registry loaded from the new one
+	if err := os.Rename(filepath.Join(oldDir, registryFileName), filepath.Join(newDir, registryFileName)); err != nil {
+		t.Fatal(err)
+	}
+	moved := loadRegistry(newDir)
+	if got := len(moved.StalePaths()); got != 2 {
+		t.Fatalf("%d stale entries, want 2", got)
+	}
+	if got := guessPreviousDataDir(moved); got != oldDir {
+		t.Errorf("guessed the previous directory was %s, want %s", got, oldDir)
+	}
+	if changed := moved.Relocate(oldDir, newDir); changed != 2 {
+		t.Errorf("relocated %d entries, want 2", changed)
+	}
+	if stale := moved.StalePaths(); len(stale) != 0 {
+		t.Errorf("entries still stale after relocating: %+v", stale)
+	}
+
+	// And the relocation is saved
+	for _, e := range loadRegistry(newDir).Entries() {
+		if e.DataPath != filepath.Join(newDir, e.Name) {
+			t.Errorf("%s has its data at %s after reloading", e.InfoHash, e.DataPath)
+		}
+		if e.InfoHash == "aa" && e.TorrentFile != filepath.Join(newDir, "a.torrent") {
+			t.Errorf("%s has its torrent file at %s after reloading", e.InfoHash, e.TorrentFile)
+		}
+	}
+}
+
+func TestVerifyPieceSample(t *testing.T) {
+	dir := t.TempDir()
+	mi := newTestMeta(t, dir, "a.iso", 64<<10)
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked, failed, err := verifyPieceSample(&info, dir, nil, 100); err != nil || checked != info.NumPieces() || failed != 0 {
+		t.Errorf("verifyPieceSample = %d, %d, %v, want all %d pieces verified", checked, failed, err, info.NumPieces())
+	}
+
+	// Corrupting every piece is caught by any sample
+	f, err := os.OpenFile(filepath.Join(dir, "a.iso"), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range info.NumPieces() {
+		f.WriteAt([]byte{0xff, 0x00, 0xff}, int64(i)*info.PieceLength)
+	}
+	f.Close()
+	if checked, failed, err := verifyPieceSample(&info, dir, nil, 2); err != nil || checked != 2 || failed != 2 {
+		t.Errorf("verifyPieceSample of corrupted data = %d, %d, %v, want 2 of 2 failed", checked, failed, err)
+	}
+
+	if _, _, err := verifyPieceSample(&info, t.TempDir(), nil, 2); err == nil {
+		t.Error("verifying missing data didn't fail")
+	}
+}
@@ -0,0 +1,305 @@
+package distroseed
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	reportStateFileName  = "report_state.json"
+	reportSampleInterval = 1 * time.Minute // Frequency of per-peer upload sampling
+)
+
+// Report periods
+const (
+	reportDaily  = "daily"
+	reportWeekly = "weekly"
+)
+
+type reportConfig struct {
+	Period  string // reportDaily, reportWeekly, or "" for no reports
+	File    string // Reports are appended here if set
+	Webhook string // Reports are POSTed here as JSON if set
+	Email   bool   // Reports are emailed to the notification recipients if set
+}
+
+// validate checks the report settings, with n the email notifier, nil if emails aren't set up
+func (c reportConfig) validate(n *notifier) error {
+	switch c.Period {
+	case "":
+		return nil
+	case reportDaily, reportWeekly:
+	default:
+		return fmt.Errorf("❌ Unknown report period '%s', expected '%s' or '%s'", c.Period, reportDaily, reportWeekly)
+	}
+	if c.Email && n == nil {
+		return fmt.Errorf("❌ Emailing reports needs email notifications to be configured")
+	}
+	if c.File == "" && c.Webhook == "" && !c.Email {
+		return fmt.Errorf("❌ Reports need a file, webhook or email to be sent to")
+	}
+	return nil
+}
+
+// uploadReport summarizes what was uploaded during one period
+type uploadReport struct {
+	Period          string          `json:"period"`
+	Start           time.Time       `json:"start"`
+	End             time.Time       `json:"end"`
+	Uploaded        int64           `json:"uploaded_bytes"`
+	PrivateUploaded int64           `json:"private_uploaded_bytes"` // Part of it by private torrents, whose trackers keep their own ratios
+	Torrents        []torrentReport `json:"torrents"`
+}
+
+type torrentReport struct {
+	InfoHash    string  `json:"info_hash"`
+	Name        string  `json:"name"`
+	Uploaded    int64   `json:"uploaded_bytes"`
+	PeersServed int     `json:"peers_served"` // Distinct peer IPs that were sent data
+	Ratio       float64 `json:"ratio"`        // Uploaded over the torrent's size
+	Private     bool    `json:"private,omitempty"`
+}
+
+// reportState accumulates the current period, and is persisted so restarts and upgrades don't
+// lose it
+type reportState struct {
+	Period   string                    `json:"period"`
+	Start    time.Time                 `json:"start"`
+	Torrents map[string]*reportTorrent `json:"torrents"`
+}
+
+type reportTorrent struct {
+	Name     string          `json:"name"`
+	Size     int64           `json:"size"`
+	Uploaded int64           `json:"uploaded"`
+	Private  bool            `json:"private,omitempty"`
+	Peers    map[string]bool `json:"peers"`
+}
+
+// Generate upload reports for each period until the context is cancelled
+func (s *Seeder) generateReports(ctx context.Context, client *torrent.Client, cfg reportConfig, downloadDir string) {
+	if cfg.Period == "" {
+		return
+	}
+	statePath := filepath.Join(downloadDir, reportStateFileName)
+	state := loadReportState(statePath, cfg.Period)
+	// Bytes uploaded per torrent at the previous sample
+	previous := make(map[string]int64)
+
+	ticker := time.NewTicker(reportSampleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			sampleReportState(client, state, previous)
+			saveReportState(statePath, state)
+			return
+		case now := <-ticker.C:
+			sampleReportState(client, state, previous)
+			if end := reportPeriodEnd(state.Period, state.Start); !now.Before(end) {
+				s.sendReport(ctx, cfg, state.report(end))
+				// Skip periods the seeder wasn't running for rather than reporting them empty
+				state = newReportState(cfg.Period, now)
+			}
+			saveReportState(statePath, state)
+		}
+	}
+}
+
+// Start of the period containing t, in local time. Weeks start on Monday.
+func reportPeriodStart(period string, t time.Time) time.Time {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == reportWeekly {
+		daysSinceMonday := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -daysSinceMonday)
+	}
+	return start
+}
+
+func reportPeriodEnd(period string, start time.Time) time.Time {
+	if period == reportWeekly {
+		return start.AddDate(0, 0, 7)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func newReportState(period string, now time.Time) *reportState {
+	return &reportState{
+		Period:   period,
+		Start:    reportPeriodStart(period, now),
+		Torrents: make(map[string]*reportTorrent),
+	}
+}
+
+func loadReportState(path, period string) *reportState {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not read report state: %v", err)
+		}
+		return newReportState(period, time.Now())
+	}
+	var state reportState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Warning: Failed to parse report state: %v", err)
+		return newReportState(period, time.Now())
+	}
+	if state.Period != period || state.Torrents == nil {
+		return newReportState(period, time.Now())
+	}
+	return &state
+}
+
+func saveReportState(path string, state *reportState) {
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		log.Printf("Error: Failed to write report state: %v", err)
+	}
+}
+
+// Add what each torrent uploaded since the previous sample, and the peers it was uploaded to
+func sampleReportState(client *torrent.Client, state *reportState, previous map[string]int64) {
+	for _, t := range client.Torrents() {
+		if t.Info() == nil {
+			continue
+		}
+		ih := t.InfoHash().HexString()
+		stats := t.Stats()
+		uploaded := stats.BytesWrittenData.Int64()
+
+		rt, ok := state.Torrents[ih]
+		if !ok {
+			rt = &reportTorrent{Peers: make(map[string]bool)}
+			state.Torrents[ih] = rt
+		}
+		rt.Name = t.Name()
+		rt.Size = t.Length()
+		rt.Private = isPrivate(t)
+		rt.Uploaded += uploaded - previous[ih]
+		previous[ih] = uploaded
+
+		for _, pc := range t.PeerConns() {
+			peerStats := pc.Stats()
+			if peerStats.BytesWrittenData.Int64() == 0 {
+				continue
+			}
+			host, _, err := net.SplitHostPort(pc.RemoteAddr.String())
+			if err != nil {
+				host = pc.RemoteAddr.String()
+			}
+			rt.Peers[host] = true
+		}
+	}
+}
+
+func (s *reportState) report(end time.Time) uploadReport {
+	r := uploadReport{Period: s.Period, Start: s.Start, End: end, Torrents: []torrentReport{}}
+	for ih, rt := range s.Torrents {
+		tr := torrentReport{InfoHash: ih, Name: rt.Name, Uploaded: rt.Uploaded, PeersServed: len(rt.Peers), Private: rt.Private}
+		if rt.Size > 0 {
+			tr.Ratio = float64(rt.Uploaded) / float64(rt.Size)
+		}
+		r.Uploaded += rt.Uploaded
+		if rt.Private {
+			r.PrivateUploaded += rt.Uploaded
+		}
+		r.Torrents = append(r.Torrents, tr)
+	}
+	slices.SortFunc(r.Torrents, func(a, b torrentReport) int {
+		return cmp.Compare(b.Uploaded, a.Uploaded)
+	})
+	return r
+}
+
+func (r uploadReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Distro Seed %s report: %s to %s\n", r.Period, r.Start.Format(time.DateTime), r.End.Format(time.DateTime))
+	fmt.Fprintf(&b, "Total uploaded: %.2f MB\n", float64(r.Uploaded)/1024/1024)
+	// Private torrents are listed on their own, as their trackers keep their own ratios
+	hasPrivate := slices.ContainsFunc(r.Torrents, func(t torrentReport) bool { return t.Private })
+	for _, private := range []bool{false, true} {
+		if private && hasPrivate {
+			fmt.Fprintf(&b, "Private torrents: %.2f MB\n", float64(r.PrivateUploaded)/1024/1024)
+		}
+		for _, t := range r.Torrents {
+			if t.Private == private {
+				fmt.Fprintf(&b, "  %s - Uploaded: %.2f MB - Peers served: %d - Ratio: %.2f\n",
+					t.Name, float64(t.Uploaded)/1024/1024, t.PeersServed, t.Ratio)
+			}
+		}
+	}
+	return b.String()
+}
+
+func (s *Seeder) sendReport(ctx context.Context, cfg reportConfig, r uploadReport) {
+	log.Printf("📑 %s upload report: %.2f MB across %d torrents", r.Period, float64(r.Uploaded)/1024/1024, len(r.Torrents))
+
+	if cfg.File != "" {
+		if err := appendReport(cfg.File, r); err != nil {
+			log.Printf("Error: Failed to write report to %s: %v", cfg.File, err)
+		}
+	}
+	if cfg.Webhook != "" {
+		if err := postReport(ctx, cfg.Webhook, r); err != nil {
+			log.Printf("⚠️ Error sending report to webhook: %v", err)
+		}
+	}
+	if cfg.Email {
+		subject := fmt.Sprintf("%s upload report for %s", r.Period, r.Start.Format(time.DateOnly))
+		if err := s.notifications.send(subject, r.String()); err != nil {
+			log.Printf("⚠️ Error emailing report: %v", err)
+		}
+	}
+}
+
+func appendReport(path string, r uploadReport) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintln(file, r.String())
+	return err
+}
+
+// postReport sends the report to the webhook, giving up after webhookTimeout so a stalled
+// webhook can't hold up shutdown
+func postReport(ctx context.Context, webhook string, r uploadReport) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPeriods(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	tests := []struct {
+		period     string
+		at         time.Time
+		start, end time.Time
+	}{
+		{reportDaily, time.Date(2024, 5, 8, 13, 30, 0, 0, loc), time.Date(2024, 5, 8, 0, 0, 0, 0, loc), time.Date(2024, 5, 9, 0, 0, 0, 0, loc)},
+		{reportDaily, time.Date(2024, 12, 31, 23, 59, 0, 0, loc), time.Date(2024, 12, 31, 0, 0, 0, 0, loc), time.Date(2025, 1, 1, 0, 0, 0, 0, loc)},
+		// Weeks start on Monday, 2024-05-06
+		{reportWeekly, time.Date(2024, 5, 8, 13, 30, 0, 0, loc), time.Date(2024, 5, 6, 0, 0, 0, 0, loc), time.Date(2024, 5, 13, 0, 0, 0, 0, loc)},
+		{reportWeekly, time.Date(2024, 5, 12, 23, 0, 0, 0, loc), time.Date(2024, 5, 6, 0, 0, 0, 0, loc), time.Date(2024, 5, 13, 0, 0, 0, 0, loc)},
+		{reportWeekly, time.Date(2024, 5, 6, 0, 0, 0, 0, loc), time.Date(2024, 5, 6, 0, 0, 0, 0, loc), time.Date(2024, 5, 13, 0, 0, 0, 0, loc)},
+	}
+	for _, tt := range tests {
+		start := reportPeriodStart(tt.period, tt.at)
+		if !start.Equal(tt.start) {
+			t.Errorf("%s period of %s starts %s, want %s", tt.period, tt.at, start, tt.start)
+		}
+		if end := reportPeriodEnd(tt.period, start); !end.Equal(tt.end) {
+			t.Errorf("%s period of %s ends %s, want %s", tt.period, tt.at, end, tt.end)
+		}
+	}
+}
+
+func TestReportSummarizesTorrents(t *testing.T) {
+	start := time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC)
+	state := &reportState{Period: reportWeekly, Start: start, Torrents: map[string]*reportTorrent{
+		"aa": {Name: "a.iso", Size: 100, Uploaded: 50, Peers: map[string]bool{"192.0.2.1": true}},
+		"bb": {Name: "b.iso", Size: 100, Uploaded: 250, Peers: map[string]bool{"192.0.2.1": true, "192.0.2.2": true}},
+		"cc": {Name: "c.iso", Size: 100, Uploaded: 100, Private: true, Peers: map[string]bool{"192.0.2.3": true}},
+	}}
+	got := state.report(start.AddDate(0, 0, 7))
+	want := uploadReport{Period: reportWeekly, Start: start, End: start.AddDate(0, 0, 7), Uploaded: 400, PrivateUploaded: 100, Torrents: []torrentReport{
+		{InfoHash: "bb", Name: "b.iso", Uploaded: 250, PeersServed: 2, Ratio: 2.5},
+		{InfoHash: "cc", Name: "c.iso", Uploaded: 100, PeersServed: 1, Ratio: 1, Private: true},
+		{InfoHash: "aa", Name: "a.iso", Uploaded: 50, PeersServed: 1, Ratio: 0.5},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("report = %+v, want %+v", got, want)
+	}
+	// Private torrents are listed after the public ones
+	if s := got.String(); !strings.Contains(s, "a.iso - Uploaded: 0.00 MB - Peers served: 1 - Ratio: 0.50\nPrivate torrents: 0.00 MB\n  c.iso") {
+		t.Errorf("report text:\n%s", s)
+	}
+}
+
+func TestPostReport(t *testing.T) {
+	var received uploadReport
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+	r := uploadReport{Period: reportDaily, Uploaded: 42, Torrents: []torrentReport{{InfoHash: "aa", Uploaded: 42}}}
+	if err := postReport(context.Background(), server.URL, r); err != nil {
+		t.Fatal(err)
+	}
+	if received.Uploaded != 42 || len(received.Torrents) != 1 {
+		t.Errorf("webhook received %+v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := postReport(context.Background(), failing.URL, r); err == nil {
+		t.Error("posting to a failing webhook didn't fail")
+	}
+
+	// A stalled webhook is given up on when the seeder stops
+	release := make(chan struct{})
+	stalled := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer stalled.Close()
+	defer close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := postReport(ctx, stalled.URL, r); err == nil {
+		t.Error("posting to a stalled webhook didn't fail")
+	}
+}
@@ -0,0 +1,85 @@
+package distroseed
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	fdReserve       = 256       // Descriptors kept back for torrent files, listeners, DHT and the API
+	connMemory      = 512 << 10 // Rough memory per peer connection, with its buffers and request data
+	connMemoryShare = 2         // Peer connections may use 1/connMemoryShare of the available memory
+	lowMemory       = 256 << 20 // Below this much available memory, warn at startup
+)
+
+// availableMemory returns the memory that can be used without swapping, from /proc/meminfo
+func availableMemory() (uint64, error) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rest, ok := strings.CutPrefix(scanner.Text(), "MemAvailable:"); ok {
+			kib, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("parsing MemAvailable: %w", err)
+			}
+			return kib * 1024, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("no MemAvailable in /proc/meminfo")
+}
+
+// tuneConnections caps peer connections at what the open file limit and available memory allow
+// when no cap is set, or warns when the cap set is beyond them
+func tuneConnections(cfg *runtimeConfig) {
+	limit, reason := 0, ""
+	if files, err := openFileLimit(); err == nil {
+		if files <= fdReserve {
+			log.Printf("⚠️ Open file limit of %d leaves little room for peer connections, raise it with ulimit -n or LimitNOFILE", files)
+		}
+		limit = int(min(files-min(files, fdReserve), math.MaxInt32))
+		reason = fmt.Sprintf("open file limit of %d", files)
+	}
+	if mem, err := availableMemory(); err == nil {
+		if mem < lowMemory {
+			log.Printf("⚠️ Only %.0f MB of memory available", float64(mem)/1024/1024)
+		}
+		if byMemory := int(mem / connMemoryShare / connMemory); reason == "" || byMemory < limit {
+			limit = byMemory
+			reason = fmt.Sprintf("%.0f MB of available memory", float64(mem)/1024/1024)
+		}
+	}
+	if reason == "" {
+		return
+	}
+	limit = max(limit, 1)
+
+	switch {
+	case cfg.MaxConns == 0:
+		cfg.MaxConns = limit
+		log.Printf("🧰 Capping peer connections at %d for the %s", limit, reason)
+	case cfg.MaxConns > limit:
+		log.Printf("⚠️ Max connections %d is more than the %s allows (%d)", cfg.MaxConns, reason, limit)
+	}
+}
+
+// resourceUsage returns the open file descriptors and the limit, either 0 if unknown
+func resourceUsage() (open int, limit uint64) {
+	open, err := openFileCount()
+	if err != nil {
+		return 0, 0
+	}
+	limit, _ = openFileLimit()
+	return open, limit
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package distroseed
+
+import "errors"
+
+// Open file limits aren't supported on this platform.
+func openFileLimit() (uint64, error) {
+	return 0, errors.New("open file limits are not supported on this platform")
+}
+
+func openFileCount() (int, error) {
+	return 0, errors.New("open file counts are not supported on this platform")
+}
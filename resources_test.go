@@ -0,0 +1,44 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestTuneConnections(t *testing.T) {
+	files, err := openFileLimit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := testConfig()
+	tuneConnections(&cfg)
+	if cfg.MaxConns < 1 || uint64(cfg.MaxConns) > max(files-min(files, fdReserve), 1) {
+		t.Errorf("with an open file limit of %d, connections were capped at %d", files, cfg.MaxConns)
+	}
+
+	// A cap that's set is only warned about
+	cfg.MaxConns = math.MaxInt32
+	tuneConnections(&cfg)
+	if cfg.MaxConns != math.MaxInt32 {
+		t.Errorf("the configured cap was changed to %d", cfg.MaxConns)
+	}
+}
+
+func TestOpenFileCount(t *testing.T) {
+	before, err := openFileCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if after, err := openFileCount(); err != nil || after != before+1 {
+		t.Errorf("after opening a file, openFileCount = %d, %v, want %d", after, err, before+1)
+	}
+}
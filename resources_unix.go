@@ -0,0 +1,29 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openFileLimit returns the soft limit on open file descriptors
+func openFileLimit() (uint64, error) {
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, err
+	}
+	return uint64(rl.Cur), nil
+}
+
+// openFileCount returns the file descriptors we have open
+func openFileCount() (int, error) {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries) - 1, nil // Less the one reading the directory
+		}
+	}
+	return 0, errors.New("no file descriptor directory to count")
+}
@@ -0,0 +1,271 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const scheduleCheckInterval = 30 * time.Second
+
+// pauseWindow is a time of day seeding is paused, on some or all days of the week. Windows that
+// end before they start run past midnight into the next day.
+type pauseWindow struct {
+	days       [7]bool       // By time.Weekday, all false for every day
+	start, end time.Duration // Since midnight
+}
+
+var weekdayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// parsePauseWindows reads windows like "mon-fri 09:00-10:30, sat 22:00-02:00, 13:00-13:30"
+func parsePauseWindows(s string) ([]pauseWindow, error) {
+	var windows []pauseWindow
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		var w pauseWindow
+		times := spec
+		if days, rest, ok := strings.Cut(spec, " "); ok {
+			times = strings.TrimSpace(rest)
+			from, to, isRange := strings.Cut(strings.ToLower(days), "-")
+			first, last := weekdayIndex(from), weekdayIndex(to)
+			if !isRange {
+				last = first
+			}
+			if first < 0 || last < 0 {
+				return nil, fmt.Errorf("❌ Invalid days '%s' in pause window '%s', use e.g. mon-fri or sat", days, spec)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+		start, end, ok := strings.Cut(times, "-")
+		var err error
+		if !ok {
+			return nil, fmt.Errorf("❌ Invalid pause window '%s', use e.g. 09:00-10:30", spec)
+		}
+		if w.start, err = parseTimeOfDay(start); err != nil {
+			return nil, fmt.Errorf("❌ Invalid start in pause window '%s': %w", spec, err)
+		}
+		if w.end, err = parseTimeOfDay(end); err != nil {
+			return nil, fmt.Errorf("❌ Invalid end in pause window '%s': %w", spec, err)
+		}
+		if w.start == w.end {
+			return nil, fmt.Errorf("❌ Pause window '%s' is empty", spec)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func weekdayIndex(name string) int {
+	for i, n := range weekdayNames {
+		if name == n {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("'%s' isn't a time like 09:30", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func (w pauseWindow) onDay(day time.Weekday) bool {
+	return w.days == [7]bool{} || w.days[day]
+}
+
+// active reports whether now falls in the window, and when the window ends if it does
+func (w pauseWindow) active(now time.Time) (bool, time.Time) {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	sinceMidnight := now.Sub(midnight)
+	if w.start < w.end {
+		if w.onDay(now.Weekday()) && sinceMidnight >= w.start && sinceMidnight < w.end {
+			return true, midnight.Add(w.end)
+		}
+		return false, time.Time{}
+	}
+	// Started today and runs into tomorrow, or started yesterday
+	if w.onDay(now.Weekday()) && sinceMidnight >= w.start {
+		return true, midnight.AddDate(0, 0, 1).Add(w.end)
+	}
+	if w.onDay(midnight.AddDate(0, 0, -1).Weekday()) && sinceMidnight < w.end {
+		return true, midnight.Add(w.end)
+	}
+	return false, time.Time{}
+}
+
+// seedingSchedule pauses seeding entirely during its windows: torrents stop announcing, their
+// peer connections are closed and no data is transferred. Everything is restored when the
+// window ends.
+type seedingSchedule struct {
+	seeder  *Seeder
+	windows []pauseWindow
+
+	mu       sync.Mutex
+	until    time.Time             // End of the current pause, zero while seeding
+	trackers map[string][][]string // Announce lists of paused torrents, by infohash
+}
+
+func newSeedingSchedule(s *Seeder, windows []pauseWindow) *seedingSchedule {
+	return &seedingSchedule{seeder: s, windows: windows, trackers: make(map[string][][]string)}
+}
+
+// pausedUntil returns when the pause now falls in ends, the latest end of overlapping windows
+func (s *seedingSchedule) pausedUntil(now time.Time) (until time.Time) {
+	for _, w := range s.windows {
+		if ok, end := w.active(now); ok && end.After(until) {
+			until = end
+		}
+	}
+	return until
+}
+
+func (s *seedingSchedule) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(scheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		s.check(client, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *seedingSchedule) check(client *torrent.Client, now time.Time) {
+	until := s.pausedUntil(now)
+	s.mu.Lock()
+	wasPaused := !s.until.IsZero()
+	s.until = until
+	s.mu.Unlock()
+
+	switch {
+	case !until.IsZero():
+		if !wasPaused {
+			log.Printf("🌙 Pausing seeding until %s, as scheduled", until.Format(time.DateTime))
+		}
+		// Also catches torrents added since the pause started
+		for _, t := range client.Torrents() {
+			s.pause(t)
+		}
+	case wasPaused:
+		log.Printf("☀️ Scheduled pause over, resuming seeding")
+		for _, t := range client.Torrents() {
+			s.resume(t)
+		}
+	}
+}
+
+func (s *seedingSchedule) pause(t *torrent.Torrent) {
+	ih := t.InfoHash().HexString()
+	s.mu.Lock()
+	_, paused := s.trackers[ih]
+	if !paused {
+		mi := t.Metainfo()
+		s.trackers[ih] = mi.UpvertedAnnounceList()
+	}
+	s.mu.Unlock()
+	if paused {
+		return
+	}
+	t.ModifyTrackers(nil)
+	t.DisallowDataUpload()
+	t.DisallowDataDownload()
+	t.SetMaxEstablishedConns(0)
+}
+
+// resume undoes pause, leaving what other parts of the seeder hold back held back. Connection
+// limits are handed back to the slot manager.
+func (s *seedingSchedule) resume(t *torrent.Torrent) {
+	ih := t.InfoHash().HexString()
+	s.mu.Lock()
+	trackers, paused := s.trackers[ih]
+	delete(s.trackers, ih)
+	s.mu.Unlock()
+	if !paused {
+		return
+	}
+	if len(trackers) > 0 && !s.seeder.uploadOnly.Held(ih) && !s.seeder.pauses.hold(ih, trackers) {
+		t.ModifyTrackers(trackers)
+	}
+	switch s.seeder.queue.kind(ih) {
+	case queuedSeed:
+		t.AllowDataDownload()
+		t.SetMaxEstablishedConns(idleConnsPerTorrent)
+	case queuedDownload:
+		t.AllowDataUpload()
+		t.SetMaxEstablishedConns(s.seeder.liveSettings.Get().ConnsPerTorrent)
+	default:
+		t.AllowDataUpload()
+		if !s.seeder.diskPauses.IsPaused(ih) {
+			t.AllowDataDownload()
+		}
+		t.SetMaxEstablishedConns(s.seeder.liveSettings.Get().ConnsPerTorrent)
+	}
+	s.seeder.uploadOnly.restrict(t)
+	s.seeder.quota.restrict(t)
+	s.seeder.pauses.restrict(t)
+}
+
+// hold takes over the announce list of a torrent another pause is done with, while seeding is
+// paused, so it's restored when the pause ends. It reports whether it did.
+func (s *seedingSchedule) hold(infoHash string, trackers [][]string) bool {
+	if !s.Paused() {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trackers[infoHash] = trackers
+	return true
+}
+
+// addTrackers adds trackers to the announce list a torrent gets back when the pause ends,
+// reporting whether it's paused
+func (s *seedingSchedule) addTrackers(infoHash string, tiers [][]string) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	trackers, ok := s.trackers[infoHash]
+	if ok {
+		s.trackers[infoHash] = appendTrackers(trackers, tiers)
+	}
+	return ok
+}
+
+// Paused reports whether seeding is paused by the schedule
+func (s *seedingSchedule) Paused() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.until.IsZero()
+}
+
+// restrict keeps a torrent from transferring data or connecting while seeding is paused, after
+// they were allowed for other reasons
+func (s *seedingSchedule) restrict(t *torrent.Torrent) {
+	if s.Paused() {
+		t.DisallowDataUpload()
+		t.DisallowDataDownload()
+		t.SetMaxEstablishedConns(0)
+	}
+}
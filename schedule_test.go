@@ -0,0 +1,102 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePauseWindows(t *testing.T) {
+	windows, err := parsePauseWindows("mon-fri 09:00-10:30, Sat 22:00-02:00, 13:00-13:30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows) != 3 {
+		t.Fatalf("got %d windows, want 3", len(windows))
+	}
+	if want := [7]bool{false, true, true, true, true, true, false}; windows[0].days != want {
+		t.Errorf("mon-fri covers %v", windows[0].days)
+	}
+	if windows[0].start != 9*time.Hour || windows[0].end != 10*time.Hour+30*time.Minute {
+		t.Errorf("09:00-10:30 parsed as %s-%s", windows[0].start, windows[0].end)
+	}
+	if want := [7]bool{6: true}; windows[1].days != want {
+		t.Errorf("sat covers %v", windows[1].days)
+	}
+	if windows[2].days != [7]bool{} {
+		t.Errorf("a window without days covers %v", windows[2].days)
+	}
+
+	// Day ranges can wrap around the week
+	windows, _ = parsePauseWindows("fri-mon 01:00-02:00")
+	if want := [7]bool{true, true, false, false, false, true, true}; windows[0].days != want {
+		t.Errorf("fri-mon covers %v", windows[0].days)
+	}
+
+	for _, bad := range []string{"09:00", "someday 09:00-10:00", "9am-10am", "25:00-26:00", "10:00-10:00"} {
+		if _, err := parsePauseWindows(bad); err == nil {
+			t.Errorf("%q is valid", bad)
+		}
+	}
+}
+
+func TestPauseWindowActive(t *testing.T) {
+	windows, _ := parsePauseWindows("fri 22:00-02:00")
+	w := windows[0]
+	// 2025-03-07 is a Friday
+	friday := func(day, hour, minute int) time.Time {
+		return time.Date(2025, 3, 7+day, hour, minute, 0, 0, time.Local)
+	}
+	for _, tc := range []struct {
+		now    time.Time
+		active bool
+		end    time.Time
+	}{
+		{friday(0, 21, 59), false, time.Time{}},
+		{friday(0, 22, 0), true, friday(1, 2, 0)},
+		{friday(1, 1, 30), true, friday(1, 2, 0)},
+		{friday(1, 2, 0), false, time.Time{}},
+		{friday(1, 22, 30), false, time.Time{}}, // Saturday evening
+		{friday(0, 1, 0), false, time.Time{}},   // Friday morning, after a Thursday without a pause
+	} {
+		active, end := w.active(tc.now)
+		if active != tc.active || !end.Equal(tc.end) {
+			t.Errorf("at %s: active %v until %s, want %v until %s", tc.now, active, end, tc.active, tc.end)
+		}
+	}
+}
+
+func TestSeedingSchedulePausesAndResumes(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	setTestSeederState(s, dir)
+	resetTestState(s, testConfig())
+	client := newTestClient(t, dir)
+	tt := addSeedingTestTorrent(t, client, dir, "a.iso")
+
+	windows, _ := parsePauseWindows("09:00-10:00, 09:30-11:00")
+	sched := newSeedingSchedule(s, windows)
+	day := time.Date(2025, 3, 7, 0, 0, 0, 0, time.Local)
+	sched.check(client, day.Add(8*time.Hour))
+	if sched.Paused() {
+		t.Fatal("paused before the window")
+	}
+
+	sched.check(client, day.Add(9*time.Hour))
+	if !sched.Paused() {
+		t.Fatal("not paused in the window")
+	}
+	if until := sched.pausedUntil(day.Add(9*time.Hour + 45*time.Minute)); !until.Equal(day.Add(11 * time.Hour)) {
+		t.Errorf("overlapping windows pause until %s, want 11:00", until.Format(time.TimeOnly))
+	}
+	if _, ok := sched.trackers[tt.InfoHash().HexString()]; !ok {
+		t.Error("torrent wasn't paused")
+	}
+
+	sched.check(client, day.Add(11*time.Hour))
+	if sched.Paused() {
+		t.Error("still paused after the windows")
+	}
+	if len(sched.trackers) != 0 {
+		t.Errorf("%d torrents still paused", len(sched.trackers))
+	}
+}
@@ -0,0 +1,114 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"log"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/announce"
+)
+
+const (
+	defaultScrapeInterval = 30 * time.Minute
+	scrapeBatchSize       = 50 // Infohashes per request, UDP trackers take about 74
+)
+
+// swarmTotals is what a tracker knows of a torrent's whole swarm, rather than the peers we're
+// connected to. With several trackers, the one reporting the most peers is kept, since their
+// swarms overlap and can't be added up.
+type swarmTotals struct {
+	Seeders   int       `json:"seeders"`
+	Leechers  int       `json:"leechers"`
+	Completed int       `json:"completed"` // Downloads the tracker saw finish
+	Tracker   string    `json:"tracker"`
+	ScrapedAt time.Time `json:"scraped_at"`
+}
+
+// trackerScrapes periodically scrapes each tracker for the totals of its torrents
+type trackerScrapes struct {
+	seeder   *Seeder
+	interval time.Duration
+
+	mu     sync.Mutex
+	totals map[string]swarmTotals // By infohash
+}
+
+func newTrackerScrapes(s *Seeder, interval time.Duration) *trackerScrapes {
+	return &trackerScrapes{seeder: s, interval: interval, totals: make(map[string]swarmTotals)}
+}
+
+// Totals returns the last scraped totals of the torrent
+func (s *trackerScrapes) Totals(infoHash string) (swarmTotals, bool) {
+	if s == nil {
+		return swarmTotals{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	totals, ok := s.totals[infoHash]
+	return totals, ok
+}
+
+// run scrapes the trackers of the client's torrents every interval until the context is
+// cancelled, starting once torrents have had time to be added
+func (s *trackerScrapes) run(ctx context.Context, client *torrent.Client) {
+	timer := time.NewTimer(time.Minute)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+			s.scrapeAll(ctx, client)
+			timer.Reset(s.interval)
+		}
+	}
+}
+
+// scrapeAll scrapes every tracker once for all of its torrents, and forgets removed torrents
+func (s *trackerScrapes) scrapeAll(ctx context.Context, client *torrent.Client) {
+	byTracker := make(map[string][]metainfo.Hash)
+	current := make(map[string]bool)
+	for _, t := range client.Torrents() {
+		current[t.InfoHash().HexString()] = true
+		mi := t.Metainfo()
+		for _, trackerURL := range mi.UpvertedAnnounceList().DistinctValues() {
+			byTracker[trackerURL] = append(byTracker[trackerURL], t.InfoHash())
+		}
+	}
+
+	now := time.Now()
+	scraped := make(map[string]swarmTotals)
+	for _, trackerURL := range slices.Sorted(maps.Keys(byTracker)) {
+		for batch := range slices.Chunk(byTracker[trackerURL], scrapeBatchSize) {
+			results, err := announce.Scrape(ctx, s.seeder.scrapeHTTPClient, buildInfo.clientName(), trackerURL, batch)
+			if errors.Is(err, announce.ErrNoScrape) {
+				break
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("⚠️ Could not scrape %s: %v", trackerURL, err)
+				break
+			}
+			for i, r := range results {
+				ih := batch[i].HexString()
+				totals := swarmTotals{Seeders: int(r.Seeders), Leechers: int(r.Leechers), Completed: int(r.Completed), Tracker: trackerURL, ScrapedAt: now}
+				if prev, ok := scraped[ih]; !ok || totals.Seeders+totals.Leechers > prev.Seeders+prev.Leechers {
+					scraped[ih] = totals
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	maps.Copy(s.totals, scraped)
+	maps.DeleteFunc(s.totals, func(ih string, _ swarmTotals) bool { return !current[ih] })
+}
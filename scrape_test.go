@@ -0,0 +1,94 @@
+package distroseed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/tracker/udp"
+)
+
+func TestScrapeTrackers(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	var (
+		mu    sync.Mutex
+		paths []string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path+"?"+r.URL.Query().Get("passkey"))
+		mu.Unlock()
+		if r.URL.Path != "/scrape.php" && r.URL.Path != "/other/scrape" {
+			http.NotFound(w, r)
+			return
+		}
+		files := make(map[string]udp.ScrapeInfohashResult)
+		for _, ih := range r.URL.Query()["info_hash"] {
+			if r.URL.Path == "/scrape.php" {
+				files[ih] = udp.ScrapeInfohashResult{Seeders: 12, Leechers: 3, Completed: 40}
+			} else {
+				files[ih] = udp.ScrapeInfohashResult{Seeders: 2, Leechers: 1}
+			}
+		}
+		bencode.NewEncoder(w).Encode(map[string]any{"files": files})
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	mi := newTestMeta(t, dir, "a.iso", 32<<10)
+	mi.AnnounceList = metainfo.AnnounceList{
+		{srv.URL + "/announce.php?passkey=secret"},
+		{srv.URL + "/other/announce"},
+		{srv.URL + "/no-scrape"},
+	}
+	tt, err := client.AddTorrent(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scraper := newTrackerScrapes(s, defaultScrapeInterval)
+	scraper.scrapeAll(context.Background(), client)
+
+	totals, ok := scraper.Totals(tt.InfoHash().HexString())
+	if !ok || totals.Seeders != 12 || totals.Leechers != 3 || totals.Completed != 40 || totals.Tracker != srv.URL+"/announce.php?passkey=secret" {
+		t.Errorf("totals = %+v, %v, want those of the tracker with the most peers", totals, ok)
+	}
+	mu.Lock()
+	for _, want := range []string{"/scrape.php?secret", "/other/scrape?"} {
+		if !slices.Contains(paths, want) {
+			t.Errorf("scrape requests %v, want %s", paths, want)
+		}
+	}
+	mu.Unlock()
+
+	// Removed torrents are forgotten
+	tt.Drop()
+	scraper.scrapeAll(context.Background(), client)
+	if _, ok := scraper.Totals(tt.InfoHash().HexString()); ok {
+		t.Error("a removed torrent's totals were kept")
+	}
+}
+
+func TestStatusTableSwarm(t *testing.T) {
+	s := seederStatus{Torrents: []torrentStatus{
+		{Name: "debian.iso", State: "seeding", Peers: 12, Swarm: &swarmTotals{Seeders: 120, Leechers: 8}},
+		{Name: "a.iso", State: "seeding", Peers: 3},
+	}}
+	lines := statusWriter{format: statusFormatTable}.table(s)
+	want := []string{
+		"NAME        STATE    PEERS  SWARM     UP   DOWN  UPLOADED  PROGRESS",
+		"debian.iso  seeding     12  120/8  0 B/s  0 B/s       0 B  -",
+		"a.iso       seeding      3      -  0 B/s  0 B/s       0 B  -",
+	}
+	for i := range want {
+		if i >= len(lines) || lines[i] != want[i] {
+			t.Errorf("table:\n%s", lines)
+			break
+		}
+	}
+}
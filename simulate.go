@@ -0,0 +1,332 @@
+package distroseed
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Swarm trace event types
+const (
+	traceArrive  = "arrive"  // A peer connects
+	traceRequest = "request" // A peer's rates change
+	traceLeave   = "leave"   // A peer disconnects
+)
+
+const (
+	simulationTick     = time.Second    // How often uploads are handed out in a simulation
+	maxSimulationDrain = 24 * time.Hour // How long peers that want more are simulated after the trace ends
+)
+
+// traceEvent is a line of a swarm trace, recorded with -record-trace or written by hand
+type traceEvent struct {
+	At      duration `json:"at"`                // Since the trace started
+	Torrent string   `json:"torrent,omitempty"` // Infohash, in traces of several swarms
+	Peer    string   `json:"peer"`
+	Type    string   `json:"type"`
+	Rate    int64    `json:"rate,omitempty"`  // Bytes/s the peer downloads from us at most, 0 for as fast as it's given
+	Gives   int64    `json:"gives,omitempty"` // Bytes/s the peer uploads to us
+	Wants   int64    `json:"wants,omitempty"` // Bytes the peer downloads before it's done, 0 until it leaves
+}
+
+// traceRecorder writes the peers of every swarm to a trace as the choke rounds see them. A peer's
+// rate is the fastest it has been uploaded to, since choked peers can't show how fast they'd go.
+// Only used from chokePolicy.round, under its lock.
+type traceRecorder struct {
+	w     io.Writer
+	start time.Time
+	peers map[string]map[string]traceEvent // Last recorded event, by infohash and peer address
+}
+
+func newTraceRecorder(w io.Writer, start time.Time) *traceRecorder {
+	return &traceRecorder{w: w, start: start, peers: make(map[string]map[string]traceEvent)}
+}
+
+// round records the peers that arrived, left or got faster since the torrent's last round
+func (r *traceRecorder) round(ih string, peers []strategyPeer, now time.Time) {
+	if r == nil {
+		return
+	}
+	at := duration(now.Sub(r.start).Round(time.Second))
+	seconds := int64(chokeRoundInterval / time.Second)
+	prev, next := r.peers[ih], make(map[string]traceEvent, len(peers))
+	var events []traceEvent
+	for _, p := range peers {
+		e := traceEvent{At: at, Torrent: ih, Peer: p.addr, Type: traceArrive, Rate: p.uploadRate / seconds, Gives: p.downloadRate / seconds}
+		if last, ok := prev[p.addr]; ok {
+			e.Type, e.Rate = traceRequest, max(e.Rate, last.Rate)
+			if e.Rate == last.Rate && e.Gives == last.Gives {
+				next[p.addr] = last
+				continue
+			}
+		}
+		next[p.addr] = e
+		events = append(events, e)
+	}
+	for _, addr := range slices.Sorted(maps.Keys(prev)) {
+		if _, ok := next[addr]; !ok {
+			events = append(events, traceEvent{At: at, Torrent: ih, Peer: addr, Type: traceLeave})
+		}
+	}
+	r.peers[ih] = next
+	enc := json.NewEncoder(r.w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			log.Printf("⚠️ Failed to record swarm trace: %v", err)
+			return
+		}
+	}
+}
+
+// readTrace reads a swarm trace, one JSON event per line, in the order of their times
+func readTrace(r io.Reader) ([]traceEvent, error) {
+	var events []traceEvent
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var e traceEvent
+		if err := json.Unmarshal([]byte(text), &e); err != nil {
+			return nil, fmt.Errorf("❌ Invalid trace line %d: %w", line, err)
+		}
+		if e.Type != traceArrive && e.Type != traceRequest && e.Type != traceLeave {
+			return nil, fmt.Errorf("❌ Invalid trace line %d: unknown event type %q", line, e.Type)
+		}
+		if e.Peer == "" {
+			return nil, fmt.Errorf("❌ Invalid trace line %d: no peer", line)
+		}
+		events = append(events, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("❌ Failed to read trace: %w", err)
+	}
+	slices.SortStableFunc(events, func(a, b traceEvent) int { return cmp.Compare(a.At, b.At) })
+	return events, nil
+}
+
+// simulation replays a swarm trace against an upload strategy, with the choke rounds of the
+// seeder and an upload shared among the unchoked peers, but no networking
+type simulation struct {
+	strategy           string
+	maxUnchoked        int
+	uploadRate         int64 // Bytes/s
+	optimisticInterval time.Duration
+	seed               uint64 // Seeds the shuffles and optimistic unchokes, so runs can be repeated
+}
+
+// SimulationResult is how the peers of a replayed trace fared under a strategy
+type SimulationResult struct {
+	Strategy   string   `json:"strategy"`
+	Uploaded   int64    `json:"uploaded_bytes"`
+	Peers      int      `json:"peers"`
+	Served     int      `json:"served"`      // Peers uploaded to at all
+	Finished   int      `json:"finished"`    // Peers that got all they wanted
+	MeanFinish duration `json:"mean_finish"` // From arriving to getting all they wanted
+	MaxWait    duration `json:"max_wait"`    // Longest a peer waited for its first byte, or until it left
+}
+
+func (r SimulationResult) String() string {
+	s := fmt.Sprintf("%s: %s uploaded, %d of %d peers served", r.Strategy, formatBytes(r.Uploaded), r.Served, r.Peers)
+	if r.Finished > 0 {
+		s += fmt.Sprintf(", %d finished in %s on average", r.Finished, time.Duration(r.MeanFinish))
+	}
+	return s + fmt.Sprintf(", longest wait %s", time.Duration(r.MaxWait))
+}
+
+// simPeer is a peer of a simulated swarm
+type simPeer struct {
+	traceEvent
+	arrived   time.Duration
+	gone      bool          // Whether it left or finished
+	left      time.Duration // When it left or finished
+	firstByte time.Duration // When it was first uploaded to, 0 until then
+	uploaded  int64
+	lastRound int64 // Bytes uploaded by the last round
+	unchoked  bool
+}
+
+// done reports whether the peer is gone or has all it wants
+func (p *simPeer) done() bool {
+	return p.gone || p.Wants > 0 && p.uploaded >= p.Wants
+}
+
+// demand returns how many bytes the peer takes in a tick
+func (p *simPeer) demand() int64 {
+	n := p.Rate * int64(simulationTick/time.Second)
+	if p.Rate == 0 {
+		n = 1<<63 - 1
+	}
+	if p.Wants > 0 {
+		n = min(n, p.Wants-p.uploaded)
+	}
+	return n
+}
+
+func (s simulation) run(events []traceEvent) SimulationResult {
+	rnd := rand.New(rand.NewPCG(s.seed, s.seed))
+	strategy := uploadStrategies[cmp.Or(s.strategy, strategyDefault)]
+	swarms := make(map[string]map[string]*simPeer)
+	var all []*simPeer
+	optimistic := make(map[string]string)
+	var lastPick time.Duration
+	end := time.Duration(0)
+	if len(events) > 0 {
+		end = time.Duration(events[len(events)-1].At)
+	}
+
+	for now := time.Duration(0); ; now += simulationTick {
+		for ; len(events) > 0 && time.Duration(events[0].At) <= now; events = events[1:] {
+			e := events[0]
+			if swarms[e.Torrent] == nil {
+				swarms[e.Torrent] = make(map[string]*simPeer)
+			}
+			p := swarms[e.Torrent][e.Peer]
+			switch {
+			case e.Type == traceArrive && (p == nil || p.gone):
+				p = &simPeer{traceEvent: e, arrived: now}
+				swarms[e.Torrent][e.Peer] = p
+				all = append(all, p)
+			case p == nil || p.gone: // Not connected
+			case e.Type == traceLeave:
+				p.gone, p.left = true, now
+			default:
+				p.Rate, p.Gives = e.Rate, e.Gives
+				p.Wants = cmp.Or(e.Wants, p.Wants)
+			}
+		}
+
+		// Choke rounds
+		if now%chokeRoundInterval == 0 {
+			pick := now == 0 || now-lastPick >= s.optimisticInterval
+			if pick {
+				lastPick = now
+			}
+			for _, ih := range slices.Sorted(maps.Keys(swarms)) {
+				var peers []strategyPeer
+				for _, addr := range slices.Sorted(maps.Keys(swarms[ih])) {
+					p := swarms[ih][addr]
+					if p.done() {
+						continue
+					}
+					peers = append(peers, strategyPeer{
+						addr:         addr,
+						uploadRate:   p.uploaded - p.lastRound,
+						downloadRate: p.Gives * int64(chokeRoundInterval/time.Second),
+						uploaded:     p.uploaded,
+					})
+					p.lastRound = p.uploaded
+				}
+				var unchoked map[string]bool
+				unchoked, optimistic[ih] = unchoke(peers, strategy, s.maxUnchoked, optimistic[ih], pick, rnd)
+				for addr, p := range swarms[ih] {
+					p.unchoked = unchoked[addr]
+				}
+			}
+		}
+
+		// The upload goes evenly to the unchoked peers, and what the slowest can't take to the rest
+		var active []*simPeer
+		for _, p := range all {
+			if p.unchoked && !p.done() {
+				active = append(active, p)
+			}
+		}
+		slices.SortStableFunc(active, func(a, b *simPeer) int { return cmp.Compare(a.demand(), b.demand()) })
+		capacity := s.uploadRate * int64(simulationTick/time.Second)
+		for i, p := range active {
+			n := min(p.demand(), capacity/int64(len(active)-i))
+			if n > 0 && p.uploaded == 0 {
+				p.firstByte = now + simulationTick
+			}
+			p.uploaded += n
+			capacity -= n
+			if p.Wants > 0 && p.uploaded >= p.Wants {
+				p.gone, p.left = true, now+simulationTick
+			}
+		}
+
+		if now >= end && (now >= end+maxSimulationDrain || !slices.ContainsFunc(all, func(p *simPeer) bool { return p.Wants > 0 && !p.done() })) {
+			return s.result(all, now)
+		}
+	}
+}
+
+func (s simulation) result(peers []*simPeer, end time.Duration) SimulationResult {
+	r := SimulationResult{Strategy: cmp.Or(s.strategy, strategyDefault), Peers: len(peers)}
+	var finishing time.Duration
+	for _, p := range peers {
+		r.Uploaded += p.uploaded
+		wait := end - p.arrived
+		if p.firstByte > 0 {
+			wait = p.firstByte - p.arrived
+		} else if p.gone {
+			wait = p.left - p.arrived
+		}
+		if p.uploaded > 0 {
+			r.Served++
+		}
+		if p.Wants > 0 && p.uploaded >= p.Wants {
+			r.Finished++
+			finishing += p.left - p.arrived
+		}
+		r.MaxWait = max(r.MaxWait, duration(wait))
+	}
+	if r.Finished > 0 {
+		r.MeanFinish = duration(finishing / time.Duration(r.Finished))
+	}
+	return r
+}
+
+// SimulationSettings are what a trace is replayed with: the seeder's upload slots and rate, and
+// the strategy picking peers for them, all of them if empty
+type SimulationSettings struct {
+	Strategy           string
+	MaxUnchoked        int   // 0 for all peers
+	UploadRate         int64 // Bytes/s
+	OptimisticInterval time.Duration
+	Seed               uint64 // Seeds the random choices, so runs can be repeated
+}
+
+// SimulateTrace replays a trace recorded with -record-trace against the upload strategies,
+// without networking, and returns how the peers fared under each
+func SimulateTrace(trace io.Reader, settings SimulationSettings) ([]SimulationResult, error) {
+	if settings.UploadRate <= 0 {
+		return nil, errors.New("❌ The upload rate must be positive")
+	}
+	if err := validateStrategy(settings.Strategy); err != nil {
+		return nil, err
+	}
+	events, err := readTrace(trace)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, errors.New("❌ The trace has no events")
+	}
+	strategies := []string{settings.Strategy}
+	if settings.Strategy == "" {
+		strategies = slices.Sorted(maps.Keys(uploadStrategies))
+	}
+	var results []SimulationResult
+	for _, name := range strategies {
+		results = append(results, simulation{
+			strategy:           name,
+			maxUnchoked:        settings.MaxUnchoked,
+			uploadRate:         settings.UploadRate,
+			optimisticInterval: settings.OptimisticInterval,
+			seed:               settings.Seed,
+		}.run(events))
+	}
+	return results, nil
+}
@@ -0,0 +1,89 @@
+package distroseed
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A swarm of two peers that upload back and four that don't, each wanting 20 MiB
+const testTrace = `# Peers upload 512 KiB/s back, or nothing
+{"at":"0s","peer":"giver1","type":"arrive","gives":524288,"wants":20971520}
+{"at":"0s","peer":"leech1","type":"arrive","wants":20971520}
+{"at":"0s","peer":"leech2","type":"arrive","wants":20971520}
+{"at":"5s","peer":"giver2","type":"arrive","gives":524288,"wants":20971520}
+{"at":"5s","peer":"leech3","type":"arrive","rate":131072,"wants":20971520}
+{"at":"20s","peer":"leech4","type":"arrive","wants":20971520}
+{"at":"60s","peer":"leech2","type":"leave"}
+`
+
+func TestSimulation(t *testing.T) {
+	events, err := readTrace(strings.NewReader(testTrace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make(map[string]SimulationResult)
+	for name := range uploadStrategies {
+		s := simulation{strategy: name, maxUnchoked: 2, uploadRate: 1 << 20, optimisticInterval: 30 * time.Second, seed: 1}
+		r := s.run(events)
+		if again := s.run(events); again != r {
+			t.Errorf("%s replayed differently with the same seed: %s, then %s", name, r, again)
+		}
+		if r.Peers != 6 || r.Finished != 5 {
+			t.Errorf("%s: %s, want 5 of 6 peers finished", name, r)
+		}
+		results[name] = r
+	}
+	// Spreading the upload out gets every peer started sooner, and favoring the peers that upload
+	// back gets those done sooner
+	if wide, def := results[strategyWidestDistribution], results[strategyDefault]; wide.MaxWait >= def.MaxWait {
+		t.Errorf("widest-distribution waits %s, default %s", time.Duration(wide.MaxWait), time.Duration(def.MaxWait))
+	}
+	if fast, def := results[strategyFastestFirst], results[strategyDefault]; fast.MeanFinish >= def.MeanFinish {
+		t.Errorf("fastest-first finishes in %s on average, default %s", time.Duration(fast.MeanFinish), time.Duration(def.MeanFinish))
+	}
+}
+
+func TestReadTraceErrors(t *testing.T) {
+	for _, trace := range []string{
+		`{"at":"0s","peer":"a","type":"join"}`,
+		`{"at":"0s","type":"arrive"}`,
+		`{"at":"soon","peer":"a","type":"arrive"}`,
+	} {
+		if _, err := readTrace(strings.NewReader(trace)); err == nil {
+			t.Errorf("trace %s was accepted", trace)
+		}
+	}
+}
+
+func TestTraceRecorder(t *testing.T) {
+	var buf bytes.Buffer
+	start := time.Now()
+	r := newTraceRecorder(&buf, start)
+	const ih = "0123456789abcdef0123456789abcdef01234567"
+	perRound := func(rate int64) int64 { return rate * int64(chokeRoundInterval/time.Second) }
+	r.round(ih, []strategyPeer{{addr: "a:1", uploadRate: perRound(1000)}, {addr: "b:1"}}, start.Add(chokeRoundInterval))
+	// a is choked, which doesn't lower its rate, b starts uploading back
+	r.round(ih, []strategyPeer{{addr: "a:1"}, {addr: "b:1", downloadRate: perRound(500)}}, start.Add(2*chokeRoundInterval))
+	r.round(ih, []strategyPeer{{addr: "b:1", downloadRate: perRound(500)}}, start.Add(3*chokeRoundInterval))
+
+	events, err := readTrace(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []traceEvent{
+		{At: duration(chokeRoundInterval), Torrent: ih, Peer: "a:1", Type: traceArrive, Rate: 1000},
+		{At: duration(chokeRoundInterval), Torrent: ih, Peer: "b:1", Type: traceArrive},
+		{At: duration(2 * chokeRoundInterval), Torrent: ih, Peer: "b:1", Type: traceRequest, Gives: 500},
+		{At: duration(3 * chokeRoundInterval), Torrent: ih, Peer: "a:1", Type: traceLeave},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("recorded %+v, want %+v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+}
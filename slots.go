@@ -0,0 +1,68 @@
+package distroseed
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	minConnsPerTorrent = 20   // Lower bound for the scaled connections per torrent
+	slotScaleStep      = 20   // Connections added or removed per torrent while probing
+	slotHoldChecks     = 10   // Checks to wait after backing off before probing again
+	marginalThreshold  = 0.25 // Fraction of the average per-slot throughput new slots must add
+)
+
+// slotScaler finds how many connections per torrent maximize total upload by adding slots while
+// each new one still adds a meaningful share of throughput, and backing off once it doesn't
+type slotScaler struct {
+	slots     int     // Current connections per active torrent
+	prevSlots int     // Slots during the previous measurement
+	prevRate  float64 // Upload rate during the previous measurement, in bytes/s
+	hold      int     // Checks left before probing again
+	floor     int     // Lower bound, below minConnsPerTorrent if the slots start there
+	lastBytes int64
+	lastAt    time.Time
+}
+
+func newSlotScaler(slots int) *slotScaler {
+	return &slotScaler{slots: slots, prevSlots: slots, floor: min(slots, minConnsPerTorrent)}
+}
+
+// update takes the client's total bytes uploaded and returns the connections per active torrent
+// to use until the next check, at most maxSlots
+func (s *slotScaler) update(uploaded int64, now time.Time, maxSlots int) int {
+	if s.lastAt.IsZero() {
+		s.lastBytes, s.lastAt = uploaded, now
+		return s.slots
+	}
+	rate := float64(uploaded-s.lastBytes) / now.Sub(s.lastAt).Seconds()
+	s.lastBytes, s.lastAt = uploaded, now
+
+	next := s.slots
+	switch {
+	case s.slots != s.prevSlots && s.prevRate > 0:
+		// The last check probed a new slot count, keep it only if it paid off
+		marginal := (rate - s.prevRate) / float64(s.slots-s.prevSlots)
+		average := s.prevRate / float64(s.prevSlots)
+		if s.slots > s.prevSlots && marginal < average*marginalThreshold {
+			next = s.prevSlots
+			s.hold = slotHoldChecks
+			log.Printf("🚦 Upload throughput stopped growing at %d connections per torrent, settling on %d", s.slots, next)
+		} else if s.slots > s.prevSlots {
+			next = s.slots + slotScaleStep
+		}
+	case s.hold > 0:
+		s.hold--
+	case rate > 0:
+		// Only probe while there's upload demand to measure
+		next = s.slots + slotScaleStep
+	}
+	next = max(s.floor, min(next, maxSlots))
+
+	if next > s.slots {
+		log.Printf("🚦 Uploading %.2f MB/s, trying %d connections per torrent", rate/1024/1024, next)
+	}
+	s.prevSlots, s.prevRate = s.slots, rate
+	s.slots = next
+	return s.slots
+}
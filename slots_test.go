@@ -0,0 +1,91 @@
+package distroseed
+
+import (
+	"testing"
+	"time"
+)
+
+// feedRates passes a total uploaded that grew at each rate in bytes/s over a minute to the
+// scaler, and returns the slots it chose after each
+func feedRates(s *slotScaler, uploaded *int64, at *time.Time, rates ...float64) []int {
+	var slots []int
+	for _, rate := range rates {
+		*at = at.Add(time.Minute)
+		*uploaded += int64(rate * 60)
+		slots = append(slots, s.update(*uploaded, *at, defaultMaxConnsPerTorrent))
+	}
+	return slots
+}
+
+func TestSlotScalerProbesAndBacksOff(t *testing.T) {
+	const mb = 1 << 20
+	s := newSlotScaler(defaultConnsPerTorrent)
+	var uploaded int64
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := s.update(uploaded, at, defaultMaxConnsPerTorrent); got != defaultConnsPerTorrent {
+		t.Fatalf("first check chose %d slots, want %d", got, defaultConnsPerTorrent)
+	}
+
+	// Throughput keeps growing with each step up, then stops paying off
+	got := feedRates(s, &uploaded, &at, 1*mb, 1.5*mb, 1.52*mb)
+	want := []int{defaultConnsPerTorrent + slotScaleStep, defaultConnsPerTorrent + 2*slotScaleStep, defaultConnsPerTorrent + slotScaleStep}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("slots = %v, want %v", got, want)
+		}
+	}
+
+	// It holds there for a while before probing again
+	held := feedRates(s, &uploaded, &at, make([]float64, slotHoldChecks+1)...)
+	for i, slots := range held {
+		if slots != defaultConnsPerTorrent+slotScaleStep {
+			t.Fatalf("check %d after backing off chose %d slots, want %d", i, slots, defaultConnsPerTorrent+slotScaleStep)
+		}
+	}
+	if got := feedRates(s, &uploaded, &at, 1.5*mb); got[0] != defaultConnsPerTorrent+2*slotScaleStep {
+		t.Errorf("after holding chose %d slots, want to probe %d", got[0], defaultConnsPerTorrent+2*slotScaleStep)
+	}
+}
+
+func TestSlotScalerWithoutUploads(t *testing.T) {
+	s := newSlotScaler(defaultConnsPerTorrent)
+	var uploaded int64
+	at := time.Now()
+	s.update(uploaded, at, defaultMaxConnsPerTorrent)
+	// Nothing to measure, so nothing is probed
+	for i, slots := range feedRates(s, &uploaded, &at, 0, 0, 0) {
+		if slots != defaultConnsPerTorrent {
+			t.Errorf("check %d without uploads chose %d slots, want %d", i, slots, defaultConnsPerTorrent)
+		}
+	}
+}
+
+func TestSlotScalerStaysWithinBounds(t *testing.T) {
+	const mb = 1 << 20
+	s := newSlotScaler(10)
+	var uploaded int64
+	at := time.Now()
+	s.update(uploaded, at, 30)
+
+	// Growth stops at the max, however well it pays off
+	var slots int
+	for _, rate := range []int64{1 * mb, 2 * mb, 3 * mb, 4 * mb} {
+		at = at.Add(time.Minute)
+		uploaded += rate * 60
+		slots = s.update(uploaded, at, 30)
+	}
+	if slots != 30 {
+		t.Errorf("slots = %d, want them capped at 30", slots)
+	}
+
+	// A lower max applies straight away
+	at = at.Add(time.Minute)
+	uploaded += 4 * mb * 60
+	if slots = s.update(uploaded, at, 20); slots != 20 {
+		t.Errorf("with a max of 20, slots = %d", slots)
+	}
+	// Slots starting below the usual minimum can go back down there
+	if s.floor != 10 {
+		t.Errorf("floor = %d, want the 10 slots it started with", s.floor)
+	}
+}
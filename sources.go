@@ -0,0 +1,88 @@
+package distroseed
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/anacrolix/torrent"
+)
+
+// sourceRegistry maps each configured torrent URL or magnet link to the torrent added for it, or
+// the torrents for a directory of torrent files
+type sourceRegistry struct {
+	mu       sync.Mutex
+	torrents map[string][]*torrent.Torrent
+}
+
+func (r *sourceRegistry) Add(url string, t *torrent.Torrent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.torrents[url], t) {
+		r.torrents[url] = append(r.torrents[url], t)
+	}
+}
+
+// Remove forgets the source and returns its torrents that no other source still refers to
+func (r *sourceRegistry) Remove(url string) (unused []*torrent.Torrent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ts := r.torrents[url]
+	delete(r.torrents, url)
+	return r.unused(ts)
+}
+
+// Replace sets the torrents for a source, returning those it no longer has that no other source
+// refers to
+func (r *sourceRegistry) Replace(url string, ts []*torrent.Torrent) (unused []*torrent.Torrent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev := r.torrents[url]
+	r.torrents[url] = ts
+	return r.unused(slices.DeleteFunc(slices.Clone(prev), func(t *torrent.Torrent) bool {
+		return slices.Contains(ts, t)
+	}))
+}
+
+// unused returns the torrents no source refers to, the caller must hold r.mu
+func (r *sourceRegistry) unused(ts []*torrent.Torrent) []*torrent.Torrent {
+	return slices.DeleteFunc(ts, func(t *torrent.Torrent) bool {
+		for _, others := range r.torrents {
+			if slices.Contains(others, t) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Get returns the torrent added for url, the first one for a directory, if any
+func (r *sourceRegistry) Get(url string) (*torrent.Torrent, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	ts := r.torrents[url]
+	if len(ts) == 0 {
+		return nil, false
+	}
+	return ts[0], true
+}
+
+// Torrents returns the torrents added for url
+func (r *sourceRegistry) Torrents(url string) []*torrent.Torrent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.torrents[url])
+}
+
+// URLs returns the sources the torrent was added from
+func (r *sourceRegistry) URLs(t *torrent.Torrent) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var urls []string
+	for url, ts := range r.torrents {
+		if slices.Contains(ts, t) {
+			urls = append(urls, url)
+		}
+	}
+	slices.Sort(urls)
+	return urls
+}
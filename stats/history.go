@@ -0,0 +1,108 @@
+package stats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	historyFileName = "upload_history.csv"
+	historyDate     = time.DateOnly
+)
+
+// History keeps a daily rollup of bytes uploaded, in local time, so long-term trends
+// survive alongside the single running total in the stats file
+type History struct {
+	mu   sync.Mutex
+	path string
+	days []DailyUpload // Oldest first
+}
+
+// DailyUpload is the bytes uploaded on a day
+type DailyUpload struct {
+	Date     string // YYYY-MM-DD
+	Uploaded int64
+}
+
+// LoadHistory reads the history kept in downloadDir
+func LoadHistory(downloadDir string) *History {
+	h := &History{path: filepath.Join(downloadDir, historyFileName)}
+	file, err := os.Open(h.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Warning: Could not read upload history: %v", err)
+		}
+		return h
+	}
+	defer file.Close()
+
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		log.Printf("Warning: Failed to parse upload history: %v", err)
+		return h
+	}
+	for i, record := range records {
+		if i == 0 || len(record) < 2 {
+			continue // Header
+		}
+		uploaded, err := strconv.ParseInt(record[1], 10, 64)
+		if err != nil {
+			log.Printf("Warning: Skipping invalid upload history row %d: %v", i+1, err)
+			continue
+		}
+		h.days = append(h.days, DailyUpload{Date: record[0], Uploaded: uploaded})
+	}
+	return h
+}
+
+// Add counts bytes uploaded at the given time towards that day and saves the history
+func (h *History) Add(uploaded int64, at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	date := at.Format(historyDate)
+	if n := len(h.days); n > 0 && h.days[n-1].Date == date {
+		h.days[n-1].Uploaded += uploaded
+	} else {
+		h.days = append(h.days, DailyUpload{Date: date, Uploaded: uploaded})
+	}
+	if err := h.save(); err != nil {
+		log.Printf("Error: Failed to write upload history: %v", err)
+	}
+}
+
+// Between returns the days from start up to, but not including, end
+func (h *History) Between(start, end time.Time) []DailyUpload {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	from, to := start.Format(historyDate), end.Format(historyDate)
+	var days []DailyUpload
+	for _, d := range h.days {
+		if d.Date >= from && d.Date < to {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// save writes the history, the caller must hold h.mu
+func (h *History) save() error {
+	file, err := os.Create(h.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write([]string{"date", "uploaded_bytes"})
+	for _, d := range h.days {
+		w.Write([]string{d.Date, fmt.Sprint(d.Uploaded)})
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,30 @@
+package stats
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUploadHistoryRollsUpDays(t *testing.T) {
+	dir := t.TempDir()
+	h := LoadHistory(dir)
+	day := func(d, hour int) time.Time { return time.Date(2024, 3, d, hour, 0, 0, 0, time.Local) }
+	h.Add(100, day(1, 9))
+	h.Add(50, day(1, 23))
+	h.Add(7, day(3, 0))
+
+	// Across restarts too
+	reloaded := LoadHistory(dir)
+	reloaded.Add(3, day(3, 12))
+	want := []DailyUpload{{Date: "2024-03-01", Uploaded: 150}, {Date: "2024-03-03", Uploaded: 10}}
+	if got := reloaded.Between(day(1, 0), day(4, 0)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Between = %+v, want %+v", got, want)
+	}
+	if got := reloaded.Between(day(2, 0), day(3, 0)); len(got) != 0 {
+		t.Errorf("Between a day without uploads = %+v, want none", got)
+	}
+	if got := reloaded.Between(day(3, 0), day(10, 0)); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("Between from the 3rd = %+v, want %+v", got, want[1:])
+	}
+}
@@ -0,0 +1,137 @@
+package stats
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+const ledgerFileName = "upload_totals.json"
+
+// Ledger keeps each torrent's lifetime upload by infohash. The client's counters start
+// from zero in every process, so the ledger remembers the last counter it accounted for along
+// with the session it belongs to. A session spans a seeder's run across upgrades, which hand it
+// over with the counters, and accounting is against what was persisted, so reading the counters
+// twice or in the wrong order around a restart can't count the same bytes twice.
+type Ledger struct {
+	mu       sync.Mutex
+	path     string
+	session  string
+	torrents map[string]*ledgerEntry
+}
+
+type ledgerEntry struct {
+	Lifetime int64  `json:"lifetime"` // Bytes uploaded across all runs
+	Session  string `json:"session"`  // Session the counter was read in
+	Counter  int64  `json:"counter"`  // Session upload counter accounted for so far
+}
+
+// NewSessionID identifies a seeder's run, for a process that wasn't started by an upgrade
+func NewSessionID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LoadLedger reads the ledger kept in downloadDir, for the given session
+func LoadLedger(downloadDir, session string) *Ledger {
+	l := &Ledger{
+		path:     filepath.Join(downloadDir, ledgerFileName),
+		session:  session,
+		torrents: make(map[string]*ledgerEntry),
+	}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("⚠️ Could not read per-torrent upload totals: %v", err)
+		}
+		return l
+	}
+	if err := json.Unmarshal(data, &l.torrents); err != nil {
+		log.Printf("⚠️ Could not parse per-torrent upload totals: %v", err)
+	}
+	return l
+}
+
+// Record accounts for a torrent's session counter, made of what previous processes in the
+// session handed over and what this process's client uploaded, and returns the bytes uploaded
+// since it was last recorded
+func (l *Ledger) Record(ih string, inherited, uploaded int64) int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	counter := inherited + uploaded
+	e, ok := l.torrents[ih]
+	if !ok {
+		e = &ledgerEntry{}
+		l.torrents[ih] = e
+	}
+	var delta int64
+	switch {
+	case e.Session != l.session:
+		// The first reading in this session, which the last one's counters don't apply to
+		delta = counter
+	case counter >= e.Counter:
+		delta = counter - e.Counter
+	default:
+		// Counters only go down when the torrent was dropped and added again, which starts
+		// the client's counter over
+		log.Printf("⚠️ Upload counter for %s went back from %d to %d, counting it from zero", ih, e.Counter, counter)
+		delta = uploaded
+	}
+	e.Lifetime += delta
+	e.Session, e.Counter = l.session, counter
+	return delta
+}
+
+// Inherit takes the counters handed over by a process that predates sessions as already
+// accounted for, since it saved its total before handing over
+func (l *Ledger) Inherit(counters map[string]int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for ih, counter := range counters {
+		e, ok := l.torrents[ih]
+		if !ok {
+			e = &ledgerEntry{}
+			l.torrents[ih] = e
+		}
+		e.Session, e.Counter = l.session, counter
+	}
+}
+
+// Session returns the session handed over on upgrades, empty without a ledger
+func (l *Ledger) Session() string {
+	if l == nil {
+		return ""
+	}
+	return l.session
+}
+
+// Lifetime returns the bytes the torrent uploaded across all runs, as of the last record
+func (l *Ledger) Lifetime(ih string) int64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if e, ok := l.torrents[ih]; ok {
+		return e.Lifetime
+	}
+	return 0
+}
+
+// Save writes the ledger, as of the last record
+func (l *Ledger) Save() error {
+	l.mu.Lock()
+	data, err := json.MarshalIndent(l.torrents, "", "  ")
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return atomicfile.Write(l.path, data, 0644)
+}
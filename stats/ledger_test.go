@@ -0,0 +1,69 @@
+package stats
+
+import "testing"
+
+const ledgerTestHash = "0123456789abcdef0123456789abcdef01234567"
+
+func TestUploadLedgerRestarts(t *testing.T) {
+	dir := t.TempDir()
+	first := LoadLedger(dir, "first")
+	if got := first.Record(ledgerTestHash, 0, 100); got != 100 {
+		t.Errorf("first reading added %d, want 100", got)
+	}
+	if got := first.Record(ledgerTestHash, 0, 100); got != 0 {
+		t.Errorf("reading the same counter again added %d", got)
+	}
+	if got := first.Record(ledgerTestHash, 0, 250); got != 150 {
+		t.Errorf("second reading added %d, want 150", got)
+	}
+	if err := first.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart starts a new session, with the client counting from zero
+	second := LoadLedger(dir, "second")
+	if got := second.Record(ledgerTestHash, 0, 40); got != 40 {
+		t.Errorf("reading after a restart added %d, want 40", got)
+	}
+	if got := second.Lifetime(ledgerTestHash); got != 290 {
+		t.Errorf("lifetime %d after a restart, want 290", got)
+	}
+	if err := second.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The upgraded process continues the session, and the bytes uploaded after the last save
+	// are counted once
+	second.Record(ledgerTestHash, 0, 60)
+	upgraded := LoadLedger(dir, "second")
+	if got := upgraded.Record(ledgerTestHash, 60, 10); got != 30 {
+		t.Errorf("reading after an upgrade added %d, want 30", got)
+	}
+	if got := upgraded.Lifetime(ledgerTestHash); got != 320 {
+		t.Errorf("lifetime %d after an upgrade, want 320", got)
+	}
+}
+
+func TestUploadLedgerCounterReset(t *testing.T) {
+	l := LoadLedger(t.TempDir(), "session")
+	l.Record(ledgerTestHash, 500, 100)
+	// Dropped and added again, so the client's counter started over
+	if got := l.Record(ledgerTestHash, 500, 20); got != 20 {
+		t.Errorf("reading after a reset added %d, want 20", got)
+	}
+	if got := l.Record(ledgerTestHash, 500, 50); got != 30 {
+		t.Errorf("reading after the reset added %d, want 30", got)
+	}
+	if got := l.Lifetime(ledgerTestHash); got != 650 {
+		t.Errorf("lifetime %d, want 650", got)
+	}
+}
+
+func TestUploadLedgerInheritsWithoutSession(t *testing.T) {
+	// An older process handed over its counters after saving its total
+	l := LoadLedger(t.TempDir(), "new")
+	l.Inherit(map[string]int64{ledgerTestHash: 1000})
+	if got := l.Record(ledgerTestHash, 1000, 5); got != 5 {
+		t.Errorf("reading after an upgrade from an older version added %d, want 5", got)
+	}
+}
@@ -0,0 +1,75 @@
+// Package stats keeps a seeder's upload accounting on disk: the lifetime total in the stats
+// file, each torrent's total across sessions and restarts, and a daily rollup of uploads.
+package stats
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pawl/distro-seed/internal/atomicfile"
+)
+
+// Snapshots of the stats file kept as seed_stats.txt.1 (newest) to .N, at most one per interval,
+// to fall back on if the file is lost or corrupted
+const (
+	statsBackups        = 5
+	statsBackupInterval = time.Hour
+)
+
+func statsBackupPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// WriteSeedStats saves the lifetime upload total, and snapshots it into the backups when the
+// newest one is older than the backup interval
+func WriteSeedStats(path string, total int64, now time.Time) error {
+	data := []byte(strconv.FormatInt(total, 10))
+	if err := atomicfile.Write(path, data, 0644); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(statsBackupPath(path, 1)); err == nil && now.Sub(fi.ModTime()) < statsBackupInterval {
+		return nil
+	}
+	for n := statsBackups - 1; n >= 1; n-- {
+		if err := os.Rename(statsBackupPath(path, n), statsBackupPath(path, n+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return atomicfile.Write(statsBackupPath(path, 1), data, 0644)
+}
+
+func readStatsFile(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// ReadTotalUploaded reads the lifetime upload total, from the newest readable backup if the
+// stats file is missing or corrupted
+func ReadTotalUploaded(path string) int64 {
+	total, err := readStatsFile(path)
+	if err == nil {
+		return total
+	}
+	if os.IsNotExist(err) {
+		// A fresh download directory, unless only the file was lost
+		if _, backupErr := os.Stat(statsBackupPath(path, 1)); os.IsNotExist(backupErr) {
+			return 0
+		}
+	}
+	log.Printf("⚠️ Could not read the seed stats file: %v", err)
+	for n := 1; n <= statsBackups; n++ {
+		if total, err := readStatsFile(statsBackupPath(path, n)); err == nil {
+			log.Printf("♻️ Restored the upload total from %s", statsBackupPath(path, n))
+			return total
+		}
+	}
+	log.Printf("⚠️ No usable backup of the seed stats file, starting the upload total from 0")
+	return 0
+}
@@ -0,0 +1,53 @@
+package stats
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSeedStatsBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed_stats.txt")
+	if got := ReadTotalUploaded(path); got != 0 {
+		t.Errorf("fresh total %d", got)
+	}
+
+	// Snapshots are timed by their modification time, set to when they were taken
+	now := time.Now()
+	writes := []struct {
+		total int64
+		at    time.Time
+	}{{100, now}, {200, now.Add(statsBackupInterval)}, {300, now.Add(statsBackupInterval + time.Minute)}}
+	for _, w := range writes {
+		before, _ := os.Stat(statsBackupPath(path, 1))
+		if err := WriteSeedStats(path, w.total, w.at); err != nil {
+			t.Fatal(err)
+		}
+		if after, _ := os.Stat(statsBackupPath(path, 1)); before == nil || !os.SameFile(before, after) {
+			os.Chtimes(statsBackupPath(path, 1), w.at, w.at)
+		}
+	}
+	for file, want := range map[string]int64{path: 300, statsBackupPath(path, 1): 200, statsBackupPath(path, 2): 100} {
+		if got, err := readStatsFile(file); err != nil || got != want {
+			t.Errorf("%s holds %d, %v, want %d", file, got, err, want)
+		}
+	}
+	if _, err := os.Stat(statsBackupPath(path, 3)); !os.IsNotExist(err) {
+		t.Error("took a snapshot within the backup interval")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), ".*.tmp")); len(matches) > 0 {
+		t.Errorf("left temp files %v", matches)
+	}
+
+	// A torn write or a lost file falls back on the newest snapshot
+	os.WriteFile(path, []byte("30"+"\x00"), 0644)
+	if got := ReadTotalUploaded(path); got != 200 {
+		t.Errorf("restored %d from a corrupted file, want 200", got)
+	}
+	os.Remove(path)
+	os.WriteFile(statsBackupPath(path, 1), nil, 0644)
+	if got := ReadTotalUploaded(path); got != 100 {
+		t.Errorf("restored %d from a lost file, want 100", got)
+	}
+}
@@ -0,0 +1,302 @@
+package distroseed
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	statusFormatTable = "table"
+	statusFormatJSON  = "json"
+	statusFormatPlain = "plain"
+)
+
+// ANSI colors for the status table
+const (
+	colorReset  = "\x1b[0m"
+	colorBold   = "\x1b[1m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+)
+
+// torrentStatus is one torrent's line in the periodic status output
+type torrentStatus struct {
+	InfoHash       string            `json:"infohash"`
+	Name           string            `json:"name"`
+	State          string            `json:"state"`
+	Peers          int               `json:"peers"`
+	PEXPeers       int64             `json:"pex_peers,omitempty"` // Learned of through peer exchange
+	Swarm          *swarmTotals      `json:"swarm,omitempty"`     // From tracker scrapes
+	Uploaded       int64             `json:"uploaded"`            // This run, across upgrades
+	Lifetime       int64             `json:"lifetime_uploaded"`   // All runs
+	Rates          transferRates     `json:"rates"`
+	CompletedAt    *time.Time        `json:"completed_at,omitempty"`
+	TimeToComplete *duration         `json:"time_to_complete,omitempty"` // From being added to being downloaded
+	Missing        int64             `json:"missing,omitempty"`          // Bytes missing on disk in upload-only mode
+	OnlySeed       bool              `json:"only_seed,omitempty"`
+	Download       *downloadProgress `json:"download,omitempty"`
+	Labels         []string          `json:"labels,omitempty"`
+	Error          *torrentError     `json:"error,omitempty"`
+}
+
+// seederStatus is everything logged on each status tick
+type seederStatus struct {
+	Time          time.Time                `json:"time"`
+	Torrents      []torrentStatus          `json:"torrents"`
+	TotalUploaded int64                    `json:"total_uploaded"` // All runs
+	Rates         transferRates            `json:"rates"`
+	Sources       map[string]sourceTraffic `json:"sources,omitempty"` // Traffic by how peers were found
+	DHT           []dhtStatus              `json:"dht,omitempty"`
+	OpenFiles     int                      `json:"open_files,omitempty"`
+	OpenFileLimit uint64                   `json:"open_file_limit,omitempty"`
+}
+
+type dhtStatus struct {
+	Name      string `json:"name"`
+	Nodes     int    `json:"nodes"`
+	GoodNodes int    `json:"good_nodes"`
+	Announces int64  `json:"announces"`
+}
+
+// torrentState describes what a torrent is doing, in the status and the API. What holds it back
+// comes before what it would be doing otherwise.
+func (s *Seeder) torrentState(t *torrent.Torrent) string {
+	ih := t.InfoHash().HexString()
+	_, missing := s.uploadOnly.Missing(ih)
+	switch {
+	case s.torrentErrors.Failed(ih):
+		return "error"
+	case s.diskPauses.IsPaused(ih) || s.schedule.Paused() || s.pauses.IsPaused(ih):
+		return "paused"
+	case s.queue.IsQueued(ih):
+		return "queued"
+	case missing:
+		return "missing data"
+	case t.Info() == nil:
+		return "waiting for metadata"
+	case s.uploadOnly == nil && !s.downloadComplete(t):
+		return "downloading"
+	default:
+		return "seeding"
+	}
+}
+
+// statusWriter logs the periodic status in the chosen format
+type statusWriter struct {
+	format string
+	color  bool // Only used for tables
+	quiet  bool // Nothing is logged
+}
+
+func validStatusFormat(format string) bool {
+	return format == statusFormatTable || format == statusFormatJSON || format == statusFormatPlain
+}
+
+func (w statusWriter) write(s seederStatus) {
+	if w.quiet {
+		return
+	}
+	switch w.format {
+	case statusFormatJSON:
+		b, err := json.Marshal(s)
+		if err != nil {
+			log.Printf("Error: Failed to encode status: %v", err)
+			return
+		}
+		log.Print(string(b))
+	case statusFormatPlain:
+		w.writePlain(s)
+	default:
+		for _, line := range w.table(s) {
+			log.Print(line)
+		}
+		w.writeSummary(s)
+	}
+}
+
+func (w statusWriter) writePlain(s seederStatus) {
+	for _, t := range s.Torrents {
+		var details string
+		if t.CompletedAt != nil {
+			details += " - Completed: " + t.CompletedAt.Format(time.DateTime)
+			if t.TimeToComplete != nil {
+				details += " in " + time.Duration(*t.TimeToComplete).String()
+			}
+		}
+		switch t.State {
+		case "queued":
+			details += " - Queued"
+		case "paused":
+			details += " - Paused (low disk space)"
+		case "error":
+			if t.Error != nil {
+				details += fmt.Sprintf(" - Error (%s): %s", t.Error.Kind, t.Error.Message)
+			}
+		}
+		if t.Missing > 0 {
+			details += fmt.Sprintf(" - Missing %s (upload-only)", formatBytes(t.Missing))
+		}
+		if t.OnlySeed {
+			details += " - Only seed"
+		}
+		if t.Swarm != nil {
+			details += fmt.Sprintf(" - Swarm: %d seeders, %d leechers", t.Swarm.Seeders, t.Swarm.Leechers)
+		}
+		if t.Download != nil {
+			details += " - " + t.Download.String()
+		}
+		if len(t.Labels) > 0 {
+			details += " - Labels: " + strings.Join(t.Labels, ", ")
+		}
+		log.Printf("➡️ %s - %d peers - %s - Total Uploaded: %s%s", t.Name, t.Peers, t.Rates, formatBytes(t.Uploaded), details)
+	}
+	w.writeSummary(s)
+}
+
+// writeSummary logs the totals, DHT and resource lines shared by the plain and table formats
+func (w statusWriter) writeSummary(s seederStatus) {
+	log.Printf("📊 Total uploaded: %s (all runs)", formatBytes(s.TotalUploaded))
+	r := s.Rates
+	log.Printf("📈 Upload: %s (1m %s, 15m %s) - Download: %s (1m %s, 15m %s)",
+		formatRate(r.Upload), formatRate(r.Upload1m), formatRate(r.Upload15m),
+		formatRate(r.Download), formatRate(r.Download1m), formatRate(r.Download15m))
+	var sources []string
+	for _, source := range peerSourceNames {
+		if t, ok := s.Sources[source]; ok && t.Uploaded > 0 {
+			sources = append(sources, fmt.Sprintf("%s %s (%d peers)", source, formatBytes(t.Uploaded), t.Peers))
+		}
+	}
+	if len(sources) > 0 {
+		log.Printf("🔎 Uploaded by peer source: %s", strings.Join(sources, ", "))
+	}
+	for _, d := range s.DHT {
+		log.Printf("🌐 DHT %s - %d nodes (%d good) - %d announces", d.Name, d.Nodes, d.GoodNodes, d.Announces)
+	}
+	switch {
+	case s.OpenFiles == 0:
+	case s.OpenFileLimit > 0:
+		log.Printf("🗂️ Open files: %d of %d", s.OpenFiles, s.OpenFileLimit)
+	default:
+		log.Printf("🗂️ Open files: %d", s.OpenFiles)
+	}
+}
+
+// table lays the torrents out in aligned columns, one line per torrent after the header
+func (w statusWriter) table(s seederStatus) []string {
+	header := []string{"NAME", "STATE", "PEERS", "UP", "DOWN", "UPLOADED", "PROGRESS"}
+	rows := make([][]string, 0, len(s.Torrents))
+	for _, t := range s.Torrents {
+		progress := "-"
+		if t.Download != nil {
+			progress = fmt.Sprintf("%.1f%%", t.Download.Percent)
+			if t.Download.ETA > 0 {
+				progress += " ETA " + time.Duration(t.Download.ETA).String()
+			}
+		} else if t.Missing > 0 {
+			progress = "missing " + formatBytes(t.Missing)
+		}
+		state := t.State
+		if t.OnlySeed {
+			state += " (only seed)"
+		}
+		rows = append(rows, []string{t.Name, state, fmt.Sprint(t.Peers), formatRate(t.Rates.Upload),
+			formatRate(t.Rates.Download), formatBytes(t.Uploaded), progress})
+	}
+
+	// Numbers are right-aligned
+	rightAligned := []bool{false, false, true, true, true, true, false}
+	// Seeders and leechers of the whole swarm, once trackers have been scraped
+	if slices.ContainsFunc(s.Torrents, func(t torrentStatus) bool { return t.Swarm != nil }) {
+		header = slices.Insert(header, 3, "SWARM")
+		rightAligned = slices.Insert(rightAligned, 3, true)
+		for i, t := range s.Torrents {
+			swarm := "-"
+			if t.Swarm != nil {
+				swarm = fmt.Sprintf("%d/%d", t.Swarm.Seeders, t.Swarm.Leechers)
+			}
+			rows[i] = slices.Insert(rows[i], 3, swarm)
+		}
+	}
+
+	widths := make([]int, len(header))
+	for _, row := range append([][]string{header}, rows...) {
+		for i, cell := range row {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	line := func(row []string, colors []string) string {
+		var b strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell))
+			if colors[i] != "" {
+				cell = colors[i] + cell + colorReset
+			}
+			if rightAligned[i] {
+				b.WriteString(pad + cell)
+			} else if i < len(row)-1 {
+				b.WriteString(cell + pad)
+			} else {
+				b.WriteString(cell)
+			}
+		}
+		return b.String()
+	}
+
+	lines := make([]string, 0, len(rows)+1)
+	colors := make([]string, len(header))
+	if w.color {
+		for i := range colors {
+			colors[i] = colorBold
+		}
+	}
+	lines = append(lines, line(header, colors))
+	for i, row := range rows {
+		colors := make([]string, len(header))
+		if w.color {
+			colors[1] = stateColor(s.Torrents[i].State)
+		}
+		lines = append(lines, line(row, colors))
+	}
+	return lines
+}
+
+func stateColor(state string) string {
+	switch state {
+	case "seeding":
+		return colorGreen
+	case "downloading", "queued", "waiting for metadata":
+		return colorYellow
+	default:
+		return colorRed
+	}
+}
+
+// formatBytes formats a size in the largest binary unit that keeps it at least 1
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 && n > -1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, unit := float64(n)/1024, 0
+	for (value >= 1024 || value <= -1024) && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	return fmt.Sprintf("%.2f %ciB", value, units[unit])
+}
+
+// formatRate formats a rate in bytes per second like formatBytes
+func formatRate(n int64) string {
+	return formatBytes(n) + "/s"
+}
@@ -0,0 +1,46 @@
+package distroseed
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatBytes(t *testing.T) {
+	for _, tt := range []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.50 KiB"},
+		{5 << 20, "5.00 MiB"},
+		{3 << 30, "3.00 GiB"},
+		{2 << 40, "2.00 TiB"},
+	} {
+		if got := formatBytes(tt.n); got != tt.want {
+			t.Errorf("formatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestStatusTable(t *testing.T) {
+	s := seederStatus{Torrents: []torrentStatus{
+		{Name: "debian.iso", State: "seeding", Peers: 12, Uploaded: 3 << 30, Rates: transferRates{Upload: 1 << 20}},
+		{Name: "a.iso", State: "downloading", Peers: 3, Download: &downloadProgress{Percent: 42}},
+	}}
+	lines := statusWriter{format: statusFormatTable}.table(s)
+	want := []string{
+		"NAME        STATE        PEERS          UP   DOWN  UPLOADED  PROGRESS",
+		"debian.iso  seeding         12  1.00 MiB/s  0 B/s  3.00 GiB  -",
+		"a.iso       downloading      3       0 B/s  0 B/s       0 B  42.0%",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("table:\n%s\nwant:\n%s", strings.Join(lines, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Colors don't throw the columns out
+	colored := statusWriter{format: statusFormatTable, color: true}.table(s)
+	if got := strings.ReplaceAll(colored[1], colorGreen, ""); strings.ReplaceAll(got, colorReset, "") != want[1] {
+		t.Errorf("colored row = %q", colored[1])
+	}
+}
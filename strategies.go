@@ -0,0 +1,93 @@
+package distroseed
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Built-in upload strategies
+const (
+	strategyDefault            = "default"
+	strategyFastestFirst       = "fastest-first"
+	strategyWidestDistribution = "widest-distribution"
+)
+
+// uploadStrategy decides how a torrent's upload is spent among its peers each choke round: which
+// peers are uploaded to first, and how many of them share the upload
+type uploadStrategy interface {
+	// rank orders the peers by who is uploaded to first. They come shuffled, so a stable sort
+	// leaves equals in random order.
+	rank(peers []strategyPeer)
+	// slots returns how many peers are uploaded to at full speed with -max-unchoked set to
+	// maxUnchoked
+	slots(maxUnchoked int) int
+}
+
+// strategyPeer is what a strategy knows of a connected peer
+type strategyPeer struct {
+	addr         string
+	uploadRate   int64 // Bytes uploaded to it over the last round
+	downloadRate int64 // Bytes downloaded from it over the last round
+	uploaded     int64 // Bytes uploaded to it since it connected
+}
+
+// The strategies torrents can be given by name
+var uploadStrategies = map[string]uploadStrategy{
+	strategyDefault:            defaultStrategy{},
+	strategyFastestFirst:       fastestFirstStrategy{},
+	strategyWidestDistribution: widestDistributionStrategy{},
+}
+
+func validateStrategy(name string) error {
+	if _, ok := uploadStrategies[name]; name != "" && !ok {
+		return fmt.Errorf("❌ Unknown upload strategy '%s', expected one of %s", name, strings.Join(slices.Sorted(maps.Keys(uploadStrategies)), ", "))
+	}
+	return nil
+}
+
+// strategyOf returns the strategy a torrent's sources pick, or the configured one
+func (s *Seeder) strategyOf(t *torrent.Torrent) uploadStrategy {
+	name := cmp.Or(s.optionsOf(t).Strategy, s.liveSettings.Get().UploadStrategy)
+	if strategy, ok := uploadStrategies[name]; ok {
+		return strategy
+	}
+	return defaultStrategy{}
+}
+
+// defaultStrategy keeps uploading to the peers it uploads to fastest, as in the BitTorrent spec
+type defaultStrategy struct{}
+
+func (defaultStrategy) rank(peers []strategyPeer) {
+	slices.SortStableFunc(peers, func(a, b strategyPeer) int { return cmp.Compare(b.uploadRate, a.uploadRate) })
+}
+
+func (defaultStrategy) slots(maxUnchoked int) int { return maxUnchoked }
+
+// fastestFirstStrategy favors the peers that upload back fastest, then the ones taking data
+// fastest, and gives them half the slots so each gets more. Fast peers finish sooner and start
+// seeding, and leechers that never upload are the last to be served.
+type fastestFirstStrategy struct{}
+
+func (fastestFirstStrategy) rank(peers []strategyPeer) {
+	slices.SortStableFunc(peers, func(a, b strategyPeer) int {
+		return cmp.Or(cmp.Compare(b.downloadRate, a.downloadRate), cmp.Compare(b.uploadRate, a.uploadRate))
+	})
+}
+
+func (fastestFirstStrategy) slots(maxUnchoked int) int { return max(1, maxUnchoked/2) }
+
+// widestDistributionStrategy spreads the upload over as many peers as it can, serving the ones
+// it has uploaded the least to first with twice the slots, so pieces reach more of a new
+// release's swarm to be passed on
+type widestDistributionStrategy struct{}
+
+func (widestDistributionStrategy) rank(peers []strategyPeer) {
+	slices.SortStableFunc(peers, func(a, b strategyPeer) int { return cmp.Compare(a.uploaded, b.uploaded) })
+}
+
+func (widestDistributionStrategy) slots(maxUnchoked int) int { return 2 * maxUnchoked }
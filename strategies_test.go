@@ -0,0 +1,65 @@
+package distroseed
+
+import "testing"
+
+func TestUploadStrategies(t *testing.T) {
+	// Peer 0 has taken the most, 1 uploads back fastest, 2 is uploaded to fastest
+	peers := []strategyPeer{
+		{uploadRate: 10, downloadRate: 0, uploaded: 900},
+		{uploadRate: 20, downloadRate: 50, uploaded: 500},
+		{uploadRate: 40, downloadRate: 5, uploaded: 100},
+	}
+	for _, tt := range []struct {
+		name  string
+		order []int64 // Bytes uploaded to each peer, in the order ranked
+		slots int     // With 4 slots
+	}{
+		{strategyDefault, []int64{100, 500, 900}, 4},
+		{strategyFastestFirst, []int64{500, 100, 900}, 2},
+		{strategyWidestDistribution, []int64{100, 500, 900}, 8},
+	} {
+		ranked := append([]strategyPeer(nil), peers...)
+		s := uploadStrategies[tt.name]
+		s.rank(ranked)
+		for i, p := range ranked {
+			if p.uploaded != tt.order[i] {
+				t.Errorf("%s ranked %+v", tt.name, ranked)
+				break
+			}
+		}
+		if got := s.slots(4); got != tt.slots {
+			t.Errorf("%s gives %d slots of 4, want %d", tt.name, got, tt.slots)
+		}
+	}
+	if got := (fastestFirstStrategy{}).slots(1); got != 1 {
+		t.Errorf("fastest-first gives %d slots of 1", got)
+	}
+	if err := validateStrategy("leech-first"); err == nil {
+		t.Error("an unknown strategy was accepted")
+	}
+}
+
+func TestStrategyOf(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.UploadStrategy = strategyWidestDistribution
+	cfg.TorrentOptions = map[string]torrentOptions{"fast.torrent": {Strategy: strategyFastestFirst}}
+	s := newTestSeeder(t, cfg)
+	client := newTestClient(t, dir)
+	fast, err := client.AddTorrent(newTestMeta(t, dir, "fast.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wide, err := client.AddTorrent(newTestMeta(t, dir, "wide.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.torrentSources.Add("fast.torrent", fast)
+	s.torrentSources.Add("wide.torrent", wide)
+	if _, ok := s.strategyOf(fast).(fastestFirstStrategy); !ok {
+		t.Errorf("fast.iso uses %T, want its option's fastest-first", s.strategyOf(fast))
+	}
+	if _, ok := s.strategyOf(wide).(widestDistributionStrategy); !ok {
+		t.Errorf("wide.iso uses %T, want the configured widest-distribution", s.strategyOf(wide))
+	}
+}
@@ -0,0 +1,74 @@
+package distroseed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/api"
+)
+
+const streamReadahead = 8 << 20 // Bytes past what's being read that are downloaded first
+
+// streamFile serves one of a torrent's files over HTTP, while it's still downloading too. The
+// pieces a request reads are downloaded before the rest, so an ISO can be piped into dd or
+// written to a USB stick as it arrives, and Range requests let players and resumed downloads
+// skip ahead.
+func (a *apiServer) streamFile(w http.ResponseWriter, r *http.Request) {
+	t, ok := a.apiTorrent(w, r)
+	if !ok {
+		return
+	}
+	if t.Info() == nil {
+		api.WriteError(w, http.StatusConflict, errors.New("the torrent's metadata isn't known yet"))
+		return
+	}
+	f := torrentFile(t, r.PathValue("path"))
+	if f == nil {
+		api.WriteError(w, http.StatusNotFound, fmt.Errorf("no file '%s' in %s", r.PathValue("path"), t.Name()))
+		return
+	}
+	if f.BytesCompleted() < f.Length() {
+		if err := a.seeder.streamable(t.InfoHash().HexString()); err != nil {
+			api.WriteError(w, http.StatusConflict, err)
+			return
+		}
+	}
+
+	log.Printf("📡 Streaming %s to %s", f.DisplayPath(), r.RemoteAddr)
+	reader := f.NewReader()
+	defer reader.Close()
+	reader.SetContext(r.Context())
+	reader.SetResponsive()
+	reader.SetReadahead(streamReadahead)
+	http.ServeContent(w, r, path.Base(f.Path()), time.Time{}, reader)
+}
+
+// torrentFile finds a file of a torrent by its path in the torrent, nil if there isn't one
+func torrentFile(t *torrent.Torrent, filePath string) *torrent.File {
+	for _, f := range t.Files() {
+		if f.Path() == filePath {
+			return f
+		}
+	}
+	return nil
+}
+
+// streamable returns why a torrent's missing data can't be downloaded to stream it, if it can't
+func (s *Seeder) streamable(infoHash string) error {
+	switch {
+	case s.uploadOnly != nil:
+		return errors.New("only complete files are served in upload-only mode")
+	case s.pauses.IsPaused(infoHash), s.schedule.Paused():
+		return errors.New("the torrent is paused")
+	case s.queue.IsQueued(infoHash):
+		return errors.New("the torrent is queued")
+	case s.diskPauses.IsPaused(infoHash):
+		return errors.New("the torrent is paused for lack of disk space")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamFile(t *testing.T) {
+	dir := t.TempDir()
+	seeder := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, dir, "image.iso", 256<<10)
+	seeding, err := seeder.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seeding.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "image.iso"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is downloaded until it's streamed
+	leecher := newTestClient(t, t.TempDir())
+	leeching, err := leecher.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leeching.AddClientPeer(seeder)
+	ih := leeching.InfoHash().HexString()
+	api := &apiServer{seeder: s, ctx: context.Background(), client: leecher}
+	get := func(path, rangeHeader string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/torrents/"+ih+"/files/"+path, nil)
+		req.SetPathValue("infohash", ih)
+		req.SetPathValue("path", path)
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		w := httptest.NewRecorder()
+		api.streamFile(w, req)
+		return w
+	}
+
+	w := get("image.iso", "bytes=100000-100099")
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), data[100000:100100]) {
+		t.Fatalf("range of the file still downloading: status %d, %d bytes", w.Code, w.Body.Len())
+	}
+	w = get("image.iso", "")
+	if body, _ := io.ReadAll(w.Body); w.Code != http.StatusOK || !bytes.Equal(body, data) {
+		t.Fatalf("whole file: status %d, %d bytes", w.Code, len(body))
+	}
+	if w := get("other.iso", ""); w.Code != http.StatusNotFound {
+		t.Errorf("file not in the torrent: status %d, want 404", w.Code)
+	}
+}
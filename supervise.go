@@ -0,0 +1,119 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	maxTaskRestarts  = 3                // Panics a torrent's task is restarted after, before the torrent is stopped
+	taskRestartDelay = 10 * time.Second // Before the first restart, doubled for each one after
+	taskFailureQueue = 64               // Failures waiting for the supervisor, before they're handled where they happened
+)
+
+// taskFailure is a torrent's task that panicked
+type taskFailure struct {
+	t       *torrent.Torrent
+	err     error
+	restart func() // Starts the task again
+}
+
+// taskSupervisor isolates the torrents whose tasks panic, so a malformed torrent can't take the
+// seeder down. Their tasks are restarted a few times in case it was a fluke, and then the torrent
+// is stopped with a panic error until the seeder restarts.
+type taskSupervisor struct {
+	seeder   *Seeder
+	failures chan taskFailure
+	mu       sync.Mutex
+	panics   map[string]int // By infohash
+}
+
+func newTaskSupervisor(s *Seeder) *taskSupervisor {
+	return &taskSupervisor{seeder: s, failures: make(chan taskFailure, taskFailureQueue), panics: make(map[string]int)}
+}
+
+// goTorrentTask runs a task of a torrent, like seeding it, in a goroutine that shutdown waits for.
+// If it panics, the supervisor is told instead of the seeder crashing.
+func (s *Seeder) goTorrentTask(ctx context.Context, t *torrent.Torrent, name string, task func(context.Context)) {
+	s.goTask(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err := fmt.Errorf("%s panicked: %v", name, r)
+				log.Printf("💥 %s: %v\n%s", t.InfoHash().HexString(), err, debug.Stack())
+				s.supervisor.report(ctx, taskFailure{t: t, err: err, restart: func() { s.goTorrentTask(ctx, t, name, task) }})
+			}
+		}()
+		task(ctx)
+	})
+}
+
+// catchPanic runs f, and returns what it panicked with as an error
+func catchPanic(f func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panicked: %v", r)
+			log.Printf("💥 %v\n%s", err, debug.Stack())
+		}
+	}()
+	f()
+	return nil
+}
+
+// report hands a failure to the supervisor, or handles it right away if the supervisor is
+// behind, so a torrent's task never blocks on it
+func (s *taskSupervisor) report(ctx context.Context, f taskFailure) {
+	select {
+	case s.failures <- f:
+	default:
+		s.handle(ctx, f)
+	}
+}
+
+// run handles the failures of torrents' tasks until ctx is done
+func (s *taskSupervisor) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case f := <-s.failures:
+			s.handle(ctx, f)
+		}
+	}
+}
+
+// handle restarts the failed task after a delay, or stops its torrent if it failed too often
+func (s *taskSupervisor) handle(ctx context.Context, f taskFailure) {
+	ih := f.t.InfoHash().HexString()
+	s.mu.Lock()
+	s.panics[ih]++
+	panics := s.panics[ih]
+	s.mu.Unlock()
+	if panics > maxTaskRestarts {
+		s.seeder.torrentErrors.Fail(f.t, errorPanic, fmt.Errorf("%w, %d times", f.err, panics), nil)
+		return
+	}
+	delay := taskRestartDelay << (panics - 1)
+	log.Printf("⚠️ Restarting %s in %s: %v", ih, delay, f.err)
+	s.seeder.events.Record(ih, eventRestarted, f.err.Error())
+	s.seeder.goTask(func() {
+		select {
+		case <-ctx.Done():
+		case <-f.t.Closed():
+		case <-time.After(delay):
+			f.restart()
+		}
+	})
+}
+
+// forget drops the panics of a torrent that was removed
+func (s *taskSupervisor) forget(infoHash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.panics, infoHash)
+}
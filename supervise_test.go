@@ -0,0 +1,51 @@
+package distroseed
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskSupervisor(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	tt := addSeedingTestTorrent(t, client, dir, "image.iso")
+	ih := tt.InfoHash().HexString()
+
+	// A panicking task is reported instead of crashing the seeder
+	s.goTorrentTask(context.Background(), tt, "seeding", func(context.Context) { panic("malformed") })
+	var f taskFailure
+	select {
+	case f = <-s.supervisor.failures:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the panic wasn't reported")
+	}
+	if f.t != tt || !strings.Contains(f.err.Error(), "seeding panicked: malformed") {
+		t.Errorf("failure = %v of %s", f.err, f.t.Name())
+	}
+
+	// It's restarted a few times, then the torrent is stopped
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range maxTaskRestarts {
+		s.supervisor.handle(canceled, f)
+		if s.torrentErrors.Failed(ih) {
+			t.Fatalf("stopped after %d panics", s.supervisor.panics[ih])
+		}
+	}
+	s.supervisor.handle(canceled, f)
+	if te, ok := s.torrentErrors.Error(ih); !ok || te.Kind != errorPanic || te.NextRetry != nil {
+		t.Errorf("error = %+v, want a panic error that isn't retried", te)
+	}
+}
+
+func TestCatchPanic(t *testing.T) {
+	if err := catchPanic(func() { panic("malformed metainfo") }); err == nil || !strings.Contains(err.Error(), "malformed metainfo") {
+		t.Errorf("catchPanic = %v", err)
+	}
+	if err := catchPanic(func() {}); err != nil {
+		t.Errorf("catchPanic without a panic = %v", err)
+	}
+}
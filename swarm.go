@@ -0,0 +1,69 @@
+package distroseed
+
+import (
+	"math"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Availability buckets, by how many connected peers have a piece
+var availabilityBuckets = []struct {
+	label    string
+	min, max int
+}{
+	{"0", 0, 0},
+	{"1", 1, 1},
+	{"2-4", 2, 4},
+	{"5+", 5, math.MaxInt},
+}
+
+// swarmHealth is how well a torrent is seeded, as far as the connected peers show
+type swarmHealth struct {
+	Seeds        int            `json:"seeds"` // Connected peers with every piece
+	Leechers     int            `json:"leechers"`
+	Availability map[string]int `json:"availability"`  // Pieces by how many connected peers have them
+	RarestCopies int            `json:"rarest_copies"` // Connected peers with the rarest piece
+	OnlySeed     bool           `json:"only_seed"`     // We have it all and no connected peer does
+}
+
+// swarmHealthOf works out the torrent's swarm health from its peers' bitfields, or returns false
+// before its metadata is known
+func swarmHealthOf(t *torrent.Torrent) (swarmHealth, bool) {
+	if t.Info() == nil {
+		return swarmHealth{}, false
+	}
+	numPieces := t.NumPieces()
+	h := swarmHealth{Availability: make(map[string]int, len(availabilityBuckets))}
+	copies := make([]int, numPieces)
+	for _, pc := range t.PeerConns() {
+		pieces := pc.PeerPieces()
+		if pieces.GetCardinality() >= uint64(numPieces) {
+			h.Seeds++
+			continue
+		}
+		h.Leechers++
+		pieces.Iterate(func(i uint32) bool {
+			if int(i) < numPieces {
+				copies[i]++
+			}
+			return true
+		})
+	}
+
+	h.RarestCopies = -1
+	for _, n := range copies {
+		n += h.Seeds
+		if h.RarestCopies < 0 || n < h.RarestCopies {
+			h.RarestCopies = n
+		}
+		for _, b := range availabilityBuckets {
+			if n >= b.min && n <= b.max {
+				h.Availability[b.label]++
+				break
+			}
+		}
+	}
+	h.RarestCopies = max(h.RarestCopies, 0)
+	h.OnlySeed = t.Complete().Bool() && h.Seeds == 0
+	return h, true
+}
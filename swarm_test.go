@@ -0,0 +1,52 @@
+package distroseed
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent/types"
+)
+
+func TestSwarmHealthOf(t *testing.T) {
+	seedDir, leechDir := t.TempDir(), t.TempDir()
+	seeder, leecher := newTestClient(t, seedDir), newTestClient(t, leechDir)
+	meta := newTestMeta(t, seedDir, "a.iso", 64<<10)
+	seeding, err := seeder.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := seeding.VerifyData(); err != nil {
+		t.Fatal(err)
+	}
+
+	if h, ok := swarmHealthOf(seeding); !ok || !h.OnlySeed || h.Availability["0"] != 4 {
+		t.Errorf("without peers, swarm health = %+v, %v", h, ok)
+	}
+
+	// The leecher only wants the first piece, so it stays a leecher
+	leeching, err := leecher.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leeching.Piece(0).SetPriority(types.PiecePriorityNormal)
+	leeching.AddClientPeer(seeder)
+
+	var h swarmHealth
+	for deadline := time.Now().Add(5 * time.Second); h.Availability["1"] != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("the seeder never saw the leecher's piece: %+v", h)
+		}
+		h, _ = swarmHealthOf(seeding)
+	}
+	want := swarmHealth{Leechers: 1, Availability: map[string]int{"0": 3, "1": 1}, OnlySeed: true}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("seeder's swarm health = %+v, want %+v", h, want)
+	}
+
+	h, _ = swarmHealthOf(leeching)
+	want = swarmHealth{Seeds: 1, Availability: map[string]int{"1": 4}, RarestCopies: 1}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("leecher's swarm health = %+v, want %+v", h, want)
+	}
+}
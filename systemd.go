@@ -0,0 +1,84 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+// Added to the fetch timeout when extending systemd's start timeout, for loading what was fetched
+const startupFetchMargin = 30 * time.Second
+
+// sdNotify sends a state update to systemd when running as a Type=notify service, and does
+// nothing otherwise
+func sdNotify(state string) {
+	socketPath := os.Getenv("NOTIFY_SOCKET")
+	if socketPath == "" {
+		return
+	}
+	if socketPath[0] == '@' {
+		socketPath = "\x00" + socketPath[1:] // Abstract socket
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socketPath, Net: "unixgram"})
+	if err != nil {
+		log.Printf("Warning: Could not notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("Warning: Could not notify systemd: %v", err)
+	}
+}
+
+// watchdogInterval returns how often systemd expects a keepalive, if the watchdog is enabled
+// for this process
+func watchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// Send watchdog keepalives at half the interval systemd expects, until the context is cancelled.
+// Keepalives stop if the client deadlocks, so systemd restarts us.
+func runWatchdog(ctx context.Context, client *torrent.Client) {
+	interval, ok := watchdogInterval()
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			client.Torrents() // Blocks if the client lock is stuck
+			sdNotify("WATCHDOG=1")
+		}
+	}
+}
+
+// sdExtendStartup asks systemd to wait another fetch timeout for the seeder to be ready, for
+// each file fetched while starting, so a long list of torrents doesn't run past TimeoutStartSec.
+// It's ignored once the seeder is ready.
+func sdExtendStartup(what string) {
+	sdNotify(fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d\nSTATUS=%s", (fetch.Timeout + startupFetchMargin).Microseconds(), what))
+}
+
+// Show a one-line summary in `systemctl status`
+func sdNotifyStatus(torrents, peers int, totalUploaded int64) {
+	sdNotify(fmt.Sprintf("STATUS=Seeding %d torrents to %d peers, %s uploaded (all runs)",
+		torrents, peers, formatBytes(totalUploaded)))
+}
@@ -0,0 +1,79 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/pawl/distro-seed/fetch"
+)
+
+func TestSDNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	sdNotifyStatus(3, 10, 5<<20)
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(buf[:n]), "STATUS=Seeding 3 torrents to 10 peers, 5.00 MiB uploaded (all runs)"; got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestSDExtendStartup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notify")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	sdExtendStartup("Loading a.torrent")
+	buf := make([]byte, 256)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "EXTEND_TIMEOUT_USEC=" + strconv.FormatInt((fetch.Timeout+startupFetchMargin).Microseconds(), 10) + "\nSTATUS=Loading a.torrent"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		usec, pid string
+		want      time.Duration
+		ok        bool
+	}{
+		{usec: "", ok: false},
+		{usec: "30000000", want: 30 * time.Second, ok: true},
+		{usec: "30000000", pid: strconv.Itoa(os.Getpid()), want: 30 * time.Second, ok: true},
+		// Meant for another process, such as the one that exec'd us
+		{usec: "30000000", pid: "1", ok: false},
+		{usec: "-5", ok: false},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCHDOG_USEC", tt.usec)
+		t.Setenv("WATCHDOG_PID", tt.pid)
+		if got, ok := watchdogInterval(); got != tt.want || ok != tt.ok {
+			t.Errorf("watchdogInterval with WATCHDOG_USEC=%s WATCHDOG_PID=%s = %s, %t, want %s, %t", tt.usec, tt.pid, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package distroseed
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"slices"
+
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+// cachedTorrentPath returns the cached .torrent file for url, if it's been fetched before. A
+// copy saved by an older release is copied into the cache first if the registry shows it was
+// fetched from url, and left in place, as other URLs with the same base name may have used it.
+func (s *Seeder) cachedTorrentPath(downloadDir, url string) (string, bool) {
+	path := fetch.CachePath(downloadDir, url)
+	if _, err := os.Stat(path); err == nil {
+		return path, true
+	}
+	data, ok := legacyTorrentFile(s.registry, downloadDir, url)
+	if !ok {
+		return "", false
+	}
+	if _, err := fetch.CacheTorrent(downloadDir, url, data); err != nil {
+		log.Printf("⚠️ Could not cache the saved copy of %s, fetching it again: %v", url, err)
+		return "", false
+	}
+	return path, true
+}
+
+// legacyTorrentFile returns the .torrent file an older release saved for url, if r has its
+// torrent as added from url. Files were named by the URL's base name then, so one there may be
+// another URL's, like https://b/x.torrent's for https://a/x.torrent.
+func legacyTorrentFile(r *torrentRegistry, downloadDir, url string) ([]byte, bool) {
+	if r == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(fetch.LegacyPath(downloadDir, url))
+	if err != nil {
+		return nil, false
+	}
+	meta, err := metainfo.Load(bytes.NewReader(data))
+	if err != nil {
+		return nil, false
+	}
+	e, ok := r.Entry(meta.HashInfoBytes().HexString())
+	if !ok || (e.Source != url && !slices.Contains(e.Duplicates, url)) {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,99 @@
+package distroseed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+func TestTorrentCacheKeyedByURL(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	metas := map[string][]byte{}
+	for _, id := range []string{"1", "2"} {
+		data, err := bencode.Marshal(newTestMeta(t, dir, "release"+id+".iso", 32<<10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		metas[id] = data
+	}
+	fetches := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		w.Write(metas[r.URL.Query().Get("id")])
+	}))
+	t.Cleanup(srv.Close)
+
+	// Both URLs have the base name download?id=..., and used to share a file
+	downloadDir := t.TempDir()
+	for _, id := range []string{"1", "2", "1"} {
+		url := srv.URL + "/download?id=" + id
+		meta, err := s.loadTorrentFile(t.Context(), url, downloadDir, fetch.Credentials{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, _ := meta.UnmarshalInfo()
+		if want := "release" + id + ".iso"; info.Name != want {
+			t.Errorf("%s loaded %s, want %s", url, info.Name, want)
+		}
+	}
+	if fetches != 2 {
+		t.Errorf("fetched %d times, want 2", fetches)
+	}
+
+	index := fetch.ReadCacheIndex(downloadDir)
+	for _, id := range []string{"1", "2"} {
+		url := srv.URL + "/download?id=" + id
+		if got := index[filepath.Base(fetch.CachePath(downloadDir, url))].URL; got != url {
+			t.Errorf("index maps %s's file to %q", url, got)
+		}
+	}
+}
+
+func TestTorrentCacheMigratesLegacyCopy(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	oldMeta := newTestMeta(t, dir, "old.iso", 32<<10)
+	old, err := bencode.Marshal(oldMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := bencode.Marshal(newTestMeta(t, dir, "other.iso", 32<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write(other) }))
+	t.Cleanup(srv.Close)
+
+	downloadDir := t.TempDir()
+	setTestSeederState(s, downloadDir)
+	url := "http://127.0.0.1:1/x.torrent" // Nothing answers, so the old copy has to be used
+	s.registry.Record(oldMeta.HashInfoBytes().HexString(), url, fetch.LegacyPath(downloadDir, url))
+	if err := os.WriteFile(fetch.LegacyPath(downloadDir, url), old, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.loadTorrentFile(t.Context(), url, downloadDir, fetch.Credentials{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(fetch.CachePath(downloadDir, url)); err != nil {
+		t.Errorf("the old copy wasn't cached: %v", err)
+	}
+	if got := fetch.ReadCacheIndex(downloadDir)[filepath.Base(fetch.CachePath(downloadDir, url))].URL; got != url {
+		t.Errorf("index maps the old copy to %q", got)
+	}
+
+	// Another URL with the same base name isn't given the old copy
+	meta, err := s.loadTorrentFile(t.Context(), srv.URL+"/x.torrent", downloadDir, fetch.Credentials{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := meta.UnmarshalInfo(); info.Name != "other.iso" {
+		t.Errorf("%s/x.torrent loaded %s, want other.iso", srv.URL, info.Name)
+	}
+}
@@ -0,0 +1,199 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Kinds of torrent errors
+const (
+	errorIO           = "io"            // Reading or writing the torrent's data failed
+	errorMissingFiles = "missing_files" // The torrent's data was deleted, or its disk unmounted
+	errorVerification = "verification"  // The data doesn't match what it's meant to be
+	errorPanic        = "panic"         // Seeding the torrent kept crashing
+)
+
+const (
+	recoveryCheckInterval = 15 * time.Second
+	minRecoveryBackoff    = time.Minute
+	maxRecoveryBackoff    = time.Hour
+)
+
+// torrentError is why a torrent stopped, and how recovering from it is going
+type torrentError struct {
+	Kind      string     `json:"kind"`
+	Message   string     `json:"message"`
+	Since     time.Time  `json:"since"`
+	Attempts  int        `json:"attempts,omitempty"`   // Recoveries tried so far
+	NextRetry *time.Time `json:"next_retry,omitempty"` // Unset for errors that aren't retried
+
+	retry func() error // Checks whether the cause is gone, nil if it can't be recovered from
+}
+
+// torrentErrorTracker keeps the torrents stopped by errors. They're paused, so they don't
+// announce or serve data that isn't there, and the ones that can recover are retried with a
+// backoff until they do, instead of stalling until someone notices.
+type torrentErrorTracker struct {
+	seeder *Seeder
+	mu     sync.Mutex
+	errors map[string]*torrentError // By infohash
+}
+
+// Fail stops the torrent for an error, reporting whether it wasn't stopped for one already. With
+// retry, it's called with a backoff until it succeeds, and then the torrent carries on.
+func (e *torrentErrorTracker) Fail(t *torrent.Torrent, kind string, err error, retry func() error) bool {
+	ih := t.InfoHash().HexString()
+	now := time.Now()
+	te := &torrentError{Kind: kind, Message: err.Error(), Since: now, retry: retry}
+	if retry != nil {
+		next := now.Add(minRecoveryBackoff)
+		te.NextRetry = &next
+	}
+	e.mu.Lock()
+	if _, ok := e.errors[ih]; ok {
+		e.mu.Unlock()
+		return false
+	}
+	e.errors[ih] = te
+	e.mu.Unlock()
+
+	e.seeder.pauses.pause(t, pauseError)
+	if retry != nil {
+		log.Printf("❌ Stopped %s after a %s error, retrying in %s: %v", t.Name(), kind, minRecoveryBackoff, err)
+	} else {
+		log.Printf("❌ Stopped %s after a %s error: %v", t.Name(), kind, err)
+	}
+	e.seeder.events.Record(ih, eventError, kind+": "+err.Error())
+	return true
+}
+
+// Error returns the error the torrent is stopped for, if it is
+func (e *torrentErrorTracker) Error(infoHash string) (torrentError, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if te, ok := e.errors[infoHash]; ok {
+		return *te, true
+	}
+	return torrentError{}, false
+}
+
+// Failed reports whether the torrent is stopped by an error
+func (e *torrentErrorTracker) Failed(infoHash string) bool {
+	_, ok := e.Error(infoHash)
+	return ok
+}
+
+// clear lets a torrent that recovered carry on
+func (e *torrentErrorTracker) clear(t *torrent.Torrent) {
+	ih := t.InfoHash().HexString()
+	e.mu.Lock()
+	delete(e.errors, ih)
+	e.mu.Unlock()
+	e.seeder.pauses.resume(t, pauseError)
+	log.Printf("✅ Recovered: %s", t.Name())
+	e.seeder.events.Record(ih, eventRecovered, "")
+}
+
+// forget drops the error of a torrent that was removed
+func (e *torrentErrorTracker) forget(infoHash string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.errors, infoHash)
+}
+
+// due returns the torrents whose recovery is due
+func (e *torrentErrorTracker) due(client *torrent.Client, now time.Time) []*torrent.Torrent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var due []*torrent.Torrent
+	for _, t := range client.Torrents() {
+		if te, ok := e.errors[t.InfoHash().HexString()]; ok && te.NextRetry != nil && !now.Before(*te.NextRetry) {
+			due = append(due, t)
+		}
+	}
+	return due
+}
+
+// retry tries to recover the torrent, and clears its error or backs off further
+func (e *torrentErrorTracker) retry(t *torrent.Torrent, now time.Time) {
+	ih := t.InfoHash().HexString()
+	e.mu.Lock()
+	te, ok := e.errors[ih]
+	e.mu.Unlock()
+	if !ok || te.retry == nil {
+		return
+	}
+	err := te.retry()
+	if err == nil {
+		e.clear(t)
+		return
+	}
+	e.mu.Lock()
+	te.Attempts++
+	te.Message = err.Error()
+	backoff := recoveryBackoff(te.Attempts)
+	next := now.Add(backoff)
+	te.NextRetry = &next
+	attempts := te.Attempts
+	e.mu.Unlock()
+	log.Printf("⚠️ %s is still failing after %d retries, retrying in %s: %v", t.Name(), attempts, backoff, err)
+}
+
+// recoveryBackoff doubles the wait after each failed retry, up to maxRecoveryBackoff
+func recoveryBackoff(attempts int) time.Duration {
+	backoff := minRecoveryBackoff
+	for range attempts {
+		if backoff *= 2; backoff >= maxRecoveryBackoff {
+			return maxRecoveryBackoff
+		}
+	}
+	return backoff
+}
+
+// recoverTorrents retries the recoveries of stopped torrents as they come due, until the
+// context is cancelled
+func (s *Seeder) recoverTorrents(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(recoveryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		for _, t := range s.torrentErrors.due(client, now) {
+			s.torrentErrors.retry(t, now)
+		}
+	}
+}
+
+// watchWriteErrors stops the torrent when writing its data fails, rather than the library's
+// default of silently no longer downloading it. It's retried once the directory can be written.
+func (s *Seeder) watchWriteErrors(t *torrent.Torrent) {
+	t.SetOnWriteChunkError(func(err error) {
+		dir := s.placement.Dir(t.InfoHash().HexString())
+		s.torrentErrors.Fail(t, errorIO, fmt.Errorf("writing data: %w", err), func() error { return checkWritable(dir) })
+	})
+}
+
+// checkWritable checks a file can be written in dir, which fails when it's full, read-only or
+// gone, like after a disk is unmounted
+func checkWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".distro-seed-write-check-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write([]byte{0}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,95 @@
+package distroseed
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTorrentErrorRecovery(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, dir, "image.iso", 32<<10)
+	meta.Announce = "http://127.0.0.1:1/announce"
+	tt, err := client.AddTorrent(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ih := tt.InfoHash().HexString()
+
+	fixed := false
+	retry := func() error {
+		if !fixed {
+			return errors.New("disk still gone")
+		}
+		return nil
+	}
+	if !s.torrentErrors.Fail(tt, errorIO, errors.New("writing data: input/output error"), retry) {
+		t.Fatal("failing reported no change")
+	}
+	if s.torrentErrors.Fail(tt, errorVerification, errors.New("another"), nil) {
+		t.Error("failing twice reported a change")
+	}
+	if state := s.torrentState(tt); state != "error" || !s.pauses.IsPaused(ih) {
+		t.Errorf("state = %s, paused %v, want a paused torrent in the error state", state, s.pauses.IsPaused(ih))
+	}
+	mi := tt.Metainfo()
+	if trackers := mi.UpvertedAnnounceList().DistinctValues(); len(trackers) != 0 {
+		t.Errorf("torrent in the error state still announces to %v", trackers)
+	}
+
+	// Resuming through the API doesn't let a failed torrent go
+	if s.pauses.Resume(tt) {
+		t.Error("resuming a torrent that's only stopped by an error reported a change")
+	}
+	if !s.pauses.Pause(tt) || !s.pauses.Resume(tt) || !s.pauses.IsPaused(ih) {
+		t.Error("pausing and resuming a failed torrent let it go")
+	}
+
+	now := time.Now()
+	s.torrentErrors.retry(tt, now)
+	te, ok := s.torrentErrors.Error(ih)
+	if !ok || te.Attempts != 1 || te.Message != "disk still gone" || !te.NextRetry.Equal(now.Add(2*minRecoveryBackoff)) {
+		t.Fatalf("after a failed retry: %+v", te)
+	}
+	if due := s.torrentErrors.due(client, now.Add(minRecoveryBackoff)); len(due) != 0 {
+		t.Error("retry is due before its backoff")
+	}
+
+	fixed = true
+	if due := s.torrentErrors.due(client, now.Add(2*minRecoveryBackoff)); len(due) != 1 {
+		t.Fatal("retry isn't due after its backoff")
+	}
+	s.torrentErrors.retry(tt, now.Add(2*minRecoveryBackoff))
+	if s.torrentErrors.Failed(ih) || s.pauses.IsPaused(ih) {
+		t.Error("torrent is still stopped after recovering")
+	}
+	mi = tt.Metainfo()
+	if trackers := mi.UpvertedAnnounceList().DistinctValues(); !slices.Equal(trackers, []string{meta.Announce}) {
+		t.Errorf("trackers after recovering = %v", trackers)
+	}
+}
+
+func TestRecoveryBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{0: time.Minute, 1: 2 * time.Minute, 3: 8 * time.Minute, 6: time.Hour, 100: time.Hour} {
+		if got := recoveryBackoff(attempts); got != want {
+			t.Errorf("backoff after %d attempts = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestCheckWritable(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkWritable(dir); err != nil {
+		t.Errorf("writable directory: %v", err)
+	}
+	if err := checkWritable(filepath.Join(dir, "unmounted")); err == nil {
+		t.Error("missing directory is writable")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, "*")); len(matches) != 0 {
+		t.Errorf("check left %v behind", matches)
+	}
+}
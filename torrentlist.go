@@ -0,0 +1,193 @@
+package distroseed
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/api"
+)
+
+// The states torrents are listed in, as torrentState describes them
+var torrentStates = []string{"downloading", "seeding", "paused", "queued", "missing data", "waiting for metadata", "error"}
+
+// torrentListing is a torrent as /api/torrents lists it
+type torrentListing struct {
+	InfoHash       string        `json:"infohash"`
+	Name           string        `json:"name"`
+	State          string        `json:"state"`
+	Size           int64         `json:"size"`      // Bytes, 0 until metadata is known
+	Completed      int64         `json:"completed"` // Bytes downloaded and verified
+	Uploaded       int64         `json:"uploaded"`  // Bytes across all runs
+	Ratio          float64       `json:"ratio"`     // Uploaded as a multiple of the size
+	Peers          int           `json:"peers"`
+	UploadRate     int64         `json:"upload_rate"`                // Bytes per second, averaged over the last minute
+	CompletedAt    *time.Time    `json:"completed_at,omitempty"`     // When we finished downloading it
+	TimeToComplete *duration     `json:"time_to_complete,omitempty"` // From being added to being downloaded
+	Labels         []string      `json:"labels,omitempty"`
+	Release        *releaseInfo  `json:"release,omitempty"` // What its name says, like the distro and version
+	Sources        []string      `json:"sources"`
+	Dir            string        `json:"dir,omitempty"`
+	Error          *torrentError `json:"error,omitempty"`
+}
+
+func (s *Seeder) listTorrent(t *torrent.Torrent) torrentListing {
+	ih := t.InfoHash().HexString()
+	l := torrentListing{
+		InfoHash:   ih,
+		Name:       t.Name(),
+		State:      s.torrentState(t),
+		Uploaded:   s.ledger.Lifetime(ih),
+		Peers:      len(t.PeerConns()),
+		UploadRate: s.rates.Torrent(ih).Upload1m,
+		Labels:     s.optionsOf(t).Labels,
+		Sources:    s.torrentSources.URLs(t),
+	}
+	if te, ok := s.torrentErrors.Error(ih); ok {
+		l.Error = &te
+	}
+	if release := releaseOf(l.Name, l.Labels); release != (releaseInfo{}) {
+		l.Release = &release
+	}
+	if s.completions != nil {
+		if at, ok := s.completions.CompletedAt(ih); ok {
+			l.CompletedAt = &at
+		}
+		if took, ok := s.completions.TimeToComplete(ih); ok {
+			l.TimeToComplete = (*duration)(&took)
+		}
+	}
+	if t.Info() != nil {
+		l.Size, l.Completed = t.Length(), t.BytesCompleted()
+		l.Dir = s.placement.Dir(ih)
+		if l.Size > 0 {
+			l.Ratio = float64(l.Uploaded) / float64(l.Size)
+		}
+	}
+	return l
+}
+
+// torrentQuery filters, sorts and pages the torrent list
+type torrentQuery struct {
+	states             []string
+	label, name        string
+	distro             string
+	minRatio, maxRatio float64 // Negative for no bound
+	sort               string
+	descending         bool
+	offset, limit      int // A limit of 0 lists them all
+}
+
+// The fields the list can be sorted by
+var torrentSorts = map[string]func(a, b torrentListing) int{
+	"name": func(a, b torrentListing) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	},
+	"state":       func(a, b torrentListing) int { return strings.Compare(a.State, b.State) },
+	"size":        func(a, b torrentListing) int { return cmp.Compare(a.Size, b.Size) },
+	"uploaded":    func(a, b torrentListing) int { return cmp.Compare(a.Uploaded, b.Uploaded) },
+	"ratio":       func(a, b torrentListing) int { return cmp.Compare(a.Ratio, b.Ratio) },
+	"peers":       func(a, b torrentListing) int { return cmp.Compare(a.Peers, b.Peers) },
+	"upload_rate": func(a, b torrentListing) int { return cmp.Compare(a.UploadRate, b.UploadRate) },
+	"version": func(a, b torrentListing) int {
+		return cmp.Or(strings.Compare(a.Release.distro(), b.Release.distro()), compareVersions(a.Release.version(), b.Release.version()))
+	},
+}
+
+// parseTorrentQuery reads ?state=seeding,paused&label=&name=&distro=&min_ratio=&max_ratio=,
+// sorted by ?sort=field, or -field for descending, and paged by ?offset= and ?limit=
+func parseTorrentQuery(values url.Values) (torrentQuery, error) {
+	q := torrentQuery{
+		label: values.Get("label"), name: strings.ToLower(values.Get("name")), distro: strings.ToLower(values.Get("distro")),
+		minRatio: -1, maxRatio: -1, sort: "name",
+	}
+	if s := values.Get("state"); s != "" {
+		for _, state := range strings.Split(s, ",") {
+			state = strings.NewReplacer("_", " ", "-", " ").Replace(strings.TrimSpace(state))
+			if !slices.Contains(torrentStates, state) {
+				return q, fmt.Errorf("unknown state '%s', use %s", state, strings.Join(torrentStates, ", "))
+			}
+			q.states = append(q.states, state)
+		}
+	}
+	for param, bound := range map[string]*float64{"min_ratio": &q.minRatio, "max_ratio": &q.maxRatio} {
+		if s := values.Get(param); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil || v < 0 {
+				return q, fmt.Errorf("%s must be a number that isn't negative, got '%s'", param, s)
+			}
+			*bound = v
+		}
+	}
+	if s := values.Get("sort"); s != "" {
+		q.sort, q.descending = strings.CutPrefix(s, "-")
+		if _, ok := torrentSorts[q.sort]; !ok {
+			return q, fmt.Errorf("can't sort by '%s', use %s", q.sort, strings.Join(slices.Sorted(maps.Keys(torrentSorts)), ", "))
+		}
+	}
+	for param, n := range map[string]*int{"offset": &q.offset, "limit": &q.limit} {
+		if s := values.Get(param); s != "" {
+			v, err := strconv.Atoi(s)
+			if err != nil || v < 0 {
+				return q, fmt.Errorf("%s must be a number that isn't negative, got '%s'", param, s)
+			}
+			*n = v
+		}
+	}
+	return q, nil
+}
+
+func (q torrentQuery) matches(l torrentListing) bool {
+	return (len(q.states) == 0 || slices.Contains(q.states, l.State)) &&
+		(q.label == "" || slices.Contains(l.Labels, q.label)) &&
+		(q.name == "" || strings.Contains(strings.ToLower(l.Name), q.name)) &&
+		(q.distro == "" || l.Release.distro() == q.distro) &&
+		(q.minRatio < 0 || l.Ratio >= q.minRatio) &&
+		(q.maxRatio < 0 || l.Ratio <= q.maxRatio)
+}
+
+// apply returns the page of matching torrents, and how many match in all
+func (q torrentQuery) apply(listings []torrentListing) (page []torrentListing, total int) {
+	matching := slices.DeleteFunc(listings, func(l torrentListing) bool { return !q.matches(l) })
+	byField := torrentSorts[q.sort]
+	slices.SortFunc(matching, func(a, b torrentListing) int {
+		c := byField(a, b)
+		if q.descending {
+			c = -c
+		}
+		return cmp.Or(c, strings.Compare(a.InfoHash, b.InfoHash))
+	})
+	total = len(matching)
+	start := min(q.offset, total)
+	end := total
+	if q.limit > 0 {
+		end = min(start+q.limit, total)
+	}
+	return matching[start:end], total
+}
+
+// List the torrents, filtered, sorted and paged as the query asks
+func (a *apiServer) getTorrents(w http.ResponseWriter, r *http.Request) {
+	q, err := parseTorrentQuery(r.URL.Query())
+	if err != nil {
+		api.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	var listings []torrentListing
+	for _, t := range a.client.Torrents() {
+		listings = append(listings, a.seeder.listTorrent(t))
+	}
+	page, total := q.apply(listings)
+	api.WriteJSON(w, http.StatusOK, struct {
+		Total    int              `json:"total"` // Matching torrents, across all pages
+		Offset   int              `json:"offset"`
+		Torrents []torrentListing `json:"torrents"`
+	}{total, q.offset, append([]torrentListing{}, page...)})
+}
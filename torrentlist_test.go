@@ -0,0 +1,109 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestTorrentQuery(t *testing.T) {
+	listings := []torrentListing{
+		{InfoHash: "1", Name: "ubuntu-24.10-desktop", State: "seeding", Size: 300, Ratio: 2, Labels: []string{"ubuntu"}, Release: &releaseInfo{Distro: "ubuntu", Version: "24.10"}},
+		{InfoHash: "2", Name: "Ubuntu-24.10-server", State: "paused", Size: 100, Ratio: 0.5, Labels: []string{"ubuntu"}, Release: &releaseInfo{Distro: "ubuntu", Version: "24.04"}},
+		{InfoHash: "3", Name: "debian-12", State: "downloading", Size: 200, Ratio: 0, Release: &releaseInfo{Distro: "debian", Version: "12"}},
+		{InfoHash: "4", Name: "fedora-41", State: "missing data", Size: 400, Ratio: 1},
+	}
+	names := func(page []torrentListing) []string {
+		var names []string
+		for _, l := range page {
+			names = append(names, l.Name)
+		}
+		return names
+	}
+
+	for query, want := range map[string][]string{
+		"":                           {"debian-12", "fedora-41", "ubuntu-24.10-desktop", "Ubuntu-24.10-server"},
+		"state=seeding,paused":       {"ubuntu-24.10-desktop", "Ubuntu-24.10-server"},
+		"state=missing-data":         {"fedora-41"},
+		"label=ubuntu&sort=-size":    {"ubuntu-24.10-desktop", "Ubuntu-24.10-server"},
+		"name=UBUNTU&max_ratio=1":    {"Ubuntu-24.10-server"},
+		"min_ratio=1&sort=ratio":     {"fedora-41", "ubuntu-24.10-desktop"},
+		"sort=size&offset=1&limit=2": {"debian-12", "ubuntu-24.10-desktop"},
+		"offset=10":                  nil,
+		"distro=Ubuntu&sort=version": {"Ubuntu-24.10-server", "ubuntu-24.10-desktop"},
+		"sort=-version":              {"ubuntu-24.10-desktop", "Ubuntu-24.10-server", "debian-12", "fedora-41"},
+	} {
+		values, _ := url.ParseQuery(query)
+		q, err := parseTorrentQuery(values)
+		if err != nil {
+			t.Errorf("%q: %v", query, err)
+			continue
+		}
+		page, _ := q.apply(slices.Clone(listings))
+		if got := names(page); !slices.Equal(got, want) {
+			t.Errorf("%q = %v, want %v", query, got, want)
+		}
+	}
+
+	q, _ := parseTorrentQuery(url.Values{"limit": {"1"}, "state": {"seeding,paused"}})
+	if page, total := q.apply(slices.Clone(listings)); len(page) != 1 || total != 2 {
+		t.Errorf("limited page has %d torrents of %d, want 1 of 2", len(page), total)
+	}
+
+	for _, query := range []string{"state=stopped", "min_ratio=-1", "max_ratio=x", "sort=color", "limit=-5", "offset=a"} {
+		values, _ := url.ParseQuery(query)
+		if _, err := parseTorrentQuery(values); err == nil {
+			t.Errorf("%q was accepted", query)
+		}
+	}
+}
+
+func TestGetTorrents(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent", "b.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu"}}}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	api := &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir}
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	b := addSeedingTestTorrent(t, client, dir, "b.iso")
+	s.torrentSources.Add("a.torrent", a)
+	s.torrentSources.Add("b.torrent", b)
+	s.pauses.Pause(b)
+
+	type reply struct {
+		Total    int              `json:"total"`
+		Torrents []torrentListing `json:"torrents"`
+	}
+	list := func(query string) (int, reply) {
+		w := httptest.NewRecorder()
+		api.handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/torrents?"+query, nil))
+		var got reply
+		json.NewDecoder(w.Body).Decode(&got)
+		return w.Code, got
+	}
+
+	code, got := list("")
+	if code != http.StatusOK || got.Total != 2 || len(got.Torrents) != 2 || got.Torrents[0].Name != "a.iso" {
+		t.Fatalf("list all: %d %+v", code, got)
+	}
+	if l := got.Torrents[0]; l.State != "seeding" || l.Size == 0 || l.Completed != l.Size || !slices.Equal(l.Labels, []string{"ubuntu"}) || !slices.Equal(l.Sources, []string{"a.torrent"}) || l.Dir != dir {
+		t.Errorf("listing of a.iso = %+v", l)
+	}
+
+	if code, got = list("state=paused"); code != http.StatusOK || got.Total != 1 || got.Torrents[0].InfoHash != b.InfoHash().HexString() {
+		t.Errorf("paused torrents: %d %+v", code, got)
+	}
+	if code, got = list("label=ubuntu&state=paused"); code != http.StatusOK || got.Total != 0 || got.Torrents == nil {
+		t.Errorf("paused ubuntu torrents: %d %+v", code, got)
+	}
+	if code, _ = list("sort=color"); code != http.StatusBadRequest {
+		t.Errorf("unknown sort field: %d", code)
+	}
+}
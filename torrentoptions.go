@@ -0,0 +1,401 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+	"golang.org/x/net/http/httpguts"
+)
+
+const (
+	selectionCheckInterval = 5 * time.Second // How often a partial download is checked for completion
+	ratioCheckInterval     = time.Minute
+)
+
+// torrentOptions are the settings for the torrents added from one source, like qBittorrent's add
+// form. They're given under torrent_options in the config file, or with the torrent when it's
+// added through the API.
+type torrentOptions struct {
+	Announce    string            `json:"announce,omitempty"`     // Announced to instead of the torrent's own trackers, like a private tracker's URL with a passkey
+	Trackers    []string          `json:"trackers,omitempty"`     // Announced to on top of the torrent's own
+	WebSeeds    []string          `json:"webseeds,omitempty"`     // HTTP mirrors pieces are also downloaded from
+	PEX         *bool             `json:"pex,omitempty"`          // false keeps the torrent's peers out of peer exchange
+	Strategy    string            `json:"strategy,omitempty"`     // Upload strategy, instead of -upload-strategy
+	Files       []string          `json:"files,omitempty"`        // Paths of the files to download and seed, all if empty
+	UploadLimit int64             `json:"upload_limit,omitempty"` // KiB/s for each torrent, 0 for unlimited
+	Weight      float64           `json:"weight,omitempty"`       // Share of the upload limit relative to other torrents, 1 if unset
+	Labels      []string          `json:"labels,omitempty"`
+	RatioTarget float64           `json:"ratio_target,omitempty"` // Times its size uploaded before the source is removed, 0 to seed forever
+	Export      string            `json:"export,omitempty"`       // Path template the downloaded files are exported to
+	ExportMode  string            `json:"export_mode,omitempty"`  // copy, the default, or hardlink
+	Username    string            `json:"username,omitempty"`     // HTTP basic auth for fetching the source's .torrent or metalink file
+	Password    string            `json:"password,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"` // Sent when fetching the source's .torrent or metalink file, like an auth token
+}
+
+func (o torrentOptions) validate(source string) error {
+	if o.Announce != "" {
+		if u, err := url.Parse(o.Announce); err != nil || !slices.Contains([]string{"http", "https", "udp"}, u.Scheme) || u.Host == "" {
+			return fmt.Errorf("❌ Invalid announce URL for torrent %s", source)
+		}
+	}
+	for _, tracker := range o.Trackers {
+		if u, err := url.Parse(tracker); err != nil || !slices.Contains([]string{"http", "https", "udp", "ws", "wss"}, u.Scheme) || u.Host == "" {
+			return fmt.Errorf("❌ Invalid tracker '%s' for torrent %s", tracker, source)
+		}
+	}
+	for _, webSeed := range o.WebSeeds {
+		if u, err := url.Parse(webSeed); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("❌ Invalid webseed '%s' for torrent %s", webSeed, source)
+		}
+	}
+	if slices.Contains(o.Files, "") || slices.Contains(o.Labels, "") {
+		return fmt.Errorf("❌ Empty file or label for torrent %s", source)
+	}
+	if err := validateStrategy(o.Strategy); err != nil {
+		return fmt.Errorf("❌ Invalid strategy for torrent %s: %w", source, err)
+	}
+	if o.UploadLimit < 0 {
+		return fmt.Errorf("❌ Upload limit for torrent %s can't be negative", source)
+	}
+	if o.Weight < 0 {
+		return fmt.Errorf("❌ Weight for torrent %s can't be negative", source)
+	}
+	if o.RatioTarget < 0 {
+		return fmt.Errorf("❌ Ratio target for torrent %s can't be negative", source)
+	}
+	if err := validateExport(o.Export, o.ExportMode); err != nil {
+		return fmt.Errorf("❌ Invalid export for torrent %s: %w", source, err)
+	}
+	if o.Password != "" && o.Username == "" {
+		return fmt.Errorf("❌ Password without a username for torrent %s", source)
+	}
+	for name, value := range o.Headers {
+		if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+			return fmt.Errorf("❌ Invalid header '%s' for torrent %s", name, source)
+		}
+	}
+	return nil
+}
+
+func (o torrentOptions) isZero() bool {
+	return o.Announce == "" && len(o.Trackers) == 0 && o.PEX == nil && o.Strategy == "" && len(o.WebSeeds) == 0 && len(o.Files) == 0 && len(o.Labels) == 0 && o.UploadLimit == 0 && o.Weight == 0 &&
+		o.RatioTarget == 0 && o.Export == "" && o.ExportMode == "" && o.Username == "" && o.Password == "" && len(o.Headers) == 0
+}
+
+func (o torrentOptions) String() string {
+	if o.isZero() {
+		return "none"
+	}
+	o = o.redacted()
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+// What secrets are replaced with when shown
+const redactedValue = "***"
+
+// redacted returns the options with the passkey in the announce URL, the password and the
+// header values masked, for logs and the API
+func (o torrentOptions) redacted() torrentOptions {
+	if o.Announce != "" {
+		o.Announce = redactAnnounce(o.Announce)
+	}
+	if o.Password != "" {
+		o.Password = redactedValue
+	}
+	if len(o.Headers) > 0 {
+		redacted := make(map[string]string, len(o.Headers))
+		for name := range o.Headers {
+			redacted[name] = redactedValue
+		}
+		o.Headers = redacted
+	}
+	return o
+}
+
+// unredacted returns the options with values masked by redacted, as sent back by a client that
+// read them from the API, put back from prev
+func (o torrentOptions) unredacted(prev torrentOptions) torrentOptions {
+	if prev.Announce != "" && o.Announce == redactAnnounce(prev.Announce) {
+		o.Announce = prev.Announce
+	}
+	if o.Password == redactedValue {
+		o.Password = prev.Password
+	}
+	if len(o.Headers) > 0 {
+		headers := maps.Clone(o.Headers)
+		for name, value := range headers {
+			if prevValue, ok := prev.Headers[name]; ok && value == redactedValue {
+				headers[name] = prevValue
+			}
+		}
+		o.Headers = headers
+	}
+	return o
+}
+
+// redactAnnounce hides the path and query of an announce URL, where private trackers put the
+// passkey, so it stays out of logs
+func redactAnnounce(announce string) string {
+	u, err := url.Parse(announce)
+	if err != nil {
+		return "***"
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return announce
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// withTorrentSource returns the config with a torrent source added, along with the directory and
+// options given for it
+func (c runtimeConfig) withTorrentSource(source, dir string, opts torrentOptions) runtimeConfig {
+	next := c
+	next.TorrentURLs = append(slices.Clone(c.TorrentURLs), source)
+	if dir != "" {
+		next.TorrentDirs = cloneOrMake(c.TorrentDirs)
+		next.TorrentDirs[source] = dir
+	}
+	if !opts.isZero() {
+		next.TorrentOptions = cloneOrMake(c.TorrentOptions)
+		next.TorrentOptions[source] = opts
+	}
+	return next
+}
+
+func cloneOrMake[V any](m map[string]V) map[string]V {
+	clone := make(map[string]V, len(m)+1)
+	maps.Copy(clone, m)
+	return clone
+}
+
+// optionsOf merges the options of the sources a torrent was added from. Files are only selected
+// if every source selects some, the lowest upload limit and the highest weight win, peer exchange
+// is off if any source turns it off, and the first announce URL, strategy and export are used.
+func (s *Seeder) optionsOf(t *torrent.Torrent) torrentOptions {
+	options := s.liveSettings.Get().TorrentOptions
+	var merged torrentOptions
+	allFiles := false
+	for _, source := range s.torrentSources.URLs(t) {
+		o := options[source]
+		if merged.Announce == "" {
+			merged.Announce = o.Announce
+		}
+		if o.PEX != nil && (merged.PEX == nil || !*o.PEX) {
+			merged.PEX = o.PEX
+		}
+		merged.Trackers = appendMissing(merged.Trackers, o.Trackers...)
+		merged.WebSeeds = appendMissing(merged.WebSeeds, o.WebSeeds...)
+		merged.Labels = appendMissing(merged.Labels, o.Labels...)
+		merged.Files = appendMissing(merged.Files, o.Files...)
+		merged.UploadLimit = lowerLimit(merged.UploadLimit, o.UploadLimit)
+		merged.Weight = max(merged.Weight, o.Weight)
+		if merged.Strategy == "" {
+			merged.Strategy = o.Strategy
+		}
+		if merged.Export == "" {
+			merged.Export, merged.ExportMode = o.Export, o.ExportMode
+		}
+		allFiles = allFiles || len(o.Files) == 0
+	}
+	if allFiles {
+		merged.Files = nil
+	}
+	return merged
+}
+
+// applyToMeta swaps the torrent's trackers for the source's announce URL, adds the source's
+// trackers, as a tier after the torrent's own, and its webseeds to a torrent file before it's
+// added, so torrents held back from the swarm get them when they're let through
+func (o torrentOptions) applyToMeta(meta *metainfo.MetaInfo) {
+	if o.Announce != "" {
+		meta.Announce = o.Announce
+		meta.AnnounceList = nil
+	}
+	if len(o.Trackers) > 0 {
+		if len(meta.AnnounceList) == 0 && meta.Announce != "" {
+			meta.AnnounceList = metainfo.AnnounceList{{meta.Announce}}
+		}
+		meta.AnnounceList = append(meta.AnnounceList, o.Trackers)
+	}
+	meta.UrlList = appendMissing(meta.UrlList, o.WebSeeds...)
+}
+
+// credentials returns what the source's .torrent or metalink file is fetched with
+func (o torrentOptions) credentials() fetch.Credentials {
+	return fetch.Credentials{Username: o.Username, Password: o.Password, Headers: o.Headers}
+}
+
+// applyTorrentOptions adds the source's announce URL, trackers and webseeds to a torrent added
+// from a magnet link, which keeps the trackers of the link
+func (s *Seeder) applyTorrentOptions(t *torrent.Torrent, o torrentOptions) {
+	if o.Announce != "" {
+		s.addTrackers(t, [][]string{{o.Announce}})
+	}
+	if len(o.Trackers) > 0 {
+		s.addTrackers(t, [][]string{o.Trackers})
+	}
+	if len(o.WebSeeds) > 0 {
+		t.AddWebSeeds(o.WebSeeds)
+	}
+}
+
+// selectedFiles returns the torrent's files its sources select, nil for all of them. Files are
+// given by their path in the torrent, with or without the torrent's name in front.
+func (s *Seeder) selectedFiles(t *torrent.Torrent) []*torrent.File {
+	paths := s.optionsOf(t).Files
+	if len(paths) == 0 || t.Info() == nil {
+		return nil
+	}
+	var selected []*torrent.File
+	for _, f := range t.Files() {
+		if slices.Contains(paths, f.DisplayPath()) || slices.Contains(paths, f.Path()) {
+			selected = append(selected, f)
+		}
+	}
+	return selected
+}
+
+// downloadWanted downloads the files selected for the torrent, or all of it
+func (s *Seeder) downloadWanted(t *torrent.Torrent) {
+	if len(s.optionsOf(t).Files) == 0 {
+		t.DownloadAll()
+		return
+	}
+	selected := s.selectedFiles(t)
+	if len(selected) == 0 {
+		log.Printf("⚠️ None of the files selected are in %s, downloading all of it", t.Name())
+		t.DownloadAll()
+		return
+	}
+	for _, f := range t.Files() {
+		if slices.Contains(selected, f) {
+			f.Download()
+		} else {
+			f.SetPriority(torrent.PiecePriorityNone)
+		}
+	}
+	log.Printf("📂 Downloading %d of the %d files in %s", len(selected), len(t.Files()), t.Name())
+}
+
+// wantedBytes returns the size of the files selected for the torrent, or all of it, and how much
+// of that is downloaded
+func (s *Seeder) wantedBytes(t *torrent.Torrent) (size, completed int64) {
+	selected := s.selectedFiles(t)
+	if len(selected) == 0 {
+		return t.Length(), t.BytesCompleted()
+	}
+	for _, f := range selected {
+		size += f.Length()
+		completed += f.BytesCompleted()
+	}
+	return size, completed
+}
+
+// downloadComplete reports whether the files selected for the torrent, or all of it, are
+// downloaded, which is when it's seeding
+func (s *Seeder) downloadComplete(t *torrent.Torrent) bool {
+	if t.Complete().Bool() {
+		return true
+	}
+	if t.Info() == nil || len(s.selectedFiles(t)) == 0 {
+		return false
+	}
+	size, completed := s.wantedBytes(t)
+	return completed == size
+}
+
+// downloaded returns a channel that's closed once downloadComplete, which for torrents with
+// files selected is checked every few seconds until the context ends or the torrent is dropped
+func (s *Seeder) downloaded(ctx context.Context, t *torrent.Torrent) <-chan struct{} {
+	if len(s.selectedFiles(t)) == 0 {
+		return t.Complete().On()
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(selectionCheckInterval)
+		defer ticker.Stop()
+		for !s.downloadComplete(t) {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.Closed():
+				return
+			case <-ticker.C:
+			}
+		}
+		close(done)
+	}()
+	return done
+}
+
+// applyTorrentUploadLimits throttles the uploads of torrents whose sources limit them
+func (s *Seeder) applyTorrentUploadLimits(client *torrent.Client) {
+	limits := make(map[string]int64)
+	for _, t := range client.Torrents() {
+		if limit := s.optionsOf(t).UploadLimit; limit > 0 {
+			limits[t.InfoHash().HexString()] = limit
+		}
+	}
+	s.bandwidth.setTorrentLimits(limits)
+}
+
+// ratioTargetsReached returns the sources with a ratio target whose torrents have all uploaded
+// that many times their size, across all runs
+func (s *Seeder) ratioTargetsReached(cfg runtimeConfig, lifetime func(infoHash string) int64) []string {
+	var reached []string
+	for _, source := range cfg.TorrentURLs {
+		target := cfg.TorrentOptions[source].RatioTarget
+		ts := s.torrentSources.Torrents(source)
+		if target == 0 || len(ts) == 0 {
+			continue
+		}
+		if !slices.ContainsFunc(ts, func(t *torrent.Torrent) bool {
+			return t.Info() == nil || t.Length() == 0 || float64(lifetime(t.InfoHash().HexString()))/float64(t.Length()) < target
+		}) {
+			reached = append(reached, source)
+		}
+	}
+	return reached
+}
+
+// enforceRatioTargets removes the sources whose torrents reached their ratio target, like
+// removing them through the API, so they last until the next reload
+func (s *Seeder) enforceRatioTargets(ctx context.Context, client *torrent.Client, downloadDir string) {
+	ticker := time.NewTicker(ratioCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reached := s.ratioTargetsReached(s.liveSettings.Get(), s.ledger.Lifetime)
+		if len(reached) == 0 {
+			continue
+		}
+		for _, source := range reached {
+			log.Printf("🎯 %s reached its ratio target of %g, no longer seeding it", source, s.liveSettings.Get().TorrentOptions[source].RatioTarget)
+		}
+		_, err := s.updateConfig(ctx, client, downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+			next := current
+			next.TorrentURLs = slices.DeleteFunc(slices.Clone(current.TorrentURLs), func(source string) bool {
+				return slices.Contains(reached, source)
+			})
+			return next, nil
+		})
+		if err != nil {
+			log.Printf("⚠️ Could not remove torrents that reached their ratio target: %v", err)
+		}
+	}
+}
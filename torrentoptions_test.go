@@ -0,0 +1,165 @@
+package distroseed
+
+import (
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/fetch"
+)
+
+func TestTorrentOptionsValidate(t *testing.T) {
+	tests := []struct {
+		opts torrentOptions
+		ok   bool
+	}{
+		{torrentOptions{}, true},
+		{torrentOptions{Trackers: []string{"udp://tracker.example.com:6969", "https://tracker.example.com/announce"}, WebSeeds: []string{"https://mirror.example.com/"}}, true},
+		{torrentOptions{Trackers: []string{"tracker.example.com"}}, false},
+		{torrentOptions{WebSeeds: []string{"ftp://mirror.example.com/"}}, false},
+		{torrentOptions{Labels: []string{""}}, false},
+		{torrentOptions{UploadLimit: -1}, false},
+		{torrentOptions{RatioTarget: -0.5}, false},
+		{torrentOptions{Weight: 2.5}, true},
+		{torrentOptions{Weight: -1}, false},
+		{torrentOptions{Export: "/srv/isos/{label}/{name}", ExportMode: "hardlink"}, true},
+		{torrentOptions{Export: "isos"}, false},
+		{torrentOptions{Export: "/srv/isos/{version}"}, false},
+		{torrentOptions{Export: "/srv/isos", ExportMode: "move"}, false},
+		{torrentOptions{ExportMode: "copy"}, false},
+		{torrentOptions{Username: "mirror", Password: "secret", Headers: map[string]string{"X-Api-Key": "abc"}}, true},
+		{torrentOptions{Password: "secret"}, false},
+		{torrentOptions{Headers: map[string]string{"Bad Header": "abc"}}, false},
+		{torrentOptions{Headers: map[string]string{"X-Api-Key": "abc\r\nX-Other: 1"}}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.opts.validate("x.torrent"); (err == nil) != tt.ok {
+			t.Errorf("validate(%+v) = %v, want ok %v", tt.opts, err, tt.ok)
+		}
+	}
+}
+
+func TestFetchWithCredentials(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	meta := newTestMeta(t, t.TempDir(), "private.iso", 32<<10)
+	data, err := bencode.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "mirror" || pass != "secret" || r.Header.Get("X-Api-Key") != "abc" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	opts := torrentOptions{Username: "mirror", Password: "secret", Headers: map[string]string{"X-Api-Key": "abc"}}
+
+	if _, err := s.loadTorrentFile(t.Context(), srv.URL+"/private.torrent", t.TempDir(), fetch.Credentials{}); err == nil {
+		t.Error("loaded a torrent file without credentials")
+	}
+	if _, err := s.loadTorrentFile(t.Context(), srv.URL+"/private.torrent", t.TempDir(), opts.credentials()); err != nil {
+		t.Errorf("with credentials: %v", err)
+	}
+	if str := opts.String(); strings.Contains(str, "secret") || strings.Contains(str, "abc") {
+		t.Errorf("String() shows credentials: %s", str)
+	}
+}
+
+func TestApplyToMeta(t *testing.T) {
+	meta := &metainfo.MetaInfo{Announce: "http://tracker.example.com/announce"}
+	torrentOptions{Trackers: []string{"udp://backup.example.com:6969"}, WebSeeds: []string{"https://mirror.example.com/"}}.applyToMeta(meta)
+	want := metainfo.AnnounceList{{"http://tracker.example.com/announce"}, {"udp://backup.example.com:6969"}}
+	if !slices.EqualFunc(meta.AnnounceList, want, slices.Equal) {
+		t.Errorf("announce list = %v, want %v", meta.AnnounceList, want)
+	}
+	if !slices.Equal(meta.UrlList, []string{"https://mirror.example.com/"}) {
+		t.Errorf("webseeds = %v", meta.UrlList)
+	}
+}
+
+func TestSelectedFilesComplete(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentOptions = map[string]torrentOptions{"partial": {Files: []string{"b.iso"}}}
+	s := newTestSeeder(t, cfg)
+
+	// A torrent of two files, of which only b.iso is on disk
+	set := filepath.Join(dir, "set")
+	os.Mkdir(set, 0o755)
+	for _, name := range []string{"a.iso", "b.iso"} {
+		data := make([]byte, 64<<10)
+		rand.Read(data)
+		if err := os.WriteFile(filepath.Join(set, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info := metainfo.Info{PieceLength: 16 << 10}
+	if err := info.BuildFromFilePath(set); err != nil {
+		t.Fatal(err)
+	}
+	infoBytes, err := bencode.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(set, "a.iso"))
+	tt, err := client.AddTorrent(&metainfo.MetaInfo{InfoBytes: infoBytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tt.VerifyData()
+
+	s.torrentSources.Add("all", tt)
+	if s.downloadComplete(tt) {
+		t.Error("complete with a file missing and no files selected")
+	}
+	s.torrentSources.Add("partial", tt)
+	if s.downloadComplete(tt) {
+		t.Error("complete while another source wants all files")
+	}
+	s.torrentSources.Remove("all")
+	if !s.downloadComplete(tt) {
+		t.Error("not complete with the selected file on disk")
+	}
+	if size, completed := s.wantedBytes(tt); size != 64<<10 || completed != size {
+		t.Errorf("wanted bytes = %d of %d, want all of b.iso", completed, size)
+	}
+}
+
+func TestRatioTargetsReached(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"done.torrent", "seeding.torrent", "forever.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{
+		"done.torrent":    {RatioTarget: 2},
+		"seeding.torrent": {RatioTarget: 2},
+	}
+	s := newTestSeeder(t, cfg)
+
+	done := addSeedingTestTorrent(t, client, dir, "done.iso")
+	seeding := addSeedingTestTorrent(t, client, dir, "seeding.iso")
+	forever := addSeedingTestTorrent(t, client, dir, "forever.iso")
+	s.torrentSources.Add("done.torrent", done)
+	s.torrentSources.Add("seeding.torrent", seeding)
+	s.torrentSources.Add("forever.torrent", forever)
+
+	uploaded := map[string]int64{
+		done.InfoHash().HexString():    2 * done.Length(),
+		seeding.InfoHash().HexString(): seeding.Length(),
+		forever.InfoHash().HexString(): 10 * forever.Length(),
+	}
+	reached := s.ratioTargetsReached(s.liveSettings.Get(), func(ih string) int64 { return uploaded[ih] })
+	if !slices.Equal(reached, []string{"done.torrent"}) {
+		t.Errorf("reached = %v, want [done.torrent]", reached)
+	}
+}
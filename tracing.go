@@ -0,0 +1,78 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/anacrolix/torrent"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.30.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Spans of a torrent's lifecycle: adding it, fetching its metadata, verifying its data,
+// downloading it and announcing it. Without an OTLP endpoint they go nowhere.
+var tracer = otel.Tracer("github.com/pawl/distro-seed")
+
+// setupTracing exports spans to an OTLP/HTTP collector at a base URL like http://localhost:4318,
+// posting them to /v1/traces under it as OTEL_EXPORTER_OTLP_ENDPOINT is meant to be read. The
+// exporter also follows the other OTEL_EXPORTER_OTLP_* variables for headers and TLS. The
+// returned function flushes the spans not yet exported.
+func setupTracing(endpoint string) (func(context.Context) error, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("❌ Invalid OTLP endpoint '%s', use a URL like http://localhost:4318", endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	exporter, err := otlptracehttp.New(context.Background(), otlptracehttp.WithEndpointURL(u.String()))
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to set up trace export to %s: %w", endpoint, err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL,
+			semconv.ServiceName("distro-seed"),
+			semconv.ServiceVersion(buildInfo.Version),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// torrentAttributes identify the torrent a span is about
+func torrentAttributes(t *torrent.Torrent) trace.SpanStartOption {
+	attrs := []attribute.KeyValue{attribute.String("torrent.infohash", t.InfoHash().HexString())}
+	if t.Info() != nil {
+		attrs = append(attrs, attribute.String("torrent.name", t.Name()), attribute.Int64("torrent.size", t.Length()))
+	}
+	return trace.WithAttributes(attrs...)
+}
+
+// endSpan ends a span, marking it failed if there was an error
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// traceAdd starts the span of adding a torrent from a source
+func traceAdd(ctx context.Context, source string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "torrent.add", trace.WithAttributes(attribute.String("torrent.source", source)))
+}
+
+// endAdd ends the span of adding a torrent, with the torrent it added or the error adding it
+func endAdd(span trace.Span, t *torrent.Torrent, err error) {
+	if t != nil {
+		span.SetAttributes(attribute.String("torrent.infohash", t.InfoHash().HexString()))
+	}
+	endSpan(span, err)
+}
@@ -0,0 +1,73 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans has the tracer record spans for the test to check
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	prev := tracer
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)).Tracer("test")
+	t.Cleanup(func() { tracer = prev })
+	return recorder
+}
+
+func TestTracesVerification(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	recorder := recordSpans(t)
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(newTestMeta(t, dir, "a.iso", 64<<10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec.DisableInitialPieceCheck = true
+	tor, _, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := newVerifyQueue(s, 1, false)
+	q.hold(tor.InfoHash().HexString())
+
+	ctx, add := traceAdd(context.Background(), "a.torrent")
+	endAdd(add, tor, nil)
+	if !q.verify(ctx, tor) {
+		t.Fatal("torrent wasn't verified")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 || spans[1].Name() != "torrent.verify" {
+		t.Fatalf("recorded %d spans, want the add and the verification", len(spans))
+	}
+	verify := spans[1]
+	if verify.Parent().SpanID() != spans[0].SpanContext().SpanID() {
+		t.Error("verification isn't part of the torrent's trace")
+	}
+	if events := verify.Events(); len(events) != 1 || events[0].Name != "hashing" {
+		t.Errorf("verification events %v, want hashing", events)
+	}
+}
+
+func TestTracesFailedAdd(t *testing.T) {
+	recorder := recordSpans(t)
+	_, span := traceAdd(context.Background(), "http://example.com/missing.torrent")
+	endAdd(span, nil, errors.New("404 Not Found"))
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Errorf("failed add recorded as %+v", spans)
+	}
+}
+
+func TestSetupTracingEndpoint(t *testing.T) {
+	if _, err := setupTracing("localhost:4318"); err == nil {
+		t.Error("endpoint without a scheme is valid")
+	}
+}
@@ -0,0 +1,72 @@
+package distroseed
+
+import (
+	"context"
+	"log/slog"
+)
+
+// trackerLogHandler passes the client's log records on, picking out the results of tracker
+// announces, which the library only reports through its logger
+type trackerLogHandler struct {
+	seeder     *Seeder
+	next       slog.Handler
+	trackerURL string // Set on the loggers of tracker announcers
+	infoHash   string // Set on the loggers of torrents
+}
+
+func (h trackerLogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	// Successful announces are only logged at debug level
+	return h.trackerURL != "" || h.next.Enabled(ctx, level)
+}
+
+func (h trackerLogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.trackerURL != "" {
+		switch r.Message {
+		case "announce failed":
+			if ctx.Err() == nil && r.Level >= slog.LevelWarn {
+				var errText string
+				r.Attrs(func(a slog.Attr) bool {
+					if a.Key == "err" {
+						errText = a.Value.String()
+					}
+					return true
+				})
+				h.seeder.trackers.Record(h.trackerURL, errText)
+				h.seeder.events.announced(h.infoHash, h.trackerURL, errText)
+			}
+		case "announce returned":
+			h.seeder.trackers.Record(h.trackerURL, "")
+			h.seeder.events.announced(h.infoHash, h.trackerURL, "")
+		}
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h trackerLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	for _, a := range attrs {
+		switch a.Key {
+		case "urlKey":
+			h.trackerURL = a.Value.String()
+		case "torrent":
+			if a.Value.Kind() != slog.KindGroup {
+				break
+			}
+			// The library groups the torrent's name and infohash
+			for _, ta := range a.Value.Group() {
+				if ta.Key == "ih" {
+					h.infoHash = ta.Value.String()
+				}
+			}
+		}
+	}
+	h.next = h.next.WithAttrs(attrs)
+	return h
+}
+
+func (h trackerLogHandler) WithGroup(name string) slog.Handler {
+	h.next = h.next.WithGroup(name)
+	return h
+}
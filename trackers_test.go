@@ -0,0 +1,57 @@
+package distroseed
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/pawl/distro-seed/announce"
+)
+
+func TestTrackerLogHandlerRecordsAnnounces(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.trackers = announce.NewStatus()
+	logger := slog.New(trackerLogHandler{seeder: s, next: slog.DiscardHandler})
+	const url = "http://tracker.example.com/announce"
+	announcer := logger.With("urlKey", url)
+
+	announcer.Warn("announce failed", "err", errors.New("connection refused"))
+	announcer.Warn("announce failed", "err", errors.New("timeout"))
+	// Logged after shutting down, which isn't the tracker's fault
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	announcer.Log(ctx, slog.LevelWarn, "announce failed", "err", context.Canceled)
+	// Other loggers' records aren't announces
+	logger.Warn("announce failed", "err", errors.New("unrelated"))
+
+	health := s.trackers.Snapshot()
+	if len(health) != 1 || health[url].ConsecutiveFailures != 2 || health[url].LastError != "timeout" {
+		t.Fatalf("tracker health = %+v, want 2 failures ending in a timeout", health)
+	}
+
+	// Successes are only logged at debug level, but still count
+	announcer.Debug("announce returned")
+	if h := s.trackers.Snapshot()[url]; h.ConsecutiveFailures != 0 || h.LastError != "" || h.LastSuccess.IsZero() {
+		t.Errorf("tracker health after a success = %+v", h)
+	}
+}
+
+func TestTrackerLogHandlerRecordsTorrentEvents(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.trackers = announce.NewStatus()
+	s.events = loadEventLog(s, t.TempDir(), time.Now())
+
+	const ih = "0123456789abcdef0123456789abcdef01234567"
+	announcer := slog.New(trackerLogHandler{seeder: s, next: slog.DiscardHandler}).
+		With(slog.Group("torrent", "name", "a.iso", "ih", ih)).
+		With("urlKey", "http://tracker.example.com/announce")
+	announcer.Warn("announce failed", "err", context.DeadlineExceeded)
+	announcer.Debug("announce returned")
+
+	history, _ := s.events.Events(ih)
+	if len(history) != 2 || history[0].Kind != eventTrackerError || history[1].Kind != eventTrackerRecovered {
+		t.Errorf("events = %+v, want a tracker error and a recovery", history)
+	}
+}
@@ -0,0 +1,467 @@
+package distroseed
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/pawl/distro-seed/api"
+)
+
+const (
+	transmissionRPCPath     = "/transmission/rpc"
+	transmissionSessionID   = "X-Transmission-Session-Id"
+	transmissionRPCVersion  = 17 // Transmission 4.0
+	transmissionRPCMinimum  = 14
+	transmissionUnsupported = "method name not recognized"
+)
+
+// Transmission's torrent statuses
+const (
+	trStopped      = 0
+	trCheckWait    = 1
+	trCheck        = 2
+	trDownloadWait = 3
+	trDownload     = 4
+	trSeedWait     = 5
+	trSeed         = 6
+)
+
+// transmissionRPC serves a subset of Transmission's RPC protocol, so remote GUIs, monitoring
+// scripts and tools that already talk to Transmission can list, add, start, stop and remove
+// torrents. Changes go through the same configuration updates as the HTTP API, and last until
+// the next reload.
+type transmissionRPC struct {
+	seeder    *Seeder
+	api       *apiServer
+	sessionID string
+
+	mu     sync.Mutex
+	ids    map[string]int // Transmission's torrent ids by infohash, kept while running
+	nextID int
+}
+
+func newTransmissionRPC(s *Seeder, management *apiServer) *transmissionRPC {
+	id := make([]byte, 24)
+	rand.Read(id)
+	return &transmissionRPC{seeder: s, api: management, sessionID: hex.EncodeToString(id), ids: make(map[string]int), nextID: 1}
+}
+
+// serve serves the RPC until ctx is done. With a token set, requests must carry it, which
+// Transmission clients send as the basic auth password. With a TLS config it's served over TLS.
+func (tr *transmissionRPC) serve(ctx context.Context, l net.Listener, token string, tlsConfig *tls.Config) {
+	log.Printf("🌐 Transmission RPC listening on %s", l.Addr())
+	var handler http.Handler = tr
+	if token != "" {
+		handler = api.RequireToken(handler, token)
+	}
+	if err := api.Serve(ctx, l, handler, tlsConfig); err != nil {
+		log.Printf("⚠️ Transmission RPC stopped: %v", err)
+	}
+}
+
+type transmissionRequest struct {
+	Method    string          `json:"method"`
+	Arguments json.RawMessage `json:"arguments"`
+	Tag       any             `json:"tag,omitempty"`
+}
+
+type transmissionResponse struct {
+	Result    string         `json:"result"`
+	Arguments map[string]any `json:"arguments"`
+	Tag       any            `json:"tag,omitempty"`
+}
+
+// ServeHTTP answers RPC requests. Like Transmission, requests without the current session id
+// get a 409 with the id to send, which guards against cross-site requests.
+func (tr *transmissionRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != transmissionRPCPath {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set(transmissionSessionID, tr.sessionID)
+	if r.Header.Get(transmissionSessionID) != tr.sessionID {
+		http.Error(w, "Invalid session id", http.StatusConflict)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req transmissionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	args, err := tr.call(req.Method, req.Arguments)
+	resp := transmissionResponse{Result: "success", Arguments: args, Tag: req.Tag}
+	if err != nil {
+		resp.Result = err.Error()
+	}
+	if resp.Arguments == nil {
+		resp.Arguments = map[string]any{}
+	}
+	api.WriteJSON(w, http.StatusOK, resp)
+}
+
+func (tr *transmissionRPC) call(method string, raw json.RawMessage) (map[string]any, error) {
+	var args struct {
+		IDs             json.RawMessage `json:"ids"`
+		Fields          []string        `json:"fields"`
+		Filename        string          `json:"filename"`
+		Metainfo        string          `json:"metainfo"`
+		DownloadDir     string          `json:"download-dir"`
+		Paused          bool            `json:"paused"`
+		Labels          []string        `json:"labels"`
+		DeleteLocalData bool            `json:"delete-local-data"`
+
+		SpeedLimitUp          *int64 `json:"speed-limit-up"`
+		SpeedLimitUpEnabled   *bool  `json:"speed-limit-up-enabled"`
+		SpeedLimitDown        *int64 `json:"speed-limit-down"`
+		SpeedLimitDownEnabled *bool  `json:"speed-limit-down-enabled"`
+	}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &args); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+
+	switch method {
+	case "session-get":
+		return tr.session(), nil
+	case "session-set":
+		return nil, tr.setSession(args.SpeedLimitUp, args.SpeedLimitUpEnabled, args.SpeedLimitDown, args.SpeedLimitDownEnabled)
+	case "session-stats":
+		return tr.stats(), nil
+	case "torrent-get":
+		ts, err := tr.torrents(args.IDs)
+		if err != nil {
+			return nil, err
+		}
+		list := make([]map[string]any, 0, len(ts))
+		for _, t := range ts {
+			list = append(list, tr.describe(t, args.Fields))
+		}
+		return map[string]any{"torrents": list}, nil
+	case "torrent-add":
+		return tr.add(args.Filename, args.Metainfo, args.DownloadDir, args.Labels, args.Paused)
+	case "torrent-start", "torrent-start-now", "torrent-stop", "torrent-reannounce":
+		ts, err := tr.torrents(args.IDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range ts {
+			switch ih := t.InfoHash().HexString(); method {
+			case "torrent-stop":
+				tr.seeder.pauses.Pause(t)
+			case "torrent-reannounce":
+				if !tr.seeder.uploadOnly.Held(ih) && !tr.seeder.pauses.IsPaused(ih) && !tr.seeder.schedule.Paused() {
+					tr.seeder.announces.Request(tr.api.ctx, tr.api.client, t)
+				}
+			default:
+				tr.seeder.pauses.Resume(t)
+			}
+		}
+		return nil, nil
+	case "torrent-remove":
+		if args.DeleteLocalData {
+			return nil, errors.New("deleting local data isn't supported")
+		}
+		ts, err := tr.torrents(args.IDs)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]batchTorrent, len(ts))
+		if err := tr.api.removeSources(ts, results); err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if r.Error != "" {
+				return nil, fmt.Errorf("%s: %s", r.Name, r.Error)
+			}
+		}
+		return nil, nil
+	}
+	return nil, errors.New(transmissionUnsupported)
+}
+
+// id returns Transmission's id for a torrent, numbering torrents as they're first seen
+func (tr *transmissionRPC) id(infoHash string) int {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	id, ok := tr.ids[infoHash]
+	if !ok {
+		id = tr.nextID
+		tr.ids[infoHash] = id
+		tr.nextID++
+	}
+	return id
+}
+
+// torrents resolves the ids argument: all torrents if it's missing, "recently-active" for the
+// ones transferring, or an id, infohash or list of them
+func (tr *transmissionRPC) torrents(raw json.RawMessage) ([]*torrent.Torrent, error) {
+	all := tr.api.client.Torrents()
+	slices.SortFunc(all, func(a, b *torrent.Torrent) int {
+		return tr.id(a.InfoHash().HexString()) - tr.id(b.InfoHash().HexString())
+	})
+	if len(raw) == 0 {
+		return all, nil
+	}
+	var ids []any
+	var single any
+	if err := json.Unmarshal(raw, &single); err != nil {
+		return nil, fmt.Errorf("invalid ids: %v", err)
+	}
+	switch v := single.(type) {
+	case []any:
+		ids = v
+	case string:
+		if v == "recently-active" {
+			return slices.DeleteFunc(all, func(t *torrent.Torrent) bool {
+				r := tr.seeder.rates.Torrent(t.InfoHash().HexString())
+				return r.Upload1m == 0 && r.Download1m == 0
+			}), nil
+		}
+		ids = []any{v}
+	default:
+		ids = []any{v}
+	}
+	return slices.DeleteFunc(all, func(t *torrent.Torrent) bool {
+		ih := t.InfoHash().HexString()
+		id := float64(tr.id(ih))
+		return !slices.ContainsFunc(ids, func(want any) bool {
+			switch want := want.(type) {
+			case float64:
+				return want == id
+			case string:
+				return strings.EqualFold(want, ih)
+			}
+			return false
+		})
+	}), nil
+}
+
+// transmissionStatus is a torrent's status as Transmission numbers them
+func (s *Seeder) transmissionStatus(t *torrent.Torrent) int {
+	ih := t.InfoHash().HexString()
+	switch s.torrentState(t) {
+	case "error", "paused", "missing data":
+		return trStopped
+	case "queued":
+		if t.Info() != nil && s.downloadComplete(t) {
+			return trSeedWait
+		}
+		return trDownloadWait
+	case "seeding":
+		if s.uploadOnly.Held(ih) {
+			return trCheck
+		}
+		return trSeed
+	}
+	return trDownload
+}
+
+// describe returns the torrent's fields Transmission clients asked for, all the ones known if
+// none were
+func (tr *transmissionRPC) describe(t *torrent.Torrent, fields []string) map[string]any {
+	l := tr.seeder.listTorrent(t)
+	stats := t.Stats()
+	r := tr.seeder.rates.Torrent(l.InfoHash)
+	labels := l.Labels
+	if labels == nil {
+		labels = []string{} // Clients expect a list
+	}
+	d := map[string]any{
+		"id":                      tr.id(l.InfoHash),
+		"hashString":              l.InfoHash,
+		"name":                    l.Name,
+		"status":                  tr.seeder.transmissionStatus(t),
+		"error":                   0,
+		"errorString":             "",
+		"totalSize":               l.Size,
+		"sizeWhenDone":            l.Size,
+		"leftUntilDone":           l.Size - l.Completed,
+		"haveValid":               l.Completed,
+		"percentDone":             0.0,
+		"metadataPercentComplete": 0.0,
+		"rateUpload":              r.Upload,
+		"rateDownload":            r.Download,
+		"uploadedEver":            l.Uploaded,
+		"downloadedEver":          stats.BytesReadData.Int64(),
+		"uploadRatio":             l.Ratio,
+		"peersConnected":          l.Peers,
+		"eta":                     -1,
+		"downloadDir":             l.Dir,
+		"addedDate":               0,
+		"doneDate":                0,
+		"isFinished":              false,
+		"isStalled":               false,
+		"labels":                  labels,
+		"magnetLink":              torrentMagnet(t),
+	}
+	if t.Info() != nil {
+		d["metadataPercentComplete"] = 1.0
+		if l.Size > 0 {
+			d["percentDone"] = float64(l.Completed) / float64(l.Size)
+		}
+	}
+	if l.Error != nil {
+		d["error"] = 3 // Local error
+		d["errorString"] = l.Error.Message
+	}
+	if p, ok := tr.seeder.downloads.Progress(t); ok && p.ETA > 0 {
+		d["eta"] = int64(time.Duration(p.ETA).Seconds())
+	}
+	if tr.seeder.registry != nil {
+		if e, ok := tr.seeder.registry.Entry(l.InfoHash); ok {
+			d["addedDate"] = e.AddedAt.Unix()
+		}
+	}
+	if l.CompletedAt != nil {
+		d["doneDate"] = l.CompletedAt.Unix()
+	}
+	if len(fields) == 0 {
+		return d
+	}
+	picked := make(map[string]any, len(fields))
+	for _, f := range fields {
+		if v, ok := d[f]; ok {
+			picked[f] = v
+		}
+	}
+	return picked
+}
+
+// add adds a torrent from a URL, magnet link or path, or from the base64 of a .torrent file,
+// which is kept in the download directory to be added from
+func (tr *transmissionRPC) add(filename, metainfoB64, dir string, labels []string, paused bool) (map[string]any, error) {
+	url := filename
+	if metainfoB64 != "" {
+		data, err := base64.StdEncoding.DecodeString(metainfoB64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metainfo: %v", err)
+		}
+		if url, err = tr.api.keepTorrentFile(data); err != nil {
+			return nil, err
+		}
+	}
+	if url == "" {
+		return nil, errors.New("no filename or metainfo given")
+	}
+	t, duplicate, err := tr.api.addSource(url, dir, torrentOptions{Labels: labels})
+	if err != nil {
+		return nil, err
+	}
+	if paused {
+		tr.seeder.pauses.Pause(t)
+	}
+	ih := t.InfoHash().HexString()
+	added := map[string]any{"id": tr.id(ih), "name": t.Name(), "hashString": ih}
+	if duplicate {
+		return map[string]any{"torrent-duplicate": added}, nil
+	}
+	return map[string]any{"torrent-added": added}, nil
+}
+
+// session describes the seeder as Transmission's session settings
+func (tr *transmissionRPC) session() map[string]any {
+	cfg := tr.seeder.liveSettings.Get()
+	return map[string]any{
+		"version":                  "4.0.0 (" + buildInfo.clientName() + ")",
+		"rpc-version":              transmissionRPCVersion,
+		"rpc-version-minimum":      transmissionRPCMinimum,
+		"session-id":               tr.sessionID,
+		"download-dir":             tr.api.downloadDir,
+		"speed-limit-up":           cfg.UploadLimit,
+		"speed-limit-up-enabled":   cfg.UploadLimit > 0,
+		"speed-limit-down":         cfg.DownloadLimit,
+		"speed-limit-down-enabled": cfg.DownloadLimit > 0,
+		"alt-speed-enabled":        false,
+		"units": map[string]any{
+			"speed-units":  []string{"kB/s", "MB/s", "GB/s", "TB/s"},
+			"speed-bytes":  1024,
+			"size-units":   []string{"kB", "MB", "GB", "TB"},
+			"size-bytes":   1024,
+			"memory-units": []string{"KiB", "MiB", "GiB", "TiB"},
+			"memory-bytes": 1024,
+		},
+	}
+}
+
+// setSession changes the upload and download limits. Transmission keeps a limit while it's
+// disabled, but the seeder has no such setting, so disabling one sets it to unlimited.
+func (tr *transmissionRPC) setSession(up *int64, upEnabled *bool, down *int64, downEnabled *bool) error {
+	limit := func(current int64, value *int64, enabled *bool) int64 {
+		if value != nil {
+			current = *value
+		}
+		if enabled != nil && !*enabled {
+			current = 0
+		}
+		return current
+	}
+	_, err := tr.seeder.updateConfig(tr.api.ctx, tr.api.client, tr.api.downloadDir, false, func(current runtimeConfig) (runtimeConfig, error) {
+		next := current
+		next.UploadLimit = limit(current.UploadLimit, up, upEnabled)
+		next.DownloadLimit = limit(current.DownloadLimit, down, downEnabled)
+		return next, next.validate()
+	})
+	return err
+}
+
+// stats are Transmission's session statistics. The seeder doesn't keep counts across runs apart
+// from uploads, so the cumulative ones only count uploads from before this run.
+func (tr *transmissionRPC) stats() map[string]any {
+	torrents := tr.api.client.Torrents()
+	active, paused := 0, 0
+	var lifetime int64
+	for _, t := range torrents {
+		switch tr.seeder.transmissionStatus(t) {
+		case trStopped:
+			paused++
+		case trDownload, trSeed:
+			active++
+		}
+		lifetime += tr.seeder.ledger.Lifetime(t.InfoHash().HexString())
+	}
+	total := tr.seeder.rates.Total()
+	clientStats := tr.api.client.Stats()
+	uptime := int64(time.Since(processStarted).Seconds())
+	current := map[string]any{
+		"uploadedBytes":   clientStats.BytesWrittenData.Int64(),
+		"downloadedBytes": clientStats.BytesReadData.Int64(),
+		"filesAdded":      len(torrents),
+		"sessionCount":    1,
+		"secondsActive":   uptime,
+	}
+	cumulative := map[string]any{
+		"uploadedBytes":   max(lifetime, clientStats.BytesWrittenData.Int64()),
+		"downloadedBytes": clientStats.BytesReadData.Int64(),
+		"filesAdded":      len(torrents),
+		"sessionCount":    1,
+		"secondsActive":   uptime,
+	}
+	return map[string]any{
+		"activeTorrentCount": active,
+		"pausedTorrentCount": paused,
+		"torrentCount":       len(torrents),
+		"uploadSpeed":        total.Upload,
+		"downloadSpeed":      total.Download,
+		"current-stats":      current,
+		"cumulative-stats":   cumulative,
+	}
+}
@@ -0,0 +1,108 @@
+package distroseed
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestTransmissionRPC(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu"}}}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	s.torrentSources.Add("a.torrent", a)
+	rpc := newTransmissionRPC(s, &apiServer{seeder: s, ctx: context.Background(), client: client, downloadDir: dir})
+
+	call := func(body string) (int, transmissionResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, transmissionRPCPath, strings.NewReader(body))
+		r.Header.Set(transmissionSessionID, rpc.sessionID)
+		rpc.ServeHTTP(w, r)
+		var resp transmissionResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	// Clients learn the session id from a 409 first
+	w := httptest.NewRecorder()
+	rpc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, transmissionRPCPath, strings.NewReader(`{"method": "session-get"}`)))
+	if w.Code != http.StatusConflict || w.Header().Get(transmissionSessionID) != rpc.sessionID {
+		t.Fatalf("request without session id: status %d, id %q", w.Code, w.Header().Get(transmissionSessionID))
+	}
+
+	code, resp := call(`{"method": "torrent-get", "arguments": {"fields": ["id", "hashString", "name", "status", "percentDone", "labels"]}, "tag": 7}`)
+	if code != http.StatusOK || resp.Result != "success" || resp.Tag != 7.0 {
+		t.Fatalf("torrent-get: status %d, %+v", code, resp)
+	}
+	torrents := resp.Arguments["torrents"].([]any)
+	if len(torrents) != 1 {
+		t.Fatalf("torrent-get listed %d torrents", len(torrents))
+	}
+	got := torrents[0].(map[string]any)
+	if got["hashString"] != a.InfoHash().HexString() || got["name"] != "a.iso" || got["status"] != float64(trSeed) || got["percentDone"] != 1.0 || len(got) != 6 {
+		t.Errorf("torrent-get = %v", got)
+	}
+	if labels := got["labels"].([]any); len(labels) != 1 || labels[0] != "ubuntu" {
+		t.Errorf("labels = %v", labels)
+	}
+	id := got["id"].(float64)
+
+	if _, resp := call(fmt.Sprintf(`{"method": "torrent-stop", "arguments": {"ids": [%d]}}`, int(id))); resp.Result != "success" || !s.pauses.IsPaused(a.InfoHash().HexString()) {
+		t.Errorf("torrent-stop: %+v, paused %v", resp, s.pauses.IsPaused(a.InfoHash().HexString()))
+	}
+	if _, resp := call(`{"method": "torrent-get", "arguments": {"ids": "` + a.InfoHash().HexString() + `", "fields": ["status"]}}`); resp.Arguments["torrents"].([]any)[0].(map[string]any)["status"] != float64(trStopped) {
+		t.Errorf("status after torrent-stop = %v", resp.Arguments)
+	}
+	if _, resp := call(`{"method": "torrent-start", "arguments": {"ids": ["` + a.InfoHash().HexString() + `"]}}`); resp.Result != "success" || s.pauses.IsPaused(a.InfoHash().HexString()) {
+		t.Errorf("torrent-start: %+v", resp)
+	}
+
+	// Adding from the .torrent file's contents keeps a copy to add it from
+	meta := newTestMeta(t, dir, "b.iso", 32<<10)
+	var buf strings.Builder
+	meta.Write(&buf)
+	_, resp = call(`{"method": "torrent-add", "arguments": {"metainfo": "` + base64.StdEncoding.EncodeToString([]byte(buf.String())) + `", "labels": ["debian"]}}`)
+	waitForSeedTorrents(t, s)
+	added, ok := resp.Arguments["torrent-added"].(map[string]any)
+	if resp.Result != "success" || !ok || added["hashString"] != meta.HashInfoBytes().HexString() {
+		t.Fatalf("torrent-add: %+v", resp)
+	}
+	saved := filepath.Join(dir, importDirName, meta.HashInfoBytes().HexString()+".torrent")
+	if _, err := os.Stat(saved); err != nil {
+		t.Errorf("added torrent wasn't kept: %v", err)
+	}
+	if urls := s.liveSettings.Get().TorrentURLs; !slices.Contains(urls, saved) {
+		t.Errorf("torrents after torrent-add = %v", urls)
+	}
+
+	if _, resp := call(`{"method": "torrent-remove", "arguments": {"ids": ["` + a.InfoHash().HexString() + `"], "delete-local-data": true}}`); resp.Result == "success" {
+		t.Error("torrent-remove deleting data succeeded")
+	}
+	if _, resp := call(`{"method": "torrent-remove", "arguments": {"ids": ["` + a.InfoHash().HexString() + `"]}}`); resp.Result != "success" || slices.Contains(s.liveSettings.Get().TorrentURLs, "a.torrent") {
+		t.Errorf("torrent-remove: %+v, urls %v", resp, s.liveSettings.Get().TorrentURLs)
+	}
+
+	if _, resp := call(`{"method": "session-set", "arguments": {"speed-limit-up": 300, "speed-limit-up-enabled": true}}`); resp.Result != "success" || s.liveSettings.Get().UploadLimit != 300 {
+		t.Errorf("session-set: %+v, upload limit %d", resp, s.liveSettings.Get().UploadLimit)
+	}
+	if _, resp := call(`{"method": "session-get"}`); resp.Arguments["speed-limit-up"] != 300.0 || resp.Arguments["rpc-version"] != float64(transmissionRPCVersion) {
+		t.Errorf("session-get = %v", resp.Arguments)
+	}
+	if _, resp := call(`{"method": "blocklist-update"}`); resp.Result != transmissionUnsupported {
+		t.Errorf("unsupported method: %+v", resp)
+	}
+}
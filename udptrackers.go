@@ -0,0 +1,155 @@
+package distroseed
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/announce"
+)
+
+const udpFallbackInterval = time.Minute
+
+// udpPreference leaves the HTTP trackers of a torrent out of its announce list when the torrent
+// lists a UDP tracker on the same host, which answers the same announce in a single round trip.
+// The HTTP tracker is announced to after all if its UDP twin starts failing.
+type udpPreference struct {
+	seeder  *Seeder
+	mu      sync.Mutex
+	dropped map[string]map[string]string // Infohash to the HTTP trackers left out and their UDP twins
+}
+
+func newUDPPreference(s *Seeder) *udpPreference {
+	return &udpPreference{seeder: s, dropped: make(map[string]map[string]string)}
+}
+
+// filter returns the tiers without the HTTP trackers that have a working UDP tracker on the same
+// host anywhere in them, remembering the ones left out for the torrent
+func (p *udpPreference) filter(infoHash string, tiers [][]string) [][]string {
+	if p == nil || len(tiers) == 0 {
+		return tiers
+	}
+	udpHosts := make(map[string]string)
+	for _, tier := range tiers {
+		for _, tracker := range tier {
+			if u, err := url.Parse(tracker); err == nil && strings.EqualFold(u.Scheme, "udp") {
+				udpHosts[strings.ToLower(u.Hostname())] = tracker
+			}
+		}
+	}
+	if len(udpHosts) == 0 {
+		return tiers
+	}
+	health := p.seeder.trackers.Snapshot()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	filtered := make([][]string, 0, len(tiers))
+	for _, tier := range tiers {
+		tier = slices.DeleteFunc(slices.Clone(tier), func(tracker string) bool {
+			u, err := url.Parse(tracker)
+			if err != nil || (!strings.EqualFold(u.Scheme, "http") && !strings.EqualFold(u.Scheme, "https")) {
+				return false
+			}
+			twin, ok := udpHosts[strings.ToLower(u.Hostname())]
+			if !ok || p.seeder.udpFailing(twin, health) {
+				return false
+			}
+			if p.dropped[infoHash] == nil {
+				p.dropped[infoHash] = make(map[string]string)
+			}
+			p.dropped[infoHash][tracker] = twin
+			return true
+		})
+		if len(tier) > 0 {
+			filtered = append(filtered, tier)
+		}
+	}
+	return filtered
+}
+
+// filterMeta leaves the HTTP trackers with UDP twins out of a torrent's announce list
+func (p *udpPreference) filterMeta(meta *metainfo.MetaInfo) {
+	if p == nil {
+		return
+	}
+	if announceList := meta.UpvertedAnnounceList(); len(announceList) > 0 {
+		meta.AnnounceList = p.filter(meta.HashInfoBytes().HexString(), announceList)
+	}
+}
+
+// udpFailing reports whether enough announces to a UDP tracker failed in a row over every IP
+// family it was announced over, so its HTTP twin is needed after all
+func (s *Seeder) udpFailing(tracker string, health map[string]announce.Health) bool {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return false
+	}
+	announced := false
+	for _, scheme := range []string{"udp4", "udp6"} {
+		u.Scheme = scheme
+		h, ok := health[u.String()]
+		if !ok {
+			continue
+		}
+		announced = true
+		if h.ConsecutiveFailures < s.alerts.TrackerFailures {
+			return false
+		}
+	}
+	return announced
+}
+
+func (p *udpPreference) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(udpFallbackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.fallBack(client)
+		}
+	}
+}
+
+// fallBack adds back the HTTP trackers of the UDP trackers that are failing, and forgets the
+// torrents that were removed
+func (p *udpPreference) fallBack(client *torrent.Client) {
+	health := p.seeder.trackers.Snapshot()
+	restore := make(map[*torrent.Torrent][]string)
+	p.mu.Lock()
+	for ih, dropped := range p.dropped {
+		var hash metainfo.Hash
+		if err := hash.FromHexString(ih); err != nil {
+			delete(p.dropped, ih)
+			continue
+		}
+		t, ok := client.Torrent(hash)
+		if !ok {
+			delete(p.dropped, ih)
+			continue
+		}
+		for tracker, twin := range dropped {
+			if p.seeder.udpFailing(twin, health) {
+				restore[t] = append(restore[t], tracker)
+				delete(dropped, tracker)
+			}
+		}
+		if len(dropped) == 0 {
+			delete(p.dropped, ih)
+		}
+	}
+	p.mu.Unlock()
+
+	for t, http := range restore {
+		slices.Sort(http)
+		log.Printf("⚠️ UDP trackers of %s are failing, announcing to %s instead", t.Name(), strings.Join(http, ", "))
+		p.seeder.addTrackers(t, [][]string{http})
+	}
+}
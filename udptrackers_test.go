@@ -0,0 +1,135 @@
+package distroseed
+
+import (
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/pawl/distro-seed/announce"
+)
+
+// serveUDPTracker answers connects and announces like a UDP tracker (BEP 15), with no peers
+func serveUDPTracker(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if n < 16 {
+				continue
+			}
+			action, txID := binary.BigEndian.Uint32(buf[8:]), binary.BigEndian.Uint32(buf[12:])
+			resp := binary.BigEndian.AppendUint32(nil, action)
+			resp = binary.BigEndian.AppendUint32(resp, txID)
+			switch action {
+			case 0: // Connect
+				resp = binary.BigEndian.AppendUint64(resp, 0x1234)
+			case 1: // Announce: interval, leechers, seeders
+				resp = binary.BigEndian.AppendUint32(resp, 1800)
+				resp = binary.BigEndian.AppendUint32(resp, 0)
+				resp = binary.BigEndian.AppendUint32(resp, 1)
+			default:
+				continue
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return "udp://" + conn.LocalAddr().String() + "/announce"
+}
+
+func TestUDPTrackerAnnounce(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.trackers = announce.NewStatus()
+	tracker := serveUDPTracker(t)
+
+	dir := t.TempDir()
+	client := newTestClient(t, dir, func(cfg *torrent.ClientConfig) {
+		cfg.Slogger = slog.New(trackerLogHandler{seeder: s, next: slog.DiscardHandler})
+	})
+	mi := newTestMeta(t, dir, "a.iso", 32<<10)
+	mi.AnnounceList = metainfo.AnnounceList{{tracker}}
+	if _, err := client.AddTorrent(mi); err != nil {
+		t.Fatal(err)
+	}
+
+	// udp:// trackers are announced to over each IP family the client uses
+	want := "udp4" + tracker[len("udp"):]
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if h, ok := s.trackers.Snapshot()[want]; ok {
+			if h.Protocol != "udp4" || h.Announces != 1 || h.Failures != 0 || h.LastSuccess.IsZero() {
+				t.Errorf("tracker health = %+v, want a successful udp4 announce", h)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no announce to %s recorded: %+v", want, s.trackers.Snapshot())
+		}
+	}
+	if p := s.trackers.Protocols()["udp4"]; p.Trackers != 1 || p.Announces != 1 || p.Failures != 0 {
+		t.Errorf("udp4 totals = %+v", p)
+	}
+}
+
+func TestPreferUDPTrackers(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.trackers = announce.NewStatus()
+	s.preferUDP = newUDPPreference(s)
+
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	mi := newTestMeta(t, dir, "a.iso", 32<<10)
+	ih := mi.HashInfoBytes().HexString()
+	announceList := metainfo.AnnounceList{
+		{"https://tracker.example.com/announce", "udp://tracker.example.com:1337/announce"},
+		{"http://Tracker.example.com:6969/announce"},
+		{"http://other.example.org/announce"},
+	}
+	mi.AnnounceList = announceList
+	s.preferUDP.filterMeta(mi)
+	want := [][]string{{"udp://tracker.example.com:1337/announce"}, {"http://other.example.org/announce"}}
+	if !slices.EqualFunc(mi.AnnounceList, want, slices.Equal) {
+		t.Fatalf("announce list = %v, want %v", mi.AnnounceList, want)
+	}
+	tt, err := client.AddTorrent(mi)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The HTTP trackers are announced to once the UDP tracker fails over every IP family
+	for range s.alerts.TrackerFailures {
+		s.trackers.Record("udp4://tracker.example.com:1337/announce", "timeout")
+	}
+	s.trackers.Record("udp6://tracker.example.com:1337/announce", "")
+	s.preferUDP.fallBack(client)
+	if got := slices.Concat(tt.Metainfo().AnnounceList...); slices.Contains(got, "https://tracker.example.com/announce") {
+		t.Fatalf("announce list while IPv6 works = %v", got)
+	}
+	for range s.alerts.TrackerFailures {
+		s.trackers.Record("udp6://tracker.example.com:1337/announce", "timeout")
+	}
+	s.preferUDP.fallBack(client)
+	if got := slices.Concat(tt.Metainfo().AnnounceList...); !slices.Contains(got, "http://Tracker.example.com:6969/announce") || !slices.Contains(got, "https://tracker.example.com/announce") {
+		t.Errorf("announce list after the UDP tracker failed = %v", got)
+	}
+	if _, ok := s.preferUDP.dropped[ih]; ok {
+		t.Error("restored trackers are still remembered as left out")
+	}
+
+	// Adding the trackers again leaves the HTTP ones in while the UDP tracker is failing
+	if got := s.preferUDP.filter(ih, announceList); len(got) != 3 {
+		t.Errorf("filtered while the UDP tracker is failing = %v", got)
+	}
+}
@@ -0,0 +1,240 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const (
+	upgradeEnv       = "DISTRO_SEED_UPGRADE"    // Set in the environment of a freshly exec'd binary
+	listenFDsEnv     = "DISTRO_SEED_LISTEN_FDS" // Comma-separated listener names, in fd order starting at 3
+	handoverFileName = ".handover.json"
+	firstInheritedFD = 3
+)
+
+// handoverState is what an upgrading process passes on to its replacement.
+type handoverState struct {
+	PeerID   []byte            `json:"peer_id"`
+	Session  string            `json:"session,omitempty"` // Upload accounting session, see stats.Ledger
+	Torrents []handoverTorrent `json:"torrents"`
+}
+
+type handoverTorrent struct {
+	InfoHash string `json:"info_hash"`
+	MetaInfo []byte `json:"metainfo,omitempty"` // Bencoded, empty if metadata was never retrieved
+	Uploaded int64  `json:"uploaded"`           // Bytes uploaded since the seeder was started
+	Queued   string `json:"queued,omitempty"`   // What the torrent was queued for, if it was
+}
+
+// sessionUploaded returns the bytes the torrent uploaded since the seeder was started, across
+// upgrades
+func (s *Seeder) sessionUploaded(t *torrent.Torrent) int64 {
+	stats := t.Stats()
+	return s.inheritedUploads[t.InfoHash().HexString()] + stats.BytesWrittenData.Int64()
+}
+
+func isUpgradeChild() bool {
+	return os.Getenv(upgradeEnv) != ""
+}
+
+// inheritedListeners are the listeners the previous process handed over during an upgrade,
+// keyed by name. Each is taken once, by the seeder the command runs.
+type inheritedListeners struct {
+	mu        sync.Mutex
+	listeners map[string]net.Listener
+}
+
+func loadInheritedListeners() *inheritedListeners {
+	inherited := &inheritedListeners{listeners: make(map[string]net.Listener)}
+	names := os.Getenv(listenFDsEnv)
+	if names == "" {
+		return inherited
+	}
+	for i, name := range strings.Split(names, ",") {
+		file := os.NewFile(uintptr(firstInheritedFD+i), name)
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			log.Printf("⚠️ Could not inherit '%s' listener: %v", name, err)
+			continue
+		}
+		inherited.listeners[name] = l
+	}
+	return inherited
+}
+
+// has reports whether a listener was handed over under name and not taken yet
+func (l *inheritedListeners) has(name string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.listeners[name]
+	return ok
+}
+
+// take returns the listener handed over under name, if it wasn't taken already
+func (l *inheritedListeners) take(name string) (net.Listener, bool) {
+	if l == nil {
+		return nil, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	listener, ok := l.listeners[name]
+	delete(l.listeners, name)
+	return listener, ok
+}
+
+// listenOrInherit returns the listener handed over by the previous process under name, or
+// opens a new one.
+func (s *Seeder) listenOrInherit(name, network, addr string) (net.Listener, error) {
+	if l, ok := s.inherited.take(name); ok {
+		log.Printf("♻️ Inherited %s listener on %s", name, l.Addr())
+		return l, nil
+	}
+	return net.Listen(network, addr)
+}
+
+func (s *Seeder) saveHandoverState(client *torrent.Client, downloadDir string) error {
+	peerID := client.PeerID()
+	state := handoverState{PeerID: peerID[:], Session: s.ledger.Session()}
+	for _, t := range client.Torrents() {
+		ht := handoverTorrent{InfoHash: t.InfoHash().HexString(), Uploaded: s.sessionUploaded(t)}
+		if s.queue.IsQueued(ht.InfoHash) {
+			ht.Queued = s.queue.kind(ht.InfoHash)
+		}
+		if t.Info() != nil {
+			var buf bytes.Buffer
+			mi := t.Metainfo()
+			if err := mi.Write(&buf); err != nil {
+				return fmt.Errorf("❌ Failed to encode metainfo for %s: %w", t.Name(), err)
+			}
+			ht.MetaInfo = buf.Bytes()
+		}
+		state.Torrents = append(state.Torrents, ht)
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(downloadDir, handoverFileName), data, 0600)
+}
+
+// loadHandoverState reads and removes the state left by the previous process. It returns nil
+// when this process was not started by an upgrade.
+func loadHandoverState(downloadDir string) *handoverState {
+	if !isUpgradeChild() {
+		return nil
+	}
+	path := filepath.Join(downloadDir, handoverFileName)
+	defer os.Remove(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Warning: Could not read handover state: %v", err)
+		return nil
+	}
+	var state handoverState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("Warning: Could not parse handover state: %v", err)
+		return nil
+	}
+	return &state
+}
+
+// restoreHandoverTorrents re-adds torrents of the previous process that weren't configured
+// again. Metadata is carried over, so magnets don't need to fetch it from the swarm again, and
+// queued torrents stay queued if queueing is still enabled.
+func (s *Seeder) restoreHandoverTorrents(ctx context.Context, client *torrent.Client, state *handoverState, queueing bool) {
+	restored := 0
+	for _, ht := range state.Torrents {
+		var mi *metainfo.MetaInfo
+		if len(ht.MetaInfo) > 0 {
+			var err error
+			if mi, err = metainfo.Load(bytes.NewReader(ht.MetaInfo)); err != nil {
+				log.Printf("⚠️ Error decoding handed over metainfo for %s: %v", ht.InfoHash, err)
+			}
+		}
+
+		var ih metainfo.Hash
+		if err := ih.FromHexString(ht.InfoHash); err != nil {
+			log.Printf("⚠️ Invalid handed over infohash '%s': %v", ht.InfoHash, err)
+			continue
+		}
+		if t, ok := client.Torrent(ih); ok {
+			if t.Info() == nil && mi != nil {
+				if err := t.SetInfoBytes(mi.InfoBytes); err != nil {
+					log.Printf("⚠️ Error restoring metadata for %s: %v", ht.InfoHash, err)
+				}
+			}
+			continue
+		}
+
+		var (
+			t   *torrent.Torrent
+			err error
+		)
+		if mi != nil {
+			s.preferUDP.filterMeta(mi)
+			t, err = client.AddTorrent(mi)
+		} else {
+			t, err = client.AddMagnet("magnet:?xt=urn:btih:" + ht.InfoHash)
+		}
+		if err != nil {
+			log.Printf("⚠️ Error restoring torrent %s: %v", ht.InfoHash, err)
+			continue
+		}
+		restored++
+		s.goTorrentTask(ctx, t, "seeding", func(ctx context.Context) { s.seedTorrent(ctx, client, t) })
+	}
+	log.Printf("♻️ Restored %d additional torrents from previous process", restored)
+
+	if queueing {
+		for _, ht := range state.Torrents {
+			if ht.Queued != "" {
+				s.restoreQueueState(client, ht)
+			}
+		}
+	}
+}
+
+// Queue the torrent as it was in the previous process, until the next rotation
+func (s *Seeder) restoreQueueState(client *torrent.Client, ht handoverTorrent) {
+	var ih metainfo.Hash
+	if err := ih.FromHexString(ht.InfoHash); err != nil {
+		return
+	}
+	if t, ok := client.Torrent(ih); ok && t.Info() != nil {
+		s.applyQueueState(t, ht.Queued)
+	}
+}
+
+// retryDuringUpgrade retries f while the previous process is still releasing its sockets
+// during an upgrade.
+func retryDuringUpgrade(f func() error) (err error) {
+	attempts := 1
+	if isUpgradeChild() {
+		attempts = 20
+	}
+	for i := 0; i < attempts; i++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package distroseed
+
+import (
+	"errors"
+	"net"
+	"os"
+)
+
+// In-place binary upgrades aren't supported on this platform.
+var upgradeSignals []os.Signal
+
+func startUpgradedProcess(listeners map[string]net.Listener) (int, error) {
+	return 0, errors.New("❌ In-place upgrades are not supported on this platform")
+}
@@ -0,0 +1,93 @@
+package distroseed
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandoverRoundTrip(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	old := newTestClient(t, dir)
+	mi := newTestMeta(t, dir, "a.iso", 64<<10)
+	if _, err := old.AddTorrent(mi); err != nil {
+		t.Fatal(err)
+	}
+	ih := mi.HashInfoBytes().HexString()
+	s.queue = &torrentQueue{queued: map[string]string{ih: queuedSeed}}
+	s.inheritedUploads[ih] = 1000
+	if _, err := old.AddMagnet("magnet:?xt=urn:btih:0123456789abcdef0123456789abcdef01234567"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.saveHandoverState(old, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if state := loadHandoverState(dir); state != nil {
+		t.Fatalf("a process that wasn't upgraded loaded %+v", state)
+	}
+	t.Setenv(upgradeEnv, "1")
+	state := loadHandoverState(dir)
+	if state == nil {
+		t.Fatal("the upgraded process loaded no state")
+	}
+	if _, err := os.Stat(filepath.Join(dir, handoverFileName)); !os.IsNotExist(err) {
+		t.Errorf("the handover file is still there: %v", err)
+	}
+	if peerID := old.PeerID(); string(state.PeerID) != string(peerID[:]) {
+		t.Errorf("handed over peer ID %x, want %x", state.PeerID, peerID)
+	}
+	if len(state.Torrents) != 2 {
+		t.Fatalf("handed over %d torrents, want 2", len(state.Torrents))
+	}
+	for _, ht := range state.Torrents {
+		if ht.InfoHash == ih && (ht.Uploaded != 1000 || ht.Queued != queuedSeed) {
+			t.Errorf("handed over %+v, want the uploads of earlier processes and its queue state", ht)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	nextDir := t.TempDir()
+	setTestSeederState(s, nextDir)
+	next := newTestClient(t, nextDir)
+	s.queue = &torrentQueue{queued: make(map[string]string)}
+	s.restoreHandoverTorrents(ctx, next, state, true)
+	if len(next.Torrents()) != 2 {
+		t.Fatalf("restored %d torrents, want 2", len(next.Torrents()))
+	}
+	restored, ok := next.Torrent(mi.HashInfoBytes())
+	if !ok || restored.Info() == nil {
+		t.Error("the torrent with metadata was restored without it")
+	}
+	if got := s.queue.kind(ih); got != queuedSeed {
+		t.Errorf("restored torrent is queued for %q, want %q", got, queuedSeed)
+	}
+}
+
+func TestListenOrInheritTakesInheritedListener(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	s := newSeeder(nil)
+	s.inherited = &inheritedListeners{listeners: map[string]net.Listener{"peer": l}}
+
+	got, err := s.listenOrInherit("peer", "tcp", "127.0.0.1:0")
+	if err != nil || got != l {
+		t.Fatalf("listenOrInherit = %v, %v, want the inherited listener", got, err)
+	}
+	// Only once, a second listener is opened anew
+	again, err := s.listenOrInherit("peer", "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer again.Close()
+	if again == l {
+		t.Error("the inherited listener was handed out twice")
+	}
+}
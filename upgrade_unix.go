@@ -0,0 +1,66 @@
+//go:build unix
+
+package distroseed
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Signals that trigger an in-place binary upgrade.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+type fileListener interface {
+	File() (*os.File, error)
+}
+
+// startUpgradedProcess execs the binary at our own path with the same arguments, passing it
+// the given listeners so no incoming connections are refused while it starts, and returns its pid.
+func startUpgradedProcess(listeners map[string]net.Listener) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("❌ Failed to locate executable: %w", err)
+	}
+
+	var (
+		names []string
+		files []*os.File
+	)
+	for name, l := range listeners {
+		fl, ok := l.(fileListener)
+		if !ok {
+			return 0, fmt.Errorf("❌ Listener '%s' can't be handed over", name)
+		}
+		file, err := fl.File()
+		if err != nil {
+			return 0, fmt.Errorf("❌ Failed to duplicate '%s' listener: %w", name, err)
+		}
+		defer file.Close()
+		names = append(names, name)
+		files = append(files, file)
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = files
+	// The watchdog belongs to whichever process systemd considers the main one
+	env := slices.DeleteFunc(os.Environ(), func(kv string) bool {
+		return strings.HasPrefix(kv, "WATCHDOG_PID=")
+	})
+	cmd.Env = append(env,
+		upgradeEnv+"=1",
+		listenFDsEnv+"="+strings.Join(names, ","),
+	)
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("❌ Failed to start upgraded binary: %w", err)
+	}
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
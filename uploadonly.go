@@ -0,0 +1,158 @@
+package distroseed
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const uploadOnlyCheckInterval = 1 * time.Second // How often to see if the client is done hashing
+
+// uploadOnlyGuard makes sure a seed-only box never downloads. Torrents are added without
+// trackers, downloads, or uploads, and only announced once their data is found complete on disk.
+// Incomplete ones are flagged and kept out of the swarm.
+type uploadOnlyGuard struct {
+	seeder  *Seeder
+	mu      sync.Mutex
+	held    map[string]*torrent.TorrentSpec // Torrents not cleared yet, with the trackers held back
+	missing map[string]int64                // Bytes missing from the flagged torrents
+}
+
+func newUploadOnlyGuard(s *Seeder) *uploadOnlyGuard {
+	return &uploadOnlyGuard{seeder: s, held: make(map[string]*torrent.TorrentSpec), missing: make(map[string]int64)}
+}
+
+// add adds the torrent held back from the swarm until check clears it
+func (g *uploadOnlyGuard) add(client *torrent.Client, meta *metainfo.MetaInfo) (*torrent.Torrent, error) {
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(meta)
+	if err != nil {
+		return nil, fmt.Errorf("❌ Failed to add torrent: %w", err)
+	}
+	opts := spec.AddTorrentOpts
+	opts.DisallowDataDownload = true
+	opts.DisallowDataUpload = true
+	opts.IgnoreUnverifiedPieceCompletion = true // Files of the right size aren't taken as complete
+	opts.DisableInitialPieceCheck = g.seeder.verifications != nil
+	t, isNew := client.AddTorrentOpt(opts)
+	if isNew {
+		g.seeder.verifications.hold(t.InfoHash().HexString())
+		g.mu.Lock()
+		g.held[t.InfoHash().HexString()] = spec
+		g.mu.Unlock()
+	}
+	return t, nil
+}
+
+// check waits for the client to hash the torrent's data on disk, then announces it if it's
+// complete, or flags it otherwise. It reports whether the torrent can be seeded.
+func (g *uploadOnlyGuard) check(ctx context.Context, t *torrent.Torrent) bool {
+	ih := t.InfoHash().HexString()
+	t.DisallowDataDownload()
+	g.mu.Lock()
+	spec, held := g.held[ih]
+	if !held {
+		// Magnets are announced to fetch their metadata, so they're held back from here on
+		g.held[ih] = nil
+		t.DisallowDataUpload()
+	}
+	g.mu.Unlock()
+
+	ticker := time.NewTicker(uploadOnlyCheckInterval)
+	defer ticker.Stop()
+	for slices.ContainsFunc(t.PieceStateRuns(), func(r torrent.PieceStateRun) bool {
+		return r.Hashing || r.QueuedForHash || r.Marking
+	}) {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.Closed():
+			return false
+		case <-ticker.C:
+		}
+	}
+
+	if missing := t.BytesMissing(); missing > 0 {
+		g.mu.Lock()
+		g.missing[ih] = missing
+		g.mu.Unlock()
+		t.ModifyTrackers(nil)
+		log.Printf("❌ %s is missing %.2f MB on disk, not seeding it in upload-only mode", t.Name(), float64(missing)/1024/1024)
+		if g.seeder.notifications != nil {
+			g.seeder.notifications.Notify("upload-only:"+ih, "Missing data: "+t.Name(),
+				fmt.Sprintf("%s is missing %.2f MB on disk. It isn't being downloaded or announced, since the seeder runs in upload-only mode.", t.Name(), float64(missing)/1024/1024))
+		}
+		return false
+	}
+
+	g.mu.Lock()
+	delete(g.held, ih)
+	g.mu.Unlock()
+	t.AllowDataUpload()
+	g.seeder.quota.restrict(t)
+	g.seeder.schedule.restrict(t)
+	g.seeder.pauses.restrict(t)
+	if spec != nil {
+		if g.seeder.pauses.hold(ih, spec.Trackers) || g.seeder.schedule.hold(ih, spec.Trackers) {
+			spec.Trackers = nil // Announced to once the pause is over
+		}
+		if err := t.MergeSpec(spec); err != nil {
+			log.Printf("⚠️ Error adding trackers for %s: %v", t.Name(), err)
+		}
+	}
+	return true
+}
+
+// addTrackers adds trackers to the ones a held torrent is announced to once it's checked,
+// reporting whether it's held with its trackers taken away
+func (g *uploadOnlyGuard) addTrackers(infoHash string, tiers [][]string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	spec := g.held[infoHash]
+	if spec == nil {
+		return false
+	}
+	spec.Trackers = appendTrackers(spec.Trackers, tiers)
+	return true
+}
+
+// Held reports whether the torrent is being checked or was flagged, and mustn't be announced
+func (g *uploadOnlyGuard) Held(infoHash string) bool {
+	if g == nil {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	_, ok := g.held[infoHash]
+	return ok
+}
+
+// Missing returns the bytes missing from a flagged torrent
+func (g *uploadOnlyGuard) Missing(infoHash string) (int64, bool) {
+	if g == nil {
+		return 0, false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	missing, ok := g.missing[infoHash]
+	return missing, ok
+}
+
+// restrict undoes what allowing a torrent's data transfers would let through in upload-only mode
+func (g *uploadOnlyGuard) restrict(t *torrent.Torrent) {
+	if g == nil {
+		return
+	}
+	t.DisallowDataDownload()
+	if g.Held(t.InfoHash().HexString()) {
+		t.DisallowDataUpload()
+	}
+}
@@ -0,0 +1,75 @@
+package distroseed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestUploadOnlyGuard(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	g := newUploadOnlyGuard(s)
+	const tracker = "http://127.0.0.1:1/announce"
+
+	add := func(name string) (*metainfo.MetaInfo, string) {
+		meta := newTestMeta(t, dir, name, 64<<10)
+		meta.Announce = tracker
+		return meta, meta.HashInfoBytes().HexString()
+	}
+	completeMeta, complete := add("complete.iso")
+	partialMeta, partial := add("partial.iso")
+	if err := os.Truncate(filepath.Join(dir, "partial.iso"), 16<<10); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		meta     *metainfo.MetaInfo
+		ih       string
+		seedable bool
+	}{
+		{completeMeta, complete, true},
+		{partialMeta, partial, false},
+	} {
+		tor, err := g.add(client, tt.meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !g.Held(tt.ih) {
+			t.Errorf("%s isn't held before it's checked", tor.Name())
+		}
+		if trackers := trackerTiers(tor); len(trackers) != 0 {
+			t.Errorf("%s was added with trackers %v before it was checked", tor.Name(), trackers)
+		}
+		if got := g.check(context.Background(), tor); got != tt.seedable {
+			t.Errorf("check(%s) = %v, want %v", tor.Name(), got, tt.seedable)
+		}
+		if g.Held(tt.ih) == tt.seedable {
+			t.Errorf("%s held = %v after the check", tor.Name(), g.Held(tt.ih))
+		}
+		missing, flagged := g.Missing(tt.ih)
+		if flagged == tt.seedable || (flagged && missing <= 0) {
+			t.Errorf("%s missing = %d, %v after the check", tor.Name(), missing, flagged)
+		}
+		hasTracker := len(trackerTiers(tor)) > 0
+		if hasTracker != tt.seedable {
+			t.Errorf("%s has trackers = %v after the check, want %v", tor.Name(), hasTracker, tt.seedable)
+		}
+	}
+
+	var none *uploadOnlyGuard
+	if none.Held(complete) {
+		t.Error("torrents are held without upload-only mode")
+	}
+}
+
+// trackerTiers returns the trackers the torrent announces to
+func trackerTiers(tor *torrent.Torrent) [][]string {
+	mi := tor.Metainfo()
+	return mi.UpvertedAnnounceList()
+}
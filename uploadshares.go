@@ -0,0 +1,187 @@
+package distroseed
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+const (
+	shareInterval  = 10 * time.Second // How often the upload limit is shared out again
+	shareMinimum   = 16               // KiB/s every torrent may upload, so new leechers get started before the next share
+	shareSaturated = 0.8              // Torrents using this much of their share want more
+)
+
+// Weights of torrents by swarm priority, on top of their sources' weight
+var shareWeights = map[swarmPriority]float64{priorityNormal: 1, priorityRare: 2, priorityCrowded: 0.5}
+
+// shareClaim is what a torrent asks of the upload limit
+type shareClaim struct {
+	InfoHash string  `json:"infohash"`
+	Weight   float64 `json:"weight"`
+	Demand   int64   `json:"demand"` // KiB/s it could use, 0 if it has no leechers
+	Share    int64   `json:"share"`  // KiB/s it may upload
+}
+
+// uploadShares divides the global upload limit between torrents by weight, giving what the ones
+// that can't use their share leave over to the others, so one popular release can't take all of
+// it from peers of the other torrents just by asking first
+type uploadShares struct {
+	seeder *Seeder
+	mu     sync.Mutex
+	claims []shareClaim // As last shared out, largest share first
+}
+
+func newUploadShares(s *Seeder) *uploadShares {
+	return &uploadShares{seeder: s}
+}
+
+// run shares out the upload limit every shareInterval until the context is cancelled
+func (s *uploadShares) run(ctx context.Context, client *torrent.Client) {
+	ticker := time.NewTicker(shareInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.update(client)
+		}
+	}
+}
+
+// update works out the torrents' claims from their leechers and how much of their last share
+// they used, and limits each to its share. Without an upload limit there's nothing to share.
+func (s *uploadShares) update(client *torrent.Client) {
+	upload, _ := s.seeder.bandwidth.Limits(s.seeder.liveSettings.Get().UploadLimit, 0)
+	if upload == 0 {
+		s.mu.Lock()
+		s.claims = nil
+		s.mu.Unlock()
+		s.seeder.bandwidth.setShares(nil)
+		return
+	}
+	previous := make(map[string]int64)
+	for _, c := range s.Claims() {
+		previous[c.InfoHash] = c.Share
+	}
+	var claims []shareClaim
+	for _, t := range client.Torrents() {
+		h, ok := swarmHealthOf(t)
+		if !ok {
+			continue
+		}
+		ih := t.InfoHash().HexString()
+		claims = append(claims, shareClaim{
+			InfoHash: ih,
+			Weight:   shareWeight(s.seeder.optionsOf(t).Weight, s.seeder.priorities.Of(ih), s.seeder.leechBoosts.Boosted(ih)),
+			Demand:   shareDemand(h.Leechers, s.seeder.rates.Torrent(ih).Upload/1024, previous[ih], upload),
+		})
+	}
+	claims = shareOut(upload, claims)
+
+	limits := make(map[string]int64, len(claims))
+	for _, c := range claims {
+		limits[c.InfoHash] = c.Share
+	}
+	s.mu.Lock()
+	s.claims = claims
+	s.mu.Unlock()
+	s.seeder.bandwidth.setShares(limits)
+}
+
+// Claims returns the torrents' claims and shares as last shared out
+func (s *uploadShares) Claims() []shareClaim {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.claims)
+}
+
+// shareWeight is a torrent's weight from its sources', its swarm priority and whether it's
+// favored while it downloads
+func shareWeight(weight float64, priority swarmPriority, leeching bool) float64 {
+	if weight == 0 {
+		weight = 1
+	}
+	if leeching {
+		weight *= leechShareWeight
+	}
+	return weight * shareWeights[priority]
+}
+
+// shareDemand estimates the KiB/s a torrent could upload. One using most of its share may be held
+// back by it, so could use all of the limit. Others are given some room to grow on what they use.
+func shareDemand(leechers int, rate, share, limit int64) int64 {
+	switch {
+	case leechers == 0:
+		return 0
+	case share == 0 || float64(rate) >= float64(share)*shareSaturated:
+		return limit
+	default:
+		return rate + rate/4 + shareMinimum
+	}
+}
+
+// shareOut divides the limit between the claims by weighted max-min fairness. Each torrent gets
+// its demand or its weight's part of what's left, whichever is less, repeated until what's left
+// is only wanted by torrents that would take more than their part. Whatever nobody wants is
+// shared by weight between the torrents with leechers, and torrents without any get
+// shareMinimum.
+func shareOut(limit int64, claims []shareClaim) []shareClaim {
+	claims = slices.Clone(claims)
+	left := float64(limit)
+	var waiting []int
+	for i, c := range claims {
+		if c.Demand > 0 {
+			waiting = append(waiting, i)
+		}
+	}
+	for len(waiting) > 0 {
+		var weights float64
+		for _, i := range waiting {
+			weights += claims[i].Weight
+		}
+		satisfied := false
+		waiting = slices.DeleteFunc(waiting, func(i int) bool {
+			if float64(claims[i].Demand) > left*claims[i].Weight/weights {
+				return false
+			}
+			claims[i].Share = claims[i].Demand
+			left -= float64(claims[i].Demand)
+			satisfied = true
+			return true
+		})
+		if !satisfied {
+			for _, i := range waiting {
+				claims[i].Share = int64(left * claims[i].Weight / weights)
+			}
+			left = 0
+			break
+		}
+	}
+
+	// Room to grow for the torrents with leechers, from whatever nobody wanted
+	var weights float64
+	for _, c := range claims {
+		if c.Demand > 0 {
+			weights += c.Weight
+		}
+	}
+	for i, c := range claims {
+		if c.Demand > 0 {
+			claims[i].Share += int64(left * c.Weight / weights)
+		}
+		claims[i].Share = max(claims[i].Share, shareMinimum)
+	}
+	slices.SortFunc(claims, func(a, b shareClaim) int {
+		return cmp.Or(cmp.Compare(b.Share, a.Share), cmp.Compare(a.InfoHash, b.InfoHash))
+	})
+	return claims
+}
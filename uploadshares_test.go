@@ -0,0 +1,101 @@
+package distroseed
+
+import (
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestShareOut(t *testing.T) {
+	share := func(claims []shareClaim, infoHash string) int64 {
+		for _, c := range claims {
+			if c.InfoHash == infoHash {
+				return c.Share
+			}
+		}
+		t.Fatalf("no share for %s", infoHash)
+		return 0
+	}
+
+	// A hot release wanting everything doesn't starve the others
+	claims := shareOut(1000, []shareClaim{
+		{InfoHash: "hot", Weight: 1, Demand: 1000},
+		{InfoHash: "rare", Weight: 2, Demand: 1000},
+		{InfoHash: "small", Weight: 1, Demand: 100},
+		{InfoHash: "idle", Weight: 1},
+	})
+	if claims[0].InfoHash != "rare" {
+		t.Errorf("largest share went to %s, want the heaviest", claims[0].InfoHash)
+	}
+	if got := share(claims, "small"); got != 100 {
+		t.Errorf("small share = %d, want all it asked for", got)
+	}
+	if hot, rare := share(claims, "hot"), share(claims, "rare"); hot != 300 || rare != 600 {
+		t.Errorf("hot and rare shares = %d and %d, want what's left split 1:2", hot, rare)
+	}
+	if got := share(claims, "idle"); got != shareMinimum {
+		t.Errorf("share without leechers = %d, want %d", got, shareMinimum)
+	}
+
+	// What nobody wants is shared by weight, so torrents can grow into it
+	claims = shareOut(1000, []shareClaim{
+		{InfoHash: "a", Weight: 1, Demand: 100},
+		{InfoHash: "b", Weight: 3, Demand: 100},
+	})
+	if a, b := share(claims, "a"), share(claims, "b"); a != 300 || b != 700 {
+		t.Errorf("shares with bandwidth to spare = %d and %d, want 300 and 700", a, b)
+	}
+}
+
+func TestShareDemand(t *testing.T) {
+	tests := []struct {
+		leechers           int
+		rate, share, limit int64
+		want               int64
+	}{
+		{0, 0, 100, 1000, 0},
+		{2, 0, 0, 1000, 1000},    // Not shared out yet
+		{2, 90, 100, 1000, 1000}, // Held back by its share
+		{2, 40, 100, 1000, 40 + 10 + shareMinimum},
+	}
+	for _, tt := range tests {
+		if got := shareDemand(tt.leechers, tt.rate, tt.share, tt.limit); got != tt.want {
+			t.Errorf("shareDemand(%d, %d, %d, %d) = %d, want %d", tt.leechers, tt.rate, tt.share, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestShareWeight(t *testing.T) {
+	if got := shareWeight(0, priorityNormal, false); got != 1 {
+		t.Errorf("default weight = %v, want 1", got)
+	}
+	if got := shareWeight(3, priorityRare, false); got != 6 {
+		t.Errorf("weight of a rare torrent weighted 3 = %v, want 6", got)
+	}
+	if got := shareWeight(1, priorityCrowded, false); got != 0.5 {
+		t.Errorf("weight of a crowded torrent = %v, want 0.5", got)
+	}
+	if got := shareWeight(0, priorityNormal, true); got != leechShareWeight {
+		t.Errorf("weight of a torrent favored while it downloads = %v, want %v", got, leechShareWeight)
+	}
+}
+
+func TestBandwidthScheduleShares(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	sched := newTestBandwidthSchedule(s)
+	limiter := sched.torrentLimiter("aa")
+	sched.setTorrentLimits(map[string]int64{"aa": 256})
+	sched.setShares(map[string]int64{"aa": 128})
+	if limiter.Limit() != rate.Limit(128*1024) {
+		t.Errorf("limit = %v, want the share when it's lower", limiter.Limit())
+	}
+	sched.setShares(map[string]int64{"aa": 512})
+	if limiter.Limit() != rate.Limit(256*1024) {
+		t.Errorf("limit = %v, want the torrent's own limit when it's lower", limiter.Limit())
+	}
+	sched.setShares(nil)
+	sched.setTorrentLimits(nil)
+	if limiter.Limit() != rate.Inf {
+		t.Errorf("limit without shares = %v, want unlimited", limiter.Limit())
+	}
+}
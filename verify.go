@@ -0,0 +1,77 @@
+package distroseed
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// verifyPieceSample hashes up to n randomly chosen pieces of the torrent's payload under
+// dataDir, without involving the client, and returns how many didn't match. Payloads stored
+// encrypted are decrypted with key, which is nil otherwise.
+func verifyPieceSample(info *metainfo.Info, dataDir string, key []byte, n int) (checked, failed int, err error) {
+	if !info.HasV1() {
+		return 0, 0, fmt.Errorf("only v1 piece hashes are supported")
+	}
+	var block cipher.Block
+	if key != nil {
+		if block, err = aes.NewCipher(key); err != nil {
+			return 0, 0, err
+		}
+	}
+	numPieces := info.NumPieces()
+	for _, i := range rand.Perm(numPieces)[:min(n, numPieces)] {
+		data, err := readPiece(info, dataDir, i)
+		if err != nil {
+			return checked, failed, err
+		}
+		if block != nil {
+			xorKeyStream(block, data, info.Piece(i).Offset())
+		}
+		checked++
+		hash := sha1.Sum(data)
+		if !bytes.Equal(hash[:], info.Pieces[i*sha1.Size:(i+1)*sha1.Size]) {
+			failed++
+		}
+	}
+	return checked, failed, nil
+}
+
+// readPiece reads a piece from the payload files as laid out by the client's file storage
+func readPiece(info *metainfo.Info, dataDir string, index int) ([]byte, error) {
+	piece := info.Piece(index)
+	begin := piece.Offset()
+	end := begin + piece.V1Length()
+	data := make([]byte, 0, end-begin)
+
+	for fi := range info.UpvertedV1Files() {
+		fileBegin, fileEnd := fi.TorrentOffset, fi.TorrentOffset+fi.Length
+		if fileEnd <= begin || fileBegin >= end {
+			continue
+		}
+
+		path := filepath.Join(append([]string{dataDir, info.BestName()}, fi.BestPath()...)...)
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		readBegin := max(begin, fileBegin)
+		readEnd := min(end, fileEnd)
+		buf := make([]byte, readEnd-readBegin)
+		_, err = f.ReadAt(buf, readBegin-fileBegin)
+		f.Close()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		data = append(data, buf...)
+	}
+	return data, nil
+}
@@ -0,0 +1,289 @@
+package distroseed
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	verifyWindow        = 64              // Piece checks handed to the client at once per torrent
+	verifyQueueInterval = 5 * time.Second // How often waiting torrents are reconsidered as priorities change
+)
+
+// States of a torrent in the verification queue
+const (
+	verifyWaiting  = "waiting"
+	verifyDeferred = "deferred"
+	verifyActive   = "verifying"
+)
+
+// verifyQueue staggers the initial verification of torrents' data, so a restart with many
+// torrents doesn't hash them all at once. Torrents are added with the client's initial piece check
+// off, and verified at most limit at a time in the order they were added. Low priority torrents
+// can be put off until nothing else is being verified.
+type verifyQueue struct {
+	seeder   *Seeder
+	mu       sync.Mutex
+	limit    int  // 0 for no limit
+	deferLow bool // Queued and crowded torrents wait for the others
+	held     map[string]bool
+	waiting  []*queuedVerify // In the order they were added
+	active   map[string]*activeVerify
+	changed  chan struct{} // Closed when a torrent starts or finishes verifying
+}
+
+type queuedVerify struct {
+	infoHash string
+	since    time.Time
+}
+
+type activeVerify struct {
+	started  time.Time
+	unqueued int // Pieces not handed to the client yet
+}
+
+func newVerifyQueue(s *Seeder, limit int, deferLow bool) *verifyQueue {
+	return &verifyQueue{
+		seeder:   s,
+		limit:    limit,
+		deferLow: deferLow,
+		held:     make(map[string]bool),
+		active:   make(map[string]*activeVerify),
+		changed:  make(chan struct{}),
+	}
+}
+
+// hold records a torrent added without its initial piece check, to be verified by verify
+func (q *verifyQueue) hold(infoHash string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	q.held[infoHash] = true
+	q.mu.Unlock()
+}
+
+// lowPriority reports whether a torrent's verification can wait until nothing else is verified
+func (q *verifyQueue) lowPriority(infoHash string) bool {
+	return q.deferLow && (q.seeder.queue.IsQueued(infoHash) || q.seeder.priorities.Of(infoHash) == priorityCrowded)
+}
+
+// next returns the waiting torrent to verify next, nil if none may start yet. The caller holds mu.
+func (q *verifyQueue) next() *queuedVerify {
+	if q.limit > 0 && len(q.active) >= q.limit {
+		return nil
+	}
+	var deferred *queuedVerify
+	for _, v := range q.waiting {
+		if !q.lowPriority(v.infoHash) {
+			return v
+		}
+		if deferred == nil {
+			deferred = v
+		}
+	}
+	if len(q.active) > 0 {
+		return nil
+	}
+	return deferred
+}
+
+// notify wakes the torrents waiting for their turn. The caller holds mu.
+func (q *verifyQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+// verify waits for the torrent's turn, then has the client check the pieces whose state it
+// doesn't know. It reports whether the torrent was verified, rather than dropped or ctx done.
+func (q *verifyQueue) verify(ctx context.Context, t *torrent.Torrent) bool {
+	if q == nil {
+		return true
+	}
+	ih := t.InfoHash().HexString()
+	q.mu.Lock()
+	if !q.held[ih] {
+		q.mu.Unlock()
+		return true
+	}
+	v := &queuedVerify{infoHash: ih, since: time.Now()}
+	q.waiting = append(q.waiting, v)
+	q.mu.Unlock()
+	defer q.finish(v)
+	ctx, span := tracer.Start(ctx, "torrent.verify", torrentAttributes(t))
+	defer span.End()
+
+	ticker := time.NewTicker(verifyQueueInterval)
+	defer ticker.Stop()
+	for logged := false; ; logged = true {
+		q.mu.Lock()
+		start := q.next() == v
+		if start {
+			q.waiting = slices.DeleteFunc(q.waiting, func(w *queuedVerify) bool { return w == v })
+			q.active[ih] = &activeVerify{started: time.Now()}
+			q.notify()
+		}
+		changed := q.changed
+		ahead := slices.Index(q.waiting, v) + len(q.active)
+		q.mu.Unlock()
+		if start {
+			break
+		}
+		if !logged {
+			log.Printf("⏳ Waiting to verify %s, %d torrents ahead of it", t.Name(), ahead)
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-t.Closed():
+			return false
+		case <-changed:
+		case <-ticker.C:
+		}
+	}
+
+	var unchecked []int
+	i := 0
+	for _, r := range t.PieceStateRuns() {
+		if !r.Ok {
+			for j := range r.Length {
+				unchecked = append(unchecked, i+j)
+			}
+		}
+		i += r.Length
+	}
+	q.setUnqueued(ih, len(unchecked))
+	span.AddEvent("hashing", trace.WithAttributes(attribute.Int("pieces", len(unchecked))))
+
+	// The client hashes a piece a check is waiting for, so a window of them keeps its hashers busy
+	window := make(chan struct{}, verifyWindow)
+	var wg sync.WaitGroup
+	for n, i := range unchecked {
+		select {
+		case window <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		q.setUnqueued(ih, len(unchecked)-n-1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t.Piece(i).VerifyDataContext(ctx)
+			<-window
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-t.Closed():
+		return false
+	default:
+		if ctx.Err() != nil {
+			return false
+		}
+		if len(unchecked) > 0 {
+			q.seeder.recordVerified(t)
+		}
+		return true
+	}
+}
+
+func (q *verifyQueue) setUnqueued(infoHash string, n int) {
+	q.mu.Lock()
+	if v, ok := q.active[infoHash]; ok {
+		v.unqueued = n
+	}
+	q.mu.Unlock()
+}
+
+// finish takes a torrent out of the queue, letting the next one start
+func (q *verifyQueue) finish(v *queuedVerify) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.waiting = slices.DeleteFunc(q.waiting, func(w *queuedVerify) bool { return w == v })
+	delete(q.active, v.infoHash)
+	delete(q.held, v.infoHash)
+	q.notify()
+}
+
+// Unqueued returns how many of the torrent's pieces are still to be handed to the client for hashing
+func (q *verifyQueue) Unqueued(infoHash string) int {
+	if q == nil {
+		return 0
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if v, ok := q.active[infoHash]; ok {
+		return v.unqueued
+	}
+	return 0
+}
+
+// queuedVerification is a torrent in the verification queue, as reported by the API
+type queuedVerification struct {
+	InfoHash   string    `json:"infohash"`
+	Name       string    `json:"name"`
+	State      string    `json:"state"`
+	Position   int       `json:"position,omitempty"`    // Place in line of a waiting torrent, from 1
+	Since      time.Time `json:"since,omitzero"`        // When it started waiting or verifying
+	PiecesLeft int       `json:"pieces_left,omitempty"` // Pieces of a verifying torrent still to hash
+}
+
+// Status lists the torrents being verified, then the ones waiting in the order they'll go. Without
+// a queue it lists the torrents the client is verifying.
+func (q *verifyQueue) Status(torrents []*torrent.Torrent) []queuedVerification {
+	byHash := make(map[string]*torrent.Torrent, len(torrents))
+	for _, t := range torrents {
+		byHash[t.InfoHash().HexString()] = t
+	}
+	list := []queuedVerification{}
+	if q == nil {
+		for ih, t := range byHash {
+			if pending := q.seeder.pendingHashes(t); pending > 0 {
+				list = append(list, queuedVerification{InfoHash: ih, Name: t.Name(), State: verifyActive, PiecesLeft: pending})
+			}
+		}
+		slices.SortFunc(list, func(a, b queuedVerification) int { return strings.Compare(a.Name, b.Name) })
+		return list
+	}
+
+	var waiting, deferred []queuedVerification
+	q.mu.Lock()
+	for ih, v := range q.active {
+		if t, ok := byHash[ih]; ok {
+			list = append(list, queuedVerification{InfoHash: ih, Name: t.Name(), State: verifyActive, Since: v.started})
+		}
+	}
+	for _, v := range q.waiting {
+		if t, ok := byHash[v.infoHash]; ok {
+			entry := queuedVerification{InfoHash: v.infoHash, Name: t.Name(), State: verifyWaiting, Since: v.since}
+			if q.lowPriority(v.infoHash) {
+				entry.State = verifyDeferred
+				deferred = append(deferred, entry)
+			} else {
+				waiting = append(waiting, entry)
+			}
+		}
+	}
+	q.mu.Unlock()
+
+	slices.SortFunc(list, func(a, b queuedVerification) int { return a.Since.Compare(b.Since) })
+	for i := range list {
+		list[i].PiecesLeft = q.seeder.pendingHashes(byHash[list[i].InfoHash])
+	}
+	for i, entry := range append(waiting, deferred...) {
+		entry.Position = i + 1
+		list = append(list, entry)
+	}
+	return list
+}
@@ -0,0 +1,120 @@
+package distroseed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+func TestVerifyQueueNext(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	s.priorities = &swarmPriorities{seeder: s, levels: map[string]swarmPriority{"crowded": priorityCrowded}}
+
+	tests := []struct {
+		name     string
+		limit    int
+		deferLow bool
+		waiting  []string
+		active   []string
+		want     string // "" if none may start
+	}{
+		{name: "first added goes first", waiting: []string{"a", "b"}, want: "a"},
+		{name: "no limit", waiting: []string{"a"}, active: []string{"b", "c"}, want: "a"},
+		{name: "under the limit", limit: 2, waiting: []string{"a"}, active: []string{"b"}, want: "a"},
+		{name: "at the limit", limit: 1, waiting: []string{"a"}, active: []string{"b"}},
+		{name: "crowded in order without deferring", waiting: []string{"crowded", "a"}, want: "crowded"},
+		{name: "crowded waits for the others", deferLow: true, waiting: []string{"crowded", "a"}, want: "a"},
+		{name: "crowded waits while others verify", deferLow: true, waiting: []string{"crowded"}, active: []string{"a"}},
+		{name: "crowded goes when nothing else is verified", deferLow: true, waiting: []string{"crowded"}, want: "crowded"},
+		{name: "nothing waiting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newVerifyQueue(s, tt.limit, tt.deferLow)
+			for _, ih := range tt.waiting {
+				q.waiting = append(q.waiting, &queuedVerify{infoHash: ih})
+			}
+			for _, ih := range tt.active {
+				q.active[ih] = &activeVerify{}
+			}
+			got := ""
+			if v := q.next(); v != nil {
+				got = v.infoHash
+			}
+			if got != tt.want {
+				t.Errorf("next() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyQueueVerify(t *testing.T) {
+	s := newTestSeeder(t, testConfig())
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	q := newVerifyQueue(s, 1, false)
+	add := func(name string) *torrent.Torrent {
+		spec, err := torrent.TorrentSpecFromMetaInfoErr(newTestMeta(t, dir, name, 64<<10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		spec.DisableInitialPieceCheck = true
+		tor, _, err := client.AddTorrentSpec(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q.hold(tor.InfoHash().HexString())
+		return tor
+	}
+	first, second := add("first.iso"), add("second.iso")
+
+	// Hold the only slot, so the second torrent waits its turn
+	q.mu.Lock()
+	q.active["busy"] = &activeVerify{unqueued: 3}
+	q.mu.Unlock()
+	if got := q.Unqueued("busy"); got != 3 {
+		t.Errorf("Unqueued() = %d, want 3", got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	verified := make(chan bool)
+	go func() { verified <- q.verify(ctx, second) }()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(time.Millisecond) {
+		status := q.Status(client.Torrents())
+		if len(status) == 1 && status[0].State == verifyWaiting && status[0].Position == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Status() = %+v, want the second torrent waiting first in line", status)
+		}
+	}
+	cancel()
+	if <-verified {
+		t.Error("a torrent was verified while another held the only slot")
+	}
+	if status := q.Status(client.Torrents()); len(status) != 0 {
+		t.Errorf("Status() = %+v after the waiting torrent gave up, want it empty", status)
+	}
+	q.finish(&queuedVerify{infoHash: "busy"})
+
+	if !q.verify(context.Background(), first) {
+		t.Fatal("verify() = false for a torrent with the slot free")
+	}
+	if pending := s.pendingHashes(first); pending != 0 {
+		t.Errorf("%d pieces still to hash after the torrent was verified", pending)
+	}
+	if q.held[first.InfoHash().HexString()] || len(q.active) != 0 || len(q.waiting) != 0 {
+		t.Errorf("the queue still tracks a verified torrent: held %v, active %v, waiting %d", q.held, q.active, len(q.waiting))
+	}
+
+	// A torrent that wasn't held was verified by the client
+	if !q.verify(context.Background(), first) {
+		t.Error("verify() = false for a torrent that wasn't held")
+	}
+	var none *verifyQueue
+	none.hold("ignored")
+	if !none.verify(context.Background(), second) {
+		t.Error("verify() = false without a queue")
+	}
+}
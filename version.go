@@ -0,0 +1,99 @@
+package distroseed
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Set at build time with -ldflags "-X github.com/pawl/distro-seed.version=v1.2.0", and commit and
+// buildDate the same way. Otherwise they're filled in from the module and VCS info Go embeds in
+// the binary.
+var (
+	version   string
+	commit    string
+	buildDate string
+)
+
+// When this process started, for the uptime in /api/status
+var processStarted = time.Now()
+
+// versionInfo identifies the build that's running
+type versionInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	Modified  bool   `json:"modified,omitempty"` // Built from a tree with uncommitted changes
+	GoVersion string `json:"go_version"`
+}
+
+// buildVersion works out the version info, preferring what was set with -ldflags
+func buildVersion() versionInfo {
+	v := versionInfo{Version: version, Commit: commit, BuildDate: buildDate, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v.Version == "" && info.Main.Version != "(devel)" {
+			v.Version = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if v.Commit == "" {
+					v.Commit = s.Value
+				}
+			case "vcs.time":
+				if v.BuildDate == "" {
+					v.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+	if v.Version == "" {
+		v.Version = "dev"
+	}
+	return v
+}
+
+var buildInfo = buildVersion()
+
+func (v versionInfo) String() string {
+	s := "distro-seed " + v.Version
+	var details []string
+	if v.Commit != "" {
+		c := v.Commit[:min(len(v.Commit), 12)]
+		if v.Modified {
+			c += "-dirty"
+		}
+		details = append(details, "commit "+c)
+	}
+	if v.BuildDate != "" {
+		details = append(details, "built "+v.BuildDate)
+	}
+	details = append(details, v.GoVersion)
+	return s + " (" + strings.Join(details, ", ") + ")"
+}
+
+// clientName is how we identify ourselves to peers in the extended handshake, and to trackers
+// and web seeds as the HTTP user agent
+func (v versionInfo) clientName() string {
+	return "distro-seed/" + strings.TrimPrefix(v.Version, "v")
+}
+
+// peerIDPrefix is the Azureus-style prefix of our peer ID (BEP 20), "-DS" followed by the
+// major, minor and patch versions and a zero, each as one base 36 digit
+func (v versionInfo) peerIDPrefix() string {
+	digits := []byte("0000")
+	core, _, _ := strings.Cut(strings.TrimPrefix(v.Version, "v"), "-")
+	for i, part := range strings.SplitN(core, ".", 3) {
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			break
+		}
+		digits[i] = strconv.FormatUint(min(n, 35), 36)[0]
+	}
+	return fmt.Sprintf("-DS%s-", digits)
+}
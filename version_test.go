@@ -0,0 +1,26 @@
+package distroseed
+
+import "testing"
+
+func TestPeerIDPrefix(t *testing.T) {
+	for v, want := range map[string]string{
+		"v1.2.3":          "-DS1230-",
+		"1.12.0-rc1":      "-DS1c00-",
+		"v0.0.0-2025abcd": "-DS0000-",
+		"dev":             "-DS0000-",
+	} {
+		if got := (versionInfo{Version: v}).peerIDPrefix(); got != want {
+			t.Errorf("peerIDPrefix for %s = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestVersionString(t *testing.T) {
+	v := versionInfo{Version: "v1.2.3", Commit: "0123456789abcdef", BuildDate: "2025-05-01T10:00:00Z", Modified: true, GoVersion: "go1.24.2"}
+	if got, want := v.String(), "distro-seed v1.2.3 (commit 0123456789ab-dirty, built 2025-05-01T10:00:00Z, go1.24.2)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := v.clientName(); got != "distro-seed/1.2.3" {
+		t.Errorf("client name %q", got)
+	}
+}
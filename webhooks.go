@@ -0,0 +1,153 @@
+package distroseed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+const (
+	webhookTimeout   = 30 * time.Second // Requests still unanswered after this long are given up on
+	webhookQueueSize = 100              // Events waiting to be sent, later ones are dropped
+)
+
+// defaultWebhookEvents are the events sent to the webhook unless others are given
+var defaultWebhookEvents = []string{eventCompleted}
+
+// webhookEvent is what's POSTed to the webhook for an event of a torrent, as JSON. What the
+// torrent no longer knows, like the size of one that was removed, is left out.
+type webhookEvent struct {
+	Event          string    `json:"event"`
+	Message        string    `json:"message,omitempty"`
+	Time           time.Time `json:"time"`
+	InfoHash       string    `json:"infohash"`
+	Name           string    `json:"name,omitempty"`
+	Path           string    `json:"path,omitempty"`
+	Size           int64     `json:"size,omitempty"`
+	Labels         []string  `json:"labels,omitempty"`
+	Sources        []string  `json:"sources,omitempty"`
+	TimeToComplete *duration `json:"time_to_complete,omitempty"`
+}
+
+// webhookSender POSTs torrent events to a URL, one at a time in the order they happened, for
+// services that would rather be told than run a hook, like chat bots or CI
+type webhookSender struct {
+	seeder *Seeder
+	url    string
+	events []string // Kinds of events to send
+	client *torrent.Client
+	queue  chan webhookEvent
+}
+
+func newWebhookSender(s *Seeder, url string, events []string, client *torrent.Client) *webhookSender {
+	if url == "" {
+		return nil
+	}
+	return &webhookSender{seeder: s, url: url, events: events, client: client, queue: make(chan webhookEvent, webhookQueueSize)}
+}
+
+// validateWebhook checks the webhook is an HTTP URL, and that the events for it are ones torrents
+// have
+func validateWebhook(webhook string, events []string) error {
+	if webhook == "" {
+		return nil
+	}
+	if u, err := url.Parse(webhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("❌ Invalid webhook '%s', it must be an http or https URL", webhook)
+	}
+	for _, kind := range events {
+		if !slices.Contains(eventKinds, kind) {
+			return fmt.Errorf("❌ Unknown webhook event '%s', use some of: %s", kind, strings.Join(eventKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// fire queues an event of a torrent to be sent, if it's one the webhook is for
+func (w *webhookSender) fire(infoHash, kind, message string) {
+	if w == nil || !slices.Contains(w.events, kind) {
+		return
+	}
+	e := webhookEvent{Event: kind, Message: message, Time: time.Now(), InfoHash: infoHash}
+	if w.seeder.registry != nil {
+		if entry, ok := w.seeder.registry.Entry(infoHash); ok {
+			e.Name, e.Path = entry.Name, entry.DataPath
+		}
+	}
+	select {
+	case w.queue <- e:
+	default:
+		log.Printf("⚠️ Too many events waiting for the webhook, not sending %s of %s", kind, infoHash)
+	}
+}
+
+// run sends queued events until the context is cancelled
+func (w *webhookSender) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-w.queue:
+			if err := w.send(ctx, w.describe(e)); err != nil {
+				log.Printf("⚠️ Webhook for %s of %s failed: %v", e.Event, e.InfoHash, err)
+			}
+		}
+	}
+}
+
+func (w *webhookSender) send(ctx context.Context, e webhookEvent) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// describe adds what the torrent knows about itself to the event
+func (w *webhookSender) describe(e webhookEvent) webhookEvent {
+	var ih metainfo.Hash
+	if ih.FromHexString(e.InfoHash) != nil {
+		return e
+	}
+	if t, ok := w.client.Torrent(ih); ok {
+		if t.Info() != nil {
+			e.Size = t.Length()
+			if e.Name == "" {
+				e.Name = t.Info().BestName()
+			}
+		}
+		e.Labels = w.seeder.optionsOf(t).Labels
+		e.Sources = w.seeder.torrentSources.URLs(t)
+	}
+	if w.seeder.completions != nil {
+		if took, ok := w.seeder.completions.TimeToComplete(e.InfoHash); ok {
+			e.TimeToComplete = (*duration)(&took)
+		}
+	}
+	return e
+}
@@ -0,0 +1,67 @@
+package distroseed
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWebhookSender(t *testing.T) {
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	cfg := testConfig()
+	cfg.TorrentURLs = []string{"a.torrent"}
+	cfg.TorrentOptions = map[string]torrentOptions{"a.torrent": {Labels: []string{"ubuntu"}}}
+	s := newTestSeeder(t, cfg)
+	setTestSeederState(s, dir)
+	a := addSeedingTestTorrent(t, client, dir, "a.iso")
+	s.torrentSources.Add("a.torrent", a)
+	ih := a.InfoHash().HexString()
+	s.registry.Record(ih, "a.torrent", "")
+	s.registry.SetName(ih, "a.iso", dir)
+	s.completions = loadCompletions(dir)
+	s.completions.record(ih, time.Now(), 5*time.Minute)
+
+	received := make(chan webhookEvent, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e webhookEvent
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			t.Error(err)
+		}
+		received <- e
+	}))
+	defer server.Close()
+	s.webhooks = newWebhookSender(s, server.URL, defaultWebhookEvents, client)
+
+	s.events.Record(ih, eventPaused, "")
+	s.events.Record(ih, eventCompleted, "32.0 KiB in 5m0s")
+	if len(s.webhooks.queue) != 1 {
+		t.Fatalf("%d events queued, want only the completion", len(s.webhooks.queue))
+	}
+	if err := s.webhooks.send(t.Context(), s.webhooks.describe(<-s.webhooks.queue)); err != nil {
+		t.Fatal(err)
+	}
+	e := <-received
+	if e.Event != eventCompleted || e.InfoHash != ih || e.Name != "a.iso" || e.Size != 32<<10 || !slices.Equal(e.Labels, []string{"ubuntu"}) ||
+		!slices.Equal(e.Sources, []string{"a.torrent"}) || e.TimeToComplete == nil || time.Duration(*e.TimeToComplete) != 5*time.Minute {
+		t.Errorf("webhook got %+v", e)
+	}
+}
+
+func TestValidateWebhook(t *testing.T) {
+	if err := validateWebhook("https://example.com/hook", []string{eventCompleted, eventError}); err != nil {
+		t.Errorf("valid webhook: %v", err)
+	}
+	if err := validateWebhook("example.com/hook", defaultWebhookEvents); err == nil {
+		t.Error("webhook without a scheme is valid")
+	}
+	if err := validateWebhook("https://example.com/hook", []string{"finished"}); err == nil {
+		t.Error("webhook for an unknown event is valid")
+	}
+	if err := validateWebhook("", nil); err != nil {
+		t.Errorf("no webhook: %v", err)
+	}
+}